@@ -0,0 +1,179 @@
+// Package admin implements the offline subcommands of the server binary,
+// which open a data directory directly while no server runs
+package admin
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/logging"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// Exit codes returned by Run
+const (
+	ExitOK      = 0 // Success, or fsck found nothing wrong
+	ExitFailure = 1 // The command failed, or fsck found problems it left
+	ExitUsage   = 2 // Invalid subcommand, flags or arguments
+)
+
+// subcommand is one offline subcommand
+type subcommand struct {
+	args    string
+	summary string
+	run     func(c *context, args []string) error
+}
+
+// subcommands lists the offline subcommands by name
+var subcommands = map[string]subcommand{
+	"inspect":  {"<datadir>", "Print graphs, their node, edge and key counts, and keys per key family", runInspect},
+	"export":   {"[-meta] <datadir> <graph> <out.json>", "Write a graph in the GRAPH.EXPORT format (\"-\" for stdout)", runExport},
+	"fsck":     {"[--repair] <datadir>", "Audit the keyspace, and with --repair fix what it finds", runFsck},
+	"backup":   {"<datadir> <out file>", "Write a manifest-wrapped backup of the database", runBackup},
+	"restore":  {"<datadir> <in file>", "Verify a backup and load it into the database", runRestore},
+	"generate": {"[-shape s] [-nodes n] [-degree m] [-seed s] <datadir> <graph>", "Create a graph of generated nodes and edges, for benchmarks and load tests", runGenerate},
+	"version":  {"", "Print the version and git commit the binary was built from", runVersion},
+}
+
+// errUsage reports invalid arguments after the usage has been printed
+var errUsage = errors.New("usage")
+
+// errHelp reports that -h printed the usage
+var errHelp = errors.New("help")
+
+// errProblems reports that fsck left problems, after printing them
+var errProblems = errors.New("problems found")
+
+// context is what a subcommand runs with
+type context struct {
+	flags  *flag.FlagSet
+	stdout io.Writer
+	stderr io.Writer
+	level  *string
+	logger *slog.Logger
+}
+
+// parse parses the flags, which may come before, between or after the
+// arguments, and returns the arguments if there are as many as want
+func (c *context) parse(args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := c.flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, errHelp
+			}
+			return nil, errUsage
+		}
+		args = c.flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != want {
+		fmt.Fprintf(c.stderr, "%s takes %d arguments, got %d\n", c.flags.Name(), want, len(positional))
+		c.flags.Usage()
+		return nil, errUsage
+	}
+
+	level, err := logging.ParseLevel(*c.level)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Invalid -log-level: %v\n", err)
+		return nil, errUsage
+	}
+	c.logger = logging.NewWriter(c.stderr, level)
+	return positional, nil
+}
+
+// open opens the data directory offline, read-only unless readOnly is
+// false. A read-only open fails if the directory does not exist rather
+// than creating it.
+func (c *context) open(dataDir string, readOnly bool) (*storage.BadgerEngine, error) {
+	if readOnly {
+		if _, err := os.Stat(dataDir); err != nil {
+			return nil, fmt.Errorf("data directory: %w", err)
+		}
+	}
+	engine := storage.NewBadgerEngine(storage.WithLogger(c.logger), storage.WithOffline(readOnly))
+	if err := engine.Open(dataDir); err != nil {
+		if errors.Is(err, badger.ErrTruncateNeeded) {
+			return nil, fmt.Errorf("failed to open %s: %w (fsck --repair opens it for writing)", dataDir, err)
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", dataDir, err)
+	}
+	return engine, nil
+}
+
+// Run runs the offline subcommand named by args[0] with the rest of args,
+// writing its output to stdout and errors to stderr, and returns the exit
+// code. No network listener is started; the data directory is opened
+// read-only unless the subcommand writes to it.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		Usage(stderr)
+		if len(args) == 0 {
+			return ExitUsage
+		}
+		return ExitOK
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown subcommand: %s\n", args[0])
+		Usage(stderr)
+		return ExitUsage
+	}
+
+	c := &context{
+		flags:  flag.NewFlagSet(args[0], flag.ContinueOnError),
+		stdout: stdout,
+		stderr: stderr,
+	}
+	c.flags.SetOutput(stderr)
+	c.flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s %s\n\n%s\n", args[0], cmd.args, cmd.summary)
+		c.flags.PrintDefaults()
+	}
+	c.level = c.flags.String("log-level", "warn", "Log level (debug, info, warn, error)")
+
+	switch err := cmd.run(c, args[1:]); {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errHelp):
+		return ExitOK
+	case errors.Is(err, errUsage):
+		return ExitUsage
+	case errors.Is(err, errProblems):
+		return ExitFailure
+	default:
+		fmt.Fprintf(stderr, "%s: %v\n", args[0], err)
+		return ExitFailure
+	}
+}
+
+// Usage writes the list of subcommands to w
+func Usage(w io.Writer) {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: redis-server [serve] [flags]")
+	fmt.Fprintln(w, "       redis-server <subcommand> [flags] <args>")
+	fmt.Fprintln(w, "\nServe runs the Redis protocol server and is the default. Subcommands work")
+	fmt.Fprintln(w, "on a data directory offline and must not be run while a server uses it:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", name, subcommands[name].args, subcommands[name].summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun a subcommand with -h for its flags.")
+}
@@ -0,0 +1,336 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/testutil/graphgen"
+	"github.com/ywadi/PathwayDB/version"
+)
+
+// runInspect handles inspect <datadir>: a table of graphs with their node,
+// edge and key counts, then one of keys per key family, then totals
+func runInspect(c *context, args []string) error {
+	args, err := c.parse(args, 1)
+	if err != nil {
+		return err
+	}
+	engine, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	graphs, err := engine.ListGraphs()
+	if err != nil {
+		return err
+	}
+	audit, err := engine.AuditKeys("")
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "GRAPH\tNODES\tEDGES\tKEYS")
+	for _, graph := range graphs {
+		nodes, err := engine.CountNodes(graph.ID)
+		if err != nil {
+			return err
+		}
+		edges, err := engine.CountEdges(graph.ID)
+		if err != nil {
+			return err
+		}
+		var keys int64
+		for _, count := range audit.Graphs[graph.ID] {
+			keys += count
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", graph.ID, nodes, edges, keys)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(c.stdout)
+	tw = tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FAMILY\tKEYS")
+	for _, name := range sortedNames(audit.Families) {
+		fmt.Fprintf(tw, "%s\t%d\n", name, audit.Families[name])
+	}
+	for _, name := range sortedNames(audit.UnknownPrefixes) {
+		fmt.Fprintf(tw, "%s (unknown)\t%d\n", name, audit.UnknownPrefixes[name])
+	}
+	tw.Flush()
+
+	fmt.Fprintf(c.stdout, "\n%d graphs, %d keys (%d unknown, %d orphaned)\n", len(graphs), audit.Keys, audit.Unknown, audit.Orphaned)
+	return nil
+}
+
+// runExport handles export [-meta] <datadir> <graph> <out.json>. An out
+// of "-" writes to stdout; a file is removed again if the export fails.
+func runExport(c *context, args []string) error {
+	withMeta := c.flags.Bool("meta", false, "Include the graph's metadata")
+	args, err := c.parse(args, 3)
+	if err != nil {
+		return err
+	}
+	engine, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	graphID, out := models.GraphID(args[1]), args[2]
+	if _, err := engine.GetGraph(graphID); err != nil {
+		return err
+	}
+	if out == "-" {
+		return engine.ExportGraph(graphID, c.stdout, *withMeta)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	err = engine.ExportGraph(graphID, f, *withMeta)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		return err
+	}
+	fmt.Fprintf(c.stderr, "Exported graph %s to %s\n", graphID, out)
+	return nil
+}
+
+// runFsck handles fsck [--repair] <datadir>. It prints the problems the key
+// audit finds: interrupted graph deletions, keys of graphs without a graph
+// record and indexes whose entry counts differ from their records. With
+// --repair, the database is opened for writing, the problems are repaired
+// and the audit is run again. It fails with errProblems if any remain.
+func runFsck(c *context, args []string) error {
+	repair := c.flags.Bool("repair", false, "Repair the problems found")
+	args, err := c.parse(args, 1)
+	if err != nil {
+		return err
+	}
+	engine, err := c.open(args[0], !*repair)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	audit, err := engine.AuditKeys("")
+	if err != nil {
+		return err
+	}
+	problems := printAudit(c, audit)
+
+	if *repair && problems > 0 {
+		report, err := engine.Repair()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "\nrepair: resumed %d graph deletions\n", report.DeletionsResumed)
+		fmt.Fprintf(c.stdout, "repair: deleted %d orphaned keys\n", report.OrphansDeleted)
+		for _, rebuilt := range report.IndexesRebuilt {
+			fmt.Fprintf(c.stdout, "repair: rebuilt %s index of graph %s\n", rebuilt.Index, rebuilt.Graph)
+		}
+
+		if audit, err = engine.AuditKeys(""); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout)
+		problems = printAudit(c, audit)
+	}
+
+	if problems > 0 {
+		fmt.Fprintf(c.stdout, "\n%d problems found\n", problems)
+		return errProblems
+	}
+	fmt.Fprintln(c.stdout, "\nno problems found")
+	return nil
+}
+
+// printAudit prints the keyspace totals and problems of an audit, and
+// returns the number of problems. Unknown keys, which a newer build may
+// have written, are counted but not problems.
+func printAudit(c *context, audit *storage.KeyAudit) int {
+	fmt.Fprintf(c.stdout, "keys: %d\n", audit.Keys)
+	fmt.Fprintf(c.stdout, "unknown keys: %d\n", audit.Unknown)
+	fmt.Fprintf(c.stdout, "orphaned keys: %d\n", audit.Orphaned)
+
+	problems := 0
+	if deletions := audit.Families["gd"]; deletions > 0 {
+		fmt.Fprintf(c.stdout, "problem: %d interrupted graph deletions\n", deletions)
+		problems++
+	}
+	for _, family := range sortedNames(audit.Orphans) {
+		fmt.Fprintf(c.stdout, "problem: %d %s keys of graphs without a graph record\n", audit.Orphans[family], family)
+		problems++
+	}
+	for _, mismatch := range audit.Mismatches {
+		fmt.Fprintf(c.stdout, "problem: graph %s has %d %s records but %d %s index entries\n",
+			mismatch.Graph, mismatch.Entities, mismatch.Family, mismatch.Entries, mismatch.Index)
+		problems++
+	}
+	return problems
+}
+
+// runBackup handles backup <datadir> <out file>. If out is a directory, the
+// backup is written to backup.db in it, as SYSTEM.BACKUP does.
+func runBackup(c *context, args []string) error {
+	args, err := c.parse(args, 2)
+	if err != nil {
+		return err
+	}
+	engine, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	out := args[1]
+	if info, err := os.Stat(out); err == nil && info.IsDir() {
+		err = engine.Backup(out)
+	} else {
+		err = engine.BackupFile(out)
+	}
+	if err != nil {
+		return err
+	}
+
+	manifest, err := storage.ReadBackupManifest(out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Backed up %d keys of %d graphs to %s\n", manifest.TotalKeys, len(manifest.Graphs), out)
+	return nil
+}
+
+// runRestore handles restore <datadir> <in file>. The backup is verified
+// before the data directory is opened, so a damaged backup leaves it as it
+// was; keys already in the database that the backup does not hold are kept.
+func runRestore(c *context, args []string) error {
+	args, err := c.parse(args, 2)
+	if err != nil {
+		return err
+	}
+	manifest, err := storage.VerifyBackup(args[1])
+	if err != nil {
+		return err
+	}
+	engine, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	if err := engine.Restore(args[1]); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Restored %d keys of %d graphs from %s\n", manifest.TotalKeys, len(manifest.Graphs), args[1])
+	return nil
+}
+
+// runGenerate handles generate [flags] <datadir> <graph>. The graph is
+// created, so it must not exist, and filled with a graphgen graph of the
+// shape and size the flags give. The same flags always generate the same
+// graph.
+func runGenerate(c *context, args []string) error {
+	shape := c.flags.String("shape", "dag", "Shape of the graph: chain, star, dag or scalefree")
+	nodes := c.flags.Int("nodes", 1000, "Number of nodes, the hub included for star")
+	degree := c.flags.Int("degree", 3, "Edges per node for dag, and per new node for scalefree")
+	seed := c.flags.Int64("seed", 1, "Seed of the random choices")
+	nodeTypes := c.flags.String("node-types", "", "Comma-separated node types to assign at random")
+	edgeTypes := c.flags.String("edge-types", "", "Comma-separated edge types to assign at random")
+	attributes := c.flags.Int("attributes", 0, "Attributes per node and edge")
+	args, err := c.parse(args, 2)
+	if err != nil {
+		return err
+	}
+
+	var generator graphgen.Generator
+	switch *shape {
+	case "chain":
+		generator = graphgen.Chain(*nodes)
+	case "star":
+		generator = graphgen.Star("hub", *nodes-1)
+	case "dag":
+		generator = graphgen.RandomDAG(*nodes, *degree, *seed)
+	case "scalefree":
+		generator = graphgen.ScaleFree(*nodes, *degree, *seed)
+	default:
+		fmt.Fprintf(c.stderr, "Invalid -shape: %s (must be chain, star, dag or scalefree)\n", *shape)
+		return errUsage
+	}
+	var types []models.NodeType
+	for _, t := range splitList(*nodeTypes) {
+		types = append(types, models.NodeType(t))
+	}
+	var kinds []models.EdgeType
+	for _, t := range splitList(*edgeTypes) {
+		kinds = append(kinds, models.EdgeType(t))
+	}
+	generator = graphgen.WithTypes(generator, types, kinds, *seed)
+	if *attributes > 0 {
+		generator = graphgen.WithAttributes(generator, *attributes, *seed)
+	}
+
+	engine, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	graphID := models.GraphID(args[1])
+	if _, err := engine.GetGraph(graphID); err == nil {
+		return fmt.Errorf("graph %w: %s", storage.ErrAlreadyExists, graphID)
+	}
+	now := time.Now()
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID), CreatedAt: now, UpdatedAt: now}); err != nil {
+		return err
+	}
+	graph := generator()
+	if err := graphgen.Write(engine, graphID, graph); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Generated graph %s with %d nodes and %d edges in %s\n", graphID, len(graph.Nodes), len(graph.Edges), time.Since(now).Round(time.Millisecond))
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// runVersion handles version: the version and commit the binary was built
+// from, as set with -ldflags
+func runVersion(c *context, args []string) error {
+	if _, err := c.parse(args, 0); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "PathwayDB %s (commit %s)\n", version.Version, version.Commit)
+	return nil
+}
+
+// sortedNames returns the keys of counts in order
+func sortedNames(counts map[string]int64) []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// adjacency indexes the edges of a graph by their endpoints, loaded with
+// one ListEdges call. Searches that expand many nodes read it instead of
+// listing and decoding each node's edges from storage. It is built for one
+// call and then dropped, so it never outlives a write to the graph.
+type adjacency struct {
+	out map[models.NodeID][]*models.Edge
+	in  map[models.NodeID][]*models.Edge
+
+	// counter, set when the analyzer is traced, counts the nodes expanded
+	// and their edges as the storage reads they replace would have been
+	counter *countingStorage
+}
+
+// buildAdjacency loads the live edges of a graph, only those of
+// edgeTypes if any are given. Each node's edges keep the order ListEdges
+// returns them in, by edge ID, as GetOutgoingEdges and GetIncomingEdges
+// return them.
+func (ga *GraphAnalyzer) buildAdjacency(graphID models.GraphID, edgeTypes []models.EdgeType) (*adjacency, error) {
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+
+	adj := &adjacency{
+		out: make(map[models.NodeID][]*models.Edge),
+		in:  make(map[models.NodeID][]*models.Edge),
+	}
+	if counter, ok := ga.storage.(*countingStorage); ok {
+		adj.counter = counter
+	}
+	for _, edge := range liveEdges(edges) {
+		if !matchesEdgeTypes(edge, edgeTypes) {
+			continue
+		}
+		adj.out[edge.FromNodeID] = append(adj.out[edge.FromNodeID], edge)
+		adj.in[edge.ToNodeID] = append(adj.in[edge.ToNodeID], edge)
+	}
+	return adj, nil
+}
+
+// edges returns the edges of nodeID in a direction: its outgoing edges,
+// its incoming edges, or for DirectionBoth the outgoing followed by the
+// incoming ones. The result must not be modified.
+func (a *adjacency) edges(nodeID models.NodeID, direction types.TraversalDirection) []*models.Edge {
+	var edges []*models.Edge
+	switch direction {
+	case types.DirectionForward:
+		edges = a.out[nodeID]
+	case types.DirectionBackward:
+		edges = a.in[nodeID]
+	default:
+		edges = make([]*models.Edge, 0, len(a.out[nodeID])+len(a.in[nodeID]))
+		edges = append(append(edges, a.out[nodeID]...), a.in[nodeID]...)
+	}
+	if a.counter != nil {
+		a.counter.count(nodeID, edges)
+	}
+	return edges
+}
@@ -0,0 +1,372 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// ErrNotConverged is returned, wrapped and alongside the best estimate, when
+// an iterative centrality does not converge within the iteration limit
+var ErrNotConverged = errors.New("did not converge")
+
+// adjacencySnapshot is a read-only copy of a graph's edges over dense node
+// indexes, oriented for a traversal direction
+type adjacencySnapshot struct {
+	nodes []models.NodeID
+	index map[models.NodeID]int
+	out   [][]int
+}
+
+// snapshotAdjacency loads all nodes and edges of a graph, or only the edges
+// of edgeTypes if any are given. With DirectionForward each edge points
+// from FromNodeID to ToNodeID, DirectionBackward reverses it, and
+// DirectionBoth adds both orientations. Parallel edges are kept and act as
+// weights.
+func (ga *GraphAnalyzer) snapshotAdjacency(graphID models.GraphID, direction types.TraversalDirection, edgeTypes []models.EdgeType) (*adjacencySnapshot, error) {
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+
+	snapshot := &adjacencySnapshot{
+		nodes: make([]models.NodeID, len(nodes)),
+		index: make(map[models.NodeID]int, len(nodes)),
+		out:   make([][]int, len(nodes)),
+	}
+	index := snapshot.index
+	for i, node := range nodes {
+		snapshot.nodes[i] = node.ID
+		index[node.ID] = i
+	}
+
+	for _, edge := range edges {
+		if !matchesEdgeTypes(edge, edgeTypes) {
+			continue
+		}
+		from, okFrom := index[edge.FromNodeID]
+		to, okTo := index[edge.ToNodeID]
+		if !okFrom || !okTo {
+			continue
+		}
+		switch direction {
+		case types.DirectionForward:
+			snapshot.out[from] = append(snapshot.out[from], to)
+		case types.DirectionBackward:
+			snapshot.out[to] = append(snapshot.out[to], from)
+		default:
+			snapshot.out[from] = append(snapshot.out[from], to)
+			snapshot.out[to] = append(snapshot.out[to], from)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// scores maps a dense score vector back to node IDs
+func (s *adjacencySnapshot) scores(values []float64) map[models.NodeID]float64 {
+	scores := make(map[models.NodeID]float64, len(values))
+	for i, value := range values {
+		scores[s.nodes[i]] = value
+	}
+	return scores
+}
+
+// CalculatePageRank computes PageRank with the power-iteration method. Rank
+// flows along edges in the given direction, so with DirectionForward nodes
+// that many others depend on rank highest. Rank held by dangling nodes (no
+// outgoing edges) is redistributed evenly across all nodes each iteration.
+// Iteration stops when the L1 change between iterations drops below tol. If
+// that does not happen within maxIter iterations, the last estimate is
+// returned together with an error wrapping ErrNotConverged.
+func (ga *GraphAnalyzer) CalculatePageRank(graphID models.GraphID, damping float64, maxIter int, tol float64, direction types.TraversalDirection) (map[models.NodeID]float64, error) {
+	if damping <= 0 || damping >= 1 {
+		return nil, fmt.Errorf("damping must be between 0 and 1, got %v", damping)
+	}
+	if maxIter <= 0 || tol <= 0 {
+		return nil, fmt.Errorf("iterations and tolerance must be positive")
+	}
+
+	snapshot, err := ga.snapshotAdjacency(graphID, direction, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(snapshot.nodes)
+	if n == 0 {
+		return map[models.NodeID]float64{}, nil
+	}
+
+	rank := make([]float64, n)
+	next := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+
+	for iter := 0; iter < maxIter; iter++ {
+		dangling := 0.0
+		for u, targets := range snapshot.out {
+			if len(targets) == 0 {
+				dangling += rank[u]
+			}
+		}
+
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for u, targets := range snapshot.out {
+			if len(targets) == 0 {
+				continue
+			}
+			share := damping * rank[u] / float64(len(targets))
+			for _, v := range targets {
+				next[v] += share
+			}
+		}
+
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+
+		if delta < tol {
+			return snapshot.scores(rank), nil
+		}
+	}
+
+	return snapshot.scores(rank), fmt.Errorf("pagerank %w within %d iterations", ErrNotConverged, maxIter)
+}
+
+// CalculateEigenvectorCentrality computes eigenvector centrality with power
+// iteration: a node's score is proportional to the sum of the scores of the
+// nodes with edges pointing to it in the given direction. Each step uses
+// A+I instead of A so the iteration also settles on bipartite graphs, and
+// scores are normalized to unit length. Convergence is handled as in
+// CalculatePageRank.
+func (ga *GraphAnalyzer) CalculateEigenvectorCentrality(graphID models.GraphID, maxIter int, tol float64, direction types.TraversalDirection) (map[models.NodeID]float64, error) {
+	if maxIter <= 0 || tol <= 0 {
+		return nil, fmt.Errorf("iterations and tolerance must be positive")
+	}
+
+	snapshot, err := ga.snapshotAdjacency(graphID, direction, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(snapshot.nodes)
+	if n == 0 {
+		return map[models.NodeID]float64{}, nil
+	}
+
+	score := make([]float64, n)
+	next := make([]float64, n)
+	for i := range score {
+		score[i] = 1 / math.Sqrt(float64(n))
+	}
+
+	for iter := 0; iter < maxIter; iter++ {
+		copy(next, score)
+		for u, targets := range snapshot.out {
+			for _, v := range targets {
+				next[v] += score[u]
+			}
+		}
+
+		norm := 0.0
+		for _, value := range next {
+			norm += value * value
+		}
+		norm = math.Sqrt(norm)
+
+		delta := 0.0
+		for i := range next {
+			next[i] /= norm
+			delta += math.Abs(next[i] - score[i])
+		}
+		score, next = next, score
+
+		if delta < tol {
+			return snapshot.scores(score), nil
+		}
+	}
+
+	return snapshot.scores(score), fmt.Errorf("eigenvector centrality %w within %d iterations", ErrNotConverged, maxIter)
+}
+
+// distinctTargets returns the targets of each node once, without
+// self-loops. Shortest path counts use it, so that parallel edges and the
+// two orientations DirectionBoth adds do not count as extra paths.
+func (s *adjacencySnapshot) distinctTargets() [][]int {
+	targets := make([][]int, len(s.out))
+	seen := make(map[int]bool)
+	for u, out := range s.out {
+		clear(seen)
+		for _, v := range out {
+			if v != u && !seen[v] {
+				seen[v] = true
+				targets[u] = append(targets[u], v)
+			}
+		}
+	}
+	return targets
+}
+
+// shortestPathSnapshot checks that nodeID, if given, exists and loads the
+// adjacency snapshot the shortest path centralities search
+func (ga *GraphAnalyzer) shortestPathSnapshot(graphID models.GraphID, nodeID *models.NodeID, direction types.TraversalDirection) (*adjacencySnapshot, error) {
+	if nodeID != nil {
+		if _, err := ga.storage.GetNode(graphID, *nodeID); err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", *nodeID, err)
+		}
+	}
+	snapshot, err := ga.snapshotAdjacency(graphID, direction, nil)
+	if err != nil {
+		return nil, err
+	}
+	if nodeID != nil {
+		// Deleted between the check and the snapshot
+		if _, ok := snapshot.index[*nodeID]; !ok {
+			return nil, fmt.Errorf("failed to get node %s: %w", *nodeID, storage.ErrNodeNotFound)
+		}
+	}
+	return snapshot, nil
+}
+
+// CalculateBetweennessCentrality computes betweenness centrality with
+// Brandes' algorithm: a node's score is the sum, over all pairs of other
+// nodes s and t, of the fraction of shortest paths from s to t in the given
+// direction that pass through it. Edges are unweighted, and parallel edges
+// and self-loops add no paths. With DirectionBoth each unordered pair is
+// counted once. Scores are not normalized. If nodeID is given, only its
+// score is returned, though every shortest path is still searched.
+func (ga *GraphAnalyzer) CalculateBetweennessCentrality(graphID models.GraphID, nodeID *models.NodeID, direction types.TraversalDirection) (map[models.NodeID]float64, error) {
+	snapshot, err := ga.shortestPathSnapshot(graphID, nodeID, direction)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(snapshot.nodes)
+	targets := snapshot.distinctTargets()
+	betweenness := make([]float64, n)
+	sigma := make([]float64, n)
+	dist := make([]int, n)
+	delta := make([]float64, n)
+	predecessors := make([][]int, n)
+	order := make([]int, 0, n)
+	for source := 0; source < n; source++ {
+		if err := ga.context().Err(); err != nil {
+			return nil, err
+		}
+		for i := range dist {
+			sigma[i], dist[i], delta[i] = 0, -1, 0
+			predecessors[i] = predecessors[i][:0]
+		}
+		sigma[source], dist[source] = 1, 0
+
+		// Count the shortest paths from source breadth first, in order of
+		// distance
+		order = append(order[:0], source)
+		for head := 0; head < len(order); head++ {
+			u := order[head]
+			for _, v := range targets[u] {
+				if dist[v] < 0 {
+					dist[v] = dist[u] + 1
+					order = append(order, v)
+				}
+				if dist[v] == dist[u]+1 {
+					sigma[v] += sigma[u]
+					predecessors[v] = append(predecessors[v], u)
+				}
+			}
+		}
+
+		// Accumulate dependencies from the farthest nodes back
+		for i := len(order) - 1; i > 0; i-- {
+			w := order[i]
+			for _, u := range predecessors[w] {
+				delta[u] += sigma[u] / sigma[w] * (1 + delta[w])
+			}
+			betweenness[w] += delta[w]
+		}
+	}
+	if direction == types.DirectionBoth {
+		for i := range betweenness {
+			betweenness[i] /= 2
+		}
+	}
+
+	return snapshot.selectScores(betweenness, nodeID), nil
+}
+
+// CalculateClosenessCentrality computes harmonic closeness centrality with
+// a breadth-first search from each node: the sum of 1/d over the distances
+// d in the given direction to every other node, divided by n-1 so scores
+// lie between 0 and 1. Unreachable nodes add nothing rather than making a
+// distance infinite, so graphs of several components get finite scores and
+// an isolated node scores 0. With DirectionForward distances follow edges
+// out of the node. If nodeID is given, only its score is computed.
+func (ga *GraphAnalyzer) CalculateClosenessCentrality(graphID models.GraphID, nodeID *models.NodeID, direction types.TraversalDirection) (map[models.NodeID]float64, error) {
+	snapshot, err := ga.shortestPathSnapshot(graphID, nodeID, direction)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(snapshot.nodes)
+	sources := make([]int, 0, n)
+	if nodeID != nil {
+		sources = append(sources, snapshot.index[*nodeID])
+	} else {
+		for i := 0; i < n; i++ {
+			sources = append(sources, i)
+		}
+	}
+
+	closeness := make([]float64, n)
+	dist := make([]int, n)
+	queue := make([]int, 0, n)
+	for _, source := range sources {
+		if err := ga.context().Err(); err != nil {
+			return nil, err
+		}
+		for i := range dist {
+			dist[i] = -1
+		}
+		dist[source] = 0
+		sum := 0.0
+		queue = append(queue[:0], source)
+		for head := 0; head < len(queue); head++ {
+			u := queue[head]
+			for _, v := range snapshot.out[u] {
+				if dist[v] < 0 {
+					dist[v] = dist[u] + 1
+					sum += 1 / float64(dist[v])
+					queue = append(queue, v)
+				}
+			}
+		}
+		if n > 1 {
+			closeness[source] = sum / float64(n-1)
+		}
+	}
+
+	return snapshot.selectScores(closeness, nodeID), nil
+}
+
+// selectScores maps a dense score vector back to node IDs, keeping only
+// nodeID if it is given
+func (s *adjacencySnapshot) selectScores(values []float64, nodeID *models.NodeID) map[models.NodeID]float64 {
+	if nodeID == nil {
+		return s.scores(values)
+	}
+	return map[models.NodeID]float64{*nodeID: values[s.index[*nodeID]]}
+}
@@ -0,0 +1,276 @@
+package analysis
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TransitiveClosureSize returns the number of nodes reachable from nodeID in
+// the given direction (dependencies for DirectionForward, dependents for
+// DirectionBackward), excluding the node itself.
+//
+// The count is well-defined on cyclic graphs: the graph is condensed into
+// strongly connected components, and every member of the node's own SCC
+// counts as a dependency, so all members of a cycle share the same count.
+// EdgeTypes restricts which edges are followed and NodeTypes restricts which
+// reachable nodes are counted (traversal still passes through other types).
+// MaxDepth and StopCondition are ignored.
+func (ga *GraphAnalyzer) TransitiveClosureSize(graphID models.GraphID, nodeID models.NodeID, direction types.TraversalDirection, options *types.TraversalOptions) (int, error) {
+	if _, err := ga.storage.GetNode(graphID, nodeID); err != nil {
+		return 0, fmt.Errorf("failed to get node %s: %w", nodeID, err)
+	}
+
+	sizes, err := ga.transitiveClosureSizes(graphID, []models.NodeID{nodeID}, direction, options)
+	if err != nil {
+		return 0, err
+	}
+	return sizes[nodeID], nil
+}
+
+// TransitiveClosureSizes computes TransitiveClosureSize for every node in the
+// graph in a single pass over the condensation.
+func (ga *GraphAnalyzer) TransitiveClosureSizes(graphID models.GraphID, direction types.TraversalDirection, options *types.TraversalOptions) (map[models.NodeID]int, error) {
+	return ga.transitiveClosureSizes(graphID, nil, direction, options)
+}
+
+// closureMemory bounds the bytes of reachable-node bitsets
+// transitiveClosureSizes holds at once
+const closureMemory = 64 << 20
+
+// transitiveClosureSizes counts reachable nodes for the nodes reachable from
+// starts, or for all nodes when starts is nil. SCCs are found with an
+// iterative Tarjan pass; Tarjan emits components in reverse topological order,
+// so each component's reachable set is the union of its own members and the
+// already computed sets of its successors.
+//
+// The sets take components x nodes bits in all, so they are built in chunks
+// of node indexes: memory stays within closureMemory beyond the graph
+// itself, while time grows as nodes x (nodes + edges) / 64.
+func (ga *GraphAnalyzer) transitiveClosureSizes(graphID models.GraphID, starts []models.NodeID, direction types.TraversalDirection, options *types.TraversalOptions) (map[models.NodeID]int, error) {
+	if options == nil {
+		options = &types.TraversalOptions{}
+	}
+
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+
+	index := make(map[models.NodeID]int, len(nodes))
+	counted := make([]bool, len(nodes))
+	for i, node := range nodes {
+		index[node.ID] = i
+		counted[i] = matchesNodeTypes(node, options.NodeTypes)
+	}
+
+	adjacency := make([][]int, len(nodes))
+	for _, edge := range edges {
+		if !matchesEdgeTypes(edge, options.EdgeTypes) {
+			continue
+		}
+		from, okFrom := index[edge.FromNodeID]
+		to, okTo := index[edge.ToNodeID]
+		if !okFrom || !okTo {
+			continue
+		}
+		switch direction {
+		case types.DirectionForward:
+			adjacency[from] = append(adjacency[from], to)
+		case types.DirectionBackward:
+			adjacency[to] = append(adjacency[to], from)
+		case types.DirectionBoth:
+			adjacency[from] = append(adjacency[from], to)
+			adjacency[to] = append(adjacency[to], from)
+		}
+	}
+
+	roots := make([]int, 0, len(nodes))
+	if starts == nil {
+		for i := range nodes {
+			roots = append(roots, i)
+		}
+	} else {
+		for _, id := range starts {
+			if i, ok := index[id]; ok {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	component, components := stronglyConnectedComponents(adjacency, roots)
+
+	// Only counted node types contribute to the size.
+	words := (len(nodes) + 63) / 64
+	mask := make([]uint64, words)
+	for n, ok := range counted {
+		if ok {
+			mask[n/64] |= 1 << (n % 64)
+		}
+	}
+
+	// reach[c] holds the set of nodes reachable from component c, including
+	// its own members, as a bitset over node indexes. Sets over all nodes
+	// would take components x nodes bits, so they are built over one chunk
+	// of node indexes at a time, sized to stay within closureMemory (or one
+	// word per component, past that many components), and each chunk's
+	// counts are added to totals.
+	chunk := closureMemory / 8 / max(len(components), 1)
+	chunk = min(max(chunk, 1), max(words, 1))
+	backing := make([]uint64, len(components)*chunk)
+	reach := make([][]uint64, len(components))
+	totals := make([]int, len(components))
+	for start := 0; start < words; start += chunk {
+		if err := ga.context().Err(); err != nil {
+			return nil, err
+		}
+		width := min(chunk, words-start)
+		clear(backing)
+		for c, members := range components {
+			set := backing[c*chunk : c*chunk+width]
+			for _, n := range members {
+				if w := n/64 - start; w >= 0 && w < width {
+					set[w] |= 1 << (n % 64)
+				}
+				for _, next := range adjacency[n] {
+					if nc := component[next]; nc != c {
+						for w, bitsWord := range reach[nc] {
+							set[w] |= bitsWord
+						}
+					}
+				}
+			}
+			reach[c] = set
+			for w, bitsWord := range set {
+				totals[c] += bits.OnesCount64(bitsWord & mask[start+w])
+			}
+		}
+	}
+
+	sizes := make(map[models.NodeID]int)
+	for c, members := range components {
+		for _, n := range members {
+			size := totals[c]
+			if counted[n] {
+				size-- // A node is not its own dependency
+			}
+			sizes[nodes[n].ID] = size
+		}
+	}
+
+	return sizes, nil
+}
+
+// stronglyConnectedComponents runs Tarjan's algorithm from each root and
+// returns the component index of every visited node (-1 if unvisited) and the
+// members of each component, in reverse topological order.
+func stronglyConnectedComponents(adjacency [][]int, roots []int) ([]int, [][]int) {
+	n := len(adjacency)
+	order := make([]int, n)
+	lowlink := make([]int, n)
+	component := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range order {
+		order[i] = -1
+		component[i] = -1
+	}
+
+	var components [][]int
+	var stack []int
+	counter := 0
+
+	type frame struct {
+		node int
+		next int // index of the next neighbour to visit
+	}
+
+	for _, root := range roots {
+		if order[root] != -1 {
+			continue
+		}
+
+		callStack := []frame{{node: root}}
+		order[root], lowlink[root] = counter, counter
+		counter++
+		stack = append(stack, root)
+		onStack[root] = true
+
+		for len(callStack) > 0 {
+			top := &callStack[len(callStack)-1]
+			v := top.node
+
+			if top.next < len(adjacency[v]) {
+				w := adjacency[v][top.next]
+				top.next++
+				if order[w] == -1 {
+					order[w], lowlink[w] = counter, counter
+					counter++
+					stack = append(stack, w)
+					onStack[w] = true
+					callStack = append(callStack, frame{node: w})
+				} else if onStack[w] && order[w] < lowlink[v] {
+					lowlink[v] = order[w]
+				}
+				continue
+			}
+
+			// All neighbours visited: close the component if v is its root.
+			if lowlink[v] == order[v] {
+				var members []int
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					component[w] = len(components)
+					members = append(members, w)
+					if w == v {
+						break
+					}
+				}
+				components = append(components, members)
+			}
+
+			callStack = callStack[:len(callStack)-1]
+			if len(callStack) > 0 {
+				parent := callStack[len(callStack)-1].node
+				if lowlink[v] < lowlink[parent] {
+					lowlink[parent] = lowlink[v]
+				}
+			}
+		}
+	}
+
+	return component, components
+}
+
+// matchesNodeTypes reports whether node passes the node type filter
+func matchesNodeTypes(node *models.Node, nodeTypes []models.NodeType) bool {
+	if len(nodeTypes) == 0 {
+		return true
+	}
+	for _, nodeType := range nodeTypes {
+		if node.Type == nodeType {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesEdgeTypes reports whether edge passes the edge type filter
+func matchesEdgeTypes(edge *models.Edge, edgeTypes []models.EdgeType) bool {
+	if len(edgeTypes) == 0 {
+		return true
+	}
+	for _, edgeType := range edgeTypes {
+		if edge.Type == edgeType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// ComputeComponents assigns every node to its weakly connected component,
+// treating edges as undirected. EdgeTypes restricts which edges connect
+// nodes; NodeTypes restricts which nodes take part, and edges to other nodes
+// are ignored. MaxDepth, Direction and StopCondition are ignored.
+//
+// Labels are stable: components are numbered from 0 in the order of their
+// smallest node ID, so the same graph always gets the same labels.
+func (ga *GraphAnalyzer) ComputeComponents(graphID models.GraphID, options *types.TraversalOptions) (map[models.NodeID]int, error) {
+	if options == nil {
+		options = &types.TraversalOptions{}
+	}
+
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+
+	ids, labels := labelComponents(nodes, edges, options)
+	components := make(map[models.NodeID]int, len(ids))
+	for i, id := range ids {
+		components[id] = labels[i]
+	}
+	return components, nil
+}
+
+// labelComponents numbers the weakly connected components of the given
+// nodes and edges as ComputeComponents does, returning the matching node
+// IDs in order and the component of each
+func labelComponents(nodes []*models.Node, edges []*models.Edge, options *types.TraversalOptions) ([]models.NodeID, []int) {
+	ids := make([]models.NodeID, 0, len(nodes))
+	for _, node := range nodes {
+		if matchesNodeTypes(node, options.NodeTypes) {
+			ids = append(ids, node.ID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	index := make(map[models.NodeID]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+	adjacency := make([][]int, len(ids))
+	for _, edge := range edges {
+		if !matchesEdgeTypes(edge, options.EdgeTypes) {
+			continue
+		}
+		from, okFrom := index[edge.FromNodeID]
+		to, okTo := index[edge.ToNodeID]
+		if !okFrom || !okTo {
+			continue
+		}
+		adjacency[from] = append(adjacency[from], to)
+		adjacency[to] = append(adjacency[to], from)
+	}
+
+	// Visiting nodes in ID order makes each component's first node its
+	// smallest, which fixes the label order. The search uses an explicit
+	// stack so large components cannot overflow the goroutine stack.
+	labels := make([]int, len(ids))
+	for i := range labels {
+		labels[i] = -1
+	}
+	next := 0
+	var stack []int
+	for start := range ids {
+		if labels[start] >= 0 {
+			continue
+		}
+		labels[start] = next
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, neighbor := range adjacency[current] {
+				if labels[neighbor] < 0 {
+					labels[neighbor] = next
+					stack = append(stack, neighbor)
+				}
+			}
+		}
+		next++
+	}
+
+	return ids, labels
+}
@@ -0,0 +1,49 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// liveEdges drops the weak edges that lead to a deleted node. Analyses only
+// follow edges between existing nodes, so a node whose remaining edges all
+// dangle counts as having none.
+func liveEdges(edges []*models.Edge) []*models.Edge {
+	live := edges[:0:0]
+	for _, edge := range edges {
+		if !edge.Dangling() {
+			live = append(live, edge)
+		}
+	}
+	return live
+}
+
+// danglingEdges returns the weak edges of nodeID, in the direction and of
+// the edge types of options, whose other end has been deleted
+func (ga *GraphAnalyzer) danglingEdges(graphID models.GraphID, nodeID models.NodeID, options *types.TraversalOptions) ([]*models.Edge, error) {
+	var edges []*models.Edge
+	if options.Direction != types.DirectionBackward {
+		outgoing, err := ga.storage.GetOutgoingEdges(graphID, nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get outgoing edges: %w", err)
+		}
+		edges = append(edges, outgoing...)
+	}
+	if options.Direction != types.DirectionForward {
+		incoming, err := ga.storage.GetIncomingEdges(graphID, nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get incoming edges: %w", err)
+		}
+		edges = append(edges, incoming...)
+	}
+
+	var dangling []*models.Edge
+	for _, edge := range edges {
+		if edge.Dangling() && matchesEdgeTypes(edge, options.EdgeTypes) {
+			dangling = append(dangling, edge)
+		}
+	}
+	return dangling, nil
+}
@@ -0,0 +1,62 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GetDependenciesWithDepth returns the transitive dependencies of nodeID,
+// each with the depth of the shortest chain of edges to it. Entries are
+// sorted by depth and then by node ID, and each node is listed once.
+//
+// options apply as in WalkBFS, except Direction, which is always forward:
+// a node failing NodeTypes is left out but its own dependencies are still
+// found, at their depth through it. Nil options find all dependencies.
+func (ga *GraphAnalyzer) GetDependenciesWithDepth(graphID models.GraphID, nodeID models.NodeID, options *types.TraversalOptions) (entries []types.DependencyEntry, err error) {
+	traced, end := ga.traced("analysis.dependencies", graphID)
+	defer func() {
+		end(err, attribute.String("start", string(nodeID)), attribute.Int("dependencies", len(entries)))
+	}()
+	return traced.dependenciesWithDepth(graphID, nodeID, types.DirectionForward, options)
+}
+
+// GetDependentsWithDepth returns the transitive dependents of nodeID like
+// GetDependenciesWithDepth, following edges backward
+func (ga *GraphAnalyzer) GetDependentsWithDepth(graphID models.GraphID, nodeID models.NodeID, options *types.TraversalOptions) (entries []types.DependencyEntry, err error) {
+	traced, end := ga.traced("analysis.dependents", graphID)
+	defer func() {
+		end(err, attribute.String("start", string(nodeID)), attribute.Int("dependents", len(entries)))
+	}()
+	return traced.dependenciesWithDepth(graphID, nodeID, types.DirectionBackward, options)
+}
+
+func (ga *GraphAnalyzer) dependenciesWithDepth(graphID models.GraphID, nodeID models.NodeID, direction types.TraversalDirection, options *types.TraversalOptions) ([]types.DependencyEntry, error) {
+	// Copy the options so the caller's are not changed
+	walkOptions := types.TraversalOptions{MaxDepth: -1}
+	if options != nil {
+		walkOptions = *options
+	}
+	walkOptions.Direction = direction
+
+	var entries []types.DependencyEntry
+	_, err := ga.walk(ga.context(), graphID, nodeID, &walkOptions, true, func(node *models.Node, depth int, _ *models.Edge, _ *hop) error {
+		if node.ID != nodeID {
+			entries = append(entries, types.DependencyEntry{Node: node, Depth: depth, Direct: depth == 1})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Depth != entries[j].Depth {
+			return entries[i].Depth < entries[j].Depth
+		}
+		return entries[i].Node.ID < entries[j].Node.ID
+	})
+	return entries, nil
+}
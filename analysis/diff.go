@@ -0,0 +1,76 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// DiffSnapshot compares a stored snapshot (before) with the live graph (after)
+func (ga *GraphAnalyzer) DiffSnapshot(graphID models.GraphID, snapshot *models.SnapshotData) (*types.GraphDiff, error) {
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+
+	return DiffGraphData(snapshot, &models.SnapshotData{Nodes: nodes, Edges: edges}), nil
+}
+
+// DiffGraphData compares two versions of a graph's contents. Timestamps are
+// ignored; only types, endpoints and attributes count as changes. Each list
+// in the result is sorted by ID.
+func DiffGraphData(before, after *models.SnapshotData) *types.GraphDiff {
+	diff := &types.GraphDiff{}
+
+	beforeNodes := make(map[models.NodeID]*models.Node, len(before.Nodes))
+	for _, node := range before.Nodes {
+		beforeNodes[node.ID] = node
+	}
+	for _, node := range after.Nodes {
+		old, exists := beforeNodes[node.ID]
+		switch {
+		case !exists:
+			diff.AddedNodes = append(diff.AddedNodes, node.ID)
+		case old.Type != node.Type || !models.AttributesEqual(old.Attributes, node.Attributes):
+			diff.ChangedNodes = append(diff.ChangedNodes, node.ID)
+		}
+		delete(beforeNodes, node.ID)
+	}
+	for id := range beforeNodes {
+		diff.RemovedNodes = append(diff.RemovedNodes, id)
+	}
+
+	beforeEdges := make(map[models.EdgeID]*models.Edge, len(before.Edges))
+	for _, edge := range before.Edges {
+		beforeEdges[edge.ID] = edge
+	}
+	for _, edge := range after.Edges {
+		old, exists := beforeEdges[edge.ID]
+		switch {
+		case !exists:
+			diff.AddedEdges = append(diff.AddedEdges, edge.ID)
+		case old.Type != edge.Type || old.FromNodeID != edge.FromNodeID || old.ToNodeID != edge.ToNodeID ||
+			!models.AttributesEqual(old.Attributes, edge.Attributes):
+			diff.ChangedEdges = append(diff.ChangedEdges, edge.ID)
+		}
+		delete(beforeEdges, edge.ID)
+	}
+	for id := range beforeEdges {
+		diff.RemovedEdges = append(diff.RemovedEdges, id)
+	}
+
+	for _, ids := range [][]models.NodeID{diff.AddedNodes, diff.RemovedNodes, diff.ChangedNodes} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	for _, ids := range [][]models.EdgeID{diff.AddedEdges, diff.RemovedEdges, diff.ChangedEdges} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+
+	return diff
+}
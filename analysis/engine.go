@@ -1,11 +1,12 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/ywadi/PathwayDB/models"
-	"github.com/ywadi/PathwayDB/storage"
 	"github.com/ywadi/PathwayDB/types"
+	"go.opentelemetry.io/otel/attribute"
 	"gonum.org/v1/gonum/graph/community"
 	"strings"
 
@@ -14,164 +15,104 @@ import (
 
 // GraphAnalyzer provides comprehensive graph analysis capabilities
 type GraphAnalyzer struct {
-	storage storage.StorageEngine
+	storage GraphReader
+	// ctx holds the span of the command the analyzer runs for, see
+	// WithContext
+	ctx context.Context
 }
 
-// NewGraphAnalyzer creates a new graph analyzer instance
-func NewGraphAnalyzer(storage storage.StorageEngine) *GraphAnalyzer {
+// NewGraphAnalyzer creates a new graph analyzer instance reading graphs
+// from storage
+func NewGraphAnalyzer(storage GraphReader) *GraphAnalyzer {
 	return &GraphAnalyzer{
 		storage: storage,
 	}
 }
 
-// DepthFirstSearch performs a depth-first search traversal starting from a given node
-func (ga *GraphAnalyzer) DepthFirstSearch(graphID models.GraphID, startNodeID models.NodeID, options *types.TraversalOptions) (*types.TraversalResult, error) {
-	if options == nil {
-		options = &types.TraversalOptions{
-			MaxDepth:  -1, // No limit
-			Direction: types.DirectionForward,
-		}
-	}
+// DepthFirstSearch performs a depth-first search traversal starting from a given node.
+// MinDepth is compared with each node's shortest depth from the start
+// node, not the depth of the branch it is first reached on, so the nodes
+// left out do not depend on the order edges are followed in.
+func (ga *GraphAnalyzer) DepthFirstSearch(graphID models.GraphID, startNodeID models.NodeID, options *types.TraversalOptions) (result *types.TraversalResult, err error) {
+	traced, end := ga.traced("analysis.traverse", graphID)
+	defer func() { end(err, attribute.String("start", string(startNodeID))) }()
+	return traced.depthFirstSearch(graphID, startNodeID, options)
+}
 
-	visited := make(map[models.NodeID]bool)
+func (ga *GraphAnalyzer) depthFirstSearch(graphID models.GraphID, startNodeID models.NodeID, options *types.TraversalOptions) (*types.TraversalResult, error) {
 	var nodes []*models.Node
 	var edges []*models.Edge
 	var path []models.NodeID
-
-	// Use iterative DFS with a stack
-	type stackItem struct {
-		nodeID models.NodeID
-		depth  int
-	}
-
-	stack := []stackItem{{nodeID: startNodeID, depth: 0}}
-
-	for len(stack) > 0 {
-		// Main traversal loop
-		current := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-
-		// Skip if already visited or depth exceeded
-		if visited[current.nodeID] {
-			continue
-		}
-		if options.MaxDepth >= 0 && current.depth > options.MaxDepth {
-			continue
-		}
-
-		// Mark as visited
-		visited[current.nodeID] = true
-
-		// Get the current node
-		node, err := ga.storage.GetNode(graphID, current.nodeID)
+	var hops []types.Hop
+	var dangling []*models.Edge
+
+	var shortest map[models.NodeID]int
+	if options != nil && options.MinDepth > 0 {
+		shortest = make(map[models.NodeID]int)
+		_, err := ga.walk(ga.context(), graphID, startNodeID, options, true, func(node *models.Node, depth int, _ *models.Edge, _ *hop) error {
+			shortest[node.ID] = depth
+			return nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get node %s: %w", current.nodeID, err)
-		}
-
-		// Check stop condition
-		if options.StopCondition != nil && options.StopCondition(node) {
-			continue
-		}
-
-		// Check node type filter
-		nodeTypeMatch := true
-		if len(options.NodeTypes) > 0 {
-			nodeTypeMatch = false
-			for _, nodeType := range options.NodeTypes {
-				if node.Type == nodeType {
-					nodeTypeMatch = true
-					break
-				}
-			}
-		}
-
-		// Add to results if node type matches
-		if nodeTypeMatch {
-			nodes = append(nodes, node)
-			path = append(path, current.nodeID)
+			return nil, err
 		}
+	}
 
-		// Get connected edges
-		var connectedEdges []*models.Edge
-		switch options.Direction {
-		case types.DirectionForward:
-			connectedEdges, err = ga.storage.GetOutgoingEdges(graphID, current.nodeID)
-		case types.DirectionBackward:
-			connectedEdges, err = ga.storage.GetIncomingEdges(graphID, current.nodeID)
-		case types.DirectionBoth:
-			outgoing, err1 := ga.storage.GetOutgoingEdges(graphID, current.nodeID)
-			if err1 != nil {
-				return nil, fmt.Errorf("failed to get outgoing edges: %w", err1)
+	fanout, err := ga.walk(ga.context(), graphID, startNodeID, options, false, func(node *models.Node, depth int, via *models.Edge, reached *hop) error {
+		if shortest != nil {
+			// A random MAXFANOUT may let the depth-first walk reach nodes
+			// the breadth-first one did not
+			if d, ok := shortest[node.ID]; ok {
+				depth = d
 			}
-			incoming, err2 := ga.storage.GetIncomingEdges(graphID, current.nodeID)
-			if err2 != nil {
-				return nil, fmt.Errorf("failed to get incoming edges: %w", err2)
+			if depth < options.MinDepth {
+				return nil
 			}
-			connectedEdges = append(outgoing, incoming...)
 		}
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to get connected edges: %w", err)
+		nodes = append(nodes, node)
+		path = append(path, node.ID)
+		if reached != nil {
+			edges = append(edges, reached.edges...)
+			hops = append(hops, reached.public())
+		} else if via != nil {
+			edges = append(edges, via)
 		}
-
-		// Filter edges by type if specified
-		if len(options.EdgeTypes) > 0 {
-			var filteredEdges []*models.Edge
-			for _, edge := range connectedEdges {
-				for _, edgeType := range options.EdgeTypes {
-					if edge.Type == edgeType {
-						filteredEdges = append(filteredEdges, edge)
-						break
-					}
-				}
-			}
-			connectedEdges = filteredEdges
-		}
-
-		// Add edges and connected nodes to stack (in reverse order for DFS)
-		for i := len(connectedEdges) - 1; i >= 0; i-- {
-			edge := connectedEdges[i]
-
-			// Determine next node
-			var nextNodeID models.NodeID
-			switch options.Direction {
-			case types.DirectionForward:
-				if edge.FromNodeID == current.nodeID {
-					nextNodeID = edge.ToNodeID
-				}
-			case types.DirectionBackward:
-				if edge.ToNodeID == current.nodeID {
-					nextNodeID = edge.FromNodeID
-				}
-			case types.DirectionBoth:
-				if edge.FromNodeID == current.nodeID {
-					nextNodeID = edge.ToNodeID
-				} else if edge.ToNodeID == current.nodeID {
-					nextNodeID = edge.FromNodeID
-				}
-			}
-
-			// Add to stack if not visited
-			if nextNodeID != "" && !visited[nextNodeID] {
-				edges = append(edges, edge)
-				stack = append(stack, stackItem{
-					nodeID: nextNodeID,
-					depth:  current.depth + 1,
-				})
+		if options != nil && options.IncludeDangling {
+			found, err := ga.danglingEdges(graphID, node.ID, options)
+			if err != nil {
+				return err
 			}
+			dangling = append(dangling, found...)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &types.TraversalResult{
-		Nodes:    nodes,
-		Edges:    edges,
-		Path:     path,
-		Distance: len(path) - 1,
+		Nodes:              nodes,
+		Edges:              edges,
+		Path:               path,
+		Distance:           len(path) - 1,
+		FanoutLimitedNodes: fanout.limited(),
+		Hops:               hops,
+		DanglingEdges:      dangling,
 	}, nil
 }
 
 // AllPathsTraversal finds all complete paths from a starting node, exploring all branches
-func (ga *GraphAnalyzer) AllPathsTraversal(graphID models.GraphID, startNodeID models.NodeID, options *types.TraversalOptions) ([]*types.TraversalResult, error) {
+// allowed by options. With EdgeTypeTransitions, a path ends where the grammar
+// allows no further edge. Each path's Terminal says why it ended; a node
+// whose branches end for different reasons ends one path for each reason.
+// Paths, cycles included, with fewer edges than MinDepth are left out.
+func (ga *GraphAnalyzer) AllPathsTraversal(graphID models.GraphID, startNodeID models.NodeID, options *types.TraversalOptions) (paths []*types.TraversalResult, err error) {
+	traced, end := ga.traced("analysis.traverse_paths", graphID)
+	defer func() { end(err, attribute.String("start", string(startNodeID)), attribute.Int("paths", len(paths))) }()
+	return traced.allPathsTraversal(graphID, startNodeID, options)
+}
+
+func (ga *GraphAnalyzer) allPathsTraversal(graphID models.GraphID, startNodeID models.NodeID, options *types.TraversalOptions) ([]*types.TraversalResult, error) {
 	if options == nil {
 		options = &types.TraversalOptions{
 			MaxDepth:  -1, // No limit
@@ -183,37 +124,58 @@ func (ga *GraphAnalyzer) AllPathsTraversal(graphID models.GraphID, startNodeID m
 	visited := make(map[models.NodeID]bool)
 
 	// Start recursive path finding
-	err := ga.findAllPathsRecursive(graphID, startNodeID, "", visited, []models.NodeID{}, []*models.Edge{}, 0, options, &allPaths)
+	fanout := newFanoutLimiter(options)
+	_, err := ga.findAllPathsRecursive(graphID, startNodeID, "", visited, []models.NodeID{}, []*models.Edge{}, nil, 0, options, fanout, &allPaths)
 	if err != nil {
 		return nil, err
 	}
 
+	// Paths shorter than MinDepth are left out, though the longer paths
+	// through their nodes were followed
+	if options.MinDepth > 0 {
+		kept := allPaths[:0]
+		for _, path := range allPaths {
+			if path.Distance >= options.MinDepth {
+				kept = append(kept, path)
+			}
+		}
+		allPaths = kept
+	}
+
+	// Every path reports all truncated nodes, as a node truncated on one
+	// path hides paths that would otherwise branch from it
+	for _, path := range allPaths {
+		path.FanoutLimitedNodes = fanout.limited()
+	}
+
 	return allPaths, nil
 }
 
-// findAllPathsRecursive recursively finds all paths from current node
+// findAllPathsRecursive recursively finds all paths from current node. It
+// reports whether the node matched StopCondition, which ends the path
+// before it.
 func (ga *GraphAnalyzer) findAllPathsRecursive(graphID models.GraphID, nodeID models.NodeID, previousEdgeID models.EdgeID, visited map[models.NodeID]bool,
-	currentPath []models.NodeID, currentEdges []*models.Edge, depth int, options *types.TraversalOptions, allPaths *[]*types.TraversalResult) error {
+	currentPath []models.NodeID, currentEdges []*models.Edge, currentHops []types.Hop, depth int, options *types.TraversalOptions, fanout *fanoutLimiter, allPaths *[]*types.TraversalResult) (bool, error) {
 
 	// Check depth limit
 	if options.MaxDepth >= 0 && depth > options.MaxDepth {
-		return nil
+		return false, nil
 	}
 
 	// Skip if already visited in this path (prevent cycles)
 	if visited[nodeID] {
-		return nil
+		return false, nil
 	}
 
 	// Get the current node
 	node, err := ga.storage.GetNode(graphID, nodeID)
 	if err != nil {
-		return fmt.Errorf("failed to get node %s: %w", nodeID, err)
+		return false, fmt.Errorf("failed to get node %s: %w", nodeID, err)
 	}
 
 	// Check stop condition
 	if options.StopCondition != nil && options.StopCondition(node) {
-		return nil
+		return true, nil
 	}
 
 	// Check node type filter
@@ -228,6 +190,11 @@ func (ga *GraphAnalyzer) findAllPathsRecursive(graphID models.GraphID, nodeID mo
 		}
 	}
 
+	// Check staleness filter
+	if nodeTypeMatch && options.UpdatedBefore != nil {
+		nodeTypeMatch = node.UpdatedBefore(*options.UpdatedBefore)
+	}
+
 	// Add current node to path if it matches filter
 	if nodeTypeMatch {
 		currentPath = append(currentPath, nodeID)
@@ -247,18 +214,19 @@ func (ga *GraphAnalyzer) findAllPathsRecursive(graphID models.GraphID, nodeID mo
 	case types.DirectionBoth:
 		outgoing, err1 := ga.storage.GetOutgoingEdges(graphID, nodeID)
 		if err1 != nil {
-			return fmt.Errorf("failed to get outgoing edges: %w", err1)
+			return false, fmt.Errorf("failed to get outgoing edges: %w", err1)
 		}
 		incoming, err2 := ga.storage.GetIncomingEdges(graphID, nodeID)
 		if err2 != nil {
-			return fmt.Errorf("failed to get incoming edges: %w", err2)
+			return false, fmt.Errorf("failed to get incoming edges: %w", err2)
 		}
 		connectedEdges = append(outgoing, incoming...)
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to get connected edges: %w", err)
+		return false, fmt.Errorf("failed to get connected edges: %w", err)
 	}
+	connectedEdges = liveEdges(connectedEdges)
 
 	// Filter edges by type if specified
 	if len(options.EdgeTypes) > 0 {
@@ -274,6 +242,13 @@ func (ga *GraphAnalyzer) findAllPathsRecursive(graphID models.GraphID, nodeID mo
 		connectedEdges = filteredEdges
 	}
 
+	// Filter edges by the path grammar, given the edge this node was reached by
+	var via *models.Edge
+	if len(currentEdges) > 0 {
+		via = currentEdges[len(currentEdges)-1]
+	}
+	connectedEdges = filterTransitions(options, transitionState(options, via), connectedEdges)
+
 	// In 'both' direction, we need to filter out the edge we just came from
 	// before deciding if this is a leaf node.
 	var edgesToExplore []*models.Edge
@@ -286,223 +261,135 @@ func (ga *GraphAnalyzer) findAllPathsRecursive(graphID models.GraphID, nodeID mo
 	} else {
 		edgesToExplore = connectedEdges
 	}
+	edgesToExplore = fanout.limit(nodeID, edgesToExplore)
+
+	// Pass-through nodes are crossed by hops, so a node whose edges only
+	// reach dead-end pass-through nodes is a leaf as well
+	steps, err := ga.contract(graphID, edgeHops(nodeID, edgesToExplore, options.Direction), options, "", fanout)
+	if err != nil {
+		return false, err
+	}
 
 	// If no edges to explore, this is a leaf node - save the current path
-	if len(edgesToExplore) == 0 {
-		if len(currentPath) > 0 {
-			// Convert path to nodes
-			pathNodes := make([]*models.Node, len(currentPath))
-			for i, pathNodeID := range currentPath {
-				pathNode, err := ga.storage.GetNode(graphID, pathNodeID)
-				if err != nil {
-					return fmt.Errorf("failed to get path node %s: %w", pathNodeID, err)
-				}
-				pathNodes[i] = pathNode
-			}
+	if len(steps) == 0 {
+		return false, ga.savePath(graphID, currentPath, currentEdges, currentHops, types.TerminalLeaf, allPaths)
+	}
 
-			*allPaths = append(*allPaths, &types.TraversalResult{
-				Nodes:    pathNodes,
-				Edges:    append([]*models.Edge{}, currentEdges...), // Copy edges
-				Path:     append([]models.NodeID{}, currentPath...), // Copy path
-				Distance: len(currentPath) - 1,
-			})
+	// Explore each connected edge, or each hop across pass-through nodes
+	depthLimited, anyStopped := false, false
+	for _, step := range steps {
+		nextNodeID := step.to
+		newEdges := append(currentEdges, step.edges...)
+		var newHops []types.Hop
+		if len(options.PassThroughNodeTypes) > 0 {
+			newHops = append(currentHops, step.public())
 		}
-		return nil
-	}
 
-	// Explore each connected edge
-	for _, edge := range edgesToExplore {
-		// Determine next node
-		var nextNodeID models.NodeID
-		switch options.Direction {
-		case types.DirectionForward:
-			if edge.FromNodeID == nodeID {
-				nextNodeID = edge.ToNodeID
-			}
-		case types.DirectionBackward:
-			if edge.ToNodeID == nodeID {
-				nextNodeID = edge.FromNodeID
-			}
-		case types.DirectionBoth:
-			if edge.FromNodeID == nodeID {
-				nextNodeID = edge.ToNodeID
-			} else if edge.ToNodeID == nodeID {
-				nextNodeID = edge.FromNodeID
+		// If the neighbor is already in the path, we have a cycle.
+		if visited[nextNodeID] {
+			cycleStartIndex := -1
+			for i, pathNodeID := range currentPath {
+				if pathNodeID == nextNodeID {
+					cycleStartIndex = i
+					break
+				}
 			}
-		}
 
-		// Continue recursion if next node is valid
-		if nextNodeID != "" {
-			newEdges := append(currentEdges, edge)
-
-			// If the neighbor is already in the path, we have a cycle.
-			if visited[nextNodeID] {
-				cycleStartIndex := -1
-				for i, pathNodeID := range currentPath {
-					if pathNodeID == nextNodeID {
-						cycleStartIndex = i
-						break
+			if cycleStartIndex != -1 {
+				// Construct the cycle path and edges
+				cyclePath := currentPath[cycleStartIndex:]
+				cycleEdges := newEdges[cycleStartIndex:]
+				var cycleHops []types.Hop
+				if newHops != nil {
+					edgeStart := 0
+					for _, h := range newHops[:cycleStartIndex] {
+						edgeStart += len(h.Edges)
 					}
+					cycleEdges = newEdges[edgeStart:]
+					cycleHops = append(cycleHops, newHops[cycleStartIndex:]...)
 				}
 
-				if cycleStartIndex != -1 {
-					// Construct the cycle path and edges
-					cyclePath := currentPath[cycleStartIndex:]
-					cycleEdges := newEdges[cycleStartIndex:]
-
-					// Get node objects for the path
-					pathNodes := make([]*models.Node, len(cyclePath))
-					for i, pathNodeID := range cyclePath {
-						pathNode, nodeErr := ga.storage.GetNode(graphID, pathNodeID)
-						if nodeErr != nil {
-							return fmt.Errorf("failed to get cycle path node %s: %w", pathNodeID, nodeErr)
-						}
-						pathNodes[i] = pathNode
+				// Get node objects for the path
+				pathNodes := make([]*models.Node, len(cyclePath))
+				for i, pathNodeID := range cyclePath {
+					pathNode, nodeErr := ga.storage.GetNode(graphID, pathNodeID)
+					if nodeErr != nil {
+						return false, fmt.Errorf("failed to get cycle path node %s: %w", pathNodeID, nodeErr)
 					}
-
-					// Add the closing node to complete the cycle visualization
-					pathNodes = append(pathNodes, pathNodes[0])
-					cyclePath = append(cyclePath, cyclePath[0])
-
-					*allPaths = append(*allPaths, &types.TraversalResult{
-						Nodes:    pathNodes,
-						Edges:    cycleEdges,
-						Path:     cyclePath,
-						Distance: len(cyclePath) - 1,
-					})
+					pathNodes[i] = pathNode
 				}
-			} else {
-				// Continue recursion if it's not a cycle
-				err := ga.findAllPathsRecursive(graphID, nextNodeID, edge.ID, visited, currentPath, newEdges, depth+1, options, allPaths)
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-
-	return nil
-}
 
-// dfsRecursive performs the recursive DFS traversal
-func (ga *GraphAnalyzer) dfsRecursive(graphID models.GraphID, nodeID models.NodeID, visited map[models.NodeID]bool, nodes *[]*models.Node, edges *[]*models.Edge, path *[]models.NodeID, depth int, options *types.TraversalOptions) error {
-	// Check depth limit
-	if options.MaxDepth >= 0 && depth > options.MaxDepth {
-		return nil
-	}
-
-	// Skip if already visited
-	if visited[nodeID] {
-		return nil
-	}
-
-	// Mark as visited immediately to prevent cycles
-	visited[nodeID] = true
-
-	// Get the current node
-	node, err := ga.storage.GetNode(graphID, nodeID)
-	if err != nil {
-		return fmt.Errorf("failed to get node %s: %w", nodeID, err)
-	}
-
-	// Check stop condition
-	if options.StopCondition != nil && options.StopCondition(node) {
-		return nil
-	}
-
-	// Check node type filter and add to results if it matches
-	nodeTypeMatch := true
-	if len(options.NodeTypes) > 0 {
-		nodeTypeMatch = false
-		for _, nodeType := range options.NodeTypes {
-			if node.Type == nodeType {
-				nodeTypeMatch = true
-				break
+				// Add the closing node to complete the cycle visualization
+				pathNodes = append(pathNodes, pathNodes[0])
+				cyclePath = append(cyclePath, cyclePath[0])
+
+				*allPaths = append(*allPaths, &types.TraversalResult{
+					Nodes:    pathNodes,
+					Edges:    cycleEdges,
+					Path:     cyclePath,
+					Distance: len(cyclePath) - 1,
+					Hops:     cycleHops,
+					Terminal: types.TerminalCycle,
+				})
 			}
+		} else if options.MaxDepth >= 0 && depth >= options.MaxDepth {
+			// The neighbor is past the depth limit
+			depthLimited = true
+		} else {
+			// Continue recursion if it's not a cycle
+			stopped, err := ga.findAllPathsRecursive(graphID, nextNodeID, step.last().ID, visited, currentPath, newEdges, newHops, depth+1, options, fanout, allPaths)
+			if err != nil {
+				return false, err
+			}
+			anyStopped = anyStopped || stopped
 		}
 	}
 
-	// Add to results only if node type matches (or no filter specified)
-	if nodeTypeMatch {
-		*nodes = append(*nodes, node)
-		*path = append(*path, nodeID)
-	}
-
-	// Get connected edges based on direction
-	var connectedEdges []*models.Edge
-	switch options.Direction {
-	case types.DirectionForward:
-		connectedEdges, err = ga.storage.GetOutgoingEdges(graphID, nodeID)
-		if err != nil {
-			return fmt.Errorf("failed to get outgoing edges: %w", err)
+	// The path to this node ends here for the branches that could not go on
+	if depthLimited {
+		if err := ga.savePath(graphID, currentPath, currentEdges, currentHops, types.TerminalMaxDepth, allPaths); err != nil {
+			return false, err
 		}
-	case types.DirectionBackward:
-		connectedEdges, err = ga.storage.GetIncomingEdges(graphID, nodeID)
-		if err != nil {
-			return fmt.Errorf("failed to get incoming edges: %w", err)
-		}
-	case types.DirectionBoth:
-		outgoing, err1 := ga.storage.GetOutgoingEdges(graphID, nodeID)
-		if err1 != nil {
-			return fmt.Errorf("failed to get outgoing edges: %w", err1)
-		}
-		incoming, err2 := ga.storage.GetIncomingEdges(graphID, nodeID)
-		if err2 != nil {
-			return fmt.Errorf("failed to get incoming edges: %w", err2)
-		}
-		connectedEdges = append(outgoing, incoming...)
 	}
-
-	// Filter edges by type if specified
-	if len(options.EdgeTypes) > 0 {
-		var filteredEdges []*models.Edge
-		for _, edge := range connectedEdges {
-			for _, edgeType := range options.EdgeTypes {
-				if edge.Type == edgeType {
-					filteredEdges = append(filteredEdges, edge)
-					break
-				}
-			}
+	if anyStopped {
+		if err := ga.savePath(graphID, currentPath, currentEdges, currentHops, types.TerminalStopCondition, allPaths); err != nil {
+			return false, err
 		}
-		connectedEdges = filteredEdges
 	}
+	return false, nil
+}
 
-	// Traverse connected nodes
-	for _, edge := range connectedEdges {
-		// Determine next node based on direction and current node
-		var nextNodeID models.NodeID
-		switch options.Direction {
-		case types.DirectionForward:
-			if edge.FromNodeID == nodeID {
-				nextNodeID = edge.ToNodeID
-			}
-		case types.DirectionBackward:
-			if edge.ToNodeID == nodeID {
-				nextNodeID = edge.FromNodeID
-			}
-		case types.DirectionBoth:
-			if edge.FromNodeID == nodeID {
-				nextNodeID = edge.ToNodeID
-			} else if edge.ToNodeID == nodeID {
-				nextNodeID = edge.FromNodeID
-			}
-		}
+// savePath adds the path to allPaths as a path that ended for reason.
+// Nothing is added for an empty path, whose nodes were all filtered out.
+func (ga *GraphAnalyzer) savePath(graphID models.GraphID, path []models.NodeID, edges []*models.Edge, hops []types.Hop, reason types.TerminalReason, allPaths *[]*types.TraversalResult) error {
+	if len(path) == 0 {
+		return nil
+	}
 
-		// Recursively traverse the next node
-		if nextNodeID != "" && !visited[nextNodeID] {
-			// Add edge to results before traversing
-			*edges = append(*edges, edge)
-			err = ga.dfsRecursive(graphID, nextNodeID, visited, nodes, edges, path, depth+1, options)
-			if err != nil {
-				return err
-			}
+	// Convert path to nodes
+	pathNodes := make([]*models.Node, len(path))
+	for i, pathNodeID := range path {
+		pathNode, err := ga.storage.GetNode(graphID, pathNodeID)
+		if err != nil {
+			return fmt.Errorf("failed to get path node %s: %w", pathNodeID, err)
 		}
+		pathNodes[i] = pathNode
 	}
 
+	*allPaths = append(*allPaths, &types.TraversalResult{
+		Nodes:    pathNodes,
+		Edges:    append([]*models.Edge{}, edges...), // Copy edges
+		Path:     append([]models.NodeID{}, path...), // Copy path
+		Distance: len(path) - 1,
+		Hops:     append([]types.Hop(nil), hops...),
+		Terminal: reason,
+	})
 	return nil
 }
 
-// GetAllDependencies returns a flat list of all transitive dependencies
+// GetAllDependencies returns a flat list of all transitive dependencies in
+// the order DepthFirstSearch reaches them. GetDependenciesWithDepth sorts
+// them by depth instead.
 func (ga *GraphAnalyzer) GetAllDependencies(graphID models.GraphID, nodeID models.NodeID, options *types.TraversalOptions) ([]*models.Node, error) {
 	if options == nil {
 		options = &types.TraversalOptions{
@@ -526,15 +413,18 @@ func (ga *GraphAnalyzer) GetAllDependencies(graphID models.GraphID, nodeID model
 	return dependencies, nil
 }
 
-// GetAllDependents returns a flat list of all transitive dependents
+// GetAllDependents returns a flat list of all transitive dependents in the
+// order DepthFirstSearch reaches them, see GetDependentsWithDepth
 func (ga *GraphAnalyzer) GetAllDependents(graphID models.GraphID, nodeID models.NodeID, options *types.TraversalOptions) ([]*models.Node, error) {
 	if options == nil {
 		options = &types.TraversalOptions{
 			Direction: types.DirectionBackward,
 		}
 	} else {
-		// Override direction for dependents
-		options.Direction = types.DirectionBackward
+		// Override direction for dependents without mutating the caller's options
+		backward := *options
+		backward.Direction = types.DirectionBackward
+		options = &backward
 	}
 
 	result, err := ga.DepthFirstSearch(graphID, nodeID, options)
@@ -554,101 +444,115 @@ func (ga *GraphAnalyzer) GetAllDependents(graphID models.GraphID, nodeID models.
 }
 
 // GetShortestPath finds the shortest path between two nodes using BFS
-func (ga *GraphAnalyzer) GetShortestPath(graphID models.GraphID, fromNodeID, toNodeID models.NodeID, options *types.TraversalOptions) (*types.PathResult, error) {
+// over the edges options allow. With EdgeTypeTransitions it finds the
+// shortest path the grammar allows, which may be longer than the shortest
+// path overall. With PassThroughNodeTypes it finds the path of fewest hops,
+// follows only edges of EdgeTypes, and reports the hops.
+func (ga *GraphAnalyzer) GetShortestPath(graphID models.GraphID, fromNodeID, toNodeID models.NodeID, options *types.TraversalOptions) (result *types.PathResult, err error) {
+	traced, end := ga.traced("analysis.shortest_path", graphID)
+	defer func() {
+		length := -1
+		if result != nil {
+			length = result.Length
+		}
+		end(err, attribute.String("from", string(fromNodeID)), attribute.String("to", string(toNodeID)), attribute.Int("length", length))
+	}()
+	return traced.getShortestPath(graphID, fromNodeID, toNodeID, options)
+}
+
+func (ga *GraphAnalyzer) getShortestPath(graphID models.GraphID, fromNodeID, toNodeID models.NodeID, options *types.TraversalOptions) (*types.PathResult, error) {
 	if options == nil {
 		options = &types.TraversalOptions{
 			Direction: types.DirectionForward,
 		}
 	}
+	if len(options.PassThroughNodeTypes) > 0 {
+		return ga.shortestContractedPath(graphID, fromNodeID, toNodeID, options)
+	}
 
-	visited := make(map[models.NodeID]bool)
-	parent := make(map[models.NodeID]models.NodeID)
-	edgeMap := make(map[models.NodeID]models.EdgeID)
+	adj, err := ga.buildAdjacency(graphID, options.EdgeTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nodes are searched together with their transition state, so a node
+	// reached by an edge the path grammar cannot continue from does not
+	// hide a longer path through it that can
+	visited := make(map[walkKey]bool)
+	parent := make(map[walkKey]walkKey)
+	edgeMap := make(map[walkKey]models.EdgeID)
+	fanout := newFanoutLimiter(options)
 
 	// Queue for BFS
 	type queueItem struct {
-		nodeID models.NodeID
-		depth  int
+		key   walkKey
+		depth int
 	}
-	queue := []queueItem{{nodeID: fromNodeID, depth: 0}}
-	visited[fromNodeID] = true
+	queue := []queueItem{{key: walkKey{nodeID: fromNodeID}, depth: 0}}
+	visited[walkKey{nodeID: fromNodeID}] = true
 
+	var target walkKey
 	found := false
 	for len(queue) > 0 && !found {
 		current := queue[0]
 		queue = queue[1:]
 
-		if current.nodeID == toNodeID {
+		if current.key.nodeID == toNodeID {
+			target = current.key
 			found = true
 			break
 		}
-
-		// Get connected edges
-		var connectedEdges []*models.Edge
-		var err error
-		switch options.Direction {
-		case types.DirectionForward:
-			connectedEdges, err = ga.storage.GetOutgoingEdges(graphID, current.nodeID)
-		case types.DirectionBackward:
-			connectedEdges, err = ga.storage.GetIncomingEdges(graphID, current.nodeID)
-		case types.DirectionBoth:
-			outgoing, err1 := ga.storage.GetOutgoingEdges(graphID, current.nodeID)
-			if err1 != nil {
-				return nil, fmt.Errorf("failed to get outgoing edges: %w", err1)
-			}
-			incoming, err2 := ga.storage.GetIncomingEdges(graphID, current.nodeID)
-			if err2 != nil {
-				return nil, fmt.Errorf("failed to get incoming edges: %w", err2)
-			}
-			connectedEdges = append(outgoing, incoming...)
+		if err := ga.context().Err(); err != nil {
+			return nil, err
 		}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to get connected edges: %w", err)
-		}
+		connectedEdges := adj.edges(current.key.nodeID, options.Direction)
+		connectedEdges = filterTransitions(options, current.key.state, connectedEdges)
+		connectedEdges = fanout.limit(current.key.nodeID, connectedEdges)
 
 		for _, edge := range connectedEdges {
 			var nextNodeID models.NodeID
 			switch options.Direction {
 			case types.DirectionForward:
-				if edge.FromNodeID == current.nodeID {
+				if edge.FromNodeID == current.key.nodeID {
 					nextNodeID = edge.ToNodeID
 				}
 			case types.DirectionBackward:
-				if edge.ToNodeID == current.nodeID {
+				if edge.ToNodeID == current.key.nodeID {
 					nextNodeID = edge.FromNodeID
 				}
 			case types.DirectionBoth:
-				if edge.FromNodeID == current.nodeID {
+				if edge.FromNodeID == current.key.nodeID {
 					nextNodeID = edge.ToNodeID
-				} else if edge.ToNodeID == current.nodeID {
+				} else if edge.ToNodeID == current.key.nodeID {
 					nextNodeID = edge.FromNodeID
 				}
 			}
 
-			if nextNodeID != "" && !visited[nextNodeID] {
-				visited[nextNodeID] = true
-				parent[nextNodeID] = current.nodeID
-				edgeMap[nextNodeID] = edge.ID
+			next := walkKey{nodeID: nextNodeID, state: transitionState(options, edge)}
+			if nextNodeID != "" && !visited[next] {
+				visited[next] = true
+				parent[next] = current.key
+				edgeMap[next] = edge.ID
 				queue = append(queue, queueItem{
-					nodeID: nextNodeID,
-					depth:  current.depth + 1,
+					key:   next,
+					depth: current.depth + 1,
 				})
 			}
 		}
 	}
 
 	if !found {
-		return nil, fmt.Errorf("no path found from %s to %s", fromNodeID, toNodeID)
+		return nil, fmt.Errorf("%w from %s to %s", ErrNoPath, fromNodeID, toNodeID)
 	}
 
 	// Reconstruct path
 	var path []models.NodeID
 	var edges []models.EdgeID
-	currentNode := toNodeID
+	currentNode := target
 
-	for currentNode != fromNodeID {
-		path = append([]models.NodeID{currentNode}, path...)
+	for currentNode != (walkKey{nodeID: fromNodeID}) {
+		path = append([]models.NodeID{currentNode.nodeID}, path...)
 		if edgeID, exists := edgeMap[currentNode]; exists {
 			edges = append([]models.EdgeID{edgeID}, edges...)
 		}
@@ -662,11 +566,26 @@ func (ga *GraphAnalyzer) GetShortestPath(graphID models.GraphID, fromNodeID, toN
 		Path:       path,
 		Length:     len(path) - 1,
 		Edges:      edges,
+
+		FanoutLimitedNodes: fanout.limited(),
 	}, nil
 }
 
 // AllShortestPaths finds all shortest paths between two nodes
-func (ga *GraphAnalyzer) AllShortestPaths(graphID models.GraphID, fromNodeID, toNodeID models.NodeID) ([]*types.PathResult, error) {
+func (ga *GraphAnalyzer) AllShortestPaths(graphID models.GraphID, fromNodeID, toNodeID models.NodeID) (paths []*types.PathResult, err error) {
+	traced, end := ga.traced("analysis.shortest_paths", graphID)
+	defer func() {
+		end(err, attribute.String("from", string(fromNodeID)), attribute.String("to", string(toNodeID)), attribute.Int("paths", len(paths)))
+	}()
+	return traced.allShortestPaths(graphID, fromNodeID, toNodeID)
+}
+
+func (ga *GraphAnalyzer) allShortestPaths(graphID models.GraphID, fromNodeID, toNodeID models.NodeID) ([]*types.PathResult, error) {
+	adj, err := ga.buildAdjacency(graphID, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	// Use BFS to find all paths of minimum length
 	type queueItem struct {
 		nodeID models.NodeID
@@ -719,15 +638,12 @@ func (ga *GraphAnalyzer) AllShortestPaths(graphID models.GraphID, fromNodeID, to
 			continue
 		}
 		visited[current.nodeID] = current.dist
-
-		// Get outgoing edges
-		outgoingEdges, err := ga.storage.GetOutgoingEdges(graphID, current.nodeID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get outgoing edges from %s: %w", current.nodeID, err)
+		if err := ga.context().Err(); err != nil {
+			return nil, err
 		}
 
 		// Explore neighbors
-		for _, edge := range outgoingEdges {
+		for _, edge := range adj.edges(current.nodeID, types.DirectionForward) {
 			nextNodeID := edge.ToNodeID
 
 			// Avoid cycles in the current path
@@ -756,7 +672,13 @@ func (ga *GraphAnalyzer) AllShortestPaths(graphID models.GraphID, fromNodeID, to
 }
 
 // FindAllCycles finds all elementary cycles in the graph.
-func (ga *GraphAnalyzer) FindAllCycles(graphID models.GraphID, options *types.TraversalOptions) ([][]models.NodeID, error) {
+func (ga *GraphAnalyzer) FindAllCycles(graphID models.GraphID, options *types.TraversalOptions) (cycles [][]models.NodeID, err error) {
+	traced, end := ga.traced("analysis.cycles", graphID)
+	defer func() { end(err, attribute.Int("cycles", len(cycles))) }()
+	return traced.findAllCycles(graphID, options)
+}
+
+func (ga *GraphAnalyzer) findAllCycles(graphID models.GraphID, options *types.TraversalOptions) ([][]models.NodeID, error) {
 	if options == nil {
 		options = &types.TraversalOptions{
 			Direction: types.DirectionForward,
@@ -767,12 +689,16 @@ func (ga *GraphAnalyzer) FindAllCycles(graphID models.GraphID, options *types.Tr
 	if err != nil {
 		return nil, fmt.Errorf("failed to list nodes for cycle detection: %w", err)
 	}
+	adj, err := ga.buildAdjacency(graphID, options.EdgeTypes)
+	if err != nil {
+		return nil, err
+	}
 
 	var allCycles [][]models.NodeID
 	for _, node := range allNodes {
 		path := []models.NodeID{node.ID}
 		blocked := make(map[models.NodeID]bool)
-		cycles, err := ga.findCyclesRecursive(graphID, node.ID, node.ID, path, blocked, &allCycles, options)
+		cycles, err := ga.findCyclesRecursive(adj, node.ID, node.ID, path, blocked, &allCycles)
 		if err != nil {
 			return nil, err
 		}
@@ -827,29 +753,15 @@ func cycleToString(path []models.NodeID) string {
 	return strings.Join(ids, "->")
 }
 
-func (ga *GraphAnalyzer) findCyclesRecursive(graphID models.GraphID, startNode, currentNode models.NodeID, path []models.NodeID, blocked map[models.NodeID]bool, allCycles *[][]models.NodeID, options *types.TraversalOptions) ([][]models.NodeID, error) {
+func (ga *GraphAnalyzer) findCyclesRecursive(adj *adjacency, startNode, currentNode models.NodeID, path []models.NodeID, blocked map[models.NodeID]bool, allCycles *[][]models.NodeID) ([][]models.NodeID, error) {
 	var newCycles [][]models.NodeID
 	blocked[currentNode] = true
 	defer func() { blocked[currentNode] = false }() // Unblock node on backtrack
 
-	connectedEdges, err := ga.storage.GetOutgoingEdges(graphID, currentNode)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get outgoing edges from %s: %w", currentNode, err)
-	}
-
-	// Filter edges by type if specified
-	if options != nil && len(options.EdgeTypes) > 0 {
-		var filteredEdges []*models.Edge
-		for _, edge := range connectedEdges {
-			for _, edgeType := range options.EdgeTypes {
-				if edge.Type == edgeType {
-					filteredEdges = append(filteredEdges, edge)
-					break
-				}
-			}
-		}
-		connectedEdges = filteredEdges
+	if err := ga.context().Err(); err != nil {
+		return nil, err
 	}
+	connectedEdges := adj.edges(currentNode, types.DirectionForward)
 
 	for _, edge := range connectedEdges {
 		neighbor := edge.ToNodeID
@@ -861,7 +773,7 @@ func (ga *GraphAnalyzer) findCyclesRecursive(graphID models.GraphID, startNode,
 			newCycles = append(newCycles, cycle)
 		} else if !blocked[neighbor] {
 			newPath := append(path, neighbor)
-			cycles, err := ga.findCyclesRecursive(graphID, startNode, neighbor, newPath, blocked, allCycles, options)
+			cycles, err := ga.findCyclesRecursive(adj, startNode, neighbor, newPath, blocked, allCycles)
 			if err != nil {
 				return nil, err
 			}
@@ -873,13 +785,15 @@ func (ga *GraphAnalyzer) findCyclesRecursive(graphID models.GraphID, startNode,
 	return newCycles, nil
 }
 
-// HasCycles checks if the graph contains any cycles by calling FindAllCycles.
+// HasCycles checks if the graph contains any cycles, as a strongly connected
+// component holding one, so it takes linear time where FindAllCycles could
+// take exponential time.
 func (ga *GraphAnalyzer) HasCycles(graphID models.GraphID, options *types.TraversalOptions) (bool, error) {
-	cycles, err := ga.FindAllCycles(graphID, options)
+	components, err := ga.FindStronglyConnectedComponents(graphID, options)
 	if err != nil {
 		return false, err
 	}
-	return len(cycles) > 0, nil
+	return len(components) > 0, nil
 }
 
 // dfsHasCycle performs DFS to detect cycles using the three-color approach
@@ -911,6 +825,7 @@ func (ga *GraphAnalyzer) dfsHasCycle(graphID models.GraphID, nodeID models.NodeI
 	if err != nil {
 		return false, fmt.Errorf("failed to get connected edges: %w", err)
 	}
+	connectedEdges = liveEdges(connectedEdges)
 
 	// Filter edges by type if specified
 	if len(options.EdgeTypes) > 0 {
@@ -968,34 +883,88 @@ func (ga *GraphAnalyzer) dfsHasCycle(graphID models.GraphID, nodeID models.NodeI
 	return false, nil
 }
 
-// GetGraphStats calculates comprehensive statistics for a graph
-func (ga *GraphAnalyzer) GetGraphStats(graphID models.GraphID, options *types.TraversalOptions) (*types.GraphStats, error) {
-	// Get all nodes and edges
-	allNodes, err := ga.storage.ListNodes(graphID)
+// typeCounts returns stats holding the node and edge counts of a graph,
+// overall and by type. They are read from the counts the storage keeps if
+// it keeps them, which include nodes and edges that have expired but not
+// yet been cleaned up; otherwise the nodes are listed and the edges
+// counted.
+func (ga *GraphAnalyzer) typeCounts(graphID models.GraphID, edges []*models.Edge) (*types.GraphStats, error) {
+	stats := &types.GraphStats{
+		NodeTypeCount: make(map[models.NodeType]int),
+		EdgeTypeCount: make(map[models.EdgeType]int),
+	}
+
+	if counter, ok := ga.storage.(typeCounter); ok {
+		nodeCounts, err := counter.CountNodesByType(graphID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count nodes: %w", err)
+		}
+		edgeCounts, err := counter.CountEdgesByType(graphID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count edges: %w", err)
+		}
+		for nodeType, count := range nodeCounts {
+			stats.NodeTypeCount[nodeType] = count
+			stats.NodeCount += count
+		}
+		for edgeType, count := range edgeCounts {
+			stats.EdgeTypeCount[edgeType] = count
+			stats.EdgeCount += count
+		}
+		return stats, nil
+	}
+
+	nodes, err := ga.storage.ListNodes(graphID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get nodes: %w", err)
 	}
+	stats.NodeCount = len(nodes)
+	for _, node := range nodes {
+		stats.NodeTypeCount[node.Type]++
+	}
+	stats.EdgeCount = len(edges)
+	for _, edge := range edges {
+		stats.EdgeTypeCount[edge.Type]++
+	}
+	return stats, nil
+}
+
+// GetGraphStats calculates comprehensive statistics for a graph. With
+// PassThroughNodeTypes, the statistics describe the graph with pass-through
+// nodes contracted into the hops across them. Node and edge counts cover the
+// whole graph, while NodeTypes and EdgeTypes filter the root, leaf and
+// orphan counts as they do GetRootNodes.
+func (ga *GraphAnalyzer) GetGraphStats(graphID models.GraphID, options *types.TraversalOptions) (*types.GraphStats, error) {
+	if options != nil && len(options.PassThroughNodeTypes) > 0 {
+		return ga.contractedGraphStats(graphID, options)
+	}
 
 	allEdges, err := ga.storage.ListEdges(graphID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get edges: %w", err)
 	}
 
-	stats := &types.GraphStats{
-		NodeCount:     len(allNodes),
-		EdgeCount:     len(allEdges),
-		NodeTypeCount: make(map[models.NodeType]int),
-		EdgeTypeCount: make(map[models.EdgeType]int),
-	}
-
-	// Count node types
-	for _, node := range allNodes {
-		stats.NodeTypeCount[node.Type]++
+	stats, err := ga.typeCounts(graphID, allEdges)
+	if err != nil {
+		return nil, err
 	}
 
-	// Count edge types
+	// Count dangling edges and parallel edges sharing (from, to, type)
+	multiplicity := make(map[edgeTriple]int)
 	for _, edge := range allEdges {
-		stats.EdgeTypeCount[edge.Type]++
+		if edge.Dangling() {
+			stats.DanglingEdgeCount++
+		}
+
+		triple := edgeTriple{from: edge.FromNodeID, to: edge.ToNodeID, edgeType: edge.Type}
+		multiplicity[triple]++
+		count := multiplicity[triple]
+		if count == 2 {
+			stats.ParallelEdgeGroupCount++
+		}
+		if count > stats.MaxEdgeMultiplicity {
+			stats.MaxEdgeMultiplicity = count
+		}
 	}
 
 	// Calculate root nodes (nodes with no incoming edges)
@@ -1043,35 +1012,24 @@ func (ga *GraphAnalyzer) GetGraphStats(graphID models.GraphID, options *types.Tr
 	return stats, nil
 }
 
-// GetRootNodes returns nodes with no incoming edges (dependencies)
+// GetRootNodes returns nodes with no incoming edges (dependencies). With
+// NodeTypes only nodes of those types are candidates, and with EdgeTypes a
+// node is a root if it has no incoming edges of those types, whatever the
+// types of the nodes at their other end.
 func (ga *GraphAnalyzer) GetRootNodes(graphID models.GraphID, options *types.TraversalOptions) ([]*models.Node, error) {
-	allNodes, err := ga.storage.ListNodes(graphID)
+	candidates, err := ga.classificationCandidates(graphID, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get nodes: %w", err)
+		return nil, err
 	}
 
 	var rootNodes []*models.Node
-	for _, node := range allNodes {
+	for _, node := range candidates {
 		incomingEdges, err := ga.storage.GetIncomingEdges(graphID, node.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get incoming edges for node %s: %w", node.ID, err)
 		}
 
-		// Filter edges by type if specified
-		if options != nil && len(options.EdgeTypes) > 0 {
-			var filteredEdges []*models.Edge
-			for _, edge := range incomingEdges {
-				for _, edgeType := range options.EdgeTypes {
-					if edge.Type == edgeType {
-						filteredEdges = append(filteredEdges, edge)
-						break
-					}
-				}
-			}
-			incomingEdges = filteredEdges
-		}
-
-		if len(incomingEdges) == 0 {
+		if len(classifiedEdges(incomingEdges, options)) == 0 {
 			rootNodes = append(rootNodes, node)
 		}
 	}
@@ -1079,35 +1037,22 @@ func (ga *GraphAnalyzer) GetRootNodes(graphID models.GraphID, options *types.Tra
 	return rootNodes, nil
 }
 
-// GetLeafNodes returns nodes with no outgoing edges (dependents)
+// GetLeafNodes returns nodes with no outgoing edges (dependents), filtered
+// the same way as GetRootNodes
 func (ga *GraphAnalyzer) GetLeafNodes(graphID models.GraphID, options *types.TraversalOptions) ([]*models.Node, error) {
-	allNodes, err := ga.storage.ListNodes(graphID)
+	candidates, err := ga.classificationCandidates(graphID, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get nodes: %w", err)
+		return nil, err
 	}
 
 	var leafNodes []*models.Node
-	for _, node := range allNodes {
+	for _, node := range candidates {
 		outgoingEdges, err := ga.storage.GetOutgoingEdges(graphID, node.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get outgoing edges for node %s: %w", node.ID, err)
 		}
 
-		// Filter edges by type if specified
-		if options != nil && len(options.EdgeTypes) > 0 {
-			var filteredEdges []*models.Edge
-			for _, edge := range outgoingEdges {
-				for _, edgeType := range options.EdgeTypes {
-					if edge.Type == edgeType {
-						filteredEdges = append(filteredEdges, edge)
-						break
-					}
-				}
-			}
-			outgoingEdges = filteredEdges
-		}
-
-		if len(outgoingEdges) == 0 {
+		if len(classifiedEdges(outgoingEdges, options)) == 0 {
 			leafNodes = append(leafNodes, node)
 		}
 	}
@@ -1115,7 +1060,44 @@ func (ga *GraphAnalyzer) GetLeafNodes(graphID models.GraphID, options *types.Tra
 	return leafNodes, nil
 }
 
-// GetOrphanNodes returns nodes with no connections (neither incoming nor outgoing edges)
+// classificationCandidates returns the nodes that root, leaf and orphan
+// classification considers: every node of the graph, or with NodeTypes only
+// nodes of those types
+func (ga *GraphAnalyzer) classificationCandidates(graphID models.GraphID, options *types.TraversalOptions) ([]*models.Node, error) {
+	allNodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	if options == nil || len(options.NodeTypes) == 0 {
+		return allNodes, nil
+	}
+
+	var candidates []*models.Node
+	for _, node := range allNodes {
+		if matchesNodeTypes(node, options.NodeTypes) {
+			candidates = append(candidates, node)
+		}
+	}
+	return candidates, nil
+}
+
+// classifiedEdges returns the edges that count towards classifying a node:
+// those that are not dangling, and with EdgeTypes only edges of those types
+func classifiedEdges(edges []*models.Edge, options *types.TraversalOptions) []*models.Edge {
+	edges = liveEdges(edges)
+	if options == nil || len(options.EdgeTypes) == 0 {
+		return edges
+	}
+
+	var filtered []*models.Edge
+	for _, edge := range edges {
+		if matchesEdgeTypes(edge, options.EdgeTypes) {
+			filtered = append(filtered, edge)
+		}
+	}
+	return filtered
+}
+
 // CalculateDegreeCentrality calculates the degree centrality for nodes in the graph.
 // If a specific nodeID is provided, it calculates for that node only.
 // Otherwise, it calculates for all nodes in the graph.
@@ -1230,14 +1212,16 @@ func (ga *GraphAnalyzer) toGonumGraph(graphID models.GraphID) (*simple.Undirecte
 	return gonumGraph, nodeMap, nil
 }
 
+// GetOrphanNodes returns nodes with no connections (neither incoming nor
+// outgoing edges), filtered the same way as GetRootNodes
 func (ga *GraphAnalyzer) GetOrphanNodes(graphID models.GraphID, options *types.TraversalOptions) ([]*models.Node, error) {
-	allNodes, err := ga.storage.ListNodes(graphID)
+	candidates, err := ga.classificationCandidates(graphID, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get nodes: %w", err)
+		return nil, err
 	}
 
 	var orphanNodes []*models.Node
-	for _, node := range allNodes {
+	for _, node := range candidates {
 		incomingEdges, err := ga.storage.GetIncomingEdges(graphID, node.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get incoming edges for node %s: %w", node.ID, err)
@@ -1248,7 +1232,7 @@ func (ga *GraphAnalyzer) GetOrphanNodes(graphID models.GraphID, options *types.T
 			return nil, fmt.Errorf("failed to get outgoing edges for node %s: %w", node.ID, err)
 		}
 
-		if len(incomingEdges) == 0 && len(outgoingEdges) == 0 {
+		if len(classifiedEdges(incomingEdges, options)) == 0 && len(classifiedEdges(outgoingEdges, options)) == 0 {
 			orphanNodes = append(orphanNodes, node)
 		}
 	}
@@ -1256,114 +1240,119 @@ func (ga *GraphAnalyzer) GetOrphanNodes(graphID models.GraphID, options *types.T
 	return orphanNodes, nil
 }
 
-// GetMaxDepth calculates the maximum depth of the dependency tree
+// GetMaxDepth returns the number of edges on the longest path from a root
+// node, following only edges of EdgeTypes when it is set. Each node's
+// height is computed once, by a depth-first search with an explicit stack,
+// so the cost is linear in the size of the graph however deep it is. An
+// edge back to a node still being searched closes a cycle and adds nothing
+// to the depth.
 func (ga *GraphAnalyzer) GetMaxDepth(graphID models.GraphID, options *types.TraversalOptions) (int, error) {
 	rootNodes, err := ga.GetRootNodes(graphID, options)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get root nodes: %w", err)
 	}
 
-	maxDepth := 0
-	for _, rootNode := range rootNodes {
-		depth, err := ga.calculateNodeDepth(graphID, rootNode.ID, make(map[models.NodeID]bool), 0, options)
-		if err != nil {
-			return 0, fmt.Errorf("failed to calculate depth for root node %s: %w", rootNode.ID, err)
-		}
-		if depth > maxDepth {
-			maxDepth = depth
-		}
+	var edgeTypes []models.EdgeType
+	if options != nil {
+		edgeTypes = options.EdgeTypes
 	}
-
-	return maxDepth, nil
-}
-
-// calculateNodeDepth recursively calculates the depth of a node
-func (ga *GraphAnalyzer) calculateNodeDepth(graphID models.GraphID, nodeID models.NodeID, visited map[models.NodeID]bool, currentDepth int, options *types.TraversalOptions) (int, error) {
-	if visited[nodeID] {
-		return currentDepth, nil
-	}
-
-	visited[nodeID] = true
-	maxChildDepth := currentDepth
-
-	outgoingEdges, err := ga.storage.GetOutgoingEdges(graphID, nodeID)
+	adj, err := ga.buildAdjacency(graphID, edgeTypes)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get outgoing edges: %w", err)
+		return 0, err
 	}
 
-	for _, edge := range outgoingEdges {
-		childDepth, err := ga.calculateNodeDepth(graphID, edge.ToNodeID, visited, currentDepth+1, options)
-		if err != nil {
-			return 0, err
-		}
-		if childDepth > maxChildDepth {
-			maxChildDepth = childDepth
+	roots := make([]models.NodeID, len(rootNodes))
+	for i, rootNode := range rootNodes {
+		roots[i] = rootNode.ID
+	}
+	height, err := ga.heights(roots, func(nodeID models.NodeID) []models.NodeID {
+		edges := adj.edges(nodeID, types.DirectionForward)
+		children := make([]models.NodeID, len(edges))
+		for i, edge := range edges {
+			children[i] = edge.ToNodeID
 		}
+		return children
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	visited[nodeID] = false
-	return maxChildDepth, nil
-}
-
-// GetConnectedComponentCount calculates the number of connected components in the graph
-func (ga *GraphAnalyzer) GetConnectedComponentCount(graphID models.GraphID, options *types.TraversalOptions) (int, error) {
-	allNodes, err := ga.storage.ListNodes(graphID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get nodes: %w", err)
+	maxDepth := 0
+	for _, root := range roots {
+		maxDepth = max(maxDepth, height[root])
 	}
+	return maxDepth, nil
+}
 
-	visited := make(map[models.NodeID]bool)
-	componentCount := 0
+// heights returns the length of the longest chain of children below each
+// node reachable from roots. It searches depth first with an explicit stack
+// and keeps each node's height once found, so shared subgraphs are searched
+// once and deep chains do not grow the call stack. A child that is an
+// ancestor on the current path closes a cycle and adds nothing to the
+// height.
+func (ga *GraphAnalyzer) heights(roots []models.NodeID, children func(models.NodeID) []models.NodeID) (map[models.NodeID]int, error) {
+	// height holds the depth below each finished node, and searching the
+	// nodes on the current path
+	height := make(map[models.NodeID]int)
+	searching := make(map[models.NodeID]bool)
+	type frame struct {
+		nodeID   models.NodeID
+		children []models.NodeID
+		next     int
+	}
+
+	for _, root := range roots {
+		if _, done := height[root]; done {
+			continue
+		}
+		searching[root] = true
+		stack := []*frame{{nodeID: root, children: children(root)}}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.next < len(top.children) {
+				child := top.children[top.next]
+				top.next++
+				if _, done := height[child]; done || searching[child] {
+					continue
+				}
+				if err := ga.context().Err(); err != nil {
+					return nil, err
+				}
+				searching[child] = true
+				stack = append(stack, &frame{nodeID: child, children: children(child)})
+				continue
+			}
 
-	for _, node := range allNodes {
-		if !visited[node.ID] {
-			componentCount++
-			err := ga.markConnectedComponent(graphID, node.ID, visited, options)
-			if err != nil {
-				return 0, fmt.Errorf("failed to mark connected component: %w", err)
+			// Every child is finished, or still being searched as an
+			// ancestor on a cycle, which has no height yet
+			depth := 0
+			for _, child := range top.children {
+				if childHeight, done := height[child]; done {
+					depth = max(depth, childHeight+1)
+				}
 			}
+			height[top.nodeID] = depth
+			delete(searching, top.nodeID)
+			stack = stack[:len(stack)-1]
 		}
 	}
 
-	return componentCount, nil
+	return height, nil
 }
 
-// markConnectedComponent marks all nodes in a connected component as visited
-func (ga *GraphAnalyzer) markConnectedComponent(graphID models.GraphID, nodeID models.NodeID, visited map[models.NodeID]bool, options *types.TraversalOptions) error {
-	if visited[nodeID] {
-		return nil
-	}
-
-	visited[nodeID] = true
-
-	// Get all connected edges (both incoming and outgoing)
-	outgoingEdges, err := ga.storage.GetOutgoingEdges(graphID, nodeID)
-	if err != nil {
-		return fmt.Errorf("failed to get outgoing edges: %w", err)
-	}
-
-	incomingEdges, err := ga.storage.GetIncomingEdges(graphID, nodeID)
+// GetConnectedComponentCount calculates the number of connected components
+// in the graph, honoring the same options as ComputeComponents
+func (ga *GraphAnalyzer) GetConnectedComponentCount(graphID models.GraphID, options *types.TraversalOptions) (int, error) {
+	components, err := ga.ComputeComponents(graphID, options)
 	if err != nil {
-		return fmt.Errorf("failed to get incoming edges: %w", err)
+		return 0, fmt.Errorf("failed to compute components: %w", err)
 	}
 
-	allEdges := append(outgoingEdges, incomingEdges...)
-
-	// Recursively mark connected nodes
-	for _, edge := range allEdges {
-		if edge.FromNodeID == nodeID && !visited[edge.ToNodeID] {
-			err := ga.markConnectedComponent(graphID, edge.ToNodeID, visited, options)
-			if err != nil {
-				return err
-			}
-		}
-		if edge.ToNodeID == nodeID && !visited[edge.FromNodeID] {
-			err := ga.markConnectedComponent(graphID, edge.FromNodeID, visited, options)
-			if err != nil {
-				return err
-			}
+	count := 0
+	for _, label := range components {
+		if label+1 > count {
+			count = label + 1
 		}
 	}
-
-	return nil
+	return count, nil
 }
@@ -0,0 +1,23 @@
+package analysis
+
+import "errors"
+
+// Errors returned by the analyzer, wrapped with the nodes involved, so
+// callers can tell them apart with errors.Is. Storage errors, such as
+// storage.ErrNodeNotFound, are wrapped rather than replaced and match too.
+var (
+	// ErrNoPath is returned when no path connects the requested nodes:
+	// "no path found from <from> to <to>"
+	ErrNoPath = errors.New("no path found")
+
+	// ErrInvalidWeight is returned by weighted analyses for an edge whose
+	// weight is missing, not a number or negative:
+	// "invalid edge weight: <reason>"
+	ErrInvalidWeight = errors.New("invalid edge weight")
+
+	// ErrCycleDetected is returned by analyses that need an acyclic graph,
+	// such as TopologicalSort, and find a cycle. Cycle detection itself
+	// reports cycles as results, not errors:
+	// "cycle detected: nodes <id>, <id>... are on cycles"
+	ErrCycleDetected = errors.New("cycle detected")
+)
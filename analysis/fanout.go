@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// fanoutLimiter applies TraversalOptions.MaxFanout to the edges of each
+// expanded node and records the nodes it truncated, in the order they were
+// first truncated
+type fanoutLimiter struct {
+	options *types.TraversalOptions
+	seen    map[models.NodeID]bool
+	nodes   []models.NodeID
+}
+
+// newFanoutLimiter creates a limiter for one traversal
+func newFanoutLimiter(options *types.TraversalOptions) *fanoutLimiter {
+	return &fanoutLimiter{
+		options: options,
+		seen:    make(map[models.NodeID]bool),
+	}
+}
+
+// limit returns the edges of nodeID to expand. Edges are returned unchanged
+// when MaxFanout is not set or not exceeded; otherwise MaxFanout of them are
+// kept, sorted by edge ID.
+func (f *fanoutLimiter) limit(nodeID models.NodeID, edges []*models.Edge) []*models.Edge {
+	maxFanout := f.options.MaxFanout
+	if maxFanout <= 0 || len(edges) <= maxFanout {
+		return edges
+	}
+
+	if !f.seen[nodeID] {
+		f.seen[nodeID] = true
+		f.nodes = append(f.nodes, nodeID)
+	}
+
+	kept := append([]*models.Edge{}, edges...)
+	if f.options.FanoutStrategy == types.FanoutRandom {
+		// Reservoir sampling, seeded per node so the sample does not depend
+		// on the order nodes are visited in
+		hash := fnv.New64a()
+		hash.Write([]byte(nodeID))
+		rng := rand.New(rand.NewSource(f.options.FanoutSeed ^ int64(hash.Sum64())))
+		for i := maxFanout; i < len(edges); i++ {
+			if j := rng.Intn(i + 1); j < maxFanout {
+				kept[j] = edges[i]
+			}
+		}
+		kept = kept[:maxFanout]
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].ID < kept[j].ID })
+	return kept[:maxFanout]
+}
+
+// limited returns the truncated nodes, or nil if there were none
+func (f *fanoutLimiter) limited() []models.NodeID {
+	return f.nodes
+}
@@ -0,0 +1,177 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// DefaultStatsHistoryInterval is how often StatsHistoryRecorder records
+// snapshots by default
+const DefaultStatsHistoryInterval = 24 * time.Hour
+
+// SnapshotStats computes the compact statistics recorded in a graph's stats
+// history: node and edge counts by type, whether the graph has a directed
+// cycle and its weakly connected component count. It reads the nodes and
+// edges once and runs linear passes over them, unlike GetGraphStats, which
+// enumerates cycles and walks depths.
+func (ga *GraphAnalyzer) SnapshotStats(graphID models.GraphID) (*models.StatsSnapshot, error) {
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+
+	snapshot := &models.StatsSnapshot{
+		NodeCount:     len(nodes),
+		EdgeCount:     len(edges),
+		NodeTypeCount: make(map[models.NodeType]int),
+		EdgeTypeCount: make(map[models.EdgeType]int),
+	}
+	for _, node := range nodes {
+		snapshot.NodeTypeCount[node.Type]++
+	}
+	for _, edge := range edges {
+		snapshot.EdgeTypeCount[edge.Type]++
+	}
+
+	ids, labels := labelComponents(nodes, edges, &types.TraversalOptions{})
+	for _, label := range labels {
+		if label+1 > snapshot.ConnectedComponents {
+			snapshot.ConnectedComponents = label + 1
+		}
+	}
+
+	// A graph has a cycle if it has a self-loop or a strongly connected
+	// component of more than one node
+	index := make(map[models.NodeID]int, len(ids))
+	roots := make([]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+		roots[i] = i
+	}
+	adjacency := make([][]int, len(ids))
+	for _, edge := range edges {
+		from, okFrom := index[edge.FromNodeID]
+		to, okTo := index[edge.ToNodeID]
+		if !okFrom || !okTo {
+			continue
+		}
+		if from == to {
+			snapshot.HasCycles = true
+		}
+		adjacency[from] = append(adjacency[from], to)
+	}
+	if !snapshot.HasCycles {
+		_, components := stronglyConnectedComponents(adjacency, roots)
+		for _, members := range components {
+			if len(members) > 1 {
+				snapshot.HasCycles = true
+				break
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// StatsHistoryRecorder records a stats snapshot of every graph when it is
+// started and then once per interval, keeping retainDays days of them per
+// graph. Dates come from the storage engine's clock, so recording more than
+// once a day replaces that day's snapshot.
+type StatsHistoryRecorder struct {
+	storage    storage.StorageEngine
+	analyzer   *GraphAnalyzer
+	interval   time.Duration
+	retainDays int
+	logger     *slog.Logger
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewStatsHistoryRecorder creates a stats history recorder. A zero interval
+// uses DefaultStatsHistoryInterval, a zero retainDays keeps every snapshot
+// and a nil logger uses slog.Default.
+func NewStatsHistoryRecorder(storageEngine storage.StorageEngine, interval time.Duration, retainDays int, logger *slog.Logger) *StatsHistoryRecorder {
+	if interval <= 0 {
+		interval = DefaultStatsHistoryInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &StatsHistoryRecorder{
+		storage:    storageEngine,
+		analyzer:   NewGraphAnalyzer(storageEngine),
+		interval:   interval,
+		retainDays: retainDays,
+		logger:     logger,
+	}
+}
+
+// Start records snapshots now and then every interval until Stop
+func (r *StatsHistoryRecorder) Start() {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(r.stop, r.done)
+}
+
+// Stop halts the background loop and waits for an in-flight run to finish
+func (r *StatsHistoryRecorder) Stop() {
+	if r.done == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.done = nil
+}
+
+// run is the main loop for the recorder
+func (r *StatsHistoryRecorder) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Record(); err != nil {
+			r.logger.Warn("failed to record stats history", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Record records a stats snapshot of every graph now, as the background loop
+// does, and prunes snapshots past the retention. A graph that fails does not
+// stop the others; their errors are returned together.
+func (r *StatsHistoryRecorder) Record() error {
+	graphs, err := r.storage.ListGraphs()
+	if err != nil {
+		return fmt.Errorf("failed to list graphs: %w", err)
+	}
+
+	var errs []error
+	for _, graph := range graphs {
+		snapshot, err := r.analyzer.SnapshotStats(graph.ID)
+		if err == nil {
+			err = r.storage.RecordStatsSnapshot(graph.ID, snapshot, r.retainDays)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("graph %s: %w", graph.ID, err))
+			continue
+		}
+		r.logger.Debug("Stats snapshot recorded", "graph", graph.ID, "date", snapshot.Date,
+			"nodes", snapshot.NodeCount, "edges", snapshot.EdgeCount)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,73 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// PairwiseDistances returns the number of edges on the shortest path from
+// each of nodeIDs to each other, following options.Direction and, if any
+// are given, only edges of options.EdgeTypes. A node is 0 from itself, and
+// a node that cannot be reached, or only in more than options.MaxDepth
+// edges when MaxDepth is not negative, is -1. Every node must exist. The
+// graph's edges are loaded once and searched breadth-first from each node,
+// so the cost grows with the number of nodes rather than of pairs.
+func (ga *GraphAnalyzer) PairwiseDistances(graphID models.GraphID, nodeIDs []models.NodeID, options *types.TraversalOptions) (map[models.NodeID]map[models.NodeID]int, error) {
+	if options == nil {
+		options = &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: -1}
+	}
+	for _, nodeID := range nodeIDs {
+		if _, err := ga.storage.GetNode(graphID, nodeID); err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
+		}
+	}
+
+	snapshot, err := ga.snapshotAdjacency(graphID, options.Direction, options.EdgeTypes)
+	if err != nil {
+		return nil, err
+	}
+	for _, nodeID := range nodeIDs {
+		// Deleted between the check and the snapshot
+		if _, ok := snapshot.index[nodeID]; !ok {
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeID, storage.ErrNodeNotFound)
+		}
+	}
+
+	distances := make(map[models.NodeID]map[models.NodeID]int, len(nodeIDs))
+	depth := make([]int, len(snapshot.nodes))
+	for _, source := range nodeIDs {
+		if err := ga.context().Err(); err != nil {
+			return nil, err
+		}
+
+		for i := range depth {
+			depth[i] = -1
+		}
+		start := snapshot.index[source]
+		depth[start] = 0
+		queue := []int{start}
+		for len(queue) > 0 {
+			u := queue[0]
+			queue = queue[1:]
+			if options.MaxDepth >= 0 && depth[u] >= options.MaxDepth {
+				continue
+			}
+			for _, v := range snapshot.out[u] {
+				if depth[v] < 0 {
+					depth[v] = depth[u] + 1
+					queue = append(queue, v)
+				}
+			}
+		}
+
+		row := make(map[models.NodeID]int, len(nodeIDs))
+		for _, target := range nodeIDs {
+			row[target] = depth[snapshot.index[target]]
+		}
+		distances[source] = row
+	}
+	return distances, nil
+}
@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// edgeTriple identifies a group of parallel edges
+type edgeTriple struct {
+	from     models.NodeID
+	to       models.NodeID
+	edgeType models.EdgeType
+}
+
+// ParallelEdges returns the (from, to, type) triples shared by at least
+// minCount edges, sorted by count descending. Edges are scanned one source
+// node at a time, so only the groups of the current node are held in memory
+// besides those that reach the threshold.
+func (ga *GraphAnalyzer) ParallelEdges(graphID models.GraphID, minCount int) ([]types.ParallelEdgeGroup, error) {
+	if minCount < 2 {
+		return nil, fmt.Errorf("minimum multiplicity must be at least 2, got %d", minCount)
+	}
+
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	var groups []types.ParallelEdgeGroup
+	for _, node := range nodes {
+		outgoing, err := ga.storage.GetOutgoingEdges(graphID, node.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get outgoing edges for node %s: %w", node.ID, err)
+		}
+
+		counts := make(map[edgeTriple]int)
+		for _, edge := range outgoing {
+			counts[edgeTriple{from: edge.FromNodeID, to: edge.ToNodeID, edgeType: edge.Type}]++
+		}
+		for triple, count := range counts {
+			if count >= minCount {
+				groups = append(groups, types.ParallelEdgeGroup{
+					FromNodeID: triple.from,
+					ToNodeID:   triple.to,
+					Type:       triple.edgeType,
+					Count:      count,
+				})
+			}
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.FromNodeID != b.FromNodeID {
+			return a.FromNodeID < b.FromNodeID
+		}
+		if a.ToNodeID != b.ToNodeID {
+			return a.ToNodeID < b.ToNodeID
+		}
+		return a.Type < b.Type
+	})
+
+	return groups, nil
+}
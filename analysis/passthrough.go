@@ -0,0 +1,401 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// hop is a step from one node to the next. It is a single edge unless it
+// crosses pass-through nodes, listed in via in the order crossed.
+type hop struct {
+	from  models.NodeID
+	to    models.NodeID
+	edges []*models.Edge
+	via   []models.NodeID
+}
+
+// last returns the edge a hop arrives by, or nil for the empty hop that
+// reaches the start node
+func (h hop) last() *models.Edge {
+	if len(h.edges) == 0 {
+		return nil
+	}
+	return h.edges[len(h.edges)-1]
+}
+
+// public converts a hop to its reported form
+func (h hop) public() types.Hop {
+	edges := make([]models.EdgeID, len(h.edges))
+	for i, edge := range h.edges {
+		edges[i] = edge.ID
+	}
+	return types.Hop{From: h.from, To: h.to, Edges: edges, Via: h.via}
+}
+
+// isPassThrough reports whether node is of one of options'
+// PassThroughNodeTypes
+func isPassThrough(node *models.Node, options *types.TraversalOptions) bool {
+	return len(options.PassThroughNodeTypes) > 0 && matchesNodeTypes(node, options.PassThroughNodeTypes)
+}
+
+// edgeHops returns a single-edge hop for each edge leading away from nodeID
+// in direction
+func edgeHops(nodeID models.NodeID, edges []*models.Edge, direction types.TraversalDirection) []hop {
+	hops := make([]hop, 0, len(edges))
+	for _, edge := range edges {
+		if next := otherEnd(edge, nodeID, direction); next != "" {
+			hops = append(hops, hop{from: nodeID, to: next, edges: []*models.Edge{edge}})
+		}
+	}
+	return hops
+}
+
+// hopsFrom returns the hops leaving nodeID, which was reached in transition
+// state previous, with pass-through nodes contracted
+func (ga *GraphAnalyzer) hopsFrom(graphID models.GraphID, nodeID models.NodeID, options *types.TraversalOptions, previous models.EdgeType,
+	keep models.NodeID, fanout *fanoutLimiter) ([]hop, error) {
+	edges, err := ga.walkEdges(graphID, nodeID, options, previous, fanout)
+	if err != nil {
+		return nil, err
+	}
+	return ga.contract(graphID, edgeHops(nodeID, edges, options.Direction), options, keep, fanout)
+}
+
+// contract extends each hop that ends on a pass-through node by the edges
+// leaving that node, until every hop ends on a node that is not pass-through
+// or on keep. Edges are followed as in a walk, never back along the edge a
+// pass-through node was reached by, and each pass-through node is crossed at
+// most once per transition state, so chains of them cannot loop. A
+// pass-through node matching StopCondition ends the hops through it. Hops
+// are returned in the order their edges were followed.
+func (ga *GraphAnalyzer) contract(graphID models.GraphID, hops []hop, options *types.TraversalOptions, keep models.NodeID, fanout *fanoutLimiter) ([]hop, error) {
+	if len(options.PassThroughNodeTypes) == 0 {
+		return hops, nil
+	}
+
+	contracted := make([]hop, 0, len(hops))
+	crossed := make(map[walkKey]bool)
+	stack := make([]hop, 0, len(hops))
+	for i := len(hops) - 1; i >= 0; i-- {
+		stack = append(stack, hops[i])
+	}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current.to == keep {
+			contracted = append(contracted, current)
+			continue
+		}
+
+		node, err := ga.storage.GetNode(graphID, current.to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", current.to, err)
+		}
+		if !isPassThrough(node, options) {
+			contracted = append(contracted, current)
+			continue
+		}
+		if options.StopCondition != nil && options.StopCondition(node) {
+			continue
+		}
+
+		arrival := current.last()
+		key := walkKey{nodeID: node.ID, state: transitionState(options, arrival)}
+		if crossed[key] {
+			continue
+		}
+		crossed[key] = true
+
+		edges, err := ga.walkEdges(graphID, node.ID, options, key.state, fanout)
+		if err != nil {
+			return nil, err
+		}
+		// Push in reverse so the first edge is followed first
+		for i := len(edges) - 1; i >= 0; i-- {
+			next := otherEnd(edges[i], node.ID, options.Direction)
+			if next == "" || edges[i].ID == arrival.ID {
+				continue
+			}
+			stack = append(stack, hop{
+				from:  current.from,
+				to:    next,
+				edges: append(current.edges[:len(current.edges):len(current.edges)], edges[i]),
+				via:   append(current.via[:len(current.via):len(current.via)], node.ID),
+			})
+		}
+	}
+	return contracted, nil
+}
+
+// shortestContractedPath implements GetShortestPath for PassThroughNodeTypes.
+// Every hop counts as one step, however many pass-through nodes it crosses.
+func (ga *GraphAnalyzer) shortestContractedPath(graphID models.GraphID, fromNodeID, toNodeID models.NodeID, options *types.TraversalOptions) (*types.PathResult, error) {
+	start := walkKey{nodeID: fromNodeID}
+	visited := map[walkKey]bool{start: true}
+	reachedBy := make(map[walkKey]hop)
+	parent := make(map[walkKey]walkKey)
+	fanout := newFanoutLimiter(options)
+
+	queue := []walkKey{start}
+	var target walkKey
+	found := false
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.nodeID == toNodeID {
+			target = current
+			found = true
+			break
+		}
+
+		hops, err := ga.hopsFrom(graphID, current.nodeID, options, current.state, toNodeID, fanout)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hops {
+			next := walkKey{nodeID: h.to, state: transitionState(options, h.last())}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			reachedBy[next] = h
+			parent[next] = current
+			queue = append(queue, next)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w from %s to %s", ErrNoPath, fromNodeID, toNodeID)
+	}
+
+	var steps []hop
+	for key := target; key != start; key = parent[key] {
+		steps = append([]hop{reachedBy[key]}, steps...)
+	}
+
+	result := &types.PathResult{
+		FromNodeID: fromNodeID,
+		ToNodeID:   toNodeID,
+		Path:       []models.NodeID{fromNodeID},
+		Length:     len(steps),
+		Edges:      []models.EdgeID{},
+		Hops:       make([]types.Hop, len(steps)),
+
+		FanoutLimitedNodes: fanout.limited(),
+	}
+	for i, step := range steps {
+		result.Path = append(result.Path, step.to)
+		result.Hops[i] = step.public()
+		result.Edges = append(result.Edges, result.Hops[i].Edges...)
+	}
+	return result, nil
+}
+
+// CalculateContractedDegreeCentrality is CalculateDegreeCentrality with the
+// nodes of the passThrough types contracted: a node's degree counts the hops
+// leading to or from it, each crossing any number of pass-through nodes,
+// rather than its edges. Pass-through nodes are scored only when asked for
+// by nodeID.
+func (ga *GraphAnalyzer) CalculateContractedDegreeCentrality(graphID models.GraphID, nodeID *models.NodeID, direction types.TraversalDirection,
+	passThrough []models.NodeType) (map[models.NodeID]int, error) {
+	contracting := &types.TraversalOptions{PassThroughNodeTypes: passThrough}
+	var nodesToProcess []*models.Node
+	if nodeID != nil {
+		node, err := ga.storage.GetNode(graphID, *nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", *nodeID, err)
+		}
+		nodesToProcess = append(nodesToProcess, node)
+	} else {
+		nodes, err := ga.storage.ListNodes(graphID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		for _, node := range nodes {
+			if !isPassThrough(node, contracting) {
+				nodesToProcess = append(nodesToProcess, node)
+			}
+		}
+	}
+
+	var directions []*types.TraversalOptions
+	if direction == types.DirectionForward || direction == types.DirectionBoth {
+		directions = append(directions, &types.TraversalOptions{Direction: types.DirectionForward, PassThroughNodeTypes: passThrough})
+	}
+	if direction == types.DirectionBackward || direction == types.DirectionBoth {
+		directions = append(directions, &types.TraversalOptions{Direction: types.DirectionBackward, PassThroughNodeTypes: passThrough})
+	}
+
+	scores := make(map[models.NodeID]int, len(nodesToProcess))
+	for _, node := range nodesToProcess {
+		degree := 0
+		for _, options := range directions {
+			hops, err := ga.hopsFrom(graphID, node.ID, options, types.TransitionStart, "", newFanoutLimiter(options))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get hops for %s: %w", node.ID, err)
+			}
+			degree += len(hops)
+		}
+		scores[node.ID] = degree
+	}
+	return scores, nil
+}
+
+// contractedGraphStats implements GetGraphStats for PassThroughNodeTypes.
+// Pass-through nodes are left out, and every hop between the remaining
+// nodes counts as one edge, following only edges of options' EdgeTypes.
+// Edge type and parallel edge counts still describe the stored edges, and
+// only nodes of options' NodeTypes count as roots, leaves and orphans.
+func (ga *GraphAnalyzer) contractedGraphStats(graphID models.GraphID, options *types.TraversalOptions) (*types.GraphStats, error) {
+	allNodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	allEdges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get edges: %w", err)
+	}
+
+	forward := &types.TraversalOptions{
+		MaxDepth:             -1,
+		Direction:            types.DirectionForward,
+		EdgeTypes:            options.EdgeTypes,
+		PassThroughNodeTypes: options.PassThroughNodeTypes,
+	}
+	fanout := newFanoutLimiter(forward)
+	stats := &types.GraphStats{
+		NodeTypeCount: make(map[models.NodeType]int),
+		EdgeTypeCount: make(map[models.EdgeType]int),
+	}
+
+	var nodes []models.NodeID
+	classified := make(map[models.NodeID]bool)
+	successors := make(map[models.NodeID][]models.NodeID)
+	inDegree := make(map[models.NodeID]int)
+	for _, node := range allNodes {
+		if isPassThrough(node, forward) {
+			continue
+		}
+		stats.NodeCount++
+		stats.NodeTypeCount[node.Type]++
+		nodes = append(nodes, node.ID)
+		classified[node.ID] = matchesNodeTypes(node, options.NodeTypes)
+
+		hops, err := ga.hopsFrom(graphID, node.ID, forward, types.TransitionStart, "", fanout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hops for node %s: %w", node.ID, err)
+		}
+		for _, h := range hops {
+			successors[node.ID] = append(successors[node.ID], h.to)
+			inDegree[h.to]++
+		}
+		stats.EdgeCount += len(hops)
+	}
+
+	multiplicity := make(map[edgeTriple]int)
+	for _, edge := range allEdges {
+		stats.EdgeTypeCount[edge.Type]++
+		if edge.Dangling() {
+			stats.DanglingEdgeCount++
+		}
+
+		triple := edgeTriple{from: edge.FromNodeID, to: edge.ToNodeID, edgeType: edge.Type}
+		multiplicity[triple]++
+		count := multiplicity[triple]
+		if count == 2 {
+			stats.ParallelEdgeGroupCount++
+		}
+		if count > stats.MaxEdgeMultiplicity {
+			stats.MaxEdgeMultiplicity = count
+		}
+	}
+
+	// Hops are undirected for components
+	neighbors := make(map[models.NodeID][]models.NodeID)
+	for _, id := range nodes {
+		for _, next := range successors[id] {
+			neighbors[id] = append(neighbors[id], next)
+			neighbors[next] = append(neighbors[next], id)
+		}
+	}
+
+	var roots []models.NodeID
+	state := make(map[models.NodeID]int)
+	component := make(map[models.NodeID]bool)
+	for _, id := range nodes {
+		root := classified[id] && inDegree[id] == 0
+		leaf := classified[id] && len(successors[id]) == 0
+		if root {
+			stats.RootNodeCount++
+			roots = append(roots, id)
+		}
+		if leaf {
+			stats.LeafNodeCount++
+		}
+		if root && leaf {
+			stats.OrphanNodeCount++
+		}
+		if !stats.HasCycles && state[id] == 0 && contractedCycle(id, successors, state) {
+			stats.HasCycles = true
+		}
+
+		if component[id] {
+			continue
+		}
+		stats.ConnectedComponents++
+		component[id] = true
+		stack := []models.NodeID{id}
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, next := range neighbors[current] {
+				if !component[next] {
+					component[next] = true
+					stack = append(stack, next)
+				}
+			}
+		}
+	}
+
+	height, err := ga.heights(roots, func(nodeID models.NodeID) []models.NodeID { return successors[nodeID] })
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range roots {
+		stats.MaxDepth = max(stats.MaxDepth, height[root])
+	}
+
+	return stats, nil
+}
+
+// contractedCycle reports whether a cycle is reachable from nodeID over
+// successors, searching depth first with an explicit stack. state is 0 for
+// unvisited nodes, 1 while on the stack and 2 once finished.
+func contractedCycle(nodeID models.NodeID, successors map[models.NodeID][]models.NodeID, state map[models.NodeID]int) bool {
+	type frame struct {
+		nodeID models.NodeID
+		next   int
+	}
+	state[nodeID] = 1
+	stack := []*frame{{nodeID: nodeID}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if top.next < len(successors[top.nodeID]) {
+			next := successors[top.nodeID][top.next]
+			top.next++
+			switch state[next] {
+			case 1:
+				return true
+			case 0:
+				state[next] = 1
+				stack = append(stack, &frame{nodeID: next})
+			}
+			continue
+		}
+		state[top.nodeID] = 2
+		stack = stack[:len(stack)-1]
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package analysis
+
+import "github.com/ywadi/PathwayDB/models"
+
+// GraphReader is the part of storage.StorageEngine the analyzer reads
+// graphs through. The Badger engine satisfies it, and so can an in-memory
+// graph, such as the fixtures algorithm tests run on without a database.
+type GraphReader interface {
+	GetNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, error)
+	ListNodes(graphID models.GraphID) ([]*models.Node, error)
+	ListEdges(graphID models.GraphID) ([]*models.Edge, error)
+	GetOutgoingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error)
+	GetIncomingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error)
+}
+
+// typeCounter is implemented by readers that keep the number of nodes and
+// edges of each type, as the Badger engine does. GetGraphStats reads the
+// counts from it rather than counting listed nodes and edges; wrappers such
+// as the what-if overlay do not implement it, so their changes are counted.
+type typeCounter interface {
+	CountNodesByType(graphID models.GraphID) (map[models.NodeType]int, error)
+	CountEdgesByType(graphID models.GraphID) (map[models.EdgeType]int, error)
+}
@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// FindStronglyConnectedComponents returns the strongly connected components
+// of a graph that hold a cycle: those of more than one node, and single
+// nodes with an edge to themselves. Unlike FindAllCycles it takes time
+// linear in the size of the graph, however many cycles the components hold.
+//
+// Each component lists its nodes sorted by ID, and components are sorted by
+// size, largest first, and then by their first node ID. Only edges of
+// options.EdgeTypes are followed when it is set; the other options do not
+// apply, as a component is the same in either direction.
+func (ga *GraphAnalyzer) FindStronglyConnectedComponents(graphID models.GraphID, options *types.TraversalOptions) (components [][]models.NodeID, err error) {
+	traced, end := ga.traced("analysis.scc", graphID)
+	defer func() { end(err, attribute.Int("components", len(components))) }()
+	return traced.findStronglyConnectedComponents(graphID, options)
+}
+
+func (ga *GraphAnalyzer) findStronglyConnectedComponents(graphID models.GraphID, options *types.TraversalOptions) ([][]models.NodeID, error) {
+	if options == nil {
+		options = &types.TraversalOptions{}
+	}
+
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+	// Sorted so that members sorted by index are sorted by node ID
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	index := make(map[models.NodeID]int, len(nodes))
+	roots := make([]int, len(nodes))
+	for i, node := range nodes {
+		index[node.ID] = i
+		roots[i] = i
+	}
+
+	adjacency := make([][]int, len(nodes))
+	for _, edge := range liveEdges(edges) {
+		if !matchesEdgeTypes(edge, options.EdgeTypes) {
+			continue
+		}
+		from, okFrom := index[edge.FromNodeID]
+		to, okTo := index[edge.ToNodeID]
+		if !okFrom || !okTo {
+			continue
+		}
+		adjacency[from] = append(adjacency[from], to)
+	}
+	if err := ga.context().Err(); err != nil {
+		return nil, err
+	}
+
+	_, found := stronglyConnectedComponents(adjacency, roots)
+	var components [][]models.NodeID
+	for _, members := range found {
+		if len(members) == 1 && !selfLoop(adjacency, members[0]) {
+			continue
+		}
+		sort.Ints(members)
+		component := make([]models.NodeID, len(members))
+		for i, n := range members {
+			component[i] = nodes[n].ID
+		}
+		components = append(components, component)
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return components[i][0] < components[j][0]
+	})
+	return components, nil
+}
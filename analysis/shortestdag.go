@@ -0,0 +1,191 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// step is an edge a traversal can follow and the node it leads to
+type step struct {
+	edge *models.Edge
+	next models.NodeID
+}
+
+// steps returns the live edges of nodeID a traversal in direction follows,
+// only those of edgeTypes if any are given
+func (ga *GraphAnalyzer) steps(graphID models.GraphID, nodeID models.NodeID, direction types.TraversalDirection, edgeTypes []models.EdgeType) ([]step, error) {
+	var steps []step
+	if direction != types.DirectionBackward {
+		outgoing, err := ga.storage.GetOutgoingEdges(graphID, nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get outgoing edges: %w", err)
+		}
+		for _, edge := range liveEdges(outgoing) {
+			if matchesEdgeTypes(edge, edgeTypes) {
+				steps = append(steps, step{edge: edge, next: edge.ToNodeID})
+			}
+		}
+	}
+	if direction != types.DirectionForward {
+		incoming, err := ga.storage.GetIncomingEdges(graphID, nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get incoming edges: %w", err)
+		}
+		for _, edge := range liveEdges(incoming) {
+			if matchesEdgeTypes(edge, edgeTypes) {
+				steps = append(steps, step{edge: edge, next: edge.FromNodeID})
+			}
+		}
+	}
+	return steps, nil
+}
+
+// distancesFrom returns the number of edges from start to each node a
+// breadth-first search in direction reaches. Nodes at maxDepth, if it is
+// not negative, are not expanded, and the search stops expanding once it
+// has reached stop.
+func (ga *GraphAnalyzer) distancesFrom(graphID models.GraphID, start, stop models.NodeID, maxDepth int, direction types.TraversalDirection, edgeTypes []models.EdgeType) (map[models.NodeID]int, error) {
+	dist := map[models.NodeID]int{start: 0}
+	queue := []models.NodeID{start}
+	for len(queue) > 0 {
+		if err := ga.context().Err(); err != nil {
+			return nil, err
+		}
+		current := queue[0]
+		queue = queue[1:]
+		if maxDepth >= 0 && dist[current] >= maxDepth {
+			continue
+		}
+		// Every node nearer than stop has been queued
+		if reached, ok := dist[stop]; ok && dist[current] >= reached {
+			break
+		}
+
+		steps, err := ga.steps(graphID, current, direction, edgeTypes)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range steps {
+			if _, seen := dist[s.next]; !seen {
+				dist[s.next] = dist[current] + 1
+				queue = append(queue, s.next)
+			}
+		}
+	}
+	return dist, nil
+}
+
+// reverse returns the direction that walks the edges of direction backward
+func reverse(direction types.TraversalDirection) types.TraversalDirection {
+	switch direction {
+	case types.DirectionForward:
+		return types.DirectionBackward
+	case types.DirectionBackward:
+		return types.DirectionForward
+	default:
+		return types.DirectionBoth
+	}
+}
+
+// ShortestPathDAG returns the edges on at least one shortest path from
+// fromNodeID to toNodeID, the nodes they join, and which of those edges
+// every shortest path uses. Only options.Direction and options.EdgeTypes
+// apply. One search forward from fromNodeID and one backward from toNodeID
+// give each node's distance from both ends, and an edge from u to v is kept
+// when dist(from, u) + 1 + dist(v, to) is the shortest distance, so the
+// cost is linear in the edges searched however many shortest paths there
+// are. Nodes are ordered by their distance from fromNodeID and edges by the
+// distance of the node they leave, each then by ID.
+func (ga *GraphAnalyzer) ShortestPathDAG(graphID models.GraphID, fromNodeID, toNodeID models.NodeID, options *types.TraversalOptions) (dag *types.Subgraph, err error) {
+	traced, end := ga.traced("analysis.shortest_path_dag", graphID)
+	defer func() {
+		length, edges := -1, 0
+		if dag != nil {
+			length, edges = dag.Distance, len(dag.Edges)
+		}
+		end(err, attribute.String("from", string(fromNodeID)), attribute.String("to", string(toNodeID)),
+			attribute.Int("length", length), attribute.Int("edges", edges))
+	}()
+	return traced.shortestPathDAG(graphID, fromNodeID, toNodeID, options)
+}
+
+func (ga *GraphAnalyzer) shortestPathDAG(graphID models.GraphID, fromNodeID, toNodeID models.NodeID, options *types.TraversalOptions) (*types.Subgraph, error) {
+	if options == nil {
+		options = &types.TraversalOptions{Direction: types.DirectionForward}
+	}
+	for _, nodeID := range []models.NodeID{fromNodeID, toNodeID} {
+		if _, err := ga.storage.GetNode(graphID, nodeID); err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
+		}
+	}
+
+	fromDist, err := ga.distancesFrom(graphID, fromNodeID, toNodeID, -1, options.Direction, options.EdgeTypes)
+	if err != nil {
+		return nil, err
+	}
+	distance, ok := fromDist[toNodeID]
+	if !ok {
+		return nil, fmt.Errorf("%w from %s to %s", ErrNoPath, fromNodeID, toNodeID)
+	}
+	toDist, err := ga.distancesFrom(graphID, toNodeID, fromNodeID, distance, reverse(options.Direction), options.EdgeTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nodes on a shortest path, by their distance from fromNodeID
+	var onPath []models.NodeID
+	for nodeID, d := range fromDist {
+		if rest, ok := toDist[nodeID]; ok && d+rest == distance {
+			onPath = append(onPath, nodeID)
+		}
+	}
+	sort.Slice(onPath, func(i, j int) bool {
+		if fromDist[onPath[i]] != fromDist[onPath[j]] {
+			return fromDist[onPath[i]] < fromDist[onPath[j]]
+		}
+		return onPath[i] < onPath[j]
+	})
+
+	dag := &types.Subgraph{Nodes: []*models.Node{}, Edges: []*models.Edge{}, Distance: distance, Critical: []models.EdgeID{}}
+	// The layer of an edge is the distance of the node it leaves
+	layer := make(map[models.EdgeID]int)
+	layerEdges := make([]int, distance)
+	for _, nodeID := range onPath {
+		node, err := ga.storage.GetNode(graphID, nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
+		}
+		dag.Nodes = append(dag.Nodes, node)
+		if nodeID == toNodeID {
+			continue
+		}
+
+		steps, err := ga.steps(graphID, nodeID, options.Direction, options.EdgeTypes)
+		if err != nil {
+			return nil, err
+		}
+		var edges []*models.Edge
+		for _, s := range steps {
+			if rest, ok := toDist[s.next]; ok && fromDist[nodeID]+1+rest == distance {
+				layer[s.edge.ID] = fromDist[nodeID]
+				edges = append(edges, s.edge)
+			}
+		}
+		sort.Slice(edges, func(i, j int) bool { return edges[i].ID < edges[j].ID })
+		dag.Edges = append(dag.Edges, edges...)
+		layerEdges[fromDist[nodeID]] += len(edges)
+	}
+
+	// Every shortest path crosses each layer once, so an edge alone in its
+	// layer is on all of them
+	for _, edge := range dag.Edges {
+		if layerEdges[layer[edge.ID]] == 1 {
+			dag.Critical = append(dag.Critical, edge.ID)
+		}
+	}
+	return dag, nil
+}
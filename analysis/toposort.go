@@ -0,0 +1,119 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TopologicalSort returns the nodes of a graph so that every edge leads
+// from a node to one later in the order, using Kahn's algorithm. Nodes are
+// taken level by level, and by ID within a level, so the order is the same
+// on every call and each level can be processed in parallel.
+//
+// Only the EdgeTypes of options apply, as in FindAllCycles. If the edges
+// form a cycle, it fails with ErrCycleDetected naming the nodes on cycles.
+func (ga *GraphAnalyzer) TopologicalSort(graphID models.GraphID, options *types.TraversalOptions) (order []types.TopologicalEntry, err error) {
+	traced, end := ga.traced("analysis.toposort", graphID)
+	defer func() { end(err, attribute.Int("nodes", len(order))) }()
+	return traced.topologicalSort(graphID, options)
+}
+
+func (ga *GraphAnalyzer) topologicalSort(graphID models.GraphID, options *types.TraversalOptions) ([]types.TopologicalEntry, error) {
+	if options == nil {
+		options = &types.TraversalOptions{}
+	}
+
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+	// Sorted so that indexes, and levels sorted by index, follow node IDs
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	index := make(map[models.NodeID]int, len(nodes))
+	for i, node := range nodes {
+		index[node.ID] = i
+	}
+
+	adjacency := make([][]int, len(nodes))
+	inDegree := make([]int, len(nodes))
+	for _, edge := range liveEdges(edges) {
+		if !matchesEdgeTypes(edge, options.EdgeTypes) {
+			continue
+		}
+		from, okFrom := index[edge.FromNodeID]
+		to, okTo := index[edge.ToNodeID]
+		if !okFrom || !okTo {
+			continue
+		}
+		adjacency[from] = append(adjacency[from], to)
+		inDegree[to]++
+	}
+
+	var level []int
+	for i := range nodes {
+		if inDegree[i] == 0 {
+			level = append(level, i)
+		}
+	}
+	order := make([]types.TopologicalEntry, 0, len(nodes))
+	for number := 0; len(level) > 0; number++ {
+		if err := ga.context().Err(); err != nil {
+			return nil, err
+		}
+		var next []int
+		for _, n := range level {
+			order = append(order, types.TopologicalEntry{Node: nodes[n], Level: number})
+			for _, to := range adjacency[n] {
+				inDegree[to]--
+				if inDegree[to] == 0 {
+					next = append(next, to)
+				}
+			}
+		}
+		sort.Ints(next)
+		level = next
+	}
+	if len(order) == len(nodes) {
+		return order, nil
+	}
+
+	// The nodes left are on cycles or depend on one. Report only those on
+	// a cycle: the members of strongly connected components of more than
+	// one node, and nodes with an edge to themselves.
+	var left []int
+	for i := range nodes {
+		if inDegree[i] > 0 {
+			left = append(left, i)
+		}
+	}
+	_, components := stronglyConnectedComponents(adjacency, left)
+	var cyclic []string
+	for _, members := range components {
+		for _, n := range members {
+			if len(members) > 1 || selfLoop(adjacency, n) {
+				cyclic = append(cyclic, string(nodes[n].ID))
+			}
+		}
+	}
+	sort.Strings(cyclic)
+	return nil, fmt.Errorf("%w: nodes %s are on cycles", ErrCycleDetected, strings.Join(cyclic, ", "))
+}
+
+// selfLoop reports whether node n has an edge to itself
+func selfLoop(adjacency [][]int, n int) bool {
+	for _, to := range adjacency[n] {
+		if to == n {
+			return true
+		}
+	}
+	return false
+}
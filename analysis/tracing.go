@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"context"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WithContext returns an analyzer whose traced operations, the traversals,
+// shortest paths and cycle searches, start their spans as children of the
+// span in ctx. Walks started without their own context use ctx too.
+func (ga *GraphAnalyzer) WithContext(ctx context.Context) *GraphAnalyzer {
+	copied := *ga
+	copied.ctx = ctx
+	return &copied
+}
+
+// context returns the context set by WithContext, or the background context
+func (ga *GraphAnalyzer) context() context.Context {
+	if ga.ctx == nil {
+		return context.Background()
+	}
+	return ga.ctx
+}
+
+// countingStorage counts the nodes whose edges an analysis lists and the
+// distinct edges those lists return, for the attributes of its span
+type countingStorage struct {
+	GraphReader
+	nodes map[models.NodeID]struct{}
+	edges map[models.EdgeID]struct{}
+}
+
+// GetOutgoingEdges counts the node and the edges returned
+func (c *countingStorage) GetOutgoingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	edges, err := c.GraphReader.GetOutgoingEdges(graphID, nodeID)
+	c.count(nodeID, edges)
+	return edges, err
+}
+
+// GetIncomingEdges counts the node and the edges returned
+func (c *countingStorage) GetIncomingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	edges, err := c.GraphReader.GetIncomingEdges(graphID, nodeID)
+	c.count(nodeID, edges)
+	return edges, err
+}
+
+func (c *countingStorage) count(nodeID models.NodeID, edges []*models.Edge) {
+	c.nodes[nodeID] = struct{}{}
+	for _, edge := range edges {
+		c.edges[edge.ID] = struct{}{}
+	}
+}
+
+// traced starts a span for an operation on graphID and returns the analyzer
+// to run it with and the function that ends the span. The span counts the
+// nodes and edges the operation visits. Outside a traced command the
+// analyzer is ga itself and ending does nothing.
+func (ga *GraphAnalyzer) traced(name string, graphID models.GraphID) (*GraphAnalyzer, func(err error, attrs ...attribute.KeyValue)) {
+	ctx, span := tracing.Start(ga.context(), name, attribute.String("graph", string(graphID)))
+	if !span.IsRecording() {
+		return ga, func(error, ...attribute.KeyValue) {}
+	}
+	counter := &countingStorage{
+		GraphReader: ga.storage,
+		nodes:       make(map[models.NodeID]struct{}),
+		edges:       make(map[models.EdgeID]struct{}),
+	}
+	traced := &GraphAnalyzer{storage: counter, ctx: ctx}
+	return traced, func(err error, attrs ...attribute.KeyValue) {
+		span.SetAttributes(
+			attribute.Int("nodes_visited", len(counter.nodes)),
+			attribute.Int("edges_visited", len(counter.edges)),
+		)
+		span.SetAttributes(attrs...)
+		tracing.End(span, err)
+	}
+}
@@ -0,0 +1,38 @@
+package analysis
+
+import (
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// transitionState returns the edge type that decides which edges may follow
+// via under options.EdgeTypeTransitions: via's type, or TransitionStart at
+// the start node. Without a grammar every node has the same state, so
+// traversals keyed by node and state behave as if keyed by node alone.
+func transitionState(options *types.TraversalOptions, via *models.Edge) models.EdgeType {
+	if options.EdgeTypeTransitions == nil || via == nil {
+		return types.TransitionStart
+	}
+	return via.Type
+}
+
+// filterTransitions returns the edges whose type may follow an edge of type
+// previous under options.EdgeTypeTransitions. Edges are returned unchanged
+// when no grammar is set.
+func filterTransitions(options *types.TraversalOptions, previous models.EdgeType, edges []*models.Edge) []*models.Edge {
+	if options.EdgeTypeTransitions == nil {
+		return edges
+	}
+	allowed := options.EdgeTypeTransitions[previous]
+	if len(allowed) == 0 {
+		// Unlike EdgeTypes, an empty list allows nothing
+		return nil
+	}
+	var filtered []*models.Edge
+	for _, edge := range edges {
+		if matchesEdgeTypes(edge, allowed) {
+			filtered = append(filtered, edge)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,157 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// BuildDependencyTree returns the dependencies of rootNodeID as a tree, the
+// root at depth 0 and the children of each node sorted by node ID. A node
+// is listed under every node depending on it but expanded only once, at the
+// first place it is reached at its shortest depth; elsewhere it is marked
+// Truncated, with no children. A node depending on one of its ancestors
+// lists it marked both Cycle and Truncated, and a node at MaxDepth with
+// dependencies is marked Truncated too.
+//
+// options.EdgeTypes filters the edges followed, and DirectionBackward builds
+// the tree of dependents instead. Nil options follow all edges forward with
+// no depth limit.
+func (ga *GraphAnalyzer) BuildDependencyTree(graphID models.GraphID, rootNodeID models.NodeID, options *types.TraversalOptions) (tree *types.DependencyTree, err error) {
+	traced, end := ga.traced("analysis.tree", graphID)
+	nodes := 0
+	defer func() { end(err, attribute.String("start", string(rootNodeID)), attribute.Int("nodes", nodes)) }()
+	tree, nodes, err = traced.buildDependencyTree(graphID, rootNodeID, options)
+	return tree, err
+}
+
+// dependencyTreeBuilder holds the state of one BuildDependencyTree
+type dependencyTreeBuilder struct {
+	ga       *GraphAnalyzer
+	nodes    []*models.Node
+	children [][]int
+	maxDepth int
+	// shortest is each node's depth on the shortest chain from the root,
+	// -1 for nodes not reached
+	shortest []int
+	expanded []bool
+	onBranch []bool
+	size     int
+}
+
+func (ga *GraphAnalyzer) buildDependencyTree(graphID models.GraphID, rootNodeID models.NodeID, options *types.TraversalOptions) (*types.DependencyTree, int, error) {
+	if options == nil {
+		options = &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: -1}
+	}
+
+	nodes, err := ga.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := ga.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list edges: %w", err)
+	}
+	// Sorted so that children sorted by index are sorted by node ID
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	index := make(map[models.NodeID]int, len(nodes))
+	for i, node := range nodes {
+		index[node.ID] = i
+	}
+	root, ok := index[rootNodeID]
+	if !ok {
+		return nil, 0, fmt.Errorf("failed to get node %s: %w", rootNodeID, storage.ErrNodeNotFound)
+	}
+
+	b := &dependencyTreeBuilder{
+		ga:       ga,
+		nodes:    nodes,
+		children: make([][]int, len(nodes)),
+		maxDepth: options.MaxDepth,
+		shortest: make([]int, len(nodes)),
+		expanded: make([]bool, len(nodes)),
+		onBranch: make([]bool, len(nodes)),
+	}
+	for _, edge := range liveEdges(edges) {
+		if !matchesEdgeTypes(edge, options.EdgeTypes) {
+			continue
+		}
+		from, to := edge.FromNodeID, edge.ToNodeID
+		if options.Direction == types.DirectionBackward {
+			from, to = to, from
+		}
+		parent, okFrom := index[from]
+		child, okTo := index[to]
+		if !okFrom || !okTo {
+			continue
+		}
+		b.children[parent] = append(b.children[parent], child)
+	}
+	// Parallel edges list a dependency once
+	for i, children := range b.children {
+		sort.Ints(children)
+		unique := children[:0]
+		for j, child := range children {
+			if j == 0 || child != children[j-1] {
+				unique = append(unique, child)
+			}
+		}
+		b.children[i] = unique
+	}
+
+	for i := range b.shortest {
+		b.shortest[i] = -1
+	}
+	b.shortest[root] = 0
+	queue := []int{root}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range b.children[current] {
+			if b.shortest[child] < 0 {
+				b.shortest[child] = b.shortest[current] + 1
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	tree, err := b.build(root, 0)
+	return tree, b.size, err
+}
+
+// build returns the subtree of node i reached at depth
+func (b *dependencyTreeBuilder) build(i, depth int) (*types.DependencyTree, error) {
+	if err := b.ga.context().Err(); err != nil {
+		return nil, err
+	}
+	b.size++
+	tree := &types.DependencyTree{NodeID: b.nodes[i].ID, Node: b.nodes[i], Depth: depth}
+	switch {
+	case b.onBranch[i]:
+		tree.Cycle = true
+		tree.Truncated = true
+		return tree, nil
+	case len(b.children[i]) == 0:
+		return tree, nil
+	case b.expanded[i] || depth != b.shortest[i] || (b.maxDepth >= 0 && depth >= b.maxDepth):
+		tree.Truncated = true
+		return tree, nil
+	}
+
+	b.expanded[i] = true
+	b.onBranch[i] = true
+	tree.Children = make([]*types.DependencyTree, 0, len(b.children[i]))
+	for _, child := range b.children[i] {
+		subtree, err := b.build(child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		tree.Children = append(tree.Children, subtree)
+	}
+	b.onBranch[i] = false
+	return tree, nil
+}
@@ -0,0 +1,312 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// SkipSubtree, returned by a WalkFunc, skips the edges of the node it was
+// given. Nodes reachable another way are still visited. The walk itself
+// continues and does not return SkipSubtree.
+var SkipSubtree = errors.New("skip subtree")
+
+// WalkFunc is called once for each node a walk reaches, with its depth from
+// the start node and the edge it was reached by, which is nil for the start
+// node. Returning SkipSubtree prunes the node's edges; any other error stops
+// the walk and is returned by it.
+//
+// The walk holds no transaction while WalkFunc runs, so it may call the
+// engine, including to write. Writes are seen by the rest of the walk: edges
+// added to the node being visited are followed, and nodes not yet reached
+// are read as they are when reached. Concurrent writers are seen the same
+// way, as a walk is not a snapshot.
+type WalkFunc func(node *models.Node, depth int, via *models.Edge) error
+
+// WalkDFS visits the nodes reachable from start depth-first, one at a time,
+// without collecting them. Nodes are visited in preorder, and the edges of
+// each node are followed in the order the storage returns them: by edge ID,
+// with outgoing edges before incoming ones for DirectionBoth.
+//
+// options apply as in DepthFirstSearch: EdgeTypes and MaxFanout limit the
+// edges followed, MaxDepth the depth, and a node matching StopCondition is
+// neither visited nor expanded. Nodes that fail NodeTypes or UpdatedBefore
+// are expanded but not visited. Nil options walk forward without limits.
+//
+// With EdgeTypeTransitions, only edges the grammar allows after the edge a
+// node was reached by are followed. A node reached by edges of several types
+// is expanded once for each of them but still visited only once.
+//
+// With PassThroughNodeTypes, nodes of those types other than start are
+// crossed without being visited or counted toward depth, and a node reached
+// across them is given the last edge of the hop.
+func (ga *GraphAnalyzer) WalkDFS(ctx context.Context, graphID models.GraphID, start models.NodeID, options *types.TraversalOptions, visit WalkFunc) error {
+	_, err := ga.walk(ctx, graphID, start, options, false, func(node *models.Node, depth int, via *models.Edge, _ *hop) error {
+		return visit(node, depth, via)
+	})
+	return err
+}
+
+// WalkBFS visits the nodes reachable from start breadth-first, like WalkDFS.
+// Nodes are visited in order of depth, each at its shortest depth, and
+// nodes at the same depth in the order their edges were followed.
+func (ga *GraphAnalyzer) WalkBFS(ctx context.Context, graphID models.GraphID, start models.NodeID, options *types.TraversalOptions, visit WalkFunc) error {
+	_, err := ga.walk(ctx, graphID, start, options, true, func(node *models.Node, depth int, via *models.Edge, _ *hop) error {
+		return visit(node, depth, via)
+	})
+	return err
+}
+
+// walkItem is a node waiting to be visited
+type walkItem struct {
+	nodeID models.NodeID
+	depth  int
+	via    *models.Edge
+	// reached is the hop the node was reached by, only set with
+	// PassThroughNodeTypes; via is then its last edge
+	reached *hop
+}
+
+// walkVisit is the WalkFunc of walk, also given the hop a node was reached
+// by with PassThroughNodeTypes, and nil otherwise
+type walkVisit func(node *models.Node, depth int, via *models.Edge, reached *hop) error
+
+// walkKey identifies a node together with the transition state it was
+// reached in, see transitionState
+type walkKey struct {
+	nodeID models.NodeID
+	state  models.EdgeType
+}
+
+// walkSeen is the set of nodes a walk has queued or expanded. Under a path
+// grammar nodes are tracked per transition state, and visits separately so
+// each node is still visited once. Without one, nodes alone are tracked,
+// keeping the set as small as possible for large walks.
+type walkSeen struct {
+	options *types.TraversalOptions
+	nodes   map[models.NodeID]struct{}
+	states  map[walkKey]struct{}
+	// visited maps the nodes given to visit under a grammar to whether
+	// visit skipped their subtree
+	visited map[models.NodeID]bool
+}
+
+func newWalkSeen(options *types.TraversalOptions) *walkSeen {
+	if options.EdgeTypeTransitions == nil {
+		return &walkSeen{options: options, nodes: make(map[models.NodeID]struct{})}
+	}
+	return &walkSeen{options: options, states: make(map[walkKey]struct{}), visited: make(map[models.NodeID]bool)}
+}
+
+// has reports whether nodeID, reached by via, was already marked
+func (s *walkSeen) has(nodeID models.NodeID, via *models.Edge) bool {
+	if s.states == nil {
+		_, seen := s.nodes[nodeID]
+		return seen
+	}
+	_, seen := s.states[walkKey{nodeID: nodeID, state: transitionState(s.options, via)}]
+	return seen
+}
+
+// add marks nodeID, reached by via
+func (s *walkSeen) add(nodeID models.NodeID, via *models.Edge) {
+	if s.states == nil {
+		s.nodes[nodeID] = struct{}{}
+		return
+	}
+	s.states[walkKey{nodeID: nodeID, state: transitionState(s.options, via)}] = struct{}{}
+}
+
+// walk implements WalkDFS and WalkBFS. Only the visited set and the pending
+// frontier are kept, never the nodes themselves. It returns the fanout
+// limiter so DepthFirstSearch can report truncated nodes.
+func (ga *GraphAnalyzer) walk(ctx context.Context, graphID models.GraphID, start models.NodeID, options *types.TraversalOptions,
+	breadthFirst bool, visit walkVisit) (*fanoutLimiter, error) {
+	if options == nil {
+		options = &types.TraversalOptions{
+			MaxDepth:  -1, // No limit
+			Direction: types.DirectionForward,
+		}
+	}
+
+	fanout := newFanoutLimiter(options)
+	seen := newWalkSeen(options)
+	frontier := []walkItem{{nodeID: start}}
+	if breadthFirst {
+		// Breadth-first walks mark nodes when queued, so each is queued
+		// once, at its shortest depth
+		seen.add(start, nil)
+	}
+
+	for len(frontier) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var current walkItem
+		if breadthFirst {
+			current, frontier = frontier[0], frontier[1:]
+		} else {
+			current, frontier = frontier[len(frontier)-1], frontier[:len(frontier)-1]
+			// Depth-first walks mark nodes when popped, as a node may be
+			// pushed again before it is reached
+			if seen.has(current.nodeID, current.via) {
+				continue
+			}
+			if options.MaxDepth >= 0 && current.depth > options.MaxDepth {
+				continue
+			}
+			seen.add(current.nodeID, current.via)
+		}
+
+		node, err := ga.storage.GetNode(graphID, current.nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", current.nodeID, err)
+		}
+		if options.StopCondition != nil && options.StopCondition(node) {
+			continue
+		}
+
+		visitNode := true
+		if seen.visited != nil {
+			// A node reached again in another transition state is expanded
+			// again, unless its subtree was skipped, but not visited again
+			if skipped, ok := seen.visited[current.nodeID]; ok {
+				if skipped {
+					continue
+				}
+				visitNode = false
+			} else {
+				seen.visited[current.nodeID] = false
+			}
+		}
+		if visitNode && matchesNodeTypes(node, options.NodeTypes) && (options.UpdatedBefore == nil || node.UpdatedBefore(*options.UpdatedBefore)) {
+			if err := visit(node, current.depth, current.via, current.reached); err == SkipSubtree {
+				if seen.visited != nil {
+					seen.visited[current.nodeID] = true
+				}
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if breadthFirst && options.MaxDepth >= 0 && current.depth >= options.MaxDepth {
+			continue
+		}
+
+		if len(options.PassThroughNodeTypes) > 0 {
+			hops, err := ga.hopsFrom(graphID, current.nodeID, options, transitionState(options, current.via), "", fanout)
+			if err != nil {
+				return nil, err
+			}
+			frontier = pushHops(frontier, seen, hops, current.depth+1, breadthFirst)
+			continue
+		}
+
+		edges, err := ga.walkEdges(graphID, current.nodeID, options, transitionState(options, current.via), fanout)
+		if err != nil {
+			return nil, err
+		}
+
+		if breadthFirst {
+			for _, edge := range edges {
+				next := otherEnd(edge, current.nodeID, options.Direction)
+				if next == "" || seen.has(next, edge) {
+					continue
+				}
+				seen.add(next, edge)
+				frontier = append(frontier, walkItem{nodeID: next, depth: current.depth + 1, via: edge})
+			}
+			continue
+		}
+
+		// Push in reverse so the first edge is followed first
+		for i := len(edges) - 1; i >= 0; i-- {
+			next := otherEnd(edges[i], current.nodeID, options.Direction)
+			if next == "" || seen.has(next, edges[i]) {
+				continue
+			}
+			frontier = append(frontier, walkItem{nodeID: next, depth: current.depth + 1, via: edges[i]})
+		}
+	}
+
+	return fanout, nil
+}
+
+// pushHops adds the nodes hops lead to to a walk's frontier, as walk does
+// for edges
+func pushHops(frontier []walkItem, seen *walkSeen, hops []hop, depth int, breadthFirst bool) []walkItem {
+	if breadthFirst {
+		for i, h := range hops {
+			if seen.has(h.to, h.last()) {
+				continue
+			}
+			seen.add(h.to, h.last())
+			frontier = append(frontier, walkItem{nodeID: h.to, depth: depth, via: h.last(), reached: &hops[i]})
+		}
+		return frontier
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		if seen.has(hops[i].to, hops[i].last()) {
+			continue
+		}
+		frontier = append(frontier, walkItem{nodeID: hops[i].to, depth: depth, via: hops[i].last(), reached: &hops[i]})
+	}
+	return frontier
+}
+
+// walkEdges returns the edges of nodeID a walk follows, filtered by
+// EdgeTypes and by the transitions allowed after previous, and limited by
+// MaxFanout
+func (ga *GraphAnalyzer) walkEdges(graphID models.GraphID, nodeID models.NodeID, options *types.TraversalOptions, previous models.EdgeType, fanout *fanoutLimiter) ([]*models.Edge, error) {
+	var edges []*models.Edge
+	var err error
+	switch options.Direction {
+	case types.DirectionForward:
+		edges, err = ga.storage.GetOutgoingEdges(graphID, nodeID)
+	case types.DirectionBackward:
+		edges, err = ga.storage.GetIncomingEdges(graphID, nodeID)
+	case types.DirectionBoth:
+		outgoing, err1 := ga.storage.GetOutgoingEdges(graphID, nodeID)
+		if err1 != nil {
+			return nil, fmt.Errorf("failed to get outgoing edges: %w", err1)
+		}
+		incoming, err2 := ga.storage.GetIncomingEdges(graphID, nodeID)
+		if err2 != nil {
+			return nil, fmt.Errorf("failed to get incoming edges: %w", err2)
+		}
+		edges = append(outgoing, incoming...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connected edges: %w", err)
+	}
+	edges = liveEdges(edges)
+
+	if len(options.EdgeTypes) > 0 {
+		filtered := edges[:0]
+		for _, edge := range edges {
+			if matchesEdgeTypes(edge, options.EdgeTypes) {
+				filtered = append(filtered, edge)
+			}
+		}
+		edges = filtered
+	}
+	edges = filterTransitions(options, previous, edges)
+	return fanout.limit(nodeID, edges), nil
+}
+
+// otherEnd returns the node an edge of nodeID leads to in direction, or ""
+// if the edge does not lead away from nodeID in that direction
+func otherEnd(edge *models.Edge, nodeID models.NodeID, direction types.TraversalDirection) models.NodeID {
+	switch {
+	case direction != types.DirectionBackward && edge.FromNodeID == nodeID:
+		return edge.ToNodeID
+	case direction != types.DirectionForward && edge.ToNodeID == nodeID:
+		return edge.FromNodeID
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package analysis
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// weightedItem is a node Dijkstra's algorithm has reached and the weight
+// of the lightest path to it found so far
+type weightedItem struct {
+	nodeID models.NodeID
+	dist   float64
+}
+
+// weightedQueue is a min-heap of reached nodes, lightest first and then by
+// ID, so equal paths are chosen the same way every time
+type weightedQueue []weightedItem
+
+func (q weightedQueue) Len() int { return len(q) }
+func (q weightedQueue) Less(i, j int) bool {
+	if q[i].dist != q[j].dist {
+		return q[i].dist < q[j].dist
+	}
+	return q[i].nodeID < q[j].nodeID
+}
+func (q weightedQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *weightedQueue) Push(x interface{}) { *q = append(*q, x.(weightedItem)) }
+func (q *weightedQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// edgeWeight returns the weight weight gives edge
+func edgeWeight(edge *models.Edge, weight types.EdgeWeight) (float64, error) {
+	var value float64
+	numeric := true
+	switch v := edge.Attributes[weight.Attribute].(type) {
+	case float64:
+		value = v
+	case float32:
+		value = float64(v)
+	case int:
+		value = float64(v)
+	case int64:
+		value = float64(v)
+	case json.Number:
+		f, err := v.Float64()
+		value, numeric = f, err == nil
+	default:
+		numeric = false
+	}
+	if !numeric {
+		if weight.Default == nil {
+			return 0, fmt.Errorf("%w: edge %s has no numeric %s attribute", ErrInvalidWeight, edge.ID, weight.Attribute)
+		}
+		value = *weight.Default
+	}
+	if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%w: edge %s weighs %v, weights must be finite and not negative", ErrInvalidWeight, edge.ID, value)
+	}
+	return value, nil
+}
+
+// GetWeightedShortestPath finds the path from fromNodeID to toNodeID whose
+// edges have the least total weight, using Dijkstra's algorithm. Edges are
+// weighed by an attribute as weight says. Only the Direction and EdgeTypes
+// of options apply. The result's TotalWeight is the path's weight, and
+// Length its number of edges.
+func (ga *GraphAnalyzer) GetWeightedShortestPath(graphID models.GraphID, fromNodeID, toNodeID models.NodeID, weight types.EdgeWeight, options *types.TraversalOptions) (result *types.PathResult, err error) {
+	traced, end := ga.traced("analysis.weighted_shortest_path", graphID)
+	defer func() {
+		length := -1
+		if result != nil {
+			length = result.Length
+		}
+		end(err, attribute.String("from", string(fromNodeID)), attribute.String("to", string(toNodeID)),
+			attribute.String("weight", weight.Attribute), attribute.Int("length", length))
+	}()
+	return traced.getWeightedShortestPath(graphID, fromNodeID, toNodeID, weight, options)
+}
+
+func (ga *GraphAnalyzer) getWeightedShortestPath(graphID models.GraphID, fromNodeID, toNodeID models.NodeID, weight types.EdgeWeight, options *types.TraversalOptions) (*types.PathResult, error) {
+	if options == nil {
+		options = &types.TraversalOptions{Direction: types.DirectionForward}
+	}
+	for _, nodeID := range []models.NodeID{fromNodeID, toNodeID} {
+		if _, err := ga.storage.GetNode(graphID, nodeID); err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
+		}
+	}
+
+	dist := map[models.NodeID]float64{fromNodeID: 0}
+	// The lightest path found to each node ends with an edge from a node
+	prev := make(map[models.NodeID]models.NodeID)
+	viaEdge := make(map[models.NodeID]models.EdgeID)
+	settled := make(map[models.NodeID]bool)
+	queue := &weightedQueue{{nodeID: fromNodeID}}
+	for queue.Len() > 0 {
+		if err := ga.context().Err(); err != nil {
+			return nil, err
+		}
+		current := heap.Pop(queue).(weightedItem)
+		if settled[current.nodeID] {
+			continue
+		}
+		settled[current.nodeID] = true
+		if current.nodeID == toNodeID {
+			break
+		}
+
+		steps, err := ga.steps(graphID, current.nodeID, options.Direction, options.EdgeTypes)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range steps {
+			if settled[s.next] {
+				continue
+			}
+			w, err := edgeWeight(s.edge, weight)
+			if err != nil {
+				return nil, err
+			}
+			if d, reached := dist[s.next]; reached && d <= current.dist+w {
+				continue
+			}
+			dist[s.next] = current.dist + w
+			prev[s.next], viaEdge[s.next] = current.nodeID, s.edge.ID
+			heap.Push(queue, weightedItem{nodeID: s.next, dist: current.dist + w})
+		}
+	}
+	if !settled[toNodeID] {
+		return nil, fmt.Errorf("%w from %s to %s", ErrNoPath, fromNodeID, toNodeID)
+	}
+
+	// Walk back from the target
+	path := []models.NodeID{toNodeID}
+	var edges []models.EdgeID
+	for nodeID := toNodeID; nodeID != fromNodeID; nodeID = prev[nodeID] {
+		edges = append(edges, viaEdge[nodeID])
+		path = append(path, prev[nodeID])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+		edges[i], edges[j] = edges[j], edges[i]
+	}
+
+	return &types.PathResult{
+		FromNodeID:  fromNodeID,
+		ToNodeID:    toNodeID,
+		Path:        path,
+		Length:      len(path) - 1,
+		Edges:       edges,
+		TotalWeight: dist[toNodeID],
+	}, nil
+}
@@ -0,0 +1,252 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// errTargetReached ends a walk once the node it looks for is visited
+var errTargetReached = errors.New("target reached")
+
+// overlayStorage applies an overlay to the reads the analyzer makes of one
+// graph. Every other call goes to the underlying reader unchanged.
+type overlayStorage struct {
+	GraphReader
+	graphID models.GraphID
+	overlay *types.Overlay
+}
+
+// GetNode hides removed nodes
+func (o *overlayStorage) GetNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, error) {
+	if graphID == o.graphID && o.overlay.RemovedNodes[nodeID] {
+		return nil, fmt.Errorf("%w: %s", storage.ErrNodeNotFound, nodeID)
+	}
+	return o.GraphReader.GetNode(graphID, nodeID)
+}
+
+// GetOutgoingEdges returns the stored outgoing edges the overlay keeps,
+// followed by the added ones
+func (o *overlayStorage) GetOutgoingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	edges, err := o.GraphReader.GetOutgoingEdges(graphID, nodeID)
+	if err != nil || graphID != o.graphID {
+		return edges, err
+	}
+	return o.apply(edges, func(edge *models.Edge) bool { return edge.FromNodeID == nodeID }), nil
+}
+
+// GetIncomingEdges returns the stored incoming edges the overlay keeps,
+// followed by the added ones
+func (o *overlayStorage) GetIncomingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	edges, err := o.GraphReader.GetIncomingEdges(graphID, nodeID)
+	if err != nil || graphID != o.graphID {
+		return edges, err
+	}
+	return o.apply(edges, func(edge *models.Edge) bool { return edge.ToNodeID == nodeID }), nil
+}
+
+// ListEdges returns the stored edges the overlay keeps, followed by the
+// added ones
+func (o *overlayStorage) ListEdges(graphID models.GraphID) ([]*models.Edge, error) {
+	edges, err := o.GraphReader.ListEdges(graphID)
+	if err != nil || graphID != o.graphID {
+		return edges, err
+	}
+	return o.apply(edges, func(*models.Edge) bool { return true }), nil
+}
+
+// apply drops the removed edges and the edges of removed nodes, then
+// appends the added edges matching incident
+func (o *overlayStorage) apply(edges []*models.Edge, incident func(edge *models.Edge) bool) []*models.Edge {
+	var kept []*models.Edge
+	for _, edge := range edges {
+		if !o.removed(edge) {
+			kept = append(kept, edge)
+		}
+	}
+	for _, edge := range o.overlay.AddedEdges {
+		if incident(edge) && !o.removed(edge) {
+			kept = append(kept, edge)
+		}
+	}
+	return kept
+}
+
+// removed reports whether the overlay removes an edge or one of its nodes
+func (o *overlayStorage) removed(edge *models.Edge) bool {
+	return o.overlay.RemovedEdges[edge.ID] || o.overlay.RemovedNodes[edge.FromNodeID] || o.overlay.RemovedNodes[edge.ToNodeID]
+}
+
+// withOverlay returns an analyzer that sees graphID with overlay applied.
+// Added edges must join existing nodes.
+func (ga *GraphAnalyzer) withOverlay(graphID models.GraphID, overlay *types.Overlay) (*GraphAnalyzer, error) {
+	if overlay == nil {
+		overlay = &types.Overlay{}
+	}
+	for _, edge := range overlay.AddedEdges {
+		for _, nodeID := range []models.NodeID{edge.FromNodeID, edge.ToNodeID} {
+			if _, err := ga.storage.GetNode(graphID, nodeID); err != nil {
+				return nil, fmt.Errorf("added edge %s: %w", edge.ID, err)
+			}
+		}
+	}
+	return &GraphAnalyzer{storage: &overlayStorage{GraphReader: ga.storage, graphID: graphID, overlay: overlay}, ctx: ga.ctx}, nil
+}
+
+// WhatIfReachable reports whether to can be reached from from by following
+// edges forward once overlay is applied. It is false if either node is
+// removed.
+func (ga *GraphAnalyzer) WhatIfReachable(graphID models.GraphID, from, to models.NodeID, overlay *types.Overlay) (bool, error) {
+	overlaid, err := ga.withOverlay(graphID, overlay)
+	if err != nil {
+		return false, err
+	}
+	if _, err := ga.storage.GetNode(graphID, from); err != nil {
+		return false, err
+	}
+	if _, err := ga.storage.GetNode(graphID, to); err != nil {
+		return false, err
+	}
+	return overlaid.reachable(graphID, from, to)
+}
+
+// reachable reports whether a forward walk from from visits to
+func (ga *GraphAnalyzer) reachable(graphID models.GraphID, from, to models.NodeID) (bool, error) {
+	if _, err := ga.storage.GetNode(graphID, from); err != nil {
+		// Removed by an overlay; the caller checked the node exists
+		return false, nil
+	}
+	err := ga.WalkBFS(context.Background(), graphID, from, nil, func(node *models.Node, depth int, via *models.Edge) error {
+		if node.ID == to {
+			return errTargetReached
+		}
+		return nil
+	})
+	if err == errTargetReached {
+		return true, nil
+	}
+	return false, err
+}
+
+// WhatIfShortestPath finds the shortest forward path between two nodes once
+// overlay is applied
+func (ga *GraphAnalyzer) WhatIfShortestPath(graphID models.GraphID, from, to models.NodeID, overlay *types.Overlay) (*types.PathResult, error) {
+	overlaid, err := ga.withOverlay(graphID, overlay)
+	if err != nil {
+		return nil, err
+	}
+	if overlay != nil && (overlay.RemovedNodes[from] || overlay.RemovedNodes[to]) {
+		return nil, fmt.Errorf("%w from %s to %s", ErrNoPath, from, to)
+	}
+	return overlaid.GetShortestPath(graphID, from, to, nil)
+}
+
+// WhatIfStats compares which targets each source reaches with and without
+// overlay. Nodes the overlay removes are left out of both sets, and a node
+// is not paired with itself.
+func (ga *GraphAnalyzer) WhatIfStats(graphID models.GraphID, sources, targets []models.NodeID, overlay *types.Overlay) (*types.WhatIfSummary, error) {
+	overlaid, err := ga.withOverlay(graphID, overlay)
+	if err != nil {
+		return nil, err
+	}
+	removed := func(nodeID models.NodeID) bool {
+		return overlay != nil && overlay.RemovedNodes[nodeID]
+	}
+
+	summary := &types.WhatIfSummary{Lost: []types.NodePair{}, Gained: []types.NodePair{}}
+	for _, source := range sources {
+		if removed(source) {
+			continue
+		}
+		before, err := ga.reachableSet(graphID, source)
+		if err != nil {
+			return nil, err
+		}
+		after, err := overlaid.reachableSet(graphID, source)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, target := range targets {
+			if target == source || removed(target) {
+				continue
+			}
+			summary.Pairs++
+			pair := types.NodePair{From: source, To: target}
+			if before[target] {
+				summary.ReachableBefore++
+			}
+			if after[target] {
+				summary.ReachableAfter++
+			}
+			if before[target] && !after[target] {
+				summary.Lost = append(summary.Lost, pair)
+			} else if after[target] && !before[target] {
+				summary.Gained = append(summary.Gained, pair)
+			}
+		}
+	}
+	return summary, nil
+}
+
+// WhatIfCriticalEdges returns, sorted, the edges that are single points of
+// failure for the source and target pairs once overlay is applied: those on
+// every shortest path of a pair that stays reachable, so that removing one
+// more of them lengthens the pair's distance or disconnects it. Pairs are
+// formed as in WhatIfStats, and each costs one ShortestPathDAG.
+func (ga *GraphAnalyzer) WhatIfCriticalEdges(graphID models.GraphID, sources, targets []models.NodeID, overlay *types.Overlay) ([]models.EdgeID, error) {
+	overlaid, err := ga.withOverlay(graphID, overlay)
+	if err != nil {
+		return nil, err
+	}
+	removed := func(nodeID models.NodeID) bool {
+		return overlay != nil && overlay.RemovedNodes[nodeID]
+	}
+
+	critical := make(map[models.EdgeID]bool)
+	for _, source := range sources {
+		if removed(source) {
+			continue
+		}
+		for _, target := range targets {
+			if target == source || removed(target) {
+				continue
+			}
+			dag, err := overlaid.shortestPathDAG(graphID, source, target, nil)
+			if errors.Is(err, ErrNoPath) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, edgeID := range dag.Critical {
+				critical[edgeID] = true
+			}
+		}
+	}
+
+	edgeIDs := make([]models.EdgeID, 0, len(critical))
+	for edgeID := range critical {
+		edgeIDs = append(edgeIDs, edgeID)
+	}
+	sort.Slice(edgeIDs, func(i, j int) bool { return edgeIDs[i] < edgeIDs[j] })
+	return edgeIDs, nil
+}
+
+// reachableSet returns the nodes a forward walk from source visits
+func (ga *GraphAnalyzer) reachableSet(graphID models.GraphID, source models.NodeID) (map[models.NodeID]bool, error) {
+	reached := make(map[models.NodeID]bool)
+	err := ga.WalkBFS(context.Background(), graphID, source, nil, func(node *models.Node, depth int, via *models.Edge) error {
+		reached[node.ID] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reached, nil
+}
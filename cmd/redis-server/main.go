@@ -2,12 +2,21 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/ywadi/PathwayDB/admin"
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/logging"
+	"github.com/ywadi/PathwayDB/models"
 	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
 	"github.com/ywadi/PathwayDB/storage"
 )
 
@@ -20,19 +29,85 @@ func getEnv(key, fallback string) string {
 }
 
 func main() {
+	// Serve is the default; any other subcommand works on the data
+	// directory offline
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	} else if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		os.Exit(admin.Run(args, os.Stdout, os.Stderr))
+	}
+	serve(args)
+}
+
+// serve runs the Redis protocol server until it is signalled to stop
+func serve(args []string) {
 	// Configuration with environment variable override
 	redisAddr := getEnv("REDIS_ADDR", ":6379")
+	logLevel := getEnv("LOG_LEVEL", "info")
+	adminPassword := getEnv("ADMIN_PASSWORD", "")
 
 	// Command line flags
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Usage = func() {
+		admin.Usage(flags.Output())
+		fmt.Fprintln(flags.Output(), "\nServe flags:")
+		flags.PrintDefaults()
+	}
+	defaults := commands.DefaultAnalysisLimits()
 	var (
-		addr     = flag.String("addr", redisAddr, "Redis server address")
-		dataDir  = flag.String("data", "./data", "Data directory for storage")
-		debug    = flag.Bool("debug", false, "Enable debug logging")
+		addr     = flags.String("addr", redisAddr, "Redis server address")
+		dataDir  = flags.String("data", "./data", "Data directory for storage")
+		debug    = flags.Bool("debug", false, "Enable debug logging")
+		level    = flags.String("log-level", logLevel, "Log level (debug, info, warn, error)")
+		password = flags.String("admin-password", adminPassword, "Password for AUTH to grant the admin role (disabled if empty)")
+		workers  = flags.Int("job-workers", 2, "Number of background analysis jobs run at the same time")
+		maxJobs  = flags.Int("max-jobs", 16, "Maximum queued plus running analysis jobs")
+		maxSnaps = flags.Int("max-snapshots", storage.DefaultMaxSnapshots, "Snapshots kept per graph before the oldest are pruned (0 keeps all)")
+		metaSize = flags.Int("meta-quota", storage.DefaultMetaQuota, "Bytes each META namespace of a graph may hold (0 for no limit)")
+		maxCmds  = flags.Int("max-concurrent-commands", 64, "Commands executed at the same time across all connections (0 runs each on its connection)")
+		cmdQueue = flags.Int("command-queue", 256, "Commands that may wait for a worker before clients get a BUSY error")
+		track    = flags.Bool("track-reads", false, "Count node reads for ANALYSIS.HOTNODES")
+		human    = flags.Bool("human-readable", false, "Log array replies item by item for debugging with telnet or netcat")
+		transfer = flags.Duration("transfer-timeout", 5*time.Minute, "Idle time before a chunked GRAPH.EXPORT or GRAPH.IMPORT session is discarded")
+		attrKeys = flags.Int("max-attribute-key-length", storage.DefaultMaxAttributeKeyLength, "Longest attribute key in bytes that writes accept (0 for no limit)")
+		strict   = flags.Bool("strict-attribute-keys", false, "Also reject updates to entities that already hold an attribute key or value the policy rejects")
+		attrDeep = flags.Int("max-attribute-depth", models.DefaultMaxAttributeDepth, "How deeply attribute objects and arrays may nest, the attributes object being 1 (0 for no limit)")
+		attrKeyN = flags.Int("max-attribute-keys", models.DefaultMaxAttributeKeys, "Object keys and array elements the attributes of an entity may hold in all (0 for no limit)")
+		attrStr  = flags.Int("max-attribute-string-length", models.DefaultMaxAttributeStringLength, "Longest attribute string value in bytes that writes accept (0 for no limit)")
+		cacheMax = flags.Int("cache-entries", 0, "Node and edge records kept in the read cache (0 disables the cache)")
+		cacheMem = flags.Int64("cache-memory", storage.DefaultCacheMemory, "Estimated bytes the read cache may hold (0 for no limit)")
+		compress = flags.Int("compress-above", 0, "Gzip node and edge records larger than this many bytes when they are written (0 disables compression)")
+		traces   = flags.Bool("tracing", false, "Export OpenTelemetry spans for commands and analyses")
+		otlp     = flags.String("otlp-endpoint", "", "OTLP/HTTP collector URL or host:port for --tracing (exporter defaults if empty)")
+		txTraces = flags.Float64("storage-trace-rate", 0, "Fraction of storage write transactions traced with --tracing (0 to 1)")
+		history  = flags.Bool("stats-history", false, "Record a daily stats snapshot of every graph for ANALYSIS.STATSHISTORY")
+		histEach = flags.Duration("stats-history-interval", analysis.DefaultStatsHistoryInterval, "How often --stats-history records snapshots (one is kept per day)")
+		histDays = flags.Int("stats-history-days", storage.DefaultStatsHistoryDays, "Days of stats snapshots kept per graph (0 keeps all)")
+		delLog   = flags.Duration("deletion-log-retention", storage.DefaultDeletionLogRetention, "How long node and edge deletions are kept for GRAPH.EXPORT SINCE (0 keeps all)")
+		pathMax  = flags.Int("max-path-edges", defaults.PathEnumeration.MaxEdges, "Edges above which ANALYSIS.TRAVERSE lists paths only with FORCE (0 for no limit)")
+		cycleMax = flags.Int("max-cycle-edges", defaults.CycleEnumeration.MaxEdges, "Edges above which ANALYSIS.CYCLES runs only with FORCE (0 for no limit)")
+		clustMax = flags.Int("max-clustering-edges", defaults.Clustering.MaxEdges, "Edges above which ANALYSIS.CLUSTERING runs only with FORCE (0 for no limit)")
+		pairMax  = flags.Int("max-pairwise-nodes", defaults.PairwiseNodes, "Most nodes ANALYSIS.PAIRWISE compares in one call (0 for no limit)")
+		sortMax  = flags.Int("max-sort-results", commands.DefaultMaxSortResults, "Most results the list, filter and neighbor commands sort for ORDERBY (0 for no limit)")
 	)
-	flag.Parse()
+	flags.Parse(args)
+
+	minLevel, err := logging.ParseLevel(*level)
+	if err != nil {
+		log.Fatalf("Invalid --log-level: %v", err)
+	}
+	if *debug {
+		minLevel = slog.LevelDebug
+	}
+	logger := logging.New(minLevel)
 
 	// Create storage engine
-	storageEngine := storage.NewBadgerEngine()
+	storageEngine := storage.NewBadgerEngine(storage.WithLogger(logger), storage.WithMaxSnapshots(*maxSnaps), storage.WithMetaQuota(*metaSize),
+		storage.WithMaxAttributeKeyLength(*attrKeys), storage.WithStrictAttributeKeys(*strict),
+		storage.WithAttributeLimits(models.AttributeLimits{MaxDepth: *attrDeep, MaxKeys: *attrKeyN, MaxStringLength: *attrStr}),
+		storage.WithRecordCache(*cacheMax, *cacheMem), storage.WithCompressAbove(*compress),
+		storage.WithDeletionLogRetention(*delLog))
 	if err := storageEngine.Open(*dataDir); err != nil {
 		log.Fatalf("Failed to open storage engine: %v", err)
 	}
@@ -42,9 +117,29 @@ func main() {
 	config := redis.DefaultConfig()
 	config.Address = *addr
 	config.Debug = *debug
+	config.LogLevel = *level
+	config.AdminPassword = *password
+	config.JobWorkers = *workers
+	config.MaxActiveJobs = *maxJobs
+	config.MaxConcurrentCommands = *maxCmds
+	config.CommandQueueSize = *cmdQueue
+	config.TrackReads = *track
+	config.TransferTimeout = *transfer
+	config.HumanReadable = *human
+	config.EnableTracing = *traces
+	config.TracingEndpoint = *otlp
+	config.StorageTraceSampleRate = *txTraces
+	config.EnableStatsHistory = *history
+	config.StatsHistoryInterval = *histEach
+	config.StatsHistoryDays = *histDays
+	config.AnalysisLimits.PathEnumeration.MaxEdges = *pathMax
+	config.AnalysisLimits.CycleEnumeration.MaxEdges = *cycleMax
+	config.AnalysisLimits.Clustering.MaxEdges = *clustMax
+	config.AnalysisLimits.PairwiseNodes = *pairMax
+	config.MaxSortResults = *sortMax
 
 	// Create and start Redis server
-	server := redis.NewServer(config, storageEngine)
+	server := redis.NewServer(config, storageEngine, redis.WithLogger(logger))
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// embeddedBackend runs commands in-process on a storage engine owned by the
+// IDE backend, in place of a Redis server reached over TCP
+type embeddedBackend struct {
+	engine    *storage.BadgerEngine
+	handler   *redis.CommandHandler
+	closeOnce sync.Once
+}
+
+// NewEmbeddedProxy creates a proxy running commands on engine itself, with
+// no Redis server or connection pool. The proxy owns engine and closes it
+// when it is closed.
+func NewEmbeddedProxy(engine *storage.BadgerEngine) *RedisProxy {
+	return &RedisProxy{
+		limits:  DefaultMessageLimits(),
+		monitor: newBackendMonitor("", DefaultReconnectPolicy()),
+		embedded: &embeddedBackend{
+			engine:  engine,
+			handler: redis.NewCommandHandler(engine),
+		},
+	}
+}
+
+// execute runs a command for session and converts its reply as the proxy
+// converts the reply read from Redis, so the frontend cannot tell the modes
+// apart
+func (b *embeddedBackend) execute(session *commands.Session, command string, args []string) *WebSocketResponse {
+	response, err := b.handler.HandleSession(session, command, args)
+	if err != nil {
+		return newCommandResponse(&respValue{kind: '-', str: redis.ErrorReply(err)}, command, args)
+	}
+	return newCommandResponse(toRESP(response), command, args)
+}
+
+// closeSession releases the state a WebSocket connection's session holds,
+// as the server does when a client disconnects
+func (b *embeddedBackend) closeSession(session *commands.Session) {
+	b.handler.CloseSession(session)
+}
+
+// Close closes the storage engine. Only the first call closes it.
+func (b *embeddedBackend) Close() {
+	b.closeOnce.Do(func() {
+		if err := b.engine.Close(); err != nil {
+			log.Printf("Failed to close storage engine: %v", err)
+		}
+	})
+}
+
+// toRESP returns the reply the server writes for response
+func toRESP(response *protocol.Response) *respValue {
+	switch response.Type {
+	case protocol.ResponseTypeString:
+		return &respValue{kind: '+', str: response.StringValue}
+	case protocol.ResponseTypeInt:
+		return &respValue{kind: ':', num: response.IntValue}
+	case protocol.ResponseTypeArray:
+		return bulkArray(response.ArrayValue)
+	case protocol.ResponseTypeNestedArray:
+		value := &respValue{kind: '*', elems: make([]*respValue, len(response.NestedArrayValue))}
+		for i, subArray := range response.NestedArrayValue {
+			if sa, ok := subArray.([]string); ok {
+				value.elems[i] = bulkArray(sa)
+			} else if item, ok := subArray.(string); ok {
+				value.elems[i] = &respValue{kind: '$', str: item}
+			} else if subArray == nil {
+				value.elems[i] = &respValue{kind: '$', null: true}
+			} else {
+				value.elems[i] = &respValue{kind: '-', str: "ERR invalid nested array format"}
+			}
+		}
+		return value
+	case protocol.ResponseTypeBulk:
+		return &respValue{kind: '$', str: response.StringValue}
+	case protocol.ResponseTypeNull:
+		return &respValue{kind: '$', null: true}
+	case protocol.ResponseTypeError:
+		return &respValue{kind: '-', str: response.StringValue}
+	default:
+		return &respValue{kind: '-', str: "ERR unknown response type"}
+	}
+}
+
+// bulkArray returns an array reply of bulk strings
+func bulkArray(values []string) *respValue {
+	value := &respValue{kind: '*', elems: make([]*respValue, len(values))}
+	for i, item := range values {
+		value.elems[i] = &respValue{kind: '$', str: item}
+	}
+	return value
+}
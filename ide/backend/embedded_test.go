@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// dialEmbeddedProxy starts the WebSocket handler in embedded mode on a new
+// database and returns a connected client. No Redis server is started.
+func dialEmbeddedProxy(t *testing.T) (*RedisProxy, *storage.BadgerEngine, *websocket.Conn) {
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(filepath.Join(t.TempDir(), "data")); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	proxy := NewEmbeddedProxy(engine)
+	t.Cleanup(proxy.Close)
+	server := httptest.NewServer(http.HandlerFunc(proxy.handleWebSocket))
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial test proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proxy, engine, conn
+}
+
+// frame returns a response as JSON, without its timestamp
+func frame(t *testing.T, response *WebSocketResponse) []byte {
+	t.Helper()
+	response.Timestamp = 0
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+	return data
+}
+
+func TestEmbeddedMode(t *testing.T) {
+	t.Run("EndToEnd", func(t *testing.T) {
+		_, _, conn := dialEmbeddedProxy(t)
+		send := func(id, command string, args ...string) *WebSocketResponse {
+			t.Helper()
+			if err := conn.WriteJSON(WebSocketMessage{ID: id, Command: command, Args: args}); err != nil {
+				t.Fatalf("Failed to send message: %v", err)
+			}
+			return readResponse(t, conn)
+		}
+
+		for i, args := range [][]string{
+			{"GRAPH.CREATE", "g"},
+			{"NODE.CREATE", "g", "a", "service", "{}"},
+			{"NODE.CREATE", "g", "b", "database", `{"engine":"postgres"}`},
+			{"EDGE.CREATE", "g", "e1", "a", "b", "calls", "{}"},
+		} {
+			response := send("setup", args[0], args[1:]...)
+			if response.Type != "string" || response.Value != "OK" {
+				t.Fatalf("Expected %v (%d) to reply OK, got %+v", args, i, response)
+			}
+		}
+
+		response := send("req-1", "ANALYSIS.TRAVERSE", "g", "a")
+		expected := `{"id":"req-1","type":"array","value":["a:service->e1:calls->b:database"],` +
+			`"data":{"paths":[{"nodes":[{"id":"a","type":"service"},{"id":"b","type":"database"}],"edges":[{"id":"e1","type":"calls","direction":"out"}]}]},"timestamp":0}`
+		if got := frame(t, response); !sameJSON(t, got, []byte(expected)) {
+			t.Errorf("Unexpected frame\n got: %s\nwant: %s", got, expected)
+		}
+
+		response = send("req-2", "node.get", "g", "b")
+		expected = `{"id":"req-2","type":"array","value":["b","database","{\"engine\":\"postgres\"}",""],` +
+			`"data":{"attributes":{"engine":"postgres"},"expiresAt":"","id":"b","type":"database"},"timestamp":0}`
+		if got := frame(t, response); !sameJSON(t, got, []byte(expected)) {
+			t.Errorf("Unexpected frame\n got: %s\nwant: %s", got, expected)
+		}
+
+		// Errors carry the code the server would send
+		response = send("req-3", "NODE.GET", "g", "missing")
+		if value, _ := response.Value.(string); response.ID != "req-3" || response.Type != "error" || !strings.HasPrefix(value, "ERR ") {
+			t.Errorf("Expected an ERR reply for req-3, got %+v", response)
+		}
+		response = send("req-4", "NODE.UPDATE", "g", "a", "ATTRIBUTES", "{}", "IFGEN", "0")
+		if value, _ := response.Value.(string); response.Type != "error" || !strings.HasPrefix(value, "CONFLICT ") {
+			t.Errorf("Expected a CONFLICT reply for req-4, got %+v", response)
+		}
+
+		// Messages are still validated before they run
+		response = send("req-5", "GRAPH.CREATE;FLUSHALL")
+		if response.ID != "req-5" || response.Code != ErrCodeInvalidMessage {
+			t.Errorf("Expected invalid_message error for req-5, got %+v", response)
+		}
+	})
+
+	t.Run("SameFrames", func(t *testing.T) {
+		// Each response is converted as the reply the server writes for it
+		tests := []struct {
+			name     string
+			response *protocol.Response
+			stream   string
+			command  string
+		}{
+			{"String", protocol.OK(), "+OK\r\n", "GRAPH.CREATE"},
+			{"Int", protocol.NewIntResponse(42), ":42\r\n", "GRAPH.DELATTR"},
+			{"Array", protocol.NewArrayResponse([]string{"a:service", "b:database"}), "*2\r\n$9\r\na:service\r\n$10\r\nb:database\r\n", "NODE.LIST"},
+			{"EmptyArray", protocol.NewArrayResponse(nil), "*0\r\n", "NODE.LIST"},
+			{"Nested", protocol.NewNestedArrayResponse([]interface{}{[]string{"a", "b"}, []string{"c"}}), "*2\r\n*2\r\n$1\r\na\r\n$1\r\nb\r\n*1\r\n$1\r\nc\r\n", "ANALYSIS.COMPONENTS"},
+			{"NestedNull", protocol.NewNestedArrayResponse([]interface{}{nil, []string{"c"}}), "*2\r\n$-1\r\n*1\r\n$1\r\nc\r\n", "NODE.MGET"},
+			{"Bulk", protocol.NewBulkResponse(`{"x":1}`), "$7\r\n{\"x\":1}\r\n", "META.GET"},
+			{"Null", protocol.NewNullResponse(), "$-1\r\n", "ANALYSIS.SHORTESTPATH"},
+			{"Error", protocol.NewErrorResponse("ERR failed"), "-ERR failed\r\n", "NODE.GET"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				response := newCommandResponse(toRESP(tt.response), tt.command, []string{"g"})
+				response.ID = "req-1"
+				if got, want := frame(t, response), renderRecorded(t, tt.stream, tt.command, "g"); !sameJSON(t, got, want) {
+					t.Errorf("Unexpected frame\n got: %s\nwant: %s", got, want)
+				}
+			})
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		proxy, engine, _ := dialEmbeddedProxy(t)
+		if response, err := proxy.ExecuteCommand("GRAPH.CREATE", []string{"g"}); err != nil || response.Value != "OK" {
+			t.Fatalf("Expected GRAPH.CREATE to succeed, got %+v, %v", response, err)
+		}
+		proxy.Close()
+		if _, err := engine.GetGraph("g"); err == nil {
+			t.Errorf("Expected closing the proxy to close the engine, got %v", err)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,15 +11,19 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
+	"regexp"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/parser"
 	"github.com/gorilla/websocket"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
 )
 
 var upgrader = websocket.Upgrader{
@@ -33,13 +39,95 @@ type WebSocketMessage struct {
 	Timestamp int64    `json:"timestamp"`
 }
 
+// WebSocketResponse carries one reply. Type names the RESP type of Value;
+// Data holds a structured form of replies the proxy recognises.
 type WebSocketResponse struct {
 	ID        string      `json:"id"`
 	Type      string      `json:"type"`
+	Code      string      `json:"code,omitempty"`
 	Value     interface{} `json:"value"`
+	Data      interface{} `json:"data,omitempty"`
 	Timestamp int64       `json:"timestamp"`
 }
 
+// Error codes returned for messages rejected before reaching Redis
+const (
+	ErrCodeMessageTooLarge = "message_too_large"
+	ErrCodeInvalidMessage  = "invalid_message"
+)
+
+// MessageLimits bounds what a WebSocket client may send.
+// MaxMessageBytes is enforced at the framing level and drops the connection;
+// the argument limits are checked per message and answered with an error.
+type MessageLimits struct {
+	MaxMessageBytes int64
+	MaxArgs         int
+	MaxArgBytes     int
+}
+
+// DefaultMessageLimits returns the limits used when none are configured
+func DefaultMessageLimits() MessageLimits {
+	return MessageLimits{
+		MaxMessageBytes: 4 << 20,
+		MaxArgs:         1024,
+		MaxArgBytes:     2 << 20,
+	}
+}
+
+// commandPattern matches plain (PING) and namespaced (GRAPH.CREATE) commands
+var commandPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)?$`)
+
+// maxCommandLength caps the command token length
+const maxCommandLength = 64
+
+// newErrorResponse builds a structured error response for request id
+func newErrorResponse(id, code, message string) *WebSocketResponse {
+	return &WebSocketResponse{
+		ID:        id,
+		Type:      "error",
+		Code:      code,
+		Value:     message,
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
+// decodeMessage parses and validates a client message. On failure it returns
+// an error response carrying the request ID when one could be recovered.
+func decodeMessage(data []byte, limits MessageLimits) (*WebSocketMessage, *WebSocketResponse) {
+	var msg WebSocketMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		// Recover the ID so the client can match the error to its request
+		var envelope struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(data, &envelope)
+		return nil, newErrorResponse(envelope.ID, ErrCodeInvalidMessage, fmt.Sprintf("malformed message: %v", err))
+	}
+
+	if msg.Command == "" {
+		return nil, newErrorResponse(msg.ID, ErrCodeInvalidMessage, "command is required")
+	}
+	if len(msg.Command) > maxCommandLength || !commandPattern.MatchString(msg.Command) {
+		return nil, newErrorResponse(msg.ID, ErrCodeInvalidMessage, "invalid command name")
+	}
+	if limits.MaxArgs > 0 && len(msg.Args) > limits.MaxArgs {
+		return nil, newErrorResponse(msg.ID, ErrCodeMessageTooLarge,
+			fmt.Sprintf("too many arguments: %d (max %d)", len(msg.Args), limits.MaxArgs))
+	}
+	if limits.MaxArgBytes > 0 {
+		total := 0
+		for _, arg := range msg.Args {
+			total += len(arg)
+		}
+		if total > limits.MaxArgBytes {
+			return nil, newErrorResponse(msg.ID, ErrCodeMessageTooLarge,
+				fmt.Sprintf("arguments too large: %d bytes (max %d)", total, limits.MaxArgBytes))
+		}
+	}
+
+	return &msg, nil
+}
+
 type ConnectionPool struct {
 	redisAddr string
 	pool      chan net.Conn
@@ -56,12 +144,14 @@ func NewConnectionPool(redisAddr string, maxSize int) *ConnectionPool {
 	}
 }
 
-func (cp *ConnectionPool) GetConnection() (net.Conn, error) {
+// GetConnection returns a pooled connection, or a new one if none is idle.
+// reused reports whether the connection came from the pool.
+func (cp *ConnectionPool) GetConnection() (conn net.Conn, reused bool, err error) {
 	cp.mutex.Lock()
 	defer cp.mutex.Unlock()
 	
 	if cp.closed {
-		return nil, fmt.Errorf("connection pool is closed")
+		return nil, false, fmt.Errorf("connection pool is closed")
 	}
 	
 	select {
@@ -74,18 +164,21 @@ func (cp *ConnectionPool) GetConnection() (net.Conn, error) {
 		
 		if err == nil {
 			// Connection has data, put it back and create new one
-			return net.Dial("tcp", cp.redisAddr)
+			conn, err := net.Dial("tcp", cp.redisAddr)
+			return conn, false, err
 		} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			// Connection is alive (timeout as expected)
-			return conn, nil
+			return conn, true, nil
 		} else {
 			// Connection is dead, create new one
 			conn.Close()
-			return net.Dial("tcp", cp.redisAddr)
+			conn, err := net.Dial("tcp", cp.redisAddr)
+			return conn, false, err
 		}
 	default:
 		// No connection available, create new one
-		return net.Dial("tcp", cp.redisAddr)
+		conn, err := net.Dial("tcp", cp.redisAddr)
+		return conn, false, err
 	}
 }
 
@@ -107,6 +200,25 @@ func (cp *ConnectionPool) ReturnConnection(conn net.Conn) {
 	}
 }
 
+// Drain closes the idle connections, which go stale together when the Redis
+// server restarts
+func (cp *ConnectionPool) Drain() {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if cp.closed {
+		return
+	}
+	for {
+		select {
+		case conn := <-cp.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
 func (cp *ConnectionPool) Close() {
 	cp.mutex.Lock()
 	defer cp.mutex.Unlock()
@@ -123,20 +235,79 @@ func (cp *ConnectionPool) Close() {
 type RedisProxy struct {
 	redisAddr string
 	connPool  *ConnectionPool
+	limits    MessageLimits
+	monitor   *backendMonitor
+	// embedded runs commands in-process in embedded mode, where redisAddr
+	// and connPool are unused; nil in proxy mode
+	embedded *embeddedBackend
 }
 
 func NewRedisProxy(redisAddr string) *RedisProxy {
+	return NewRedisProxyWithPolicy(redisAddr, DefaultReconnectPolicy())
+}
+
+// NewRedisProxyWithPolicy creates a proxy probing an unreachable Redis server
+// as policy sets
+func NewRedisProxyWithPolicy(redisAddr string, policy ReconnectPolicy) *RedisProxy {
 	return &RedisProxy{
 		redisAddr: redisAddr,
 		connPool:  NewConnectionPool(redisAddr, 10), // Pool of 10 connections
+		limits:    DefaultMessageLimits(),
+		monitor:   newBackendMonitor(redisAddr, policy),
 	}
 }
 
+// Close stops probing Redis and closes the pooled connections, or in
+// embedded mode closes the storage engine
+func (rp *RedisProxy) Close() {
+	rp.monitor.Close()
+	if rp.embedded != nil {
+		rp.embedded.Close()
+		return
+	}
+	rp.connPool.Close()
+}
+
+// ExecuteCommand sends a command to Redis and returns its reply. While the
+// backend is degraded, commands fail fast with a backend_unavailable error
+// carrying the next retry time. A command failing on a pooled connection,
+// as every pooled connection does after a server restart, is retried once on
+// a new one; if that fails to connect too, the backend becomes degraded.
+// In embedded mode the command runs in-process with a session of its own.
 func (rp *RedisProxy) ExecuteCommand(command string, args []string) (*WebSocketResponse, error) {
+	if rp.embedded != nil {
+		return rp.embedded.execute(&commands.Session{}, command, args), nil
+	}
+	if status, degraded := rp.monitor.degraded(); degraded {
+		return newUnavailableResponse(status), nil
+	}
+
+	response, reused, err := rp.execute(command, args)
+	if err != nil && reused && isConnectionError(err) {
+		rp.connPool.Drain()
+		response, _, err = rp.execute(command, args)
+	}
+	if err != nil && isConnectionError(err) {
+		rp.monitor.markDegraded(err)
+		return newUnavailableResponse(rp.monitor.Status()), nil
+	}
+	if err != nil {
+		return &WebSocketResponse{
+			Type:      "error",
+			Value:     err.Error(),
+			Timestamp: time.Now().UnixMilli(),
+		}, nil
+	}
+	return response, nil
+}
+
+// execute sends a command on a pooled connection and reads its reply.
+// reused reports whether the connection came from the pool.
+func (rp *RedisProxy) execute(command string, args []string) (response *WebSocketResponse, reused bool, err error) {
 	// Get connection from pool
-	conn, err := rp.connPool.GetConnection()
+	conn, reused, err := rp.connPool.GetConnection()
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	
 	// Return connection to pool when done (or close if error)
@@ -156,75 +327,13 @@ func (rp *RedisProxy) ExecuteCommand(command string, args []string) (*WebSocketR
 	}
 
 	// Send command
-	_, err = conn.Write([]byte(redisCmd))
-	if err != nil {
-		return &WebSocketResponse{
-			Type:      "error",
-			Value:     err.Error(),
-			Timestamp: time.Now().UnixMilli(),
-		}, nil
-	}
-
-	// Read response
-	buf := make([]byte, 4096)
-	n, err := conn.Read(buf)
-	if err != nil {
-		return &WebSocketResponse{
-			Type:      "error",
-			Value:     err.Error(),
-			Timestamp: time.Now().UnixMilli(),
-		}, nil
-	}
-
-	responseStr := string(buf[:n])
-	response := &WebSocketResponse{
-		Timestamp: time.Now().UnixMilli(),
+	if _, err = conn.Write([]byte(redisCmd)); err != nil {
+		return nil, reused, err
 	}
 
-	// Parse Redis response
-	if len(responseStr) == 0 {
-		response.Type = "null"
-		response.Value = nil
-	} else {
-		switch responseStr[0] {
-		case '+':
-			response.Type = "string"
-			response.Value = strings.TrimSpace(responseStr[1:])
-		case '-':
-			response.Type = "error"
-			response.Value = strings.TrimSpace(responseStr[1:])
-		case ':':
-			response.Type = "int"
-			if val, err := strconv.ParseInt(strings.TrimSpace(responseStr[1:]), 10, 64); err == nil {
-				response.Value = val
-			} else {
-				response.Value = 0
-			}
-		case '$':
-			response.Type = "bulk"
-			lines := strings.Split(responseStr, "\r\n")
-			if len(lines) > 1 {
-				response.Value = lines[1]
-			} else {
-				response.Value = ""
-			}
-		case '*':
-			response.Type = "array"
-			lines := strings.Split(responseStr, "\r\n")
-			var result []string
-			for i := 1; i < len(lines); i += 2 {
-				if i+1 < len(lines) && lines[i][0] == '$' {
-					result = append(result, lines[i+1])
-				}
-			}
-			response.Value = result
-		default:
-			response.Type = "string"
-			response.Value = strings.TrimSpace(responseStr)
-		}
-	}
-
-	return response, nil
+	// Read the whole reply, however many reads it spans
+	response, err = renderReply(bufio.NewReader(conn), command, args)
+	return response, reused, err
 }
 
 func (rp *RedisProxy) handleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -237,33 +346,86 @@ func (rp *RedisProxy) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("WebSocket client connected: %s", conn.RemoteAddr())
 
+	// Oversized frames are a framing-level violation: the reader fails, the
+	// library sends a 1009 close frame and the connection is dropped.
+	conn.SetReadLimit(rp.limits.MaxMessageBytes)
+
+	// Backend status events are written alongside replies, so writes are
+	// serialized
+	var writeMutex sync.Mutex
+	send := func(response *WebSocketResponse) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		return conn.WriteJSON(response)
+	}
+
+	statuses, unsubscribe := rp.monitor.subscribe()
+	defer unsubscribe()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case status := <-statuses:
+				if err := send(newStatusResponse(status)); err != nil {
+					log.Printf("Failed to send backend status: %v", err)
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	// A client connecting while the backend is degraded learns it at once
+	if status, degraded := rp.monitor.degraded(); degraded {
+		send(newStatusResponse(status))
+	}
+
+	// In embedded mode the connection is one client session, as a TCP
+	// connection to the server would be
+	var session *commands.Session
+	if rp.embedded != nil {
+		session = &commands.Session{Client: conn.RemoteAddr().String()}
+		defer rp.embedded.closeSession(session)
+	}
+
 	for {
-		var msg WebSocketMessage
-		err := conn.ReadJSON(&msg)
+		_, data, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if err == websocket.ErrReadLimit {
+				log.Printf("WebSocket message exceeded %d bytes, closing: %s", rp.limits.MaxMessageBytes, conn.RemoteAddr())
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
 
-		log.Printf("Received command: %s %v", msg.Command, msg.Args)
+		msg, response := decodeMessage(data, rp.limits)
+		if msg != nil {
+			log.Printf("Received command: %s (%d args)", msg.Command, len(msg.Args))
 
-		// Execute Redis command
-		response, err := rp.ExecuteCommand(msg.Command, msg.Args)
-		if err != nil {
-			response = &WebSocketResponse{
-				Type:      "error",
-				Value:     err.Error(),
-				Timestamp: time.Now().UnixMilli(),
+			// Execute Redis command
+			if session != nil {
+				response, err = rp.embedded.execute(session, msg.Command, msg.Args), nil
+			} else {
+				response, err = rp.ExecuteCommand(msg.Command, msg.Args)
+			}
+			if err != nil {
+				response = &WebSocketResponse{
+					Type:      "error",
+					Value:     err.Error(),
+					Timestamp: time.Now().UnixMilli(),
+				}
 			}
-		}
 
-		// Set response ID to match request
-		response.ID = msg.ID
+			// Set response ID to match request
+			response.ID = msg.ID
+		} else {
+			log.Printf("Rejected message (%s): %v", response.Code, response.Value)
+		}
 
 		// Send response back to client
-		if err := conn.WriteJSON(response); err != nil {
+		if err := send(response); err != nil {
 			log.Printf("Failed to send response: %v", err)
 			break
 		}
@@ -272,13 +434,28 @@ func (rp *RedisProxy) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	log.Printf("WebSocket client disconnected: %s", conn.RemoteAddr())
 }
 
+// handleHealth reports the backend status. It answers 503 while Redis
+// cannot be reached.
 func (rp *RedisProxy) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "ok",
-		"redis":  rp.redisAddr,
-		"time":   time.Now().Unix(),
-	})
+	backend, degraded := rp.monitor.degraded()
+	status := "ok"
+	if degraded {
+		status = BackendDegraded
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	health := map[string]interface{}{
+		"status":  status,
+		"mode":    "proxy",
+		"redis":   rp.redisAddr,
+		"backend": backend,
+		"time":    time.Now().Unix(),
+	}
+	if rp.embedded != nil {
+		health["mode"] = "embedded"
+		delete(health, "redis")
+	}
+	json.NewEncoder(w).Encode(health)
 }
 
 func (rp *RedisProxy) handleListDocs(w http.ResponseWriter, r *http.Request) {
@@ -401,6 +578,10 @@ func (rp *RedisProxy) handleGetDoc(w http.ResponseWriter, r *http.Request) {
 	w.Write(html)
 }
 
+// shutdownTimeout bounds how long shutdown waits for HTTP requests in
+// flight. WebSocket connections are not waited for.
+const shutdownTimeout = 5 * time.Second
+
 // getEnv reads an environment variable or returns a fallback value.
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -414,16 +595,42 @@ func main() {
 	websocketAddr := getEnv("WEBSOCKET_ADDR", ":8081")
 	redisAddrEnv := getEnv("REDIS_ADDR", "localhost:6379")
 
+	limits := DefaultMessageLimits()
+	reconnect := DefaultReconnectPolicy()
+
 	var (
-		addr      = flag.String("addr", websocketAddr, "WebSocket server address")
-		redisAddr = flag.String("redis", redisAddrEnv, "Redis server address")
+		addr            = flag.String("addr", websocketAddr, "WebSocket server address")
+		redisAddr       = flag.String("redis", redisAddrEnv, "Redis server address")
+		maxMessageBytes = flag.Int64("max-message-bytes", limits.MaxMessageBytes, "Maximum WebSocket message size in bytes")
+		maxArgs         = flag.Int("max-args", limits.MaxArgs, "Maximum number of command arguments")
+		maxArgBytes     = flag.Int("max-arg-bytes", limits.MaxArgBytes, "Maximum total size of command arguments in bytes")
+		retryInitial    = flag.Duration("reconnect-initial", reconnect.InitialBackoff, "Wait before the first probe of an unreachable Redis server")
+		retryMax        = flag.Duration("reconnect-max", reconnect.MaxBackoff, "Longest wait between probes of an unreachable Redis server")
+		embedded        = flag.Bool("embedded", false, "Open the database in-process instead of connecting to a Redis server")
+		dataDir         = flag.String("data", "", "Data directory opened in --embedded mode")
 	)
 	flag.Parse()
 
-	proxy := NewRedisProxy(*redisAddr)
-	
-	// Cleanup connection pool on shutdown
-	defer proxy.connPool.Close()
+	var proxy *RedisProxy
+	if *embedded {
+		if *dataDir == "" {
+			log.Fatalf("--embedded requires --data <dir>")
+		}
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(*dataDir); err != nil {
+			log.Fatalf("Failed to open storage engine: %v", err)
+		}
+		proxy = NewEmbeddedProxy(engine)
+	} else {
+		reconnect.InitialBackoff = *retryInitial
+		reconnect.MaxBackoff = *retryMax
+		proxy = NewRedisProxyWithPolicy(*redisAddr, reconnect)
+	}
+	proxy.limits = MessageLimits{
+		MaxMessageBytes: *maxMessageBytes,
+		MaxArgs:         *maxArgs,
+		MaxArgBytes:     *maxArgBytes,
+	}
 
 	// WebSocket endpoint
 	http.HandleFunc("/ws", proxy.handleWebSocket)
@@ -438,10 +645,30 @@ func main() {
 	// Serve static files (for development)
 	http.Handle("/", http.FileServer(http.Dir("../frontend/build/")))
 
+	// Handle graceful shutdown: stop accepting requests, then stop probing
+	// and cleanup the connection pool, or close the database in embedded
+	// mode
+	server := &http.Server{Addr: *addr}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		log.Println("Shutting down PathwayDB IDE WebSocket server...")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
 	log.Printf("PathwayDB IDE WebSocket server starting on %s", *addr)
-	log.Printf("Connecting to Redis server at %s with connection pool (max 10 connections)", *redisAddr)
+	if *embedded {
+		log.Printf("Running commands in-process on data directory %s", *dataDir)
+	} else {
+		log.Printf("Connecting to Redis server at %s with connection pool (max 10 connections)", *redisAddr)
+	}
 
-	if err := http.ListenAndServe(*addr, nil); err != nil {
+	err := server.ListenAndServe()
+	proxy.Close()
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialTestProxy starts the WebSocket handler with the given limits and
+// returns a connected client. No Redis server is needed: every message sent
+// by these tests is rejected before it would be forwarded.
+func dialTestProxy(t *testing.T, limits MessageLimits) *websocket.Conn {
+	proxy := NewRedisProxy("127.0.0.1:0")
+	proxy.limits = limits
+	server := httptest.NewServer(http.HandlerFunc(proxy.handleWebSocket))
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial test proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readResponse reads a single response with a deadline
+func readResponse(t *testing.T, conn *websocket.Conn) *WebSocketResponse {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var response WebSocketResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return &response
+}
+
+func TestWebSocketMessageValidation(t *testing.T) {
+	limits := MessageLimits{MaxMessageBytes: 4096, MaxArgs: 8, MaxArgBytes: 1024}
+
+	t.Run("EmptyCommand", func(t *testing.T) {
+		conn := dialTestProxy(t, limits)
+		if err := conn.WriteJSON(WebSocketMessage{ID: "req-1", Command: ""}); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+		response := readResponse(t, conn)
+		if response.ID != "req-1" || response.Type != "error" || response.Code != ErrCodeInvalidMessage {
+			t.Errorf("Expected invalid_message error for req-1, got %+v", response)
+		}
+
+		// The connection survives and keeps answering
+		conn.WriteJSON(WebSocketMessage{ID: "req-2", Command: "GRAPH.CREATE;FLUSHALL"})
+		response = readResponse(t, conn)
+		if response.ID != "req-2" || response.Code != ErrCodeInvalidMessage {
+			t.Errorf("Expected invalid_message error for req-2, got %+v", response)
+		}
+	})
+
+	t.Run("NestedArgs", func(t *testing.T) {
+		conn := dialTestProxy(t, limits)
+		nested := `{"id":"req-3","command":"NODE.CREATE","args":[` + strings.Repeat("[", 200) + strings.Repeat("]", 200) + `]}`
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(nested)); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+		response := readResponse(t, conn)
+		if response.ID != "req-3" || response.Code != ErrCodeInvalidMessage {
+			t.Errorf("Expected invalid_message error for req-3, got %+v", response)
+		}
+
+		conn.WriteJSON(WebSocketMessage{ID: "req-4"})
+		if response := readResponse(t, conn); response.ID != "req-4" {
+			t.Errorf("Expected connection to survive, got %+v", response)
+		}
+	})
+
+	t.Run("TooManyArgs", func(t *testing.T) {
+		conn := dialTestProxy(t, limits)
+		conn.WriteJSON(WebSocketMessage{ID: "req-5", Command: "NODE.CREATE", Args: make([]string, 9)})
+		response := readResponse(t, conn)
+		if response.ID != "req-5" || response.Code != ErrCodeMessageTooLarge {
+			t.Errorf("Expected message_too_large error for req-5, got %+v", response)
+		}
+	})
+
+	t.Run("ArgBytesExceeded", func(t *testing.T) {
+		conn := dialTestProxy(t, limits)
+		conn.WriteJSON(WebSocketMessage{ID: "req-6", Command: "NODE.CREATE", Args: []string{strings.Repeat("x", 2000)}})
+		response := readResponse(t, conn)
+		if response.ID != "req-6" || response.Code != ErrCodeMessageTooLarge {
+			t.Errorf("Expected message_too_large error for req-6, got %+v", response)
+		}
+
+		conn.WriteJSON(WebSocketMessage{ID: "req-7"})
+		if response := readResponse(t, conn); response.ID != "req-7" {
+			t.Errorf("Expected connection to survive, got %+v", response)
+		}
+	})
+
+	t.Run("OversizedFrame", func(t *testing.T) {
+		conn := dialTestProxy(t, limits)
+		conn.WriteJSON(WebSocketMessage{ID: "req-8", Command: "NODE.CREATE", Args: []string{strings.Repeat("x", 8192)}})
+
+		// Framing-level violations drop the connection. The client sees the
+		// 1009 close frame, or a reset if the server closed before it arrived.
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err == nil {
+			t.Fatalf("Expected connection to be closed, got message %s", data)
+		}
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			t.Errorf("Expected close code %d, got %v", websocket.CloseMessageTooBig, err)
+		}
+	})
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// postProcessor turns a recognised reply into structured data for the
+// frontend. It returns nil when the reply does not have the expected shape,
+// in which case the frontend only gets the raw value.
+type postProcessor func(args []string, reply *respValue) interface{}
+
+// postProcessors lists the commands whose replies are converted, keyed by
+// upper-case command name
+var postProcessors = map[string]postProcessor{
+	"ANALYSIS.TRAVERSE":     processPaths,
+	"ANALYSIS.SHORTESTPATH": processPaths,
+	"ANALYSIS.CYCLES":       processPaths,
+	"ANALYSIS.CLUSTERING":   processGroups,
+	"ANALYSIS.COMPONENTS":   processGroups,
+	"ANALYSIS.CENTRALITY":   processRanking("score"),
+	"ANALYSIS.HOTNODES":     processRanking("reads"),
+	"NODE.LIST":             processEntities("nodes"),
+	"EDGE.LIST":             processEntities("edges"),
+	"META.LIST":             processMeta,
+	"GRAPH.GET": processRecord(
+		recordField{"id", fieldString},
+		recordField{"name", fieldString},
+		recordField{"description", fieldString},
+		recordField{"nodeCount", fieldInt},
+		recordField{"edgeCount", fieldInt},
+		recordField{"attributes", fieldJSON},
+	),
+	"NODE.GET": processRecord(
+		recordField{"id", fieldString},
+		recordField{"type", fieldString},
+		recordField{"attributes", fieldJSON},
+		recordField{"expiresAt", fieldString},
+	),
+	"EDGE.GET": processRecord(
+		recordField{"id", fieldString},
+		recordField{"from", fieldString},
+		recordField{"to", fieldString},
+		recordField{"type", fieldString},
+		recordField{"attributes", fieldJSON},
+		recordField{"expiresAt", fieldString},
+	),
+}
+
+// EntityRef is a node or edge rendered as id:type
+type EntityRef struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// PathEdge is an edge on a path and the direction it was followed in:
+// "out" for ->, "in" for <-
+type PathEdge struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Direction string `json:"direction"`
+}
+
+// Path is a path reply split into its nodes and the edges between them
+type Path struct {
+	Nodes []EntityRef `json:"nodes"`
+	Edges []PathEdge  `json:"edges"`
+}
+
+// RankedEntry is one id, value pair of a ranking reply
+type RankedEntry map[string]interface{}
+
+// hasDisplayOptions reports whether args ask for labels or ages, which are
+// appended to id:type entries and make them ambiguous to split
+func hasDisplayOptions(args []string) bool {
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "LABELS", "AGE":
+			return true
+		}
+	}
+	return false
+}
+
+// parseEntity splits an id:type entry at its last colon. Types may not
+// contain colons, but IDs may.
+func parseEntity(entry string) (EntityRef, bool) {
+	i := strings.LastIndex(entry, ":")
+	if i <= 0 || i == len(entry)-1 {
+		return EntityRef{}, false
+	}
+	return EntityRef{ID: entry[:i], Type: entry[i+1:]}, true
+}
+
+// parsePath splits node->edge->node or node<-edge<-node notation. IDs and
+// types may not contain the arrows, so every arrow is a separator.
+func parsePath(path string) (*Path, bool) {
+	var tokens, arrows []string
+	for {
+		i := strings.Index(path, "->")
+		if j := strings.Index(path, "<-"); j >= 0 && (i < 0 || j < i) {
+			i = j
+		}
+		if i < 0 {
+			tokens = append(tokens, path)
+			break
+		}
+		tokens = append(tokens, path[:i])
+		arrows = append(arrows, path[i:i+2])
+		path = path[i+2:]
+	}
+	// Tokens alternate node, edge, node, ... with the same arrow on both
+	// sides of each edge
+	if len(tokens)%2 == 0 {
+		return nil, false
+	}
+
+	result := &Path{Nodes: []EntityRef{}, Edges: []PathEdge{}}
+	for i, token := range tokens {
+		entity, ok := parseEntity(token)
+		if !ok {
+			return nil, false
+		}
+		if i%2 == 0 {
+			result.Nodes = append(result.Nodes, entity)
+			continue
+		}
+		if arrows[i-1] != arrows[i] {
+			return nil, false
+		}
+		direction := "out"
+		if arrows[i] == "<-" {
+			direction = "in"
+		}
+		result.Edges = append(result.Edges, PathEdge{ID: entity.ID, Type: entity.Type, Direction: direction})
+	}
+	return result, true
+}
+
+// hasFlag reports whether args hold the keyword flag, in any case
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if strings.EqualFold(arg, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSimpleFormat reports whether args ask for FORMAT simple
+func isSimpleFormat(args []string) bool {
+	for i := 0; i+1 < len(args); i++ {
+		if strings.EqualFold(args[i], "FORMAT") {
+			return strings.EqualFold(args[i+1], "simple")
+		}
+	}
+	return false
+}
+
+// processPaths converts path lists, as returned in the detailed format, into
+// {"paths": [...]}, and id:type lists, as returned in the simple format, into
+// {"nodes": [...]}. The leading count of a reply to a command given COUNT is
+// dropped, and the node IDs after a MAXFANOUT reply's "fanout_limited"
+// marker become "fanoutLimited".
+func processPaths(args []string, reply *respValue) interface{} {
+	values, ok := reply.strings()
+	if !ok || hasDisplayOptions(args) {
+		return nil
+	}
+
+	data := map[string]interface{}{}
+	for i, value := range values {
+		if value == "fanout_limited" {
+			data["fanoutLimited"] = append([]string{}, values[i+1:]...)
+			values = values[:i]
+			break
+		}
+	}
+	if hasFlag(args, "COUNT") && len(values) > 0 {
+		if count, err := strconv.Atoi(values[0]); err == nil && count == len(values)-1 {
+			values = values[1:]
+		}
+	}
+
+	if !isSimpleFormat(args) {
+		paths := make([]*Path, 0, len(values))
+		for _, value := range values {
+			path, ok := parsePath(value)
+			if !ok {
+				return nil
+			}
+			paths = append(paths, path)
+		}
+		data["paths"] = paths
+		return data
+	}
+
+	nodes := make([]EntityRef, 0, len(values))
+	for _, value := range values {
+		entity, ok := parseEntity(value)
+		if !ok {
+			return nil
+		}
+		nodes = append(nodes, entity)
+	}
+	data["nodes"] = nodes
+	return data
+}
+
+// processGroups converts nested arrays of node IDs, such as communities or
+// components, into {"groups": [[...], ...]}
+func processGroups(args []string, reply *respValue) interface{} {
+	if reply.kind != '*' || !reply.isNested() {
+		return nil
+	}
+	groups := make([][]string, 0, len(reply.elems))
+	for _, elem := range reply.elems {
+		members, ok := elem.strings()
+		if !ok {
+			return nil
+		}
+		groups = append(groups, members)
+	}
+	return map[string]interface{}{"groups": groups}
+}
+
+// processRanking converts id, value pairs into {"ranking": [{"id": ...,
+// field: ...}]}. A PAGE reply's leading cursor becomes "cursor", and a
+// trailing "warning" pair, whose message is not a number, becomes "warning".
+func processRanking(field string) postProcessor {
+	return func(args []string, reply *respValue) interface{} {
+		values, ok := reply.strings()
+		if !ok {
+			return nil
+		}
+
+		data := map[string]interface{}{}
+		for _, arg := range args {
+			if strings.ToUpper(arg) == "PAGE" && len(values) > 0 {
+				data["cursor"] = values[0]
+				values = values[1:]
+				break
+			}
+		}
+		if len(values)%2 != 0 {
+			return nil
+		}
+
+		ranking := make([]RankedEntry, 0, len(values)/2)
+		for i := 0; i < len(values); i += 2 {
+			number, err := strconv.ParseFloat(values[i+1], 64)
+			if err != nil {
+				if values[i] == "warning" && i == len(values)-2 {
+					data["warning"] = values[i+1]
+					break
+				}
+				return nil
+			}
+			ranking = append(ranking, RankedEntry{"id": values[i], field: number})
+		}
+		data["ranking"] = ranking
+		return data
+	}
+}
+
+// processEntities converts id:type lists into {key: [{"id": ..., "type":
+// ...}]}
+func processEntities(key string) postProcessor {
+	return func(args []string, reply *respValue) interface{} {
+		values, ok := reply.strings()
+		if !ok || hasDisplayOptions(args) {
+			return nil
+		}
+		entities := make([]EntityRef, 0, len(values))
+		for _, value := range values {
+			entity, ok := parseEntity(value)
+			if !ok {
+				return nil
+			}
+			entities = append(entities, entity)
+		}
+		return map[string]interface{}{key: entities}
+	}
+}
+
+// fieldKind is how a positional record field is decoded
+type fieldKind int
+
+const (
+	fieldString fieldKind = iota
+	fieldInt
+	fieldJSON
+)
+
+// recordField names a position of a record reply
+type recordField struct {
+	name string
+	kind fieldKind
+}
+
+// processRecord converts a fixed-length positional reply, such as NODE.GET,
+// into an object with the given field names
+func processRecord(fields ...recordField) postProcessor {
+	return func(args []string, reply *respValue) interface{} {
+		values, ok := reply.strings()
+		if !ok || len(values) != len(fields) {
+			return nil
+		}
+
+		record := make(map[string]interface{}, len(fields))
+		for i, field := range fields {
+			switch field.kind {
+			case fieldInt:
+				n, err := strconv.ParseInt(values[i], 10, 64)
+				if err != nil {
+					return nil
+				}
+				record[field.name] = n
+			case fieldJSON:
+				if !json.Valid([]byte(values[i])) {
+					return nil
+				}
+				record[field.name] = json.RawMessage(values[i])
+			default:
+				record[field.name] = values[i]
+			}
+		}
+		return record
+	}
+}
+
+// processMeta converts META.LIST key, value pairs into {"entries": {key:
+// value}}, with each value parsed as JSON. The IDE reads node positions
+// from the "ide-layout" namespace this way.
+func processMeta(args []string, reply *respValue) interface{} {
+	values, ok := reply.strings()
+	if !ok || len(values)%2 != 0 {
+		return nil
+	}
+	entries := make(map[string]json.RawMessage, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		if !json.Valid([]byte(values[i+1])) {
+			return nil
+		}
+		entries[values[i]] = json.RawMessage(values[i+1])
+	}
+	return map[string]interface{}{"entries": entries}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Backend states reported by /health and status events
+const (
+	BackendUp       = "up"
+	BackendDegraded = "degraded"
+)
+
+// ErrCodeBackendUnavailable is returned for commands received while Redis
+// cannot be reached
+const ErrCodeBackendUnavailable = "backend_unavailable"
+
+// ReconnectPolicy sets how an unreachable Redis server is probed. The first
+// probe waits InitialBackoff and each later one twice as long as the one
+// before, up to MaxBackoff. A probe fails if it gets no reply to PING within
+// ProbeTimeout.
+type ReconnectPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	ProbeTimeout   time.Duration
+}
+
+// DefaultReconnectPolicy returns the policy used when none is configured
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		ProbeTimeout:   2 * time.Second,
+	}
+}
+
+// nextBackoff returns the wait after one of backoff, doubled and capped.
+// A zero InitialBackoff probes at once and then waits MaxBackoff.
+func (p ReconnectPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > p.MaxBackoff || backoff <= 0 {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// BackendStatus is the state of the connection to Redis. Times are Unix
+// milliseconds; NextRetry and Attempts are only set while degraded.
+type BackendStatus struct {
+	State     string `json:"state"`
+	Error     string `json:"error,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+	NextRetry int64  `json:"nextRetry,omitempty"`
+	Since     int64  `json:"since"`
+}
+
+// isConnectionError reports whether err means Redis could not be reached or
+// the connection broke, as opposed to a malformed reply
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.As(err, &netErr)
+}
+
+// backendMonitor tracks whether Redis is reachable. A connection failure
+// marks the backend degraded and starts probing it with backoff; the first
+// probe answered clears the state. Status changes are published to
+// subscribers.
+type backendMonitor struct {
+	redisAddr string
+	policy    ReconnectPolicy
+
+	mutex       sync.Mutex
+	status      BackendStatus
+	subscribers map[chan BackendStatus]struct{}
+	done        chan struct{}
+	closed      bool
+}
+
+func newBackendMonitor(redisAddr string, policy ReconnectPolicy) *backendMonitor {
+	return &backendMonitor{
+		redisAddr:   redisAddr,
+		policy:      policy,
+		status:      BackendStatus{State: BackendUp, Since: time.Now().UnixMilli()},
+		subscribers: make(map[chan BackendStatus]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Status returns the current status
+func (m *backendMonitor) Status() BackendStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.status
+}
+
+// degraded returns the current status and whether it is degraded
+func (m *backendMonitor) degraded() (BackendStatus, bool) {
+	status := m.Status()
+	return status, status.State == BackendDegraded
+}
+
+// markDegraded records a connection failure. If the backend was up, it
+// becomes degraded and probing starts; otherwise the probes already running
+// carry on.
+func (m *backendMonitor) markDegraded(err error) {
+	m.mutex.Lock()
+	if m.closed || m.status.State == BackendDegraded {
+		m.mutex.Unlock()
+		return
+	}
+	now := time.Now()
+	m.status = BackendStatus{
+		State:     BackendDegraded,
+		Error:     err.Error(),
+		NextRetry: now.Add(m.policy.InitialBackoff).UnixMilli(),
+		Since:     now.UnixMilli(),
+	}
+	m.publish()
+	m.mutex.Unlock()
+
+	go m.probe()
+}
+
+// probe pings Redis with backoff until it answers or the monitor is closed
+func (m *backendMonitor) probe() {
+	backoff := m.policy.InitialBackoff
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-m.done:
+			return
+		}
+
+		err := m.ping()
+		m.mutex.Lock()
+		if m.closed {
+			m.mutex.Unlock()
+			return
+		}
+		if err == nil {
+			m.status = BackendStatus{State: BackendUp, Since: time.Now().UnixMilli()}
+			m.publish()
+			m.mutex.Unlock()
+			return
+		}
+		backoff = m.policy.nextBackoff(backoff)
+		m.status.Error = err.Error()
+		m.status.Attempts++
+		m.status.NextRetry = time.Now().Add(backoff).UnixMilli()
+		m.publish()
+		m.mutex.Unlock()
+	}
+}
+
+// ping dials Redis and sends PING. Any reply, even an error, shows the
+// server is reachable.
+func (m *backendMonitor) ping() error {
+	conn, err := net.DialTimeout("tcp", m.redisAddr, m.policy.ProbeTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(m.policy.ProbeTimeout))
+	if _, err := conn.Write([]byte("*1\r\n$4\r\nPING\r\n")); err != nil {
+		return err
+	}
+	if _, err := readRESP(bufio.NewReader(conn)); err != nil {
+		return fmt.Errorf("no reply to PING: %w", err)
+	}
+	return nil
+}
+
+// subscribe returns a channel receiving each status change and a function
+// ending the subscription. A subscriber that falls behind only misses
+// intermediate changes, never the latest one.
+func (m *backendMonitor) subscribe() (<-chan BackendStatus, func()) {
+	ch := make(chan BackendStatus, 1)
+	m.mutex.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mutex.Unlock()
+	return ch, func() {
+		m.mutex.Lock()
+		delete(m.subscribers, ch)
+		m.mutex.Unlock()
+	}
+}
+
+// publish sends the status to every subscriber, replacing any change it has
+// not received yet. The mutex must be held.
+func (m *backendMonitor) publish() {
+	for ch := range m.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- m.status
+	}
+}
+
+// Close stops probing
+func (m *backendMonitor) Close() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.done)
+	}
+}
+
+// newStatusResponse builds the status event pushed to WebSocket clients
+func newStatusResponse(status BackendStatus) *WebSocketResponse {
+	return &WebSocketResponse{
+		Type:      "status",
+		Value:     status.State,
+		Data:      status,
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
+// newUnavailableResponse builds the error returned for a command received
+// while the backend is degraded
+func newUnavailableResponse(status BackendStatus) *WebSocketResponse {
+	response := newErrorResponse("", ErrCodeBackendUnavailable,
+		fmt.Sprintf("backend unavailable: %s (next retry at %s)", status.Error, time.UnixMilli(status.NextRetry).UTC().Format(time.RFC3339Nano)))
+	response.Data = status
+	return response
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// stubServer is a RESP server answering PING with PONG and anything else
+// with OK, which can be stopped and restarted on the same address
+type stubServer struct {
+	addr     string
+	mutex    sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+func startStubServer(t *testing.T) *stubServer {
+	s := &stubServer{addr: "127.0.0.1:0"}
+	s.start(t)
+	t.Cleanup(s.stop)
+	return s
+}
+
+// start listens on the server's address, taking a free port the first time
+func (s *stubServer) start(t *testing.T) {
+	t.Helper()
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		t.Fatalf("Failed to start stub server: %v", err)
+	}
+	s.mutex.Lock()
+	s.addr = listener.Addr().String()
+	s.listener = listener
+	s.conns = make(map[net.Conn]struct{})
+	s.mutex.Unlock()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mutex.Lock()
+			s.conns[conn] = struct{}{}
+			s.mutex.Unlock()
+			go s.serve(conn)
+		}
+	}()
+}
+
+func (s *stubServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		command, err := readRESP(r)
+		if err != nil || len(command.elems) == 0 {
+			return
+		}
+		reply := "+OK\r\n"
+		if strings.EqualFold(command.elems[0].str, "PING") {
+			reply = "+PONG\r\n"
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// stop closes the listener and every connection, as a server exiting would
+func (s *stubServer) stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.listener == nil {
+		return
+	}
+	s.listener.Close()
+	s.listener = nil
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// readUntil reads frames until one matches, failing after a deadline
+func readUntil(t *testing.T, conn *websocket.Conn, match func(*WebSocketResponse) bool) *WebSocketResponse {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var response WebSocketResponse
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if match(&response) {
+			return &response
+		}
+	}
+}
+
+// isStatus matches a status event for state
+func isStatus(state string) func(*WebSocketResponse) bool {
+	return func(response *WebSocketResponse) bool {
+		return response.Type == "status" && response.Value == state
+	}
+}
+
+// isReply matches the response to request id
+func isReply(id string) func(*WebSocketResponse) bool {
+	return func(response *WebSocketResponse) bool {
+		return response.ID == id
+	}
+}
+
+// health returns the /health status code and body
+func health(t *testing.T, server *httptest.Server) (int, map[string]interface{}) {
+	t.Helper()
+	resp, err := http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatalf("Failed to get /health: %v", err)
+	}
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode /health: %v", err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestReconnect(t *testing.T) {
+	stub := startStubServer(t)
+	proxy := NewRedisProxyWithPolicy(stub.addr, ReconnectPolicy{
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     100 * time.Millisecond,
+		ProbeTimeout:   time.Second,
+	})
+	t.Cleanup(proxy.Close)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", proxy.handleWebSocket)
+	mux.HandleFunc("/health", proxy.handleHealth)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	send := func(id, command string) {
+		t.Helper()
+		if err := conn.WriteJSON(WebSocketMessage{ID: id, Command: command}); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+	}
+
+	t.Run("Up", func(t *testing.T) {
+		send("req-1", "PING")
+		if response := readUntil(t, conn, isReply("req-1")); response.Value != "PONG" {
+			t.Errorf("Expected PONG, got %+v", response)
+		}
+		if code, body := health(t, server); code != http.StatusOK || body["status"] != "ok" {
+			t.Errorf("Expected a healthy backend, got %d %v", code, body)
+		}
+	})
+
+	t.Run("Stale Pool", func(t *testing.T) {
+		// A quick restart leaves the pooled connection stale, and the
+		// command is retried on a new one without degrading the backend
+		stub.stop()
+		stub.start(t)
+		send("req-2", "PING")
+		if response := readUntil(t, conn, isReply("req-2")); response.Value != "PONG" {
+			t.Errorf("Expected PONG after a restart, got %+v", response)
+		}
+		if status := proxy.monitor.Status(); status.State != BackendUp {
+			t.Errorf("Expected the backend to stay up, got %+v", status)
+		}
+	})
+
+	t.Run("Degraded", func(t *testing.T) {
+		stub.stop()
+		send("req-3", "NODE.LIST")
+		response := readUntil(t, conn, isReply("req-3"))
+		if response.Type != "error" || response.Code != ErrCodeBackendUnavailable {
+			t.Fatalf("Expected backend_unavailable, got %+v", response)
+		}
+		data, _ := response.Data.(map[string]interface{})
+		if data["state"] != BackendDegraded || data["nextRetry"] == nil {
+			t.Errorf("Expected the status with the next retry time, got %v", response.Data)
+		}
+
+		readUntil(t, conn, isStatus(BackendDegraded))
+		if code, body := health(t, server); code != http.StatusServiceUnavailable || body["status"] != BackendDegraded {
+			t.Errorf("Expected a degraded backend, got %d %v", code, body)
+		}
+
+		// Commands fail fast while degraded
+		send("req-4", "PING")
+		if response := readUntil(t, conn, isReply("req-4")); response.Code != ErrCodeBackendUnavailable {
+			t.Errorf("Expected backend_unavailable, got %+v", response)
+		}
+
+		// Failed probes back off, up to the cap
+		time.Sleep(300 * time.Millisecond)
+		status := proxy.monitor.Status()
+		if status.Attempts == 0 || time.Until(time.UnixMilli(status.NextRetry)) > 100*time.Millisecond {
+			t.Errorf("Expected failed probes with capped backoff, got %+v", status)
+		}
+	})
+
+	t.Run("Recovered", func(t *testing.T) {
+		stub.start(t)
+		readUntil(t, conn, isStatus(BackendUp))
+		send("req-5", "PING")
+		if response := readUntil(t, conn, isReply("req-5")); response.Value != "PONG" {
+			t.Errorf("Expected PONG on the same WebSocket, got %+v", response)
+		}
+		if code, body := health(t, server); code != http.StatusOK || body["status"] != "ok" {
+			t.Errorf("Expected a healthy backend, got %d %v", code, body)
+		}
+	})
+
+	t.Run("Late Client", func(t *testing.T) {
+		stub.stop()
+		send("req-6", "PING")
+		readUntil(t, conn, isReply("req-6"))
+
+		// A client connecting while degraded is told at once
+		late, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("Failed to dial proxy: %v", err)
+		}
+		defer late.Close()
+		readUntil(t, late, isStatus(BackendDegraded))
+	})
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	policy := ReconnectPolicy{InitialBackoff: 250 * time.Millisecond, MaxBackoff: time.Second}
+	backoff := policy.InitialBackoff
+	var waits []time.Duration
+	for i := 0; i < 4; i++ {
+		backoff = policy.nextBackoff(backoff)
+		waits = append(waits, backoff)
+	}
+	expected := []time.Duration{500 * time.Millisecond, time.Second, time.Second, time.Second}
+	for i := range expected {
+		if waits[i] != expected[i] {
+			t.Errorf("Expected waits %v, got %v", expected, waits)
+			break
+		}
+	}
+	if wait := (ReconnectPolicy{MaxBackoff: time.Second}).nextBackoff(0); wait != time.Second {
+		t.Errorf("Expected a zero backoff to wait the cap, got %v", wait)
+	}
+}
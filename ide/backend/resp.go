@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBulkBytes and maxArrayLength reject reply headers no PathwayDB server
+// would send, so a corrupt stream cannot make the proxy allocate without bound
+const (
+	maxBulkBytes   = 512 << 20
+	maxArrayLength = 1 << 24
+)
+
+// respValue is one decoded RESP reply
+type respValue struct {
+	kind  byte // '+', '-', ':', '$' or '*'
+	str   string
+	num   int64
+	elems []*respValue
+	null  bool
+}
+
+// readRESP reads one complete reply, however many reads it spans
+func readRESP(r *bufio.Reader) (*respValue, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty reply line")
+	}
+
+	value := &respValue{kind: line[0]}
+	switch value.kind {
+	case '+', '-':
+		value.str = line[1:]
+	case ':':
+		value.num, err = strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer reply: %q", line)
+		}
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 || n > maxBulkBytes {
+			return nil, fmt.Errorf("invalid bulk length: %q", line)
+		}
+		if n == -1 {
+			value.null = true
+			break
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[n:]) != "\r\n" {
+			return nil, fmt.Errorf("bulk string not terminated by CRLF")
+		}
+		value.str = string(buf[:n])
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 || n > maxArrayLength {
+			return nil, fmt.Errorf("invalid array length: %q", line)
+		}
+		if n == -1 {
+			value.null = true
+			break
+		}
+		value.elems = make([]*respValue, n)
+		for i := range value.elems {
+			if value.elems[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown reply type %q", value.kind)
+	}
+	return value, nil
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", fmt.Errorf("reply line not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+// isNested reports whether an array reply contains arrays
+func (v *respValue) isNested() bool {
+	for _, elem := range v.elems {
+		if elem.kind == '*' && !elem.null {
+			return true
+		}
+	}
+	return false
+}
+
+// plain converts a reply to the JSON value sent to the frontend
+func (v *respValue) plain() interface{} {
+	switch {
+	case v.null:
+		return nil
+	case v.kind == ':':
+		return v.num
+	case v.kind == '*':
+		values := make([]interface{}, len(v.elems))
+		for i, elem := range v.elems {
+			values[i] = elem.plain()
+		}
+		return values
+	default:
+		return v.str
+	}
+}
+
+// strings returns the elements of a flat array reply, or false if the reply
+// is anything else
+func (v *respValue) strings() ([]string, bool) {
+	if v.kind != '*' || v.null {
+		return nil, false
+	}
+	values := make([]string, len(v.elems))
+	for i, elem := range v.elems {
+		if elem.kind != '$' && elem.kind != '+' || elem.null {
+			return nil, false
+		}
+		values[i] = elem.str
+	}
+	return values, true
+}
+
+// newReplyResponse maps a reply to a typed WebSocket response. RESP types map
+// one-to-one: simple strings are "string", bulk strings "bulk", integers
+// "int", errors "error", null bulk strings and arrays "null", and arrays are
+// "array" unless they contain arrays, in which case they are "nested".
+func newReplyResponse(reply *respValue) *WebSocketResponse {
+	response := &WebSocketResponse{
+		Value:     reply.plain(),
+		Timestamp: time.Now().UnixMilli(),
+	}
+	switch {
+	case reply.null:
+		response.Type = "null"
+	case reply.kind == '+':
+		response.Type = "string"
+	case reply.kind == '-':
+		response.Type = "error"
+	case reply.kind == ':':
+		response.Type = "int"
+	case reply.kind == '$':
+		response.Type = "bulk"
+	case reply.isNested():
+		response.Type = "nested"
+	default:
+		response.Type = "array"
+	}
+	return response
+}
+
+// renderReply reads the reply to command and converts it to the response
+// sent to the frontend, adding structured data for recognised replies
+func renderReply(r *bufio.Reader, command string, args []string) (*WebSocketResponse, error) {
+	reply, err := readRESP(r)
+	if err != nil {
+		return nil, err
+	}
+	return newCommandResponse(reply, command, args), nil
+}
+
+// newCommandResponse converts the reply to command to the response sent to
+// the frontend, adding structured data for recognised replies
+func newCommandResponse(reply *respValue, command string, args []string) *WebSocketResponse {
+	response := newReplyResponse(reply)
+	if process, ok := postProcessors[strings.ToUpper(command)]; ok && reply.kind != '-' && !reply.null {
+		if data := process(args, reply); data != nil {
+			response.Data = data
+		}
+	}
+	return response
+}
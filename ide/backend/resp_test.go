@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/redcon"
+)
+
+// renderRecorded renders a recorded reply stream and returns the JSON frame
+// the proxy would send, without its timestamp
+func renderRecorded(t *testing.T, stream, command string, args ...string) []byte {
+	t.Helper()
+	response, err := renderReply(bufio.NewReader(strings.NewReader(stream)), command, args)
+	if err != nil {
+		t.Fatalf("Failed to render reply: %v", err)
+	}
+	response.ID = "req-1"
+	response.Timestamp = 0
+	frame, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+	return frame
+}
+
+// sameJSON reports whether two JSON documents decode to the same value, so
+// frames compare equal regardless of key order and escaping
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var x, y interface{}
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func TestRenderReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   string
+		command  string
+		args     []string
+		expected string
+	}{
+		{
+			name: "Traverse",
+			stream: "*2\r\n" +
+				"$40\r\nurn:a:service->e1:calls->billing:service\r\n" +
+				"$30\r\nc:service<-e2:calls<-a:service\r\n",
+			command: "ANALYSIS.TRAVERSE",
+			args:    []string{"g", "urn:a"},
+			expected: `{"id":"req-1","type":"array",` +
+				`"value":["urn:a:service->e1:calls->billing:service","c:service<-e2:calls<-a:service"],` +
+				`"data":{"paths":[` +
+				`{"nodes":[{"id":"urn:a","type":"service"},{"id":"billing","type":"service"}],"edges":[{"id":"e1","type":"calls","direction":"out"}]},` +
+				`{"nodes":[{"id":"c","type":"service"},{"id":"a","type":"service"}],"edges":[{"id":"e2","type":"calls","direction":"in"}]}` +
+				`]},"timestamp":0}`,
+		},
+		{
+			name:     "TraverseCount",
+			stream:   "*2\r\n$1\r\n1\r\n$31\r\na:service->e1:calls->b:database\r\n",
+			command:  "ANALYSIS.TRAVERSE",
+			args:     []string{"g", "a", "COUNT"},
+			expected: `{"id":"req-1","type":"array","value":["1","a:service->e1:calls->b:database"],"data":{"paths":[{"nodes":[{"id":"a","type":"service"},{"id":"b","type":"database"}],"edges":[{"id":"e1","type":"calls","direction":"out"}]}]},"timestamp":0}`,
+		},
+		{
+			name:     "CyclesNone",
+			stream:   "*0\r\n",
+			command:  "ANALYSIS.CYCLES",
+			args:     []string{"g"},
+			expected: `{"id":"req-1","type":"array","value":[],"data":{"paths":[]},"timestamp":0}`,
+		},
+		{
+			name:     "TraverseSimple",
+			stream:   "*2\r\n$9\r\na:service\r\n$10\r\nb:database\r\n",
+			command:  "analysis.traverse",
+			args:     []string{"g", "a", "FORMAT", "simple"},
+			expected: `{"id":"req-1","type":"array","value":["a:service","b:database"],"data":{"nodes":[{"id":"a","type":"service"},{"id":"b","type":"database"}]},"timestamp":0}`,
+		},
+		{
+			name:     "TraverseFanoutLimited",
+			stream:   "*4\r\n$11\r\nhub:library\r\n$9\r\na:service\r\n$14\r\nfanout_limited\r\n$3\r\nhub\r\n",
+			command:  "ANALYSIS.TRAVERSE",
+			args:     []string{"g", "hub", "FORMAT", "simple", "MAXFANOUT", "1"},
+			expected: `{"id":"req-1","type":"array","value":["hub:library","a:service","fanout_limited","hub"],"data":{"nodes":[{"id":"hub","type":"library"},{"id":"a","type":"service"}],"fanoutLimited":["hub"]},"timestamp":0}`,
+		},
+		{
+			name:     "TraverseWithLabels",
+			stream:   "*1\r\n$11\r\na:service:A\r\n",
+			command:  "ANALYSIS.TRAVERSE",
+			args:     []string{"g", "a", "LABELS"},
+			expected: `{"id":"req-1","type":"array","value":["a:service:A"],"timestamp":0}`,
+		},
+		{
+			name:     "Clustering",
+			stream:   "*2\r\n*2\r\n$9\r\nservice-a\r\n$9\r\nservice-b\r\n*1\r\n$9\r\nservice-c\r\n",
+			command:  "ANALYSIS.CLUSTERING",
+			args:     []string{"g", "louvain"},
+			expected: `{"id":"req-1","type":"nested","value":[["service-a","service-b"],["service-c"]],"data":{"groups":[["service-a","service-b"],["service-c"]]},"timestamp":0}`,
+		},
+		{
+			name:     "NullShortestPath",
+			stream:   "$-1\r\n",
+			command:  "ANALYSIS.SHORTESTPATH",
+			args:     []string{"g", "a", "z"},
+			expected: `{"id":"req-1","type":"null","value":null,"timestamp":0}`,
+		},
+		{
+			name:     "NullArray",
+			stream:   "*-1\r\n",
+			command:  "NODE.LIST",
+			args:     []string{"g"},
+			expected: `{"id":"req-1","type":"null","value":null,"timestamp":0}`,
+		},
+		{
+			name:     "EmptyArray",
+			stream:   "*0\r\n",
+			command:  "NODE.LIST",
+			args:     []string{"g"},
+			expected: `{"id":"req-1","type":"array","value":[],"data":{"nodes":[]},"timestamp":0}`,
+		},
+		{
+			name:     "Error",
+			stream:   "-ERR failed to find shortest path: node not found: z\r\n",
+			command:  "ANALYSIS.SHORTESTPATH",
+			args:     []string{"g", "a", "z"},
+			expected: `{"id":"req-1","type":"error","value":"ERR failed to find shortest path: node not found: z","timestamp":0}`,
+		},
+		{
+			name:     "Int",
+			stream:   ":42\r\n",
+			command:  "GRAPH.DELATTR",
+			args:     []string{"g", "owner"},
+			expected: `{"id":"req-1","type":"int","value":42,"timestamp":0}`,
+		},
+		{
+			name:     "SimpleString",
+			stream:   "+OK\r\n",
+			command:  "GRAPH.CREATE",
+			args:     []string{"g"},
+			expected: `{"id":"req-1","type":"string","value":"OK","timestamp":0}`,
+		},
+		{
+			name:     "CentralityPage",
+			stream:   "*5\r\n$5\r\njob:2\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\na\r\n$1\r\n1\r\n",
+			command:  "ANALYSIS.CENTRALITY",
+			args:     []string{"g", "degree", "PAGE", "0", "COUNT", "2"},
+			expected: `{"id":"req-1","type":"array","value":["job:2","b","2","a","1"],"data":{"cursor":"job:2","ranking":[{"id":"b","score":2},{"id":"a","score":1}]},"timestamp":0}`,
+		},
+		{
+			name: "NodeGet",
+			stream: "*4\r\n$1\r\na\r\n$7\r\nservice\r\n" +
+				"$20\r\n{\"region\":\"eu-west\"}\r\n$0\r\n\r\n",
+			command:  "NODE.GET",
+			args:     []string{"g", "a"},
+			expected: `{"id":"req-1","type":"array","value":["a","service","{\"region\":\"eu-west\"}",""],"data":{"attributes":{"region":"eu-west"},"expiresAt":"","id":"a","type":"service"},"timestamp":0}`,
+		},
+		{
+			name: "MetaList",
+			stream: "*4\r\n$8\r\ncheckout\r\n$15\r\n{\"x\":120,\"y\":8}\r\n" +
+				"$6\r\nledger\r\n$15\r\n{\"x\":300,\"y\":8}\r\n",
+			command:  "META.LIST",
+			args:     []string{"g", "ide-layout"},
+			expected: `{"id":"req-1","type":"array","value":["checkout","{\"x\":120,\"y\":8}","ledger","{\"x\":300,\"y\":8}"],"data":{"entries":{"checkout":{"x":120,"y":8},"ledger":{"x":300,"y":8}}},"timestamp":0}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderRecorded(t, tt.stream, tt.command, tt.args...); !sameJSON(t, got, []byte(tt.expected)) {
+				t.Errorf("Unexpected frame\n got: %s\nwant: %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadRESP(t *testing.T) {
+	t.Run("SpansReads", func(t *testing.T) {
+		// A reply larger than any single read must still be read whole
+		value := strings.Repeat("x", 10000)
+		stream := "*2\r\n$10000\r\n" + value + "\r\n$1\r\ny\r\n"
+		reader := bufio.NewReaderSize(strings.NewReader(stream), 16)
+		reply, err := readRESP(reader)
+		if err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+		values, ok := reply.strings()
+		if !ok || len(values) != 2 || values[0] != value || values[1] != "y" {
+			t.Errorf("Unexpected reply: %d values", len(values))
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, stream := range []string{
+			"",
+			"?1\r\n",
+			"$5\r\nab\r\n",
+			"$3\r\nabcd\r\n",
+			":x\r\n",
+			"*2\r\n$1\r\na\r\n",
+			"+OK\n",
+		} {
+			if _, err := readRESP(bufio.NewReader(strings.NewReader(stream))); err == nil {
+				t.Errorf("Expected an error for %q", stream)
+			}
+		}
+	})
+}
+
+// startRedconServer serves replies too large for one read and nested
+// several levels deep from a real RESP server: BIG replies with a 1MB bulk
+// string holding CRLFs, NESTED with a three-level array holding a null, and
+// NULL with a null bulk string
+func startRedconServer(t *testing.T, big string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go redcon.Serve(listener, func(conn redcon.Conn, cmd redcon.Command) {
+		switch strings.ToUpper(string(cmd.Args[0])) {
+		case "BIG":
+			conn.WriteBulkString(big)
+		case "NESTED":
+			conn.WriteArray(2)
+			conn.WriteArray(2)
+			conn.WriteArray(2)
+			conn.WriteBulkString("a")
+			conn.WriteBulkString("b\r\nc")
+			conn.WriteNull()
+			conn.WriteBulkString("d")
+		case "NULL":
+			conn.WriteNull()
+		default:
+			conn.WriteError("ERR unknown command")
+		}
+	}, nil, nil)
+	return listener.Addr().String()
+}
+
+func TestProxyReplies(t *testing.T) {
+	big := strings.Repeat("0123456789abcd\r\n", 1<<20/16)
+	proxy := NewRedisProxy(startRedconServer(t, big))
+	t.Cleanup(proxy.Close)
+
+	// Each reply is read whole, leaving the pooled connection in step for
+	// the next command
+	for i := 0; i < 2; i++ {
+		response, err := proxy.ExecuteCommand("BIG", nil)
+		if err != nil {
+			t.Fatalf("BIG failed: %v", err)
+		}
+		if value, _ := response.Value.(string); response.Type != "bulk" || value != big {
+			t.Fatalf("Expected the 1MB bulk string, got a %s reply of %d bytes", response.Type, len(value))
+		}
+
+		response, err = proxy.ExecuteCommand("NESTED", nil)
+		if err != nil {
+			t.Fatalf("NESTED failed: %v", err)
+		}
+		expected := []interface{}{[]interface{}{[]interface{}{"a", "b\r\nc"}, nil}, "d"}
+		if response.Type != "nested" || !reflect.DeepEqual(response.Value, expected) {
+			t.Errorf("Expected nested %v, got %s %v", expected, response.Type, response.Value)
+		}
+		frame, err := json.Marshal(response.Value)
+		if err != nil || string(frame) != `[[["a","b\r\nc"],null],"d"]` {
+			t.Errorf("Expected nested JSON arrays, got %s, %v", frame, err)
+		}
+
+		response, err = proxy.ExecuteCommand("NULL", nil)
+		if err != nil || response.Type != "null" || response.Value != nil {
+			t.Errorf("Expected a null reply, got %+v, %v", response, err)
+		}
+	}
+}
@@ -7,7 +7,7 @@ import Sidebar from './components/Sidebar';
 import PropertiesPanel from './components/PropertiesPanel';
 import DocumentationPage from './components/DocumentationPage';
 import { RedisWebSocket } from './services/RedisWebSocket';
-import { Graph, GraphNode, GraphEdge, ConnectionStatus, RedisResponse } from './types';
+import { Graph, GraphNode, GraphEdge, ConnectionStatus, RedisResponse, BackendStatus } from './types';
 
 const App: React.FC = () => {
   const [redisClient] = useState(() => new RedisWebSocket());
@@ -21,6 +21,7 @@ const App: React.FC = () => {
   const [selectedNode, setSelectedNode] = useState<GraphNode | null>(null);
   const [selectedEdge, setSelectedEdge] = useState<GraphEdge | null>(null);
   const [error, setError] = useState<string | null>(null);
+  const [backendStatus, setBackendStatus] = useState<BackendStatus | null>(null);
   const [showDocs, setShowDocs] = useState<boolean>(false);
   const [propertiesHeight, setPropertiesHeight] = useState<number>(30); // Percentage
   const [sidebarCollapsed, setSidebarCollapsed] = useState<boolean>(false);
@@ -29,6 +30,7 @@ const App: React.FC = () => {
   useEffect(() => {
     redisClient.onConnectionChange = setConnectionStatus;
     redisClient.onError = setError;
+    redisClient.onBackendStatus = setBackendStatus;
 
     const connect = async () => {
       try {
@@ -83,19 +85,20 @@ const App: React.FC = () => {
     if (!redisClient.isConnected()) return;
     try {
       // Load nodes and edges for the specific graph
-      const [nodeList, edgeList] = await Promise.all([
+      const [nodeList, edgeList, layout] = await Promise.all([
         redisClient.listNodes(graphId).catch(() => []),
-        redisClient.listEdges(graphId).catch(() => [])
+        redisClient.listEdges(graphId).catch(() => []),
+        redisClient.getLayout(graphId).catch(() => ({}) as Record<string, { x: number; y: number }>)
       ]);
 
       // Convert to proper format
       const graphNodes: GraphNode[] = [];
       const graphEdges: GraphEdge[] = [];
 
-      // Process nodes - NODE.LIST now returns [id:type, id:type, ...]
+      // Process nodes - the backend splits NODE.LIST entries into { id, type }
       for (let j = 0; j < nodeList.length; j++) {
         if (nodeList[j]) {
-          const [nodeId, nodeType] = nodeList[j].split(':');
+          const { id: nodeId, type: nodeType } = nodeList[j];
           if (!nodeId) continue;
           
           // Get detailed node info
@@ -123,23 +126,25 @@ const App: React.FC = () => {
               id: nodeId,
               type: nodeType || 'default',
               attributes,
-              expiresAt
+              expiresAt,
+              position: layout[nodeId]
             });
           } catch (e) {
             // If we can't get details, add basic node
             graphNodes.push({
               id: nodeId,
               type: nodeType || 'default',
-              attributes: {}
+              attributes: {},
+              position: layout[nodeId]
             });
           }
         }
       }
 
-      // Process edges - EDGE.LIST now returns [id:type, id:type, ...]
+      // Process edges - the backend splits EDGE.LIST entries into { id, type }
       for (let j = 0; j < edgeList.length; j++) {
         if (edgeList[j]) {
-          const [edgeId, edgeType] = edgeList[j].split(':');
+          const { id: edgeId, type: edgeType } = edgeList[j];
           if (!edgeId) continue;
           
           // Get detailed edge info to find source and target
@@ -298,6 +303,14 @@ const App: React.FC = () => {
     setSelectedNode(null);
   }, []);
 
+  // Dragged node positions are saved so the layout survives a reload
+  const handleNodeMoved = useCallback((nodeId: string, position: { x: number; y: number }) => {
+    if (!selectedGraph || !redisClient.isConnected()) return;
+    redisClient.saveNodePosition(selectedGraph, nodeId, position).catch((error) => {
+      console.warn(`Failed to save position of ${nodeId}:`, error);
+    });
+  }, [redisClient, selectedGraph]);
+
   const handleOpenDocumentation = useCallback(() => {
     // Store current graph selection for restoration later
     if (selectedGraph) {
@@ -369,6 +382,13 @@ const App: React.FC = () => {
         </Toolbar>
       </AppBar>
 
+      {backendStatus?.state === 'degraded' && (
+        <Alert severity="warning" sx={{ borderRadius: 0 }}>
+          PathwayDB server unreachable{backendStatus.error ? ` (${backendStatus.error})` : ''}. Retrying
+          {backendStatus.nextRetry ? ` at ${new Date(backendStatus.nextRetry).toLocaleTimeString()}` : ''}; commands fail until it is back.
+        </Alert>
+      )}
+
       {/* Main Content */}
       <Box sx={{ flexGrow: 1, display: 'flex', overflow: 'hidden' }}>
         <Grid container sx={{ height: '100%' }}>
@@ -424,6 +444,7 @@ const App: React.FC = () => {
                 edges={currentGraph?.edges || []}
                 onNodeSelect={handleNodeSelect}
                 onEdgeSelect={handleEdgeSelect}
+                onNodeMoved={handleNodeMoved}
                 onRefresh={loadGraphList}
               />
             </Box>
@@ -22,6 +22,7 @@ interface GraphVisualizationProps {
   edges: GraphEdge[];
   onNodeSelect?: (node: GraphNode | null) => void;
   onEdgeSelect?: (edge: GraphEdge | null) => void;
+  onNodeMoved?: (nodeId: string, position: { x: number; y: number }) => void;
   onRefresh?: () => void;
 }
 
@@ -30,11 +31,14 @@ const GraphVisualization: React.FC<GraphVisualizationProps> = ({
   edges,
   onNodeSelect,
   onEdgeSelect,
+  onNodeMoved,
   onRefresh
 }) => {
   const containerRef = useRef<HTMLDivElement>(null);
   const cyRef = useRef<Core | null>(null);
   const [layout, setLayout] = useState<'force' | 'hierarchical'>('force');
+  // Set once the user picks a layout, which then wins over saved positions
+  const layoutChosenRef = useRef(false);
   
   // Cleanup function to properly destroy Cytoscape instance
   const cleanupCytoscape = useCallback(() => {
@@ -134,6 +138,7 @@ const GraphVisualization: React.FC<GraphVisualizationProps> = ({
     
     // Remove existing handlers
     cy.off('tap');
+    cy.off('dragfree');
 
     // Event handlers
     cy.on('tap', 'node', (evt) => {
@@ -157,7 +162,12 @@ const GraphVisualization: React.FC<GraphVisualizationProps> = ({
         onEdgeSelect?.(null);
       }
     });
-  }, [nodes, edges, onNodeSelect, onEdgeSelect]);
+
+    cy.on('dragfree', 'node', (evt) => {
+      const node = evt.target;
+      onNodeMoved?.(node.id(), node.position());
+    });
+  }, [nodes, edges, onNodeSelect, onEdgeSelect, onNodeMoved]);
 
   useEffect(() => {
     if (!cyRef.current) return;
@@ -187,6 +197,12 @@ const GraphVisualization: React.FC<GraphVisualizationProps> = ({
     cyRef.current.elements().remove();
     cyRef.current.add(elements);
     
+    // Keep saved positions when every node has one; otherwise lay out again
+    if (!layoutChosenRef.current && nodes.length > 0 && nodes.every(node => node.position)) {
+      cyRef.current.layout({ name: 'preset', fit: true, padding: 50 } as any).run();
+      return;
+    }
+
     // Apply layout
     const layoutName = layout === 'hierarchical' ? 'dagre' : 'cose';
     const layoutOptions: any = {
@@ -232,6 +248,7 @@ const GraphVisualization: React.FC<GraphVisualizationProps> = ({
   };
 
   const handleToggleLayout = () => {
+    layoutChosenRef.current = true;
     setLayout(prev => prev === 'force' ? 'hierarchical' : 'force');
   };
 
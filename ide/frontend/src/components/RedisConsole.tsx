@@ -107,7 +107,17 @@ const RedisConsole: React.FC<RedisConsoleProps> = ({ onExecuteCommand, connected
     switch (response.type) {
       case 'array':
         if (Array.isArray(response.value)) {
-          return response.value.map((item, index) => `${index + 1}) ${item}`).join('\n');
+          return response.value.map((item, index) => `${index + 1}) ${item === null ? '(nil)' : item}`).join('\n');
+        }
+        return String(response.value);
+      case 'nested':
+        if (Array.isArray(response.value)) {
+          return response.value.map((item, index) => {
+            const inner = Array.isArray(item)
+              ? item.map((sub, subIndex) => `${subIndex > 0 ? '   ' : ''}${subIndex + 1}) ${sub}`).join('\n')
+              : String(item);
+            return `${index + 1}) ${inner}`;
+          }).join('\n');
         }
         return String(response.value);
       case 'null':
@@ -1,4 +1,7 @@
-import { RedisResponse, ConnectionStatus } from '../types';
+import { RedisResponse, ConnectionStatus, EntityRef, BackendStatus } from '../types';
+
+// LAYOUT_NAMESPACE is the META namespace node positions are saved in
+const LAYOUT_NAMESPACE = 'ide-layout';
 
 export class RedisWebSocket {
   private ws: WebSocket | null = null;
@@ -13,6 +16,7 @@ export class RedisWebSocket {
   public onConnectionChange: ((status: ConnectionStatus) => void) | null = null;
   public onResponse: ((response: RedisResponse) => void) | null = null;
   public onError: ((error: string) => void) | null = null;
+  public onBackendStatus: ((status: BackendStatus) => void) | null = null;
 
   constructor() {
     // Use environment variable if available, otherwise fall back to current behavior
@@ -103,7 +107,10 @@ export class RedisWebSocket {
   }
 
   private handleMessage(data: any): void {
-    if (data.id && this.commandQueue.has(data.id)) {
+    if (data.type === 'status') {
+      // The backend lost or regained its Redis connection
+      this.onBackendStatus?.(data.data);
+    } else if (data.id && this.commandQueue.has(data.id)) {
       // This is a response to a specific command
       const resolver = this.commandQueue.get(data.id);
       this.commandQueue.delete(data.id);
@@ -111,6 +118,7 @@ export class RedisWebSocket {
       const response: RedisResponse = {
         type: data.type || 'string',
         value: data.value,
+        data: data.data,
         timestamp: Date.now()
       };
       
@@ -120,6 +128,7 @@ export class RedisWebSocket {
       const response: RedisResponse = {
         type: data.type || 'string',
         value: data.value,
+        data: data.data,
         timestamp: Date.now()
       };
       
@@ -187,9 +196,9 @@ export class RedisWebSocket {
   }
 
   // Node commands
-  public async listNodes(graphId: string): Promise<string[]> {
+  public async listNodes(graphId: string): Promise<EntityRef[]> {
     const response = await this.executeCommand('NODE.LIST', [graphId]);
-    return response.value || [];
+    return response.data?.nodes || [];
   }
 
   public async getNode(graphId: string, nodeId: string): Promise<any> {
@@ -198,13 +207,24 @@ export class RedisWebSocket {
   }
 
   // Edge commands
-  public async listEdges(graphId: string): Promise<string[]> {
+  public async listEdges(graphId: string): Promise<EntityRef[]> {
     const response = await this.executeCommand('EDGE.LIST', [graphId]);
-    return response.value || [];
+    return response.data?.edges || [];
   }
 
   public async getEdge(graphId: string, edgeId: string): Promise<any> {
     const response = await this.executeCommand('EDGE.GET', [graphId, edgeId]);
     return response.value;
   }
+
+  // Layout commands - node positions are kept as graph metadata keyed by node ID
+  public async getLayout(graphId: string): Promise<Record<string, { x: number; y: number }>> {
+    const response = await this.executeCommand('META.LIST', [graphId, LAYOUT_NAMESPACE]);
+    return response.data?.entries || {};
+  }
+
+  public async saveNodePosition(graphId: string, nodeId: string, position: { x: number; y: number }): Promise<void> {
+    const value = JSON.stringify({ x: Math.round(position.x), y: Math.round(position.y) });
+    await this.executeCommand('META.SET', [graphId, LAYOUT_NAMESPACE, nodeId, value]);
+  }
 }
@@ -31,11 +31,21 @@ export interface RedisCommand {
 }
 
 export interface RedisResponse {
-  type: 'string' | 'int' | 'array' | 'bulk' | 'null' | 'error';
+  type: 'string' | 'int' | 'array' | 'nested' | 'bulk' | 'null' | 'error' | 'status';
+  code?: 'message_too_large' | 'invalid_message' | 'backend_unavailable';
   value: any;
+  // Structured form of recognised replies, e.g. { paths: [...] } for
+  // ANALYSIS.TRAVERSE or { nodes: EntityRef[] } for NODE.LIST
+  data?: any;
   timestamp: number;
 }
 
+// A node or edge as returned in id:type form, split by the backend
+export interface EntityRef {
+  id: string;
+  type: string;
+}
+
 export interface ConsoleEntry {
   id: string;
   command: string;
@@ -44,6 +54,16 @@ export interface ConsoleEntry {
   status: 'pending' | 'success' | 'error';
 }
 
+// The state of the backend's connection to Redis, pushed in status frames
+// and attached to backend_unavailable errors. Times are Unix milliseconds.
+export interface BackendStatus {
+  state: 'up' | 'degraded';
+  error?: string;
+  attempts?: number;
+  nextRetry?: number;
+  since: number;
+}
+
 export interface ConnectionStatus {
   connected: boolean;
   host: string;
@@ -0,0 +1,224 @@
+// Package memgraph provides an in-memory graph for testing graph algorithms
+// without a database. A Graph answers the reads analysis.GraphReader makes
+// as the Badger engine does: lists are sorted by ID and missing nodes fail
+// with storage.ErrNodeNotFound.
+package memgraph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+const (
+	// DefaultNodeType is the type of nodes an edge list gives no type
+	DefaultNodeType models.NodeType = "node"
+	// DefaultEdgeType is the type of edges an edge list gives no type
+	DefaultEdgeType models.EdgeType = "edge"
+)
+
+// Graph is one graph held in memory. Reads of any other graph find nothing.
+type Graph struct {
+	id       models.GraphID
+	nodes    map[models.NodeID]*models.Node
+	edges    map[models.EdgeID]*models.Edge
+	outgoing map[models.NodeID][]*models.Edge
+	incoming map[models.NodeID][]*models.Edge
+}
+
+// New creates an empty graph with the ID graphID
+func New(graphID models.GraphID) *Graph {
+	return &Graph{
+		id:       graphID,
+		nodes:    make(map[models.NodeID]*models.Node),
+		edges:    make(map[models.EdgeID]*models.Edge),
+		outgoing: make(map[models.NodeID][]*models.Edge),
+		incoming: make(map[models.NodeID][]*models.Edge),
+	}
+}
+
+// LoadFromEdgeList builds a graph from a compact edge list. Entries are
+// separated by commas or newlines. An entry is a node, id or id:type, or a
+// chain of nodes joined by -> or -[edge_type]->:
+//
+//	app:application -[depends_on]-> auth:service -> db, cache
+//
+// Nodes without a type get DefaultNodeType, and edges DefaultEdgeType. An
+// edge gets the ID from-to-type, as GRAPH.PATTERN names them.
+func LoadFromEdgeList(graphID models.GraphID, list string) (*Graph, error) {
+	g := New(graphID)
+	entries := strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		// Each part but the last is a node and the type of the edge after it
+		var previous models.NodeID
+		var edgeType models.EdgeType
+		parts := strings.Split(entry, "->")
+		for i, part := range parts {
+			nextType := DefaultEdgeType
+			if i < len(parts)-1 {
+				part = strings.TrimSpace(part)
+				if start := strings.LastIndex(part, "-["); start >= 0 && strings.HasSuffix(part, "]") {
+					nextType = models.EdgeType(part[start+2 : len(part)-1])
+					part = part[:start]
+				}
+			}
+			node, err := g.addListedNode(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("entry %q: %w", entry, err)
+			}
+			if i > 0 {
+				edge := &models.Edge{
+					ID:         models.EdgeID(fmt.Sprintf("%s-%s-%s", previous, node.ID, edgeType)),
+					FromNodeID: previous,
+					ToNodeID:   node.ID,
+					Type:       edgeType,
+					Attributes: make(map[string]interface{}),
+				}
+				if err := g.AddEdge(edge); err != nil {
+					return nil, fmt.Errorf("entry %q: %w", entry, err)
+				}
+			}
+			previous, edgeType = node.ID, nextType
+		}
+	}
+	return g, nil
+}
+
+// addListedNode adds the node an edge list names as id or id:type, or
+// returns the node already added. A node named without a type may be given
+// one later, but a typed node cannot be given another.
+func (g *Graph) addListedNode(spec string) (*models.Node, error) {
+	id, nodeType, typed := strings.Cut(spec, ":")
+	if id == "" {
+		return nil, fmt.Errorf("missing node ID")
+	}
+	if existing, ok := g.nodes[models.NodeID(id)]; ok {
+		if typed && existing.Type != models.NodeType(nodeType) {
+			if existing.Type != DefaultNodeType {
+				return nil, fmt.Errorf("node %s is given types %s and %s", id, existing.Type, nodeType)
+			}
+			existing.Type = models.NodeType(nodeType)
+		}
+		return existing, nil
+	}
+	node := &models.Node{ID: models.NodeID(id), Type: DefaultNodeType, Attributes: make(map[string]interface{})}
+	if typed {
+		node.Type = models.NodeType(nodeType)
+	}
+	if err := g.AddNode(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// AddNode adds node to the graph, replacing a node with its ID
+func (g *Graph) AddNode(node *models.Node) error {
+	if err := node.Validate(); err != nil {
+		return err
+	}
+	g.nodes[node.ID] = node
+	return nil
+}
+
+// AddEdge adds edge to the graph. Both of its nodes must have been added,
+// and its ID must be new.
+func (g *Graph) AddEdge(edge *models.Edge) error {
+	if err := edge.Validate(); err != nil {
+		return err
+	}
+	if _, exists := g.edges[edge.ID]; exists {
+		return fmt.Errorf("edge %s already exists", edge.ID)
+	}
+	if _, ok := g.nodes[edge.FromNodeID]; !ok {
+		return fmt.Errorf("source node does not exist: %w: %s", storage.ErrNodeNotFound, edge.FromNodeID)
+	}
+	if _, ok := g.nodes[edge.ToNodeID]; !ok {
+		return fmt.Errorf("target node does not exist: %w: %s", storage.ErrNodeNotFound, edge.ToNodeID)
+	}
+	g.edges[edge.ID] = edge
+	g.outgoing[edge.FromNodeID] = insertSorted(g.outgoing[edge.FromNodeID], edge)
+	g.incoming[edge.ToNodeID] = insertSorted(g.incoming[edge.ToNodeID], edge)
+	return nil
+}
+
+// insertSorted inserts edge into edges, which are sorted by ID
+func insertSorted(edges []*models.Edge, edge *models.Edge) []*models.Edge {
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].ID >= edge.ID })
+	edges = append(edges, nil)
+	copy(edges[i+1:], edges[i:])
+	edges[i] = edge
+	return edges
+}
+
+// GetNode returns a node, or fails with storage.ErrNodeNotFound
+func (g *Graph) GetNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, error) {
+	node, ok := g.nodes[nodeID]
+	if graphID != g.id || !ok || node.IsExpired() {
+		return nil, fmt.Errorf("%w: %s", storage.ErrNodeNotFound, nodeID)
+	}
+	return node, nil
+}
+
+// ListNodes returns the nodes sorted by ID
+func (g *Graph) ListNodes(graphID models.GraphID) ([]*models.Node, error) {
+	if graphID != g.id {
+		return nil, nil
+	}
+	var nodes []*models.Node
+	for _, node := range g.nodes {
+		if !node.IsExpired() {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// ListEdges returns the edges sorted by ID
+func (g *Graph) ListEdges(graphID models.GraphID) ([]*models.Edge, error) {
+	if graphID != g.id {
+		return nil, nil
+	}
+	var edges []*models.Edge
+	for _, edge := range g.edges {
+		if !edge.IsExpired() {
+			edges = append(edges, edge)
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].ID < edges[j].ID })
+	return edges, nil
+}
+
+// GetOutgoingEdges returns the edges leaving a node sorted by ID
+func (g *Graph) GetOutgoingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	if graphID != g.id {
+		return nil, nil
+	}
+	return unexpired(g.outgoing[nodeID]), nil
+}
+
+// GetIncomingEdges returns the edges entering a node sorted by ID
+func (g *Graph) GetIncomingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	if graphID != g.id {
+		return nil, nil
+	}
+	return unexpired(g.incoming[nodeID]), nil
+}
+
+// unexpired returns the edges that have not expired, in a new slice so
+// callers cannot reorder the graph's own
+func unexpired(edges []*models.Edge) []*models.Edge {
+	var kept []*models.Edge
+	for _, edge := range edges {
+		if !edge.IsExpired() {
+			kept = append(kept, edge)
+		}
+	}
+	return kept
+}
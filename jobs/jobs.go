@@ -0,0 +1,382 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State is the lifecycle state of a job
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateDone      State = "done"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Func is the work performed by a job. It should return promptly once ctx is
+// cancelled and may report progress through the given counters.
+type Func func(ctx context.Context, progress *Progress) (interface{}, error)
+
+// Config bounds the resources used by a Manager
+type Config struct {
+	// Workers is the number of jobs that run at the same time
+	Workers int
+
+	// MaxActive is the number of queued plus running jobs accepted before
+	// Submit starts rejecting new ones
+	MaxActive int
+
+	// ResultTTL is how long a finished job and its result are kept
+	ResultTTL time.Duration
+
+	// MaxResults caps the number of finished jobs kept; the oldest are
+	// evicted first
+	MaxResults int
+}
+
+// DefaultConfig returns the configuration used when none is given
+func DefaultConfig() Config {
+	return Config{
+		Workers:    2,
+		MaxActive:  16,
+		ResultTTL:  10 * time.Minute,
+		MaxResults: 100,
+	}
+}
+
+// Progress holds counters a job can update while it runs
+type Progress struct {
+	done  atomic.Int64
+	total atomic.Int64
+}
+
+// Add increments the number of completed work units
+func (p *Progress) Add(n int64) {
+	p.done.Add(n)
+}
+
+// SetTotal sets the expected number of work units, if known
+func (p *Progress) SetTotal(n int64) {
+	p.total.Store(n)
+}
+
+// Status is a point-in-time view of a job
+type Status struct {
+	ID         string
+	Command    string
+	State      State
+	Done       int64
+	Total      int64
+	Err        error
+	Result     interface{}
+	CreatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// job is the manager's record of a submitted job
+type job struct {
+	id         string
+	command    string
+	fn         Func
+	state      State
+	progress   Progress
+	result     interface{}
+	err        error
+	ctx        context.Context
+	cancel     context.CancelFunc
+	createdAt  time.Time
+	finishedAt time.Time
+}
+
+// Manager runs jobs on a bounded worker pool and keeps their results
+type Manager struct {
+	config Config
+	start  sync.Once
+	mu     sync.Mutex
+	ready  *sync.Cond // Signalled when a job is queued or the manager closes
+	queue  []*job     // Queued jobs, oldest first
+	jobs   map[string]*job
+	active int
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewManager creates a job manager. Workers start on the first Submit.
+func NewManager(config Config) *Manager {
+	defaults := DefaultConfig()
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.MaxActive <= 0 {
+		config.MaxActive = defaults.MaxActive
+	}
+	if config.ResultTTL <= 0 {
+		config.ResultTTL = defaults.ResultTTL
+	}
+	if config.MaxResults <= 0 {
+		config.MaxResults = defaults.MaxResults
+	}
+	m := &Manager{
+		config: config,
+		jobs:   make(map[string]*job),
+	}
+	m.ready = sync.NewCond(&m.mu)
+	return m
+}
+
+// Submit queues fn and returns the new job's ID
+func (m *Manager) Submit(command string, fn Func) (string, error) {
+	m.start.Do(func() {
+		for i := 0; i < m.config.Workers; i++ {
+			m.wg.Add(1)
+			go m.worker()
+		}
+	})
+
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return "", fmt.Errorf("job manager is closed")
+	}
+	m.prune(time.Now())
+	if m.active >= m.config.MaxActive {
+		return "", fmt.Errorf("too many active jobs (max %d)", m.config.MaxActive)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:        id,
+		command:   command,
+		fn:        fn,
+		state:     StateQueued,
+		ctx:       ctx,
+		cancel:    cancel,
+		createdAt: time.Now(),
+	}
+	m.jobs[id] = j
+	m.active++
+	m.queue = append(m.queue, j)
+	m.ready.Signal()
+
+	return id, nil
+}
+
+// Store records a result computed outside the worker pool as a finished
+// job, so it can be fetched with Status and shares the same TTL and result
+// limit as submitted jobs. It returns the new job's ID.
+func (m *Manager) Store(command string, result interface{}) (string, error) {
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return "", fmt.Errorf("job manager is closed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	now := time.Now()
+	m.jobs[id] = &job{
+		id:         id,
+		command:    command,
+		state:      StateDone,
+		result:     result,
+		ctx:        ctx,
+		cancel:     cancel,
+		createdAt:  now,
+		finishedAt: now,
+	}
+	m.prune(now)
+
+	return id, nil
+}
+
+// Lookup returns the ID of the most recently finished successful job for
+// command, if its result is still kept
+func (m *Manager) Lookup(command string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(time.Now())
+	var latest *job
+	for _, j := range m.jobs {
+		if j.command != command || j.state != StateDone {
+			continue
+		}
+		if latest == nil || j.finishedAt.After(latest.finishedAt) {
+			latest = j
+		}
+	}
+	if latest == nil {
+		return "", false
+	}
+	return latest.id, true
+}
+
+// Status returns the current state of a job
+func (m *Manager) Status(id string) (*Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(time.Now())
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+
+	return &Status{
+		ID:         j.id,
+		Command:    j.command,
+		State:      j.state,
+		Done:       j.progress.done.Load(),
+		Total:      j.progress.total.Load(),
+		Err:        j.err,
+		Result:     j.result,
+		CreatedAt:  j.createdAt,
+		FinishedAt: j.finishedAt,
+	}, nil
+}
+
+// Cancel aborts a queued or running job. Cancelling a finished job is a no-op.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	j.cancel()
+	if j.state == StateQueued {
+		m.dequeue(j)
+		m.finish(j, StateCancelled, nil, context.Canceled)
+	}
+	return nil
+}
+
+// dequeue removes a queued job from the queue. Callers must hold m.mu.
+func (m *Manager) dequeue(j *job) {
+	for i, queued := range m.queue {
+		if queued == j {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// Close cancels all jobs and waits for the workers to exit
+func (m *Manager) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	for _, j := range m.jobs {
+		j.cancel()
+	}
+	for _, j := range m.queue {
+		m.finish(j, StateCancelled, nil, context.Canceled)
+	}
+	m.queue = nil
+	m.ready.Broadcast()
+	m.mu.Unlock()
+
+	m.wg.Wait()
+}
+
+// worker runs queued jobs until the manager is closed
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	for {
+		m.mu.Lock()
+		for len(m.queue) == 0 && !m.closed {
+			m.ready.Wait()
+		}
+		if m.closed {
+			m.mu.Unlock()
+			return
+		}
+		j := m.queue[0]
+		m.queue = m.queue[1:]
+		j.state = StateRunning
+		m.mu.Unlock()
+
+		result, err := j.fn(j.ctx, &j.progress)
+
+		m.mu.Lock()
+		switch {
+		case j.ctx.Err() != nil:
+			m.finish(j, StateCancelled, nil, context.Canceled)
+		case err != nil:
+			m.finish(j, StateFailed, nil, err)
+		default:
+			m.finish(j, StateDone, result, nil)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// finish records a job's outcome. Callers must hold m.mu.
+func (m *Manager) finish(j *job, state State, result interface{}, err error) {
+	j.state = state
+	j.result = result
+	j.err = err
+	j.finishedAt = time.Now()
+	j.cancel()
+	m.active--
+}
+
+// prune drops finished jobs past their TTL and the oldest finished jobs over
+// the result limit. Callers must hold m.mu.
+func (m *Manager) prune(now time.Time) {
+	var finished []*job
+	for id, j := range m.jobs {
+		if j.finishedAt.IsZero() {
+			continue
+		}
+		if now.Sub(j.finishedAt) > m.config.ResultTTL {
+			delete(m.jobs, id)
+			continue
+		}
+		finished = append(finished, j)
+	}
+
+	if excess := len(finished) - m.config.MaxResults; excess > 0 {
+		sort.Slice(finished, func(i, k int) bool {
+			return finished[i].finishedAt.Before(finished[k].finishedAt)
+		})
+		for _, j := range finished[:excess] {
+			delete(m.jobs, j.id)
+		}
+	}
+}
+
+// newJobID returns a random job identifier
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return "job-" + hex.EncodeToString(b), nil
+}
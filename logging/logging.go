@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseLevel converts a level name (debug, info, warn, error) to a slog.Level
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid log level: %s", name)
+	}
+}
+
+// New returns a logger that writes through the standard log package, so lines
+// keep the familiar "2006/01/02 15:04:05 message" shape with the level and
+// structured fields added: "2006/01/02 15:04:05 INFO message key=value".
+func New(level slog.Leveler) *slog.Logger {
+	return slog.New(&Handler{
+		out:   log.New(log.Writer(), log.Prefix(), log.Flags()),
+		level: level,
+	})
+}
+
+// NewWriter returns a logger like New that writes to w instead of the
+// standard log package's output
+func NewWriter(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(&Handler{
+		out:   log.New(w, "", log.LstdFlags),
+		level: level,
+	})
+}
+
+// Default returns an info-level logger used when no logger is configured
+func Default() *slog.Logger {
+	return New(slog.LevelInfo)
+}
+
+// Handler is a slog.Handler that formats records as single log lines
+type Handler struct {
+	out    *log.Logger
+	level  slog.Leveler
+	prefix string // pre-rendered attributes from WithAttrs
+	group  string
+}
+
+// Enabled reports whether records at the given level are written
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle writes a record as "LEVEL message key=value ..."
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Level.String())
+	b.WriteByte(' ')
+	b.WriteString(r.Message)
+	b.WriteString(h.prefix)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	return h.out.Output(2, b.String())
+}
+
+// WithAttrs returns a handler that includes attrs on every record
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.prefix)
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	clone := *h
+	clone.prefix = b.String()
+	return &clone
+}
+
+// WithGroup returns a handler that qualifies subsequent keys with name
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	if clone.group != "" {
+		clone.group += "."
+	}
+	clone.group += name
+	return &clone
+}
+
+// appendAttr renders a single key=value pair, quoting values with spaces
+func appendAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	key := a.Key
+	if group != "" {
+		key = group + "." + key
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, key, ga)
+		}
+		return
+	}
+
+	var value string
+	switch a.Value.Kind() {
+	case slog.KindDuration:
+		value = a.Value.Duration().Round(time.Microsecond).String()
+	default:
+		value = a.Value.String()
+	}
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(value)
+}
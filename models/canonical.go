@@ -0,0 +1,147 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// CanonicalJSON serializes v with object keys sorted, no insignificant
+// whitespace and each number in a single form, so equal values always
+// serialize to the same bytes
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(canonicalNumbers(generic))
+}
+
+// canonicalNumbers rewrites the numbers of a decoded JSON value in place
+func canonicalNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = canonicalNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = canonicalNumbers(item)
+		}
+	case json.Number:
+		return canonicalNumber(value)
+	}
+	return v
+}
+
+// canonicalNumber keeps integers exact and writes every other number the way
+// encoding/json formats a float64, so 1.0, 1e0 and 1 all become 1
+func canonicalNumber(n json.Number) json.Number {
+	if _, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return n
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return n
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return n
+	}
+	return json.Number(data)
+}
+
+// NormalizeValue converts a value to the form encoding/json decodes it into:
+// objects become map[string]interface{}, arrays []interface{} and numbers
+// float64. Values that cannot be serialized are returned unchanged.
+func NormalizeValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return v
+	}
+	return normalized
+}
+
+// ValuesEqual reports whether two attribute values are equal once
+// normalized, regardless of their Go types
+func ValuesEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(NormalizeValue(a), NormalizeValue(b))
+}
+
+// MatchValue reports whether an attribute value matches target, as the
+// attribute filters compare them: numbers by value whatever their Go type,
+// so an int 8080 matches a float64 8080 or a json.Number "8080", booleans
+// and strings only each other, and objects and arrays by ValuesEqual
+func MatchValue(value, target interface{}) bool {
+	if a, ok := numberValue(value); ok {
+		b, ok := numberValue(target)
+		return ok && a == b
+	}
+	switch v := value.(type) {
+	case string:
+		t, ok := target.(string)
+		return ok && v == t
+	case bool:
+		t, ok := target.(bool)
+		return ok && v == t
+	case nil:
+		return target == nil
+	}
+	return ValuesEqual(value, target)
+}
+
+// numberValue returns a number of any Go numeric type, or a json.Number, as
+// a float64, the type encoding/json decodes numbers into
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// AttributesEqual compares attribute maps semantically, treating nil and
+// empty as equal
+func AttributesEqual(a, b Attributes) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return ValuesEqual(map[string]interface{}(a), map[string]interface{}(b))
+}
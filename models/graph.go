@@ -1,7 +1,11 @@
 package models
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"io"
+	"strings"
 	"time"
 )
 
@@ -43,8 +47,21 @@ type Edge struct {
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
 	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+
+	// Weak edges outlive their endpoints: deleting an endpoint records it
+	// under DanglingFromAttribute or DanglingToAttribute instead of
+	// deleting the edge
+	Weak bool `json:"weak,omitempty"`
 }
 
+// Attributes a weak edge is given when its source or target node is
+// deleted, holding the ID of the missing node. Storage sets and clears them;
+// they are removed when the node is created again.
+const (
+	DanglingFromAttribute = "_dangling_from"
+	DanglingToAttribute   = "_dangling_to"
+)
+
 // Graph represents a collection of nodes and edges
 type Graph struct {
 	ID          GraphID   `json:"id"`
@@ -52,36 +69,115 @@ type Graph struct {
 	Description string    `json:"description"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// DisplayNodeAttr and DisplayEdgeAttr name the attributes used as
+	// human-readable labels when a command is asked for LABELS output.
+	DisplayNodeAttr string `json:"display_node_attr,omitempty"`
+	DisplayEdgeAttr string `json:"display_edge_attr,omitempty"`
+
+	// Attributes holds arbitrary metadata such as owner or environment
+	Attributes Attributes `json:"attributes"`
+
+	// AllowSelfLoops controls whether edges may start and end at the same
+	// node. Nil allows them, as graphs did before the constraint existed.
+	AllowSelfLoops *bool `json:"allow_self_loops,omitempty"`
+
+	// EdgeTypes holds per-edge-type rules that override the graph-wide ones
+	EdgeTypes map[EdgeType]*EdgeTypeSchema `json:"edge_types,omitempty"`
+
+	// Maintenance configures background pruning of the graph's nodes
+	Maintenance *MaintenancePolicy `json:"maintenance,omitempty"`
+
+	// Protected graphs are only deleted by a GRAPH.DELETE that repeats
+	// their name after CONFIRM
+	Protected bool `json:"protected,omitempty"`
 }
 
-// ToJSON converts a node to JSON bytes
+// EdgeTypeSchema holds the constraints for one edge type. Unset fields fall
+// back to the graph-wide setting.
+type EdgeTypeSchema struct {
+	AllowSelfLoops *bool `json:"allow_self_loops,omitempty"`
+}
+
+// ToJSON converts a node to canonical JSON bytes
 func (n *Node) ToJSON() ([]byte, error) {
-	return json.Marshal(n)
+	return CanonicalJSON(n)
 }
 
-// FromJSON populates a node from JSON bytes
+// FromJSON populates a node from JSON bytes, or from a record compressed
+// with CompressRecord
 func (n *Node) FromJSON(data []byte) error {
+	data, err := decompressRecord(data)
+	if err != nil {
+		return err
+	}
 	return json.Unmarshal(data, n)
 }
 
-// ToJSON converts an edge to JSON bytes
+// ToJSON converts an edge to canonical JSON bytes
 func (e *Edge) ToJSON() ([]byte, error) {
-	return json.Marshal(e)
+	return CanonicalJSON(e)
 }
 
-// FromJSON populates an edge from JSON bytes
+// FromJSON populates an edge from JSON bytes, or from a record compressed
+// with CompressRecord
 func (e *Edge) FromJSON(data []byte) error {
+	data, err := decompressRecord(data)
+	if err != nil {
+		return err
+	}
 	return json.Unmarshal(data, e)
 }
 
-// ToJSON converts a graph to JSON bytes
+// CompressedRecord is the first byte of a node or edge record stored
+// gzip-compressed. Uncompressed records are JSON objects, which start with
+// '{', so records written before compression was enabled stay readable.
+const CompressedRecord byte = 0x01
+
+// CompressRecord gzips a serialized node or edge and prefixes it with
+// CompressedRecord
+func CompressRecord(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(CompressedRecord)
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressRecord returns the JSON of a stored node or edge record,
+// decompressing it if it starts with CompressedRecord
+func decompressRecord(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != CompressedRecord {
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// ToJSON converts a graph to canonical JSON bytes
 func (g *Graph) ToJSON() ([]byte, error) {
-	return json.Marshal(g)
+	return CanonicalJSON(g)
 }
 
-// FromJSON populates a graph from JSON bytes
+// FromJSON populates a graph from JSON bytes. Graphs stored before
+// attributes existed get an empty map.
 func (g *Graph) FromJSON(data []byte) error {
-	return json.Unmarshal(data, g)
+	if err := json.Unmarshal(data, g); err != nil {
+		return err
+	}
+	if g.Attributes == nil {
+		g.Attributes = make(Attributes)
+	}
+	return nil
 }
 
 // HasAttribute checks if a node has a specific attribute
@@ -96,6 +192,30 @@ func (n *Node) GetAttribute(key string) (interface{}, bool) {
 	return value, exists
 }
 
+// Lookup returns the value of an attribute. A key holding a dot that is not
+// itself an attribute names a key of an object attribute, as meta.owner
+// names the owner key of meta; only one level of nesting is looked into.
+func (a Attributes) Lookup(key string) (interface{}, bool) {
+	if value, exists := a[key]; exists {
+		return value, true
+	}
+	outer, inner, dotted := strings.Cut(key, ".")
+	if !dotted {
+		return nil, false
+	}
+	var object map[string]interface{}
+	switch value := a[outer].(type) {
+	case map[string]interface{}:
+		object = value
+	case Attributes:
+		object = value
+	default:
+		return nil, false
+	}
+	value, exists := object[inner]
+	return value, exists
+}
+
 // SetAttribute sets an attribute on a node
 func (n *Node) SetAttribute(key string, value interface{}) {
 	if n.Attributes == nil {
@@ -105,6 +225,35 @@ func (n *Node) SetAttribute(key string, value interface{}) {
 	n.UpdatedAt = time.Now()
 }
 
+// UpdatedBefore reports whether the node was last updated before t. Nodes
+// with a zero UpdatedAt predate timestamp tracking and are treated as
+// infinitely old, so they always match.
+func (n *Node) UpdatedBefore(t time.Time) bool {
+	return n.UpdatedAt.IsZero() || n.UpdatedAt.Before(t)
+}
+
+// IsExpired reports whether the node's TTL has elapsed
+func (n *Node) IsExpired() bool {
+	return n.ExpiresAt != nil && !n.ExpiresAt.After(time.Now())
+}
+
+// IsExpired reports whether the edge's TTL has elapsed
+func (e *Edge) IsExpired() bool {
+	return e.ExpiresAt != nil && !e.ExpiresAt.After(time.Now())
+}
+
+// Dangling reports whether either endpoint of the edge has been deleted
+func (e *Edge) Dangling() bool {
+	return e.HasAttribute(DanglingFromAttribute) || e.HasAttribute(DanglingToAttribute)
+}
+
+// DanglingEnd reports whether nodeID is an endpoint of the edge that has
+// been deleted
+func (e *Edge) DanglingEnd(nodeID NodeID) bool {
+	return (nodeID == e.FromNodeID && e.HasAttribute(DanglingFromAttribute)) ||
+		(nodeID == e.ToNodeID && e.HasAttribute(DanglingToAttribute))
+}
+
 // HasAttribute checks if an edge has a specific attribute
 func (e *Edge) HasAttribute(key string) bool {
 	_, exists := e.Attributes[key]
@@ -125,3 +274,38 @@ func (e *Edge) SetAttribute(key string, value interface{}) {
 	e.Attributes[key] = value
 	e.UpdatedAt = time.Now()
 }
+
+// GetAttribute gets a metadata attribute from a graph
+func (g *Graph) GetAttribute(key string) (interface{}, bool) {
+	value, exists := g.Attributes[key]
+	return value, exists
+}
+
+// SetAttribute sets a metadata attribute on a graph
+func (g *Graph) SetAttribute(key string, value interface{}) {
+	if g.Attributes == nil {
+		g.Attributes = make(Attributes)
+	}
+	g.Attributes[key] = value
+	g.UpdatedAt = time.Now()
+}
+
+// SelfLoopsAllowed reports whether edges of edgeType may connect a node to
+// itself, checking the edge type's rule before the graph-wide one
+func (g *Graph) SelfLoopsAllowed(edgeType EdgeType) bool {
+	if schema, ok := g.EdgeTypes[edgeType]; ok && schema != nil && schema.AllowSelfLoops != nil {
+		return *schema.AllowSelfLoops
+	}
+	return g.AllowSelfLoops == nil || *g.AllowSelfLoops
+}
+
+// DeleteAttribute removes a metadata attribute from a graph and reports
+// whether it was present
+func (g *Graph) DeleteAttribute(key string) bool {
+	if _, exists := g.Attributes[key]; !exists {
+		return false
+	}
+	delete(g.Attributes, key)
+	g.UpdatedAt = time.Now()
+	return true
+}
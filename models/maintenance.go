@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaintenancePolicy configures the background pruning of a graph. Each rule
+// runs only when its flag is set; ages are in seconds.
+type MaintenancePolicy struct {
+	// PruneOrphans deletes nodes that have had no edges for
+	// PruneOrphansAfter seconds, counted from when the maintenance loop
+	// first saw them without edges
+	PruneOrphans      bool  `json:"prune_orphans"`
+	PruneOrphansAfter int64 `json:"prune_orphans_after"`
+
+	// PruneStale deletes nodes not updated for PruneStaleAfter seconds,
+	// together with their edges
+	PruneStale      bool  `json:"prune_stale"`
+	PruneStaleAfter int64 `json:"prune_stale_after"`
+
+	// DryRun records what would be pruned without deleting anything
+	DryRun bool `json:"dry_run"`
+
+	// BatchSize is the number of nodes deleted per transaction. 0 uses
+	// the default.
+	BatchSize int `json:"batch_size,omitempty"`
+}
+
+// Enabled reports whether any pruning rule is turned on
+func (p *MaintenancePolicy) Enabled() bool {
+	return p != nil && (p.PruneOrphans || p.PruneStale)
+}
+
+// Validate checks that ages and the batch size are not negative
+func (p *MaintenancePolicy) Validate() error {
+	if p.PruneOrphansAfter < 0 {
+		return fmt.Errorf("prune_orphans_after must not be negative: %d", p.PruneOrphansAfter)
+	}
+	if p.PruneStaleAfter < 0 {
+		return fmt.Errorf("prune_stale_after must not be negative: %d", p.PruneStaleAfter)
+	}
+	if p.BatchSize < 0 {
+		return fmt.Errorf("batch_size must not be negative: %d", p.BatchSize)
+	}
+	return nil
+}
+
+// MaintenanceRun records one evaluation of a graph's maintenance policy
+type MaintenanceRun struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	DryRun    bool          `json:"dry_run"`
+
+	// Orphans and Stale count the nodes pruned by each rule, or that
+	// would have been in a dry run. Failed counts nodes whose deletion
+	// failed.
+	Orphans int `json:"orphans"`
+	Stale   int `json:"stale"`
+	Failed  int `json:"failed"`
+
+	// OrphanNodes and StaleNodes list the first of those nodes
+	OrphanNodes []NodeID `json:"orphan_nodes,omitempty"`
+	StaleNodes  []NodeID `json:"stale_nodes,omitempty"`
+}
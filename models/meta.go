@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MetaEntry is one metadata value stored alongside a graph, such as a node's
+// position in the IDE. Metadata is grouped into namespaces and is never read
+// by queries or analysis.
+type MetaEntry struct {
+	Namespace string          `json:"namespace"`
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Size is what the entry counts against its namespace's quota: the length
+// of its key and of its value
+func (m *MetaEntry) Size() int {
+	return len(m.Key) + len(m.Value)
+}
+
+// ValidateMetaNamespace checks a metadata namespace against the ID policy
+// and also rejects ":", which separates namespaces from keys in the store
+func ValidateMetaNamespace(namespace string) error {
+	if namespace == "" {
+		return fmt.Errorf("%w metadata namespace must not be empty", ErrBadArgument)
+	}
+	return validateName("metadata namespace", namespace, ":")
+}
+
+// Validate checks the namespace and the non-empty key against the
+// character policy, and that the value is JSON
+func (m *MetaEntry) Validate() error {
+	if err := ValidateMetaNamespace(m.Namespace); err != nil {
+		return err
+	}
+	if m.Key == "" {
+		return fmt.Errorf("%w metadata key must not be empty", ErrBadArgument)
+	}
+	if err := validateName("metadata key", m.Key, ""); err != nil {
+		return err
+	}
+	if !json.Valid(m.Value) {
+		return fmt.Errorf("%w metadata value for %s is not valid JSON", ErrBadArgument, m.Key)
+	}
+	return nil
+}
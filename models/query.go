@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// NamedQuery is a saved command template that can be replayed by name.
+// Args holds the template tokens after the command; tokens may contain
+// $1, $2, ... placeholders that are substituted when the query is run.
+type NamedQuery struct {
+	Name      string    `json:"name"`
+	Template  string    `json:"template"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ToJSON converts a named query to JSON bytes
+func (q *NamedQuery) ToJSON() ([]byte, error) {
+	return json.Marshal(q)
+}
+
+// FromJSON populates a named query from JSON bytes
+func (q *NamedQuery) FromJSON(data []byte) error {
+	return json.Unmarshal(data, q)
+}
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// ReindexState is the stage a reindex job is in
+type ReindexState string
+
+const (
+	ReindexRunning   ReindexState = "running"
+	ReindexPaused    ReindexState = "paused"
+	ReindexCancelled ReindexState = "cancelled"
+	ReindexFailed    ReindexState = "failed"
+	// ReindexDone means every entity that existed when the job started is
+	// indexed, so queries may rely on the index
+	ReindexDone ReindexState = "done"
+)
+
+// ReindexJob records the backfill of one index over the existing entities
+// of a graph. It is persisted after every batch, so a job resumes from its
+// cursor after a restart.
+type ReindexJob struct {
+	Index string       `json:"index"`
+	Graph GraphID      `json:"graph"`
+	State ReindexState `json:"state"`
+
+	// Rate is the most entity keys the job scans per second
+	Rate int `json:"rate"`
+
+	// Cursor is the last entity key scanned and Marker the last one that
+	// existed when the job started. Entities written after the start
+	// maintain their own index entries, so the job ends at the marker.
+	Cursor []byte `json:"cursor,omitempty"`
+	Marker []byte `json:"marker,omitempty"`
+
+	// Total counts the entities when the job started, Scanned those
+	// scanned so far and Written the index entries written for them
+	Total   int64 `json:"total"`
+	Scanned int64 `json:"scanned"`
+	Written int64 `json:"written"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// ResumedAt and ResumedScanned record when the job last started or
+	// resumed running and how far it was, to estimate its throughput
+	ResumedAt      time.Time `json:"resumed_at"`
+	ResumedScanned int64     `json:"resumed_scanned"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Progress returns the percentage of the entities scanned, which is 100
+// once the job is done
+func (j *ReindexJob) Progress() float64 {
+	if j.State == ReindexDone || j.Total == 0 {
+		return 100
+	}
+	percent := float64(j.Scanned) * 100 / float64(j.Total)
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+// ETA estimates how long a running job needs to finish, from its throughput
+// since it last resumed or, before the first batch, from its rate. It is 0
+// for jobs that are not running.
+func (j *ReindexJob) ETA(now time.Time) time.Duration {
+	remaining := j.Total - j.Scanned
+	if j.State != ReindexRunning || remaining <= 0 {
+		return 0
+	}
+	scanned, elapsed := j.Scanned-j.ResumedScanned, now.Sub(j.ResumedAt)
+	if scanned <= 0 || elapsed <= 0 {
+		if j.Rate <= 0 {
+			return 0
+		}
+		return time.Duration(remaining) * time.Second / time.Duration(j.Rate)
+	}
+	return time.Duration(float64(elapsed) * float64(remaining) / float64(scanned))
+}
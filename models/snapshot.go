@@ -0,0 +1,69 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Snapshot describes an immutable point-in-time copy of a graph
+type Snapshot struct {
+	ID        string    `json:"id"`
+	GraphID   GraphID   `json:"graph_id"`
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"created_at"`
+	NodeCount int       `json:"node_count"`
+	EdgeCount int       `json:"edge_count"`
+	Size      int       `json:"size"` // compressed size in bytes
+}
+
+// SnapshotData holds the contents of a snapshot
+type SnapshotData struct {
+	Graph *Graph  `json:"graph"`
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// ToJSON converts snapshot metadata to JSON bytes
+func (s *Snapshot) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// FromJSON populates snapshot metadata from JSON bytes
+func (s *Snapshot) FromJSON(data []byte) error {
+	return json.Unmarshal(data, s)
+}
+
+// Compress serializes the snapshot contents as gzip-compressed canonical JSON
+func (d *SnapshotData) Compress() ([]byte, error) {
+	data, err := CanonicalJSON(d)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress populates the snapshot contents from gzip-compressed JSON
+func (d *SnapshotData) Decompress(data []byte) error {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, d)
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StatsDateLayout is the layout of StatsSnapshot.Date, a UTC day
+const StatsDateLayout = "2006-01-02"
+
+// StatsSnapshot records the compact statistics of a graph on one day, for
+// reporting how a graph changes over time. A graph has at most one snapshot
+// per date; recording another the same day replaces it.
+type StatsSnapshot struct {
+	Date       string    `json:"date"`
+	RecordedAt time.Time `json:"recorded_at"`
+
+	NodeCount     int              `json:"node_count"`
+	EdgeCount     int              `json:"edge_count"`
+	NodeTypeCount map[NodeType]int `json:"node_type_count"`
+	EdgeTypeCount map[EdgeType]int `json:"edge_type_count"`
+
+	HasCycles           bool `json:"has_cycles"`
+	ConnectedComponents int  `json:"connected_components"`
+}
+
+// ToJSON converts a stats snapshot to JSON bytes
+func (s *StatsSnapshot) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// FromJSON populates a stats snapshot from JSON bytes
+func (s *StatsSnapshot) FromJSON(data []byte) error {
+	return json.Unmarshal(data, s)
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrBadArgument marks an ID or type rejected by the character policy.
+// Errors wrapping it start with "BADARG" and are sent to clients without
+// the generic ERR prefix.
+var ErrBadArgument = errors.New("BADARG")
+
+// reservedSequences may not appear in IDs or types because responses use
+// them to render paths
+var reservedSequences = []string{"->", "<-"}
+
+// ValidateID checks a graph, node or edge ID against the character policy:
+// no "->" or "<-", no control characters and no leading or trailing
+// whitespace. kind names the entity in the error.
+func ValidateID(kind string, id string) error {
+	return validateName(kind+" ID", id, "")
+}
+
+// ValidateType checks a node or edge type against the ID policy and also
+// rejects ":", which separates IDs from types in id:type responses
+func ValidateType(kind string, typ string) error {
+	return validateName(kind+" type", typ, ":")
+}
+
+// ValidateAlias checks a node alias against the ID policy. Aliases stand in
+// for node IDs, so they may not be empty either.
+func ValidateAlias(alias string) error {
+	if alias == "" {
+		return fmt.Errorf("%w node alias must not be empty", ErrBadArgument)
+	}
+	return validateName("node alias", alias, "")
+}
+
+// validateName rejects value if it breaks the character policy or contains
+// one of the extra reserved characters
+func validateName(what string, value string, extra string) error {
+	for _, seq := range reservedSequences {
+		if strings.Contains(value, seq) {
+			return fmt.Errorf("%w %s %q contains reserved sequence %q", ErrBadArgument, what, value, seq)
+		}
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) || strings.ContainsRune(extra, r) {
+			return fmt.Errorf("%w %s %q contains reserved character %q", ErrBadArgument, what, value, r)
+		}
+	}
+	if strings.TrimSpace(value) != value {
+		return fmt.Errorf("%w %s %q has leading or trailing whitespace", ErrBadArgument, what, value)
+	}
+	return nil
+}
+
+// Validate checks the node's ID and type against the character policy
+func (n *Node) Validate() error {
+	if err := ValidateID("node", string(n.ID)); err != nil {
+		return err
+	}
+	return ValidateType("node", string(n.Type))
+}
+
+// Validate checks the edge's ID and type against the character policy.
+// Endpoints are node IDs and were checked when their nodes were created.
+func (e *Edge) Validate() error {
+	if err := ValidateID("edge", string(e.ID)); err != nil {
+		return err
+	}
+	return ValidateType("edge", string(e.Type))
+}
+
+// AttributeKeyProblem describes how an attribute key breaks the attribute
+// key policy, or returns "" if it does not. Keys must be non-empty, at most
+// maxLength bytes long (0 for no limit) and free of control characters and
+// leading or trailing whitespace, so look-alike keys cannot be told apart.
+func AttributeKeyProblem(key string, maxLength int) string {
+	if key == "" {
+		return "must not be empty"
+	}
+	if maxLength > 0 && len(key) > maxLength {
+		return fmt.Sprintf("is longer than %d bytes", maxLength)
+	}
+	for _, r := range key {
+		if unicode.IsControl(r) {
+			return fmt.Sprintf("contains control character %q", r)
+		}
+	}
+	if strings.TrimSpace(key) != key {
+		return "has leading or trailing whitespace"
+	}
+	return ""
+}
+
+// ValidateAttributeKey checks a graph, node or edge attribute key against
+// the attribute key policy. kind names the entity in the error.
+func ValidateAttributeKey(kind string, key string, maxLength int) error {
+	if problem := AttributeKeyProblem(key, maxLength); problem != "" {
+		return fmt.Errorf("%w %s attribute key %q %s", ErrBadArgument, kind, key, problem)
+	}
+	return nil
+}
+
+// Default attribute limits, see AttributeLimits
+const (
+	DefaultMaxAttributeDepth        = 10
+	DefaultMaxAttributeKeys         = 1000
+	DefaultMaxAttributeStringLength = 1 << 20
+)
+
+// AttributeLimits bounds the attribute values writes accept, so deeply
+// nested or huge values cannot slow every read of an entity. A limit of 0
+// is no limit.
+type AttributeLimits struct {
+	// MaxDepth is how deeply objects and arrays may nest. The attributes
+	// object itself is at depth 1, and each object or array in it adds 1.
+	MaxDepth int
+	// MaxKeys is how many object keys and array elements the attributes
+	// may hold in all, at every depth, top-level keys included
+	MaxKeys int
+	// MaxStringLength is the longest string value, in bytes
+	MaxStringLength int
+}
+
+// DefaultAttributeLimits returns the limits writes apply by default
+func DefaultAttributeLimits() AttributeLimits {
+	return AttributeLimits{
+		MaxDepth:        DefaultMaxAttributeDepth,
+		MaxKeys:         DefaultMaxAttributeKeys,
+		MaxStringLength: DefaultMaxAttributeStringLength,
+	}
+}
+
+// ValidateAttributes walks attributes once, keys in sorted order, and checks
+// them against limits. Strings and keys must also be valid UTF-8. The error
+// is BADARG and names the value that broke a limit by its path, such as
+// attributes.metadata.labels[3]; kind names the entity.
+func ValidateAttributes(kind string, attributes Attributes, limits AttributeLimits) error {
+	w := attributeWalk{kind: kind, limits: limits}
+	return w.object("attributes", attributes, 1)
+}
+
+// attributeWalk is the state of one ValidateAttributes call
+type attributeWalk struct {
+	kind   string
+	limits AttributeLimits
+	keys   int
+}
+
+// value checks a value at path, nested in depth objects or arrays
+func (w *attributeWalk) value(path string, v interface{}, depth int) error {
+	switch v := v.(type) {
+	case string:
+		if !utf8.ValidString(v) {
+			return fmt.Errorf("%w %s %s is not valid UTF-8", ErrBadArgument, w.kind, path)
+		}
+		if w.limits.MaxStringLength > 0 && len(v) > w.limits.MaxStringLength {
+			return fmt.Errorf("%w %s %s is longer than %d bytes", ErrBadArgument, w.kind, path, w.limits.MaxStringLength)
+		}
+	case Attributes:
+		return w.object(path, v, depth+1)
+	case map[string]interface{}:
+		return w.object(path, v, depth+1)
+	case []interface{}:
+		if err := w.nest(path, depth+1); err != nil {
+			return err
+		}
+		for i, element := range v {
+			elementPath := fmt.Sprintf("%s[%d]", path, i)
+			if err := w.count(elementPath); err != nil {
+				return err
+			}
+			if err := w.value(elementPath, element, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// object checks the keys and values of an object at path and depth
+func (w *attributeWalk) object(path string, object map[string]interface{}, depth int) error {
+	if err := w.nest(path, depth); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := path + "." + key
+		if !utf8.ValidString(key) {
+			return fmt.Errorf("%w %s %s has a key that is not valid UTF-8", ErrBadArgument, w.kind, path)
+		}
+		if err := w.count(keyPath); err != nil {
+			return err
+		}
+		if err := w.value(keyPath, object[key], depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nest fails if an object or array at path is nested deeper than MaxDepth
+func (w *attributeWalk) nest(path string, depth int) error {
+	if w.limits.MaxDepth > 0 && depth > w.limits.MaxDepth {
+		return fmt.Errorf("%w %s %s is nested deeper than %d levels", ErrBadArgument, w.kind, path, w.limits.MaxDepth)
+	}
+	return nil
+}
+
+// count counts the key or element at path and fails past MaxKeys
+func (w *attributeWalk) count(path string) error {
+	w.keys++
+	if w.limits.MaxKeys > 0 && w.keys > w.limits.MaxKeys {
+		return fmt.Errorf("%w %s %s is past the limit of %d keys", ErrBadArgument, w.kind, path, w.limits.MaxKeys)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// maxAdjacencyNodes is the most nodes GRAPH.ADJACENCY returns in one reply
+// without CURSOR. Bigger graphs are read a page at a time.
+const maxAdjacencyNodes = 10000
+
+// adjacencyKeywords ends the NODETYPES and EDGETYPES lists of
+// GRAPH.ADJACENCY
+var adjacencyKeywords = map[string]bool{
+	"NODETYPES": true,
+	"EDGETYPES": true,
+	"CURSOR":    true,
+	"COUNT":     true,
+}
+
+// handleAdjacency handles GRAPH.ADJACENCY <name> [NODETYPES type1...]
+// [EDGETYPES type1...] [CURSOR <cursor> [COUNT <n>]]
+// The reply holds one array per node, in node ID order: "id:type", then
+// "out:edge_type:to_id" or "in:edge_type:from_id" for each of its edges.
+// With NODETYPES only edges between nodes of those types are listed. With
+// CURSOR the first array holds the next cursor alone, "0" after the last
+// page; other cursors are ">" followed by the last node ID of the previous
+// page, so pages split on node boundaries.
+func (g *GraphCommands) handleAdjacency(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("GRAPH.ADJACENCY requires at least 1 argument: name")
+	}
+
+	graphID := models.GraphID(args[0])
+	nodeTypes := make(map[models.NodeType]bool)
+	var edgeTypes []models.EdgeType
+	cursor := ""
+	count := 0
+
+	i := 1
+	for i < len(args) {
+		switch strings.ToUpper(args[i]) {
+		case "NODETYPES":
+			i++
+			for i < len(args) && !adjacencyKeywords[strings.ToUpper(args[i])] {
+				nodeTypes[models.NodeType(args[i])] = true
+				i++
+			}
+		case "EDGETYPES":
+			i++
+			for i < len(args) && !adjacencyKeywords[strings.ToUpper(args[i])] {
+				edgeTypes = append(edgeTypes, models.EdgeType(args[i]))
+				i++
+			}
+		case "CURSOR":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("CURSOR option requires a cursor")
+			}
+			cursor = args[i+1]
+			if cursor != "0" && !strings.HasPrefix(cursor, ">") {
+				return nil, fmt.Errorf("invalid cursor: %s", cursor)
+			}
+			i += 2
+		case "COUNT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("COUNT option requires a value")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid COUNT: %s (must be a positive integer)", args[i+1])
+			}
+			count = n
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown option for GRAPH.ADJACENCY: %s", args[i])
+		}
+	}
+	if count > 0 && cursor == "" {
+		return nil, fmt.Errorf("COUNT requires CURSOR")
+	}
+	if count == 0 {
+		count = defaultPageCount
+	}
+
+	if _, err := g.storage.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+
+	// One pass over the nodes finds those listed, and a second over the
+	// edges fills in the neighbors of the nodes on this page
+	listed := make(map[models.NodeID]models.NodeType)
+	var nodeIDs []models.NodeID
+	err := g.storage.ScanNodes(graphID, func(node *models.Node) error {
+		if len(nodeTypes) == 0 || nodeTypes[node.Type] {
+			listed[node.ID] = node.Type
+			nodeIDs = append(nodeIDs, node.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan nodes: %w", err)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool { return nodeIDs[i] < nodeIDs[j] })
+
+	page := nodeIDs
+	if cursor == "" && len(page) > maxAdjacencyNodes {
+		return nil, fmt.Errorf("graph %s has %d nodes to list, more than the %d GRAPH.ADJACENCY returns at once; use CURSOR 0 to page through them", graphID, len(page), maxAdjacencyNodes)
+	}
+	if cursor != "" {
+		start := 0
+		if after := models.NodeID(strings.TrimPrefix(cursor, ">")); cursor != "0" {
+			start = sort.Search(len(nodeIDs), func(i int) bool { return nodeIDs[i] > after })
+		}
+		page = nodeIDs[start:min(start+count, len(nodeIDs))]
+	}
+
+	rows := make(map[models.NodeID][]string, len(page))
+	for _, nodeID := range page {
+		rows[nodeID] = []string{string(nodeID) + ":" + string(listed[nodeID])}
+	}
+	err = g.storage.ScanEdges(graphID, func(edge *models.Edge) error {
+		if edge.Dangling() || !matchesAnyEdgeType(edge, edgeTypes) {
+			return nil
+		}
+		_, fromListed := listed[edge.FromNodeID]
+		_, toListed := listed[edge.ToNodeID]
+		if !fromListed || !toListed {
+			return nil
+		}
+		if row, ok := rows[edge.FromNodeID]; ok {
+			rows[edge.FromNodeID] = append(row, "out:"+string(edge.Type)+":"+string(edge.ToNodeID))
+		}
+		if row, ok := rows[edge.ToNodeID]; ok {
+			rows[edge.ToNodeID] = append(row, "in:"+string(edge.Type)+":"+string(edge.FromNodeID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan edges: %w", err)
+	}
+
+	response := make([]interface{}, 0, len(page)+1)
+	if cursor != "" {
+		next := "0"
+		if len(page) > 0 && page[len(page)-1] != nodeIDs[len(nodeIDs)-1] {
+			next = ">" + string(page[len(page)-1])
+		}
+		response = append(response, []string{next})
+	}
+	for _, nodeID := range page {
+		response = append(response, rows[nodeID])
+	}
+	return protocol.NewNestedArrayResponse(response), nil
+}
+
+// matchesAnyEdgeType reports whether edge has one of edgeTypes, or whether
+// edgeTypes is empty
+func matchesAnyEdgeType(edge *models.Edge, edgeTypes []models.EdgeType) bool {
+	if len(edgeTypes) == 0 {
+		return true
+	}
+	for _, edgeType := range edgeTypes {
+		if edge.Type == edgeType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package commands
+
+import "strings"
+
+// namespaceAliases maps short namespace names to the full ones, so that
+// N.CREATE runs NODE.CREATE
+var namespaceAliases = map[string]string{
+	"G": "GRAPH",
+	"N": "NODE",
+	"E": "EDGE",
+	"A": "ANALYSIS",
+	"Q": "QUERY",
+}
+
+// NormalizeCommand upper-cases a command name and expands namespace aliases.
+// Arguments are left alone; handlers match their keywords case-insensitively.
+func NormalizeCommand(command string) string {
+	command = strings.ToUpper(command)
+	namespace, name, dotted := strings.Cut(command, ".")
+	if full, ok := namespaceAliases[namespace]; ok && dotted {
+		return full + "." + name
+	}
+	return command
+}
@@ -2,12 +2,15 @@ package commands
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sort"
 
 	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/jobs"
 	"github.com/ywadi/PathwayDB/models"
 	"github.com/ywadi/PathwayDB/redis/protocol"
 	"github.com/ywadi/PathwayDB/storage"
@@ -18,6 +21,9 @@ import (
 type AnalysisCommands struct {
 	storage  storage.StorageEngine
 	analyzer *analysis.GraphAnalyzer
+	jobs     *jobs.Manager
+	cursors  *jobs.Manager // Rankings cached for PAGE cursors
+	limits   AnalysisLimits
 }
 
 // NewAnalysisCommands creates a new analysis commands handler
@@ -25,55 +31,299 @@ func NewAnalysisCommands(storageEngine storage.StorageEngine) *AnalysisCommands
 	return &AnalysisCommands{
 		storage:  storageEngine,
 		analyzer: analysis.NewGraphAnalyzer(storageEngine),
+		jobs:     jobs.NewManager(jobs.DefaultConfig()),
+		cursors:  newCursorCache(),
+		limits:   DefaultAnalysisLimits(),
 	}
 }
 
 // Handle routes analysis commands to their respective handlers
 func (a *AnalysisCommands) Handle(command string, args []string) (*protocol.Response, error) {
-	switch command {
-	case "SHORTESTPATH":
-		return a.handleShortestPath(args)
-	case "CENTRALITY":
-		return a.handleCentrality(args)
-	case "CLUSTERING":
-		return a.handleClustering(args)
-	case "CYCLES":
-		return a.handleCycles(args)
-	case "TRAVERSE":
-		return a.handleTraverse(args)
-	default:
-		return nil, fmt.Errorf("unknown ANALYSIS command: %s", command)
-	}
+	return route(a.Register, nil, "ANALYSIS."+command, args)
+}
+
+// Register adds the analysis commands to a registry
+func (a *AnalysisCommands) Register(r *Registry) {
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.SHORTESTPATH",
+		Args:     "<graph> <from> <to> [algorithm] [FORMAT simple|detailed|json] [LABELS] [COUNT] [TRANSITIONS <json>] [PASSTHROUGH <type,...>] [DAG] [WEIGHT <attr_key> [DEFAULT <weight>]]",
+		Keywords: []string{"FORMAT", "LABELS", "COUNT", "TRANSITIONS", "PASSTHROUGH", "DAG", "WEIGHT", "DEFAULT"},
+		Summary:  "Finds the shortest paths between two nodes, or with DAG every node and edge on one",
+		Example:  "ANALYSIS.SHORTESTPATH my-graph service-a service-c FORMAT simple",
+		ReadOnly: true,
+		Handler:  a.handleShortestPath,
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.CENTRALITY",
+		Args:     "<graph> <type> [node_id] [DIRECTION in|out|both] [TOP n] [PAGE <cursor> [COUNT n]] [PASSTHROUGH <type,...>] [FORMAT csv|tsv] [parameters_json]",
+		Keywords: []string{"DIRECTION", "TOP", "PAGE", "COUNT", "PASSTHROUGH", "FORMAT"},
+		Defaults: []string{"DIRECTION both for degree, betweenness and closeness, out for pagerank and eigenvector"},
+		Summary:  "Scores nodes by degree, pagerank, eigenvector, betweenness or closeness centrality",
+		Example:  `ANALYSIS.CENTRALITY my-graph pagerank TOP 2 {"damping":0.9}`,
+		ReadOnly: true,
+		Handler:  sessionless(a.handleCentrality),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.CLUSTERING",
+		Args:     "<graph> [algorithm] [parameters_json] [FORCE]",
+		Keywords: []string{"FORCE"},
+		Summary:  "Groups the nodes of a graph into clusters",
+		Example:  "ANALYSIS.CLUSTERING my-graph louvain",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleClustering),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.CYCLES",
+		Args:     "<graph> [NODETYPE type1...] [EDGETYPE type1...] [FORMAT simple|detailed] [LABELS] [COUNT] [FORCE]",
+		Keywords: []string{"NODETYPE", "EDGETYPE", "FORMAT", "LABELS", "COUNT", "FORCE"},
+		Summary:  "Finds the cycles of a graph",
+		Example:  "ANALYSIS.CYCLES my-graph FORMAT simple",
+		ReadOnly: true,
+		Handler:  a.handleCycles,
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.SCC",
+		Args:     "<graph> [EDGETYPES type1...]",
+		Keywords: []string{"EDGETYPES"},
+		Summary:  "Lists the strongly connected components of a graph that hold a cycle",
+		Example:  "ANALYSIS.SCC my-graph EDGETYPES depends_on",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleSCC),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.TOPOSORT",
+		Args:     "<graph> [EDGETYPES type1...] [FORMAT simple|detailed]",
+		Keywords: []string{"EDGETYPES", "FORMAT"},
+		Summary:  "Orders the nodes of an acyclic graph so every edge leads forward, by level",
+		Example:  "ANALYSIS.TOPOSORT my-graph EDGETYPES depends_on",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleTopoSort),
+	})
+	r.Register(CommandSpec{
+		Name: "ANALYSIS.TRAVERSE",
+		Args: "<graph> <start_node> [DIRECTION <dir>] [NODETYPES type1...] [EDGETYPES type1...] [FORMAT simple|detailed|json|dot] [LABELS] [AGE] [TERMINAL] [COUNT] " +
+			"[UPDATEDBEFORE <rfc3339|seconds>] [MAXFANOUT <n> [STRATEGY first|random [SEED <int>]]] [TRANSITIONS <json>] [PASSTHROUGH <type,...>] [MINDEPTH <n>] [MAXDEPTH <n>] [FORCE]",
+		Keywords: []string{"DIRECTION", "NODETYPES", "EDGETYPES", "FORMAT", "LABELS", "AGE", "TERMINAL", "COUNT", "UPDATEDBEFORE", "MAXFANOUT", "STRATEGY", "SEED", "TRANSITIONS", "PASSTHROUGH", "MINDEPTH", "MAXDEPTH", "FORCE"},
+		Defaults: []string{"DIRECTION out"},
+		Summary:  "Walks the graph from a node",
+		Example:  "ANALYSIS.TRAVERSE my-graph shared-lib FORMAT simple MAXFANOUT 2",
+		ReadOnly: true,
+		Handler:  a.handleTraverse,
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.DEPENDENCIES",
+		Args:     "<graph> <node> [DEPENDENTS] [NODETYPES type1...] [EDGETYPES type1...] [MAXDEPTH n] [FORMAT simple|detailed]",
+		Keywords: []string{"DEPENDENTS", "NODETYPES", "EDGETYPES", "MAXDEPTH", "FORMAT"},
+		Summary:  "Lists the transitive dependencies or dependents of a node with their depth",
+		Example:  "ANALYSIS.DEPENDENCIES my-graph service-a FORMAT detailed",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleDependencies),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.TREE",
+		Args:     "<graph> <node> [MAXDEPTH n] [FORMAT json]",
+		Keywords: []string{"MAXDEPTH", "FORMAT"},
+		Summary:  "Returns the dependencies of a node as a tree, in JSON",
+		Example:  "ANALYSIS.TREE my-graph service-a MAXDEPTH 3",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleTree),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.HOTNODES",
+		Args:     "<graph> [TOP n]",
+		Keywords: []string{"TOP"},
+		Summary:  "Lists the most-read nodes of a graph",
+		Example:  "ANALYSIS.HOTNODES my-graph TOP 2",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleHotNodes),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.COMPONENTS",
+		Args:     "<graph> [EDGETYPES type1...] [FORMAT labels|groups]",
+		Keywords: []string{"EDGETYPES", "FORMAT"},
+		Summary:  "Assigns every node to its weakly connected component",
+		Example:  "ANALYSIS.COMPONENTS my-graph EDGETYPES depends_on FORMAT groups",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleComponents),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.PARALLEL",
+		Args:     "<graph> [MIN n]",
+		Keywords: []string{"MIN"},
+		Summary:  "Lists the edges that share their endpoints and type",
+		Example:  "ANALYSIS.PARALLEL my-graph MIN 3",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleParallel),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.PAIRWISE",
+		Args:     "<graph> <id1,id2,...> [DIRECTION <dir>] [EDGETYPES type1...] [MAXDEPTH n]",
+		Keywords: []string{"DIRECTION", "EDGETYPES", "MAXDEPTH"},
+		Defaults: []string{"DIRECTION out"},
+		Summary:  "Returns the shortest path length between every pair of the given nodes",
+		Example:  "ANALYSIS.PAIRWISE my-graph service-a,service-b,database",
+		ReadOnly: true,
+		Handler:  sessionless(a.handlePairwise),
+	})
+	r.Register(CommandSpec{
+		Name: "ANALYSIS.WHATIF",
+		Args: "<graph> REMOVE EDGES|NODES <id,...> [REMOVE ...] " +
+			"CHECK REACHABLE|SHORTESTPATH <from> <to> | SUMMARY FROMTYPE <type,...> TOTYPE <type,...> [CRITICAL]",
+		Keywords: []string{"REMOVE", "EDGES", "NODES", "CHECK", "REACHABLE", "SHORTESTPATH", "SUMMARY", "FROMTYPE", "TOTYPE", "CRITICAL"},
+		Summary:  "Checks reachability with edges or nodes removed, without changing the graph",
+		Example:  "ANALYSIS.WHATIF my-graph REMOVE EDGES gateway-user CHECK REACHABLE frontend user-service",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleWhatIf),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.STATS",
+		Args:     "<graph> [EDGETYPES type1...]",
+		Keywords: []string{"EDGETYPES"},
+		Summary:  "Returns the node, edge, root, leaf and orphan counts, cycles, depth and components of a graph",
+		Example:  "ANALYSIS.STATS my-graph EDGETYPES depends_on",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleStats),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.STATSHISTORY",
+		Args:     "<graph> [DAYS n] [FORMAT fields|json]",
+		Keywords: []string{"DAYS", "FORMAT"},
+		Defaults: []string{"DAYS 90", "FORMAT fields"},
+		Summary:  "Returns the daily stats snapshots of a graph, oldest first",
+		Example:  "ANALYSIS.STATSHISTORY my-graph DAYS 30",
+		Handler:  sessionless(a.handleStatsHistory),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.SUBMIT",
+		Args:     "<subcommand> [args...]",
+		Summary:  "Runs an analysis command as a background job and returns its ID",
+		Example:  "ANALYSIS.SUBMIT CYCLES my-graph",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleSubmit),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.STATUS",
+		Args:     "<job_id>",
+		Summary:  "Reports a job's state and progress",
+		Example:  "ANALYSIS.STATUS job-3f9c2a1b7d4e6f80",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleStatus),
+	})
+	r.Register(CommandSpec{
+		Name:     "ANALYSIS.RESULT",
+		Args:     "<job_id>",
+		Summary:  "Returns the result of a finished job",
+		Example:  "ANALYSIS.RESULT job-3f9c2a1b7d4e6f80",
+		ReadOnly: true,
+		Handler:  sessionless(a.handleResult),
+	})
+	r.Register(CommandSpec{
+		Name:    "ANALYSIS.CANCEL",
+		Args:    "<job_id>",
+		Summary: "Cancels a queued or running job",
+		Example: "ANALYSIS.CANCEL job-3f9c2a1b7d4e6f80",
+		Handler: sessionless(a.handleCancel),
+	})
 }
 
-// handleShortestPath handles ANALYSIS.SHORTESTPATH <graph> <from> <to> [algorithm] [FORMAT simple|detailed]
-func (a *AnalysisCommands) handleShortestPath(args []string) (*protocol.Response, error) {
+// handleShortestPath handles ANALYSIS.SHORTESTPATH <graph> <from> <to> [algorithm] [FORMAT simple|detailed|json] [LABELS] [COUNT] [TRANSITIONS json] [PASSTHROUGH type,...] [DAG] [WEIGHT attr_key [DEFAULT weight]]
+func (a *AnalysisCommands) handleShortestPath(session *Session, args []string) (*protocol.Response, error) {
 	if len(args) < 3 {
 		return nil, fmt.Errorf("ANALYSIS.SHORTESTPATH requires at least 3 arguments: graph, from, to")
 	}
 
 	graphID := args[0]
-	fromNodeID := args[1]
-	toNodeID := args[2]
+	fromNodeID, err := resolveNodeID(a.storage, models.GraphID(graphID), args[1])
+	if err != nil {
+		return nil, err
+	}
+	toNodeID, err := resolveNodeID(a.storage, models.GraphID(graphID), args[2])
+	if err != nil {
+		return nil, err
+	}
 
 	format := "detailed" // Default to detailed format
+	withLabels := false
+	withCount := false
+	dag := false
+	var weight *types.EdgeWeight
+	var options *types.TraversalOptions
+
+	pathOptions := func() *types.TraversalOptions {
+		if options == nil {
+			options = &types.TraversalOptions{Direction: types.DirectionForward}
+		}
+		return options
+	}
 
 	// Parse optional arguments
 	for i := 3; i < len(args); i++ {
-		if args[i] == "FORMAT" && i+1 < len(args) {
+		if strings.ToUpper(args[i]) == "TRANSITIONS" && i+1 < len(args) {
+			i++
+			transitions, err := parseTransitions(args[i])
+			if err != nil {
+				return nil, err
+			}
+			pathOptions().EdgeTypeTransitions = transitions
+		} else if strings.ToUpper(args[i]) == "PASSTHROUGH" && i+1 < len(args) {
+			i++
+			passThrough, err := parsePassThrough(args[i])
+			if err != nil {
+				return nil, err
+			}
+			pathOptions().PassThroughNodeTypes = passThrough
+		} else if strings.ToUpper(args[i]) == "FORMAT" && i+1 < len(args) {
 			i++
-			if args[i] != "simple" && args[i] != "detailed" {
-				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple' or 'detailed')", args[i])
+			format = strings.ToLower(args[i])
+			if format != "simple" && format != "detailed" && format != "json" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple', 'detailed' or 'json')", args[i])
+			}
+		} else if strings.ToUpper(args[i]) == "LABELS" {
+			withLabels = true
+		} else if strings.ToUpper(args[i]) == "COUNT" {
+			withCount = true
+		} else if strings.ToUpper(args[i]) == "DAG" {
+			dag = true
+		} else if strings.ToUpper(args[i]) == "WEIGHT" && i+1 < len(args) {
+			i++
+			weight = &types.EdgeWeight{Attribute: args[i]}
+			if i+2 < len(args) && strings.ToUpper(args[i+1]) == "DEFAULT" {
+				value, err := strconv.ParseFloat(args[i+2], 64)
+				if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+					return nil, fmt.Errorf("invalid DEFAULT weight: %s", args[i+2])
+				}
+				weight.Default = &value
+				i += 2
 			}
-			format = args[i]
 		}
 		// Note: algorithm parameter is parsed but not used yet as the analyzer only supports one algorithm
 	}
+	if withCount && format == "json" {
+		return nil, fmt.Errorf("COUNT cannot be combined with FORMAT json")
+	}
+	if weight != nil {
+		if options != nil || dag || withCount {
+			return nil, fmt.Errorf("WEIGHT cannot be combined with TRANSITIONS, PASSTHROUGH, DAG or COUNT")
+		}
+		return a.handleWeightedShortestPath(session, models.GraphID(graphID), fromNodeID, toNodeID, *weight, format, withLabels)
+	}
+	if dag {
+		if options != nil || withLabels || withCount {
+			return nil, fmt.Errorf("DAG cannot be combined with TRANSITIONS, PASSTHROUGH, LABELS or COUNT")
+		}
+		return a.handleShortestPathDAG(session, models.GraphID(graphID), fromNodeID, toNodeID, format)
+	}
+
+	labels, err := newLabeler(a.storage, models.GraphID(graphID), withLabels, false)
+	if err != nil {
+		return nil, err
+	}
 
 	// Use the existing GetShortestPath method from GraphAnalyzer
-	pathResult, err := a.analyzer.GetShortestPath(models.GraphID(graphID), models.NodeID(fromNodeID), models.NodeID(toNodeID), nil)
+	analyzer := a.analyzer.WithContext(session.Context())
+	pathResult, err := analyzer.GetShortestPath(models.GraphID(graphID), fromNodeID, toNodeID, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compute shortest path: %v", err)
+		return nil, fmt.Errorf("failed to compute shortest path: %w", err)
 	}
 
 	if pathResult == nil {
@@ -82,20 +332,84 @@ func (a *AnalysisCommands) handleShortestPath(args []string) (*protocol.Response
 
 	// Simple format with nodeid:nodetype
 	if format == "simple" {
-		return a.buildSimplePathResponse(models.GraphID(graphID), pathResult)
+		response, err := a.buildSimplePathResponse(models.GraphID(graphID), pathResult, labels)
+		return withCountIf(withCount, response), err
+	}
+	if format == "json" {
+		return jsonResponse(pathResult)
+	}
+
+	// AllShortestPaths takes neither a path grammar nor pass-through types,
+	// so the detailed format reports the one shortest path that follows them
+	if options != nil {
+		response, err := a.buildMultiPathResponse(models.GraphID(graphID), []*types.PathResult{pathResult}, labels)
+		return withCountIf(withCount, response), err
 	}
 
 	// Enhanced detailed format with multiple paths
-	allPaths, err := a.analyzer.AllShortestPaths(models.GraphID(graphID), models.NodeID(fromNodeID), models.NodeID(toNodeID))
+	allPaths, err := analyzer.AllShortestPaths(models.GraphID(graphID), fromNodeID, toNodeID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find all shortest paths: %v", err)
+		return nil, fmt.Errorf("failed to find all shortest paths: %w", err)
 	}
 
 	if len(allPaths) == 0 {
 		return protocol.NewNullResponse(), nil
 	}
 
-	return a.buildMultiPathResponse(allPaths)
+	response, err := a.buildMultiPathResponse(models.GraphID(graphID), allPaths, labels)
+	return withCountIf(withCount, response), err
+}
+
+// handleWeightedShortestPath replies to ANALYSIS.SHORTESTPATH WEIGHT with
+// the lightest path in the requested format. The simple and detailed
+// formats end with the path's total weight.
+func (a *AnalysisCommands) handleWeightedShortestPath(session *Session, graphID models.GraphID, from, to models.NodeID, weight types.EdgeWeight, format string, withLabels bool) (*protocol.Response, error) {
+	labels, err := newLabeler(a.storage, graphID, withLabels, false)
+	if err != nil {
+		return nil, err
+	}
+	pathResult, err := a.analyzer.WithContext(session.Context()).GetWeightedShortestPath(graphID, from, to, weight, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute weighted shortest path: %w", err)
+	}
+	if format == "json" {
+		return jsonResponse(pathResult)
+	}
+
+	var response *protocol.Response
+	if format == "simple" {
+		response, err = a.buildSimplePathResponse(graphID, pathResult, labels)
+	} else {
+		response, err = a.buildMultiPathResponse(graphID, []*types.PathResult{pathResult}, labels)
+	}
+	if err != nil {
+		return nil, err
+	}
+	total := strconv.FormatFloat(pathResult.TotalWeight, 'f', -1, 64)
+	return protocol.NewArrayResponse(append(response.ArrayValue, total)), nil
+}
+
+// handleShortestPathDAG replies to ANALYSIS.SHORTESTPATH DAG with the
+// nodes and the edges on at least one shortest path, as two lists of
+// id:type entries, or with FORMAT json with the whole subgraph
+func (a *AnalysisCommands) handleShortestPathDAG(session *Session, graphID models.GraphID, from, to models.NodeID, format string) (*protocol.Response, error) {
+	dag, err := a.analyzer.WithContext(session.Context()).ShortestPathDAG(graphID, from, to, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shortest path DAG: %w", err)
+	}
+	if format == "json" {
+		return jsonResponse(dag)
+	}
+
+	nodes := make([]string, 0, len(dag.Nodes))
+	for _, node := range dag.Nodes {
+		nodes = append(nodes, string(node.ID)+":"+string(node.Type))
+	}
+	edges := make([]string, 0, len(dag.Edges))
+	for _, edge := range dag.Edges {
+		edges = append(edges, string(edge.ID)+":"+string(edge.Type))
+	}
+	return protocol.NewNestedArrayResponse([]interface{}{nodes, edges}), nil
 }
 
 // buildDetailedPathResponse creates a detailed shortest path response with pipe-delimited format
@@ -109,7 +423,7 @@ func (a *AnalysisCommands) buildDetailedPathResponse(graphID models.GraphID, pat
 	for i, nodeID := range pathResult.Path {
 		node, err := a.storage.GetNode(graphID, nodeID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get node %s: %v", nodeID, err)
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
 		}
 		nodeDetails[i] = node
 	}
@@ -143,8 +457,8 @@ func (a *AnalysisCommands) buildDetailedPathResponse(graphID models.GraphID, pat
 	return protocol.NewArrayResponse(response), nil
 }
 
-// handleCentrality handles ANALYSIS.CENTRALITY <graph> <type> [node_id] [DIRECTION in|out|both]
-// type can be: "betweenness", "closeness", "degree"
+// handleCentrality handles ANALYSIS.CENTRALITY <graph> <type> [node_id] [DIRECTION in|out|both] [TOP n] [PAGE cursor [COUNT n]] [PASSTHROUGH type,...] [FORMAT csv|tsv] [parameters_json]
+// type can be: "betweenness", "closeness", "degree", "pagerank", "eigenvector"
 func (a *AnalysisCommands) handleCentrality(args []string) (*protocol.Response, error) {
 	if len(args) < 2 {
 		return nil, fmt.Errorf("ANALYSIS.CENTRALITY requires at least 2 arguments: graph, type")
@@ -155,8 +469,21 @@ func (a *AnalysisCommands) handleCentrality(args []string) (*protocol.Response,
 
 	var nodeID *models.NodeID
 	direction := types.DirectionBoth // Default direction
-
-	// Parse optional arguments: node_id and DIRECTION
+	if centralityType == "pagerank" || centralityType == "eigenvector" {
+		direction = types.DirectionForward // Rank flows along edges by default
+	}
+	top := 0
+	var cursor *string
+	count := 0
+	var passThrough []models.NodeType
+	format := ""
+
+	// Default parameters for iterative centralities
+	damping := 0.85
+	iterations := 100
+	tolerance := 1e-6
+
+	// Parse optional arguments: node_id, DIRECTION, TOP, PAGE, COUNT, PASSTHROUGH, FORMAT and parameters_json
 	i := 2
 	for i < len(args) {
 		if strings.ToUpper(args[i]) == "DIRECTION" {
@@ -164,49 +491,486 @@ func (a *AnalysisCommands) handleCentrality(args []string) (*protocol.Response,
 				return nil, fmt.Errorf("DIRECTION option requires an argument")
 			}
 			i++
-			switch strings.ToLower(args[i]) {
-			case "in":
-				direction = types.DirectionBackward
-			case "out":
-				direction = types.DirectionForward
-			case "both":
-				direction = types.DirectionBoth
-			default:
-				return nil, fmt.Errorf("invalid DIRECTION: %s", args[i])
+			parsed, err := ParseDirection(args[i])
+			if err != nil {
+				return nil, err
+			}
+			direction = parsed
+			i++
+		} else if strings.ToUpper(args[i]) == "TOP" {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("TOP option requires an argument")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid TOP value: %s", args[i+1])
+			}
+			top = n
+			i += 2
+		} else if strings.ToUpper(args[i]) == "PAGE" {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("PAGE option requires a cursor")
+			}
+			cursor = &args[i+1]
+			i += 2
+		} else if strings.ToUpper(args[i]) == "COUNT" {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("COUNT option requires an argument")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid COUNT value: %s", args[i+1])
+			}
+			count = n
+			i += 2
+		} else if strings.ToUpper(args[i]) == "PASSTHROUGH" {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("PASSTHROUGH option requires an argument")
+			}
+			nodeTypes, err := parsePassThrough(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			passThrough = nodeTypes
+			i += 2
+		} else if strings.ToUpper(args[i]) == "FORMAT" {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("FORMAT option requires an argument")
+			}
+			tableFormat, err := parseTableFormat(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			format = tableFormat
+			i += 2
+		} else if strings.HasPrefix(args[i], "{") {
+			var params map[string]interface{}
+			if err := json.Unmarshal([]byte(args[i]), &params); err != nil {
+				return nil, fmt.Errorf("invalid parameters JSON: %w", err)
+			}
+			for key, value := range params {
+				number, ok := value.(float64)
+				if !ok {
+					return nil, fmt.Errorf("%s parameter must be a number", key)
+				}
+				switch key {
+				case "damping":
+					damping = number
+				case "iterations":
+					iterations = int(number)
+				case "tolerance":
+					tolerance = number
+				default:
+					return nil, fmt.Errorf("unknown centrality parameter: %s", key)
+				}
 			}
 			i++
 		} else {
 			if nodeID != nil {
 				return nil, fmt.Errorf("unexpected argument: %s. node_id already provided", args[i])
 			}
-			tempNodeID := models.NodeID(args[i])
+			tempNodeID, err := resolveNodeID(a.storage, graphID, args[i])
+			if err != nil {
+				return nil, err
+			}
 			nodeID = &tempNodeID
 			i++
 		}
 	}
 
+	if passThrough != nil {
+		if centralityType != "degree" {
+			return nil, fmt.Errorf("PASSTHROUGH is only supported for degree centrality")
+		}
+		if cursor != nil {
+			return nil, fmt.Errorf("PAGE cannot be combined with PASSTHROUGH")
+		}
+	}
+
+	if cursor != nil && format != "" {
+		return nil, fmt.Errorf("PAGE cannot be combined with FORMAT")
+	}
+
+	if cursor != nil {
+		if centralityType != "degree" {
+			return nil, fmt.Errorf("PAGE is only supported for degree centrality")
+		}
+		if nodeID != nil || top > 0 {
+			return nil, fmt.Errorf("PAGE cannot be combined with node_id or TOP")
+		}
+		if count == 0 {
+			count = defaultPageCount
+		}
+		return a.pageDegreeCentrality(graphID, direction, *cursor, count)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("COUNT requires PAGE")
+	}
+
 	switch centralityType {
 	case "degree":
-		scores, err := a.analyzer.CalculateDegreeCentrality(graphID, nodeID, direction)
+		ranked, err := a.rankDegreeCentrality(graphID, nodeID, direction, passThrough, top)
 		if err != nil {
-			return nil, fmt.Errorf("failed to calculate degree centrality: %w", err)
+			return nil, err
+		}
+		if format != "" {
+			return scoreTable(format, ranked)
+		}
+		return protocol.NewArrayResponse(ranked), nil
+	case "pagerank", "eigenvector", "betweenness", "closeness":
+		var scores map[models.NodeID]float64
+		var err error
+		switch centralityType {
+		case "pagerank":
+			scores, err = a.analyzer.CalculatePageRank(graphID, damping, iterations, tolerance, direction)
+		case "eigenvector":
+			scores, err = a.analyzer.CalculateEigenvectorCentrality(graphID, iterations, tolerance, direction)
+		case "betweenness":
+			scores, err = a.analyzer.CalculateBetweennessCentrality(graphID, nodeID, direction)
+		default:
+			scores, err = a.analyzer.CalculateClosenessCentrality(graphID, nodeID, direction)
+		}
+		// A convergence failure still yields the best estimate
+		if err != nil && !errors.Is(err, analysis.ErrNotConverged) {
+			return nil, fmt.Errorf("failed to calculate %s centrality: %w", centralityType, err)
 		}
 
-		response := make([]string, 0, len(scores)*2)
+		if nodeID != nil {
+			score, exists := scores[*nodeID]
+			if !exists {
+				return nil, fmt.Errorf("%w: %s", storage.ErrNodeNotFound, *nodeID)
+			}
+			scores = map[models.NodeID]float64{*nodeID: score}
+		}
+
+		ranked := make([]rankedScore, 0, len(scores))
 		for id, score := range scores {
-			response = append(response, string(id), strconv.Itoa(score))
+			ranked = append(ranked, rankedScore{id: id, score: score, value: strconv.FormatFloat(score, 'f', 6, 64)})
+		}
+		response := formatRankedScores(ranked, top)
+		if format != "" {
+			// A table has no place for the warning, so a ranking that did
+			// not converge is still returned as the best estimate
+			return scoreTable(format, response)
+		}
+		if err != nil {
+			response = append(response, "warning", err.Error())
 		}
 		return protocol.NewArrayResponse(response), nil
-	case "betweenness", "closeness":
-		// TODO: Implement betweenness and closeness centrality
-		return protocol.NewArrayResponse([]string{"centrality", centralityType, "not_implemented"}), nil
 	default:
 		return nil, fmt.Errorf("unknown centrality type: %s", centralityType)
 	}
 }
 
-// handleClustering handles ANALYSIS.CLUSTERING <graph> [algorithm] [parameters_json]
+// rankDegreeCentrality returns the degree centrality of one node, or of all
+// nodes ranked highest first, as id, score pairs. Nodes of the passThrough
+// types are contracted when any are given.
+func (a *AnalysisCommands) rankDegreeCentrality(graphID models.GraphID, nodeID *models.NodeID, direction types.TraversalDirection,
+	passThrough []models.NodeType, top int) ([]string, error) {
+	var scores map[models.NodeID]int
+	var err error
+	if passThrough != nil {
+		scores, err = a.analyzer.CalculateContractedDegreeCentrality(graphID, nodeID, direction, passThrough)
+	} else {
+		scores, err = a.analyzer.CalculateDegreeCentrality(graphID, nodeID, direction)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate degree centrality: %w", err)
+	}
+
+	ranked := make([]rankedScore, 0, len(scores))
+	for id, score := range scores {
+		ranked = append(ranked, rankedScore{id: id, score: float64(score), value: strconv.Itoa(score)})
+	}
+	return formatRankedScores(ranked, top), nil
+}
+
+// scoreTable replies with node, score pairs as a node,score table
+func scoreTable(format string, pairs []string) (*protocol.Response, error) {
+	rows := make([][]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		rows = append(rows, pairs[i:i+2])
+	}
+	return tableResponse(format, []string{"node", "score"}, rows)
+}
+
+// rankedScore is a node's centrality score and its formatted value
+type rankedScore struct {
+	id    models.NodeID
+	score float64
+	value string
+}
+
+// formatRankedScores sorts scores highest first (ties by node ID) and returns
+// the first top entries, or all of them when top is 0, as id, score pairs
+func formatRankedScores(ranked []rankedScore, top int) []string {
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].id < ranked[j].id
+	})
+	if top > 0 && top < len(ranked) {
+		ranked = ranked[:top]
+	}
+
+	response := make([]string, 0, len(ranked)*2)
+	for _, entry := range ranked {
+		response = append(response, string(entry.id), entry.value)
+	}
+	return response
+}
+
+// handleComponents handles ANALYSIS.COMPONENTS <graph> [EDGETYPES type1...] [FORMAT labels|groups]
+func (a *AnalysisCommands) handleComponents(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("ANALYSIS.COMPONENTS requires at least 1 argument: graph")
+	}
+
+	graphID := models.GraphID(args[0])
+	format := "labels"
+	options := &types.TraversalOptions{}
+
+	i := 1
+	for i < len(args) {
+		switch strings.ToUpper(args[i]) {
+		case "EDGETYPES":
+			i++
+			for i < len(args) && strings.ToUpper(args[i]) != "FORMAT" {
+				options.EdgeTypes = append(options.EdgeTypes, models.EdgeType(args[i]))
+				i++
+			}
+		case "FORMAT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("FORMAT option requires an argument")
+			}
+			format = strings.ToLower(args[i+1])
+			if format != "labels" && format != "groups" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'labels' or 'groups')", args[i+1])
+			}
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown option for ANALYSIS.COMPONENTS: %s", args[i])
+		}
+	}
+
+	if _, err := a.storage.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+	components, err := a.analyzer.ComputeComponents(graphID, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute components: %w", err)
+	}
+	nodes, err := a.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
+	if format == "groups" {
+		var groups [][]string
+		for _, node := range nodes {
+			label := components[node.ID]
+			for len(groups) <= label {
+				groups = append(groups, nil)
+			}
+			groups[label] = append(groups[label], string(node.ID))
+		}
+		response := make([]interface{}, len(groups))
+		for i, group := range groups {
+			response[i] = group
+		}
+		return protocol.NewNestedArrayResponse(response), nil
+	}
+
+	result := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, fmt.Sprintf("%s:%s:%d", node.ID, node.Type, components[node.ID]))
+	}
+	return protocol.NewArrayResponse(result), nil
+}
+
+// handleHotNodes handles ANALYSIS.HOTNODES <graph> [TOP n]
+func (a *AnalysisCommands) handleHotNodes(args []string) (*protocol.Response, error) {
+	if len(args) != 1 && len(args) != 3 {
+		return nil, fmt.Errorf("ANALYSIS.HOTNODES requires a graph and optionally TOP n")
+	}
+
+	graphID := models.GraphID(args[0])
+	top := 10
+	if len(args) == 3 {
+		if strings.ToUpper(args[1]) != "TOP" {
+			return nil, fmt.Errorf("unexpected argument: %s", args[1])
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid TOP value: %s", args[2])
+		}
+		top = n
+	}
+
+	if _, err := a.storage.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+	hot, err := a.storage.HotNodes(graphID, top)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hot nodes: %w", err)
+	}
+
+	result := make([]string, 0, len(hot)*2)
+	for _, entry := range hot {
+		result = append(result, string(entry.NodeID), strconv.FormatUint(entry.Reads, 10))
+	}
+	return protocol.NewArrayResponse(result), nil
+}
+
+// handleStats handles ANALYSIS.STATS <graph> [EDGETYPES type1...]
+// The reply is field and value pairs, ending with node_types and edge_types
+// fields whose values are arrays of type and count pairs sorted by type.
+// EDGETYPES follows only edges of the listed types for the root, leaf and
+// orphan counts, cycles, depth and components; the node and edge counts
+// cover the whole graph.
+func (a *AnalysisCommands) handleStats(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("ANALYSIS.STATS requires at least 1 argument: graph")
+	}
+
+	graphID := models.GraphID(args[0])
+	options := &types.TraversalOptions{Direction: types.DirectionForward}
+	if len(args) > 1 {
+		if strings.ToUpper(args[1]) != "EDGETYPES" {
+			return nil, fmt.Errorf("unknown option for ANALYSIS.STATS: %s", args[1])
+		}
+		if len(args) == 2 {
+			return nil, fmt.Errorf("EDGETYPES option requires at least one type")
+		}
+		for _, edgeType := range args[2:] {
+			options.EdgeTypes = append(options.EdgeTypes, models.EdgeType(edgeType))
+		}
+	}
+
+	if _, err := a.storage.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+	stats, err := a.analyzer.GetGraphStats(graphID, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph stats: %w", err)
+	}
+
+	response := []interface{}{
+		"node_count", strconv.Itoa(stats.NodeCount),
+		"edge_count", strconv.Itoa(stats.EdgeCount),
+		"root_node_count", strconv.Itoa(stats.RootNodeCount),
+		"leaf_node_count", strconv.Itoa(stats.LeafNodeCount),
+		"orphan_node_count", strconv.Itoa(stats.OrphanNodeCount),
+		"has_cycles", strconv.FormatBool(stats.HasCycles),
+		"max_depth", strconv.Itoa(stats.MaxDepth),
+		"connected_components", strconv.Itoa(stats.ConnectedComponents),
+	}
+	nodeTypes := make([]string, 0, len(stats.NodeTypeCount))
+	for nodeType := range stats.NodeTypeCount {
+		nodeTypes = append(nodeTypes, string(nodeType))
+	}
+	sort.Strings(nodeTypes)
+	nodeTypeCounts := make([]string, 0, len(nodeTypes)*2)
+	for _, nodeType := range nodeTypes {
+		nodeTypeCounts = append(nodeTypeCounts, nodeType, strconv.Itoa(stats.NodeTypeCount[models.NodeType(nodeType)]))
+	}
+	edgeTypes := make([]string, 0, len(stats.EdgeTypeCount))
+	for edgeType := range stats.EdgeTypeCount {
+		edgeTypes = append(edgeTypes, string(edgeType))
+	}
+	sort.Strings(edgeTypes)
+	edgeTypeCounts := make([]string, 0, len(edgeTypes)*2)
+	for _, edgeType := range edgeTypes {
+		edgeTypeCounts = append(edgeTypeCounts, edgeType, strconv.Itoa(stats.EdgeTypeCount[models.EdgeType(edgeType)]))
+	}
+	response = append(response, "node_types", nodeTypeCounts, "edge_types", edgeTypeCounts)
+	return protocol.NewNestedArrayResponse(response), nil
+}
+
+// handleStatsHistory handles ANALYSIS.STATSHISTORY <graph> [DAYS n]
+// [FORMAT fields|json]. The fields format replies with one group per
+// snapshot: its date followed by field and value pairs, with a
+// node_type:<type> and edge_type:<type> count per type.
+func (a *AnalysisCommands) handleStatsHistory(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("ANALYSIS.STATSHISTORY requires at least 1 argument: graph")
+	}
+
+	graphID := models.GraphID(args[0])
+	days := storage.DefaultStatsHistoryDays
+	format := "fields"
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("%s option requires an argument", strings.ToUpper(args[i]))
+		}
+		switch strings.ToUpper(args[i]) {
+		case "DAYS":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid DAYS value: %s", args[i+1])
+			}
+			days = n
+		case "FORMAT":
+			format = strings.ToLower(args[i+1])
+			if format != "fields" && format != "json" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'fields' or 'json')", args[i+1])
+			}
+		default:
+			return nil, fmt.Errorf("unknown option for ANALYSIS.STATSHISTORY: %s", args[i])
+		}
+	}
+
+	history, err := a.storage.StatsHistory(graphID, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats history: %w", err)
+	}
+
+	if format == "json" {
+		if history == nil {
+			history = []*models.StatsSnapshot{}
+		}
+		data, err := json.Marshal(history)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize stats history: %w", err)
+		}
+		return protocol.NewBulkResponse(string(data)), nil
+	}
+
+	response := make([]interface{}, len(history))
+	for i, snapshot := range history {
+		row := []string{
+			snapshot.Date,
+			"node_count", strconv.Itoa(snapshot.NodeCount),
+			"edge_count", strconv.Itoa(snapshot.EdgeCount),
+			"has_cycles", strconv.FormatBool(snapshot.HasCycles),
+			"connected_components", strconv.Itoa(snapshot.ConnectedComponents),
+		}
+		nodeTypes := make([]string, 0, len(snapshot.NodeTypeCount))
+		for nodeType := range snapshot.NodeTypeCount {
+			nodeTypes = append(nodeTypes, string(nodeType))
+		}
+		sort.Strings(nodeTypes)
+		for _, nodeType := range nodeTypes {
+			row = append(row, "node_type:"+nodeType, strconv.Itoa(snapshot.NodeTypeCount[models.NodeType(nodeType)]))
+		}
+		edgeTypes := make([]string, 0, len(snapshot.EdgeTypeCount))
+		for edgeType := range snapshot.EdgeTypeCount {
+			edgeTypes = append(edgeTypes, string(edgeType))
+		}
+		sort.Strings(edgeTypes)
+		for _, edgeType := range edgeTypes {
+			row = append(row, "edge_type:"+edgeType, strconv.Itoa(snapshot.EdgeTypeCount[models.EdgeType(edgeType)]))
+		}
+		response[i] = row
+	}
+	return protocol.NewNestedArrayResponse(response), nil
+}
+
+// handleClustering handles ANALYSIS.CLUSTERING <graph> [algorithm] [parameters_json] [FORCE]
 func (a *AnalysisCommands) handleClustering(args []string) (*protocol.Response, error) {
+	args, force := takeForce(args)
 	if len(args) < 1 {
 		return nil, fmt.Errorf("ANALYSIS.CLUSTERING requires at least 1 argument: graph")
 	}
@@ -214,7 +978,7 @@ func (a *AnalysisCommands) handleClustering(args []string) (*protocol.Response,
 	graphID := models.GraphID(args[0])
 	algorithm := "louvain" // default
 	if len(args) > 1 {
-		algorithm = args[1]
+		algorithm = strings.ToLower(args[1])
 	}
 
 	// Default parameters
@@ -224,7 +988,7 @@ func (a *AnalysisCommands) handleClustering(args []string) (*protocol.Response,
 	if len(args) > 2 {
 		var params map[string]interface{}
 		if err := json.Unmarshal([]byte(args[2]), &params); err != nil {
-			return nil, fmt.Errorf("invalid parameters JSON: %v", err)
+			return nil, fmt.Errorf("invalid parameters JSON: %w", err)
 		}
 		if res, ok := params["resolution"]; ok {
 			if r, ok := res.(float64); ok {
@@ -235,6 +999,12 @@ func (a *AnalysisCommands) handleClustering(args []string) (*protocol.Response,
 		}
 	}
 
+	if !force {
+		if err := a.checkSize(graphID, "clustering", a.limits.Clustering, ""); err != nil {
+			return nil, err
+		}
+	}
+
 	switch algorithm {
 	case "louvain":
 		communities, err := a.analyzer.CalculateLouvainClustering(graphID, resolution)
@@ -257,7 +1027,7 @@ func (a *AnalysisCommands) handleClustering(args []string) (*protocol.Response,
 	case "connected_components":
 		componentCount, err := a.analyzer.GetConnectedComponentCount(models.GraphID(graphID), nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to compute connected components: %v", err)
+			return nil, fmt.Errorf("failed to compute connected components: %w", err)
 		}
 		result := []string{"connected_components", strconv.Itoa(componentCount)}
 		return protocol.NewArrayResponse(result), nil
@@ -267,14 +1037,17 @@ func (a *AnalysisCommands) handleClustering(args []string) (*protocol.Response,
 	}
 }
 
-// handleCycles handles ANALYSIS.CYCLES <graph> [NODETYPE type1...] [EDGETYPE type1...] [FORMAT simple|detailed]
-func (a *AnalysisCommands) handleCycles(args []string) (*protocol.Response, error) {
+// handleCycles handles ANALYSIS.CYCLES <graph> [NODETYPE type1...] [EDGETYPE type1...] [FORMAT simple|detailed] [LABELS] [COUNT] [FORCE]
+func (a *AnalysisCommands) handleCycles(session *Session, args []string) (*protocol.Response, error) {
+	args, force := takeForce(args)
 	if len(args) < 1 {
 		return nil, fmt.Errorf("ANALYSIS.CYCLES requires at least 1 argument: graph")
 	}
 
 	graphID := args[0]
 	format := "detailed" // Default to detailed format
+	withLabels := false
+	withCount := false
 	options := &types.TraversalOptions{
 		Direction: types.DirectionForward,
 	}
@@ -282,16 +1055,16 @@ func (a *AnalysisCommands) handleCycles(args []string) (*protocol.Response, erro
 	// Parse optional filters
 	i := 1
 	for i < len(args) {
-		switch args[i] {
+		switch strings.ToUpper(args[i]) {
 		case "NODETYPE", "NODETYPES":
 			i++
-			for i < len(args) && args[i] != "EDGETYPE" && args[i] != "EDGETYPES" && args[i] != "FORMAT" {
+			for i < len(args) && !cycleKeywords[strings.ToUpper(args[i])] {
 				options.NodeTypes = append(options.NodeTypes, models.NodeType(args[i]))
 				i++
 			}
 		case "EDGETYPE", "EDGETYPES":
 			i++
-			for i < len(args) && args[i] != "NODETYPE" && args[i] != "NODETYPES" && args[i] != "FORMAT" {
+			for i < len(args) && !cycleKeywords[strings.ToUpper(args[i])] {
 				options.EdgeTypes = append(options.EdgeTypes, models.EdgeType(args[i]))
 				i++
 			}
@@ -300,19 +1073,37 @@ func (a *AnalysisCommands) handleCycles(args []string) (*protocol.Response, erro
 				return nil, fmt.Errorf("FORMAT option requires an argument")
 			}
 			i++
-			if args[i] != "simple" && args[i] != "detailed" {
-				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple' or 'detailed')", args[i])
+			format = strings.ToLower(args[i])
+			if format != "simple" && format != "detailed" && format != "json" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple', 'detailed' or 'json')", args[i])
 			}
-			format = args[i]
+			i++
+		case "LABELS":
+			withLabels = true
+			i++
+		case "COUNT":
+			withCount = true
 			i++
 		default:
 			return nil, fmt.Errorf("unknown option for ANALYSIS.CYCLES: %s", args[i])
 		}
 	}
 
-	cycles, err := a.analyzer.FindAllCycles(models.GraphID(graphID), options)
+	if !force {
+		advice := "NODETYPE and EDGETYPE limit the search to part of the graph"
+		if err := a.checkSize(models.GraphID(graphID), "cycle enumeration", a.limits.CycleEnumeration, advice); err != nil {
+			return nil, err
+		}
+	}
+
+	labels, err := newLabeler(a.storage, models.GraphID(graphID), withLabels, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check for cycles: %v", err)
+		return nil, err
+	}
+
+	cycles, err := a.analyzer.WithContext(session.Context()).FindAllCycles(models.GraphID(graphID), options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for cycles: %w", err)
 	}
 
 	if len(cycles) == 0 {
@@ -333,18 +1124,19 @@ func (a *AnalysisCommands) handleCycles(args []string) (*protocol.Response, erro
 		for nodeID := range uniqueNodes {
 			node, err := a.storage.GetNode(models.GraphID(graphID), nodeID)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get node %s: %v", nodeID, err)
+				return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
 			}
-			response = append(response, string(node.ID)+":"+string(node.Type))
+			response = append(response, labels.node(node))
 		}
 
 		// Sort for deterministic output
 		sort.Strings(response)
 
-		return protocol.NewArrayResponse(response), nil
+		return withCountIf(withCount, protocol.NewArrayResponse(response)), nil
 	}
 
-	return a.buildDetailedCycleResponse(models.GraphID(graphID), cycles)
+	response, err := a.buildDetailedCycleResponse(models.GraphID(graphID), cycles, labels)
+	return withCountIf(withCount, response), err
 }
 
 // buildSimpleCycleResponse creates a simple cycle response with nodeid:nodetype format
@@ -357,7 +1149,7 @@ func (a *AnalysisCommands) buildSimpleCycleResponse(graphID models.GraphID, cycl
 	for i, nodeID := range cyclePath {
 		node, err := a.storage.GetNode(graphID, nodeID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get node %s: %v", nodeID, err)
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
 		}
 		response[i] = string(node.ID) + ":" + string(node.Type)
 	}
@@ -366,7 +1158,7 @@ func (a *AnalysisCommands) buildSimpleCycleResponse(graphID models.GraphID, cycl
 }
 
 // buildDetailedCycleResponse creates a detailed response for multiple cycles with arrow notation.
-func (a *AnalysisCommands) buildDetailedCycleResponse(graphID models.GraphID, cycles [][]models.NodeID) (*protocol.Response, error) {
+func (a *AnalysisCommands) buildDetailedCycleResponse(graphID models.GraphID, cycles [][]models.NodeID, labels *labeler) (*protocol.Response, error) {
 	if len(cycles) == 0 {
 		return protocol.NewNullResponse(), nil
 	}
@@ -381,7 +1173,7 @@ func (a *AnalysisCommands) buildDetailedCycleResponse(graphID models.GraphID, cy
 		for i, nodeID := range cyclePath {
 			node, err := a.storage.GetNode(graphID, nodeID)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get node %s: %v", nodeID, err)
+				return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
 			}
 			nodeDetails[i] = node
 		}
@@ -391,17 +1183,13 @@ func (a *AnalysisCommands) buildDetailedCycleResponse(graphID models.GraphID, cy
 			currentNode := nodeDetails[i]
 			nextNode := nodeDetails[i+1]
 
-			pathBuilder.WriteString(string(currentNode.ID))
-			pathBuilder.WriteString(":")
-			pathBuilder.WriteString(string(currentNode.Type))
+			pathBuilder.WriteString(labels.node(currentNode))
 
 			edge, err := a.findEdgeBetweenNodes(graphID, currentNode.ID, nextNode.ID)
 			if err == nil && edge != nil {
 				arrow := buildArrow(currentNode.ID, nextNode.ID, edge)
 				pathBuilder.WriteString(arrow)
-				pathBuilder.WriteString(string(edge.ID))
-				pathBuilder.WriteString(":")
-				pathBuilder.WriteString(string(edge.Type))
+				pathBuilder.WriteString(labels.edge(edge))
 				pathBuilder.WriteString(arrow)
 			} else {
 				pathBuilder.WriteString("->unknown:unknown->")
@@ -409,29 +1197,122 @@ func (a *AnalysisCommands) buildDetailedCycleResponse(graphID models.GraphID, cy
 		}
 
 		lastNode := nodeDetails[len(nodeDetails)-1]
-		pathBuilder.WriteString(string(lastNode.ID))
-		pathBuilder.WriteString(":")
-		pathBuilder.WriteString(string(lastNode.Type))
+		pathBuilder.WriteString(labels.node(lastNode))
 
 		cycleStrings = append(cycleStrings, pathBuilder.String())
 	}
 
-	// Return cycle count and the detailed path strings
-	response := make([]string, 0, len(cycleStrings)+1)
-	response = append(response, strconv.Itoa(len(cycleStrings)))
-	response = append(response, cycleStrings...)
+	return protocol.NewArrayResponse(cycleStrings), nil
+}
 
-	return protocol.NewArrayResponse(response), nil
+// handleParallel handles ANALYSIS.PARALLEL <graph> [MIN n]
+func (a *AnalysisCommands) handleParallel(args []string) (*protocol.Response, error) {
+	if len(args) != 1 && len(args) != 3 {
+		return nil, fmt.Errorf("ANALYSIS.PARALLEL requires 1 argument: graph, and optionally MIN n")
+	}
+
+	graphID := args[0]
+	minCount := 2
+	if len(args) == 3 {
+		if strings.ToUpper(args[1]) != "MIN" {
+			return nil, fmt.Errorf("unknown option for ANALYSIS.PARALLEL: %s", args[1])
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 2 {
+			return nil, fmt.Errorf("invalid MIN value: %s (must be an integer >= 2)", args[2])
+		}
+		minCount = n
+	}
+
+	groups, err := a.analyzer.ParallelEdges(models.GraphID(graphID), minCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find parallel edges: %w", err)
+	}
+
+	// Format as from:to:type:count
+	result := make([]string, len(groups))
+	for i, group := range groups {
+		result[i] = fmt.Sprintf("%s:%s:%s:%d", group.FromNodeID, group.ToNodeID, group.Type, group.Count)
+	}
+
+	return protocol.NewArrayResponse(result), nil
+}
+
+// cycleKeywords ends the NODETYPE and EDGETYPE lists of ANALYSIS.CYCLES
+var cycleKeywords = map[string]bool{
+	"NODETYPE":  true,
+	"NODETYPES": true,
+	"EDGETYPE":  true,
+	"EDGETYPES": true,
+	"FORMAT":    true,
+	"LABELS":    true,
+	"COUNT":     true,
+}
+
+// traverseKeywords ends the NODETYPES and EDGETYPES lists of ANALYSIS.TRAVERSE
+var traverseKeywords = map[string]bool{
+	"NODETYPES":     true,
+	"EDGETYPES":     true,
+	"DIRECTION":     true,
+	"FORMAT":        true,
+	"LABELS":        true,
+	"AGE":           true,
+	"TERMINAL":      true,
+	"UPDATEDBEFORE": true,
+	"MAXFANOUT":     true,
+	"STRATEGY":      true,
+	"SEED":          true,
+	"TRANSITIONS":   true,
+	"PASSTHROUGH":   true,
+	"COUNT":         true,
+	"MAXDEPTH":      true,
+	"MINDEPTH":      true,
+}
+
+// parsePassThrough parses the PASSTHROUGH option: a comma-separated list of
+// the node types to cross without reporting
+func parsePassThrough(arg string) ([]models.NodeType, error) {
+	var nodeTypes []models.NodeType
+	for _, nodeType := range strings.Split(arg, ",") {
+		if nodeType == "" {
+			return nil, fmt.Errorf("invalid PASSTHROUGH: %s (must be a comma-separated list of node types)", arg)
+		}
+		nodeTypes = append(nodeTypes, models.NodeType(nodeType))
+	}
+	return nodeTypes, nil
+}
+
+// jsonResponse replies with value encoded as JSON
+func jsonResponse(value interface{}) (*protocol.Response, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	return protocol.NewBulkResponse(string(data)), nil
+}
+
+// parseTransitions parses the TRANSITIONS option: a JSON object mapping an
+// edge type, or "" for the start node, to the edge types that may follow it
+func parseTransitions(arg string) (map[models.EdgeType][]models.EdgeType, error) {
+	var transitions map[models.EdgeType][]models.EdgeType
+	if err := json.Unmarshal([]byte(arg), &transitions); err != nil || transitions == nil {
+		return nil, fmt.Errorf("invalid TRANSITIONS: %s (must be a JSON object of edge type lists)", arg)
+	}
+	return transitions, nil
 }
 
-// handleTraverse handles ANALYSIS.TRAVERSE <graph> <start_node> [DIRECTION dir] [NODETYPES type1...] [EDGETYPES type1...] [FORMAT simple|detailed]
-func (a *AnalysisCommands) handleTraverse(args []string) (*protocol.Response, error) {
+// handleTraverse handles ANALYSIS.TRAVERSE <graph> <start_node> [DIRECTION dir] [NODETYPES type1...] [EDGETYPES type1...] [FORMAT simple|detailed|json] [LABELS] [AGE] [TERMINAL] [COUNT] [UPDATEDBEFORE ts] [MAXFANOUT n [STRATEGY first|random [SEED n]]] [TRANSITIONS json] [PASSTHROUGH type,...] [MINDEPTH n] [MAXDEPTH n] [FORCE]
+func (a *AnalysisCommands) handleTraverse(session *Session, args []string) (*protocol.Response, error) {
+	args, force := takeForce(args)
 	if len(args) < 2 {
 		return nil, fmt.Errorf("ANALYSIS.TRAVERSE requires at least 2 arguments: graph, start_node")
 	}
 
 	graphID := models.GraphID(args[0])
-	startNodeID := models.NodeID(args[1])
+	startNodeID, err := resolveNodeID(a.storage, graphID, args[1])
+	if err != nil {
+		return nil, err
+	}
 
 	options := &types.TraversalOptions{
 		Direction: types.DirectionForward, // Default direction
@@ -439,38 +1320,38 @@ func (a *AnalysisCommands) handleTraverse(args []string) (*protocol.Response, er
 	}
 
 	format := "detailed" // Default to detailed format
+	withLabels := false
+	withAge := false
+	withTerminal := false
+	withCount := false
+	seeded := false
 
 	// Parse optional keyword arguments
 	i := 2
 	for i < len(args) {
-		switch args[i] {
+		switch strings.ToUpper(args[i]) {
 		case "DIRECTION":
 			if i+1 >= len(args) {
 				return nil, fmt.Errorf("DIRECTION option requires an argument")
 			}
 			i++
-			switch args[i] {
-			case "in":
-				options.Direction = types.DirectionBackward
-			case "out":
-				options.Direction = types.DirectionForward
-			case "both":
-				options.Direction = types.DirectionBoth
-			default:
-				return nil, fmt.Errorf("invalid DIRECTION: %s", args[i])
+			direction, err := ParseDirection(args[i])
+			if err != nil {
+				return nil, err
 			}
+			options.Direction = direction
 			i++
 		case "NODETYPES":
 			i++
 			// Accept multiple node types (OR logic)
-			for i < len(args) && args[i] != "EDGETYPES" && args[i] != "DIRECTION" && args[i] != "FORMAT" {
+			for i < len(args) && !traverseKeywords[strings.ToUpper(args[i])] {
 				options.NodeTypes = append(options.NodeTypes, models.NodeType(args[i]))
 				i++
 			}
 		case "EDGETYPES":
 			i++
 			// Accept multiple edge types (OR logic)
-			for i < len(args) && args[i] != "NODETYPES" && args[i] != "DIRECTION" && args[i] != "FORMAT" {
+			for i < len(args) && !traverseKeywords[strings.ToUpper(args[i])] {
 				options.EdgeTypes = append(options.EdgeTypes, models.EdgeType(args[i]))
 				i++
 			}
@@ -479,59 +1360,221 @@ func (a *AnalysisCommands) handleTraverse(args []string) (*protocol.Response, er
 				return nil, fmt.Errorf("FORMAT option requires an argument")
 			}
 			i++
-			if args[i] != "simple" && args[i] != "detailed" {
-				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple' or 'detailed')", args[i])
+			format = strings.ToLower(args[i])
+			if format != "simple" && format != "detailed" && format != "json" && format != "dot" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple', 'detailed', 'json' or 'dot')", args[i])
 			}
-			format = args[i]
 			i++
+		case "LABELS":
+			withLabels = true
+			i++
+		case "AGE":
+			withAge = true
+			i++
+		case "TERMINAL":
+			withTerminal = true
+			i++
+		case "COUNT":
+			withCount = true
+			i++
+		case "UPDATEDBEFORE":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("UPDATEDBEFORE option requires an argument")
+			}
+			cutoff, err := parseUpdatedBefore(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			options.UpdatedBefore = &cutoff
+			i += 2
+		case "MAXFANOUT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("MAXFANOUT option requires an argument")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid MAXFANOUT: %s (must be a positive integer)", args[i+1])
+			}
+			options.MaxFanout = n
+			i += 2
+		case "STRATEGY":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("STRATEGY option requires an argument")
+			}
+			strategy := types.FanoutStrategy(strings.ToLower(args[i+1]))
+			if strategy != types.FanoutFirst && strategy != types.FanoutRandom {
+				return nil, fmt.Errorf("invalid STRATEGY: %s (must be 'first' or 'random')", args[i+1])
+			}
+			options.FanoutStrategy = strategy
+			i += 2
+		case "SEED":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("SEED option requires an argument")
+			}
+			seed, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SEED: %s", args[i+1])
+			}
+			options.FanoutSeed = seed
+			seeded = true
+			i += 2
+		case "TRANSITIONS":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("TRANSITIONS option requires an argument")
+			}
+			transitions, err := parseTransitions(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			options.EdgeTypeTransitions = transitions
+			i += 2
+		case "PASSTHROUGH":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("PASSTHROUGH option requires an argument")
+			}
+			passThrough, err := parsePassThrough(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			options.PassThroughNodeTypes = passThrough
+			i += 2
+		case "MINDEPTH", "MAXDEPTH":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s option requires an argument", strings.ToUpper(args[i]))
+			}
+			depth, err := strconv.Atoi(args[i+1])
+			if err != nil || depth < 0 {
+				return nil, fmt.Errorf("invalid %s: %s (must be a non-negative integer)", strings.ToUpper(args[i]), args[i+1])
+			}
+			if strings.EqualFold(args[i], "MINDEPTH") {
+				options.MinDepth = depth
+			} else {
+				options.MaxDepth = depth
+			}
+			i += 2
 		default:
 			return nil, fmt.Errorf("unknown option for ANALYSIS.TRAVERSE: %s", args[i])
 		}
 	}
 
+	if options.FanoutStrategy != "" && options.MaxFanout == 0 {
+		return nil, fmt.Errorf("STRATEGY requires MAXFANOUT")
+	}
+	if seeded && options.FanoutStrategy != types.FanoutRandom {
+		return nil, fmt.Errorf("SEED requires STRATEGY random")
+	}
+	if options.MaxDepth >= 0 && options.MinDepth > options.MaxDepth {
+		return nil, fmt.Errorf("MINDEPTH %d exceeds MAXDEPTH %d", options.MinDepth, options.MaxDepth)
+	}
+	if withCount && (format == "json" || format == "dot") {
+		return nil, fmt.Errorf("COUNT cannot be combined with FORMAT %s", format)
+	}
+	if withTerminal && format != "detailed" {
+		return nil, fmt.Errorf("TERMINAL requires FORMAT detailed")
+	}
+
+	labels, err := newLabeler(a.storage, graphID, withLabels, withAge)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "detailed" && !force {
+		advice := "FORMAT simple visits each node once instead of listing every path, and MAXFANOUT, NODETYPES and EDGETYPES narrow the traversal"
+		if err := a.checkSize(graphID, "path enumeration", a.limits.PathEnumeration, advice); err != nil {
+			return nil, err
+		}
+	}
+
 	// Use AllPathsTraversal for detailed format to get multiple paths
 	if format == "detailed" {
-		allPaths, err := a.analyzer.AllPathsTraversal(models.GraphID(graphID), startNodeID, options)
+		allPaths, err := a.analyzer.WithContext(session.Context()).AllPathsTraversal(models.GraphID(graphID), startNodeID, options)
 		if err != nil {
-			return nil, fmt.Errorf("failed to perform multi-path traversal: %v", err)
+			return nil, fmt.Errorf("failed to perform multi-path traversal: %w", err)
 		}
 
 		if allPaths == nil || len(allPaths) == 0 {
 			return protocol.NewNullResponse(), nil
 		}
 
-		return a.buildMultiPathTraversalResponse(allPaths)
+		response, err := a.buildMultiPathTraversalResponse(allPaths, labels, withTerminal)
+		response = withCountIf(withCount, response)
+		if err != nil || options.MaxFanout == 0 {
+			return response, err
+		}
+		return withFanoutLimited(response, allPaths[0].FanoutLimitedNodes), nil
 	}
 
-	// Use single path traversal for simple format
-	result, err := a.analyzer.DepthFirstSearch(models.GraphID(graphID), startNodeID, options)
+	// Use single path traversal for the simple, JSON and DOT formats
+	result, err := a.analyzer.WithContext(session.Context()).DepthFirstSearch(models.GraphID(graphID), startNodeID, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to traverse graph: %v", err)
+		return nil, fmt.Errorf("failed to traverse graph: %w", err)
 	}
 
 	if result == nil {
 		return protocol.NewNullResponse(), nil
 	}
+	if format == "json" {
+		return jsonResponse(result)
+	}
+	if format == "dot" {
+		graph, err := a.storage.GetGraph(graphID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get graph: %w", err)
+		}
+		return dotResponse(string(graphID), result.Nodes, result.Edges, dotLabelAttr(graph, "")), nil
+	}
+
+	response, err := a.buildSimpleTraversalResponse(result, labels)
+	response = withCountIf(withCount, response)
+	if err != nil || options.MaxFanout == 0 {
+		return response, err
+	}
+	return withFanoutLimited(response, result.FanoutLimitedNodes), nil
+}
+
+// withFanoutLimited appends "fanout_limited" and the IDs of the nodes whose
+// edges MAXFANOUT cut to a traversal reply. Traversal entries always contain
+// a colon, so the marker cannot be mistaken for one. Null replies are
+// returned unchanged.
+func withFanoutLimited(response *protocol.Response, limited []models.NodeID) *protocol.Response {
+	if response.Type != protocol.ResponseTypeArray {
+		return response
+	}
+	values := append(response.ArrayValue, "fanout_limited")
+	for _, nodeID := range limited {
+		values = append(values, string(nodeID))
+	}
+	return protocol.NewArrayResponse(values)
+}
 
-	return a.buildSimpleTraversalResponse(result)
+// withCountIf prefixes an array reply with its number of entries when
+// withCount is set, for the COUNT flag. Replies carry no count otherwise;
+// nil, null and other replies are returned unchanged.
+func withCountIf(withCount bool, response *protocol.Response) *protocol.Response {
+	if !withCount || response == nil || response.Type != protocol.ResponseTypeArray {
+		return response
+	}
+	values := make([]string, 0, len(response.ArrayValue)+1)
+	values = append(values, strconv.Itoa(len(response.ArrayValue)))
+	return protocol.NewArrayResponse(append(values, response.ArrayValue...))
 }
 
 // buildSimpleTraversalResponse creates a simple traversal response with nodeid:nodetype format
-func (a *AnalysisCommands) buildSimpleTraversalResponse(result *types.TraversalResult) (*protocol.Response, error) {
+func (a *AnalysisCommands) buildSimpleTraversalResponse(result *types.TraversalResult, labels *labeler) (*protocol.Response, error) {
 	if len(result.Nodes) == 0 {
 		return protocol.NewNullResponse(), nil
 	}
 
 	response := make([]string, len(result.Nodes))
 	for i, node := range result.Nodes {
-		response[i] = string(node.ID) + ":" + string(node.Type)
+		response[i] = labels.node(node)
 	}
 
 	return protocol.NewArrayResponse(response), nil
 }
 
 // buildSimplePathResponse creates a simple path response with nodeid:nodetype format
-func (a *AnalysisCommands) buildSimplePathResponse(graphID models.GraphID, pathResult *types.PathResult) (*protocol.Response, error) {
+func (a *AnalysisCommands) buildSimplePathResponse(graphID models.GraphID, pathResult *types.PathResult, labels *labeler) (*protocol.Response, error) {
 	if len(pathResult.Path) == 0 {
 		return protocol.NewNullResponse(), nil
 	}
@@ -541,10 +1584,10 @@ func (a *AnalysisCommands) buildSimplePathResponse(graphID models.GraphID, pathR
 		// Get node details
 		node, err := a.storage.GetNode(graphID, nodeID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get node %s: %v", nodeID, err)
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
 		}
 
-		response[i] = string(node.ID) + ":" + string(node.Type)
+		response[i] = labels.node(node)
 	}
 
 	return protocol.NewArrayResponse(response), nil
@@ -595,12 +1638,7 @@ func (a *AnalysisCommands) buildDetailedTraversalResponse(graphID models.GraphID
 		paths = append(paths, pathBuilder.String())
 	}
 
-	// Return paths count followed by each path
-	response := make([]string, 0, len(paths)+1)
-	response = append(response, fmt.Sprintf("%d", len(paths)))
-	response = append(response, paths...)
-
-	return protocol.NewArrayResponse(response), nil
+	return protocol.NewArrayResponse(paths), nil
 }
 
 // buildArrow determines the correct arrow notation based on traversal direction.
@@ -613,31 +1651,39 @@ func buildArrow(fromNode, toNode models.NodeID, edge *models.Edge) string {
 	return "->" // Default for safety, though this case should be rare.
 }
 
-// buildMultiPathTraversalResponse creates response for multiple traversal paths
-func (a *AnalysisCommands) buildMultiPathTraversalResponse(allPaths []*types.TraversalResult) (*protocol.Response, error) {
-	response := make([]string, 0, len(allPaths)+1)
-	response = append(response, fmt.Sprintf("%d", len(allPaths)))
+// buildMultiPathTraversalResponse creates response for multiple traversal paths,
+// each followed by "|" and why it ended when withTerminal is set
+func (a *AnalysisCommands) buildMultiPathTraversalResponse(allPaths []*types.TraversalResult, labels *labeler, withTerminal bool) (*protocol.Response, error) {
+	response := make([]string, 0, len(allPaths))
 
 	for _, path := range allPaths {
 		var pathBuilder strings.Builder
 
+		offset := 0
 		for i, node := range path.Nodes {
-			pathBuilder.WriteString(string(node.ID))
-			pathBuilder.WriteString(":")
-			pathBuilder.WriteString(string(node.Type))
+			pathBuilder.WriteString(labels.node(node))
 
-			if i < len(path.Nodes)-1 && i < len(path.Edges) {
+			if i < len(path.Nodes)-1 && i < len(path.Hops) {
+				hop := path.Hops[i]
+				if offset+len(hop.Edges) <= len(path.Edges) {
+					writeHop(&pathBuilder, hop, path.Edges[offset:offset+len(hop.Edges)], labels)
+				}
+				offset += len(hop.Edges)
+			} else if i < len(path.Nodes)-1 && i < len(path.Edges) {
 				edge := path.Edges[i]
 				arrow := buildArrow(node.ID, path.Nodes[i+1].ID, edge)
 
 				pathBuilder.WriteString(arrow)
-				pathBuilder.WriteString(string(edge.ID))
-				pathBuilder.WriteString(":")
-				pathBuilder.WriteString(string(edge.Type))
+				pathBuilder.WriteString(labels.edge(edge))
 				pathBuilder.WriteString(arrow)
 			}
 		}
 
+		if withTerminal {
+			pathBuilder.WriteString("|")
+			pathBuilder.WriteString(string(path.Terminal))
+		}
+
 		response = append(response, pathBuilder.String())
 	}
 
@@ -645,17 +1691,16 @@ func (a *AnalysisCommands) buildMultiPathTraversalResponse(allPaths []*types.Tra
 }
 
 // buildMultiPathResponse creates response for multiple shortest paths
-func (a *AnalysisCommands) buildMultiPathResponse(allPaths []*types.PathResult) (*protocol.Response, error) {
-	response := make([]string, 0, len(allPaths)+1)
-	response = append(response, fmt.Sprintf("%d", len(allPaths)))
+func (a *AnalysisCommands) buildMultiPathResponse(graphID models.GraphID, allPaths []*types.PathResult, labels *labeler) (*protocol.Response, error) {
+	response := make([]string, 0, len(allPaths))
 
 	for _, pathResult := range allPaths {
 		// Get node details for each node in the path
 		nodeDetails := make([]*models.Node, len(pathResult.Path))
 		for i, nodeID := range pathResult.Path {
-			node, err := a.storage.GetNode(models.GraphID(pathResult.FromNodeID), nodeID)
+			node, err := a.storage.GetNode(graphID, nodeID)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get node %s: %v", nodeID, err)
+				return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
 			}
 			nodeDetails[i] = node
 		}
@@ -664,20 +1709,27 @@ func (a *AnalysisCommands) buildMultiPathResponse(allPaths []*types.PathResult)
 		var pathBuilder strings.Builder
 
 		for i, node := range nodeDetails {
-			pathBuilder.WriteString(string(node.ID))
-			pathBuilder.WriteString(":")
-			pathBuilder.WriteString(string(node.Type))
-
-			if i < len(nodeDetails)-1 && i < len(pathResult.Edges) {
+			pathBuilder.WriteString(labels.node(node))
+
+			if i < len(nodeDetails)-1 && i < len(pathResult.Hops) {
+				hop := pathResult.Hops[i]
+				edges := make([]*models.Edge, len(hop.Edges))
+				for j, edgeID := range hop.Edges {
+					edge, err := a.storage.GetEdge(graphID, edgeID)
+					if err != nil {
+						return nil, fmt.Errorf("failed to get edge %s: %w", edgeID, err)
+					}
+					edges[j] = edge
+				}
+				writeHop(&pathBuilder, hop, edges, labels)
+			} else if i < len(nodeDetails)-1 && i < len(pathResult.Edges) {
 				// Get edge details
 				edgeID := pathResult.Edges[i]
-				edge, err := a.storage.GetEdge(models.GraphID(pathResult.FromNodeID), edgeID)
+				edge, err := a.storage.GetEdge(graphID, edgeID)
 				if err == nil && edge != nil {
 					arrow := buildArrow(node.ID, nodeDetails[i+1].ID, edge)
 					pathBuilder.WriteString(arrow)
-					pathBuilder.WriteString(string(edge.ID))
-					pathBuilder.WriteString(":")
-					pathBuilder.WriteString(string(edge.Type))
+					pathBuilder.WriteString(labels.edge(edge))
 					pathBuilder.WriteString(arrow)
 				} else {
 					pathBuilder.WriteString("->unknown:unknown->")
@@ -691,12 +1743,27 @@ func (a *AnalysisCommands) buildMultiPathResponse(allPaths []*types.PathResult)
 	return protocol.NewArrayResponse(response), nil
 }
 
+// writeHop writes the edges of a hop across pass-through nodes, each
+// pass-through node written as (node_id) between the edges either side of it
+func writeHop(pathBuilder *strings.Builder, hop types.Hop, edges []*models.Edge, labels *labeler) {
+	ends := append(append([]models.NodeID{hop.From}, hop.Via...), hop.To)
+	for i, edge := range edges {
+		if i > 0 {
+			pathBuilder.WriteString("(" + string(hop.Via[i-1]) + ")")
+		}
+		arrow := buildArrow(ends[i], ends[i+1], edge)
+		pathBuilder.WriteString(arrow)
+		pathBuilder.WriteString(labels.edge(edge))
+		pathBuilder.WriteString(arrow)
+	}
+}
+
 // findEdgeBetweenNodes finds an edge between two nodes
 func (a *AnalysisCommands) findEdgeBetweenNodes(graphID models.GraphID, fromNode, toNode models.NodeID) (*models.Edge, error) {
 	// Get outgoing edges from the source node
 	outgoingEdges, err := a.storage.GetOutgoingEdges(graphID, fromNode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get outgoing edges from %s: %v", fromNode, err)
+		return nil, fmt.Errorf("failed to get outgoing edges from %s: %w", fromNode, err)
 	}
 
 	// Find edge that connects to the target node
@@ -709,7 +1776,7 @@ func (a *AnalysisCommands) findEdgeBetweenNodes(graphID models.GraphID, fromNode
 	// Also check incoming edges to the target node (which would be outgoing from other nodes)
 	incomingEdges, err := a.storage.GetIncomingEdges(graphID, toNode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get incoming edges to %s: %v", toNode, err)
+		return nil, fmt.Errorf("failed to get incoming edges to %s: %w", toNode, err)
 	}
 
 	for _, edge := range incomingEdges {
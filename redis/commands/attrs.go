@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// attrView is the part of an entity's attributes NODE.GET and EDGE.GET
+// reply with: all of them, a page of them by sorted key (ATTRS), or only
+// their keys (ATTRKEYS)
+type attrView struct {
+	paged    bool
+	keysOnly bool
+	offset   int
+	count    int
+}
+
+// parseAttrView parses the ATTRS <offset> <count> and ATTRKEYS options that
+// follow the ID of a GET command
+func parseAttrView(command string, args []string) (attrView, error) {
+	var view attrView
+	if len(args) == 0 {
+		return view, nil
+	}
+	switch strings.ToUpper(args[0]) {
+	case "ATTRS":
+		if len(args) != 3 {
+			return view, fmt.Errorf("ATTRS requires 2 arguments: offset, count")
+		}
+		offset, err := strconv.Atoi(args[1])
+		if err != nil || offset < 0 {
+			return view, fmt.Errorf("invalid ATTRS offset: %s (must be a non-negative integer)", args[1])
+		}
+		count, err := strconv.Atoi(args[2])
+		if err != nil || count <= 0 {
+			return view, fmt.Errorf("invalid ATTRS count: %s (must be a positive integer)", args[2])
+		}
+		view.paged, view.offset, view.count = true, offset, count
+	case "ATTRKEYS":
+		if len(args) != 1 {
+			return view, fmt.Errorf("ATTRKEYS takes no arguments")
+		}
+		view.keysOnly = true
+	default:
+		return view, fmt.Errorf("unknown option for %s: %s", command, args[0])
+	}
+	return view, nil
+}
+
+// sortedAttributeKeys returns the keys of attributes in sorted order
+func sortedAttributeKeys(attributes models.Attributes) []string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reply builds a GET reply from fields, which hold the entity's details
+// with the serialized attributes at index attrIndex. With ATTRKEYS the
+// reply is the sorted attribute keys alone. With ATTRS only the page of
+// attributes is serialized, never the whole map, and the total number of
+// attribute keys is appended to the fields.
+func (v attrView) reply(fields []string, attrIndex int, attributes models.Attributes) (*protocol.Response, error) {
+	if v.keysOnly {
+		return protocol.NewArrayResponse(sortedAttributeKeys(attributes)), nil
+	}
+
+	page := attributes
+	if v.paged {
+		keys := sortedAttributeKeys(attributes)
+		start := min(v.offset, len(keys))
+		end := min(start+v.count, len(keys))
+		page = make(models.Attributes, end-start)
+		for _, key := range keys[start:end] {
+			page[key] = attributes[key]
+		}
+	}
+	attributesJSON, err := json.Marshal(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize attributes: %w", err)
+	}
+	fields[attrIndex] = string(attributesJSON)
+	if v.paged {
+		fields = append(fields, strconv.Itoa(len(attributes)))
+	}
+	return protocol.NewArrayResponse(fields), nil
+}
+
+// parseAttributesJSON decodes the attributes JSON argument of a write
+// command. The decoder would replace invalid UTF-8 with U+FFFD, so it is
+// rejected first as BADARG, naming the first value or key holding it. The
+// engine checks the decoded attributes against its limits when writing.
+func parseAttributesJSON(kind string, arg string) (map[string]interface{}, error) {
+	var attributes map[string]interface{}
+	if err := json.Unmarshal([]byte(arg), &attributes); err != nil {
+		return nil, fmt.Errorf("invalid attributes JSON: %w", err)
+	}
+	if !utf8.ValidString(arg) {
+		return nil, invalidUTF8Error(kind, attributes)
+	}
+	return attributes, nil
+}
+
+// invalidUTF8Error is the BADARG error for attributes decoded from JSON that
+// was not valid UTF-8
+func invalidUTF8Error(kind string, attributes map[string]interface{}) error {
+	if path := replacedPath("attributes", attributes); path != "" {
+		return fmt.Errorf("%w %s %s is not valid UTF-8", models.ErrBadArgument, kind, path)
+	}
+	return fmt.Errorf("%w %s attributes are not valid UTF-8", models.ErrBadArgument, kind)
+}
+
+// replacedPath returns the path of the first string or key of v, in sorted
+// key order, that holds U+FFFD, or "" if none does
+func replacedPath(path string, v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if strings.ContainsRune(v, utf8.RuneError) {
+			return path
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if strings.ContainsRune(key, utf8.RuneError) {
+				return path + "." + key
+			}
+			if found := replacedPath(path+"."+key, v[key]); found != "" {
+				return found
+			}
+		}
+	case []interface{}:
+		for i, element := range v {
+			if found := replacedPath(fmt.Sprintf("%s[%d]", path, i), element); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// updateAttempts bounds how often an update is run again after a
+// concurrent write to the same entity made its transaction conflict
+const updateAttempts = 50
+
+// updateBackoff is the longest wait before the first retry of an update,
+// doubled for each further retry up to 32 times as long. The wait is
+// random, so updates that conflicted do not retry in lockstep.
+const updateBackoff = 100 * time.Microsecond
+
+// backoff waits before retrying an update that conflicted attempt times
+func backoff(attempt int) {
+	time.Sleep(time.Duration(rand.Int63n(int64(updateBackoff) << min(attempt, 5))))
+}
+
+// updateNode reads a node, changes it with change and writes it back in one
+// transaction, so a concurrent write to the node is never overwritten with
+// what was read before it: the transaction conflicts and is run again. It
+// reports whether the node was written, as an update leaving it as stored
+// is not.
+func updateNode(engine storage.StorageEngine, graphID models.GraphID, nodeID models.NodeID, generation *uint64, change func(node *models.Node) error) (bool, error) {
+	var written bool
+	var err error
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		err = writeGraph(engine, graphID, generation, func(tx storage.Transaction) error {
+			node, err := tx.GetNode(graphID, nodeID)
+			if err != nil {
+				return err
+			}
+			if node.IsExpired() {
+				return fmt.Errorf("%w: %s", storage.ErrNodeNotFound, nodeID)
+			}
+			if err := change(node); err != nil {
+				return err
+			}
+			node.UpdatedAt = time.Now()
+			written, err = tx.UpdateNodeWithResult(graphID, node)
+			return err
+		})
+		if !errors.Is(err, storage.ErrConflict) {
+			break
+		}
+		backoff(attempt)
+	}
+	return written, err
+}
+
+// updateEdge is updateNode for edges
+func updateEdge(engine storage.StorageEngine, graphID models.GraphID, edgeID models.EdgeID, generation *uint64, change func(edge *models.Edge) error) (bool, error) {
+	var written bool
+	var err error
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		err = writeGraph(engine, graphID, generation, func(tx storage.Transaction) error {
+			edge, err := tx.GetEdge(graphID, edgeID)
+			if err != nil {
+				return err
+			}
+			if edge.IsExpired() {
+				return fmt.Errorf("%w: %s", storage.ErrEdgeNotFound, edgeID)
+			}
+			if err := change(edge); err != nil {
+				return err
+			}
+			edge.UpdatedAt = time.Now()
+			written, err = tx.UpdateEdgeWithResult(graphID, edge)
+			return err
+		})
+		if !errors.Is(err, storage.ErrConflict) {
+			break
+		}
+		backoff(attempt)
+	}
+	return written, err
+}
+
+// mergeAttributes sets every key of patch in attributes, replacing nested
+// objects whole rather than merging into them, and returns the result
+func mergeAttributes(attributes models.Attributes, patch map[string]interface{}) models.Attributes {
+	if attributes == nil {
+		attributes = make(models.Attributes, len(patch))
+	}
+	for key, value := range patch {
+		attributes[key] = value
+	}
+	return attributes
+}
+
+// parseValueJSON decodes the value JSON argument of an ATTR.SET command,
+// rejecting invalid UTF-8 as parseAttributesJSON does
+func parseValueJSON(kind, key, arg string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(arg), &value); err != nil {
+		return nil, fmt.Errorf("invalid value JSON: %w", err)
+	}
+	if !utf8.ValidString(arg) {
+		return nil, invalidUTF8Error(kind, map[string]interface{}{key: value})
+	}
+	return value, nil
+}
+
+// handleAttrSet handles NODE.ATTR.SET <graph> <id> <key> <value_json>
+func (n *NodeCommands) handleAttrSet(args []string) (*protocol.Response, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("NODE.ATTR.SET requires exactly 4 arguments: graph, id, key, value_json")
+	}
+	graphID := models.GraphID(args[0])
+	nodeID, err := resolveNodeID(n.storage, graphID, args[1])
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseValueJSON("node", args[2], args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := updateNode(n.storage, graphID, nodeID, nil, func(node *models.Node) error {
+		node.Attributes = mergeAttributes(node.Attributes, map[string]interface{}{args[2]: value})
+		return nil
+	})
+	if err != nil {
+		return nil, wrapError("update node", err)
+	}
+	if !written {
+		return protocol.NewStringResponse("NOCHANGE"), nil
+	}
+	n.storage.RecordActivity(graphID, storage.ActivityNodeUpdate, 1)
+	return protocol.OK(), nil
+}
+
+// handleAttrDel handles NODE.ATTR.DEL <graph> <id> <key>. It replies with 1
+// if the key was removed and 0 if the node did not have it.
+func (n *NodeCommands) handleAttrDel(args []string) (*protocol.Response, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("NODE.ATTR.DEL requires exactly 3 arguments: graph, id, key")
+	}
+	graphID := models.GraphID(args[0])
+	nodeID, err := resolveNodeID(n.storage, graphID, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := updateNode(n.storage, graphID, nodeID, nil, func(node *models.Node) error {
+		delete(node.Attributes, args[2])
+		return nil
+	})
+	if err != nil {
+		return nil, wrapError("update node", err)
+	}
+	if !written {
+		return protocol.NewIntResponse(0), nil
+	}
+	n.storage.RecordActivity(graphID, storage.ActivityNodeUpdate, 1)
+	return protocol.NewIntResponse(1), nil
+}
+
+// handleAttrSet handles EDGE.ATTR.SET <graph> <id> <key> <value_json>
+func (e *EdgeCommands) handleAttrSet(args []string) (*protocol.Response, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("EDGE.ATTR.SET requires exactly 4 arguments: graph, id, key, value_json")
+	}
+	graphID := models.GraphID(args[0])
+	value, err := parseValueJSON("edge", args[2], args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := updateEdge(e.storage, graphID, models.EdgeID(args[1]), nil, func(edge *models.Edge) error {
+		edge.Attributes = mergeAttributes(edge.Attributes, map[string]interface{}{args[2]: value})
+		return nil
+	})
+	if err != nil {
+		return nil, wrapError("update edge", err)
+	}
+	if !written {
+		return protocol.NewStringResponse("NOCHANGE"), nil
+	}
+	e.storage.RecordActivity(graphID, storage.ActivityEdgeUpdate, 1)
+	return protocol.OK(), nil
+}
+
+// handleAttrDel handles EDGE.ATTR.DEL <graph> <id> <key>. It replies with 1
+// if the key was removed and 0 if the edge did not have it.
+func (e *EdgeCommands) handleAttrDel(args []string) (*protocol.Response, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("EDGE.ATTR.DEL requires exactly 3 arguments: graph, id, key")
+	}
+	graphID := models.GraphID(args[0])
+
+	written, err := updateEdge(e.storage, graphID, models.EdgeID(args[1]), nil, func(edge *models.Edge) error {
+		delete(edge.Attributes, args[2])
+		return nil
+	})
+	if err != nil {
+		return nil, wrapError("update edge", err)
+	}
+	if !written {
+		return protocol.NewIntResponse(0), nil
+	}
+	e.storage.RecordActivity(graphID, storage.ActivityEdgeUpdate, 1)
+	return protocol.NewIntResponse(1), nil
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// isTableFormat reports whether a FORMAT value asks for a CSV or TSV table
+func isTableFormat(format string) bool {
+	return format == "csv" || format == "tsv"
+}
+
+// parseTableFormat parses the FORMAT of a command whose only formats are
+// csv and tsv
+func parseTableFormat(value string) (string, error) {
+	format := strings.ToLower(value)
+	if !isTableFormat(format) {
+		return "", fmt.Errorf("invalid FORMAT: %s (must be 'csv' or 'tsv')", value)
+	}
+	return format, nil
+}
+
+// tableResponse replies with a header row followed by rows as a single bulk
+// string of CSV, or of TSV with tabs separating fields. Fields containing
+// the separator, a quote or a line break are quoted with their quotes
+// doubled, as in RFC 4180, so any CSV reader parses the records back.
+func tableResponse(format string, header []string, rows [][]string) (*protocol.Response, error) {
+	var table strings.Builder
+	w := csv.NewWriter(&table)
+	if format == "tsv" {
+		w.Comma = '\t'
+	}
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write table: %w", err)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("failed to write table: %w", err)
+	}
+	return protocol.NewBulkResponse(table.String()), nil
+}
+
+// attributesColumn renders attributes as one JSON table column
+func attributesColumn(attributes models.Attributes) (string, error) {
+	if attributes == nil {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode attributes: %w", err)
+	}
+	return string(encoded), nil
+}
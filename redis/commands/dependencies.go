@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// dependencyKeywords ends the NODETYPES and EDGETYPES lists of
+// ANALYSIS.DEPENDENCIES
+var dependencyKeywords = map[string]bool{
+	"DEPENDENTS": true,
+	"NODETYPES":  true,
+	"EDGETYPES":  true,
+	"MAXDEPTH":   true,
+	"FORMAT":     true,
+}
+
+// handleDependencies handles ANALYSIS.DEPENDENCIES <graph> <node> [DEPENDENTS] [NODETYPES type1...] [EDGETYPES type1...] [MAXDEPTH n] [FORMAT simple|detailed].
+// Entries are sorted by depth and then by node ID. The simple format lists
+// them as id:type, and the detailed one as id:type:depth:direct or
+// id:type:depth:transitive.
+func (a *AnalysisCommands) handleDependencies(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("ANALYSIS.DEPENDENCIES requires at least 2 arguments: graph, node")
+	}
+	graphID := models.GraphID(args[0])
+	nodeID, err := resolveNodeID(a.storage, graphID, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	options := &types.TraversalOptions{MaxDepth: -1}
+	dependents := false
+	format := "detailed"
+	i := 2
+	for i < len(args) {
+		switch strings.ToUpper(args[i]) {
+		case "DEPENDENTS":
+			dependents = true
+			i++
+		case "NODETYPES":
+			i++
+			for i < len(args) && !dependencyKeywords[strings.ToUpper(args[i])] {
+				options.NodeTypes = append(options.NodeTypes, models.NodeType(args[i]))
+				i++
+			}
+		case "EDGETYPES":
+			i++
+			for i < len(args) && !dependencyKeywords[strings.ToUpper(args[i])] {
+				options.EdgeTypes = append(options.EdgeTypes, models.EdgeType(args[i]))
+				i++
+			}
+		case "MAXDEPTH":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("MAXDEPTH option requires an argument")
+			}
+			depth, err := strconv.Atoi(args[i+1])
+			if err != nil || depth < 0 {
+				return nil, fmt.Errorf("invalid MAXDEPTH: %s (must be a non-negative integer)", args[i+1])
+			}
+			options.MaxDepth = depth
+			i += 2
+		case "FORMAT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("FORMAT option requires an argument")
+			}
+			format = strings.ToLower(args[i+1])
+			if format != "simple" && format != "detailed" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple' or 'detailed')", args[i+1])
+			}
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown option for ANALYSIS.DEPENDENCIES: %s", args[i])
+		}
+	}
+
+	var entries []types.DependencyEntry
+	if dependents {
+		entries, err = a.analyzer.GetDependentsWithDepth(graphID, nodeID, options)
+	} else {
+		entries, err = a.analyzer.GetDependenciesWithDepth(graphID, nodeID, options)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dependencies: %w", err)
+	}
+
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if format == "simple" {
+			result = append(result, fmt.Sprintf("%s:%s", entry.Node.ID, entry.Node.Type))
+			continue
+		}
+		kind := "transitive"
+		if entry.Direct {
+			kind = "direct"
+		}
+		result = append(result, fmt.Sprintf("%s:%s:%d:%s", entry.Node.ID, entry.Node.Type, entry.Depth, kind))
+	}
+	return protocol.NewArrayResponse(result), nil
+}
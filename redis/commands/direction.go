@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// ParseDirection parses a direction token in any case: out or forward
+// follows outgoing edges, in or backward incoming edges, and both or
+// bidirectional either. Every command taking a direction parses it here.
+func ParseDirection(token string) (types.TraversalDirection, error) {
+	switch strings.ToLower(token) {
+	case "out", "forward":
+		return types.DirectionForward, nil
+	case "in", "backward":
+		return types.DirectionBackward, nil
+	case "both", "bidirectional":
+		return types.DirectionBoth, nil
+	}
+	return 0, fmt.Errorf("invalid DIRECTION: %s (must be 'in', 'out', 'both', 'forward', 'backward' or 'bidirectional')", token)
+}
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// defaultDotLabelAttr is the node attribute DOT labels are taken from when
+// neither LABEL nor GRAPH.DISPLAY names one
+const defaultDotLabelAttr = "name"
+
+// dotShapes and dotColors style the node types of a DOT graph. Types are
+// styled in sorted order, so a type keeps its style as long as the set of
+// types stays the same.
+var (
+	dotShapes = []string{"box", "ellipse", "cylinder", "hexagon", "diamond", "component", "octagon", "parallelogram"}
+	dotColors = []string{"#a6cee3", "#b2df8a", "#fdbf6f", "#cab2d6", "#fb9a99", "#ffff99", "#8dd3c7", "#d9d9d9"}
+)
+
+// dotQuote quotes an ID or label as a DOT string, so IDs holding colons,
+// spaces or quotes are read back whole
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "").Replace(s)
+	return `"` + s + `"`
+}
+
+// dotLabelAttr returns the node attribute DOT labels are taken from: attr if
+// given, else the graph's display attribute, else "name"
+func dotLabelAttr(graph *models.Graph, attr string) string {
+	if attr != "" {
+		return attr
+	}
+	if graph != nil && graph.DisplayNodeAttr != "" {
+		return graph.DisplayNodeAttr
+	}
+	return defaultDotLabelAttr
+}
+
+// dotResponse replies with a digraph of nodes and edges as a bulk string.
+// Nodes are labelled with their labelAttr attribute, or their ID if they
+// have none, and styled by type; edges are labelled with their type.
+func dotResponse(name string, nodes []*models.Node, edges []*models.Edge, labelAttr string) *protocol.Response {
+	var typeNames []string
+	styles := make(map[models.NodeType]int)
+	for _, node := range nodes {
+		if _, ok := styles[node.Type]; !ok {
+			styles[node.Type] = 0
+			typeNames = append(typeNames, string(node.Type))
+		}
+	}
+	sort.Strings(typeNames)
+	for i, nodeType := range typeNames {
+		styles[models.NodeType(nodeType)] = i
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(name))
+	b.WriteString("  node [style=filled];\n")
+	for _, node := range nodes {
+		label := labelValue(node.Attributes, labelAttr)
+		if label == "" {
+			label = string(node.ID)
+		}
+		style := styles[node.Type]
+		fmt.Fprintf(&b, "  %s [label=%s, shape=%s, fillcolor=%s, tooltip=%s];\n",
+			dotQuote(string(node.ID)), dotQuote(label), dotShapes[style%len(dotShapes)],
+			dotQuote(dotColors[style%len(dotColors)]), dotQuote(string(node.Type)))
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n",
+			dotQuote(string(edge.FromNodeID)), dotQuote(string(edge.ToNodeID)), dotQuote(string(edge.Type)))
+	}
+	b.WriteString("}\n")
+	return protocol.NewBulkResponse(b.String())
+}
+
+// handleExportDot handles GRAPH.EXPORT.DOT <name> [LABEL <attr>]
+// Edges to nodes that no longer exist, such as weak edges left by a
+// deleted node, are left out.
+func (g *GraphCommands) handleExportDot(args []string) (*protocol.Response, error) {
+	if len(args) != 1 && len(args) != 3 {
+		return nil, fmt.Errorf("GRAPH.EXPORT.DOT requires 1 or 3 arguments: name, [LABEL attr]")
+	}
+	attr := ""
+	if len(args) == 3 {
+		if !strings.EqualFold(args[1], "LABEL") {
+			return nil, fmt.Errorf("unknown option for GRAPH.EXPORT.DOT: %s", args[1])
+		}
+		attr = args[2]
+	}
+
+	graphID := models.GraphID(args[0])
+	graph, err := g.storage.GetGraph(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+	nodes, err := g.storage.ListNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	edges, err := g.storage.ListEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+
+	exists := make(map[models.NodeID]bool, len(nodes))
+	for _, node := range nodes {
+		exists[node.ID] = true
+	}
+	live := edges[:0]
+	for _, edge := range edges {
+		if exists[edge.FromNodeID] && exists[edge.ToNodeID] {
+			live = append(live, edge)
+		}
+	}
+	return dotResponse(string(graph.ID), nodes, live, dotLabelAttr(graph, attr)), nil
+}
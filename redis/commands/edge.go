@@ -3,6 +3,7 @@ package commands
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -10,49 +11,160 @@ import (
 	"github.com/ywadi/PathwayDB/models"
 	"github.com/ywadi/PathwayDB/redis/protocol"
 	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
 )
 
 // EdgeCommands handles edge-related Redis commands
 type EdgeCommands struct {
-	storage storage.StorageEngine
+	storage   storage.StorageEngine
+	ids       IDGenerator
+	sortLimit int
 }
 
 // NewEdgeCommands creates a new edge commands handler
 func NewEdgeCommands(storageEngine storage.StorageEngine) *EdgeCommands {
 	return &EdgeCommands{
-		storage: storageEngine,
+		storage:   storageEngine,
+		ids:       defaultIDs,
+		sortLimit: DefaultMaxSortResults,
 	}
 }
 
+// SetIDGenerator replaces the generator of EDGE.CREATE AUTO IDs
+func (e *EdgeCommands) SetIDGenerator(ids IDGenerator) {
+	e.ids = ids
+}
+
+// SetSortLimit sets the most results ORDERBY sorts; 0 is not checked
+func (e *EdgeCommands) SetSortLimit(limit int) {
+	e.sortLimit = limit
+}
+
 // Handle routes edge commands to their respective handlers
 func (e *EdgeCommands) Handle(command string, args []string) (*protocol.Response, error) {
-	switch command {
-	case "CREATE":
-		return e.handleCreate(args)
-	case "GET":
-		return e.handleGet(args)
-	case "UPDATE":
-		return e.handleUpdate(args)
-	case "DELETE":
-		return e.handleDelete(args)
-	case "FILTER":
-		return e.handleFilter(args)
-	case "NEIGHBORS":
-		return e.handleNeighbors(args)
-	case "LIST":
-		return e.handleList(args)
-	case "EXISTS":
-		return e.handleExists(args)
-	default:
-		return nil, fmt.Errorf("unknown EDGE command: %s", command)
-	}
+	return route(e.Register, nil, "EDGE."+command, args)
 }
 
-// handleCreate handles EDGE.CREATE <graph> <id> <from> <to> <type> [attributes_json] [TTL <seconds>]
+// Register adds the edge commands to a registry
+func (e *EdgeCommands) Register(r *Registry) {
+	r.Register(CommandSpec{
+		Name:     "EDGE.CREATE",
+		Args:     "<graph> <id|AUTO> <from> <to> <type> [attributes_json] [TTL <seconds>] [WEAK] [NX] [IFGEN <generation>]",
+		Keywords: []string{"AUTO", "TTL", "WEAK", "NX", "IFGEN"},
+		Summary:  "Creates or fully replaces an edge between two nodes",
+		Example:  `EDGE.CREATE my-graph edge-ab service-a service-b depends_on '{"protocol":"http"}'`,
+		Handler:  sessionless(e.handleCreate),
+	})
+	r.Register(CommandSpec{
+		Name:    "EDGE.MCREATE",
+		Args:    "<graph> <json_array>",
+		Summary: "Creates a batch of edges in one transaction",
+		Example: `EDGE.MCREATE my-graph '[{"id":"edge-ab","from":"service-a","to":"db-1","type":"reads"}]'`,
+		Handler: sessionless(e.handleMCreate),
+	})
+	r.Register(CommandSpec{
+		Name:     "EDGE.GET",
+		Args:     "<graph> <id> [ATTRS <offset> <count> | ATTRKEYS]",
+		Keywords: []string{"ATTRS", "ATTRKEYS"},
+		Summary:  "Returns an edge's details",
+		Example:  "EDGE.GET my-graph edge-ab",
+		ReadOnly: true,
+		Handler:  sessionless(e.handleGet),
+	})
+	r.Register(CommandSpec{
+		Name:     "EDGE.MGET",
+		Args:     "<graph> <id> [<id> ...]",
+		Summary:  "Returns the details of several edges, with a null for each missing one",
+		Example:  "EDGE.MGET my-graph edge-ab edge-bc",
+		ReadOnly: true,
+		Handler:  sessionless(e.handleMGet),
+	})
+	r.Register(CommandSpec{
+		Name:     "EDGE.UPDATE",
+		Args:     "<graph> <id> [MERGE] <attributes_json> [TTL <seconds>] [IFGEN <generation>]",
+		Keywords: []string{"MERGE", "TTL", "IFGEN"},
+		Summary:  "Replaces an edge's attributes, or with MERGE merges keys into them, and optionally its TTL",
+		Example:  `EDGE.UPDATE my-graph edge-ab '{"protocol":"https"}'`,
+		Handler:  sessionless(e.handleUpdate),
+	})
+	r.Register(CommandSpec{
+		Name:    "EDGE.ATTR.SET",
+		Args:    "<graph> <id> <key> <value_json>",
+		Summary: "Sets one attribute of an edge, keeping the others",
+		Example: `EDGE.ATTR.SET my-graph edge-ab protocol '"grpc"'`,
+		Handler: sessionless(e.handleAttrSet),
+	})
+	r.Register(CommandSpec{
+		Name:    "EDGE.ATTR.DEL",
+		Args:    "<graph> <id> <key>",
+		Summary: "Removes one attribute of an edge",
+		Example: "EDGE.ATTR.DEL my-graph edge-ab protocol",
+		Handler: sessionless(e.handleAttrDel),
+	})
+	r.Register(CommandSpec{
+		Name:     "EDGE.DELETE",
+		Args:     "<graph> <id> [IFGEN <generation>]",
+		Keywords: []string{"IFGEN"},
+		Summary:  "Deletes an edge",
+		Example:  "EDGE.DELETE my-graph edge-ab",
+		Handler:  sessionless(e.handleDelete),
+	})
+	r.Register(CommandSpec{
+		Name:     "EDGE.FILTER",
+		Args:     "<graph> <attribute_key> <attribute_value> | <graph> [FROM <node_id>] [TO <node_id>] [TYPE <edge_type>] [FROMTYPE <node_type>] [TOTYPE <node_type>] [ATTR <key> <value>] [LIMIT <n>] [ORDERBY id|type|created|updated [DESC]]",
+		Keywords: []string{"FROM", "TO", "TYPE", "FROMTYPE", "TOTYPE", "ATTR", "LIMIT", "ORDERBY", "DESC"},
+		Summary:  "Finds the edges with an attribute value or matching endpoint and type selectors",
+		Example:  "EDGE.FILTER my-graph TYPE depends_on FROMTYPE service LIMIT 1",
+		ReadOnly: true,
+		Handler:  sessionless(e.handleFilter),
+	})
+	r.Register(CommandSpec{
+		Name:     "EDGE.NEIGHBORS",
+		Args:     "<graph> <node_id> [in|out|both] [FORMAT simple|detailed] [LABELS] [COUNT] [ORDERBY id|type|created|updated [DESC]]",
+		Keywords: []string{"FORMAT", "LABELS", "COUNT", "ORDERBY", "DESC"},
+		Defaults: []string{"direction both"},
+		Summary:  "Lists the nodes connected to a node",
+		Example:  "EDGE.NEIGHBORS my-graph service-a out FORMAT simple",
+		ReadOnly: true,
+		Handler:  sessionless(e.handleNeighbors),
+	})
+	r.Register(CommandSpec{
+		Name:     "EDGE.LIST",
+		Args:     "<graph> [ORPHANS] [VERBOSE | FORMAT csv|tsv] [ORDERBY id|type|created|updated [DESC]] | <graph> CURSOR <cursor> [COUNT <n>]",
+		Keywords: []string{"ORPHANS", "VERBOSE", "FORMAT", "ORDERBY", "DESC", "CURSOR", "COUNT"},
+		Summary:  "Lists the edges of a graph, or with ORPHANS its weak edges to deleted nodes, or with VERBOSE one [id, type, from, to, attributes, created_at, expires_at] array each",
+		Example:  "EDGE.LIST my-graph",
+		ReadOnly: true,
+		Handler:  sessionless(e.handleList),
+	})
+	r.Register(CommandSpec{
+		Name:     "EDGE.EXISTS",
+		Args:     "<graph> <id>",
+		Summary:  "Checks whether an edge exists",
+		Example:  "EDGE.EXISTS my-graph edge-ab",
+		ReadOnly: true,
+		Handler:  sessionless(e.handleExists),
+	})
+	r.Register(CommandSpec{
+		Name:    "EDGE.RETYPE",
+		Args:    "<graph> <old_type> <new_type>",
+		Summary: "Changes the type of every edge of one type",
+		Example: "EDGE.RETYPE my-graph depends_on calls",
+		Handler: sessionless(e.handleRetype),
+	})
+}
+
+// handleCreate handles EDGE.CREATE <graph> <id|AUTO> <from> <to> <type> [attributes_json] [TTL <seconds>] [WEAK] [NX] [IFGEN <generation>]
+// With AUTO the server generates the ID and replies with it. NX fails with
+// EXISTS rather than replacing an edge that has the ID.
 func (e *EdgeCommands) handleCreate(args []string) (*protocol.Response, error) {
 	if len(args) < 5 {
 		return nil, fmt.Errorf("EDGE.CREATE requires at least 5 arguments: graph, id, from, to, type")
 	}
+	args, generation, err := takeIfGen(args, 5)
+	if err != nil {
+		return nil, err
+	}
 
 	graphID := args[0]
 	edgeID := args[1]
@@ -62,6 +174,9 @@ func (e *EdgeCommands) handleCreate(args []string) (*protocol.Response, error) {
 
 	attributes := make(map[string]interface{})
 	var ttlSeconds int64 = -1
+	weak := false
+	auto := isAuto(edgeID)
+	nx := false
 
 	// Parse optional arguments
 	i := 5
@@ -73,25 +188,46 @@ func (e *EdgeCommands) handleCreate(args []string) (*protocol.Response, error) {
 			}
 			ttl, err := strconv.ParseInt(args[i+1], 10, 64)
 			if err != nil {
-				return nil, fmt.Errorf("invalid TTL value: %v", err)
+				return nil, fmt.Errorf("invalid TTL value: %w", err)
 			}
 			ttlSeconds = ttl
 			i += 2
+		case "WEAK":
+			weak = true
+			i++
+		case "NX":
+			nx = true
+			i++
 		default:
 			// Assume it's the attributes JSON
-			if err := json.Unmarshal([]byte(args[i]), &attributes); err != nil {
-				return nil, fmt.Errorf("invalid attributes JSON: %v", err)
+			parsed, err := parseAttributesJSON("edge", args[i])
+			if err != nil {
+				return nil, err
 			}
+			attributes = parsed
 			i++
 		}
 	}
 
+	from, err := resolveNodeID(e.storage, models.GraphID(graphID), fromNodeID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := resolveNodeID(e.storage, models.GraphID(graphID), toNodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	edge := &models.Edge{
 		ID:         models.EdgeID(edgeID),
-		FromNodeID: models.NodeID(fromNodeID),
-		ToNodeID:   models.NodeID(toNodeID),
+		FromNodeID: from,
+		ToNodeID:   to,
 		Type:       models.EdgeType(edgeType),
 		Attributes: attributes,
+		Weak:       weak,
+		CreatedAt:  now,
+		UpdatedAt:  now,
 	}
 
 	if ttlSeconds > 0 {
@@ -99,18 +235,42 @@ func (e *EdgeCommands) handleCreate(args []string) (*protocol.Response, error) {
 		edge.ExpiresAt = &expiresAt
 	}
 
-	err := e.storage.CreateEdge(models.GraphID(graphID), edge)
+	if err := edge.Validate(); err != nil {
+		return nil, err
+	}
+	err = writeGraph(e.storage, models.GraphID(graphID), generation, func(tx storage.Transaction) error {
+		if auto {
+			id, err := newEdgeID(tx, models.GraphID(graphID), e.ids)
+			if err != nil {
+				return err
+			}
+			edge.ID = id
+		} else if nx {
+			if err := requireNewEdge(tx, models.GraphID(graphID), edge.ID); err != nil {
+				return err
+			}
+		}
+		return tx.CreateEdge(models.GraphID(graphID), edge)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create edge: %v", err)
+		return nil, wrapError("create edge", err)
 	}
+	e.storage.RecordActivity(models.GraphID(graphID), storage.ActivityEdgeCreate, 1)
 
+	if auto {
+		return protocol.NewBulkResponse(string(edge.ID)), nil
+	}
 	return protocol.OK(), nil
 }
 
-// handleGet handles EDGE.GET <graph> <id>
+// handleGet handles EDGE.GET <graph> <id> [ATTRS <offset> <count> | ATTRKEYS]
 func (e *EdgeCommands) handleGet(args []string) (*protocol.Response, error) {
-	if len(args) != 2 {
-		return nil, fmt.Errorf("EDGE.GET requires exactly 2 arguments: graph, id")
+	if len(args) < 2 {
+		return nil, fmt.Errorf("EDGE.GET requires at least 2 arguments: graph, id")
+	}
+	view, err := parseAttrView("EDGE.GET", args[2:])
+	if err != nil {
+		return nil, err
 	}
 
 	graphID := args[0]
@@ -118,38 +278,37 @@ func (e *EdgeCommands) handleGet(args []string) (*protocol.Response, error) {
 
 	edge, err := e.storage.GetEdge(models.GraphID(graphID), models.EdgeID(edgeID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get edge: %v", err)
+		return nil, fmt.Errorf("failed to get edge: %w", err)
 	}
 
 	if edge == nil {
 		return protocol.NewNullResponse(), nil
 	}
 
-	// Serialize attributes
-	attributesJSON, err := json.Marshal(edge.Attributes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to serialize edge attributes: %v", err)
-	}
-
 	expiresAtStr := ""
 	if edge.ExpiresAt != nil {
 		expiresAtStr = edge.ExpiresAt.Format(time.RFC3339)
 	}
 
+	// The attributes are serialized into the fifth field
 	result := []string{
 		string(edge.ID),
 		string(edge.FromNodeID),
 		string(edge.ToNodeID),
 		string(edge.Type),
-		string(attributesJSON),
+		"",
 		expiresAtStr,
 	}
 
-	return protocol.NewArrayResponse(result), nil
+	return view.reply(result, 4, edge.Attributes)
 }
 
-// handleUpdate handles EDGE.UPDATE <graph> <id> <attributes_json> [TTL <seconds>]
+// handleUpdate handles EDGE.UPDATE <graph> <id> [MERGE] <attributes_json> [TTL <seconds>] [IFGEN <generation>]
 func (e *EdgeCommands) handleUpdate(args []string) (*protocol.Response, error) {
+	args, generation, err := takeIfGen(args, 3)
+	if err != nil {
+		return nil, err
+	}
 	if len(args) < 3 {
 		return nil, fmt.Errorf("EDGE.UPDATE requires at least 3 arguments: graph, id, attributes_json")
 	}
@@ -157,10 +316,17 @@ func (e *EdgeCommands) handleUpdate(args []string) (*protocol.Response, error) {
 	graphID := args[0]
 	edgeID := args[1]
 
-	// Parse new attributes
-	var attributes map[string]interface{}
-	if err := json.Unmarshal([]byte(args[2]), &attributes); err != nil {
-		return nil, fmt.Errorf("invalid attributes JSON: %v", err)
+	// Parse new attributes, or with MERGE the keys to merge into them
+	merge := strings.ToUpper(args[2]) == "MERGE"
+	if merge {
+		if len(args) < 4 {
+			return nil, fmt.Errorf("MERGE parameter requires a JSON value")
+		}
+		args = append(args[:2:2], args[3:]...)
+	}
+	attributes, err := parseAttributesJSON("edge", args[2])
+	if err != nil {
+		return nil, err
 	}
 
 	var ttlSeconds int64 = -1
@@ -168,43 +334,49 @@ func (e *EdgeCommands) handleUpdate(args []string) (*protocol.Response, error) {
 	if len(args) > 4 && strings.ToUpper(args[3]) == "TTL" {
 		ttl, err := strconv.ParseInt(args[4], 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid TTL value: %v", err)
+			return nil, fmt.Errorf("invalid TTL value: %w", err)
 		}
 		ttlSeconds = ttl
 	}
 
-	// Get the existing edge first
-	existingEdge, err := e.storage.GetEdge(models.GraphID(graphID), models.EdgeID(edgeID))
-	if err != nil {
-		return nil, fmt.Errorf("failed to get edge for update: %v", err)
-	}
-	if existingEdge == nil {
-		return nil, fmt.Errorf("edge not found")
-	}
-
-	// Update attributes
-	existingEdge.Attributes = attributes
-
-	if ttlSeconds >= 0 {
-		if ttlSeconds == 0 {
-			// TTL of 0 means remove expiration
-			existingEdge.ExpiresAt = nil
+	// The edge is read and written in one transaction, so MERGE keeps the
+	// keys a concurrent update sets. The update time it gets is what
+	// incremental exports select changes by. An update that leaves the edge
+	// as stored is not written.
+	written, err := updateEdge(e.storage, models.GraphID(graphID), models.EdgeID(edgeID), generation, func(edge *models.Edge) error {
+		if merge {
+			edge.Attributes = mergeAttributes(edge.Attributes, attributes)
 		} else {
-			expiresAt := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
-			existingEdge.ExpiresAt = &expiresAt
+			edge.Attributes = attributes
 		}
-	}
-
-	err = e.storage.UpdateEdge(models.GraphID(graphID), existingEdge)
+		if ttlSeconds >= 0 {
+			if ttlSeconds == 0 {
+				// TTL of 0 means remove expiration
+				edge.ExpiresAt = nil
+			} else {
+				expiresAt := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+				edge.ExpiresAt = &expiresAt
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update edge: %v", err)
+		return nil, wrapError("update edge", err)
+	}
+	if !written {
+		return protocol.NewStringResponse("NOCHANGE"), nil
 	}
+	e.storage.RecordActivity(models.GraphID(graphID), storage.ActivityEdgeUpdate, 1)
 
 	return protocol.OK(), nil
 }
 
-// handleDelete handles EDGE.DELETE <graph> <id>
+// handleDelete handles EDGE.DELETE <graph> <id> [IFGEN <generation>]
 func (e *EdgeCommands) handleDelete(args []string) (*protocol.Response, error) {
+	args, generation, err := takeIfGen(args, 2)
+	if err != nil {
+		return nil, err
+	}
 	if len(args) != 2 {
 		return nil, fmt.Errorf("EDGE.DELETE requires exactly 2 arguments: graph, id")
 	}
@@ -212,34 +384,87 @@ func (e *EdgeCommands) handleDelete(args []string) (*protocol.Response, error) {
 	graphID := args[0]
 	edgeID := args[1]
 
-	err := e.storage.DeleteEdge(models.GraphID(graphID), models.EdgeID(edgeID))
+	err = writeGraph(e.storage, models.GraphID(graphID), generation, func(tx storage.Transaction) error {
+		return tx.DeleteEdge(models.GraphID(graphID), models.EdgeID(edgeID))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete edge: %v", err)
+		return nil, wrapError("delete edge", err)
 	}
+	e.storage.RecordActivity(models.GraphID(graphID), storage.ActivityEdgeDelete, 1)
 
 	return protocol.OK(), nil
 }
 
-// handleFilter handles EDGE.FILTER <graph> <attribute_key> <attribute_value>
-func (e *EdgeCommands) handleFilter(args []string) (*protocol.Response, error) {
+// handleRetype handles EDGE.RETYPE <graph> <old_type> <new_type>
+func (e *EdgeCommands) handleRetype(args []string) (*protocol.Response, error) {
 	if len(args) != 3 {
-		return nil, fmt.Errorf("EDGE.FILTER requires exactly 3 arguments: graph, attribute_key, attribute_value")
+		return nil, fmt.Errorf("EDGE.RETYPE requires exactly 3 arguments: graph, old_type, new_type")
+	}
+	if err := models.ValidateType("edge", args[2]); err != nil {
+		return nil, err
 	}
 
-	graphID := args[0]
-	attrKey := args[1]
-	attrValue := args[2]
-
-	// Attempt to unmarshal the value as JSON, if it fails, use it as a string
-	var value interface{}
-	if err := json.Unmarshal([]byte(attrValue), &value); err != nil {
-		value = attrValue
+	count, err := e.storage.RenameEdgeType(models.GraphID(args[0]), models.EdgeType(args[1]), models.EdgeType(args[2]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename edge type: %w", err)
 	}
+	e.storage.RecordActivity(models.GraphID(args[0]), storage.ActivityEdgeUpdate, count)
+
+	return protocol.NewIntResponse(int64(count)), nil
+}
+
+// edgeFilterKeywords are the selectors of the structured EDGE.FILTER form
+var edgeFilterKeywords = map[string]bool{
+	"FROM":     true,
+	"TO":       true,
+	"TYPE":     true,
+	"FROMTYPE": true,
+	"TOTYPE":   true,
+	"ATTR":     true,
+	"LIMIT":    true,
+}
 
-	edges, err := e.storage.FindEdgesByAttribute(models.GraphID(graphID), attrKey, value)
+// handleFilter handles EDGE.FILTER <graph> <attribute_key> <attribute_value>
+// and EDGE.FILTER <graph> [FROM node] [TO node] [TYPE type] [FROMTYPE type] [TOTYPE type] [ATTR key value] [LIMIT n]
+func (e *EdgeCommands) handleFilter(args []string) (*protocol.Response, error) {
+	// ORDERBY must follow a filter, so it is never the attribute key
+	args, order, err := takeOrderBy(args, 3)
 	if err != nil {
-		return nil, fmt.Errorf("failed to filter edges by attribute: %v", err)
+		return nil, err
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf("EDGE.FILTER requires a graph and an attribute filter or selectors")
+	}
+
+	graphID := args[0]
+	var edges []*models.Edge
+	if len(args) == 3 && !edgeFilterKeywords[strings.ToUpper(args[1])] {
+		edges, err = e.storage.FindEdgesByAttribute(models.GraphID(graphID), args[1], parseAttributeValue(args[2]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter edges by attribute: %w", err)
+		}
+	} else {
+		filter, err := parseEdgeFilter(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		for _, endpoint := range []*models.NodeID{&filter.From, &filter.To} {
+			if *endpoint == "" {
+				continue
+			}
+			if *endpoint, err = resolveNodeID(e.storage, models.GraphID(graphID), string(*endpoint)); err != nil {
+				return nil, err
+			}
+		}
+		edges, err = e.storage.FilterEdges(models.GraphID(graphID), filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter edges: %w", err)
+		}
+	}
+	if err := order.check("EDGE.FILTER", len(edges), e.sortLimit); err != nil {
+		return nil, err
 	}
+	order.sortEdges(edges)
 
 	// Format response as array of edge data
 	result := make([]string, 0, len(edges)*5)
@@ -255,36 +480,110 @@ func (e *EdgeCommands) handleFilter(args []string) (*protocol.Response, error) {
 	return protocol.NewArrayResponse(result), nil
 }
 
-// handleNeighbors handles EDGE.NEIGHBORS <graph> <node_id> [direction] [FORMAT simple|detailed]
-// direction can be: "in", "out", "both" (default: "both")
+// parseEdgeFilter parses the selectors of the structured EDGE.FILTER form
+func parseEdgeFilter(args []string) (storage.EdgeFilter, error) {
+	var filter storage.EdgeFilter
+	for i := 0; i < len(args); {
+		keyword := strings.ToUpper(args[i])
+		if !edgeFilterKeywords[keyword] {
+			return filter, fmt.Errorf("unknown option for EDGE.FILTER: %s", args[i])
+		}
+		if keyword == "ATTR" {
+			if i+2 >= len(args) {
+				return filter, fmt.Errorf("ATTR option requires a key and a value")
+			}
+			filter.AttrKey = args[i+1]
+			filter.AttrValue = parseAttributeValue(args[i+2])
+			i += 3
+			continue
+		}
+		if i+1 >= len(args) {
+			return filter, fmt.Errorf("%s option requires an argument", keyword)
+		}
+		value := args[i+1]
+		switch keyword {
+		case "FROM":
+			filter.From = models.NodeID(value)
+		case "TO":
+			filter.To = models.NodeID(value)
+		case "TYPE":
+			filter.Type = models.EdgeType(value)
+		case "FROMTYPE":
+			filter.FromType = models.NodeType(value)
+		case "TOTYPE":
+			filter.ToType = models.NodeType(value)
+		case "LIMIT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return filter, fmt.Errorf("invalid LIMIT: %s (must be a positive integer)", value)
+			}
+			filter.Limit = n
+		}
+		i += 2
+	}
+	return filter, nil
+}
+
+// handleNeighbors handles EDGE.NEIGHBORS <graph> <node_id> [direction] [FORMAT simple|detailed] [LABELS] [COUNT] [ORDERBY id|type|created|updated [DESC]]
+// direction is any token ParseDirection accepts (default: "both")
+// ORDERBY sorts the neighbors by the fields of their connecting edges
+// LABELS appends the graph's display attribute to each node and edge (id:type:label)
+// COUNT prefixes the reply with the number of neighbors, in either format
 // FORMAT simple: returns neighbor_id:neighbor_type
 // FORMAT detailed: returns neighbor_id:neighbor_type<arrow>edge_id:edge_type
 //   where <arrow> is "<-" for incoming edges or "->" for outgoing edges
 func (e *EdgeCommands) handleNeighbors(args []string) (*protocol.Response, error) {
+	args, order, err := takeOrderBy(args, 2)
+	if err != nil {
+		return nil, err
+	}
 	if len(args) < 2 {
 		return nil, fmt.Errorf("EDGE.NEIGHBORS requires at least 2 arguments: graph, node_id")
 	}
 
 	graphID := args[0]
-	nodeID := args[1]
-	direction := "both"
-	format := "detailed" // Default to detailed format
+	resolved, err := resolveNodeID(e.storage, models.GraphID(graphID), args[1])
+	if err != nil {
+		return nil, err
+	}
+	nodeID := string(resolved)
+	direction := types.DirectionBoth // Neighbors default to both directions
+	format := "detailed"             // Default to detailed format
+	withLabels := false
+	withCount := false
 
 	// Parse optional arguments
 	for i := 2; i < len(args); i++ {
-		if args[i] == "FORMAT" && i+1 < len(args) {
+		keyword := strings.ToUpper(args[i])
+		if keyword == "FORMAT" && i+1 < len(args) {
 			i++
-			if args[i] != "simple" && args[i] != "detailed" {
+			format = strings.ToLower(args[i])
+			if format != "simple" && format != "detailed" {
 				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple' or 'detailed')", args[i])
 			}
-			format = args[i]
-		} else if args[i] == "in" || args[i] == "out" || args[i] == "both" {
-			direction = args[i]
-		} else if args[i] != "FORMAT" {
-			return nil, fmt.Errorf("invalid argument: %s", args[i])
+		} else if keyword == "LABELS" {
+			withLabels = true
+		} else if keyword == "COUNT" {
+			withCount = true
+		} else if keyword != "FORMAT" {
+			// Any other argument is a direction. Right after the node ID
+			// it can be nothing else, so the error lists the directions.
+			parsed, err := ParseDirection(args[i])
+			if err != nil {
+				if i == 2 {
+					return nil, err
+				}
+				return nil, fmt.Errorf("invalid argument: %s", args[i])
+			}
+			direction = parsed
 		}
 	}
 
+	labels, err := newLabeler(e.storage, models.GraphID(graphID), withLabels, false)
+	if err != nil {
+		return nil, err
+	}
+
 	type NeighborInfo struct {
 		Node     *models.Node
 		Edge     *models.Edge
@@ -294,10 +593,10 @@ func (e *EdgeCommands) handleNeighbors(args []string) (*protocol.Response, error
 	var neighborInfos []NeighborInfo
 
 	switch direction {
-	case "in":
+	case types.DirectionBackward:
 		incomingEdges, err := e.storage.GetIncomingEdges(models.GraphID(graphID), models.NodeID(nodeID))
 		if err != nil {
-			return nil, fmt.Errorf("failed to get incoming edges: %v", err)
+			return nil, fmt.Errorf("failed to get incoming edges: %w", err)
 		}
 		for _, edge := range incomingEdges {
 			node, err := e.storage.GetNode(models.GraphID(graphID), edge.FromNodeID)
@@ -309,10 +608,10 @@ func (e *EdgeCommands) handleNeighbors(args []string) (*protocol.Response, error
 				})
 			}
 		}
-	case "out":
+	case types.DirectionForward:
 		outgoingEdges, err := e.storage.GetOutgoingEdges(models.GraphID(graphID), models.NodeID(nodeID))
 		if err != nil {
-			return nil, fmt.Errorf("failed to get outgoing edges: %v", err)
+			return nil, fmt.Errorf("failed to get outgoing edges: %w", err)
 		}
 		for _, edge := range outgoingEdges {
 			node, err := e.storage.GetNode(models.GraphID(graphID), edge.ToNodeID)
@@ -324,11 +623,11 @@ func (e *EdgeCommands) handleNeighbors(args []string) (*protocol.Response, error
 				})
 			}
 		}
-	case "both":
+	case types.DirectionBoth:
 		// Get incoming edges
 		incomingEdges, err := e.storage.GetIncomingEdges(models.GraphID(graphID), models.NodeID(nodeID))
 		if err != nil {
-			return nil, fmt.Errorf("failed to get incoming edges: %v", err)
+			return nil, fmt.Errorf("failed to get incoming edges: %w", err)
 		}
 		for _, edge := range incomingEdges {
 			node, err := e.storage.GetNode(models.GraphID(graphID), edge.FromNodeID)
@@ -344,7 +643,7 @@ func (e *EdgeCommands) handleNeighbors(args []string) (*protocol.Response, error
 		// Get outgoing edges
 		outgoingEdges, err := e.storage.GetOutgoingEdges(models.GraphID(graphID), models.NodeID(nodeID))
 		if err != nil {
-			return nil, fmt.Errorf("failed to get outgoing edges: %v", err)
+			return nil, fmt.Errorf("failed to get outgoing edges: %w", err)
 		}
 		for _, edge := range outgoingEdges {
 			node, err := e.storage.GetNode(models.GraphID(graphID), edge.ToNodeID)
@@ -358,19 +657,26 @@ func (e *EdgeCommands) handleNeighbors(args []string) (*protocol.Response, error
 		}
 	}
 
+	if err := order.check("EDGE.NEIGHBORS", len(neighborInfos), e.sortLimit); err != nil {
+		return nil, err
+	}
+	if order.key != "" {
+		sort.Slice(neighborInfos, func(i, j int) bool {
+			return order.less(edgeSortKeys(neighborInfos[i].Edge), edgeSortKeys(neighborInfos[j].Edge))
+		})
+	}
+
 	// Simple format with nodeid:nodetype
 	if format == "simple" {
 		response := make([]string, len(neighborInfos))
 		for i, info := range neighborInfos {
-			response[i] = string(info.Node.ID) + ":" + string(info.Node.Type)
+			response[i] = labels.node(info.Node)
 		}
-		return protocol.NewArrayResponse(response), nil
+		return withCountIf(withCount, protocol.NewArrayResponse(response)), nil
 	}
 
 	// Enhanced detailed format with arrow notation consistent with ANALYSIS.TRAVERSE
-	result := make([]string, 0, len(neighborInfos)+1)
-	result = append(result, fmt.Sprintf("%d", len(neighborInfos)))
-	
+	result := make([]string, 0, len(neighborInfos))
 	for _, info := range neighborInfos {
 		// Determine arrow direction based on edge relationship
 		var arrow string
@@ -383,29 +689,101 @@ func (e *EdgeCommands) handleNeighbors(args []string) (*protocol.Response, error
 		}
 		
 		// Format: neighbor_node_id:neighbor_node_type<arrow>connecting_edge_id:connecting_edge_type
-		neighborStr := fmt.Sprintf("%s:%s%s%s:%s",
-			string(info.Node.ID),
-			string(info.Node.Type),
-			arrow,
-			string(info.Edge.ID),
-			string(info.Edge.Type))
+		neighborStr := labels.node(info.Node) + arrow + labels.edge(info.Edge)
 		result = append(result, neighborStr)
 	}
 
-	return protocol.NewArrayResponse(result), nil
+	return withCountIf(withCount, protocol.NewArrayResponse(result)), nil
 }
 
-// handleList handles EDGE.LIST <graph>
+// handleList handles EDGE.LIST <graph> [ORPHANS] [VERBOSE | FORMAT csv|tsv] [ORDERBY id|type|created|updated [DESC]]
+// or EDGE.LIST <graph> CURSOR <cursor> [COUNT <n>]
+// VERBOSE replies with one array per edge: id, type, from, to, attributes
+// JSON, created_at and expires_at, the times in RFC 3339 or "" when unset
 func (e *EdgeCommands) handleList(args []string) (*protocol.Response, error) {
-	if len(args) != 1 {
-		return nil, fmt.Errorf("EDGE.LIST requires exactly 1 argument: graph")
+	args, order, err := takeOrderBy(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	args, cursor, err := takeListCursor(args)
+	if err != nil {
+		return nil, err
+	}
+	if cursor.set {
+		if len(args) != 1 || order.key != "" {
+			return nil, fmt.Errorf("CURSOR cannot be combined with ORPHANS, VERBOSE, FORMAT or ORDERBY")
+		}
+		return e.listPage(models.GraphID(args[0]), cursor)
+	}
+	orphans := len(args) > 1 && strings.ToUpper(args[1]) == "ORPHANS"
+	if orphans {
+		args = append([]string{args[0]}, args[2:]...)
+	}
+	verbose := len(args) == 2 && strings.ToUpper(args[1]) == "VERBOSE"
+	if len(args) != 1 && !verbose && (len(args) != 3 || strings.ToUpper(args[1]) != "FORMAT") {
+		return nil, fmt.Errorf("EDGE.LIST requires 1 argument: graph, and optionally ORPHANS, then VERBOSE or FORMAT csv|tsv, then ORDERBY, or CURSOR")
 	}
 
 	graphID := args[0]
+	format := ""
+	if len(args) == 3 {
+		var err error
+		format, err = parseTableFormat(args[2])
+		if err != nil {
+			return nil, err
+		}
+	}
 	// Get all edges in the graph using ListEdges instead
 	edges, err := e.storage.ListEdges(models.GraphID(graphID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get graph: %v", err)
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+	if orphans {
+		var dangling []*models.Edge
+		for _, edge := range edges {
+			if edge.Dangling() {
+				dangling = append(dangling, edge)
+			}
+		}
+		edges = dangling
+	}
+	if err := order.check("EDGE.LIST", len(edges), e.sortLimit); err != nil {
+		return nil, err
+	}
+	order.sortEdges(edges)
+
+	if verbose {
+		response := make([]interface{}, 0, len(edges))
+		for _, edge := range edges {
+			attributesJSON, err := json.Marshal(edge.Attributes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize edge attributes: %w", err)
+			}
+			createdAt, expiresAt := "", ""
+			if !edge.CreatedAt.IsZero() {
+				createdAt = edge.CreatedAt.Format(time.RFC3339)
+			}
+			if edge.ExpiresAt != nil {
+				expiresAt = edge.ExpiresAt.Format(time.RFC3339)
+			}
+			response = append(response, []string{
+				string(edge.ID), string(edge.Type), string(edge.FromNodeID), string(edge.ToNodeID),
+				string(attributesJSON), createdAt, expiresAt,
+			})
+		}
+		return protocol.NewNestedArrayResponse(response), nil
+	}
+
+	if format != "" {
+		rows := make([][]string, 0, len(edges))
+		for _, edge := range edges {
+			attributes, err := attributesColumn(edge.Attributes)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, []string{string(edge.ID), string(edge.Type), string(edge.FromNodeID), string(edge.ToNodeID), attributes})
+		}
+		return tableResponse(format, []string{"id", "type", "from", "to", "attributes"}, rows)
 	}
 
 	if edges == nil {
@@ -421,6 +799,23 @@ func (e *EdgeCommands) handleList(args []string) (*protocol.Response, error) {
 	return protocol.NewArrayResponse(result), nil
 }
 
+// listPage replies to EDGE.LIST CURSOR with the next cursor followed by a
+// page of edges as id:type. Only the page is read from storage.
+func (e *EdgeCommands) listPage(graphID models.GraphID, cursor listCursor) (*protocol.Response, error) {
+	edges, more, err := e.storage.ListEdgesPage(graphID, models.EdgeID(cursor.after), cursor.count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get edges: %w", err)
+	}
+	lastID := ""
+	result := make([]string, 1, len(edges)+1)
+	for _, edge := range edges {
+		result = append(result, string(edge.ID)+":"+string(edge.Type))
+		lastID = string(edge.ID)
+	}
+	result[0] = cursor.next(lastID, more)
+	return protocol.NewArrayResponse(result), nil
+}
+
 // handleExists handles EDGE.EXISTS <graph> <id>
 func (e *EdgeCommands) handleExists(args []string) (*protocol.Response, error) {
 	if len(args) != 2 {
@@ -432,7 +827,7 @@ func (e *EdgeCommands) handleExists(args []string) (*protocol.Response, error) {
 
 	edge, err := e.storage.GetEdge(models.GraphID(graphID), models.EdgeID(edgeID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to check edge existence: %v", err)
+		return nil, fmt.Errorf("failed to check edge existence: %w", err)
 	}
 
 	if edge != nil {
@@ -0,0 +1,407 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// maxChunkBytes is the largest chunk GRAPH.EXPORT CHUNKED serves
+const maxChunkBytes = 64 << 20
+
+// exportSampleSize is the number of nodes and of edges serialized to
+// estimate the size of an export
+const exportSampleSize = 100
+
+// errTransferClosed is returned to a command whose transfer was closed
+// under it
+var errTransferClosed = errors.New("session closed")
+
+// exportTransfer serves an export document in chunks. The document is
+// written by storage.ExportGraph or storage.ExportGraphSince on its own
+// goroutine into a pipe, so it is produced only as fast as chunks are
+// requested.
+type exportTransfer struct {
+	mu         sync.Mutex
+	pipe       *io.PipeReader
+	reader     *bufio.Reader
+	chunkBytes int
+	// chunk holds the chunk being served. It grows with the data read
+	// rather than to chunkBytes up front, so small documents stay small.
+	chunk bytes.Buffer
+	seq   int
+}
+
+// newExportTransfer starts writing an export document with write
+func newExportTransfer(write func(w io.Writer) error, chunkBytes int) *exportTransfer {
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		pipeWriter.CloseWithError(write(pipeWriter))
+	}()
+	return &exportTransfer{
+		pipe:       pipeReader,
+		reader:     bufio.NewReader(pipeReader),
+		chunkBytes: chunkBytes,
+	}
+}
+
+// next returns the next chunk, its sequence number starting at 1, and
+// whether it is the last one
+func (t *exportTransfer) next() ([]byte, int, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.chunk.Reset()
+	_, err := io.CopyN(&t.chunk, t.reader, int64(t.chunkBytes))
+	last := false
+	switch err {
+	case nil:
+		// Only a chunk that exactly reaches the end needs a look ahead
+		if _, err := t.reader.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return nil, 0, false, err
+		}
+	case io.EOF:
+		last = true
+	default:
+		return nil, 0, false, err
+	}
+	t.seq++
+	return t.chunk.Bytes(), t.seq, last, nil
+}
+
+// close stops the export writer
+func (t *exportTransfer) close() {
+	t.pipe.CloseWithError(errTransferClosed)
+}
+
+// importTransfer collects the chunks of an import document in a temporary
+// file until it is committed
+type importTransfer struct {
+	mu      sync.Mutex
+	graphID models.GraphID
+	file    *os.File
+	size    int64
+}
+
+// close removes the collected document
+func (t *importTransfer) close() {
+	t.file.Close()
+	os.Remove(t.file.Name())
+}
+
+// SetTransferTimeout sets how long chunked export and import sessions
+// started afterwards may sit idle before they are discarded
+func (g *GraphCommands) SetTransferTimeout(timeout time.Duration) {
+	g.transfers.setTimeout(timeout)
+}
+
+// CloseSession ends the chunked exports and imports started by a
+// connection that has closed
+func (g *GraphCommands) CloseSession(session *Session) {
+	g.transfers.release(session)
+}
+
+// handleExport handles
+//
+//	GRAPH.EXPORT <name> [WITHMETA | SINCE <rfc3339>]
+//	GRAPH.EXPORT <name> [WITHMETA | SINCE <rfc3339>] CHUNKED <chunk_bytes> BEGIN
+//	GRAPH.EXPORT NEXT <session_id>
+//	GRAPH.EXPORT ABORT <session_id>
+func (g *GraphCommands) handleExport(session *Session, args []string) (*protocol.Response, error) {
+	withMeta := len(args) >= 2 && strings.ToUpper(args[1]) == "WITHMETA"
+	if withMeta {
+		args = append([]string{args[0]}, args[2:]...)
+	}
+	var since *time.Time
+	if len(args) >= 2 && strings.ToUpper(args[1]) == "SINCE" {
+		if len(args) < 3 {
+			return nil, fmt.Errorf("SINCE requires a timestamp")
+		}
+		if withMeta {
+			return nil, fmt.Errorf("WITHMETA cannot be combined with SINCE")
+		}
+		t, err := time.Parse(time.RFC3339Nano, args[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid SINCE timestamp, expected RFC 3339: %s", args[2])
+		}
+		since = &t
+		args = append([]string{args[0]}, args[3:]...)
+	}
+
+	// write writes the document the arguments ask for
+	write := func(graphID models.GraphID) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			if since != nil {
+				return g.storage.ExportGraphSince(graphID, *since, w)
+			}
+			return g.storage.ExportGraph(graphID, w, withMeta)
+		}
+	}
+
+	switch {
+	case len(args) == 1:
+		var buf bytes.Buffer
+		if err := write(models.GraphID(args[0]))(&buf); err != nil {
+			return nil, fmt.Errorf("failed to export graph: %w", err)
+		}
+		return protocol.NewBulkResponse(buf.String()), nil
+	case len(args) == 2 && !withMeta && since == nil && strings.ToUpper(args[0]) == "NEXT":
+		return g.handleExportNext(session, args[1])
+	case len(args) == 2 && !withMeta && since == nil && strings.ToUpper(args[0]) == "ABORT":
+		if !g.transfers.abort(session, args[1]) {
+			return nil, fmt.Errorf("unknown export session: %s", args[1])
+		}
+		return protocol.OK(), nil
+	case len(args) == 4 && strings.ToUpper(args[1]) == "CHUNKED" && strings.ToUpper(args[3]) == "BEGIN":
+		return g.handleExportBegin(session, models.GraphID(args[0]), withMeta, args[2], write(models.GraphID(args[0])))
+	default:
+		return nil, fmt.Errorf("GRAPH.EXPORT requires: name [WITHMETA | SINCE timestamp] [CHUNKED chunk_bytes BEGIN], or NEXT|ABORT session_id")
+	}
+}
+
+// handleExportBegin starts a chunked export written by write and returns
+// its session ID and the estimated number of chunks. The estimate of an
+// incremental export is that of the whole graph.
+func (g *GraphCommands) handleExportBegin(session *Session, graphID models.GraphID, withMeta bool, chunkArg string, write func(w io.Writer) error) (*protocol.Response, error) {
+	chunkBytes, err := strconv.Atoi(chunkArg)
+	if err != nil || chunkBytes < 1 || chunkBytes > maxChunkBytes {
+		return nil, fmt.Errorf("chunk size must be between 1 and %d bytes", maxChunkBytes)
+	}
+
+	size, err := g.estimateExportSize(graphID, withMeta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export graph: %w", err)
+	}
+
+	t := newExportTransfer(write, chunkBytes)
+	id, err := g.transfers.add(session, t)
+	if err != nil {
+		t.close()
+		return nil, wrapError("start export", err)
+	}
+	chunks := (size + chunkBytes - 1) / chunkBytes
+	return protocol.NewArrayResponse([]string{id, strconv.Itoa(max(chunks, 1))}), nil
+}
+
+// handleExportNext returns the next chunk of an export with its sequence
+// number and a last marker. The session ends after the last chunk.
+func (g *GraphCommands) handleExportNext(session *Session, id string) (*protocol.Response, error) {
+	t, exists := g.transfers.get(session, id)
+	export, ok := t.(*exportTransfer)
+	if !exists || !ok {
+		return nil, fmt.Errorf("unknown export session: %s", id)
+	}
+
+	chunk, seq, last, err := export.next()
+	if err != nil {
+		g.transfers.end(id)
+		return nil, fmt.Errorf("failed to export graph: %w", err)
+	}
+	lastMarker := "0"
+	if last {
+		g.transfers.end(id)
+		lastMarker = "1"
+	}
+	return protocol.NewArrayResponse([]string{string(chunk), strconv.Itoa(seq), lastMarker}), nil
+}
+
+// estimateExportSize estimates the size of a graph's export document from
+// its node and edge counts and the average size of the first nodes and
+// edges. Metadata, which is bounded by its quotas, is counted exactly.
+func (g *GraphCommands) estimateExportSize(graphID models.GraphID, withMeta bool) (int, error) {
+	graph, err := g.storage.GetGraph(graphID)
+	if err != nil {
+		return 0, err
+	}
+	graphJSON, err := graph.ToJSON()
+	if err != nil {
+		return 0, err
+	}
+	nodeCount, err := g.storage.CountNodes(graphID)
+	if err != nil {
+		return 0, err
+	}
+	edgeCount, err := g.storage.CountEdges(graphID)
+	if err != nil {
+		return 0, err
+	}
+
+	// sample returns the average serialized size of up to exportSampleSize
+	// entities, counting the separating comma
+	sample := func(scan func(fn func(data []byte) error) error) (int, error) {
+		total, sampled := 0, 0
+		err := scan(func(data []byte) error {
+			total += len(data) + 1
+			sampled++
+			if sampled == exportSampleSize {
+				return storage.ErrStopScan
+			}
+			return nil
+		})
+		if sampled == 0 {
+			return 0, err
+		}
+		return total / sampled, err
+	}
+	nodeSize, err := sample(func(fn func(data []byte) error) error {
+		return g.storage.ScanNodes(graphID, func(node *models.Node) error {
+			data, err := node.ToJSON()
+			if err != nil {
+				return err
+			}
+			return fn(data)
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	edgeSize, err := sample(func(fn func(data []byte) error) error {
+		return g.storage.ScanEdges(graphID, func(edge *models.Edge) error {
+			data, err := edge.ToJSON()
+			if err != nil {
+				return err
+			}
+			return fn(data)
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	metaSize := 0
+	if withMeta {
+		err := g.storage.ScanMeta(graphID, func(entry *models.MetaEntry) error {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			metaSize += len(data) + 1
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		metaSize += len(`,"meta":[]`)
+	}
+
+	const framing = len(`{"graph":,"nodes":[],"edges":[]}`)
+	return framing + len(graphJSON) + nodeCount*nodeSize + edgeCount*edgeSize + metaSize, nil
+}
+
+// handleImport handles
+//
+//	GRAPH.IMPORT <name> <document>
+//	GRAPH.IMPORT <name> BEGIN
+//	GRAPH.IMPORT APPEND <session_id> <data>
+//	GRAPH.IMPORT COMMIT <session_id>
+//	GRAPH.IMPORT ABORT <session_id>
+//
+// A document is always a JSON object, which tells it apart from a session ID.
+func (g *GraphCommands) handleImport(session *Session, args []string) (*protocol.Response, error) {
+	switch {
+	case len(args) == 2 && strings.HasPrefix(strings.TrimSpace(args[1]), "{"):
+		if err := models.ValidateID("graph", args[0]); err != nil {
+			return nil, err
+		}
+		nodes, edges, err := g.storage.ImportGraph(models.GraphID(args[0]), strings.NewReader(args[1]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to import graph: %w", err)
+		}
+		return protocol.NewArrayResponse([]string{strconv.Itoa(nodes), strconv.Itoa(edges)}), nil
+	case len(args) == 2 && strings.ToUpper(args[1]) == "BEGIN":
+		return g.handleImportBegin(session, models.GraphID(args[0]))
+	case len(args) == 3 && strings.ToUpper(args[0]) == "APPEND":
+		return g.handleImportAppend(session, args[1], args[2])
+	case len(args) == 2 && strings.ToUpper(args[0]) == "COMMIT":
+		return g.handleImportCommit(session, args[1])
+	case len(args) == 2 && strings.ToUpper(args[0]) == "ABORT":
+		if !g.transfers.abort(session, args[1]) {
+			return nil, fmt.Errorf("unknown import session: %s", args[1])
+		}
+		return protocol.OK(), nil
+	default:
+		return nil, fmt.Errorf("GRAPH.IMPORT requires: name document|BEGIN, APPEND session_id data, or COMMIT|ABORT session_id")
+	}
+}
+
+// handleImportBegin starts a chunked import into a new graph
+func (g *GraphCommands) handleImportBegin(session *Session, graphID models.GraphID) (*protocol.Response, error) {
+	if err := models.ValidateID("graph", string(graphID)); err != nil {
+		return nil, err
+	}
+	if _, err := g.storage.GetGraph(graphID); err == nil {
+		return nil, fmt.Errorf("graph %w: %s", storage.ErrAlreadyExists, graphID)
+	}
+
+	file, err := os.CreateTemp("", "pathwaydb-import-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start import: %w", err)
+	}
+	t := &importTransfer{graphID: graphID, file: file}
+	id, err := g.transfers.add(session, t)
+	if err != nil {
+		t.close()
+		return nil, wrapError("start import", err)
+	}
+	return protocol.NewBulkResponse(id), nil
+}
+
+// handleImportAppend adds data to an import and returns the number of
+// bytes received so far. Nothing is validated until COMMIT.
+func (g *GraphCommands) handleImportAppend(session *Session, id string, data string) (*protocol.Response, error) {
+	t, exists := g.transfers.get(session, id)
+	imp, ok := t.(*importTransfer)
+	if !exists || !ok {
+		return nil, fmt.Errorf("unknown import session: %s", id)
+	}
+
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	n, err := imp.file.WriteString(data)
+	imp.size += int64(n)
+	if err != nil {
+		g.transfers.end(id)
+		return nil, fmt.Errorf("failed to append to import: %w", err)
+	}
+	return protocol.NewIntResponse(imp.size), nil
+}
+
+// handleImportCommit validates the collected document, creates the graph
+// from it and returns the number of nodes and edges imported. The session
+// ends whether or not the import succeeds.
+func (g *GraphCommands) handleImportCommit(session *Session, id string) (*protocol.Response, error) {
+	t, exists := g.transfers.take(session, id)
+	imp, ok := t.(*importTransfer)
+	if !exists || !ok {
+		if exists {
+			t.close()
+		}
+		return nil, fmt.Errorf("unknown import session: %s", id)
+	}
+	defer imp.close()
+
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	if _, err := imp.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to import graph: %w", err)
+	}
+	nodes, edges, err := g.storage.ImportGraph(imp.graphID, imp.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import graph: %w", err)
+	}
+	return protocol.NewArrayResponse([]string{strconv.Itoa(nodes), strconv.Itoa(edges)}), nil
+}
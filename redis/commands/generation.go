@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// takeIfGen removes an IFGEN <generation> option following the first
+// positional arguments, returning the remaining arguments and the
+// generation, or nil if the option was not given
+func takeIfGen(args []string, positional int) ([]string, *uint64, error) {
+	for i := positional; i < len(args); i++ {
+		if strings.ToUpper(args[i]) != "IFGEN" {
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, fmt.Errorf("IFGEN option requires a generation")
+		}
+		generation, err := strconv.ParseUint(args[i+1], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid IFGEN value: %s", args[i+1])
+		}
+		rest := append(append([]string{}, args[:i]...), args[i+2:]...)
+		return rest, &generation, nil
+	}
+	return args, nil, nil
+}
+
+// writeGraph runs write in one transaction. With a generation, the
+// transaction first requires the graph to still be at it, and fails with
+// storage.ErrGenerationConflict if another write commits first.
+func writeGraph(engine storage.StorageEngine, graphID models.GraphID, generation *uint64, write func(tx storage.Transaction) error) error {
+	return engine.RunTransaction(func(tx storage.Transaction) error {
+		if generation != nil {
+			if err := tx.RequireGeneration(graphID, *generation); err != nil {
+				return err
+			}
+		}
+		return write(tx)
+	})
+}
@@ -1,8 +1,16 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/logging"
 	"github.com/ywadi/PathwayDB/models"
 	"github.com/ywadi/PathwayDB/redis/protocol"
 	"github.com/ywadi/PathwayDB/storage"
@@ -10,32 +18,250 @@ import (
 
 // GraphCommands handles graph-related Redis commands
 type GraphCommands struct {
-	storage storage.StorageEngine
+	storage   storage.StorageEngine
+	transfers *transferRegistry
+	logger    *slog.Logger
 }
 
 // NewGraphCommands creates a new graph commands handler
 func NewGraphCommands(storageEngine storage.StorageEngine) *GraphCommands {
 	return &GraphCommands{
-		storage: storageEngine,
+		storage:   storageEngine,
+		transfers: newTransferRegistry(DefaultTransferTimeout),
+		logger:    logging.Default(),
 	}
 }
 
 // Handle routes graph commands to their respective handlers
 func (g *GraphCommands) Handle(command string, args []string) (*protocol.Response, error) {
-	switch command {
-	case "CREATE":
-		return g.handleCreate(args)
-	case "DELETE":
-		return g.handleDelete(args)
-	case "LIST":
-		return g.handleList(args)
-	case "GET":
-		return g.handleGet(args)
-	case "EXISTS":
-		return g.handleExists(args)
-	default:
-		return nil, fmt.Errorf("unknown GRAPH command: %s", command)
-	}
+	return g.HandleSession(nil, command, args)
+}
+
+// HandleSession routes graph commands on behalf of a connection, which owns
+// the chunked exports and imports it starts
+func (g *GraphCommands) HandleSession(session *Session, command string, args []string) (*protocol.Response, error) {
+	return route(g.Register, session, "GRAPH."+command, args)
+}
+
+// Register adds the graph commands to a registry
+func (g *GraphCommands) Register(r *Registry) {
+	r.Register(CommandSpec{
+		Name:    "GRAPH.CREATE",
+		Args:    "<name> [description]",
+		Summary: "Creates a graph; quote a description that contains spaces",
+		Example: `GRAPH.CREATE my-graph "My first graph"`,
+		Handler: sessionless(g.handleCreate),
+	})
+	r.Register(CommandSpec{
+		Name:         "GRAPH.DELETE",
+		Args:         "<name> [CONFIRM <name>] [DRYRUN]",
+		Keywords:     []string{"CONFIRM", "DRYRUN"},
+		Summary:      "Deletes a graph with its nodes, edges, indexes and metadata, or with DRYRUN counts what it would remove",
+		Example:      "GRAPH.DELETE prod-graph CONFIRM prod-graph",
+		ReadOnlyWhen: isDryRun,
+		Handler:      g.handleDelete,
+	})
+	r.Register(CommandSpec{
+		Name:    "GRAPH.PROTECT",
+		Args:    "<name> [on|off]",
+		Summary: "Protects a graph from deletion without CONFIRM, or reports whether it is protected",
+		Example: "GRAPH.PROTECT prod-graph on",
+		ReadOnlyWhen: func(args []string) bool {
+			return len(args) == 1
+		},
+		Handler: g.handleProtect,
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.LIST",
+		Args:     "[WITHCOUNTS] [MATCHATTR <key> <value>]",
+		Keywords: []string{"WITHCOUNTS", "MATCHATTR"},
+		Summary:  "Lists the graphs with their names and timestamps, optionally with counts or those with a matching attribute",
+		Example:  "GRAPH.LIST WITHCOUNTS MATCHATTR team payments",
+		ReadOnly: true,
+		Handler:  sessionless(g.handleList),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.GET",
+		Args:     "<name>",
+		Summary:  "Returns a graph's details",
+		Example:  "GRAPH.GET my-graph",
+		ReadOnly: true,
+		Handler:  sessionless(g.handleGet),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.EXISTS",
+		Args:     "<name>",
+		Summary:  "Checks whether a graph exists",
+		Example:  "GRAPH.EXISTS my-graph",
+		ReadOnly: true,
+		Handler:  sessionless(g.handleExists),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.SET",
+		Args:     "<name> [NAME <name>] [DESCRIPTION <description>]",
+		Keywords: []string{"NAME", "DESCRIPTION"},
+		Summary:  "Changes a graph's display name or description",
+		Example:  `GRAPH.SET my-graph NAME "My Graph" DESCRIPTION "Service dependencies"`,
+		Handler:  sessionless(g.handleSet),
+	})
+	r.Register(CommandSpec{
+		Name:    "GRAPH.RENAME",
+		Args:    "<name> <new_name>",
+		Summary: "Gives a graph a new ID, moving its nodes, edges, indexes and metadata",
+		Example: "GRAPH.RENAME my-graph my-graph-v2",
+		Handler: sessionless(g.handleRename),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.SUBSCRIBE",
+		Args:     "<name> [name ...]",
+		Summary:  "Receives a message for every node and edge created, updated or deleted in the graphs",
+		Example:  "GRAPH.SUBSCRIBE my-graph",
+		ReadOnly: true,
+		Handler:  g.handleSubscribe,
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.UNSUBSCRIBE",
+		Args:     "[name ...]",
+		Summary:  "Stops the messages of GRAPH.SUBSCRIBE for the graphs, or for every graph",
+		Example:  "GRAPH.UNSUBSCRIBE my-graph",
+		ReadOnly: true,
+		Handler:  g.handleUnsubscribe,
+	})
+	r.Register(CommandSpec{
+		Name:         "GRAPH.DISPLAY",
+		Args:         "SET <name> <node_attr> [edge_attr] | GET <name>",
+		Keywords:     []string{"SET", "GET"},
+		Summary:      "Sets or reads the attributes shown by LABELS",
+		Example:      "GRAPH.DISPLAY SET my-graph name protocol",
+		ReadOnlyWhen: readOnlySubcommands("GET"),
+		Handler:      sessionless(g.handleDisplay),
+	})
+	r.Register(CommandSpec{
+		Name:    "GRAPH.SETATTR",
+		Args:    "<name> <key> <value_json>",
+		Summary: "Sets a metadata attribute on a graph",
+		Example: `GRAPH.SETATTR my-graph schedule '{"cron":"0 * * * *"}'`,
+		Handler: sessionless(g.handleSetAttr),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.GETATTR",
+		Args:     "<name> [key]",
+		Summary:  "Returns one metadata attribute, or all of them",
+		Example:  "GRAPH.GETATTR my-graph schedule",
+		ReadOnly: true,
+		Handler:  sessionless(g.handleGetAttr),
+	})
+	r.Register(CommandSpec{
+		Name:    "GRAPH.DELATTR",
+		Args:    "<name> <key>",
+		Summary: "Removes a metadata attribute from a graph",
+		Example: "GRAPH.DELATTR my-graph schedule",
+		Handler: sessionless(g.handleDelAttr),
+	})
+	r.Register(CommandSpec{
+		Name: "GRAPH.SNAPSHOT",
+		Args: "CREATE <name> [label] | LIST <name> | DELETE <name> <snapshot_id> | " +
+			"DIFF <name> <snapshot_id> [FORMAT summary|full]",
+		Keywords:     []string{"CREATE", "LIST", "DELETE", "DIFF", "FORMAT"},
+		Summary:      "Manages point-in-time copies of a graph",
+		Example:      "GRAPH.SNAPSHOT CREATE my-graph nightly",
+		ReadOnlyWhen: readOnlySubcommands("LIST", "DIFF"),
+		Handler:      sessionless(g.handleSnapshot),
+	})
+	r.Register(CommandSpec{
+		Name:         "GRAPH.CONSTRAINT",
+		Args:         "SET <name> SELFLOOPS ALLOW|FORBID [EDGETYPE <type>] | GET <name>",
+		Keywords:     []string{"SET", "GET", "SELFLOOPS", "EDGETYPE"},
+		Summary:      "Sets or reads the structural constraints of a graph",
+		Example:      "GRAPH.CONSTRAINT SET my-graph SELFLOOPS FORBID",
+		ReadOnlyWhen: readOnlySubcommands("GET"),
+		Handler:      sessionless(g.handleConstraint),
+	})
+	r.Register(CommandSpec{
+		Name:         "GRAPH.POLICY",
+		Args:         "SET <name> <policy_json> | GET <name> | STATUS <name> [PREVIEW]",
+		Keywords:     []string{"SET", "GET", "STATUS", "PREVIEW"},
+		Summary:      "Sets or reads the maintenance policy of a graph",
+		Example:      "GRAPH.POLICY STATUS my-graph PREVIEW",
+		ReadOnlyWhen: readOnlySubcommands("GET", "STATUS"),
+		Handler:      sessionless(g.handlePolicy),
+	})
+	r.Register(CommandSpec{
+		Name:         "GRAPH.SELFLOOPS",
+		Args:         "<name> [DELETE]",
+		Keywords:     []string{"DELETE"},
+		Summary:      "Lists the self-loops of a graph, or deletes them",
+		Example:      "GRAPH.SELFLOOPS my-graph DELETE",
+		ReadOnlyWhen: func(args []string) bool { return len(args) == 1 },
+		Handler:      sessionless(g.handleSelfLoops),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.EXPORT",
+		Args:     "<name> [WITHMETA | SINCE <rfc3339>] [CHUNKED <chunk_bytes> BEGIN] | NEXT <session_id> | ABORT <session_id>",
+		Keywords: []string{"WITHMETA", "SINCE", "CHUNKED", "BEGIN", "NEXT", "ABORT"},
+		Summary:  "Exports a graph as one JSON document, whole or in chunks",
+		Example:  "GRAPH.EXPORT my-graph CHUNKED 65536 BEGIN",
+		ReadOnly: true,
+		Handler:  g.handleExport,
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.EXPORT.DOT",
+		Args:     "<name> [LABEL <attr>]",
+		Keywords: []string{"LABEL"},
+		Defaults: []string{"LABEL the GRAPH.DISPLAY node attribute, else name"},
+		Summary:  "Exports a graph as a Graphviz DOT digraph",
+		Example:  "GRAPH.EXPORT.DOT my-graph LABEL name",
+		ReadOnly: true,
+		Handler:  sessionless(g.handleExportDot),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.IMPORT",
+		Args:     "<name> <document> | <name> BEGIN | APPEND <session_id> <data> | COMMIT <session_id> | ABORT <session_id>",
+		Keywords: []string{"BEGIN", "APPEND", "COMMIT", "ABORT"},
+		Summary:  "Creates a graph from a GRAPH.EXPORT document, whole or in chunks",
+		Example:  "GRAPH.IMPORT my-graph-copy BEGIN",
+		Handler:  g.handleImport,
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.MERGE",
+		Args:     "<dst> FROM <src> [ONCONFLICT skip|overwrite|error]",
+		Keywords: []string{"FROM", "ONCONFLICT"},
+		Summary:  "Copies the nodes and edges of one graph into another",
+		Example:  "GRAPH.MERGE platform FROM payments-infra ONCONFLICT skip",
+		Handler:  sessionless(g.handleMerge),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.PATTERN",
+		Args:     "<name> CHAIN <node_type> <edge_type> <id1> <id2> [id...] | STAR <hub_id> <hub_type> <edge_type> <leaf_type> <leaf1> [leaf...]",
+		Keywords: []string{"CHAIN", "STAR"},
+		Summary:  "Creates a chain or star of nodes and edges in one transaction, reusing those that exist",
+		Example:  "GRAPH.PATTERN my-graph CHAIN service calls gateway checkout payments",
+		Handler:  sessionless(g.handlePattern),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.ACTIVITY",
+		Args:     "<name> [HOURS n]",
+		Keywords: []string{"HOURS"},
+		Summary:  "Returns hourly node and edge create, update and delete counts",
+		Example:  "GRAPH.ACTIVITY my-graph HOURS 24",
+		Handler:  sessionless(g.handleActivity),
+	})
+	r.Register(CommandSpec{
+		Name:    "GRAPH.GENERATION",
+		Args:    "<name>",
+		Summary: "Returns the generation IFGEN checks node and edge writes against",
+		Example: "GRAPH.GENERATION my-graph",
+		Handler: sessionless(g.handleGeneration),
+	})
+	r.Register(CommandSpec{
+		Name:     "GRAPH.ADJACENCY",
+		Args:     "<name> [NODETYPES type1...] [EDGETYPES type1...] [CURSOR <cursor> [COUNT <n>]]",
+		Keywords: []string{"NODETYPES", "EDGETYPES", "CURSOR", "COUNT"},
+		Summary:  "Returns one array per node of its edges and neighbors, for client-side layout",
+		Example:  "GRAPH.ADJACENCY my-graph EDGETYPES depends_on CURSOR 0 COUNT 500",
+		ReadOnly: true,
+		Handler:  sessionless(g.handleAdjacency),
+	})
 }
 
 // handleCreate handles GRAPH.CREATE <name> [description]
@@ -43,6 +269,9 @@ func (g *GraphCommands) handleCreate(args []string) (*protocol.Response, error)
 	if len(args) < 1 {
 		return nil, fmt.Errorf("GRAPH.CREATE requires at least 1 argument: name")
 	}
+	if len(args) > 2 {
+		return nil, unquotedTextError("GRAPH.CREATE requires 1 or 2 arguments: name, [description]", "description")
+	}
 
 	name := args[0]
 	description := ""
@@ -50,48 +279,182 @@ func (g *GraphCommands) handleCreate(args []string) (*protocol.Response, error)
 		description = args[1]
 	}
 
+	now := time.Now()
 	graph := &models.Graph{
 		ID:          models.GraphID(name),
 		Name:        name,
 		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
+	if err := models.ValidateID("graph", name); err != nil {
+		return nil, err
+	}
+	// Creating a graph again replaces its record, but never lifts its
+	// protection
+	if existing, err := g.storage.GetGraph(graph.ID); err == nil && existing.Protected {
+		graph.Protected = true
+	}
 	err := g.storage.CreateGraph(graph)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create graph: %v", err)
+		return nil, fmt.Errorf("failed to create graph: %w", err)
 	}
 
 	return protocol.OK(), nil
 }
 
-// handleDelete handles GRAPH.DELETE <name>
-func (g *GraphCommands) handleDelete(args []string) (*protocol.Response, error) {
-	if len(args) != 1 {
-		return nil, fmt.Errorf("GRAPH.DELETE requires exactly 1 argument: name")
+// unquotedTextError reports arguments after a trailing free-text argument.
+// They are almost always the rest of the text sent unquoted, so they are
+// rejected as BADARG instead of silently dropped.
+func unquotedTextError(usage string, text string) error {
+	return fmt.Errorf("%w %s; quote a %s containing spaces as a single argument", models.ErrBadArgument, usage, text)
+}
+
+// handleDelete handles GRAPH.DELETE <name> [CONFIRM <name>] [DRYRUN]
+func (g *GraphCommands) handleDelete(session *Session, args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("GRAPH.DELETE requires at least 1 argument: name")
 	}
 
-	name := args[0]
-	err := g.storage.DeleteGraph(models.GraphID(name))
+	graphID := models.GraphID(args[0])
+	confirm, dryRun := "", false
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "CONFIRM":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("CONFIRM option requires the graph name")
+			}
+			i++
+			confirm = args[i]
+		case "DRYRUN":
+			dryRun = true
+		default:
+			return nil, fmt.Errorf("unknown option for GRAPH.DELETE: %s", args[i])
+		}
+	}
+
+	// A dry run removes nothing, so it needs no confirmation
+	if dryRun {
+		return g.deletionCounts(graphID)
+	}
+	if err := g.checkProtected(session, "GRAPH.DELETE", graphID, confirm); err != nil {
+		return nil, err
+	}
+
+	_, err := g.storage.DeleteGraph(graphID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete graph: %v", err)
+		return nil, fmt.Errorf("failed to delete graph: %w", err)
 	}
 
 	return protocol.OK(), nil
 }
 
-// handleList handles GRAPH.LIST
+// isDryRun is the ReadOnlyWhen of commands that take DRYRUN
+func isDryRun(args []string) bool {
+	for _, arg := range args[min(1, len(args)):] {
+		if strings.ToUpper(arg) == "DRYRUN" {
+			return true
+		}
+	}
+	return false
+}
+
+// deletionCounts replies to GRAPH.DELETE DRYRUN with field and value
+// pairs: the graph, and the nodes, edges and keys deleting it would remove
+func (g *GraphCommands) deletionCounts(graphID models.GraphID) (*protocol.Response, error) {
+	if _, err := g.storage.GetGraph(graphID); err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+	nodes, err := g.storage.CountNodes(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count nodes: %w", err)
+	}
+	edges, err := g.storage.CountEdges(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count edges: %w", err)
+	}
+	keys, err := g.storage.CountGraphKeys(graphID)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.NewArrayResponse([]string{
+		"graph", string(graphID),
+		"nodes", strconv.Itoa(nodes),
+		"edges", strconv.Itoa(edges),
+		"keys", strconv.Itoa(keys),
+	}), nil
+}
+
+// handleList handles GRAPH.LIST [WITHCOUNTS] [MATCHATTR <key> <value>],
+// replying with one array per graph: id, name, description and the
+// creation and update times, followed by the node and edge counts with
+// WITHCOUNTS. Counting reads every node and edge key of a graph, so it is
+// left out by default.
 func (g *GraphCommands) handleList(args []string) (*protocol.Response, error) {
+	var matchKey string
+	var matchValue interface{}
+	withCounts := false
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WITHCOUNTS":
+			withCounts = true
+		case "MATCHATTR":
+			if i+2 >= len(args) {
+				return nil, fmt.Errorf("MATCHATTR option requires a key and a value")
+			}
+			matchKey = args[i+1]
+			matchValue = parseAttributeValue(args[i+2])
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown option for GRAPH.LIST: %s", args[i])
+		}
+	}
+
 	graphs, err := g.storage.ListGraphs()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list graphs: %v", err)
+		return nil, fmt.Errorf("failed to list graphs: %w", err)
 	}
 
-	result := make([]string, 0, len(graphs)*2)
+	result := make([]interface{}, 0, len(graphs))
 	for _, graph := range graphs {
-		result = append(result, string(graph.ID), graph.Description)
+		if matchKey != "" {
+			value, exists := graph.GetAttribute(matchKey)
+			if !exists || !models.ValuesEqual(value, matchValue) {
+				continue
+			}
+		}
+		row := []string{
+			string(graph.ID),
+			graph.Name,
+			graph.Description,
+			formatGraphTime(graph.CreatedAt),
+			formatGraphTime(graph.UpdatedAt),
+		}
+		if withCounts {
+			nodeCount, err := g.storage.CountNodes(graph.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count nodes: %w", err)
+			}
+			edgeCount, err := g.storage.CountEdges(graph.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count edges: %w", err)
+			}
+			row = append(row, strconv.Itoa(nodeCount), strconv.Itoa(edgeCount))
+		}
+		result = append(result, row)
 	}
 
-	return protocol.NewArrayResponse(result), nil
+	return protocol.NewNestedArrayResponse(result), nil
+}
+
+// formatGraphTime renders a graph timestamp as RFC3339 in UTC, or "" for
+// graphs created before timestamps were recorded
+func formatGraphTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
 }
 
 // handleGet handles GRAPH.GET <name>
@@ -103,22 +466,27 @@ func (g *GraphCommands) handleGet(args []string) (*protocol.Response, error) {
 	name := args[0]
 	graph, err := g.storage.GetGraph(models.GraphID(name))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get graph: %v", err)
+		return nil, fmt.Errorf("failed to get graph: %w", err)
 	}
 
 	if graph == nil {
 		return protocol.NewNullResponse(), nil
 	}
 
-	// Return graph info as array: [id, name, description, node_count, edge_count]
+	// Return graph info as array: [id, name, description, node_count, edge_count, attributes_json]
 	nodeCount, err := g.storage.CountNodes(graph.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count nodes: %v", err)
+		return nil, fmt.Errorf("failed to count nodes: %w", err)
 	}
 
 	edgeCount, err := g.storage.CountEdges(graph.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count edges: %v", err)
+		return nil, fmt.Errorf("failed to count edges: %w", err)
+	}
+
+	attributesJSON, err := json.Marshal(graph.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize attributes: %w", err)
 	}
 	
 	result := []string{
@@ -127,6 +495,7 @@ func (g *GraphCommands) handleGet(args []string) (*protocol.Response, error) {
 		graph.Description,
 		fmt.Sprintf("%d", nodeCount),
 		fmt.Sprintf("%d", edgeCount),
+		string(attributesJSON),
 	}
 
 	return protocol.NewArrayResponse(result), nil
@@ -141,7 +510,7 @@ func (g *GraphCommands) handleExists(args []string) (*protocol.Response, error)
 	name := args[0]
 	graph, err := g.storage.GetGraph(models.GraphID(name))
 	if err != nil {
-		return nil, fmt.Errorf("failed to check graph existence: %v", err)
+		return nil, fmt.Errorf("failed to check graph existence: %w", err)
 	}
 
 	if graph != nil {
@@ -149,3 +518,487 @@ func (g *GraphCommands) handleExists(args []string) (*protocol.Response, error)
 	}
 	return protocol.NewIntResponse(0), nil
 }
+
+// handleSet handles GRAPH.SET <name> [NAME <name>] [DESCRIPTION <description>]
+func (g *GraphCommands) handleSet(args []string) (*protocol.Response, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("GRAPH.SET requires a name and at least one of NAME <name> or DESCRIPTION <description>")
+	}
+
+	var name, description *string
+	for i := 1; i < len(args); i += 2 {
+		option := strings.ToUpper(args[i])
+		if option != "NAME" && option != "DESCRIPTION" {
+			return nil, fmt.Errorf("unknown option for GRAPH.SET: %s", args[i])
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("%s option requires a value", option)
+		}
+		value := args[i+1]
+		if option == "NAME" {
+			name = &value
+		} else {
+			description = &value
+		}
+	}
+
+	graph, err := g.storage.GetGraph(models.GraphID(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+	if name != nil {
+		graph.Name = *name
+	}
+	if description != nil {
+		graph.Description = *description
+	}
+	graph.UpdatedAt = time.Now()
+	if err := g.storage.UpdateGraph(graph); err != nil {
+		return nil, fmt.Errorf("failed to update graph: %w", err)
+	}
+
+	return protocol.OK(), nil
+}
+
+// handleRename handles GRAPH.RENAME <name> <new_name>
+func (g *GraphCommands) handleRename(args []string) (*protocol.Response, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("GRAPH.RENAME requires exactly 2 arguments: name, new_name")
+	}
+
+	if _, err := g.storage.RenameGraph(models.GraphID(args[0]), models.GraphID(args[1])); err != nil {
+		return nil, wrapError("rename graph", err)
+	}
+
+	return protocol.OK(), nil
+}
+
+// handleDisplay handles GRAPH.DISPLAY SET <name> <node_attr> [edge_attr] and GRAPH.DISPLAY GET <name>
+func (g *GraphCommands) handleDisplay(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("GRAPH.DISPLAY requires at least 2 arguments: SET|GET, name")
+	}
+
+	graph, err := g.storage.GetGraph(models.GraphID(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		if len(args) < 3 || len(args) > 4 {
+			return nil, fmt.Errorf("GRAPH.DISPLAY SET requires 3 or 4 arguments: name, node_attr, [edge_attr]")
+		}
+		graph.DisplayNodeAttr = args[2]
+		graph.DisplayEdgeAttr = ""
+		if len(args) == 4 {
+			graph.DisplayEdgeAttr = args[3]
+		}
+		graph.UpdatedAt = time.Now()
+
+		if err := g.storage.UpdateGraph(graph); err != nil {
+			return nil, fmt.Errorf("failed to update graph: %w", err)
+		}
+		return protocol.OK(), nil
+	case "GET":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("GRAPH.DISPLAY GET requires exactly 1 argument: name")
+		}
+		return protocol.NewArrayResponse([]string{graph.DisplayNodeAttr, graph.DisplayEdgeAttr}), nil
+	default:
+		return nil, fmt.Errorf("unknown GRAPH.DISPLAY subcommand: %s", args[0])
+	}
+}
+
+// handleConstraint handles GRAPH.CONSTRAINT SET <name> SELFLOOPS ALLOW|FORBID [EDGETYPE <type>] and GRAPH.CONSTRAINT GET <name>
+func (g *GraphCommands) handleConstraint(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("GRAPH.CONSTRAINT requires at least 2 arguments: SET|GET, name")
+	}
+
+	graph, err := g.storage.GetGraph(models.GraphID(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		if (len(args) != 4 && len(args) != 6) || strings.ToUpper(args[2]) != "SELFLOOPS" {
+			return nil, fmt.Errorf("GRAPH.CONSTRAINT SET requires: name, SELFLOOPS, ALLOW|FORBID, [EDGETYPE type]")
+		}
+		var allow bool
+		switch strings.ToUpper(args[3]) {
+		case "ALLOW":
+			allow = true
+		case "FORBID":
+			allow = false
+		default:
+			return nil, fmt.Errorf("invalid SELFLOOPS value: %s (must be ALLOW or FORBID)", args[3])
+		}
+
+		if len(args) == 6 {
+			if strings.ToUpper(args[4]) != "EDGETYPE" {
+				return nil, fmt.Errorf("unknown option for GRAPH.CONSTRAINT SET: %s", args[4])
+			}
+			edgeType := models.EdgeType(args[5])
+			if graph.EdgeTypes == nil {
+				graph.EdgeTypes = make(map[models.EdgeType]*models.EdgeTypeSchema)
+			}
+			if graph.EdgeTypes[edgeType] == nil {
+				graph.EdgeTypes[edgeType] = &models.EdgeTypeSchema{}
+			}
+			graph.EdgeTypes[edgeType].AllowSelfLoops = &allow
+		} else {
+			graph.AllowSelfLoops = &allow
+		}
+		graph.UpdatedAt = time.Now()
+
+		if err := g.storage.UpdateGraph(graph); err != nil {
+			return nil, fmt.Errorf("failed to update graph: %w", err)
+		}
+		return protocol.OK(), nil
+	case "GET":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("GRAPH.CONSTRAINT GET requires exactly 1 argument: name")
+		}
+		result := []string{"selfloops", selfLoopSetting(graph.AllowSelfLoops == nil || *graph.AllowSelfLoops)}
+
+		edgeTypes := make([]string, 0, len(graph.EdgeTypes))
+		for edgeType, schema := range graph.EdgeTypes {
+			if schema != nil && schema.AllowSelfLoops != nil {
+				edgeTypes = append(edgeTypes, string(edgeType))
+			}
+		}
+		sort.Strings(edgeTypes)
+		for _, edgeType := range edgeTypes {
+			allow := *graph.EdgeTypes[models.EdgeType(edgeType)].AllowSelfLoops
+			result = append(result, "selfloops:"+edgeType, selfLoopSetting(allow))
+		}
+		return protocol.NewArrayResponse(result), nil
+	default:
+		return nil, fmt.Errorf("unknown GRAPH.CONSTRAINT subcommand: %s", args[0])
+	}
+}
+
+// selfLoopSetting formats a self-loop rule for GRAPH.CONSTRAINT GET
+func selfLoopSetting(allow bool) string {
+	if allow {
+		return "allow"
+	}
+	return "forbid"
+}
+
+// handleSelfLoops handles GRAPH.SELFLOOPS <name> [DELETE]
+func (g *GraphCommands) handleSelfLoops(args []string) (*protocol.Response, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("GRAPH.SELFLOOPS requires 1 or 2 arguments: name, [DELETE]")
+	}
+
+	graphID := models.GraphID(args[0])
+	if _, err := g.storage.GetGraph(graphID); err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+
+	var loops []*models.Edge
+	var err error
+	if len(args) == 2 {
+		if strings.ToUpper(args[1]) != "DELETE" {
+			return nil, fmt.Errorf("unknown option for GRAPH.SELFLOOPS: %s", args[1])
+		}
+		loops, err = g.storage.DeleteSelfLoops(graphID)
+	} else {
+		loops, err = g.storage.ListSelfLoops(graphID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to process self-loops: %w", err)
+	}
+
+	result := make([]string, 0, len(loops))
+	for _, edge := range loops {
+		result = append(result, fmt.Sprintf("%s:%s", edge.ID, edge.Type))
+	}
+	return protocol.NewArrayResponse(result), nil
+}
+
+// handleMerge handles GRAPH.MERGE <dst> FROM <src> [ONCONFLICT
+// skip|overwrite|error]. The policy defaults to error. It replies with
+// field and value pairs counting the nodes and edges added, skipped and
+// overwritten.
+func (g *GraphCommands) handleMerge(args []string) (*protocol.Response, error) {
+	if (len(args) != 3 && len(args) != 5) || strings.ToUpper(args[1]) != "FROM" {
+		return nil, fmt.Errorf("GRAPH.MERGE requires: dst, FROM, src, [ONCONFLICT skip|overwrite|error]")
+	}
+
+	policy := storage.MergeError
+	if len(args) == 5 {
+		if strings.ToUpper(args[3]) != "ONCONFLICT" {
+			return nil, fmt.Errorf("unknown option for GRAPH.MERGE: %s", args[3])
+		}
+		policy = storage.MergePolicy(strings.ToLower(args[4]))
+		if policy != storage.MergeSkip && policy != storage.MergeOverwrite && policy != storage.MergeError {
+			return nil, fmt.Errorf("invalid ONCONFLICT: %s (must be 'skip', 'overwrite' or 'error')", args[4])
+		}
+	}
+
+	result, err := g.storage.MergeGraph(models.GraphID(args[0]), models.GraphID(args[2]), policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge graphs: %w", err)
+	}
+	return protocol.NewArrayResponse([]string{
+		"nodes_added", strconv.Itoa(result.NodesAdded),
+		"nodes_skipped", strconv.Itoa(result.NodesSkipped),
+		"nodes_overwritten", strconv.Itoa(result.NodesOverwritten),
+		"edges_added", strconv.Itoa(result.EdgesAdded),
+		"edges_skipped", strconv.Itoa(result.EdgesSkipped),
+		"edges_overwritten", strconv.Itoa(result.EdgesOverwritten),
+	}), nil
+}
+
+// handleActivity handles GRAPH.ACTIVITY <name> [HOURS n]. Each bucket is the
+// hour's start followed by its node create, update and delete counts and its
+// edge create, update and delete counts, oldest first.
+func (g *GraphCommands) handleActivity(args []string) (*protocol.Response, error) {
+	if len(args) != 1 && len(args) != 3 {
+		return nil, fmt.Errorf("GRAPH.ACTIVITY requires: name, [HOURS n]")
+	}
+
+	hours := storage.ActivityHours
+	if len(args) == 3 {
+		if strings.ToUpper(args[1]) != "HOURS" {
+			return nil, fmt.Errorf("unknown option for GRAPH.ACTIVITY: %s", args[1])
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 1 || n > storage.ActivityHours {
+			return nil, fmt.Errorf("invalid HOURS: %s (must be between 1 and %d)", args[2], storage.ActivityHours)
+		}
+		hours = n
+	}
+
+	buckets, err := g.storage.Activity(models.GraphID(args[0]), hours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activity: %w", err)
+	}
+	response := make([]interface{}, len(buckets))
+	for i, bucket := range buckets {
+		row := make([]string, 0, 1+storage.ActivityKinds)
+		row = append(row, bucket.Start.Format(time.RFC3339))
+		for _, count := range bucket.Counts {
+			row = append(row, strconv.FormatUint(count, 10))
+		}
+		response[i] = row
+	}
+	return protocol.NewNestedArrayResponse(response), nil
+}
+
+// handleGeneration handles GRAPH.GENERATION <name>
+func (g *GraphCommands) handleGeneration(args []string) (*protocol.Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("GRAPH.GENERATION requires exactly 1 argument: name")
+	}
+	graphID := models.GraphID(args[0])
+	if _, err := g.storage.GetGraph(graphID); err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+	generation, err := g.storage.CommittedGeneration(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get generation: %w", err)
+	}
+	return protocol.NewIntResponse(int64(generation)), nil
+}
+
+// handleSetAttr handles GRAPH.SETATTR <name> <key> <value_json>
+func (g *GraphCommands) handleSetAttr(args []string) (*protocol.Response, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("GRAPH.SETATTR requires exactly 3 arguments: name, key, value")
+	}
+
+	graph, err := g.storage.GetGraph(models.GraphID(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+
+	graph.SetAttribute(args[1], parseAttributeValue(args[2]))
+	if err := g.storage.UpdateGraph(graph); err != nil {
+		return nil, wrapError("update graph", err)
+	}
+
+	return protocol.OK(), nil
+}
+
+// handleGetAttr handles GRAPH.GETATTR <name> [key]
+func (g *GraphCommands) handleGetAttr(args []string) (*protocol.Response, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("GRAPH.GETATTR requires 1 or 2 arguments: name, [key]")
+	}
+
+	graph, err := g.storage.GetGraph(models.GraphID(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+
+	// Without a key, return all attributes as a JSON object
+	var value interface{} = graph.Attributes
+	if len(args) == 2 {
+		attr, exists := graph.GetAttribute(args[1])
+		if !exists {
+			return protocol.NewNullResponse(), nil
+		}
+		value = attr
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize attribute: %w", err)
+	}
+
+	return protocol.NewBulkResponse(string(encoded)), nil
+}
+
+// handleDelAttr handles GRAPH.DELATTR <name> <key>
+func (g *GraphCommands) handleDelAttr(args []string) (*protocol.Response, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("GRAPH.DELATTR requires exactly 2 arguments: name, key")
+	}
+
+	graph, err := g.storage.GetGraph(models.GraphID(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+
+	if !graph.DeleteAttribute(args[1]) {
+		return protocol.NewIntResponse(0), nil
+	}
+	if err := g.storage.UpdateGraph(graph); err != nil {
+		return nil, fmt.Errorf("failed to update graph: %w", err)
+	}
+
+	return protocol.NewIntResponse(1), nil
+}
+
+// handleSnapshot handles GRAPH.SNAPSHOT CREATE <name> [label], GRAPH.SNAPSHOT LIST <name>,
+// GRAPH.SNAPSHOT DELETE <name> <snapshot_id> and GRAPH.SNAPSHOT DIFF <name> <snapshot_id> [FORMAT summary|full]
+func (g *GraphCommands) handleSnapshot(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("GRAPH.SNAPSHOT requires at least 2 arguments: CREATE|LIST|DELETE|DIFF, name")
+	}
+
+	graphID := models.GraphID(args[1])
+	switch strings.ToUpper(args[0]) {
+	case "CREATE":
+		if len(args) > 3 {
+			return nil, unquotedTextError("GRAPH.SNAPSHOT CREATE requires 1 or 2 arguments: name, [label]", "label")
+		}
+		label := ""
+		if len(args) == 3 {
+			label = args[2]
+		}
+		snapshot, err := g.storage.CreateSnapshot(graphID, label)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create snapshot: %w", err)
+		}
+		return protocol.NewBulkResponse(snapshot.ID), nil
+	case "LIST":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("GRAPH.SNAPSHOT LIST requires exactly 1 argument: name")
+		}
+		snapshots, err := g.storage.ListSnapshots(graphID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots: %w", err)
+		}
+
+		// Six entries per snapshot: id, created_at, label, node_count, edge_count, size
+		result := make([]string, 0, len(snapshots)*6)
+		for _, snapshot := range snapshots {
+			result = append(result,
+				snapshot.ID,
+				snapshot.CreatedAt.Format(time.RFC3339),
+				snapshot.Label,
+				strconv.Itoa(snapshot.NodeCount),
+				strconv.Itoa(snapshot.EdgeCount),
+				strconv.Itoa(snapshot.Size),
+			)
+		}
+		return protocol.NewArrayResponse(result), nil
+	case "DELETE":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("GRAPH.SNAPSHOT DELETE requires exactly 2 arguments: name, snapshot_id")
+		}
+		if err := g.storage.DeleteSnapshot(graphID, args[2]); err != nil {
+			return nil, fmt.Errorf("failed to delete snapshot: %w", err)
+		}
+		return protocol.OK(), nil
+	case "DIFF":
+		return g.handleSnapshotDiff(graphID, args[2:])
+	default:
+		return nil, fmt.Errorf("unknown GRAPH.SNAPSHOT subcommand: %s", args[0])
+	}
+}
+
+// handleSnapshotDiff compares the live graph with a snapshot. The summary
+// format returns change counts; the full format lists each change as
+// +node:<id>, -node:<id>, ~node:<id> and likewise for edges.
+func (g *GraphCommands) handleSnapshotDiff(graphID models.GraphID, args []string) (*protocol.Response, error) {
+	if len(args) != 1 && len(args) != 3 {
+		return nil, fmt.Errorf("GRAPH.SNAPSHOT DIFF requires 2 arguments: name, snapshot_id, and optionally FORMAT summary|full")
+	}
+
+	format := "summary"
+	if len(args) == 3 {
+		if strings.ToUpper(args[1]) != "FORMAT" {
+			return nil, fmt.Errorf("unknown option for GRAPH.SNAPSHOT DIFF: %s", args[1])
+		}
+		format = strings.ToLower(args[2])
+		if format != "summary" && format != "full" {
+			return nil, fmt.Errorf("invalid FORMAT: %s (must be 'summary' or 'full')", args[2])
+		}
+	}
+
+	snapshot, err := g.storage.ReadSnapshot(graphID, args[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	diff, err := analysis.NewGraphAnalyzer(g.storage).DiffSnapshot(graphID, snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff snapshot: %w", err)
+	}
+
+	if format == "summary" {
+		return protocol.NewArrayResponse([]string{
+			"nodes_added", strconv.Itoa(len(diff.AddedNodes)),
+			"nodes_removed", strconv.Itoa(len(diff.RemovedNodes)),
+			"nodes_changed", strconv.Itoa(len(diff.ChangedNodes)),
+			"edges_added", strconv.Itoa(len(diff.AddedEdges)),
+			"edges_removed", strconv.Itoa(len(diff.RemovedEdges)),
+			"edges_changed", strconv.Itoa(len(diff.ChangedEdges)),
+		}), nil
+	}
+
+	result := make([]string, 0)
+	for _, change := range []struct {
+		marker string
+		ids    []models.NodeID
+	}{{"+", diff.AddedNodes}, {"-", diff.RemovedNodes}, {"~", diff.ChangedNodes}} {
+		for _, id := range change.ids {
+			result = append(result, change.marker+"node:"+string(id))
+		}
+	}
+	for _, change := range []struct {
+		marker string
+		ids    []models.EdgeID
+	}{{"+", diff.AddedEdges}, {"-", diff.RemovedEdges}, {"~", diff.ChangedEdges}} {
+		for _, id := range change.ids {
+			result = append(result, change.marker+"edge:"+string(id))
+		}
+	}
+	return protocol.NewArrayResponse(result), nil
+}
+
+// parseAttributeValue decodes value as JSON, falling back to the raw string
+func parseAttributeValue(value string) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
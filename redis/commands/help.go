@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// RegisterHelp adds HELP and a <FAMILY>.HELP command for every family
+// registered so far, so it is called once all other commands are registered
+func (r *Registry) RegisterHelp() {
+	r.Register(CommandSpec{
+		Name:     "HELP",
+		Args:     "[command|family]",
+		Summary:  "Lists the command families, or describes one command or family",
+		Example:  "HELP NODE.CREATE",
+		ReadOnly: true,
+		Handler: func(session *Session, args []string) (*protocol.Response, error) {
+			return r.handleHelp(args)
+		},
+	})
+	for _, family := range r.Families() {
+		family := family
+		r.Register(CommandSpec{
+			Name:     family + ".HELP",
+			Summary:  fmt.Sprintf("Lists the %s commands", family),
+			Example:  family + ".HELP",
+			ReadOnly: true,
+			Handler: func(session *Session, args []string) (*protocol.Response, error) {
+				if len(args) != 0 {
+					return nil, fmt.Errorf("%s.HELP takes no arguments", family)
+				}
+				return r.familyHelp(family), nil
+			},
+		})
+	}
+}
+
+// handleHelp handles HELP [command|family]
+func (r *Registry) handleHelp(args []string) (*protocol.Response, error) {
+	switch len(args) {
+	case 0:
+		lines := []string{}
+		for _, family := range r.Families() {
+			count := len(r.described(family))
+			noun := "commands"
+			if count == 1 {
+				noun = "command"
+			}
+			lines = append(lines, fmt.Sprintf("%s: %d %s, see %s.HELP", family, count, noun, family))
+		}
+		for _, spec := range r.Family("") {
+			lines = append(lines, fmt.Sprintf("%s - %s", spec.Usage(), spec.Summary))
+		}
+		lines = append(lines, "Use HELP <command> for a command's arguments and an example")
+		return protocol.NewArrayResponse(lines), nil
+	case 1:
+		name := NormalizeCommand(args[0])
+		if spec, ok := r.Lookup(name); ok {
+			return r.commandHelp(spec), nil
+		}
+		if len(r.Family(name)) > 0 {
+			return r.familyHelp(name), nil
+		}
+		if suggestion := r.Suggest(name); suggestion != "" {
+			return nil, fmt.Errorf("no help for %s (did you mean %s?)", name, suggestion)
+		}
+		return nil, fmt.Errorf("no help for %s", name)
+	default:
+		return nil, fmt.Errorf("HELP takes at most 1 argument: command or family")
+	}
+}
+
+// commandHelp describes one command: its usage, summary, keywords, option
+// defaults and example
+func (r *Registry) commandHelp(spec *CommandSpec) *protocol.Response {
+	lines := []string{spec.Usage(), spec.Summary}
+	if len(spec.Keywords) > 0 {
+		lines = append(lines, "Keywords: "+strings.Join(spec.Keywords, ", "))
+	}
+	if len(spec.Defaults) > 0 {
+		lines = append(lines, "Defaults: "+strings.Join(spec.Defaults, ", "))
+	}
+	lines = append(lines, "Example: "+spec.Example)
+	return protocol.NewArrayResponse(lines)
+}
+
+// familyHelp lists the usage and summary of each command of a family
+func (r *Registry) familyHelp(family string) *protocol.Response {
+	lines := []string{}
+	for _, spec := range r.described(family) {
+		lines = append(lines, fmt.Sprintf("%s - %s", spec.Usage(), spec.Summary))
+	}
+	return protocol.NewArrayResponse(lines)
+}
+
+// described returns the commands of a family other than its HELP command
+func (r *Registry) described(family string) []*CommandSpec {
+	var specs []*CommandSpec
+	for _, spec := range r.Family(family) {
+		if spec.Name != family+".HELP" {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
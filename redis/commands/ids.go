@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// ErrIDExists is returned when NODE.CREATE or EDGE.CREATE is given NX and a
+// node or edge with the ID exists. Errors wrapping it start with "EXISTS"
+// and are sent to clients without the generic ERR prefix.
+var ErrIDExists = errors.New("EXISTS")
+
+// autoIDAttempts is how many IDs AUTO generates before giving up, should
+// each one already be taken
+const autoIDAttempts = 5
+
+// IDGenerator returns a new ID for NODE.CREATE and EDGE.CREATE AUTO. IDs
+// should sort in the order they were generated.
+type IDGenerator func() (string, error)
+
+// crockford is the alphabet of ULIDs: Crockford's base32, which sorts in
+// the same order as the values it encodes
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULIDGenerator returns a generator of ULIDs: 26 characters holding the
+// millisecond the ID was made followed by 80 random bits. IDs made in the
+// same millisecond increment the random bits of the last one instead, so
+// successive IDs of one generator always sort in order.
+func NewULIDGenerator() IDGenerator {
+	var (
+		mu       sync.Mutex
+		lastMs   uint64
+		lastHigh uint16 // top 16 of the 80 random bits
+		lastLow  uint64 // the other 64
+	)
+	return func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ms := uint64(time.Now().UnixMilli())
+		if ms <= lastMs {
+			// Same millisecond, or the clock went back: keep the last
+			// time and count up from the last random bits
+			ms = lastMs
+			lastLow++
+			if lastLow == 0 {
+				lastHigh++
+				if lastHigh == 0 {
+					ms++
+				}
+			}
+		} else {
+			var random [10]byte
+			if _, err := rand.Read(random[:]); err != nil {
+				return "", fmt.Errorf("failed to generate ID: %w", err)
+			}
+			lastHigh = binary.BigEndian.Uint16(random[:2])
+			lastLow = binary.BigEndian.Uint64(random[2:])
+		}
+		lastMs = ms
+
+		// 48 bits of time and 80 random bits, as 26 groups of 5 bits
+		// from the lowest up; the first group holds the top 3 bits
+		hi := ms<<16 | uint64(lastHigh)
+		lo := lastLow
+		var id [26]byte
+		for i := len(id) - 1; i >= 0; i-- {
+			id[i] = crockford[lo&31]
+			lo = lo>>5 | hi<<59
+			hi >>= 5
+		}
+		return string(id[:]), nil
+	}
+}
+
+// defaultIDs is the generator of node and edge commands that are not given
+// one, shared so that node and edge IDs sort together
+var defaultIDs = NewULIDGenerator()
+
+// isAuto reports whether an ID argument asks for a generated ID
+func isAuto(id string) bool {
+	return strings.ToUpper(id) == "AUTO"
+}
+
+// newNodeID generates an ID no node of graphID has in tx, retrying should
+// one be taken
+func newNodeID(tx storage.Transaction, graphID models.GraphID, ids IDGenerator) (models.NodeID, error) {
+	for attempt := 0; attempt < autoIDAttempts; attempt++ {
+		id, err := ids()
+		if err != nil {
+			return "", err
+		}
+		if _, err := tx.GetNode(graphID, models.NodeID(id)); errors.Is(err, storage.ErrNodeNotFound) {
+			return models.NodeID(id), nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("failed to generate an unused node ID after %d attempts", autoIDAttempts)
+}
+
+// newEdgeID generates an ID no edge of graphID has in tx, retrying should
+// one be taken
+func newEdgeID(tx storage.Transaction, graphID models.GraphID, ids IDGenerator) (models.EdgeID, error) {
+	for attempt := 0; attempt < autoIDAttempts; attempt++ {
+		id, err := ids()
+		if err != nil {
+			return "", err
+		}
+		if _, err := tx.GetEdge(graphID, models.EdgeID(id)); errors.Is(err, storage.ErrEdgeNotFound) {
+			return models.EdgeID(id), nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("failed to generate an unused edge ID after %d attempts", autoIDAttempts)
+}
+
+// requireNewNode fails with ErrIDExists if graphID has a live node nodeID
+func requireNewNode(tx storage.Transaction, graphID models.GraphID, nodeID models.NodeID) error {
+	node, err := tx.GetNode(graphID, nodeID)
+	if errors.Is(err, storage.ErrNodeNotFound) || (err == nil && node.IsExpired()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w node %s already exists", ErrIDExists, nodeID)
+}
+
+// requireNewEdge fails with ErrIDExists if graphID has a live edge edgeID
+func requireNewEdge(tx storage.Transaction, graphID models.GraphID, edgeID models.EdgeID) error {
+	edge, err := tx.GetEdge(graphID, edgeID)
+	if errors.Is(err, storage.ErrEdgeNotFound) || (err == nil && edge.IsExpired()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w edge %s already exists", ErrIDExists, edgeID)
+}
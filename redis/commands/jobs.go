@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/jobs"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// SetJobManager replaces the manager used by ANALYSIS.SUBMIT
+func (a *AnalysisCommands) SetJobManager(manager *jobs.Manager) {
+	a.jobs = manager
+}
+
+// handleSubmit handles ANALYSIS.SUBMIT <subcommand> [args...]
+func (a *AnalysisCommands) handleSubmit(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("ANALYSIS.SUBMIT requires at least 1 argument: subcommand")
+	}
+
+	subcommand := strings.ToUpper(args[0])
+	switch subcommand {
+	case "SUBMIT", "STATUS", "RESULT", "CANCEL":
+		return nil, fmt.Errorf("ANALYSIS.%s cannot be submitted as a job", subcommand)
+	}
+	subArgs := append([]string(nil), args[1:]...)
+
+	id, err := a.jobs.Submit("ANALYSIS."+subcommand, func(ctx context.Context, progress *jobs.Progress) (interface{}, error) {
+		// The job reads through a storage wrapper that stops on cancellation
+		// and counts reads as progress, so every analysis is cancellable.
+		// Searches over edges loaded up front read nothing more, so they
+		// stop on the context of the session the job runs in instead.
+		jobStorage := &cancellableStorage{StorageEngine: a.storage, ctx: ctx, progress: progress}
+		jobCommands := &AnalysisCommands{
+			storage:  jobStorage,
+			analyzer: analysis.NewGraphAnalyzer(jobStorage),
+			jobs:     a.jobs,
+			cursors:  a.cursors,
+		}
+		return route(jobCommands.Register, &Session{ctx: ctx}, "ANALYSIS."+subcommand, subArgs)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit job: %w", err)
+	}
+
+	return protocol.NewBulkResponse(id), nil
+}
+
+// handleStatus handles ANALYSIS.STATUS <job_id>
+func (a *AnalysisCommands) handleStatus(args []string) (*protocol.Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("ANALYSIS.STATUS requires exactly 1 argument: job_id")
+	}
+
+	status, err := a.jobs.Status(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job status: %w", err)
+	}
+
+	errMsg := ""
+	if status.Err != nil {
+		errMsg = status.Err.Error()
+	}
+
+	// Return status as array: [state, progress, total, error]
+	result := []string{
+		string(status.State),
+		strconv.FormatInt(status.Done, 10),
+		strconv.FormatInt(status.Total, 10),
+		errMsg,
+	}
+
+	return protocol.NewArrayResponse(result), nil
+}
+
+// handleResult handles ANALYSIS.RESULT <job_id>
+func (a *AnalysisCommands) handleResult(args []string) (*protocol.Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("ANALYSIS.RESULT requires exactly 1 argument: job_id")
+	}
+
+	// Rankings cached for PAGE cursors can be fetched by ID too
+	status, err := a.jobs.Status(args[0])
+	if err != nil {
+		if cached, cacheErr := a.cursors.Status(args[0]); cacheErr == nil {
+			status, err = cached, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job result: %w", err)
+	}
+
+	switch status.State {
+	case jobs.StateDone:
+		response, ok := status.Result.(*protocol.Response)
+		if !ok {
+			return nil, fmt.Errorf("job %s has no result to return", status.ID)
+		}
+		return response, nil
+	case jobs.StateFailed:
+		return nil, fmt.Errorf("job %s failed: %w", status.ID, status.Err)
+	case jobs.StateCancelled:
+		return nil, fmt.Errorf("job %s was cancelled", status.ID)
+	default:
+		return nil, fmt.Errorf("job %s is not finished: %s", status.ID, status.State)
+	}
+}
+
+// handleCancel handles ANALYSIS.CANCEL <job_id>
+func (a *AnalysisCommands) handleCancel(args []string) (*protocol.Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("ANALYSIS.CANCEL requires exactly 1 argument: job_id")
+	}
+
+	if err := a.jobs.Cancel(args[0]); err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	return protocol.OK(), nil
+}
+
+// cancellableStorage wraps a storage engine for a job: reads fail once the
+// job's context is cancelled and each read advances the job's progress.
+type cancellableStorage struct {
+	storage.StorageEngine
+	ctx      context.Context
+	progress *jobs.Progress
+}
+
+// check returns the context error, if any, and counts one read
+func (s *cancellableStorage) check() error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.progress.Add(1)
+	return nil
+}
+
+func (s *cancellableStorage) GetNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.StorageEngine.GetNode(graphID, nodeID)
+}
+
+func (s *cancellableStorage) GetEdge(graphID models.GraphID, edgeID models.EdgeID) (*models.Edge, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.StorageEngine.GetEdge(graphID, edgeID)
+}
+
+func (s *cancellableStorage) ListNodes(graphID models.GraphID) ([]*models.Node, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.StorageEngine.ListNodes(graphID)
+}
+
+func (s *cancellableStorage) ListEdges(graphID models.GraphID) ([]*models.Edge, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.StorageEngine.ListEdges(graphID)
+}
+
+func (s *cancellableStorage) GetOutgoingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.StorageEngine.GetOutgoingEdges(graphID, nodeID)
+}
+
+func (s *cancellableStorage) GetIncomingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.StorageEngine.GetIncomingEdges(graphID, nodeID)
+}
+
+func (s *cancellableStorage) GetConnectedNodes(graphID models.GraphID, nodeID models.NodeID) ([]*models.Node, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.StorageEngine.GetConnectedNodes(graphID, nodeID)
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// labeler formats nodes and edges as id:type, optionally followed by the
+// graph's configured display attribute (id:type:label) and, for nodes, the
+// last update time (id:type@updated_at).
+// A nil labeler produces the plain id:type form.
+type labeler struct {
+	labels   bool
+	age      bool
+	nodeAttr string
+	edgeAttr string
+}
+
+// newLabeler loads the display configuration of a graph. It returns nil when
+// neither labels nor ages were requested so callers can pass the result
+// straight through.
+func newLabeler(storageEngine storage.StorageEngine, graphID models.GraphID, withLabels, withAge bool) (*labeler, error) {
+	if !withLabels && !withAge {
+		return nil, nil
+	}
+
+	l := &labeler{labels: withLabels, age: withAge}
+	if withLabels {
+		graph, err := storageEngine.GetGraph(graphID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load display settings: %w", err)
+		}
+		l.nodeAttr = graph.DisplayNodeAttr
+		l.edgeAttr = graph.DisplayEdgeAttr
+	}
+
+	return l, nil
+}
+
+// columns returns the header of the label and update time columns a table
+// of nodes gets, in the order nodeColumns returns them
+func (l *labeler) columns() []string {
+	var columns []string
+	if l != nil && l.labels {
+		columns = append(columns, "label")
+	}
+	if l != nil && l.age {
+		columns = append(columns, "updated_at")
+	}
+	return columns
+}
+
+// nodeColumns returns a node's label and update time as table columns.
+// Labels are not escaped, as the table quotes them.
+func (l *labeler) nodeColumns(node *models.Node) []string {
+	var columns []string
+	if l != nil && l.labels {
+		columns = append(columns, labelValue(node.Attributes, l.nodeAttr))
+	}
+	if l != nil && l.age {
+		columns = append(columns, formatUpdatedAt(node.UpdatedAt))
+	}
+	return columns
+}
+
+// node formats a node as id:type[:label][@updated_at]
+func (l *labeler) node(node *models.Node) string {
+	base := string(node.ID) + ":" + string(node.Type)
+	if l == nil {
+		return base
+	}
+	if l.labels {
+		base += ":" + displayValue(node.Attributes, l.nodeAttr)
+	}
+	if l.age {
+		base += "@" + formatUpdatedAt(node.UpdatedAt)
+	}
+	return base
+}
+
+// edge formats an edge as id:type[:label]
+func (l *labeler) edge(edge *models.Edge) string {
+	base := string(edge.ID) + ":" + string(edge.Type)
+	if l == nil || !l.labels {
+		return base
+	}
+	return base + ":" + displayValue(edge.Attributes, l.edgeAttr)
+}
+
+// formatUpdatedAt renders an update time for AGE output. Zero timestamps
+// come from entities created before timestamps were recorded.
+func formatUpdatedAt(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseUpdatedBefore parses an UPDATEDBEFORE cutoff given either as an
+// RFC3339 timestamp or as an age in seconds relative to now
+func parseUpdatedBefore(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return time.Time{}, fmt.Errorf("invalid UPDATEDBEFORE value: %s", value)
+		}
+		return time.Now().Add(-time.Duration(seconds) * time.Second), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid UPDATEDBEFORE value (expected RFC3339 or seconds): %s", value)
+	}
+	return t, nil
+}
+
+// displayValue returns the display label stored under attr, or an empty
+// string when the attribute is not configured or missing. Labels that
+// contain output separators are JSON-escaped so they can be parsed back.
+func displayValue(attributes models.Attributes, attr string) string {
+	label := labelValue(attributes, attr)
+	if strings.ContainsAny(label, ":\"") || strings.Contains(label, "->") || strings.Contains(label, "<-") {
+		escaped, _ := json.Marshal(label)
+		return string(escaped)
+	}
+	return label
+}
+
+// labelValue returns the display label stored under attr, with values other
+// than strings JSON-encoded, or an empty string when there is none
+func labelValue(attributes models.Attributes, attr string) string {
+	if attr == "" {
+		return ""
+	}
+
+	value, exists := attributes[attr]
+	if !exists || value == nil {
+		return ""
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+}
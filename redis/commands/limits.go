@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+)
+
+// ErrGraphTooLarge is returned when an analysis command is refused because
+// the graph is bigger than the size guard of its kind of analysis, or when
+// ORDERBY is refused because there are more results than it sorts. Errors
+// wrapping it start with "TOOLARGE" and are sent to clients without the
+// generic ERR prefix.
+var ErrGraphTooLarge = errors.New("TOOLARGE")
+
+// AnalysisLimit bounds the graphs one kind of analysis runs on unless the
+// command is given FORCE. A bound of 0 is not checked.
+type AnalysisLimit struct {
+	MaxNodes int
+	MaxEdges int
+}
+
+// AnalysisLimits holds the size guards of the analyses whose cost grows
+// fastest with the graph. They only apply to commands run directly; jobs
+// submitted with ANALYSIS.SUBMIT are never refused.
+type AnalysisLimits struct {
+	// PathEnumeration guards ANALYSIS.TRAVERSE in the detailed format,
+	// which lists every path from the start node
+	PathEnumeration AnalysisLimit
+
+	// CycleEnumeration guards ANALYSIS.CYCLES
+	CycleEnumeration AnalysisLimit
+
+	// Clustering guards ANALYSIS.CLUSTERING
+	Clustering AnalysisLimit
+
+	// PairwiseNodes is the most nodes ANALYSIS.PAIRWISE compares, whatever
+	// the graph's size; 0 is not checked. FORCE does not lift it.
+	PairwiseNodes int
+}
+
+// DefaultAnalysisLimits returns size guards generous enough that only
+// graphs on which an analysis would run for minutes are refused
+func DefaultAnalysisLimits() AnalysisLimits {
+	return AnalysisLimits{
+		PathEnumeration:  AnalysisLimit{MaxEdges: 100_000},
+		CycleEnumeration: AnalysisLimit{MaxEdges: 100_000},
+		Clustering:       AnalysisLimit{MaxNodes: 1_000_000, MaxEdges: 5_000_000},
+		PairwiseNodes:    100,
+	}
+}
+
+// SetAnalysisLimits replaces the size guards checked before analyses run
+func (a *AnalysisCommands) SetAnalysisLimits(limits AnalysisLimits) {
+	a.limits = limits
+}
+
+// takeForce removes FORCE from the end of args and reports whether it was
+// there. Only the last argument is taken, so type lists may still hold a
+// type named FORCE anywhere else.
+func takeForce(args []string) ([]string, bool) {
+	if len(args) > 0 && strings.ToUpper(args[len(args)-1]) == "FORCE" {
+		return args[:len(args)-1], true
+	}
+	return args, false
+}
+
+// checkSize refuses to run an analysis of graphID if the graph is bigger
+// than limit. The error names the analysis and the graph's size, and
+// advice, if any, names the options of the command that make the query
+// smaller. Nodes and edges are counted without being read.
+func (a *AnalysisCommands) checkSize(graphID models.GraphID, analysis string, limit AnalysisLimit, advice string) error {
+	if advice != "" {
+		advice += ". "
+	}
+	tooLarge := func(count int, what string, max int) error {
+		return fmt.Errorf("%w graph %s has %d %s, more than the %d %s runs on. %sRun it in the background with ANALYSIS.SUBMIT, or add FORCE to run it anyway",
+			ErrGraphTooLarge, graphID, count, what, max, analysis, advice)
+	}
+	if limit.MaxNodes > 0 {
+		count, err := a.storage.CountNodes(graphID)
+		if err != nil {
+			return fmt.Errorf("failed to count nodes: %w", err)
+		}
+		if count > limit.MaxNodes {
+			return tooLarge(count, "nodes", limit.MaxNodes)
+		}
+	}
+	if limit.MaxEdges > 0 {
+		count, err := a.storage.CountEdges(graphID)
+		if err != nil {
+			return fmt.Errorf("failed to count edges: %w", err)
+		}
+		if count > limit.MaxEdges {
+			return tooLarge(count, "edges", limit.MaxEdges)
+		}
+	}
+	return nil
+}
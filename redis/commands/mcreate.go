@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// batchNode is an element of the array NODE.MCREATE creates
+type batchNode struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Attributes map[string]interface{} `json:"attributes"`
+	TTL        int64                  `json:"ttl"`
+}
+
+// batchEdge is an element of the array EDGE.MCREATE creates
+type batchEdge struct {
+	ID         string                 `json:"id"`
+	From       string                 `json:"from"`
+	To         string                 `json:"to"`
+	Type       string                 `json:"type"`
+	Attributes map[string]interface{} `json:"attributes"`
+	TTL        int64                  `json:"ttl"`
+}
+
+// ttlExpiry returns when an entity created at now with ttl seconds to live
+// expires, or nil if ttl is not positive
+func ttlExpiry(now time.Time, ttl int64) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+	t := now.Add(time.Duration(ttl) * time.Second)
+	return &t
+}
+
+// handleMCreate handles NODE.MCREATE <graph> <json_array>
+// Each element is an object with an id, a type and optionally attributes
+// and a ttl in seconds. The nodes are created in one transaction, so if one
+// fails none are created. Like NODE.CREATE, a node that exists is replaced.
+func (n *NodeCommands) handleMCreate(args []string) (*protocol.Response, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("NODE.MCREATE requires exactly 2 arguments: graph, json_array")
+	}
+
+	graphID := models.GraphID(args[0])
+	var items []batchNode
+	if err := json.Unmarshal([]byte(args[1]), &items); err != nil {
+		return nil, fmt.Errorf("invalid nodes JSON array: %w", err)
+	}
+	if !utf8.ValidString(args[1]) {
+		for _, item := range items {
+			if path := replacedPath("attributes", item.Attributes); path != "" {
+				return nil, fmt.Errorf("%w node %s %s is not valid UTF-8", models.ErrBadArgument, item.ID, path)
+			}
+		}
+		return nil, fmt.Errorf("%w nodes JSON array is not valid UTF-8", models.ErrBadArgument)
+	}
+
+	now := time.Now()
+	nodes := make([]*models.Node, 0, len(items))
+	seen := make(map[string]bool)
+	for i, item := range items {
+		if item.ID == "" || item.Type == "" {
+			return nil, fmt.Errorf("%w node %d of the batch needs an id and a type", models.ErrBadArgument, i)
+		}
+		if seen[item.ID] {
+			return nil, fmt.Errorf("%w node %s appears twice in the batch", models.ErrBadArgument, item.ID)
+		}
+		seen[item.ID] = true
+		if item.Attributes == nil {
+			item.Attributes = make(map[string]interface{})
+		}
+		node := &models.Node{
+			ID:         models.NodeID(item.ID),
+			Type:       models.NodeType(item.Type),
+			Attributes: item.Attributes,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			ExpiresAt:  ttlExpiry(now, item.TTL),
+		}
+		if err := node.Validate(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if _, err := n.storage.GetGraph(graphID); err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+
+	if err := n.storage.CreateNodes(graphID, nodes); err != nil {
+		return nil, fmt.Errorf("failed to create nodes: %w", err)
+	}
+	n.storage.RecordActivity(graphID, storage.ActivityNodeCreate, len(nodes))
+
+	return protocol.NewIntResponse(int64(len(nodes))), nil
+}
+
+// handleMCreate handles EDGE.MCREATE <graph> <json_array>
+// Each element is an object with an id, from and to node IDs, a type and
+// optionally attributes and a ttl in seconds. The edges are created in one
+// transaction, so if one fails, such as an edge to a missing node, none are
+// created. Like EDGE.CREATE, an edge that exists is replaced.
+func (e *EdgeCommands) handleMCreate(args []string) (*protocol.Response, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("EDGE.MCREATE requires exactly 2 arguments: graph, json_array")
+	}
+
+	graphID := models.GraphID(args[0])
+	var items []batchEdge
+	if err := json.Unmarshal([]byte(args[1]), &items); err != nil {
+		return nil, fmt.Errorf("invalid edges JSON array: %w", err)
+	}
+	if !utf8.ValidString(args[1]) {
+		for _, item := range items {
+			if path := replacedPath("attributes", item.Attributes); path != "" {
+				return nil, fmt.Errorf("%w edge %s %s is not valid UTF-8", models.ErrBadArgument, item.ID, path)
+			}
+		}
+		return nil, fmt.Errorf("%w edges JSON array is not valid UTF-8", models.ErrBadArgument)
+	}
+
+	now := time.Now()
+	edges := make([]*models.Edge, 0, len(items))
+	seen := make(map[string]bool)
+	for i, item := range items {
+		if item.ID == "" || item.From == "" || item.To == "" || item.Type == "" {
+			return nil, fmt.Errorf("%w edge %d of the batch needs an id, from, to and a type", models.ErrBadArgument, i)
+		}
+		if seen[item.ID] {
+			return nil, fmt.Errorf("%w edge %s appears twice in the batch", models.ErrBadArgument, item.ID)
+		}
+		seen[item.ID] = true
+		from, err := resolveNodeID(e.storage, graphID, item.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := resolveNodeID(e.storage, graphID, item.To)
+		if err != nil {
+			return nil, err
+		}
+		if item.Attributes == nil {
+			item.Attributes = make(map[string]interface{})
+		}
+		edge := &models.Edge{
+			ID:         models.EdgeID(item.ID),
+			FromNodeID: from,
+			ToNodeID:   to,
+			Type:       models.EdgeType(item.Type),
+			Attributes: item.Attributes,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			ExpiresAt:  ttlExpiry(now, item.TTL),
+		}
+		if err := edge.Validate(); err != nil {
+			return nil, err
+		}
+		edges = append(edges, edge)
+	}
+	if _, err := e.storage.GetGraph(graphID); err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+
+	if err := e.storage.CreateEdges(graphID, edges); err != nil {
+		return nil, fmt.Errorf("failed to create edges: %w", err)
+	}
+	e.storage.RecordActivity(graphID, storage.ActivityEdgeCreate, len(edges))
+
+	return protocol.NewIntResponse(int64(len(edges))), nil
+}
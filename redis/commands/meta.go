@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// MetaCommands handles graph metadata Redis commands
+type MetaCommands struct {
+	storage storage.StorageEngine
+}
+
+// NewMetaCommands creates a new metadata commands handler
+func NewMetaCommands(storageEngine storage.StorageEngine) *MetaCommands {
+	return &MetaCommands{
+		storage: storageEngine,
+	}
+}
+
+// Handle routes metadata commands to their respective handlers
+func (m *MetaCommands) Handle(command string, args []string) (*protocol.Response, error) {
+	return route(m.Register, nil, "META."+command, args)
+}
+
+// Register adds the metadata commands to a registry
+func (m *MetaCommands) Register(r *Registry) {
+	r.Register(CommandSpec{
+		Name:    "META.SET",
+		Args:    "<graph> <namespace> <key> <value_json>",
+		Summary: "Stores a JSON value under a key of a graph's namespace",
+		Example: `META.SET my-graph ide-layout checkout '{"x": 120, "y": 80}'`,
+		Handler: sessionless(m.handleSet),
+	})
+	r.Register(CommandSpec{
+		Name:     "META.GET",
+		Args:     "<graph> <namespace> <key>",
+		Summary:  "Returns the JSON value stored under a key",
+		Example:  "META.GET my-graph ide-layout checkout",
+		ReadOnly: true,
+		Handler:  sessionless(m.handleGet),
+	})
+	r.Register(CommandSpec{
+		Name:    "META.DEL",
+		Args:    "<graph> <namespace> <key>",
+		Summary: "Deletes a key, replying 1 if it existed",
+		Example: "META.DEL my-graph ide-layout checkout",
+		Handler: sessionless(m.handleDel),
+	})
+	r.Register(CommandSpec{
+		Name:     "META.LIST",
+		Args:     "<graph> <namespace>",
+		Summary:  "Lists the keys and values of a namespace",
+		Example:  "META.LIST my-graph ide-layout",
+		ReadOnly: true,
+		Handler:  sessionless(m.handleList),
+	})
+}
+
+// handleSet handles META.SET <graph> <namespace> <key> <value_json>
+func (m *MetaCommands) handleSet(args []string) (*protocol.Response, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("META.SET requires exactly 4 arguments: graph, namespace, key, value_json")
+	}
+
+	entry := &models.MetaEntry{Namespace: args[1], Key: args[2], Value: []byte(args[3])}
+	if err := entry.Validate(); err != nil {
+		return nil, err
+	}
+	if err := m.storage.SetMeta(models.GraphID(args[0]), entry); err != nil {
+		return nil, fmt.Errorf("failed to set metadata: %w", err)
+	}
+	return protocol.OK(), nil
+}
+
+// handleGet handles META.GET <graph> <namespace> <key>
+func (m *MetaCommands) handleGet(args []string) (*protocol.Response, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("META.GET requires exactly 3 arguments: graph, namespace, key")
+	}
+	if err := models.ValidateMetaNamespace(args[1]); err != nil {
+		return nil, err
+	}
+
+	entry, err := m.storage.GetMeta(models.GraphID(args[0]), args[1], args[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	if entry == nil {
+		return protocol.NewNullResponse(), nil
+	}
+	return protocol.NewBulkResponse(string(entry.Value)), nil
+}
+
+// handleDel handles META.DEL <graph> <namespace> <key>, replying 1 if the
+// key existed and 0 otherwise
+func (m *MetaCommands) handleDel(args []string) (*protocol.Response, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("META.DEL requires exactly 3 arguments: graph, namespace, key")
+	}
+	if err := models.ValidateMetaNamespace(args[1]); err != nil {
+		return nil, err
+	}
+
+	deleted, err := m.storage.DeleteMeta(models.GraphID(args[0]), args[1], args[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete metadata: %w", err)
+	}
+	if deleted {
+		return protocol.NewIntResponse(1), nil
+	}
+	return protocol.NewIntResponse(0), nil
+}
+
+// handleList handles META.LIST <graph> <namespace>, replying with key and
+// value pairs sorted by key
+func (m *MetaCommands) handleList(args []string) (*protocol.Response, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("META.LIST requires exactly 2 arguments: graph, namespace")
+	}
+	if err := models.ValidateMetaNamespace(args[1]); err != nil {
+		return nil, err
+	}
+
+	entries, err := m.storage.ListMeta(models.GraphID(args[0]), args[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metadata: %w", err)
+	}
+	result := make([]string, 0, len(entries)*2)
+	for _, entry := range entries {
+		result = append(result, entry.Key, string(entry.Value))
+	}
+	return protocol.NewArrayResponse(result), nil
+}
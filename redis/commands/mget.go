@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// expiryField formats an expiry time as NODE.GET and EDGE.GET reply it, ""
+// for none
+func expiryField(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return ""
+	}
+	return expiresAt.Format(time.RFC3339)
+}
+
+// handleMGet handles NODE.MGET <graph> <id> [<id> ...]
+// Each node is replied as NODE.GET replies it, as id, type, attributes JSON
+// and expiry. A node that does not exist is a null in its place rather than
+// failing the others. The nodes are read in one transaction.
+func (n *NodeCommands) handleMGet(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("NODE.MGET requires at least 2 arguments: graph, id")
+	}
+
+	nodeIDs := make([]models.NodeID, len(args)-1)
+	for i, id := range args[1:] {
+		nodeIDs[i] = models.NodeID(id)
+	}
+	nodes, err := n.storage.GetNodes(models.GraphID(args[0]), nodeIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	response := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		if node == nil {
+			continue
+		}
+		attributesJSON, err := json.Marshal(node.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize attributes: %w", err)
+		}
+		response[i] = []string{string(node.ID), string(node.Type), string(attributesJSON), expiryField(node.ExpiresAt)}
+	}
+	return protocol.NewNestedArrayResponse(response), nil
+}
+
+// handleMGet handles EDGE.MGET <graph> <id> [<id> ...]
+// Each edge is replied as EDGE.GET replies it, with a null in the place of
+// an edge that does not exist, as in NODE.MGET.
+func (e *EdgeCommands) handleMGet(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("EDGE.MGET requires at least 2 arguments: graph, id")
+	}
+
+	edgeIDs := make([]models.EdgeID, len(args)-1)
+	for i, id := range args[1:] {
+		edgeIDs[i] = models.EdgeID(id)
+	}
+	edges, err := e.storage.GetEdges(models.GraphID(args[0]), edgeIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get edges: %w", err)
+	}
+
+	response := make([]interface{}, len(edges))
+	for i, edge := range edges {
+		if edge == nil {
+			continue
+		}
+		attributesJSON, err := json.Marshal(edge.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize attributes: %w", err)
+		}
+		response[i] = []string{
+			string(edge.ID),
+			string(edge.FromNodeID),
+			string(edge.ToNodeID),
+			string(edge.Type),
+			string(attributesJSON),
+			expiryField(edge.ExpiresAt),
+		}
+	}
+	return protocol.NewNestedArrayResponse(response), nil
+}
@@ -14,43 +14,153 @@ import (
 
 // NodeCommands handles node-related Redis commands
 type NodeCommands struct {
-	storage storage.StorageEngine
+	storage   storage.StorageEngine
+	ids       IDGenerator
+	sortLimit int
 }
 
 // NewNodeCommands creates a new node commands handler
 func NewNodeCommands(storageEngine storage.StorageEngine) *NodeCommands {
 	return &NodeCommands{
-		storage: storageEngine,
+		storage:   storageEngine,
+		ids:       defaultIDs,
+		sortLimit: DefaultMaxSortResults,
 	}
 }
 
+// SetIDGenerator replaces the generator of NODE.CREATE AUTO IDs
+func (n *NodeCommands) SetIDGenerator(ids IDGenerator) {
+	n.ids = ids
+}
+
+// SetSortLimit sets the most results ORDERBY sorts; 0 is not checked
+func (n *NodeCommands) SetSortLimit(limit int) {
+	n.sortLimit = limit
+}
+
 // Handle routes node commands to their respective handlers
 func (n *NodeCommands) Handle(command string, args []string) (*protocol.Response, error) {
-	switch command {
-	case "CREATE":
-		return n.handleCreate(args)
-	case "GET":
-		return n.handleGet(args)
-	case "UPDATE":
-		return n.handleUpdate(args)
-	case "DELETE":
-		return n.handleDelete(args)
-	case "FILTER":
-		return n.handleFilter(args)
-	case "LIST":
-		return n.handleList(args)
-	case "EXISTS":
-		return n.handleExists(args)
-	default:
-		return nil, fmt.Errorf("unknown NODE command: %s", command)
-	}
+	return route(n.Register, nil, "NODE."+command, args)
 }
 
-// handleCreate handles NODE.CREATE <graph> <id> <type> [attributes_json] [TTL <seconds>]
+// Register adds the node commands to a registry
+func (n *NodeCommands) Register(r *Registry) {
+	r.Register(CommandSpec{
+		Name:     "NODE.CREATE",
+		Args:     "<graph> <id|AUTO> <type> [attributes_json] [TTL <seconds>] [NX] [IFGEN <generation>]",
+		Keywords: []string{"AUTO", "TTL", "NX", "IFGEN"},
+		Summary:  "Creates or fully replaces a node",
+		Example:  `NODE.CREATE my-graph service-a service '{"version":"1.0"}' TTL 3600`,
+		Handler:  sessionless(n.handleCreate),
+	})
+	r.Register(CommandSpec{
+		Name:    "NODE.MCREATE",
+		Args:    "<graph> <json_array>",
+		Summary: "Creates a batch of nodes in one transaction",
+		Example: `NODE.MCREATE my-graph '[{"id":"service-a","type":"service"},{"id":"db-1","type":"database","ttl":3600}]'`,
+		Handler: sessionless(n.handleMCreate),
+	})
+	r.Register(CommandSpec{
+		Name:     "NODE.GET",
+		Args:     "<graph> <id> [ATTRS <offset> <count> | ATTRKEYS]",
+		Keywords: []string{"ATTRS", "ATTRKEYS"},
+		Summary:  "Returns a node's details",
+		Example:  "NODE.GET my-graph service-a",
+		ReadOnly: true,
+		Handler:  sessionless(n.handleGet),
+	})
+	r.Register(CommandSpec{
+		Name:     "NODE.MGET",
+		Args:     "<graph> <id> [<id> ...]",
+		Summary:  "Returns the details of several nodes, with a null for each missing one",
+		Example:  "NODE.MGET my-graph service-a db-1",
+		ReadOnly: true,
+		Handler:  sessionless(n.handleMGet),
+	})
+	r.Register(CommandSpec{
+		Name:     "NODE.UPDATE",
+		Args:     "<graph> <id> [TYPE <new_type>] [ATTRIBUTES <attributes_json> | MERGE <attributes_json>] [TTL <seconds>] [IFGEN <generation>]",
+		Keywords: []string{"TYPE", "ATTRIBUTES", "MERGE", "TTL", "IFGEN"},
+		Summary:  "Updates a node's type, attributes and/or TTL, replacing the attributes or merging keys into them",
+		Example:  `NODE.UPDATE my-graph service-a TYPE microservice ATTRIBUTES '{"version":"2.0"}'`,
+		Handler:  sessionless(n.handleUpdate),
+	})
+	r.Register(CommandSpec{
+		Name:    "NODE.ATTR.SET",
+		Args:    "<graph> <id> <key> <value_json>",
+		Summary: "Sets one attribute of a node, keeping the others",
+		Example: `NODE.ATTR.SET my-graph service-a version '"2.1"'`,
+		Handler: sessionless(n.handleAttrSet),
+	})
+	r.Register(CommandSpec{
+		Name:    "NODE.ATTR.DEL",
+		Args:    "<graph> <id> <key>",
+		Summary: "Removes one attribute of a node",
+		Example: "NODE.ATTR.DEL my-graph service-a version",
+		Handler: sessionless(n.handleAttrDel),
+	})
+	r.Register(CommandSpec{
+		Name:     "NODE.DELETE",
+		Args:     "<graph> <id> [IFGEN <generation>]",
+		Keywords: []string{"IFGEN"},
+		Summary:  "Deletes a node and all of its edges",
+		Example:  "NODE.DELETE my-graph service-a",
+		Handler:  sessionless(n.handleDelete),
+	})
+	r.Register(CommandSpec{
+		Name:     "NODE.FILTER",
+		Args:     "<graph> [<attribute_key> <attribute_value>] [UPDATEDBEFORE <rfc3339|seconds>] [FORMAT csv|tsv] [ORDERBY id|type|created|updated [DESC]]",
+		Keywords: []string{"UPDATEDBEFORE", "FORMAT", "ORDERBY", "DESC"},
+		Summary:  "Finds the nodes with an attribute value or last updated before a time",
+		Example:  "NODE.FILTER my-graph region us-east-1",
+		ReadOnly: true,
+		Handler:  sessionless(n.handleFilter),
+	})
+	r.Register(CommandSpec{
+		Name:     "NODE.LIST",
+		Args:     "<graph> [LABELS] [AGE] [FORMAT csv|tsv | CURSOR <cursor> [COUNT <n>]] [ORDERBY id|type|created|updated [DESC]]",
+		Keywords: []string{"LABELS", "AGE", "FORMAT", "ORDERBY", "DESC", "CURSOR", "COUNT"},
+		Summary:  "Lists the nodes of a graph as id:type",
+		Example:  "NODE.LIST my-graph LABELS",
+		ReadOnly: true,
+		Handler:  sessionless(n.handleList),
+	})
+	r.Register(CommandSpec{
+		Name:     "NODE.EXISTS",
+		Args:     "<graph> <id>",
+		Summary:  "Checks whether a node exists",
+		Example:  "NODE.EXISTS my-graph service-a",
+		ReadOnly: true,
+		Handler:  sessionless(n.handleExists),
+	})
+	r.Register(CommandSpec{
+		Name:     "NODE.ALIAS",
+		Args:     "ADD <graph> <id> <alias> | REMOVE <graph> <id> <alias> | LIST <graph> <id>",
+		Keywords: []string{"ADD", "REMOVE", "LIST"},
+		Summary:  "Adds, removes or lists the aliases a node can also be addressed by",
+		Example:  "NODE.ALIAS ADD my-graph service-a svc-a.internal",
+		Handler:  sessionless(n.handleAlias),
+	})
+	r.Register(CommandSpec{
+		Name:    "NODE.RETYPE",
+		Args:    "<graph> <old_type> <new_type>",
+		Summary: "Changes the type of every node of one type",
+		Example: "NODE.RETYPE my-graph service microservice",
+		Handler: sessionless(n.handleRetype),
+	})
+}
+
+// handleCreate handles NODE.CREATE <graph> <id|AUTO> <type> [attributes_json] [TTL <seconds>] [NX] [IFGEN <generation>]
+// With AUTO the server generates the ID and replies with it. NX fails with
+// EXISTS rather than replacing a node that has the ID.
 func (n *NodeCommands) handleCreate(args []string) (*protocol.Response, error) {
 	if len(args) < 3 {
 		return nil, fmt.Errorf("NODE.CREATE requires at least 3 arguments: graph, id, type")
 	}
+	args, generation, err := takeIfGen(args, 3)
+	if err != nil {
+		return nil, err
+	}
 
 	graphID := args[0]
 	nodeID := args[1]
@@ -58,34 +168,44 @@ func (n *NodeCommands) handleCreate(args []string) (*protocol.Response, error) {
 
 	attributes := make(map[string]interface{})
 	var ttlSeconds int64 = -1
+	auto := isAuto(nodeID)
+	nx := false
 
 	// Parse optional arguments
 	i := 3
 	for i < len(args) {
 		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+			i++
 		case "TTL":
 			if i+1 >= len(args) {
 				return nil, fmt.Errorf("TTL option requires a value")
 			}
 			ttl, err := strconv.ParseInt(args[i+1], 10, 64)
 			if err != nil {
-				return nil, fmt.Errorf("invalid TTL value: %v", err)
+				return nil, fmt.Errorf("invalid TTL value: %w", err)
 			}
 			ttlSeconds = ttl
 			i += 2
 		default:
 			// Assume it's the attributes JSON
-			if err := json.Unmarshal([]byte(args[i]), &attributes); err != nil {
-				return nil, fmt.Errorf("invalid attributes JSON: %v", err)
+			parsed, err := parseAttributesJSON("node", args[i])
+			if err != nil {
+				return nil, err
 			}
+			attributes = parsed
 			i++
 		}
 	}
 
+	now := time.Now()
 	node := &models.Node{
 		ID:         models.NodeID(nodeID),
 		Type:       models.NodeType(nodeType),
 		Attributes: attributes,
+		CreatedAt:  now,
+		UpdatedAt:  now,
 	}
 
 	if ttlSeconds > 0 {
@@ -93,74 +213,95 @@ func (n *NodeCommands) handleCreate(args []string) (*protocol.Response, error) {
 		node.ExpiresAt = &expiresAt
 	}
 
-	err := n.storage.CreateNode(models.GraphID(graphID), node)
+	if err := node.Validate(); err != nil {
+		return nil, err
+	}
+	err = writeGraph(n.storage, models.GraphID(graphID), generation, func(tx storage.Transaction) error {
+		if auto {
+			id, err := newNodeID(tx, models.GraphID(graphID), n.ids)
+			if err != nil {
+				return err
+			}
+			node.ID = id
+		} else if nx {
+			if err := requireNewNode(tx, models.GraphID(graphID), node.ID); err != nil {
+				return err
+			}
+		}
+		return tx.CreateNode(models.GraphID(graphID), node)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create node: %v", err)
+		return nil, wrapError("create node", err)
 	}
+	n.storage.RecordActivity(models.GraphID(graphID), storage.ActivityNodeCreate, 1)
 
+	if auto {
+		return protocol.NewBulkResponse(string(node.ID)), nil
+	}
 	return protocol.OK(), nil
 }
 
-// handleGet handles NODE.GET <graph> <id>
+// handleGet handles NODE.GET <graph> <id> [ATTRS <offset> <count> | ATTRKEYS]
 func (n *NodeCommands) handleGet(args []string) (*protocol.Response, error) {
-	if len(args) != 2 {
-		return nil, fmt.Errorf("NODE.GET requires exactly 2 arguments: graph, id")
+	if len(args) < 2 {
+		return nil, fmt.Errorf("NODE.GET requires at least 2 arguments: graph, id")
+	}
+	view, err := parseAttrView("NODE.GET", args[2:])
+	if err != nil {
+		return nil, err
 	}
 
 	graphID := args[0]
-	nodeID := args[1]
+	nodeID, err := resolveNodeID(n.storage, models.GraphID(graphID), args[1])
+	if err != nil {
+		return nil, err
+	}
 
-	node, err := n.storage.GetNode(models.GraphID(graphID), models.NodeID(nodeID))
+	node, err := n.storage.GetNode(models.GraphID(graphID), nodeID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get node: %v", err)
+		return nil, fmt.Errorf("failed to get node: %w", err)
 	}
 
 	if node == nil {
 		return protocol.NewNullResponse(), nil
 	}
 
-	// Serialize attributes
-	attributesJSON, err := json.Marshal(node.Attributes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to serialize node attributes: %v", err)
-	}
-
 	expiresAtStr := ""
 	if node.ExpiresAt != nil {
 		expiresAtStr = node.ExpiresAt.Format(time.RFC3339)
 	}
 
+	// The attributes are serialized into the third field
 	result := []string{
 		string(node.ID),
 		string(node.Type),
-		string(attributesJSON),
+		"",
 		expiresAtStr,
 	}
 
-	return protocol.NewArrayResponse(result), nil
+	return view.reply(result, 2, node.Attributes)
 }
 
-// handleUpdate handles NODE.UPDATE <graph> <id> [TYPE <new_type>] [ATTRIBUTES <attributes_json>] [TTL <seconds>]
+// handleUpdate handles NODE.UPDATE <graph> <id> [TYPE <new_type>] [ATTRIBUTES <attributes_json> | MERGE <attributes_json>] [TTL <seconds>] [IFGEN <generation>].
+// MERGE sets the keys it holds and keeps the others; nested objects are replaced, not merged.
 func (n *NodeCommands) handleUpdate(args []string) (*protocol.Response, error) {
+	args, generation, err := takeIfGen(args, 2)
+	if err != nil {
+		return nil, err
+	}
 	if len(args) < 3 {
 		return nil, fmt.Errorf("NODE.UPDATE requires at least 3 arguments: graph, id, and at least one update parameter")
 	}
 
 	graphID := args[0]
-	nodeID := args[1]
-
-	// Get the existing node first
-	existingNode, err := n.storage.GetNode(models.GraphID(graphID), models.NodeID(nodeID))
+	nodeID, err := resolveNodeID(n.storage, models.GraphID(graphID), args[1])
 	if err != nil {
-		return nil, fmt.Errorf("failed to get node for update: %v", err)
-	}
-	if existingNode == nil {
-		return nil, fmt.Errorf("node not found")
+		return nil, err
 	}
 
 	// Parse arguments - support both old and new syntax
 	var newType *models.NodeType
-	var attributes map[string]interface{}
+	var attributes, merge map[string]interface{}
 	var ttlSeconds int64 = -1
 	
 	i := 2
@@ -170,6 +311,9 @@ func (n *NodeCommands) handleUpdate(args []string) (*protocol.Response, error) {
 			if i+1 >= len(args) {
 				return nil, fmt.Errorf("TYPE parameter requires a value")
 			}
+			if err := models.ValidateType("node", args[i+1]); err != nil {
+				return nil, err
+			}
 			typeValue := models.NodeType(args[i+1])
 			newType = &typeValue
 			i += 2
@@ -177,9 +321,21 @@ func (n *NodeCommands) handleUpdate(args []string) (*protocol.Response, error) {
 			if i+1 >= len(args) {
 				return nil, fmt.Errorf("ATTRIBUTES parameter requires a JSON value")
 			}
-			if err := json.Unmarshal([]byte(args[i+1]), &attributes); err != nil {
-				return nil, fmt.Errorf("invalid attributes JSON: %v", err)
+			parsed, err := parseAttributesJSON("node", args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			attributes = parsed
+			i += 2
+		case "MERGE":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("MERGE parameter requires a JSON value")
 			}
+			parsed, err := parseAttributesJSON("node", args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			merge = parsed
 			i += 2
 		case "TTL":
 			if i+1 >= len(args) {
@@ -187,7 +343,7 @@ func (n *NodeCommands) handleUpdate(args []string) (*protocol.Response, error) {
 			}
 			ttl, err := strconv.ParseInt(args[i+1], 10, 64)
 			if err != nil {
-				return nil, fmt.Errorf("invalid TTL value: %v", err)
+				return nil, fmt.Errorf("invalid TTL value: %w", err)
 			}
 			ttlSeconds = ttl
 			i += 2
@@ -195,15 +351,17 @@ func (n *NodeCommands) handleUpdate(args []string) (*protocol.Response, error) {
 			// Support legacy syntax: NODE.UPDATE <graph> <id> <attributes_json> [TTL <seconds>]
 			if i == 2 {
 				// Third argument is attributes JSON in legacy format
-				if err := json.Unmarshal([]byte(args[i]), &attributes); err != nil {
-					return nil, fmt.Errorf("invalid attributes JSON: %v", err)
+				parsed, err := parseAttributesJSON("node", args[i])
+				if err != nil {
+					return nil, err
 				}
+				attributes = parsed
 				i++
 				// Check for legacy TTL format
 				if i < len(args) && i+1 < len(args) && strings.ToUpper(args[i]) == "TTL" {
 					ttl, err := strconv.ParseInt(args[i+1], 10, 64)
 					if err != nil {
-						return nil, fmt.Errorf("invalid TTL value: %v", err)
+						return nil, fmt.Errorf("invalid TTL value: %w", err)
 					}
 					ttlSeconds = ttl
 					i += 2
@@ -215,77 +373,230 @@ func (n *NodeCommands) handleUpdate(args []string) (*protocol.Response, error) {
 	}
 
 	// Validate that at least one update parameter was provided
-	if newType == nil && attributes == nil && ttlSeconds == -1 {
-		return nil, fmt.Errorf("at least one update parameter (TYPE, ATTRIBUTES, or TTL) must be provided")
-	}
-
-	// Apply updates
-	if newType != nil {
-		existingNode.Type = *newType
+	if newType == nil && attributes == nil && merge == nil && ttlSeconds == -1 {
+		return nil, fmt.Errorf("at least one update parameter (TYPE, ATTRIBUTES, MERGE, or TTL) must be provided")
 	}
-	
-	if attributes != nil {
-		existingNode.Attributes = attributes
+	if attributes != nil && merge != nil {
+		return nil, fmt.Errorf("ATTRIBUTES and MERGE cannot be combined")
 	}
 
-	if ttlSeconds >= 0 {
-		if ttlSeconds == 0 {
-			// TTL of 0 means remove expiration
-			existingNode.ExpiresAt = nil
-		} else {
-			expiresAt := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
-			existingNode.ExpiresAt = &expiresAt
+	// The node is read and written in one transaction, so MERGE keeps the
+	// keys a concurrent update sets. An update that leaves the node as
+	// stored is not written.
+	written, err := updateNode(n.storage, models.GraphID(graphID), nodeID, generation, func(node *models.Node) error {
+		if newType != nil {
+			node.Type = *newType
 		}
-	}
-
-	// Update the timestamp
-	existingNode.UpdatedAt = time.Now()
-
-	err = n.storage.UpdateNode(models.GraphID(graphID), existingNode)
+		if attributes != nil {
+			node.Attributes = attributes
+		}
+		if merge != nil {
+			node.Attributes = mergeAttributes(node.Attributes, merge)
+		}
+		if ttlSeconds >= 0 {
+			if ttlSeconds == 0 {
+				// TTL of 0 means remove expiration
+				node.ExpiresAt = nil
+			} else {
+				expiresAt := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+				node.ExpiresAt = &expiresAt
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update node: %v", err)
+		return nil, wrapError("update node", err)
 	}
+	if !written {
+		return protocol.NewStringResponse("NOCHANGE"), nil
+	}
+	n.storage.RecordActivity(models.GraphID(graphID), storage.ActivityNodeUpdate, 1)
 
 	return protocol.OK(), nil
 }
 
-// handleDelete handles NODE.DELETE <graph> <id>
+// handleDelete handles NODE.DELETE <graph> <id> [IFGEN <generation>]
 func (n *NodeCommands) handleDelete(args []string) (*protocol.Response, error) {
+	args, generation, err := takeIfGen(args, 2)
+	if err != nil {
+		return nil, err
+	}
 	if len(args) != 2 {
 		return nil, fmt.Errorf("NODE.DELETE requires exactly 2 arguments: graph, id")
 	}
 
 	graphID := args[0]
-	nodeID := args[1]
+	nodeID, err := resolveNodeID(n.storage, models.GraphID(graphID), args[1])
+	if err != nil {
+		return nil, err
+	}
 
-	err := n.storage.DeleteNode(models.GraphID(graphID), models.NodeID(nodeID))
+	err = writeGraph(n.storage, models.GraphID(graphID), generation, func(tx storage.Transaction) error {
+		return tx.DeleteNode(models.GraphID(graphID), nodeID)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete node: %v", err)
+		return nil, wrapError("delete node", err)
 	}
+	n.storage.RecordActivity(models.GraphID(graphID), storage.ActivityNodeDelete, 1)
 
 	return protocol.OK(), nil
 }
 
-// handleFilter handles NODE.FILTER <graph> <attribute_key> <attribute_value>
-func (n *NodeCommands) handleFilter(args []string) (*protocol.Response, error) {
+// handleAlias handles NODE.ALIAS ADD|REMOVE <graph> <id> <alias> and
+// NODE.ALIAS LIST <graph> <id>. The node may itself be given by an alias.
+func (n *NodeCommands) handleAlias(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("NODE.ALIAS requires a subcommand: ADD, REMOVE or LIST")
+	}
+
+	subcommand := strings.ToUpper(args[0])
+	switch subcommand {
+	case "ADD", "REMOVE":
+		if len(args) != 4 {
+			return nil, fmt.Errorf("NODE.ALIAS %s requires exactly 3 arguments: graph, id, alias", subcommand)
+		}
+	case "LIST":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("NODE.ALIAS LIST requires exactly 2 arguments: graph, id")
+		}
+	default:
+		return nil, fmt.Errorf("unknown NODE.ALIAS subcommand: %s", args[0])
+	}
+
+	graphID := models.GraphID(args[1])
+	nodeID, err := resolveNodeID(n.storage, graphID, args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	switch subcommand {
+	case "ADD":
+		if err := n.storage.AddNodeAlias(graphID, nodeID, args[3]); err != nil {
+			return nil, wrapError("add alias", err)
+		}
+		return protocol.OK(), nil
+	case "REMOVE":
+		removed, err := n.storage.RemoveNodeAlias(graphID, nodeID, args[3])
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove alias: %w", err)
+		}
+		if removed {
+			return protocol.NewIntResponse(1), nil
+		}
+		return protocol.NewIntResponse(0), nil
+	default:
+		aliases, err := n.storage.ListNodeAliases(graphID, nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list aliases: %w", err)
+		}
+		return protocol.NewArrayResponse(aliases), nil
+	}
+}
+
+// resolveNodeID returns the node ID a command argument names: the argument
+// itself if a node has that ID, or else the node it is an alias of. Every
+// command taking an existing node resolves it here.
+func resolveNodeID(s storage.StorageEngine, graphID models.GraphID, id string) (models.NodeID, error) {
+	nodeID, err := s.ResolveNodeID(graphID, models.NodeID(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve node %s: %w", id, err)
+	}
+	return nodeID, nil
+}
+
+// handleRetype handles NODE.RETYPE <graph> <old_type> <new_type>
+func (n *NodeCommands) handleRetype(args []string) (*protocol.Response, error) {
 	if len(args) != 3 {
-		return nil, fmt.Errorf("NODE.FILTER requires exactly 3 arguments: graph, attribute_key, attribute_value")
+		return nil, fmt.Errorf("NODE.RETYPE requires exactly 3 arguments: graph, old_type, new_type")
+	}
+	if err := models.ValidateType("node", args[2]); err != nil {
+		return nil, err
+	}
+
+	count, err := n.storage.RenameNodeType(models.GraphID(args[0]), models.NodeType(args[1]), models.NodeType(args[2]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename node type: %w", err)
+	}
+	n.storage.RecordActivity(models.GraphID(args[0]), storage.ActivityNodeUpdate, count)
+
+	return protocol.NewIntResponse(int64(count)), nil
+}
+
+// handleFilter handles NODE.FILTER <graph> [<attribute_key> <attribute_value>] [UPDATEDBEFORE <rfc3339|seconds>] [FORMAT csv|tsv] [ORDERBY id|type|created|updated [DESC]]
+func (n *NodeCommands) handleFilter(args []string) (*protocol.Response, error) {
+	// ORDERBY must also follow a filter
+	args, order, err := takeOrderBy(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf("NODE.FILTER requires a graph and an attribute filter or UPDATEDBEFORE")
 	}
 
 	graphID := args[0]
-	attrKey := args[1]
-	attrValue := args[2]
+	filters := args[1:]
+
+	// Parse the optional trailing format. It must follow a filter, so a
+	// lone FORMAT pair is still an attribute filter.
+	format := ""
+	if len(filters) >= 4 && strings.ToUpper(filters[len(filters)-2]) == "FORMAT" {
+		var err error
+		format, err = parseTableFormat(filters[len(filters)-1])
+		if err != nil {
+			return nil, err
+		}
+		filters = filters[:len(filters)-2]
+	}
 
-	// Attempt to unmarshal the value as JSON, if it fails, use it as a string
-	var value interface{}
-	if err := json.Unmarshal([]byte(attrValue), &value); err != nil {
-		value = attrValue
+	// Parse the optional trailing staleness filter
+	var updatedBefore *time.Time
+	if len(filters) >= 2 && strings.ToUpper(filters[len(filters)-2]) == "UPDATEDBEFORE" {
+		cutoff, err := parseUpdatedBefore(filters[len(filters)-1])
+		if err != nil {
+			return nil, err
+		}
+		updatedBefore = &cutoff
+		filters = filters[:len(filters)-2]
 	}
 
-	nodes, err := n.storage.FindNodesByAttribute(models.GraphID(graphID), attrKey, value)
-	if err != nil {
-		return nil, fmt.Errorf("failed to filter nodes by attribute: %v", err)
+	var nodes []*models.Node
+	switch len(filters) {
+	case 0:
+		nodes, err = n.storage.ListNodes(models.GraphID(graphID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get nodes: %w", err)
+		}
+	case 2:
+		attrKey := filters[0]
+		attrValue := filters[1]
+
+		// Attempt to unmarshal the value as JSON, if it fails, use it as a string
+		var value interface{}
+		if err := json.Unmarshal([]byte(attrValue), &value); err != nil {
+			value = attrValue
+		}
+
+		nodes, err = n.storage.FindNodesByAttribute(models.GraphID(graphID), attrKey, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter nodes by attribute: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("NODE.FILTER requires both attribute_key and attribute_value")
+	}
+
+	if updatedBefore != nil {
+		var stale []*models.Node
+		for _, node := range nodes {
+			if node.UpdatedBefore(*updatedBefore) {
+				stale = append(stale, node)
+			}
+		}
+		nodes = stale
+	}
+	if err := order.check("NODE.FILTER", len(nodes), n.sortLimit); err != nil {
+		return nil, err
 	}
+	order.sortNodes(nodes)
 
 	// Format response as array of node data
 	result := make([]string, 0, len(nodes)*3)
@@ -298,20 +609,104 @@ func (n *NodeCommands) handleFilter(args []string) (*protocol.Response, error) {
 		result = append(result, string(node.ID), string(node.Type), string(attributesJSON))
 	}
 
+	if format != "" {
+		rows := make([][]string, 0, len(result)/3)
+		for i := 0; i+2 < len(result); i += 3 {
+			rows = append(rows, result[i:i+3])
+		}
+		return tableResponse(format, []string{"id", "type", "attributes"}, rows)
+	}
 	return protocol.NewArrayResponse(result), nil
 }
 
-// handleList handles NODE.LIST <graph>
+// handleList handles NODE.LIST <graph> [LABELS] [AGE] [FORMAT csv|tsv | CURSOR <cursor> [COUNT <n>]] [ORDERBY id|type|created|updated [DESC]].
+// With CURSOR the reply is the next cursor, "0" after the last page,
+// followed by a page of nodes read on their own, in ID order.
 func (n *NodeCommands) handleList(args []string) (*protocol.Response, error) {
-	if len(args) != 1 {
-		return nil, fmt.Errorf("NODE.LIST requires exactly 1 argument: graph")
+	args, order, err := takeOrderBy(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	args, cursor, err := takeListCursor(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 1 || len(args) > 5 {
+		return nil, fmt.Errorf("NODE.LIST requires 1 argument: graph, and optionally LABELS, AGE, FORMAT, ORDERBY or CURSOR")
 	}
 
 	graphID := args[0]
+	withLabels := false
+	withAge := false
+	format := ""
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "LABELS":
+			withLabels = true
+		case "AGE":
+			withAge = true
+		case "FORMAT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("FORMAT option requires an argument")
+			}
+			i++
+			var err error
+			format, err = parseTableFormat(args[i])
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid argument: %s", args[i])
+		}
+	}
+
+	if cursor.set && (format != "" || order.key != "") {
+		return nil, fmt.Errorf("CURSOR cannot be combined with FORMAT or ORDERBY")
+	}
+
+	labels, err := newLabeler(n.storage, models.GraphID(graphID), withLabels, withAge)
+	if err != nil {
+		return nil, err
+	}
+
+	// A page is read on its own, so the graph is never listed whole
+	if cursor.set {
+		nodes, more, err := n.storage.ListNodesPage(models.GraphID(graphID), models.NodeID(cursor.after), cursor.count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get nodes: %w", err)
+		}
+		lastID := ""
+		result := make([]string, 1, len(nodes)+1)
+		for _, node := range nodes {
+			result = append(result, labels.node(node))
+			lastID = string(node.ID)
+		}
+		result[0] = cursor.next(lastID, more)
+		return protocol.NewArrayResponse(result), nil
+	}
+
 	// Get all nodes in the graph using ListNodes instead
 	nodes, err := n.storage.ListNodes(models.GraphID(graphID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get nodes: %v", err)
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	if err := order.check("NODE.LIST", len(nodes), n.sortLimit); err != nil {
+		return nil, err
+	}
+	order.sortNodes(nodes)
+
+	if format != "" {
+		header := append([]string{"id", "type"}, labels.columns()...)
+		rows := make([][]string, 0, len(nodes))
+		for _, node := range nodes {
+			attributes, err := attributesColumn(node.Attributes)
+			if err != nil {
+				return nil, err
+			}
+			row := append([]string{string(node.ID), string(node.Type)}, labels.nodeColumns(node)...)
+			rows = append(rows, append(row, attributes))
+		}
+		return tableResponse(format, append(header, "attributes"), rows)
 	}
 
 	if nodes == nil {
@@ -321,7 +716,7 @@ func (n *NodeCommands) handleList(args []string) (*protocol.Response, error) {
 	// Return node IDs and types in id:type format for consistency
 	result := make([]string, 0, len(nodes))
 	for _, node := range nodes {
-		result = append(result, string(node.ID)+":"+string(node.Type))
+		result = append(result, labels.node(node))
 	}
 
 	return protocol.NewArrayResponse(result), nil
@@ -334,11 +729,14 @@ func (n *NodeCommands) handleExists(args []string) (*protocol.Response, error) {
 	}
 
 	graphID := args[0]
-	nodeID := args[1]
+	nodeID, err := resolveNodeID(n.storage, models.GraphID(graphID), args[1])
+	if err != nil {
+		return nil, err
+	}
 
-	node, err := n.storage.GetNode(models.GraphID(graphID), models.NodeID(nodeID))
+	node, err := n.storage.GetNode(models.GraphID(graphID), nodeID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check node existence: %v", err)
+		return nil, fmt.Errorf("failed to check node existence: %w", err)
 	}
 
 	if node != nil {
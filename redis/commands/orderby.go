@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+)
+
+// DefaultMaxSortResults is the most results ORDERBY sorts unless
+// configured otherwise
+const DefaultMaxSortResults = 10_000
+
+// orderKeys are the keys ORDERBY accepts
+var orderKeys = map[string]bool{"id": true, "type": true, "created": true, "updated": true}
+
+// orderBy is the ORDERBY option of the list, filter and neighbor commands.
+// The zero value keeps the storage order, which is by ID.
+type orderBy struct {
+	key  string
+	desc bool
+}
+
+// sortKeys are the fields of a node or edge ORDERBY compares
+type sortKeys struct {
+	id      string
+	typ     string
+	created time.Time
+	updated time.Time
+}
+
+// nodeSortKeys returns the fields of a node ORDERBY compares
+func nodeSortKeys(node *models.Node) sortKeys {
+	return sortKeys{string(node.ID), string(node.Type), node.CreatedAt, node.UpdatedAt}
+}
+
+// edgeSortKeys returns the fields of an edge ORDERBY compares
+func edgeSortKeys(edge *models.Edge) sortKeys {
+	return sortKeys{string(edge.ID), string(edge.Type), edge.CreatedAt, edge.UpdatedAt}
+}
+
+// takeOrderBy removes ORDERBY <key> [DESC] from the end of args and parses
+// it. It is only taken if at least keep arguments precede it, so that
+// commands whose positional arguments could be ORDERBY still see them.
+func takeOrderBy(args []string, keep int) ([]string, orderBy, error) {
+	var order orderBy
+	n := len(args)
+	switch {
+	case n >= keep+3 && strings.ToUpper(args[n-3]) == "ORDERBY" && strings.ToUpper(args[n-1]) == "DESC":
+		order.key, order.desc = strings.ToLower(args[n-2]), true
+		args = args[:n-3]
+	case n >= keep+2 && strings.ToUpper(args[n-2]) == "ORDERBY":
+		order.key = strings.ToLower(args[n-1])
+		args = args[:n-2]
+	case n >= keep+1 && strings.ToUpper(args[n-1]) == "ORDERBY":
+		return nil, order, fmt.Errorf("ORDERBY option requires a key: id, type, created or updated")
+	default:
+		return args, order, nil
+	}
+	if !orderKeys[order.key] {
+		return nil, order, fmt.Errorf("invalid ORDERBY key: %s (must be id, type, created or updated)", order.key)
+	}
+	return args, order, nil
+}
+
+// check refuses to sort more than limit results of command, as sorting
+// needs them all in memory before the first is sent. A limit of 0 is not
+// checked.
+func (o orderBy) check(command string, count, limit int) error {
+	if o.key == "" || limit <= 0 || count <= limit {
+		return nil
+	}
+	return fmt.Errorf("%w %s has %d results, more than the %d ORDERBY sorts. Drop ORDERBY to read them in ID order, or narrow the query",
+		ErrGraphTooLarge, command, count, limit)
+}
+
+// less reports whether a sorts before b. Entities without the timestamp
+// sort as the oldest. Ties are broken by ascending ID, so the order is the
+// same on every call.
+func (o orderBy) less(a, b sortKeys) bool {
+	var cmp int
+	switch o.key {
+	case "type":
+		cmp = strings.Compare(a.typ, b.typ)
+	case "created":
+		cmp = a.created.Compare(b.created)
+	case "updated":
+		cmp = a.updated.Compare(b.updated)
+	}
+	if o.desc {
+		cmp = -cmp
+	}
+	if cmp != 0 {
+		return cmp < 0
+	}
+	if o.key == "id" && o.desc {
+		return a.id > b.id
+	}
+	return a.id < b.id
+}
+
+// sortNodes sorts nodes in place, unless no ORDERBY was given
+func (o orderBy) sortNodes(nodes []*models.Node) {
+	if o.key != "" {
+		sort.Slice(nodes, func(i, j int) bool { return o.less(nodeSortKeys(nodes[i]), nodeSortKeys(nodes[j])) })
+	}
+}
+
+// sortEdges sorts edges in place, unless no ORDERBY was given
+func (o orderBy) sortEdges(edges []*models.Edge) {
+	if o.key != "" {
+		sort.Slice(edges, func(i, j int) bool { return o.less(edgeSortKeys(edges[i]), edgeSortKeys(edges[j])) })
+	}
+}
@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ywadi/PathwayDB/jobs"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// ErrCursorStale is returned when a PAGE cursor can no longer be continued,
+// because the graph changed since the first page or its cached ranking has
+// expired. Errors wrapping it start with "CURSORSTALE" and are sent to
+// clients without the generic ERR prefix.
+var ErrCursorStale = errors.New("CURSORSTALE")
+
+// defaultPageCount is the page size used when PAGE is given without COUNT
+const defaultPageCount = 100
+
+// listCursor is the CURSOR <cursor> [COUNT <n>] option of NODE.LIST and
+// EDGE.LIST. As with GRAPH.ADJACENCY, cursor "0" starts at the first ID and
+// other cursors are ">" followed by the last ID of the previous page.
+type listCursor struct {
+	set   bool
+	after string
+	count int
+}
+
+// takeListCursor removes CURSOR <cursor> and COUNT <n> from the arguments
+// following the graph and parses them
+func takeListCursor(args []string) ([]string, listCursor, error) {
+	var cursor listCursor
+	if len(args) == 0 {
+		return args, cursor, nil
+	}
+	rest := []string{args[0]}
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "CURSOR":
+			if i+1 >= len(args) {
+				return nil, cursor, fmt.Errorf("CURSOR option requires a cursor")
+			}
+			i++
+			if args[i] != "0" && (!strings.HasPrefix(args[i], ">") || len(args[i]) == 1) {
+				return nil, cursor, fmt.Errorf("invalid cursor: %s", args[i])
+			}
+			cursor.set = true
+			cursor.after = strings.TrimPrefix(args[i], ">")
+			if args[i] == "0" {
+				cursor.after = ""
+			}
+		case "COUNT":
+			if i+1 >= len(args) {
+				return nil, cursor, fmt.Errorf("COUNT option requires a value")
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n <= 0 {
+				return nil, cursor, fmt.Errorf("invalid COUNT: %s (must be a positive integer)", args[i])
+			}
+			cursor.count = n
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	if cursor.count > 0 && !cursor.set {
+		return nil, cursor, fmt.Errorf("COUNT requires CURSOR")
+	}
+	if cursor.count == 0 {
+		cursor.count = defaultPageCount
+	}
+	return rest, cursor, nil
+}
+
+// next returns the cursor of the page after one ending at lastID, or "0" if
+// no more follow
+func (c listCursor) next(lastID string, more bool) string {
+	if !more {
+		return "0"
+	}
+	return ">" + lastID
+}
+
+// cursorCacheSize is the number of rankings kept for PAGE cursors, and
+// cursorCacheTTL how long each is kept. The cache is separate from the job
+// result store, so paging never evicts the results of submitted jobs.
+const (
+	cursorCacheSize = 16
+	cursorCacheTTL  = 10 * time.Minute
+)
+
+// newCursorCache returns the store of rankings cached for PAGE cursors. Only
+// Store and Lookup are used on it, so it starts no workers.
+func newCursorCache() *jobs.Manager {
+	return jobs.NewManager(jobs.Config{MaxResults: cursorCacheSize, ResultTTL: cursorCacheTTL})
+}
+
+// pageDegreeCentrality handles the PAGE form of degree centrality. Cursor
+// "0" ranks every node and caches the ranking in the cursor cache, keyed by
+// the graph's generation and the parameters; later cursors name the
+// cached ranking and an offset into it. The reply is the next cursor, "0"
+// after the last page, followed by id, score pairs.
+func (a *AnalysisCommands) pageDegreeCentrality(graphID models.GraphID, direction types.TraversalDirection, cursor string, count int) (*protocol.Response, error) {
+	// The generation is read before ranking, so a write that lands while
+	// the ranking is computed makes the cached copy stale rather than wrong
+	query := fmt.Sprintf("ANALYSIS.CENTRALITY %s degree DIRECTION %d", graphID, direction)
+	key := fmt.Sprintf("%s GENERATION %d", query, a.storage.Generation(graphID))
+
+	var jobID string
+	offset := 0
+	if cursor == "0" {
+		id, cached := a.cursors.Lookup(key)
+		if !cached {
+			ranked, err := a.rankDegreeCentrality(graphID, nil, direction, nil, 0)
+			if err != nil {
+				return nil, err
+			}
+			id, err = a.cursors.Store(key, protocol.NewArrayResponse(ranked))
+			if err != nil {
+				return nil, fmt.Errorf("failed to cache centrality: %w", err)
+			}
+		}
+		jobID = id
+	} else {
+		id, position, found := strings.Cut(cursor, ":")
+		n, err := strconv.Atoi(position)
+		if !found || err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid cursor: %s", cursor)
+		}
+		jobID, offset = id, n
+	}
+
+	status, err := a.cursors.Status(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("%w cursor %s has expired, restart from cursor 0", ErrCursorStale, cursor)
+	}
+	if status.Command != key {
+		if strings.HasPrefix(status.Command, query+" GENERATION ") {
+			return nil, fmt.Errorf("%w graph %s changed since cursor %s was issued, restart from cursor 0", ErrCursorStale, graphID, cursor)
+		}
+		return nil, fmt.Errorf("cursor %s does not belong to this query", cursor)
+	}
+
+	ranking, ok := status.Result.(*protocol.Response)
+	if !ok {
+		return nil, fmt.Errorf("cursor %s does not belong to this query", cursor)
+	}
+	pairs := ranking.ArrayValue
+	start := min(offset*2, len(pairs))
+	end := min(start+count*2, len(pairs))
+
+	next := "0"
+	if end < len(pairs) {
+		next = fmt.Sprintf("%s:%d", jobID, end/2)
+	}
+	response := make([]string, 0, 1+end-start)
+	response = append(response, next)
+	response = append(response, pairs[start:end]...)
+	return protocol.NewArrayResponse(response), nil
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// pairwiseKeywords ends the EDGETYPES list of ANALYSIS.PAIRWISE
+var pairwiseKeywords = map[string]bool{
+	"DIRECTION": true,
+	"EDGETYPES": true,
+	"MAXDEPTH":  true,
+}
+
+// handlePairwise handles ANALYSIS.PAIRWISE <graph> <id1,id2,...> [DIRECTION dir] [EDGETYPES type1...] [MAXDEPTH n].
+// The reply is a header row of the node IDs followed by one row of
+// distances per node, in the same order, with -1 for unreachable nodes.
+func (a *AnalysisCommands) handlePairwise(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("ANALYSIS.PAIRWISE requires at least 2 arguments: graph, node IDs")
+	}
+	graphID := models.GraphID(args[0])
+
+	options := &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: -1}
+	i := 2
+	for i < len(args) {
+		switch strings.ToUpper(args[i]) {
+		case "DIRECTION":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("DIRECTION option requires an argument")
+			}
+			direction, err := ParseDirection(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			options.Direction = direction
+			i += 2
+		case "EDGETYPES":
+			i++
+			for i < len(args) && !pairwiseKeywords[strings.ToUpper(args[i])] {
+				options.EdgeTypes = append(options.EdgeTypes, models.EdgeType(args[i]))
+				i++
+			}
+		case "MAXDEPTH":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("MAXDEPTH option requires an argument")
+			}
+			depth, err := strconv.Atoi(args[i+1])
+			if err != nil || depth < 0 {
+				return nil, fmt.Errorf("invalid MAXDEPTH: %s (must be a non-negative integer)", args[i+1])
+			}
+			options.MaxDepth = depth
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown option for ANALYSIS.PAIRWISE: %s", args[i])
+		}
+	}
+
+	ids := strings.Split(args[1], ",")
+	if max := a.limits.PairwiseNodes; max > 0 && len(ids) > max {
+		return nil, fmt.Errorf("ANALYSIS.PAIRWISE compares at most %d nodes, got %d", max, len(ids))
+	}
+	nodeIDs := make([]models.NodeID, len(ids))
+	seen := make(map[models.NodeID]bool, len(ids))
+	for j, id := range ids {
+		if id == "" {
+			return nil, fmt.Errorf("invalid node IDs: %s (must be a comma-separated list)", args[1])
+		}
+		nodeID, err := resolveNodeID(a.storage, graphID, id)
+		if err != nil {
+			return nil, err
+		}
+		if seen[nodeID] {
+			return nil, fmt.Errorf("node %s is listed more than once", id)
+		}
+		seen[nodeID] = true
+		nodeIDs[j] = nodeID
+	}
+
+	distances, err := a.analyzer.PairwiseDistances(graphID, nodeIDs, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute pairwise distances: %w", err)
+	}
+
+	header := make([]string, len(nodeIDs))
+	for j, nodeID := range nodeIDs {
+		header[j] = string(nodeID)
+	}
+	rows := []interface{}{header}
+	for _, source := range nodeIDs {
+		row := make([]string, len(nodeIDs))
+		for j, target := range nodeIDs {
+			row[j] = strconv.Itoa(distances[source][target])
+		}
+		rows = append(rows, row)
+	}
+	return protocol.NewNestedArrayResponse(rows), nil
+}
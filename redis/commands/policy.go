@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// handlePolicy handles GRAPH.POLICY SET <name> <json> | GET <name> |
+// STATUS <name> [PREVIEW]
+func (g *GraphCommands) handlePolicy(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("GRAPH.POLICY requires at least 2 arguments: SET|GET|STATUS, name")
+	}
+	graphID := models.GraphID(args[1])
+
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("GRAPH.POLICY SET requires exactly 2 arguments: name, policy_json")
+		}
+		graph, err := g.storage.GetGraph(graphID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get graph: %w", err)
+		}
+
+		// Unknown fields are rejected so a misspelled rule is not
+		// silently ignored
+		policy := &models.MaintenancePolicy{}
+		decoder := json.NewDecoder(bytes.NewReader([]byte(args[2])))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(policy); err != nil {
+			return nil, fmt.Errorf("invalid policy JSON: %w", err)
+		}
+		if err := policy.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid policy: %w", err)
+		}
+
+		graph.Maintenance = policy
+		graph.UpdatedAt = time.Now()
+		if err := g.storage.UpdateGraph(graph); err != nil {
+			return nil, fmt.Errorf("failed to update graph: %w", err)
+		}
+		return protocol.OK(), nil
+	case "GET":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("GRAPH.POLICY GET requires exactly 1 argument: name")
+		}
+		graph, err := g.storage.GetGraph(graphID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get graph: %w", err)
+		}
+		if graph.Maintenance == nil {
+			return protocol.NewNullResponse(), nil
+		}
+		policyJSON, err := json.Marshal(graph.Maintenance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize policy: %w", err)
+		}
+		return protocol.NewBulkResponse(string(policyJSON)), nil
+	case "STATUS":
+		var run *models.MaintenanceRun
+		var err error
+		switch {
+		case len(args) == 2:
+			run, err = g.storage.GetMaintenanceRun(graphID)
+		case len(args) == 3 && strings.ToUpper(args[2]) == "PREVIEW":
+			run, err = g.storage.PruneGraph(graphID, true)
+		default:
+			return nil, fmt.Errorf("GRAPH.POLICY STATUS requires: name, [PREVIEW]")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get maintenance status: %w", err)
+		}
+		if run == nil {
+			return protocol.NewNullResponse(), nil
+		}
+		return maintenanceRunResponse(run)
+	default:
+		return nil, fmt.Errorf("unknown GRAPH.POLICY subcommand: %s", args[0])
+	}
+}
+
+// maintenanceRunResponse formats a maintenance run as field and value pairs
+func maintenanceRunResponse(run *models.MaintenanceRun) (*protocol.Response, error) {
+	orphanNodes, err := json.Marshal(nodeIDsOrEmpty(run.OrphanNodes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize nodes: %w", err)
+	}
+	staleNodes, err := json.Marshal(nodeIDsOrEmpty(run.StaleNodes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize nodes: %w", err)
+	}
+	return protocol.NewArrayResponse([]string{
+		"started_at", run.StartedAt.UTC().Format(time.RFC3339),
+		"duration_ms", strconv.FormatInt(run.Duration.Milliseconds(), 10),
+		"dry_run", strconv.FormatBool(run.DryRun),
+		"orphans", strconv.Itoa(run.Orphans),
+		"stale", strconv.Itoa(run.Stale),
+		"failed", strconv.Itoa(run.Failed),
+		"orphan_nodes", string(orphanNodes),
+		"stale_nodes", string(staleNodes),
+	}), nil
+}
+
+// nodeIDsOrEmpty returns ids, or an empty slice so it encodes as [] rather
+// than null
+func nodeIDsOrEmpty(ids []models.NodeID) []models.NodeID {
+	if ids == nil {
+		return []models.NodeID{}
+	}
+	return ids
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// ErrGraphProtected is returned when a destructive command is refused
+// because the graph is protected and the command did not repeat its name
+// after CONFIRM. Errors wrapping it start with "PROTECTED" and are sent to
+// clients without the generic ERR prefix.
+var ErrGraphProtected = errors.New("PROTECTED")
+
+// SetLogger sets the logger that records attempts to destroy protected
+// graphs
+func (g *GraphCommands) SetLogger(logger *slog.Logger) {
+	g.logger = logger
+}
+
+// handleProtect handles GRAPH.PROTECT <name> [on|off]. Without a state it
+// replies with 1 if the graph is protected and 0 otherwise.
+func (g *GraphCommands) handleProtect(session *Session, args []string) (*protocol.Response, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("GRAPH.PROTECT requires 1 or 2 arguments: name, [on|off]")
+	}
+
+	graph, err := g.storage.GetGraph(models.GraphID(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get graph: %w", err)
+	}
+	if len(args) == 1 {
+		if graph.Protected {
+			return protocol.NewIntResponse(1), nil
+		}
+		return protocol.NewIntResponse(0), nil
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "on":
+		graph.Protected = true
+	case "off":
+		graph.Protected = false
+		g.logger.Warn("Graph protection turned off", "graph", graph.ID, "client", session.client())
+	default:
+		return nil, fmt.Errorf("invalid GRAPH.PROTECT state: %s (must be 'on' or 'off')", args[1])
+	}
+	graph.UpdatedAt = time.Now()
+	if err := g.storage.UpdateGraph(graph); err != nil {
+		return nil, fmt.Errorf("failed to update graph: %w", err)
+	}
+	return protocol.OK(), nil
+}
+
+// checkProtected refuses a destructive command on a protected graph unless
+// confirm, the argument given after CONFIRM, is the graph's name. Every
+// attempt on a protected graph is logged at warn with the client address,
+// whether it is refused or not. Graphs that do not exist are left for the
+// command to report.
+func (g *GraphCommands) checkProtected(session *Session, command string, graphID models.GraphID, confirm string) error {
+	graph, err := g.storage.GetGraph(graphID)
+	if err != nil || !graph.Protected {
+		return nil
+	}
+
+	attrs := []any{"command", command, "graph", graphID, "client", session.client()}
+	switch confirm {
+	case string(graphID):
+		g.logger.Warn("Confirmed destructive command on protected graph", attrs...)
+		return nil
+	case "":
+		g.logger.Warn("Refused destructive command on protected graph", attrs...)
+		return fmt.Errorf("%w graph %s is protected. Repeat its name after CONFIRM to run %s, or turn protection off with GRAPH.PROTECT",
+			ErrGraphProtected, graphID, command)
+	default:
+		g.logger.Warn("Refused destructive command on protected graph", append(attrs, "confirm", confirm)...)
+		return fmt.Errorf("%w CONFIRM %q does not match graph %s", ErrGraphProtected, confirm, graphID)
+	}
+}
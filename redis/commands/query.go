@@ -0,0 +1,271 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// QueryCommands handles named query Redis commands
+type QueryCommands struct {
+	storage  storage.StorageEngine
+	registry *Registry
+}
+
+// NewQueryCommands creates a new query commands handler. Saved queries are
+// replayed through registry, which also tells which commands are read-only.
+func NewQueryCommands(storageEngine storage.StorageEngine, registry *Registry) *QueryCommands {
+	return &QueryCommands{
+		storage:  storageEngine,
+		registry: registry,
+	}
+}
+
+// Handle routes query commands to their respective handlers
+func (q *QueryCommands) Handle(session *Session, command string, args []string) (*protocol.Response, error) {
+	return route(q.Register, session, "QUERY."+command, args)
+}
+
+// Register adds the query commands to a registry
+func (q *QueryCommands) Register(r *Registry) {
+	r.Register(CommandSpec{
+		Name:    "QUERY.SAVE",
+		Args:    "<name> <command_template>",
+		Summary: "Saves a command template, with $1, $2, ... for its arguments",
+		Example: `QUERY.SAVE downstream "ANALYSIS.TRAVERSE $1 $2 DIRECTION out"`,
+		Handler: q.handleSave,
+	})
+	r.Register(CommandSpec{
+		Name:    "QUERY.RUN",
+		Args:    "<name> [arg1 arg2 ...]",
+		Summary: "Runs a saved query with the given arguments",
+		Example: "QUERY.RUN downstream my-graph service-a",
+		Handler: q.handleRun,
+	})
+	r.Register(CommandSpec{
+		Name:    "QUERY.LIST",
+		Summary: "Lists the saved queries and their templates",
+		Example: "QUERY.LIST",
+		Handler: sessionless(q.handleList),
+	})
+	r.Register(CommandSpec{
+		Name:    "QUERY.DELETE",
+		Args:    "<name>",
+		Summary: "Deletes a saved query",
+		Example: "QUERY.DELETE downstream",
+		Handler: q.handleDelete,
+	})
+}
+
+// handleSave handles QUERY.SAVE <name> <command_template>
+func (q *QueryCommands) handleSave(session *Session, args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("QUERY.SAVE requires at least 2 arguments: name, command_template")
+	}
+
+	name := args[0]
+	template := strings.Join(args[1:], " ")
+
+	// A single template argument is a full command string; several arguments
+	// are taken as already tokenized by the client.
+	tokens := args[1:]
+	if len(args) == 2 {
+		var err error
+		tokens, err = splitTemplate(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid command template: %w", err)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("invalid command template: empty command")
+	}
+
+	command := NormalizeCommand(tokens[0])
+	if strings.Contains(command, "$") {
+		return nil, fmt.Errorf("invalid command template: the command name cannot be a placeholder")
+	}
+	if strings.HasPrefix(command, "QUERY.") {
+		return nil, fmt.Errorf("invalid command template: queries cannot run QUERY commands")
+	}
+	if !q.registry.IsReadOnly(command, tokens[1:]) && !session.Admin {
+		return nil, fmt.Errorf("only read-only commands can be saved without the admin role: %s", command)
+	}
+
+	now := time.Now()
+	query := &models.NamedQuery{
+		Name:      name,
+		Template:  template,
+		Command:   command,
+		Args:      tokens[1:],
+		CreatedBy: session.Client,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if existing, err := q.storage.GetQuery(name); err == nil {
+		if !mayReplace(session, existing) {
+			return nil, fmt.Errorf("query %s was saved by another connection; only it or an admin can replace it", name)
+		}
+		query.CreatedBy = existing.CreatedBy
+		query.CreatedAt = existing.CreatedAt
+	}
+
+	if err := q.storage.SaveQuery(query); err != nil {
+		return nil, fmt.Errorf("failed to save query: %w", err)
+	}
+
+	return protocol.OK(), nil
+}
+
+// handleRun handles QUERY.RUN <name> [arg1 arg2 ...]
+func (q *QueryCommands) handleRun(session *Session, args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("QUERY.RUN requires at least 1 argument: name")
+	}
+
+	query, err := q.storage.GetQuery(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get query: %w", err)
+	}
+
+	expanded, err := expandTemplate(query.Args, args[1:])
+	if err != nil {
+		return nil, fmt.Errorf("QUERY.RUN %s: %w", query.Name, err)
+	}
+	// The template may have been saved by an admin, so the caller's role is
+	// checked again against the command it expands to
+	if !q.registry.IsReadOnly(query.Command, expanded) && !session.Admin {
+		return nil, fmt.Errorf("QUERY.RUN %s: only read-only commands can be run without the admin role: %s", query.Name, query.Command)
+	}
+
+	return q.registry.Dispatch(session, query.Command, expanded)
+}
+
+// handleList handles QUERY.LIST
+func (q *QueryCommands) handleList(args []string) (*protocol.Response, error) {
+	queries, err := q.storage.ListQueries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queries: %w", err)
+	}
+
+	result := make([]string, 0, len(queries)*2)
+	for _, query := range queries {
+		result = append(result, query.Name, query.Template)
+	}
+
+	return protocol.NewArrayResponse(result), nil
+}
+
+// handleDelete handles QUERY.DELETE <name>
+func (q *QueryCommands) handleDelete(session *Session, args []string) (*protocol.Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("QUERY.DELETE requires exactly 1 argument: name")
+	}
+
+	query, err := q.storage.GetQuery(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete query: %w", err)
+	}
+	if !mayReplace(session, query) {
+		return nil, fmt.Errorf("query %s was saved by another connection; only it or an admin can delete it", query.Name)
+	}
+
+	if err := q.storage.DeleteQuery(args[0]); err != nil {
+		return nil, fmt.Errorf("failed to delete query: %w", err)
+	}
+
+	return protocol.OK(), nil
+}
+
+// mayReplace reports whether session may overwrite or delete a saved query:
+// admins may change any query, other connections only the ones they saved
+func mayReplace(session *Session, query *models.NamedQuery) bool {
+	return session.Admin || (query.CreatedBy != "" && query.CreatedBy == session.Client)
+}
+
+// placeholderPattern matches $1, $2, ... inside a template token
+var placeholderPattern = regexp.MustCompile(`\$([1-9][0-9]*)`)
+
+// expandTemplate substitutes placeholders token by token. Each template token
+// yields exactly one argument, so values containing spaces or quotes can
+// never introduce extra arguments.
+func expandTemplate(tokens []string, values []string) ([]string, error) {
+	arity := 0
+	for _, token := range tokens {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(token, -1) {
+			if n, _ := strconv.Atoi(match[1]); n > arity {
+				arity = n
+			}
+		}
+	}
+	if len(values) != arity {
+		return nil, fmt.Errorf("expected %d arguments, got %d", arity, len(values))
+	}
+
+	expanded := make([]string, len(tokens))
+	for i, token := range tokens {
+		expanded[i] = placeholderPattern.ReplaceAllStringFunc(token, func(placeholder string) string {
+			n, _ := strconv.Atoi(placeholder[1:])
+			return values[n-1]
+		})
+	}
+	return expanded, nil
+}
+
+// splitTemplate tokenizes a command string on whitespace. Double-quoted
+// tokens support backslash escapes; single-quoted tokens are taken literally.
+func splitTemplate(template string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inToken := false
+
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		case c == '"':
+			inToken = true
+			closed := false
+			for i++; i < len(template); i++ {
+				if template[i] == '\\' && i+1 < len(template) {
+					i++
+					current.WriteByte(template[i])
+				} else if template[i] == '"' {
+					closed = true
+					break
+				} else {
+					current.WriteByte(template[i])
+				}
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+		case c == '\'':
+			inToken = true
+			end := strings.IndexByte(template[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			current.WriteString(template[i+1 : i+1+end])
+			i += end + 1
+		default:
+			inToken = true
+			current.WriteByte(c)
+		}
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// CodedErrors carry their own code, such as BADARG or CONFLICT, in the
+// error reply of a command failing with them, in place of ERR
+var CodedErrors = []error{models.ErrBadArgument, ErrCursorStale, ErrTransferBusy, ErrGraphTooLarge, ErrIDExists, ErrGraphProtected, storage.ErrGenerationConflict}
+
+// wrapError returns err unchanged if it is one of CodedErrors, so its code
+// is still sent, and otherwise wraps it as the failure to do action
+func wrapError(action string, err error) error {
+	for _, coded := range CodedErrors {
+		if errors.Is(err, coded) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to %s: %w", action, err)
+}
+
+// HandlerFunc runs one command on behalf of a connection. The session is nil
+// when a family handler is called directly rather than for a connection.
+type HandlerFunc func(session *Session, args []string) (*protocol.Response, error)
+
+// CommandSpec documents a command and routes it to its handler, so HELP
+// describes exactly the commands that can be run
+type CommandSpec struct {
+	// Name is the full command name, such as NODE.CREATE
+	Name string
+	// Args is the argument signature, as shown after the name
+	Args string
+	// Keywords lists the option keywords the command accepts
+	Keywords []string
+	// Defaults lists the values options take when they are omitted, such as
+	// "DIRECTION out"
+	Defaults []string
+	// Summary is a one-line description
+	Summary string
+	// Example is a complete invocation
+	Example string
+	// ReadOnly marks commands that never modify the database
+	ReadOnly bool
+	// ReadOnlyWhen decides from the arguments whether a command that both
+	// reads and writes, such as GRAPH.SNAPSHOT, leaves the database unchanged.
+	// It is only consulted when ReadOnly is false.
+	ReadOnlyWhen func(args []string) bool
+	Handler      HandlerFunc
+}
+
+// Family returns the namespace of the command, or "" for commands such as
+// PING that have none
+func (c *CommandSpec) Family() string {
+	family, _, dotted := strings.Cut(c.Name, ".")
+	if !dotted {
+		return ""
+	}
+	return family
+}
+
+// Usage returns the name followed by the argument signature
+func (c *CommandSpec) Usage() string {
+	if c.Args == "" {
+		return c.Name
+	}
+	return c.Name + " " + c.Args
+}
+
+// IsReadOnly reports whether running the command with args leaves the
+// database unchanged
+func (c *CommandSpec) IsReadOnly(args []string) bool {
+	if c.ReadOnly {
+		return true
+	}
+	return c.ReadOnlyWhen != nil && c.ReadOnlyWhen(args)
+}
+
+// readOnlySubcommands returns a ReadOnlyWhen for commands whose first
+// argument is a subcommand, treating the named subcommands as read-only
+func readOnlySubcommands(subcommands ...string) func(args []string) bool {
+	return func(args []string) bool {
+		if len(args) == 0 {
+			return false
+		}
+		for _, subcommand := range subcommands {
+			if strings.EqualFold(args[0], subcommand) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Registry holds the commands a handler routes
+type Registry struct {
+	specs map[string]*CommandSpec
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{specs: make(map[string]*CommandSpec)}
+}
+
+// Register adds a command. Registering a name twice or without a handler is
+// a programming error and panics.
+func (r *Registry) Register(spec CommandSpec) {
+	if spec.Handler == nil {
+		panic(fmt.Sprintf("command %s has no handler", spec.Name))
+	}
+	if _, exists := r.specs[spec.Name]; exists {
+		panic(fmt.Sprintf("command %s registered twice", spec.Name))
+	}
+	r.specs[spec.Name] = &spec
+}
+
+// Lookup returns the command registered under a normalized name
+func (r *Registry) Lookup(name string) (*CommandSpec, bool) {
+	spec, ok := r.specs[name]
+	return spec, ok
+}
+
+// IsReadOnly reports whether the command registered under a normalized name
+// leaves the database unchanged when run with args. Unknown commands are
+// not read-only.
+func (r *Registry) IsReadOnly(name string, args []string) bool {
+	spec, ok := r.specs[name]
+	return ok && spec.IsReadOnly(args)
+}
+
+// Commands returns every registered command ordered by name
+func (r *Registry) Commands() []*CommandSpec {
+	specs := make([]*CommandSpec, 0, len(r.specs))
+	for _, spec := range r.specs {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// Families returns the namespaces that have at least one command, in order
+func (r *Registry) Families() []string {
+	seen := make(map[string]bool)
+	var families []string
+	for _, spec := range r.Commands() {
+		if family := spec.Family(); family != "" && !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+	return families
+}
+
+// Family returns the commands of one namespace ordered by name
+func (r *Registry) Family(family string) []*CommandSpec {
+	var specs []*CommandSpec
+	for _, spec := range r.Commands() {
+		if spec.Family() == family {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// Dispatch runs the command registered under a normalized name. Unknown
+// commands fail with the closest registered name as a suggestion.
+func (r *Registry) Dispatch(session *Session, name string, args []string) (*protocol.Response, error) {
+	if spec, ok := r.specs[name]; ok {
+		return spec.Handler(session, args)
+	}
+
+	family, command, dotted := strings.Cut(name, ".")
+	known := len(r.Family(family)) > 0
+	var message string
+	switch {
+	case known && !dotted:
+		return nil, fmt.Errorf("incomplete %s command", family)
+	case known:
+		message = fmt.Sprintf("unknown %s command: %s", family, command)
+	default:
+		message = fmt.Sprintf("unknown command: %s", name)
+	}
+	if suggestion := r.Suggest(name); suggestion != "" {
+		message += fmt.Sprintf(" (did you mean %s?)", suggestion)
+	}
+	return nil, fmt.Errorf("%s", message)
+}
+
+// Suggest returns the registered name closest to name by edit distance, or
+// "" if none is close enough to be a likely typo. A name is close enough
+// when at most a third of it, and no more than 3 characters, must change.
+func (r *Registry) Suggest(name string) string {
+	limit := len(name) / 3
+	if limit > 3 {
+		limit = 3
+	}
+	best, bestDistance := "", limit+1
+	for _, spec := range r.Commands() {
+		if distance := editDistance(name, spec.Name); distance < bestDistance {
+			best, bestDistance = spec.Name, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// sessionless adapts a handler that does not depend on the connection
+func sessionless(handler func(args []string) (*protocol.Response, error)) HandlerFunc {
+	return func(session *Session, args []string) (*protocol.Response, error) {
+		return handler(args)
+	}
+}
+
+// route runs a command of one family through a registry holding only the
+// commands register adds, for callers that hold the family handler alone
+func route(register func(r *Registry), session *Session, name string, args []string) (*protocol.Response, error) {
+	r := NewRegistry()
+	register(r)
+	return r.Dispatch(session, name, args)
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// handleSCC handles ANALYSIS.SCC <graph> [EDGETYPES type1...]. It replies
+// with an array of the components holding a cycle, each an array of their
+// node IDs, largest first.
+func (a *AnalysisCommands) handleSCC(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("ANALYSIS.SCC requires at least 1 argument: graph")
+	}
+
+	graphID := models.GraphID(args[0])
+	options := &types.TraversalOptions{}
+	if len(args) > 1 {
+		if strings.ToUpper(args[1]) != "EDGETYPES" {
+			return nil, fmt.Errorf("unknown option for ANALYSIS.SCC: %s", args[1])
+		}
+		for _, edgeType := range args[2:] {
+			options.EdgeTypes = append(options.EdgeTypes, models.EdgeType(edgeType))
+		}
+	}
+
+	if _, err := a.storage.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+	components, err := a.analyzer.FindStronglyConnectedComponents(graphID, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find strongly connected components: %w", err)
+	}
+
+	response := make([]interface{}, len(components))
+	for i, component := range components {
+		members := make([]string, len(component))
+		for j, nodeID := range component {
+			members[j] = string(nodeID)
+		}
+		response[i] = members
+	}
+	return protocol.NewNestedArrayResponse(response), nil
+}
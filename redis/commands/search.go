@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/search"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// SearchCommands handles search-related Redis commands
+type SearchCommands struct {
+	storage storage.StorageEngine
+}
+
+// NewSearchCommands creates a new search commands handler
+func NewSearchCommands(storageEngine storage.StorageEngine) *SearchCommands {
+	return &SearchCommands{
+		storage: storageEngine,
+	}
+}
+
+// Handle routes search commands to their respective handlers
+func (s *SearchCommands) Handle(command string, args []string) (*protocol.Response, error) {
+	return route(s.Register, nil, "SEARCH."+command, args)
+}
+
+// Register adds the search commands to a registry
+func (s *SearchCommands) Register(r *Registry) {
+	r.Register(CommandSpec{
+		Name:     "SEARCH.TEXT",
+		Args:     "<graph> <substring> [LIMIT n] [NODETYPES type1...] [EDGES]",
+		Keywords: []string{"LIMIT", "NODETYPES", "EDGES"},
+		Summary:  "Finds the nodes, and optionally edges, whose attribute values contain a substring",
+		Example:  "SEARCH.TEXT my-graph payments-v2 LIMIT 10",
+		ReadOnly: true,
+		Handler:  sessionless(s.handleText),
+	})
+}
+
+// textKeywords ends the NODETYPES list of SEARCH.TEXT
+var textKeywords = map[string]bool{
+	"LIMIT":     true,
+	"NODETYPES": true,
+	"EDGES":     true,
+}
+
+// handleText handles SEARCH.TEXT <graph> <substring> [LIMIT n] [NODETYPES type1...] [EDGES]
+func (s *SearchCommands) handleText(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("SEARCH.TEXT requires at least 2 arguments: graph, substring")
+	}
+	if args[1] == "" {
+		return nil, fmt.Errorf("search text cannot be empty")
+	}
+
+	graphID := models.GraphID(args[0])
+	matcher := search.NewMatcher(args[1])
+	limit := 0
+	withEdges := false
+	nodeTypes := make(map[models.NodeType]bool)
+
+	i := 2
+	for i < len(args) {
+		switch strings.ToUpper(args[i]) {
+		case "LIMIT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("LIMIT option requires an argument")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid LIMIT: %s", args[i+1])
+			}
+			limit = n
+			i += 2
+		case "NODETYPES":
+			i++
+			for i < len(args) && !textKeywords[strings.ToUpper(args[i])] {
+				nodeTypes[models.NodeType(args[i])] = true
+				i++
+			}
+		case "EDGES":
+			withEdges = true
+			i++
+		default:
+			return nil, fmt.Errorf("unknown option for SEARCH.TEXT: %s", args[i])
+		}
+	}
+
+	if _, err := s.storage.GetGraph(graphID); err != nil {
+		return nil, fmt.Errorf("failed to search graph: %w", err)
+	}
+
+	var results []string
+	full := func() bool {
+		return limit > 0 && len(results) >= limit
+	}
+
+	err := s.storage.ScanNodes(graphID, func(node *models.Node) error {
+		if len(nodeTypes) > 0 && !nodeTypes[node.Type] {
+			return nil
+		}
+		if key, ok := matcher.Match(node.Attributes); ok {
+			results = append(results, fmt.Sprintf("%s:%s:%s", node.ID, node.Type, key))
+			if full() {
+				return storage.ErrStopScan
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search nodes: %w", err)
+	}
+
+	if withEdges && !full() {
+		err = s.storage.ScanEdges(graphID, func(edge *models.Edge) error {
+			if key, ok := matcher.Match(edge.Attributes); ok {
+				results = append(results, fmt.Sprintf("%s:%s:%s", edge.ID, edge.Type, key))
+				if full() {
+					return storage.ErrStopScan
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search edges: %w", err)
+		}
+	}
+
+	return protocol.NewArrayResponse(results), nil
+}
@@ -0,0 +1,44 @@
+package commands
+
+import "context"
+
+// Session holds per-connection state for commands that depend on who is
+// calling rather than only on their arguments
+type Session struct {
+	// Client is the remote address of the connection
+	Client string
+
+	// Admin is set once the connection authenticates with AUTH
+	Admin bool
+
+	// Events tracks the graphs GRAPH.SUBSCRIBE subscribed the connection
+	// to. It is nil for commands run without a connection.
+	Events EventSubscriber
+
+	// ctx carries the span of the command the connection is running. A
+	// connection runs one command at a time, so it is set per command.
+	ctx context.Context
+}
+
+// SetContext sets the context of the command the session runs next
+func (s *Session) SetContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
+// Context returns the context of the command the session is running, or the
+// background context outside one
+func (s *Session) Context() context.Context {
+	if s == nil || s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// client returns the remote address of the connection, or "" for commands
+// run without one
+func (s *Session) client() string {
+	if s == nil {
+		return ""
+	}
+	return s.Client
+}
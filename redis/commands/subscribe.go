@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+)
+
+// EventSubscriber tracks the graphs a connection receives node and edge
+// events for. The server sets one on the session of every connection.
+type EventSubscriber interface {
+	// Subscribe adds a graph and returns how many the connection is
+	// subscribed to
+	Subscribe(graphID models.GraphID) int
+	// Unsubscribe removes a graph and returns how many are left
+	Unsubscribe(graphID models.GraphID) int
+	// Subscriptions returns the graphs subscribed to, sorted
+	Subscriptions() []models.GraphID
+}
+
+// handleSubscribe handles GRAPH.SUBSCRIBE <name> [name ...]. It replies with
+// a subscribe confirmation per graph, as Redis does for SUBSCRIBE.
+func (g *GraphCommands) handleSubscribe(session *Session, args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("GRAPH.SUBSCRIBE requires at least 1 argument: name")
+	}
+	if session == nil || session.Events == nil {
+		return nil, fmt.Errorf("GRAPH.SUBSCRIBE needs a connection to the server")
+	}
+	for _, name := range args {
+		if _, err := g.storage.GetGraph(models.GraphID(name)); err != nil {
+			return nil, fmt.Errorf("failed to get graph: %w", err)
+		}
+	}
+
+	replies := make([]interface{}, 0, len(args))
+	for _, name := range args {
+		count := session.Events.Subscribe(models.GraphID(name))
+		replies = append(replies, []string{"subscribe", name, strconv.Itoa(count)})
+	}
+	return protocol.NewNestedArrayResponse(replies), nil
+}
+
+// handleUnsubscribe handles GRAPH.UNSUBSCRIBE [name ...]. Without names it
+// unsubscribes from every graph.
+func (g *GraphCommands) handleUnsubscribe(session *Session, args []string) (*protocol.Response, error) {
+	if session == nil || session.Events == nil {
+		return nil, fmt.Errorf("GRAPH.UNSUBSCRIBE needs a connection to the server")
+	}
+	graphIDs := make([]models.GraphID, len(args))
+	for i, name := range args {
+		graphIDs[i] = models.GraphID(name)
+	}
+	if len(graphIDs) == 0 {
+		graphIDs = session.Events.Subscriptions()
+	}
+
+	replies := make([]interface{}, 0, len(graphIDs))
+	for _, graphID := range graphIDs {
+		count := session.Events.Unsubscribe(graphID)
+		replies = append(replies, []string{"unsubscribe", string(graphID), strconv.Itoa(count)})
+	}
+	return protocol.NewNestedArrayResponse(replies), nil
+}
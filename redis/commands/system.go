@@ -0,0 +1,513 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/version"
+)
+
+// ProtocolVersion is the reply convention the server speaks, as reported by
+// SYSTEM.PROTOVERSION. Version 2 dropped the leading count of multi-item
+// replies; the COUNT flag asks for it.
+const ProtocolVersion = 2
+
+// SystemCommands handles server administration Redis commands
+type SystemCommands struct {
+	storage storage.StorageEngine
+	// adminRestore limits SYSTEM.RESTORE to connections with the admin role
+	adminRestore bool
+}
+
+// NewSystemCommands creates a new system commands handler
+func NewSystemCommands(storageEngine storage.StorageEngine) *SystemCommands {
+	return &SystemCommands{
+		storage: storageEngine,
+	}
+}
+
+// SetAdminRequired makes SYSTEM.RESTORE, which loads a server-side file over
+// the live database, require the admin role. The server sets it when an
+// admin password is configured.
+func (s *SystemCommands) SetAdminRequired(required bool) {
+	s.adminRestore = required
+}
+
+// Handle routes system commands to their respective handlers
+func (s *SystemCommands) Handle(command string, args []string) (*protocol.Response, error) {
+	return route(s.Register, nil, "SYSTEM."+command, args)
+}
+
+// Register adds the system commands to a registry
+func (s *SystemCommands) Register(r *Registry) {
+	r.Register(CommandSpec{
+		Name:         "SYSTEM.BACKUP",
+		Args:         "INFO <path>",
+		Keywords:     []string{"INFO"},
+		Summary:      "Prints the manifest of a backup file without restoring it",
+		Example:      "SYSTEM.BACKUP INFO /backups/pathwaydb",
+		ReadOnlyWhen: readOnlySubcommands("INFO"),
+		Handler:      sessionless(s.handleBackup),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.CAPABILITIES",
+		Summary:  "Lists every command the server supports with its keywords",
+		Example:  "SYSTEM.CAPABILITIES",
+		ReadOnly: true,
+		Handler: sessionless(func(args []string) (*protocol.Response, error) {
+			return handleCapabilities(r, args)
+		}),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.CACHE",
+		Args:     "STATS | CLEAR",
+		Keywords: []string{"STATS", "CLEAR"},
+		Summary:  "Reports or clears the node and edge record cache",
+		Example:  "SYSTEM.CACHE STATS",
+		Handler:  sessionless(s.handleCache),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.HOTNODES",
+		Args:     "RESET",
+		Keywords: []string{"RESET"},
+		Summary:  "Clears the read counts of every graph",
+		Example:  "SYSTEM.HOTNODES RESET",
+		Handler:  sessionless(s.handleHotNodes),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.KEYAUDIT",
+		Args:     "[PREFIX <p>] [FORMAT fields|json]",
+		Keywords: []string{"PREFIX", "FORMAT"},
+		Summary:  "Reports the keyspace by key family and graph",
+		Example:  "SYSTEM.KEYAUDIT PREFIX n: FORMAT json",
+		ReadOnly: true,
+		Handler:  sessionless(s.handleKeyAudit),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.METRICS",
+		Args:     "TEXT",
+		Keywords: []string{"TEXT"},
+		Summary:  "Reports per-graph gauges in the Prometheus text exposition format",
+		Example:  "SYSTEM.METRICS TEXT",
+		ReadOnly: true,
+		Handler:  sessionless(s.handleMetrics),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.PROTOVERSION",
+		Summary:  "Reports the reply convention the server speaks",
+		Example:  "SYSTEM.PROTOVERSION",
+		ReadOnly: true,
+		Handler:  sessionless(s.handleProtoVersion),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.REINDEX",
+		Args:     "<index> <graph> START [RATE <keys_per_sec>] | STATUS | PAUSE | RESUME | CANCEL",
+		Keywords: []string{"START", "RATE", "STATUS", "PAUSE", "RESUME", "CANCEL"},
+		Summary:  "Backfills an index over a graph's existing entities in the background",
+		Example:  "SYSTEM.REINDEX attributes my-graph START RATE 5000",
+		Handler:  sessionless(s.handleReindex),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.RESTORE",
+		Args:     "<path> [FORCE]",
+		Keywords: []string{"FORCE"},
+		Summary:  "Loads a backup file into the database",
+		Example:  "SYSTEM.RESTORE /backups/pathwaydb",
+		Handler:  s.handleRestore,
+	})
+	r.Register(CommandSpec{
+		Name:    "SYSTEM.VALIDATEATTRS",
+		Args:    "<graph>",
+		Summary: "Reports the stored attribute keys of a graph that writes would reject",
+		Example: "SYSTEM.VALIDATEATTRS my-graph",
+		Handler: sessionless(s.handleValidateAttrs),
+	})
+	r.Register(CommandSpec{
+		Name:     "SYSTEM.VERSION",
+		Summary:  "Reports the version and git commit the server was built from",
+		Example:  "SYSTEM.VERSION",
+		ReadOnly: true,
+		Handler:  sessionless(s.handleVersion),
+	})
+}
+
+// handleBackup handles SYSTEM.BACKUP INFO <path>
+func (s *SystemCommands) handleBackup(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("SYSTEM.BACKUP requires a subcommand: INFO")
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "INFO":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("SYSTEM.BACKUP INFO requires exactly 1 argument: path")
+		}
+		manifest, err := storage.ReadBackupManifest(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup: %w", err)
+		}
+		data, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize manifest: %w", err)
+		}
+		return protocol.NewBulkResponse(string(data)), nil
+	default:
+		return nil, fmt.Errorf("unknown SYSTEM.BACKUP subcommand: %s", args[0])
+	}
+}
+
+// handleRestore handles SYSTEM.RESTORE <path> [FORCE]. A database holding
+// any graph is refused unless FORCE is given, as the backup's keys overwrite
+// the database's and the keys it does not hold are kept. With an admin
+// password configured it requires the admin role.
+func (s *SystemCommands) handleRestore(session *Session, args []string) (*protocol.Response, error) {
+	if s.adminRestore && (session == nil || !session.Admin) {
+		return nil, fmt.Errorf("SYSTEM.RESTORE requires the admin role: AUTH with the admin password first")
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("SYSTEM.RESTORE requires 1 argument: path, and accepts only FORCE")
+	}
+	force := false
+	if len(args) == 2 {
+		if strings.ToUpper(args[1]) != "FORCE" {
+			return nil, fmt.Errorf("unknown option for SYSTEM.RESTORE: %s", args[1])
+		}
+		force = true
+	}
+
+	if !force {
+		graphs, err := s.storage.ListGraphs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list graphs: %w", err)
+		}
+		if len(graphs) > 0 {
+			return nil, fmt.Errorf("database is not empty: it holds %d graphs (pass FORCE to restore over them)", len(graphs))
+		}
+	}
+	if err := s.storage.Restore(args[0]); err != nil {
+		return nil, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	return protocol.OK(), nil
+}
+
+// handleProtoVersion handles SYSTEM.PROTOVERSION, replying with field and
+// value pairs: the protocol version, whether multi-item replies carry a
+// leading count, and the breaking changes of the version
+func (s *SystemCommands) handleProtoVersion(args []string) (*protocol.Response, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("SYSTEM.PROTOVERSION takes no arguments")
+	}
+	return protocol.NewArrayResponse([]string{
+		"version", strconv.Itoa(ProtocolVersion),
+		"leading_counts", "never",
+		"changes", "EDGE.NEIGHBORS, ANALYSIS.TRAVERSE, ANALYSIS.SHORTESTPATH and ANALYSIS.CYCLES no longer prefix their replies with a count; pass COUNT to get one",
+	}), nil
+}
+
+// handleVersion handles SYSTEM.VERSION, replying with field and value
+// pairs: the server version and commit set at build time, the protocol
+// version and the Go version the server was built with
+func (s *SystemCommands) handleVersion(args []string) (*protocol.Response, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("SYSTEM.VERSION takes no arguments")
+	}
+	return protocol.NewArrayResponse([]string{
+		"version", version.Version,
+		"commit", version.Commit,
+		"protocol", strconv.Itoa(ProtocolVersion),
+		"go", runtime.Version(),
+	}), nil
+}
+
+// handleCapabilities handles SYSTEM.CAPABILITIES, replying with one array
+// per command of the registry, in name order: the command name followed by
+// its keywords. The list is read from the registry when the command runs,
+// so it is exactly the set of commands the server dispatches.
+func handleCapabilities(r *Registry, args []string) (*protocol.Response, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("SYSTEM.CAPABILITIES takes no arguments")
+	}
+	rows := []interface{}{}
+	for _, spec := range r.Commands() {
+		rows = append(rows, append([]string{spec.Name}, spec.Keywords...))
+	}
+	return protocol.NewNestedArrayResponse(rows), nil
+}
+
+// handleCache handles SYSTEM.CACHE STATS | CLEAR. STATS replies with field
+// and value pairs; a disabled cache reports enabled 0 and zero counts.
+func (s *SystemCommands) handleCache(args []string) (*protocol.Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("SYSTEM.CACHE requires a subcommand: STATS or CLEAR")
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "STATS":
+		stats := s.storage.CacheStats()
+		enabled := "0"
+		if stats.Enabled {
+			enabled = "1"
+		}
+		return protocol.NewArrayResponse([]string{
+			"enabled", enabled,
+			"entries", strconv.Itoa(stats.Entries),
+			"bytes", strconv.FormatInt(stats.Bytes, 10),
+			"max_entries", strconv.Itoa(stats.MaxEntries),
+			"max_bytes", strconv.FormatInt(stats.MaxBytes, 10),
+			"hits", strconv.FormatUint(stats.Hits, 10),
+			"misses", strconv.FormatUint(stats.Misses, 10),
+			"evictions", strconv.FormatUint(stats.Evictions, 10),
+			"invalidations", strconv.FormatUint(stats.Invalidations, 10),
+		}), nil
+	case "CLEAR":
+		s.storage.ClearCache()
+		return protocol.OK(), nil
+	default:
+		return nil, fmt.Errorf("unknown SYSTEM.CACHE subcommand: %s", args[0])
+	}
+}
+
+// graphGauges are the per-graph gauges of SYSTEM.METRICS, in the order they
+// are written. Their names and the graph label are stable, as scrapers and
+// dashboards depend on them.
+var graphGauges = []struct {
+	name  string
+	help  string
+	count func(storage.StorageEngine, models.GraphID) (int, error)
+}{
+	{"pathwaydb_graph_nodes", "Number of nodes in the graph.", storage.StorageEngine.CountNodes},
+	{"pathwaydb_graph_edges", "Number of edges in the graph.", storage.StorageEngine.CountEdges},
+	{"pathwaydb_graph_orphan_nodes", "Number of nodes in the graph with no edges.", storage.StorageEngine.CountOrphanNodes},
+}
+
+// metricLabelEscaper escapes a label value of the text exposition format
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// handleMetrics handles SYSTEM.METRICS TEXT, replying with one bulk string
+// in the Prometheus text exposition format, for scrapers that cannot reach
+// an HTTP endpoint. The gauges are counted from keys, without reading node
+// or edge records.
+func (s *SystemCommands) handleMetrics(args []string) (*protocol.Response, error) {
+	if len(args) != 1 || strings.ToUpper(args[0]) != "TEXT" {
+		return nil, fmt.Errorf("SYSTEM.METRICS requires a format: TEXT")
+	}
+
+	graphs, err := s.storage.ListGraphs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list graphs: %w", err)
+	}
+	sort.Slice(graphs, func(i, j int) bool { return graphs[i].ID < graphs[j].ID })
+
+	var b strings.Builder
+	b.WriteString("# HELP pathwaydb_graphs Number of graphs.\n")
+	b.WriteString("# TYPE pathwaydb_graphs gauge\n")
+	fmt.Fprintf(&b, "pathwaydb_graphs %d\n", len(graphs))
+	for _, gauge := range graphGauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n", gauge.name, gauge.help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", gauge.name)
+		for _, graph := range graphs {
+			count, err := gauge.count(s.storage, graph.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s of graph %s: %w", gauge.name, graph.ID, err)
+			}
+			fmt.Fprintf(&b, "%s{graph=\"%s\"} %d\n", gauge.name, metricLabelEscaper.Replace(string(graph.ID)), count)
+		}
+	}
+	return protocol.NewBulkResponse(b.String()), nil
+}
+
+// handleHotNodes handles SYSTEM.HOTNODES RESET
+func (s *SystemCommands) handleHotNodes(args []string) (*protocol.Response, error) {
+	if len(args) != 1 || strings.ToUpper(args[0]) != "RESET" {
+		return nil, fmt.Errorf("SYSTEM.HOTNODES requires a subcommand: RESET")
+	}
+	if err := s.storage.ResetReads(); err != nil {
+		return nil, fmt.Errorf("failed to reset read counts: %w", err)
+	}
+	return protocol.OK(), nil
+}
+
+// handleKeyAudit handles SYSTEM.KEYAUDIT [PREFIX <p>] [FORMAT fields|json].
+// The fields format replies with field and value pairs: totals first, then
+// keys per family, per unknown prefix, per orphaned family and per graph
+// and family, then each index mismatch as entity and index entry counts.
+func (s *SystemCommands) handleKeyAudit(args []string) (*protocol.Response, error) {
+	prefix := ""
+	format := "fields"
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("%s option requires an argument", strings.ToUpper(args[i]))
+		}
+		switch strings.ToUpper(args[i]) {
+		case "PREFIX":
+			prefix = args[i+1]
+		case "FORMAT":
+			format = strings.ToLower(args[i+1])
+			if format != "fields" && format != "json" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'fields' or 'json')", args[i+1])
+			}
+		default:
+			return nil, fmt.Errorf("unknown option for SYSTEM.KEYAUDIT: %s", args[i])
+		}
+	}
+
+	audit, err := s.storage.AuditKeys(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to audit keys: %w", err)
+	}
+
+	if format == "json" {
+		data, err := json.Marshal(audit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize key audit: %w", err)
+		}
+		return protocol.NewBulkResponse(string(data)), nil
+	}
+
+	result := []string{
+		"keys", strconv.FormatInt(audit.Keys, 10),
+		"unknown", strconv.FormatInt(audit.Unknown, 10),
+		"orphaned", strconv.FormatInt(audit.Orphaned, 10),
+		"mismatches", strconv.Itoa(len(audit.Mismatches)),
+	}
+	appendCounts := func(field string, counts map[string]int64) {
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			result = append(result, field+name, strconv.FormatInt(counts[name], 10))
+		}
+	}
+	appendCounts("family:", audit.Families)
+	appendCounts("unknown:", audit.UnknownPrefixes)
+	appendCounts("orphaned:", audit.Orphans)
+
+	graphIDs := make([]string, 0, len(audit.Graphs))
+	for graphID := range audit.Graphs {
+		graphIDs = append(graphIDs, string(graphID))
+	}
+	sort.Strings(graphIDs)
+	for _, graphID := range graphIDs {
+		appendCounts("graph:"+graphID+":", audit.Graphs[models.GraphID(graphID)])
+	}
+
+	for _, mismatch := range audit.Mismatches {
+		result = append(result,
+			fmt.Sprintf("mismatch:%s:%s:%s", mismatch.Graph, mismatch.Family, mismatch.Index),
+			fmt.Sprintf("%d/%d", mismatch.Entities, mismatch.Entries))
+	}
+	return protocol.NewArrayResponse(result), nil
+}
+
+// handleReindex handles SYSTEM.REINDEX <index> <graph> START [RATE
+// <keys_per_sec>] | STATUS | PAUSE | RESUME | CANCEL. STATUS replies with
+// field and value pairs, or null if the index was never backfilled.
+func (s *SystemCommands) handleReindex(args []string) (*protocol.Response, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("SYSTEM.REINDEX requires: index, graph, START|STATUS|PAUSE|RESUME|CANCEL")
+	}
+	index, graphID := strings.ToLower(args[0]), models.GraphID(args[1])
+	subcommand := strings.ToUpper(args[2])
+	if subcommand != "START" && len(args) != 3 {
+		return nil, fmt.Errorf("SYSTEM.REINDEX %s takes no options", subcommand)
+	}
+
+	var err error
+	switch subcommand {
+	case "START":
+		rate := storage.DefaultReindexRate
+		if len(args) > 3 {
+			if len(args) != 5 || strings.ToUpper(args[3]) != "RATE" {
+				return nil, fmt.Errorf("SYSTEM.REINDEX START accepts only: RATE <keys_per_sec>")
+			}
+			rate, err = strconv.Atoi(args[4])
+			if err != nil || rate <= 0 {
+				return nil, fmt.Errorf("invalid RATE: %s (must be a positive integer)", args[4])
+			}
+		}
+		_, err = s.storage.StartReindex(graphID, index, rate)
+	case "STATUS":
+		job, err := s.storage.ReindexStatus(graphID, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reindex status: %w", err)
+		}
+		if job == nil {
+			return protocol.NewNullResponse(), nil
+		}
+		return reindexJobResponse(job), nil
+	case "PAUSE":
+		_, err = s.storage.PauseReindex(graphID, index)
+	case "RESUME":
+		_, err = s.storage.ResumeReindex(graphID, index)
+	case "CANCEL":
+		_, err = s.storage.CancelReindex(graphID, index)
+	default:
+		return nil, fmt.Errorf("unknown SYSTEM.REINDEX subcommand: %s", args[2])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s reindex: %w", strings.ToLower(subcommand), err)
+	}
+	return protocol.OK(), nil
+}
+
+// reindexJobResponse formats a reindex job as field and value pairs
+func reindexJobResponse(job *models.ReindexJob) *protocol.Response {
+	result := []string{
+		"index", job.Index,
+		"graph", string(job.Graph),
+		"state", string(job.State),
+		"progress", strconv.FormatFloat(job.Progress(), 'f', 1, 64),
+		"scanned", strconv.FormatInt(job.Scanned, 10),
+		"total", strconv.FormatInt(job.Total, 10),
+		"written", strconv.FormatInt(job.Written, 10),
+		"rate", strconv.Itoa(job.Rate),
+		"eta_seconds", strconv.FormatInt(int64(job.ETA(time.Now()).Round(time.Second)/time.Second), 10),
+		"started_at", job.StartedAt.UTC().Format(time.RFC3339),
+		"updated_at", job.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if job.FinishedAt != nil {
+		result = append(result, "finished_at", job.FinishedAt.UTC().Format(time.RFC3339))
+	}
+	if job.Error != "" {
+		result = append(result, "error", job.Error)
+	}
+	return protocol.NewArrayResponse(result)
+}
+
+// handleValidateAttrs handles SYSTEM.VALIDATEATTRS <graph>. It replies with
+// field and value pairs: the graph, the nodes and edges scanned and the
+// number of violations, then one entity:id field per violation whose value
+// is the quoted key and what is wrong with it.
+func (s *SystemCommands) handleValidateAttrs(args []string) (*protocol.Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("SYSTEM.VALIDATEATTRS requires exactly 1 argument: graph")
+	}
+
+	report, err := s.storage.ValidateAttributeKeys(models.GraphID(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate attribute keys: %w", err)
+	}
+
+	result := []string{
+		"graph", string(report.Graph),
+		"nodes", strconv.Itoa(report.Nodes),
+		"edges", strconv.Itoa(report.Edges),
+		"violations", strconv.Itoa(len(report.Violations)),
+	}
+	for _, violation := range report.Violations {
+		result = append(result,
+			violation.Entity+":"+violation.ID,
+			fmt.Sprintf("%q %s", violation.Key, violation.Problem))
+	}
+	return protocol.NewArrayResponse(result), nil
+}
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// handleTopoSort handles ANALYSIS.TOPOSORT <graph> [EDGETYPES type1...] [FORMAT simple|detailed].
+// The simple format lists the nodes in order as id:type, and the detailed
+// one as id:type:level.
+func (a *AnalysisCommands) handleTopoSort(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("ANALYSIS.TOPOSORT requires at least 1 argument: graph")
+	}
+
+	graphID := models.GraphID(args[0])
+	format := "detailed"
+	options := &types.TraversalOptions{}
+
+	i := 1
+	for i < len(args) {
+		switch strings.ToUpper(args[i]) {
+		case "EDGETYPES":
+			i++
+			for i < len(args) && strings.ToUpper(args[i]) != "FORMAT" {
+				options.EdgeTypes = append(options.EdgeTypes, models.EdgeType(args[i]))
+				i++
+			}
+		case "FORMAT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("FORMAT option requires an argument")
+			}
+			format = strings.ToLower(args[i+1])
+			if format != "simple" && format != "detailed" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'simple' or 'detailed')", args[i+1])
+			}
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown option for ANALYSIS.TOPOSORT: %s", args[i])
+		}
+	}
+
+	if _, err := a.storage.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+	order, err := a.analyzer.TopologicalSort(graphID, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sort graph: %w", err)
+	}
+
+	result := make([]string, 0, len(order))
+	for _, entry := range order {
+		if format == "simple" {
+			result = append(result, fmt.Sprintf("%s:%s", entry.Node.ID, entry.Node.Type))
+		} else {
+			result = append(result, fmt.Sprintf("%s:%s:%d", entry.Node.ID, entry.Node.Type, entry.Level))
+		}
+	}
+	return protocol.NewArrayResponse(result), nil
+}
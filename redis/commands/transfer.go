@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTransferTimeout is how long a chunked export or import session may
+// sit idle before it is discarded
+const DefaultTransferTimeout = 5 * time.Minute
+
+const (
+	// maxSessionTransfers is the number of chunked transfers a connection
+	// may have open at once
+	maxSessionTransfers = 4
+	// maxTransfers is the number of chunked transfers open at once across
+	// all connections
+	maxTransfers = 64
+)
+
+// ErrTransferBusy is returned when starting a chunked transfer would
+// exceed the limits on open transfers. Errors wrapping it start with
+// "BUSY" and are sent to clients without the generic ERR prefix.
+var ErrTransferBusy = errors.New("BUSY")
+
+// transfer is a chunked export or import in progress
+type transfer interface {
+	// close releases the transfer's resources. It may be called while
+	// another command is using the transfer, which then fails.
+	close()
+}
+
+// transferEntry is a registered transfer with its owner and idle timer
+type transferEntry struct {
+	transfer transfer
+	owner    *Session
+	timer    *time.Timer
+}
+
+// transferRegistry tracks the chunked transfers of all connections. A
+// transfer ends when it finishes, is aborted, sits idle for longer than the
+// timeout or its connection closes.
+type transferRegistry struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	entries map[string]*transferEntry
+}
+
+// newTransferRegistry creates an empty registry
+func newTransferRegistry(timeout time.Duration) *transferRegistry {
+	return &transferRegistry{
+		timeout: timeout,
+		entries: make(map[string]*transferEntry),
+	}
+}
+
+// setTimeout changes the idle timeout of transfers started afterwards
+func (r *transferRegistry) setTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = timeout
+}
+
+// add registers t for owner and returns its session ID. It fails with
+// ErrTransferBusy when owner or the server already has the most transfers
+// open that it may.
+func (r *transferRegistry) add(owner *Session, t transfer) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) >= maxTransfers {
+		return "", fmt.Errorf("%w too many transfer sessions open on the server, try later", ErrTransferBusy)
+	}
+	owned := 0
+	for _, entry := range r.entries {
+		if entry.owner == owner {
+			owned++
+		}
+	}
+	if owned >= maxSessionTransfers {
+		return "", fmt.Errorf("%w this connection already has %d transfer sessions open, finish or abort one first", ErrTransferBusy, owned)
+	}
+	r.entries[id] = &transferEntry{
+		transfer: t,
+		owner:    owner,
+		timer:    time.AfterFunc(r.timeout, func() { r.end(id) }),
+	}
+	return id, nil
+}
+
+// get returns the transfer owner started with the given ID and restarts its
+// idle timer. Transfers of other connections are reported as missing.
+func (r *transferRegistry) get(owner *Session, id string) (transfer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, exists := r.entries[id]
+	if !exists || entry.owner != owner {
+		return nil, false
+	}
+	entry.timer.Reset(r.timeout)
+	return entry.transfer, true
+}
+
+// take removes a transfer owner started without closing it, so a command
+// can finish it without the idle timer or a disconnect closing it first
+func (r *transferRegistry) take(owner *Session, id string) (transfer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, exists := r.entries[id]
+	if !exists || entry.owner != owner {
+		return nil, false
+	}
+	return r.remove(id, entry), true
+}
+
+// remove unregisters an entry and stops its idle timer. r.mu must be held.
+func (r *transferRegistry) remove(id string, entry *transferEntry) transfer {
+	delete(r.entries, id)
+	entry.timer.Stop()
+	return entry.transfer
+}
+
+// abort removes and closes a transfer owner started. It reports whether
+// the transfer was still registered.
+func (r *transferRegistry) abort(owner *Session, id string) bool {
+	t, exists := r.take(owner, id)
+	if exists {
+		t.close()
+	}
+	return exists
+}
+
+// end removes and closes a transfer whoever started it
+func (r *transferRegistry) end(id string) {
+	r.mu.Lock()
+	entry, exists := r.entries[id]
+	var t transfer
+	if exists {
+		t = r.remove(id, entry)
+	}
+	r.mu.Unlock()
+	if exists {
+		t.close()
+	}
+}
+
+// release ends every transfer owned by a session
+func (r *transferRegistry) release(owner *Session) {
+	r.mu.Lock()
+	var owned []string
+	for id, entry := range r.entries {
+		if entry.owner == owner {
+			owned = append(owned, id)
+		}
+	}
+	r.mu.Unlock()
+	for _, id := range owned {
+		r.end(id)
+	}
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// handleTree handles ANALYSIS.TREE <graph> <node> [MAXDEPTH n] [FORMAT json].
+// It replies with the dependency tree of the node as JSON in a bulk string,
+// the only format so far.
+func (a *AnalysisCommands) handleTree(args []string) (*protocol.Response, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("ANALYSIS.TREE requires at least 2 arguments: graph, node")
+	}
+	graphID := models.GraphID(args[0])
+	nodeID, err := resolveNodeID(a.storage, graphID, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	options := &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: -1}
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("%s option requires an argument", strings.ToUpper(args[i]))
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MAXDEPTH":
+			depth, err := strconv.Atoi(args[i+1])
+			if err != nil || depth < 0 {
+				return nil, fmt.Errorf("invalid MAXDEPTH: %s (must be a non-negative integer)", args[i+1])
+			}
+			options.MaxDepth = depth
+		case "FORMAT":
+			if strings.ToLower(args[i+1]) != "json" {
+				return nil, fmt.Errorf("invalid FORMAT: %s (must be 'json')", args[i+1])
+			}
+		default:
+			return nil, fmt.Errorf("unknown option for ANALYSIS.TREE: %s", args[i])
+		}
+	}
+
+	tree, err := a.analyzer.BuildDependencyTree(graphID, nodeID, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build dependency tree: %w", err)
+	}
+	return jsonResponse(tree)
+}
@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// handleWhatIf handles ANALYSIS.WHATIF <graph> REMOVE EDGES|NODES <id,...> [REMOVE ...]
+// CHECK REACHABLE|SHORTESTPATH <from> <to> | SUMMARY FROMTYPE <type,...> TOTYPE <type,...> [CRITICAL].
+// The removals are applied over reads only; the graph is never changed.
+func (a *AnalysisCommands) handleWhatIf(args []string) (*protocol.Response, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("ANALYSIS.WHATIF requires a graph")
+	}
+	graphID := models.GraphID(args[0])
+
+	overlay := &types.Overlay{
+		RemovedNodes: make(map[models.NodeID]bool),
+		RemovedEdges: make(map[models.EdgeID]bool),
+	}
+	i := 1
+	for i < len(args) && strings.ToUpper(args[i]) == "REMOVE" {
+		if i+2 >= len(args) {
+			return nil, fmt.Errorf("REMOVE requires EDGES or NODES and a list of IDs")
+		}
+		ids := strings.Split(args[i+2], ",")
+		switch strings.ToUpper(args[i+1]) {
+		case "EDGES":
+			for _, id := range ids {
+				if _, err := a.storage.GetEdge(graphID, models.EdgeID(id)); err != nil {
+					return nil, fmt.Errorf("failed to remove edge %s: %w", id, err)
+				}
+				overlay.RemovedEdges[models.EdgeID(id)] = true
+			}
+		case "NODES":
+			for _, id := range ids {
+				nodeID, err := resolveNodeID(a.storage, graphID, id)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := a.storage.GetNode(graphID, nodeID); err != nil {
+					return nil, fmt.Errorf("failed to remove node %s: %w", id, err)
+				}
+				overlay.RemovedNodes[nodeID] = true
+			}
+		default:
+			return nil, fmt.Errorf("invalid REMOVE: %s (must be 'EDGES' or 'NODES')", args[i+1])
+		}
+		i += 3
+	}
+	if i == 1 {
+		return nil, fmt.Errorf("ANALYSIS.WHATIF requires at least one REMOVE clause")
+	}
+	if i >= len(args) {
+		return nil, fmt.Errorf("ANALYSIS.WHATIF requires CHECK or SUMMARY")
+	}
+
+	switch strings.ToUpper(args[i]) {
+	case "CHECK":
+		if len(args) != i+4 {
+			return nil, fmt.Errorf("CHECK requires REACHABLE or SHORTESTPATH and 2 nodes: from, to")
+		}
+		from, err := resolveNodeID(a.storage, graphID, args[i+2])
+		if err != nil {
+			return nil, err
+		}
+		to, err := resolveNodeID(a.storage, graphID, args[i+3])
+		if err != nil {
+			return nil, err
+		}
+		return a.handleWhatIfCheck(graphID, strings.ToUpper(args[i+1]), from, to, overlay)
+	case "SUMMARY":
+		critical := len(args) == i+6 && strings.ToUpper(args[i+5]) == "CRITICAL"
+		if (len(args) != i+5 && !critical) || strings.ToUpper(args[i+1]) != "FROMTYPE" || strings.ToUpper(args[i+3]) != "TOTYPE" {
+			return nil, fmt.Errorf("SUMMARY requires FROMTYPE <type,...> TOTYPE <type,...> [CRITICAL]")
+		}
+		return a.handleWhatIfSummary(graphID, args[i+2], args[i+4], critical, overlay)
+	default:
+		return nil, fmt.Errorf("unknown option for ANALYSIS.WHATIF: %s", args[i])
+	}
+}
+
+// handleWhatIfCheck replies to CHECK REACHABLE with reachable_before and
+// reachable_after flags, and to CHECK SHORTESTPATH with the shortest path
+// under the overlay as nodeid:nodetype entries, or null if there is none
+func (a *AnalysisCommands) handleWhatIfCheck(graphID models.GraphID, check string, from, to models.NodeID, overlay *types.Overlay) (*protocol.Response, error) {
+	switch check {
+	case "REACHABLE":
+		before, err := a.analyzer.WhatIfReachable(graphID, from, to, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check reachability: %w", err)
+		}
+		after, err := a.analyzer.WhatIfReachable(graphID, from, to, overlay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check reachability: %w", err)
+		}
+		return protocol.NewArrayResponse([]string{
+			"reachable_before", boolFlag(before),
+			"reachable_after", boolFlag(after),
+		}), nil
+	case "SHORTESTPATH":
+		reachable, err := a.analyzer.WhatIfReachable(graphID, from, to, overlay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check reachability: %w", err)
+		}
+		if !reachable {
+			return protocol.NewNullResponse(), nil
+		}
+		pathResult, err := a.analyzer.WhatIfShortestPath(graphID, from, to, overlay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute shortest path: %w", err)
+		}
+		return a.buildSimplePathResponse(graphID, pathResult, nil)
+	default:
+		return nil, fmt.Errorf("invalid CHECK: %s (must be 'REACHABLE' or 'SHORTESTPATH')", check)
+	}
+}
+
+// handleWhatIfSummary pairs every node of the FROMTYPE types with every node
+// of the TOTYPE types and replies with pairs, reachable_before,
+// reachable_after and lost counts, followed by each lost pair as from->to.
+// With CRITICAL the critical count follows, then the edges on every
+// shortest path of a pair still reachable.
+func (a *AnalysisCommands) handleWhatIfSummary(graphID models.GraphID, fromTypes, toTypes string, critical bool, overlay *types.Overlay) (*protocol.Response, error) {
+	sources, err := a.nodesOfTypes(graphID, fromTypes)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := a.nodesOfTypes(graphID, toTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := a.analyzer.WhatIfStats(graphID, sources, targets, overlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize what-if: %w", err)
+	}
+	if critical {
+		summary.Critical, err = a.analyzer.WhatIfCriticalEdges(graphID, sources, targets, overlay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find critical edges: %w", err)
+		}
+	}
+
+	result := []string{
+		"pairs", strconv.Itoa(summary.Pairs),
+		"reachable_before", strconv.Itoa(summary.ReachableBefore),
+		"reachable_after", strconv.Itoa(summary.ReachableAfter),
+		"lost", strconv.Itoa(len(summary.Lost)),
+	}
+	for _, pair := range summary.Lost {
+		result = append(result, string(pair.From)+"->"+string(pair.To))
+	}
+	if critical {
+		result = append(result, "critical", strconv.Itoa(len(summary.Critical)))
+		for _, edgeID := range summary.Critical {
+			result = append(result, string(edgeID))
+		}
+	}
+	return protocol.NewArrayResponse(result), nil
+}
+
+// nodesOfTypes returns the IDs of the nodes of a comma-separated list of types
+func (a *AnalysisCommands) nodesOfTypes(graphID models.GraphID, typeList string) ([]models.NodeID, error) {
+	var nodeIDs []models.NodeID
+	for _, nodeType := range strings.Split(typeList, ",") {
+		nodes, err := a.storage.ListNodesByType(graphID, models.NodeType(nodeType))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s nodes: %w", nodeType, err)
+		}
+		for _, node := range nodes {
+			nodeIDs = append(nodeIDs, node.ID)
+		}
+	}
+	return nodeIDs, nil
+}
+
+// boolFlag renders a flag as "1" or "0"
+func boolFlag(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
@@ -1,6 +1,15 @@
 package redis
 
-import "time"
+import (
+	"log/slog"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/jobs"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+	"go.opentelemetry.io/otel/trace"
+)
 
 // Config holds the configuration for the Redis server
 type Config struct {
@@ -21,6 +30,73 @@ type Config struct {
 	
 	// Enable debug logging
 	Debug bool
+
+	// Minimum log level: debug, info, warn or error
+	LogLevel string
+
+	// Password for AUTH; authenticated connections get the admin role.
+	// AUTH is disabled when empty.
+	AdminPassword string
+
+	// Number of background analysis jobs run at the same time
+	JobWorkers int
+
+	// Maximum queued plus running analysis jobs
+	MaxActiveJobs int
+
+	// How long finished job results are kept
+	JobResultTTL time.Duration
+
+	// Maximum number of finished job results kept
+	MaxJobResults int
+
+	// Number of commands executed at the same time across all connections.
+	// Commands run on their connection's goroutine when 0.
+	MaxConcurrentCommands int
+
+	// Commands that may wait for a free worker before new ones are
+	// rejected with a BUSY error
+	CommandQueueSize int
+
+	// Count node reads for ANALYSIS.HOTNODES
+	TrackReads bool
+
+	// How long a chunked GRAPH.EXPORT or GRAPH.IMPORT session may sit idle
+	// before it is discarded
+	TransferTimeout time.Duration
+
+	// Log array replies at info level, one numbered item per attribute,
+	// to follow a telnet or netcat session from the server side
+	HumanReadable bool
+
+	// Export a span per command, with child spans for traversals, shortest
+	// paths and cycle searches, to an OTLP/HTTP collector
+	EnableTracing bool
+
+	// OTLP/HTTP collector URL or host:port. The exporter's defaults and
+	// OTEL_EXPORTER_OTLP_* variables apply when empty.
+	TracingEndpoint string
+
+	// Fraction of storage write transactions traced, from 0 to 1. Storage
+	// spans are not traced when 0.
+	StorageTraceSampleRate float64
+
+	// Record a stats snapshot of every graph at startup and then every
+	// StatsHistoryInterval, for ANALYSIS.STATSHISTORY
+	EnableStatsHistory   bool
+	StatsHistoryInterval time.Duration
+
+	// Days of stats snapshots kept per graph; 0 keeps all
+	StatsHistoryDays int
+
+	// Graph sizes above which path enumeration, cycle enumeration and
+	// clustering commands are refused unless given FORCE, and the most
+	// nodes ANALYSIS.PAIRWISE compares
+	AnalysisLimits commands.AnalysisLimits
+
+	// Most results the list, filter and neighbor commands sort for
+	// ORDERBY; 0 is not checked
+	MaxSortResults int
 }
 
 // DefaultConfig returns a default configuration
@@ -32,5 +108,106 @@ func DefaultConfig() *Config {
 		ReadTimeout:       30 * time.Second,
 		WriteTimeout:      30 * time.Second,
 		Debug:             false,
+		LogLevel:          "info",
+		JobWorkers:        2,
+		MaxActiveJobs:     16,
+		JobResultTTL:      10 * time.Minute,
+		MaxJobResults:     100,
+
+		MaxConcurrentCommands: 64,
+		CommandQueueSize:      256,
+		TransferTimeout:       commands.DefaultTransferTimeout,
+
+		StatsHistoryInterval: analysis.DefaultStatsHistoryInterval,
+		StatsHistoryDays:     storage.DefaultStatsHistoryDays,
+
+		AnalysisLimits: commands.DefaultAnalysisLimits(),
+		MaxSortResults: commands.DefaultMaxSortResults,
+	}
+}
+
+// Option configures a Server or CommandHandler
+type Option func(*options)
+
+// options holds the values set by Option functions
+type options struct {
+	logger        *slog.Logger
+	adminPassword string
+	jobConfig     *jobs.Config
+
+	transferTimeout time.Duration
+	tracerProvider  trace.TracerProvider
+	analysisLimits  *commands.AnalysisLimits
+	idGenerator     commands.IDGenerator
+	sortLimit       *int
+}
+
+// WithLogger sets the logger used for connection and command events
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithAdminPassword sets the password AUTH accepts for the admin role
+func WithAdminPassword(password string) Option {
+	return func(o *options) {
+		o.adminPassword = password
+	}
+}
+
+// WithJobConfig sets the limits for background analysis jobs
+func WithJobConfig(config jobs.Config) Option {
+	return func(o *options) {
+		o.jobConfig = &config
+	}
+}
+
+// WithTransferTimeout sets how long chunked export and import sessions may
+// sit idle
+func WithTransferTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.transferTimeout = timeout
+	}
+}
+
+// WithAnalysisLimits sets the graph sizes above which analysis commands
+// need FORCE, in place of commands.DefaultAnalysisLimits
+func WithAnalysisLimits(limits commands.AnalysisLimits) Option {
+	return func(o *options) {
+		o.analysisLimits = &limits
+	}
+}
+
+// WithIDGenerator sets the generator of NODE.CREATE and EDGE.CREATE AUTO
+// IDs, in place of a commands.NewULIDGenerator
+func WithIDGenerator(ids commands.IDGenerator) Option {
+	return func(o *options) {
+		o.idGenerator = ids
+	}
+}
+
+// WithSortLimit sets the most results ORDERBY sorts, in place of
+// commands.DefaultMaxSortResults; 0 is not checked
+func WithSortLimit(limit int) Option {
+	return func(o *options) {
+		o.sortLimit = &limit
+	}
+}
+
+// WithTracerProvider sets the provider command spans are started with, in
+// place of the one EnableTracing would create
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = provider
+	}
+}
+
+// applyOptions collects opts into an options value
+func applyOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
 	}
+	return o
 }
@@ -0,0 +1,283 @@
+package redis
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tidwall/redcon"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// eventBacklog bounds the messages waiting to be written to a subscribed
+// connection. A connection that falls further behind is closed rather than
+// holding back the writes that publish to it.
+const eventBacklog = 1024
+
+// eventHub delivers the node and edge events of the storage engine to the
+// connections subscribed to their graph. It listens to the engine only
+// while a connection is subscribed, so transactions do not collect events
+// nobody reads.
+type eventHub struct {
+	storage     storage.StorageEngine
+	logger      *slog.Logger
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+	// listener guards stop, the function removing the hub's listener from
+	// the engine. It is never held with mu, as the engine calls publish
+	// holding its own lock.
+	listener sync.Mutex
+	stop     func()
+}
+
+// newEventHub creates the hub of a server's connections
+func newEventHub(storageEngine storage.StorageEngine, logger *slog.Logger) *eventHub {
+	return &eventHub{storage: storageEngine, logger: logger, subscribers: make(map[*subscriber]struct{})}
+}
+
+// add starts delivering events to a connection
+func (h *eventHub) add(sub *subscriber) {
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+	h.listen()
+}
+
+// remove stops delivering events to a connection
+func (h *eventHub) remove(sub *subscriber) {
+	h.mu.Lock()
+	delete(h.subscribers, sub)
+	h.mu.Unlock()
+	h.listen()
+}
+
+// listen adds the hub's listener to the engine while it has subscribers
+// and removes it once it has none
+func (h *eventHub) listen() {
+	h.listener.Lock()
+	defer h.listener.Unlock()
+	h.mu.RLock()
+	subscribers := len(h.subscribers)
+	h.mu.RUnlock()
+	switch {
+	case subscribers > 0 && h.stop == nil:
+		h.stop = h.storage.AddEventListener(h.publish)
+	case subscribers == 0 && h.stop != nil:
+		h.stop()
+		h.stop = nil
+	}
+}
+
+// publish queues the events of a committed transaction on the connections
+// subscribed to their graph
+func (h *eventHub) publish(events []storage.Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscribers {
+		if sub.dropped.Load() {
+			continue
+		}
+		for _, event := range events {
+			if sub.wants(event.Graph) && !sub.queue(event) {
+				// The connection stays subscribed until its goroutine sees
+				// it closed, so it is marked to be closed once
+				if !sub.dropped.CompareAndSwap(false, true) {
+					break
+				}
+				h.logger.Warn("closing subscriber that fell behind", "client", sub.conn.RemoteAddr(), "backlog", eventBacklog)
+				// Closing the socket rather than the redcon connection
+				// leaves its buffer to the goroutine writing it
+				sub.conn.NetConn().Close()
+				break
+			}
+		}
+	}
+}
+
+// subscriber holds the subscriptions of a connection and the events waiting
+// to be written to it. It is the session's commands.EventSubscriber.
+type subscriber struct {
+	hub      *eventHub
+	conn     redcon.Conn
+	mu       sync.Mutex
+	graphs   map[models.GraphID]struct{}
+	messages chan storage.Event
+	// dropped is set once publish closed the connection for falling behind
+	dropped atomic.Bool
+	// write serializes the replies and messages written to the connection
+	// once it is detached
+	write    sync.Mutex
+	detached bool
+}
+
+// newSubscriber creates the subscriber of a connection
+func newSubscriber(hub *eventHub, conn redcon.Conn) *subscriber {
+	return &subscriber{hub: hub, conn: conn, graphs: make(map[models.GraphID]struct{}), messages: make(chan storage.Event, eventBacklog)}
+}
+
+// Subscribe adds a graph and returns how many the connection is subscribed
+// to
+func (s *subscriber) Subscribe(graphID models.GraphID) int {
+	s.mu.Lock()
+	first := len(s.graphs) == 0
+	s.graphs[graphID] = struct{}{}
+	count := len(s.graphs)
+	s.mu.Unlock()
+	// The hub is called without s.mu, which publish takes under the hub's
+	// lock
+	if first {
+		s.hub.add(s)
+	}
+	return count
+}
+
+// Unsubscribe removes a graph and returns how many are left
+func (s *subscriber) Unsubscribe(graphID models.GraphID) int {
+	s.mu.Lock()
+	_, ok := s.graphs[graphID]
+	delete(s.graphs, graphID)
+	count := len(s.graphs)
+	s.mu.Unlock()
+	if ok && count == 0 {
+		s.hub.remove(s)
+	}
+	return count
+}
+
+// Subscriptions returns the graphs subscribed to, sorted
+func (s *subscriber) Subscriptions() []models.GraphID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	graphIDs := make([]models.GraphID, 0, len(s.graphs))
+	for graphID := range s.graphs {
+		graphIDs = append(graphIDs, graphID)
+	}
+	sort.Slice(graphIDs, func(i, j int) bool { return graphIDs[i] < graphIDs[j] })
+	return graphIDs
+}
+
+// subscribed reports whether the connection is subscribed to any graph
+func (s *subscriber) subscribed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.graphs) > 0
+}
+
+// wants reports whether the connection is subscribed to a graph
+func (s *subscriber) wants(graphID models.GraphID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.graphs[graphID]
+	return ok
+}
+
+// queue queues an event for the connection, or reports false if its backlog
+// is full
+func (s *subscriber) queue(event storage.Event) bool {
+	select {
+	case s.messages <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// close unsubscribes the connection from every graph
+func (s *subscriber) close() {
+	s.mu.Lock()
+	s.graphs = make(map[models.GraphID]struct{})
+	s.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// subscriberOf returns the subscriber the server set on a session
+func subscriberOf(session *commands.Session) *subscriber {
+	sub, _ := session.Events.(*subscriber)
+	return sub
+}
+
+// subscribedCommands are the commands a connection may run while it is
+// subscribed to a graph, besides PING, as with SUBSCRIBE in Redis
+var subscribedCommands = map[string]bool{
+	"GRAPH.SUBSCRIBE":   true,
+	"GRAPH.UNSUBSCRIBE": true,
+}
+
+// serveSubscriber takes over a connection once GRAPH.SUBSCRIBE subscribed
+// it. Redcon writes replies only after the handler returns, so the
+// connection is detached and served here: events are written as
+// ["message", graph, event JSON] arrays between the replies to its
+// commands. The connection stays detached until it closes, running any
+// command again once it has unsubscribed from every graph.
+func (s *Server) serveSubscriber(conn redcon.Conn, session *commands.Session, sub *subscriber) {
+	sub.write.Lock()
+	sub.detached = true
+	dconn := conn.Detach()
+	err := dconn.Flush()
+	sub.write.Unlock()
+
+	done := make(chan struct{})
+	var writer sync.WaitGroup
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		for {
+			select {
+			case event := <-sub.messages:
+				payload, _ := json.Marshal(event)
+				sub.write.Lock()
+				dconn.WriteArray(3)
+				dconn.WriteBulkString("message")
+				dconn.WriteBulkString(string(event.Graph))
+				dconn.WriteBulk(payload)
+				if len(sub.messages) == 0 {
+					dconn.Flush()
+				}
+				sub.write.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for err == nil {
+		var cmd redcon.Command
+		if cmd, err = dconn.ReadCommand(); err != nil {
+			break
+		}
+		if len(cmd.Args) == 0 {
+			continue
+		}
+		command := commands.NormalizeCommand(string(cmd.Args[0]))
+		var response *Response
+		var reply string
+		switch {
+		case !sub.subscribed() || subscribedCommands[command]:
+			response, reply = s.execute(conn.RemoteAddr(), session, cmd)
+		case command == "PING":
+			// A plain PONG could be mistaken for a message, so subscribed
+			// connections get an array, as in Redis
+			message := ""
+			if len(cmd.Args) > 1 {
+				message = string(cmd.Args[1])
+			}
+			response = protocol.NewArrayResponse([]string{"pong", message})
+		default:
+			reply = "ERR only GRAPH.SUBSCRIBE, GRAPH.UNSUBSCRIBE and PING are allowed while subscribed, not " + command
+		}
+		sub.write.Lock()
+		s.reply(dconn, response, reply)
+		err = dconn.Flush()
+		sub.write.Unlock()
+	}
+
+	close(done)
+	writer.Wait()
+	sub.close()
+	dconn.Close()
+}
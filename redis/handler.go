@@ -1,12 +1,20 @@
 package redis
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/ywadi/PathwayDB/jobs"
+	"github.com/ywadi/PathwayDB/logging"
 	"github.com/ywadi/PathwayDB/redis/commands"
 	"github.com/ywadi/PathwayDB/redis/protocol"
 	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/tracing"
+	"github.com/ywadi/PathwayDB/version"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Response is an alias for protocol.Response for convenience
@@ -14,57 +22,158 @@ type Response = protocol.Response
 
 // CommandHandler handles Redis command routing and execution
 type CommandHandler struct {
-	storage      storage.StorageEngine
-	graphCmd     *commands.GraphCommands
-	nodeCmd      *commands.NodeCommands
-	edgeCmd      *commands.EdgeCommands
-	analysisCmd  *commands.AnalysisCommands
+	storage       storage.StorageEngine
+	graphCmd      *commands.GraphCommands
+	analysisCmd   *commands.AnalysisCommands
+	registry      *commands.Registry
+	logger        *slog.Logger
+	adminPassword string
+	stats         *commandStats
+	// tracer starts command spans; nil unless a tracer provider was given
+	tracer trace.Tracer
 }
 
 // NewCommandHandler creates a new command handler
-func NewCommandHandler(storageEngine storage.StorageEngine) *CommandHandler {
-	return &CommandHandler{
-		storage:     storageEngine,
-		graphCmd:    commands.NewGraphCommands(storageEngine),
-		nodeCmd:     commands.NewNodeCommands(storageEngine),
-		edgeCmd:     commands.NewEdgeCommands(storageEngine),
-		analysisCmd: commands.NewAnalysisCommands(storageEngine),
+func NewCommandHandler(storageEngine storage.StorageEngine, opts ...Option) *CommandHandler {
+	o := applyOptions(opts)
+	if o.logger == nil {
+		o.logger = logging.Default()
 	}
+	h := &CommandHandler{
+		storage:       storageEngine,
+		logger:        o.logger,
+		adminPassword: o.adminPassword,
+		stats:         newCommandStats(),
+		graphCmd:      commands.NewGraphCommands(storageEngine),
+		analysisCmd:   commands.NewAnalysisCommands(storageEngine),
+		registry:      commands.NewRegistry(),
+	}
+	if o.tracerProvider != nil {
+		h.tracer = o.tracerProvider.Tracer(tracing.TracerName)
+	}
+	nodeCmd := commands.NewNodeCommands(storageEngine)
+	edgeCmd := commands.NewEdgeCommands(storageEngine)
+	h.register()
+	h.graphCmd.Register(h.registry)
+	nodeCmd.Register(h.registry)
+	edgeCmd.Register(h.registry)
+	h.analysisCmd.Register(h.registry)
+	commands.NewQueryCommands(storageEngine, h.registry).Register(h.registry)
+	commands.NewSearchCommands(storageEngine).Register(h.registry)
+	commands.NewMetaCommands(storageEngine).Register(h.registry)
+	systemCmd := commands.NewSystemCommands(storageEngine)
+	systemCmd.SetAdminRequired(o.adminPassword != "")
+	systemCmd.Register(h.registry)
+	h.registry.RegisterHelp()
+	if o.jobConfig != nil {
+		h.analysisCmd.SetJobManager(jobs.NewManager(*o.jobConfig))
+	}
+	if o.transferTimeout > 0 {
+		h.graphCmd.SetTransferTimeout(o.transferTimeout)
+	}
+	h.graphCmd.SetLogger(o.logger)
+	if o.analysisLimits != nil {
+		h.analysisCmd.SetAnalysisLimits(*o.analysisLimits)
+	}
+	if o.idGenerator != nil {
+		nodeCmd.SetIDGenerator(o.idGenerator)
+		edgeCmd.SetIDGenerator(o.idGenerator)
+	}
+	if o.sortLimit != nil {
+		nodeCmd.SetSortLimit(*o.sortLimit)
+		edgeCmd.SetSortLimit(*o.sortLimit)
+	}
+	return h
+}
+
+// CloseSession releases the per-connection state of a closed connection
+func (h *CommandHandler) CloseSession(session *commands.Session) {
+	h.graphCmd.CloseSession(session)
 }
 
 // Handle routes and executes Redis commands
 func (h *CommandHandler) Handle(command string, args []string) (*Response, error) {
-	// Split command by dots for namespaced commands (e.g., GRAPH.CREATE)
-	parts := strings.Split(command, ".")
-	
-	switch parts[0] {
-	case "PING":
-		return h.handlePing(args)
-	case "INFO":
-		return h.handleInfo(args)
-	case "GRAPH":
-		if len(parts) < 2 {
-			return nil, fmt.Errorf("incomplete GRAPH command")
-		}
-		return h.graphCmd.Handle(parts[1], args)
-	case "NODE":
-		if len(parts) < 2 {
-			return nil, fmt.Errorf("incomplete NODE command")
-		}
-		return h.nodeCmd.Handle(parts[1], args)
-	case "EDGE":
-		if len(parts) < 2 {
-			return nil, fmt.Errorf("incomplete EDGE command")
-		}
-		return h.edgeCmd.Handle(parts[1], args)
-	case "ANALYSIS":
-		if len(parts) < 2 {
-			return nil, fmt.Errorf("incomplete ANALYSIS command")
-		}
-		return h.analysisCmd.Handle(parts[1], args)
-	default:
-		return nil, fmt.Errorf("unknown command: %s", command)
+	return h.HandleSession(&commands.Session{}, command, args)
+}
+
+// HandleSession routes and executes a command on behalf of a connection
+func (h *CommandHandler) HandleSession(session *commands.Session, command string, args []string) (*Response, error) {
+	return h.handle(h.logger, session, command, args, 0)
+}
+
+// handle executes a command, records its statistics and logs its outcome
+// with the given logger. queued is how long the command waited for a
+// worker. Failures are logged at warn, successful commands at debug.
+// Command names are case-insensitive and may use a namespace alias.
+func (h *CommandHandler) handle(logger *slog.Logger, session *commands.Session, command string, args []string, queued time.Duration) (*Response, error) {
+	command = commands.NormalizeCommand(command)
+	var graph string
+	if strings.Contains(command, ".") && !strings.HasPrefix(command, "QUERY.") && !strings.HasPrefix(command, "SYSTEM.") && len(args) > 0 {
+		graph = args[0]
+	}
+	span := h.startSpan(session, command, graph, args, queued)
+
+	start := time.Now()
+	response, err := h.dispatch(session, command, args)
+	elapsed := time.Since(start)
+	h.stats.record(command, queued, elapsed, err != nil)
+	h.endSpan(session, span, err)
+
+	attrs := []any{"command", command, "duration", elapsed}
+	if queued > 0 {
+		attrs = append(attrs, "queued", queued)
+	}
+	if graph != "" {
+		attrs = append(attrs, "graph", graph)
+	}
+	if err != nil {
+		logger.Warn("command failed", append(attrs, "error", err)...)
+	} else {
+		logger.Debug("command executed", attrs...)
 	}
+	return response, err
+}
+
+// Registry returns the commands the handler routes
+func (h *CommandHandler) Registry() *commands.Registry {
+	return h.registry
+}
+
+// dispatch routes a command to the handler registered for it
+func (h *CommandHandler) dispatch(session *commands.Session, command string, args []string) (*Response, error) {
+	return h.registry.Dispatch(session, command, args)
+}
+
+// register adds the commands that belong to no namespace
+func (h *CommandHandler) register() {
+	h.registry.Register(commands.CommandSpec{
+		Name:     "PING",
+		Args:     "[message]",
+		Summary:  "Replies with PONG, or with the message",
+		Example:  "PING",
+		ReadOnly: true,
+		Handler: func(session *commands.Session, args []string) (*Response, error) {
+			return h.handlePing(args)
+		},
+	})
+	h.registry.Register(commands.CommandSpec{
+		Name:     "INFO",
+		Args:     "[server|commandstats|cache|compression|all]",
+		Keywords: []string{"server", "commandstats", "cache", "compression", "all"},
+		Summary:  "Reports server information and per-command statistics",
+		Example:  "INFO commandstats",
+		ReadOnly: true,
+		Handler: func(session *commands.Session, args []string) (*Response, error) {
+			return h.handleInfo(args)
+		},
+	})
+	h.registry.Register(commands.CommandSpec{
+		Name:    "AUTH",
+		Args:    "<password>",
+		Summary: "Grants the connection the admin role",
+		Example: "AUTH s3cret",
+		Handler: h.handleAuth,
+	})
 }
 
 // handlePing handles the PING command
@@ -75,14 +184,64 @@ func (h *CommandHandler) handlePing(args []string) (*Response, error) {
 	return protocol.NewBulkResponse(args[0]), nil
 }
 
-// handleInfo handles the INFO command
+// handleAuth handles AUTH <password>, granting the connection the admin role
+func (h *CommandHandler) handleAuth(session *commands.Session, args []string) (*Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("AUTH requires exactly 1 argument: password")
+	}
+	if h.adminPassword == "" {
+		return nil, fmt.Errorf("AUTH is not enabled: no admin password configured")
+	}
+	if subtle.ConstantTimeCompare([]byte(args[0]), []byte(h.adminPassword)) != 1 {
+		return nil, fmt.Errorf("invalid password")
+	}
+
+	session.Admin = true
+	return protocol.OK(), nil
+}
+
+// handleInfo handles INFO [section]. The commandstats and cache sections
+// are only included when asked for by name or with "all".
 func (h *CommandHandler) handleInfo(args []string) (*Response, error) {
-	info := []string{
-		"# PathwayDB",
-		"version:1.0.0",
-		"redis_protocol:enabled",
-		"storage_engine:badger",
+	section := "default"
+	if len(args) > 0 {
+		section = strings.ToLower(args[0])
+	}
+
+	var info []string
+	if section == "default" || section == "all" || section == "server" {
+		info = append(info,
+			"# PathwayDB",
+			"version:"+version.Version,
+			"commit:"+version.Commit,
+			"redis_protocol:enabled",
+			"storage_engine:badger",
+		)
 	}
-	
+	if section == "all" || section == "commandstats" {
+		if len(info) > 0 {
+			info = append(info, "")
+		}
+		info = append(info, "# Commandstats")
+		info = append(info, h.stats.lines()...)
+	}
+	if section == "all" || section == "cache" {
+		if len(info) > 0 {
+			info = append(info, "")
+		}
+		info = append(info, "# Cache")
+		info = append(info, cacheLines(h.storage.CacheStats())...)
+	}
+	if section == "all" || section == "compression" {
+		if len(info) > 0 {
+			info = append(info, "")
+		}
+		info = append(info, "# Compression")
+		info = append(info, compressionLines(h.storage.CompressionStats())...)
+	}
+	if info == nil {
+		return nil, fmt.Errorf("unknown INFO section: %s", args[0])
+	}
+
 	return protocol.NewBulkResponse(strings.Join(info, "\r\n")), nil
 }
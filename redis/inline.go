@@ -0,0 +1,213 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ErrUnbalancedQuotes is returned by SplitInline for a quote that is not
+// closed, or is closed and followed by something other than whitespace
+var ErrUnbalancedQuotes = errors.New("unbalanced quotes in request")
+
+// maxInlineSize bounds an inline command line, as in Redis
+const maxInlineSize = 64 * 1024
+
+// SplitInline splits an inline command line into arguments the way
+// redis-cli and the Redis server do. Arguments are separated by whitespace
+// and may be quoted to include it:
+//
+//   - Inside double quotes, \n, \r, \t, \b and \a are control characters,
+//     \xhh is a hex byte and a backslash before any other character escapes it.
+//   - Inside single quotes, only \' is an escape; everything else is literal.
+//   - A quote in the middle of an argument starts a quoted section that
+//     continues the argument: a"b c"d is the single argument "ab cd".
+//   - A closing quote must be followed by whitespace or the end of the line.
+func SplitInline(line string) ([]string, error) {
+	var args []string
+	p := 0
+	for {
+		for p < len(line) && isInlineSpace(line[p]) {
+			p++
+		}
+		if p == len(line) {
+			return args, nil
+		}
+
+		var current []byte
+		inDouble, inSingle := false, false
+		for done := false; !done; p++ {
+			if inDouble {
+				if p == len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+				c := line[p]
+				switch {
+				case c == '\\' && p+3 < len(line) && line[p+1] == 'x' && isHexDigit(line[p+2]) && isHexDigit(line[p+3]):
+					b, _ := strconv.ParseUint(line[p+2:p+4], 16, 8)
+					current = append(current, byte(b))
+					p += 3
+				case c == '\\' && p+1 < len(line):
+					p++
+					switch line[p] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, line[p])
+					}
+				case c == '"':
+					if p+1 < len(line) && !isInlineSpace(line[p+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					current = append(current, c)
+				}
+			} else if inSingle {
+				if p == len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+				c := line[p]
+				switch {
+				case c == '\\' && p+1 < len(line) && line[p+1] == '\'':
+					p++
+					current = append(current, '\'')
+				case c == '\'':
+					if p+1 < len(line) && !isInlineSpace(line[p+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					current = append(current, c)
+				}
+			} else {
+				if p == len(line) {
+					break
+				}
+				switch c := line[p]; {
+				case isInlineSpace(c):
+					done = true
+				case c == '"':
+					inDouble = true
+				case c == '\'':
+					inSingle = true
+				default:
+					current = append(current, c)
+				}
+			}
+		}
+		args = append(args, string(current))
+	}
+}
+
+// isInlineSpace reports whether c separates inline arguments
+func isInlineSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == 0
+}
+
+// isHexDigit reports whether c is a hexadecimal digit
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// inlineListener wraps accepted connections in an inlineConn
+type inlineListener struct {
+	net.Listener
+}
+
+// Accept waits for the next connection and wraps it
+func (l *inlineListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &inlineConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// inlineConn tokenizes inline commands with SplitInline and hands them to
+// redcon as RESP arrays. redcon splits inline commands itself but does not
+// mark them, and only honors quotes at the start of an argument, so the
+// line is read here before redcon sees it. A connection that sends a RESP
+// array is passed through untouched from then on, so RESP clients are
+// never tokenized.
+type inlineConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	resp    bool
+	pending []byte
+}
+
+// Read returns the next command, one inline line per call
+func (c *inlineConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if c.resp {
+			return c.reader.Read(p)
+		}
+		first, err := c.reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if first[0] == '*' {
+			c.resp = true
+			continue
+		}
+
+		line, err := c.readLine()
+		if err != nil {
+			return 0, err
+		}
+		args, err := SplitInline(string(line))
+		if err != nil {
+			// Redis replies and closes the connection, as the rest of the
+			// stream cannot be trusted. redcon has flushed earlier replies
+			// before reading again, so this one comes last.
+			c.Conn.Write([]byte("-ERR Protocol error: " + err.Error() + "\r\n"))
+			return 0, err
+		}
+		if len(args) == 0 {
+			continue
+		}
+		c.pending = encodeInline(args)
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readLine reads an inline line up to and including its newline
+func (c *inlineConn) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := c.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxInlineSize {
+			c.Conn.Write([]byte("-ERR Protocol error: too big inline request\r\n"))
+			return nil, fmt.Errorf("inline request exceeds %d bytes", maxInlineSize)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return line, err
+	}
+}
+
+// encodeInline encodes inline arguments as a RESP array of bulk strings
+func encodeInline(args []string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return b.Bytes()
+}
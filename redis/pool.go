@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBusy is returned when every command worker is busy and the wait queue
+// is full
+var ErrBusy = errors.New("BUSY server overloaded, try later")
+
+// commandTask is a command waiting for or running on a worker
+type commandTask struct {
+	fn       func(queued time.Duration)
+	enqueued time.Time
+	done     chan struct{}
+}
+
+// commandPool runs commands on a fixed number of workers behind a bounded
+// queue. redcon hands a connection its next pipelined command only after
+// the previous one returns, and run waits for the command to finish, so
+// each connection has at most one command in the pool and pipelined
+// commands keep their order; the rest of a pipeline waits in the
+// connection's read buffer.
+type commandPool struct {
+	tasks  chan *commandTask
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// newCommandPool starts workers goroutines that take commands from a queue
+// holding up to queueSize waiting commands
+func newCommandPool(workers, queueSize int) *commandPool {
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &commandPool{tasks: make(chan *commandTask, queueSize)}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// run queues fn and waits for it to finish. fn is given how long it waited
+// for a worker. run returns ErrBusy without queueing fn when no worker is
+// free and the queue is full.
+func (p *commandPool) run(fn func(queued time.Duration)) error {
+	task := &commandTask{fn: fn, enqueued: time.Now(), done: make(chan struct{})}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return errors.New("server is shutting down")
+	}
+	select {
+	case p.tasks <- task:
+	default:
+		p.mu.RUnlock()
+		return ErrBusy
+	}
+	p.mu.RUnlock()
+
+	<-task.done
+	return nil
+}
+
+// close stops accepting commands and waits for queued ones to finish
+func (p *commandPool) close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.tasks)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// worker runs queued commands until the pool is closed
+func (p *commandPool) worker() {
+	defer p.wg.Done()
+
+	for task := range p.tasks {
+		task.fn(time.Since(task.enqueued))
+		close(task.done)
+	}
+}
@@ -38,7 +38,9 @@ func NewIntResponse(value int64) *Response {
 	}
 }
 
-// NewNestedArrayResponse creates a nested array response
+// NewNestedArrayResponse creates a nested array response. Each value is a
+// []string, written as an array of bulk strings, a string, written as a bulk
+// string, or nil, written as null.
 func NewNestedArrayResponse(values []interface{}) *Response {
 	return &Response{
 		Type:             ResponseTypeNestedArray,
@@ -1,30 +1,103 @@
 package redis
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/tidwall/redcon"
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/jobs"
+	"github.com/ywadi/PathwayDB/logging"
+	"github.com/ywadi/PathwayDB/redis/commands"
 	"github.com/ywadi/PathwayDB/redis/protocol"
 	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
+// tracingShutdownTimeout bounds how long Stop waits to export spans
+const tracingShutdownTimeout = 5 * time.Second
+
 // Server represents the Redis protocol server for PathwayDB
 type Server struct {
 	config  *Config
 	storage storage.StorageEngine
 	handler *CommandHandler
+	pool    *commandPool
+	logger  *slog.Logger
 	mu      sync.RWMutex
 	running bool
+	// tracerProvider is the provider EnableTracing created, shut down with
+	// the server to flush its spans
+	tracerProvider *sdktrace.TracerProvider
+	// statsHistory records stats snapshots when EnableStatsHistory is set
+	statsHistory *analysis.StatsHistoryRecorder
+	// events delivers node and edge events to GRAPH.SUBSCRIBE connections
+	events *eventHub
 }
 
-// NewServer creates a new Redis protocol server
-func NewServer(config *Config, storageEngine storage.StorageEngine) *Server {
+// NewServer creates a new Redis protocol server. Without WithLogger, a
+// logger is built from config.LogLevel.
+func NewServer(config *Config, storageEngine storage.StorageEngine, opts ...Option) *Server {
+	o := applyOptions(opts)
+	if o.logger == nil {
+		level, err := logging.ParseLevel(config.LogLevel)
+		if err != nil {
+			level = slog.LevelInfo
+		}
+		if config.Debug {
+			level = slog.LevelDebug
+		}
+		o.logger = logging.New(level)
+	}
+	var owned *sdktrace.TracerProvider
+	if o.tracerProvider == nil && config.EnableTracing {
+		provider, err := tracing.NewProvider(context.Background(), config.TracingEndpoint)
+		if err != nil {
+			o.logger.Error("tracing disabled", "error", err)
+		} else {
+			o.tracerProvider, owned = provider, provider
+		}
+	}
 	server := &Server{
 		config:  config,
 		storage: storageEngine,
-		handler: NewCommandHandler(storageEngine),
+		handler: NewCommandHandler(storageEngine,
+			WithLogger(o.logger),
+			WithAdminPassword(config.AdminPassword),
+			WithJobConfig(jobs.Config{
+				Workers:    config.JobWorkers,
+				MaxActive:  config.MaxActiveJobs,
+				ResultTTL:  config.JobResultTTL,
+				MaxResults: config.MaxJobResults,
+			}),
+			WithTransferTimeout(config.TransferTimeout),
+			WithAnalysisLimits(config.AnalysisLimits),
+			WithSortLimit(config.MaxSortResults),
+			WithTracerProvider(o.tracerProvider),
+		),
+		logger:         o.logger,
+		tracerProvider: owned,
+		events:         newEventHub(storageEngine, o.logger),
+	}
+	if config.TrackReads {
+		storageEngine.SetReadTracking(true)
+	}
+	if o.tracerProvider != nil && config.StorageTraceSampleRate > 0 {
+		storageEngine.SetTracing(o.tracerProvider, config.StorageTraceSampleRate)
+	}
+	if config.EnableStatsHistory {
+		server.statsHistory = analysis.NewStatsHistoryRecorder(storageEngine, config.StatsHistoryInterval, config.StatsHistoryDays, o.logger)
+		server.statsHistory.Start()
+	}
+	if config.MaxConcurrentCommands > 0 {
+		server.pool = newCommandPool(config.MaxConcurrentCommands, config.CommandQueueSize)
 	}
 	return server
 }
@@ -39,59 +112,174 @@ func (s *Server) Start() error {
 	s.running = true
 	s.mu.Unlock()
 
-	log.Printf("Starting PathwayDB Redis server on %s", s.config.Address)
+	s.logger.Info("Starting PathwayDB Redis server", "address", s.config.Address)
+
+	ln, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return err
+	}
+	return redcon.Serve(&inlineListener{ln},
+		s.handleConnection,
+		s.handleAccept,
+		s.handleClosed,
+	)
+}
+
+// Serve serves Redis connections accepted from ln until it is closed
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+
+	s.logger.Info("Starting PathwayDB Redis server", "address", ln.Addr().String())
 
-	return redcon.ListenAndServe(s.config.Address,
+	return redcon.Serve(&inlineListener{ln},
 		s.handleConnection,
 		s.handleAccept,
 		s.handleClosed,
 	)
 }
 
-// Stop stops the Redis protocol server
+// Stop stops the Redis protocol server and the stats history recorder,
+// waits for the commands queued on the command pool and stops its workers,
+// and flushes the spans of a tracer provider created for EnableTracing.
+// Commands received after Stop fail.
 func (s *Server) Stop() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.running = false
+	if s.pool != nil {
+		s.pool.close()
+	}
+	if s.statsHistory != nil {
+		s.statsHistory.Stop()
+		s.statsHistory = nil
+	}
+	if s.tracerProvider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := s.tracerProvider.Shutdown(ctx); err != nil {
+			s.logger.Warn("failed to flush spans", "error", err)
+		}
+		s.tracerProvider = nil
+	}
 }
 
 // handleConnection handles incoming Redis commands
 func (s *Server) handleConnection(conn redcon.Conn, cmd redcon.Command) {
+	session, _ := conn.Context().(*commands.Session)
+	if session == nil {
+		session = s.newSession(conn)
+		conn.SetContext(session)
+	}
+	response, reply := s.execute(conn.RemoteAddr(), session, cmd)
+	s.reply(conn, response, reply)
+
+	// A subscribed connection is served by serveSubscriber from now on
+	if sub := subscriberOf(session); sub != nil && sub.subscribed() && !sub.detached {
+		s.serveSubscriber(conn, session, sub)
+	}
+}
+
+// execute runs a command for the connection at client and returns its
+// response, or the error reply to send in its place
+func (s *Server) execute(client string, session *commands.Session, cmd redcon.Command) (*Response, string) {
 	// Parse command
 	if len(cmd.Args) == 0 {
-		conn.WriteError("ERR empty command")
-		return
+		return nil, "ERR empty command"
 	}
 
-	command := strings.ToUpper(string(cmd.Args[0]))
+	command := commands.NormalizeCommand(string(cmd.Args[0]))
 	args := make([]string, len(cmd.Args)-1)
 	for i, arg := range cmd.Args[1:] {
 		args[i] = string(arg)
 	}
 
 	// Route command to handler
-	response, err := s.handler.Handle(command, args)
+	logger := s.logger.With("client", client)
+	var response *Response
+	var err error
+	if s.pool == nil {
+		response, err = s.handler.handle(logger, session, command, args, 0)
+	} else if poolErr := s.pool.run(func(queued time.Duration) {
+		response, err = s.handler.handle(logger, session, command, args, queued)
+	}); poolErr != nil {
+		err = poolErr
+	}
+	if errors.Is(err, ErrBusy) {
+		logger.Warn("command rejected", "command", command, "error", err)
+		return nil, err.Error()
+	}
 	if err != nil {
-		conn.WriteError("ERR " + err.Error())
-		return
+		return nil, ErrorReply(err)
 	}
 
-	// Write response
+	if s.config.HumanReadable {
+		logReply(logger, command, response)
+	}
+	return response, ""
+}
+
+// reply writes the response of a command, or the error reply execute
+// returned in its place
+func (s *Server) reply(conn redcon.Conn, response *Response, reply string) {
+	if reply != "" {
+		conn.WriteError(reply)
+		return
+	}
 	s.writeResponse(conn, response)
 }
 
+// carriesCode reports whether err starts with the code of one of
+// commands.CodedErrors. Handlers that wrap such an error in a message of their own
+// get the ERR prefix, as errors.Is alone would send them without a code.
+func carriesCode(err error) bool {
+	for _, coded := range commands.CodedErrors {
+		if errors.Is(err, coded) && strings.HasPrefix(err.Error(), coded.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorReply returns the error reply sent for a command failing with err:
+// the message alone if it starts with its own code, or else ERR and the
+// message. Clients running the command handler in-process, such as the
+// IDE's embedded mode, use it to answer as the server would.
+func ErrorReply(err error) string {
+	if carriesCode(err) {
+		return err.Error()
+	}
+	return "ERR " + err.Error()
+}
+
 // handleAccept handles new client connections
 func (s *Server) handleAccept(conn redcon.Conn) bool {
-	log.Printf("Client connected: %s", conn.RemoteAddr())
+	s.logger.Debug("Client connected", "client", conn.RemoteAddr())
+	conn.SetContext(s.newSession(conn))
 	return true
 }
 
+// newSession creates the session of a connection
+func (s *Server) newSession(conn redcon.Conn) *commands.Session {
+	return &commands.Session{Client: conn.RemoteAddr(), Events: newSubscriber(s.events, conn)}
+}
+
 // handleClosed handles client disconnections
 func (s *Server) handleClosed(conn redcon.Conn, err error) {
+	session, _ := conn.Context().(*commands.Session)
+	if sub := subscriberOf(session); sub != nil && sub.detached {
+		// Redcon reports a detached connection as closing with an error
+		// once serveSubscriber returns
+		err = nil
+	}
 	if err != nil {
-		log.Printf("Client disconnected with error: %s, error: %v", conn.RemoteAddr(), err)
+		s.logger.Debug("Client disconnected with error", "client", conn.RemoteAddr(), "error", err)
 	} else {
-		log.Printf("Client disconnected: %s", conn.RemoteAddr())
+		s.logger.Debug("Client disconnected", "client", conn.RemoteAddr())
+	}
+	if session != nil {
+		s.handler.CloseSession(session)
 	}
 }
 
@@ -115,6 +303,10 @@ func (s *Server) writeResponse(conn redcon.Conn, response *Response) {
 				for _, item := range sa {
 					conn.WriteBulkString(item)
 				}
+			} else if item, ok := subArray.(string); ok {
+				conn.WriteBulkString(item)
+			} else if subArray == nil {
+				conn.WriteNull()
 			} else {
 				conn.WriteError("ERR invalid nested array format")
 			}
@@ -130,6 +322,50 @@ func (s *Server) writeResponse(conn redcon.Conn, response *Response) {
 	}
 }
 
+// maxLoggedItems bounds the items logReply writes for one reply
+const maxLoggedItems = 100
+
+// logReply logs array replies for HumanReadable, one numbered attribute per
+// item, so a reply read over telnet can be matched to the server log.
+// Nested arrays are numbered by position, as in 2.1 for the first item of
+// the second array.
+func logReply(logger *slog.Logger, command string, response *Response) {
+	var items []any
+	switch response.Type {
+	case protocol.ResponseTypeArray:
+		items = replyItems(response.ArrayValue)
+	case protocol.ResponseTypeNestedArray:
+		for i, subArray := range response.NestedArrayValue {
+			if i == maxLoggedItems {
+				items = append(items, slog.Int("more", len(response.NestedArrayValue)-i))
+				break
+			}
+			if item, ok := subArray.(string); ok {
+				items = append(items, slog.String(strconv.Itoa(i+1), item))
+				continue
+			}
+			sa, _ := subArray.([]string)
+			items = append(items, slog.Group(strconv.Itoa(i+1), replyItems(sa)...))
+		}
+	default:
+		return
+	}
+	logger.Info("reply", append([]any{"command", command}, items...)...)
+}
+
+// replyItems returns the numbered attributes of an array reply
+func replyItems(values []string) []any {
+	items := make([]any, 0, len(values))
+	for i, value := range values {
+		if i == maxLoggedItems {
+			items = append(items, slog.Int("more", len(values)-i))
+			break
+		}
+		items = append(items, slog.String(strconv.Itoa(i+1), value))
+	}
+	return items
+}
+
 // IsRunning returns whether the server is currently running
 func (s *Server) IsRunning() bool {
 	s.mu.RLock()
@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// maxCommandStats bounds the number of distinct command names tracked, so
+// clients sending made-up commands cannot grow the table without limit
+const maxCommandStats = 1024
+
+// commandStat accumulates the calls to one command
+type commandStat struct {
+	calls  int64
+	failed int64
+	exec   time.Duration
+	queued time.Duration
+}
+
+// commandStats records per-command call counts and timings. Time spent
+// waiting for a command worker is kept apart from execution time.
+type commandStats struct {
+	mu    sync.Mutex
+	stats map[string]*commandStat
+}
+
+// newCommandStats creates an empty set of command statistics
+func newCommandStats() *commandStats {
+	return &commandStats{stats: make(map[string]*commandStat)}
+}
+
+// record adds one call to command
+func (c *commandStats) record(command string, queued, exec time.Duration, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stat, ok := c.stats[command]
+	if !ok {
+		if len(c.stats) >= maxCommandStats {
+			return
+		}
+		stat = &commandStat{}
+		c.stats[command] = stat
+	}
+	stat.calls++
+	stat.exec += exec
+	stat.queued += queued
+	if failed {
+		stat.failed++
+	}
+}
+
+// cacheLines formats the record cache statistics for the cache section of
+// INFO, with the share of reads served from the cache as hit_rate
+func cacheLines(stats storage.CacheStats) []string {
+	enabled := 0
+	if stats.Enabled {
+		enabled = 1
+	}
+	hitRate := 0.0
+	if reads := stats.Hits + stats.Misses; reads > 0 {
+		hitRate = float64(stats.Hits) / float64(reads)
+	}
+	return []string{
+		fmt.Sprintf("cache_enabled:%d", enabled),
+		fmt.Sprintf("cache_entries:%d", stats.Entries),
+		fmt.Sprintf("cache_bytes:%d", stats.Bytes),
+		fmt.Sprintf("cache_max_entries:%d", stats.MaxEntries),
+		fmt.Sprintf("cache_max_bytes:%d", stats.MaxBytes),
+		fmt.Sprintf("cache_hits:%d", stats.Hits),
+		fmt.Sprintf("cache_misses:%d", stats.Misses),
+		fmt.Sprintf("cache_hit_rate:%.4f", hitRate),
+		fmt.Sprintf("cache_evictions:%d", stats.Evictions),
+		fmt.Sprintf("cache_invalidations:%d", stats.Invalidations),
+	}
+}
+
+// compressionLines formats the record compression statistics for the
+// compression section of INFO
+func compressionLines(stats storage.CompressionStats) []string {
+	enabled := 0
+	if stats.Enabled {
+		enabled = 1
+	}
+	return []string{
+		fmt.Sprintf("compression_enabled:%d", enabled),
+		fmt.Sprintf("compression_above_bytes:%d", stats.Above),
+		fmt.Sprintf("compression_values:%d", stats.Compressed),
+		fmt.Sprintf("compression_bytes_saved:%d", stats.BytesSaved),
+	}
+}
+
+// lines formats the statistics like the commandstats section of Redis INFO,
+// with the queue wait added as queue_usec and queue_usec_per_call
+func (c *commandStats) lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.stats))
+	for name := range c.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		stat := c.stats[name]
+		usec := stat.exec.Microseconds()
+		queueUsec := stat.queued.Microseconds()
+		lines = append(lines, fmt.Sprintf("cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,queue_usec=%d,queue_usec_per_call=%.2f,failed_calls=%d",
+			strings.ToLower(name), stat.calls,
+			usec, float64(usec)/float64(stat.calls),
+			queueUsec, float64(queueUsec)/float64(stat.calls),
+			stat.failed))
+	}
+	return lines
+}
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts the span of a command, named after it, and sets it as
+// the session's context so the command's own spans become its children. It
+// returns nil when tracing is disabled.
+func (h *CommandHandler) startSpan(session *commands.Session, command, graph string, args []string, queued time.Duration) trace.Span {
+	if h.tracer == nil {
+		return nil
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("command", command),
+		attribute.Int("args", len(args)),
+	}
+	if graph != "" {
+		attrs = append(attrs, attribute.String("graph", graph))
+	}
+	if queued > 0 {
+		attrs = append(attrs, attribute.Int64("queued_us", queued.Microseconds()))
+	}
+	ctx, span := h.tracer.Start(session.Context(), command, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	session.SetContext(ctx)
+	return span
+}
+
+// endSpan records the outcome of a command on its span, ends it and clears
+// the session's context
+func (h *CommandHandler) endSpan(session *commands.Session, span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	session.SetContext(nil)
+	if err != nil {
+		span.SetAttributes(attribute.String("error", err.Error()))
+	}
+	tracing.End(span, err)
+}
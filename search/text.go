@@ -0,0 +1,89 @@
+// Package search implements attribute searches that scan graph data
+// without an index.
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flatten calls fn with the path and string form of every scalar value in
+// attrs. Nested object keys are joined with dots and array elements are
+// addressed as key[i], e.g. "config.endpoints[1]". Keys are visited in
+// sorted order. Numbers use their shortest decimal form, so 8080 is "8080";
+// null values are skipped. Flatten stops as soon as fn returns false and
+// reports whether it ran to completion.
+func Flatten(attrs map[string]interface{}, fn func(key, value string) bool) bool {
+	return flattenMap("", attrs, fn)
+}
+
+// flattenMap visits the entries of an object in key order
+func flattenMap(prefix string, m map[string]interface{}, fn func(key, value string) bool) bool {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if !flattenValue(path, m[key], fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// flattenValue visits a single value, descending into objects and arrays
+func flattenValue(path string, value interface{}, fn func(key, value string) bool) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return flattenMap(path, v, fn)
+	case []interface{}:
+		for i, item := range v {
+			if !flattenValue(fmt.Sprintf("%s[%d]", path, i), item, fn) {
+				return false
+			}
+		}
+		return true
+	case string:
+		return fn(path, v)
+	case float64:
+		return fn(path, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		return fn(path, strconv.FormatBool(v))
+	default:
+		return fn(path, fmt.Sprint(v))
+	}
+}
+
+// Matcher finds a case-insensitive substring in attribute values
+type Matcher struct {
+	needle string
+}
+
+// NewMatcher creates a matcher for text
+func NewMatcher(text string) *Matcher {
+	return &Matcher{needle: strings.ToLower(text)}
+}
+
+// Match returns the path of the first attribute value, in Flatten order,
+// that contains the matcher's text
+func (m *Matcher) Match(attrs map[string]interface{}) (string, bool) {
+	var found string
+	complete := Flatten(attrs, func(key, value string) bool {
+		if strings.Contains(strings.ToLower(value), m.needle) {
+			found = key
+			return false
+		}
+		return true
+	})
+	return found, !complete
+}
@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// ActivityHours is the number of hourly buckets of mutation counts kept per
+// graph, one week
+const ActivityHours = 168
+
+// activityFlushInterval is how often changed activity buckets are written
+const activityFlushInterval = 30 * time.Second
+
+// ActivityKind is a category of mutation counted by RecordActivity
+type ActivityKind int
+
+// Mutation categories, in the order of ActivityBucket.Counts
+const (
+	ActivityNodeCreate ActivityKind = iota
+	ActivityNodeUpdate
+	ActivityNodeDelete
+	ActivityEdgeCreate
+	ActivityEdgeUpdate
+	ActivityEdgeDelete
+
+	// ActivityKinds is the number of mutation categories
+	ActivityKinds = 6
+)
+
+// ActivityBucket holds the mutation counts of one hour of a graph
+type ActivityBucket struct {
+	Start  time.Time
+	Counts [ActivityKinds]uint64
+}
+
+// activityRing holds the last ActivityHours hourly buckets of a graph. Slot
+// i counts the hour whose Unix hour number is i modulo ActivityHours; a slot
+// is reset when the hour it holds falls out of the week. Counters are
+// incremented under the read lock; the write lock is only taken to reset a
+// slot.
+type activityRing struct {
+	mu     sync.RWMutex
+	hours  [ActivityHours]int64
+	counts [ActivityHours][ActivityKinds]atomic.Uint64
+	dirty  atomic.Bool
+}
+
+// add counts n mutations of a kind in an hour. Counts for an hour older
+// than the one its slot already holds are dropped.
+func (r *activityRing) add(hour int64, kind ActivityKind, n uint64) {
+	slot := hour % ActivityHours
+	r.mu.RLock()
+	current := r.hours[slot] == hour
+	if current {
+		r.counts[slot][kind].Add(n)
+	}
+	r.mu.RUnlock()
+
+	if !current {
+		r.mu.Lock()
+		if r.hours[slot] < hour {
+			r.hours[slot] = hour
+			for i := range r.counts[slot] {
+				r.counts[slot][i].Store(0)
+			}
+		}
+		if r.hours[slot] == hour {
+			r.counts[slot][kind].Add(n)
+		}
+		r.mu.Unlock()
+	}
+	r.dirty.Store(true)
+}
+
+// bucket returns the counts of an hour, zero if its slot holds another hour
+func (r *activityRing) bucket(hour int64) ActivityBucket {
+	bucket := ActivityBucket{Start: time.Unix(hour*3600, 0).UTC()}
+	slot := hour % ActivityHours
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.hours[slot] == hour {
+		for i := range bucket.Counts {
+			bucket.Counts[i] = r.counts[slot][i].Load()
+		}
+	}
+	return bucket
+}
+
+// encode writes the non-empty buckets as a Unix hour number followed by
+// their counts, all as uvarints
+func (r *activityRing) encode() []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var value []byte
+	for slot := range r.hours {
+		var counts [ActivityKinds]uint64
+		empty := true
+		for i := range counts {
+			counts[i] = r.counts[slot][i].Load()
+			empty = empty && counts[i] == 0
+		}
+		if empty {
+			continue
+		}
+		value = binary.AppendUvarint(value, uint64(r.hours[slot]))
+		for _, count := range counts {
+			value = binary.AppendUvarint(value, count)
+		}
+	}
+	return value
+}
+
+// decode restores the buckets written by encode
+func (r *activityRing) decode(value []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(value) > 0 {
+		var fields [1 + ActivityKinds]uint64
+		for i := range fields {
+			field, n := binary.Uvarint(value)
+			if n <= 0 {
+				return fmt.Errorf("truncated activity bucket")
+			}
+			fields[i], value = field, value[n:]
+		}
+		hour := int64(fields[0])
+		slot := hour % ActivityHours
+		if hour < r.hours[slot] {
+			continue
+		}
+		r.hours[slot] = hour
+		for i := range r.counts[slot] {
+			r.counts[slot][i].Store(fields[1+i])
+		}
+	}
+	return nil
+}
+
+// activityTracker holds the activity rings of the graphs accessed since the
+// database was opened. Rings are loaded from their stored key on first use
+// and written back by the flusher when they change.
+type activityTracker struct {
+	mu    sync.RWMutex
+	rings map[models.GraphID]*activityRing
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// activityRing returns the activity ring of a graph, loading it if needed
+func (e *BadgerEngine) activityRing(graphID models.GraphID) (*activityRing, error) {
+	e.activity.mu.RLock()
+	ring, exists := e.activity.rings[graphID]
+	e.activity.mu.RUnlock()
+	if exists {
+		return ring, nil
+	}
+
+	ring = &activityRing{}
+	value, err := e.get(utils.EncodeActivityKey(graphID))
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, fmt.Errorf("failed to read activity: %w", err)
+	}
+	if err := ring.decode(value); err != nil {
+		return nil, fmt.Errorf("failed to decode activity: %w", err)
+	}
+
+	e.activity.mu.Lock()
+	defer e.activity.mu.Unlock()
+	if loaded, exists := e.activity.rings[graphID]; exists {
+		return loaded, nil
+	}
+	if e.activity.rings == nil {
+		e.activity.rings = make(map[models.GraphID]*activityRing)
+	}
+	e.activity.rings[graphID] = ring
+	return ring, nil
+}
+
+// forgetActivity drops the in-memory activity of a graph, or of every graph
+// when graphID is empty
+func (e *BadgerEngine) forgetActivity(graphID models.GraphID) {
+	e.activity.mu.Lock()
+	defer e.activity.mu.Unlock()
+	if graphID == "" {
+		e.activity.rings = nil
+		return
+	}
+	delete(e.activity.rings, graphID)
+}
+
+// RecordActivity counts n mutations of a kind in the current hour of a
+// graph's activity. The command layer calls it after each successful write.
+func (e *BadgerEngine) RecordActivity(graphID models.GraphID, kind ActivityKind, n int) {
+	if e.db == nil || n <= 0 || kind < 0 || kind >= ActivityKinds {
+		return
+	}
+	ring, err := e.activityRing(graphID)
+	if err != nil {
+		e.logger.Warn("Failed to load activity", "graph", graphID, "error", err)
+		return
+	}
+	ring.add(e.clock().Unix()/3600, kind, uint64(n))
+}
+
+// Activity returns the mutation counts of a graph for the last hours hours,
+// up to ActivityHours, oldest first. The last bucket is the current hour.
+func (e *BadgerEngine) Activity(graphID models.GraphID, hours int) ([]ActivityBucket, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+	if hours < 1 || hours > ActivityHours {
+		return nil, fmt.Errorf("hours must be between 1 and %d", ActivityHours)
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+
+	ring, err := e.activityRing(graphID)
+	if err != nil {
+		return nil, err
+	}
+	now := e.clock().Unix() / 3600
+	buckets := make([]ActivityBucket, 0, hours)
+	for hour := now - int64(hours) + 1; hour <= now; hour++ {
+		buckets = append(buckets, ring.bucket(hour))
+	}
+	return buckets, nil
+}
+
+// startActivityFlusher periodically writes changed activity rings until
+// stopActivityFlusher is called
+func (e *BadgerEngine) startActivityFlusher() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	e.activity.stop, e.activity.done = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(activityFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := e.flushActivity(); err != nil {
+					e.logger.Warn("Failed to flush activity", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopActivityFlusher stops the flusher and writes the remaining changes
+func (e *BadgerEngine) stopActivityFlusher() {
+	if e.activity.done == nil {
+		return
+	}
+	close(e.activity.stop)
+	<-e.activity.done
+	e.activity.done = nil
+	if err := e.flushActivity(); err != nil {
+		e.logger.Warn("Failed to flush activity", "error", err)
+	}
+}
+
+// flushActivity writes the activity rings that changed since the last
+// flush. Rings of graphs deleted in the meantime are not written back.
+func (e *BadgerEngine) flushActivity() error {
+	e.activity.mu.RLock()
+	graphIDs := make([]models.GraphID, 0, len(e.activity.rings))
+	for graphID, ring := range e.activity.rings {
+		if ring.dirty.Load() {
+			graphIDs = append(graphIDs, graphID)
+		}
+	}
+	e.activity.mu.RUnlock()
+	if len(graphIDs) == 0 {
+		return nil
+	}
+	sort.Slice(graphIDs, func(i, j int) bool { return graphIDs[i] < graphIDs[j] })
+
+	var flushed []*activityRing
+	err := e.db.Update(func(txn *badger.Txn) error {
+		for _, graphID := range graphIDs {
+			e.activity.mu.RLock()
+			ring, exists := e.activity.rings[graphID]
+			e.activity.mu.RUnlock()
+			if !exists || !ring.dirty.Swap(false) {
+				continue
+			}
+			flushed = append(flushed, ring)
+			if _, err := txn.Get(utils.EncodeGraphKey(graphID)); err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+			if err := txn.Set(utils.EncodeActivityKey(graphID), ring.encode()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Keep the rings for the next flush
+		for _, ring := range flushed {
+			ring.dirty.Store(true)
+		}
+		return fmt.Errorf("failed to store activity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// ErrAliasConflict is returned when an alias already names another node, or
+// when an alias and a node ID would be the same
+var ErrAliasConflict = errors.New("alias conflict")
+
+// AddNodeAlias makes alias a second ID of a node. An alias names exactly
+// one node and may not be the ID of a node in the graph. Adding an alias the
+// node already has does nothing.
+func (e *BadgerEngine) AddNodeAlias(graphID models.GraphID, nodeID models.NodeID, alias string) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+	if err := models.ValidateAlias(alias); err != nil {
+		return err
+	}
+
+	return e.update(func(tx *BadgerTransaction) error {
+		if _, err := tx.GetNode(graphID, nodeID); err != nil {
+			return err
+		}
+		if _, err := tx.GetNode(graphID, models.NodeID(alias)); err == nil {
+			return fmt.Errorf("%w: %s is the ID of a node", ErrAliasConflict, alias)
+		}
+		owner, err := tx.aliasOwner(graphID, alias)
+		if err != nil {
+			return err
+		}
+		if owner == nodeID {
+			return nil
+		}
+		if owner != "" {
+			return fmt.Errorf("%w: %s is an alias of node %s", ErrAliasConflict, alias, owner)
+		}
+
+		if err := tx.set(utils.EncodeAliasKey(graphID, alias), []byte(nodeID)); err != nil {
+			return fmt.Errorf("failed to store alias: %w", err)
+		}
+		if err := tx.set(utils.EncodeAliasIndexKey(graphID, nodeID, alias), []byte(alias)); err != nil {
+			return fmt.Errorf("failed to index alias: %w", err)
+		}
+		return nil
+	})
+}
+
+// RemoveNodeAlias removes an alias of a node and reports whether the node
+// had it
+func (e *BadgerEngine) RemoveNodeAlias(graphID models.GraphID, nodeID models.NodeID, alias string) (bool, error) {
+	if e.db == nil {
+		return false, ErrClosed
+	}
+
+	removed := false
+	err := e.update(func(tx *BadgerTransaction) error {
+		owner, err := tx.aliasOwner(graphID, alias)
+		if err != nil || owner != nodeID {
+			return err
+		}
+		removed = true
+		return tx.removeAlias(graphID, nodeID, alias)
+	})
+	if err != nil {
+		return false, err
+	}
+	return removed, nil
+}
+
+// ListNodeAliases returns the aliases of a node in alias order
+func (e *BadgerEngine) ListNodeAliases(graphID models.GraphID, nodeID models.NodeID) ([]string, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	aliases := []string{}
+	err := e.db.View(func(txn *badger.Txn) error {
+		tx := e.newTransaction(txn)
+		if _, err := tx.GetNode(graphID, nodeID); err != nil {
+			return err
+		}
+		var err error
+		aliases, err = tx.nodeAliases(graphID, nodeID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// ResolveNodeID returns id if a node has that ID, or else the ID of the
+// node id is an alias of. An id that is neither is returned unchanged, so
+// the caller reports the missing node as usual.
+func (e *BadgerEngine) ResolveNodeID(graphID models.GraphID, id models.NodeID) (models.NodeID, error) {
+	if e.db == nil {
+		return "", ErrClosed
+	}
+
+	resolved := id
+	err := e.db.View(func(txn *badger.Txn) error {
+		tx := e.newTransaction(txn)
+		if _, err := tx.get(utils.EncodeNodeKey(graphID, id)); err != badger.ErrKeyNotFound {
+			return err
+		}
+		owner, err := tx.aliasOwner(graphID, string(id))
+		if owner != "" {
+			resolved = owner
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve node ID: %w", err)
+	}
+	return resolved, nil
+}
+
+// aliasOwner returns the node an alias names, or "" if it names none
+func (t *BadgerTransaction) aliasOwner(graphID models.GraphID, alias string) (models.NodeID, error) {
+	value, err := t.get(utils.EncodeAliasKey(graphID, alias))
+	if err == badger.ErrKeyNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read alias: %w", err)
+	}
+	return models.NodeID(value), nil
+}
+
+// nodeAliases returns the aliases of a node. The index prefix of a node
+// also covers nodes whose IDs extend it past a ":", so each alias is checked
+// against the node it names.
+func (t *BadgerTransaction) nodeAliases(graphID models.GraphID, nodeID models.NodeID) ([]string, error) {
+	prefix := utils.CreateAliasIndexIteratorPrefix(graphID, nodeID)
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	aliases := []string{}
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		value, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read alias index: %w", err)
+		}
+		alias := string(value)
+		if string(it.Item().Key()[len(prefix):]) != alias {
+			continue
+		}
+		owner, err := t.aliasOwner(graphID, alias)
+		if err != nil {
+			return nil, err
+		}
+		if owner == nodeID {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases, nil
+}
+
+// removeAlias deletes an alias and its index entry
+func (t *BadgerTransaction) removeAlias(graphID models.GraphID, nodeID models.NodeID, alias string) error {
+	if err := t.delete(utils.EncodeAliasKey(graphID, alias)); err != nil {
+		return fmt.Errorf("failed to delete alias: %w", err)
+	}
+	if err := t.delete(utils.EncodeAliasIndexKey(graphID, nodeID, alias)); err != nil {
+		return fmt.Errorf("failed to delete alias index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// attributeIndex names the attribute index for SYSTEM.REINDEX
+const attributeIndex = "attributes"
+
+// maxAttributeEntrySize bounds the attribute key and encoded value of an
+// attribute index entry. Longer attributes are not indexed, and nodes are
+// found by them with a scan.
+const maxAttributeEntrySize = 512
+
+// DefaultMaxAttributeKeyLength is the longest attribute key, in bytes,
+// writes accept by default
+const DefaultMaxAttributeKeyLength = 256
+
+// attributeKeyPolicy is how writes check the attribute keys of graphs,
+// nodes and edges against models.AttributeKeyProblem, and their values
+// against models.ValidateAttributes
+type attributeKeyPolicy struct {
+	maxLength int
+	// limits bounds the nesting, key count and string length of values
+	limits models.AttributeLimits
+	// strict also checks keys the entity already held, and values it held
+	// unchanged, which are otherwise let through so entities written before
+	// the policy stay editable
+	strict bool
+}
+
+// check validates the keys of attributes, in key order, skipping the keys
+// of held unless the policy is strict, and then their values, skipping the
+// values held unchanged. kind names the entity in the error.
+func (p attributeKeyPolicy) check(kind string, attributes, held models.Attributes) error {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		if _, ok := held[key]; ok && !p.strict {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := models.ValidateAttributeKey(kind, key, p.maxLength); err != nil {
+			return err
+		}
+	}
+
+	changed := attributes
+	if len(held) > 0 && !p.strict {
+		changed = make(models.Attributes, len(attributes))
+		for key, value := range attributes {
+			if heldValue, ok := held[key]; !ok || !models.ValuesEqual(value, heldValue) {
+				changed[key] = value
+			}
+		}
+	}
+	return models.ValidateAttributes(kind, changed, p.limits)
+}
+
+// attributeIndexValue encodes an attribute value for the attribute index,
+// so values that compare equal once normalized, such as 5 and 5.0, share an
+// entry. It reports false for attributes that are not indexed.
+func attributeIndexValue(attrKey string, value interface{}) (string, bool) {
+	encoded, err := models.CanonicalJSON(models.NormalizeValue(value))
+	if err != nil || len(attrKey)+len(encoded) > maxAttributeEntrySize {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+// indexNodeAttributes adds an attribute index entry for each indexed
+// attribute of a node and returns how many it added
+func (t *BadgerTransaction) indexNodeAttributes(graphID models.GraphID, node *models.Node) (int, error) {
+	added := 0
+	for attrKey, value := range node.Attributes {
+		encoded, ok := attributeIndexValue(attrKey, value)
+		if !ok {
+			continue
+		}
+		key := utils.EncodeAttributeIndexKey(graphID, "n", attrKey, encoded, string(node.ID))
+		if err := t.set(key, []byte(node.ID)); err != nil {
+			return added, fmt.Errorf("failed to create attribute index: %w", err)
+		}
+		added++
+	}
+	return added, nil
+}
+
+// unindexNodeAttributes removes the attribute index entries of a node
+func (t *BadgerTransaction) unindexNodeAttributes(graphID models.GraphID, node *models.Node) error {
+	for attrKey, value := range node.Attributes {
+		encoded, ok := attributeIndexValue(attrKey, value)
+		if !ok {
+			continue
+		}
+		key := utils.EncodeAttributeIndexKey(graphID, "n", attrKey, encoded, string(node.ID))
+		if err := t.delete(key); err != nil {
+			return fmt.Errorf("failed to remove attribute index: %w", err)
+		}
+	}
+	return nil
+}
+
+// AttributeKeyViolation is a stored attribute key that breaks the attribute
+// key policy
+type AttributeKeyViolation struct {
+	// Entity is "graph", "node" or "edge"
+	Entity  string `json:"entity"`
+	ID      string `json:"id"`
+	Key     string `json:"key"`
+	Problem string `json:"problem"`
+}
+
+// AttributeKeyReport lists the attribute keys of a graph, its nodes and its
+// edges that writes would reject
+type AttributeKeyReport struct {
+	Graph      models.GraphID          `json:"graph"`
+	Nodes      int                     `json:"nodes"`
+	Edges      int                     `json:"edges"`
+	Violations []AttributeKeyViolation `json:"violations"`
+}
+
+// ValidateAttributeKeys scans a graph for attribute keys that break the
+// attribute key policy, so they can be cleaned up before strict checking
+// is enabled. The graph's own keys come first, then nodes and edges in ID
+// order, each entity's keys in key order.
+func (e *BadgerEngine) ValidateAttributeKeys(graphID models.GraphID) (*AttributeKeyReport, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+	graph, err := e.GetGraph(graphID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AttributeKeyReport{Graph: graphID, Violations: []AttributeKeyViolation{}}
+	checkKeys := func(entity string, id string, attributes models.Attributes) {
+		keys := make([]string, 0, len(attributes))
+		for key := range attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if problem := models.AttributeKeyProblem(key, e.attributeKeys.maxLength); problem != "" {
+				report.Violations = append(report.Violations, AttributeKeyViolation{
+					Entity: entity, ID: id, Key: key, Problem: problem,
+				})
+			}
+		}
+	}
+	checkKeys("graph", string(graphID), graph.Attributes)
+
+	err = e.db.View(func(txn *badger.Txn) error {
+		if err := iterateTxnPrefix(txn, utils.CreateNodeIteratorPrefix(graphID), func(value []byte) error {
+			node := &models.Node{}
+			if err := node.FromJSON(value); err != nil {
+				return fmt.Errorf("failed to deserialize node: %w", err)
+			}
+			report.Nodes++
+			checkKeys("node", string(node.ID), node.Attributes)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return iterateTxnPrefix(txn, utils.CreateEdgeIteratorPrefix(graphID), func(value []byte) error {
+			edge := &models.Edge{}
+			if err := edge.FromJSON(value); err != nil {
+				return fmt.Errorf("failed to deserialize edge: %w", err)
+			}
+			report.Edges++
+			checkKeys("edge", string(edge.ID), edge.Attributes)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate attribute keys: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// maxUnknownPrefixes bounds the distinct prefixes KeyAudit.UnknownPrefixes
+// tracks, so a keyspace full of unexpected keys cannot grow the report
+// without limit. Keys beyond it are counted under "(other)".
+const maxUnknownPrefixes = 64
+
+// KeyAudit describes the keyspace as the current build understands it
+type KeyAudit struct {
+	Prefix string `json:"prefix,omitempty"`
+	Keys   int64  `json:"keys"`
+	// Families counts the keys of each known key family, such as "n" for
+	// nodes or "ti:n" for the node type index
+	Families map[string]int64 `json:"families"`
+	// Unknown counts keys matching no family, grouped in UnknownPrefixes by
+	// the text before their first ":"
+	Unknown         int64            `json:"unknown"`
+	UnknownPrefixes map[string]int64 `json:"unknown_prefixes"`
+	// Orphaned counts graph-scoped keys of graphs that have no graph
+	// record, grouped in Orphans by family
+	Orphaned int64            `json:"orphaned"`
+	Orphans  map[string]int64 `json:"orphans"`
+	// Graphs counts the keys of each family per graph
+	Graphs     map[models.GraphID]map[string]int64 `json:"graphs"`
+	Mismatches []KeyAuditMismatch                  `json:"mismatches"`
+}
+
+// KeyAuditMismatch reports a graph whose entity count differs from the
+// number of entries in one of the indexes that should hold every entity
+type KeyAuditMismatch struct {
+	Graph    models.GraphID `json:"graph"`
+	Family   string         `json:"family"`
+	Index    string         `json:"index"`
+	Entities int64          `json:"entities"`
+	Entries  int64          `json:"entries"`
+}
+
+// keyScope is how a key family names the graph a key belongs to
+type keyScope int
+
+const (
+	scopeNone   keyScope = iota // Not graph-scoped
+	scopeExact                  // The rest of the key is the graph ID
+	scopeGraph                  // The rest of the key starts with the graph ID and ":"
+	scopeExpiry                 // A timestamp and ":" precede the graph ID
+)
+
+// keyFamily is one layout of key the current build reads
+type keyFamily struct {
+	name   string
+	prefix string
+	scope  keyScope
+}
+
+// keyFamilies lists every key layout the current build reads. Keys under
+// declared but unused prefixes, such as EdgeIndexPrefix, count as unknown.
+var keyFamilies = []keyFamily{
+	{"g", utils.GraphPrefix, scopeExact},
+	{"n", utils.NodePrefix, scopeGraph},
+	{"e", utils.EdgePrefix, scopeGraph},
+	{"ni:out", utils.NodeIndexPrefix + "out:", scopeGraph},
+	{"ni:in", utils.NodeIndexPrefix + "in:", scopeGraph},
+	{"ti:n", utils.TypeIndexPrefix + "n:", scopeGraph},
+	{"ti:e", utils.TypeIndexPrefix + "e:", scopeGraph},
+	{"xi", utils.ExpiryIndexPrefix, scopeExpiry},
+	{"xe", utils.EdgeExpiryPrefix, scopeExpiry},
+	{"q", utils.QueryPrefix, scopeNone},
+	{"s", utils.SnapshotPrefix, scopeGraph},
+	{"sd", utils.SnapshotDataPrefix, scopeGraph},
+	{"hr", utils.ReadCountPrefix, scopeGraph},
+	{"mr", utils.MaintenancePrefix, scopeExact},
+	{"m", utils.MetaPrefix, scopeGraph},
+	{"ai", utils.AttributePrefix, scopeGraph},
+	{"rx", utils.ReindexPrefix, scopeGraph},
+	{"al", utils.AliasPrefix, scopeGraph},
+	{"na", utils.AliasIndexPrefix, scopeGraph},
+	{"act", utils.ActivityPrefix, scopeExact},
+	{"gd", utils.DeletionPrefix, scopeExact},
+	{"gen", utils.GenerationPrefix, scopeExact},
+	{"sh", utils.StatsHistoryPrefix, scopeGraph},
+	{"dl", utils.DeletionLogPrefix, scopeGraph},
+	{"tc:n", utils.TypeCountPrefix + "n:", scopeGraph},
+	{"tc:e", utils.TypeCountPrefix + "e:", scopeGraph},
+	{"tc:g", utils.TypeCountPrefix + "g:", scopeExact},
+	{"gr", utils.RenamePrefix, scopeExact},
+}
+
+// indexChecks pairs entity families with the indexes holding one entry per
+// entity
+var indexChecks = []struct{ family, index string }{
+	{"n", "ti:n"},
+	{"e", "ti:e"},
+	{"e", "ni:out"},
+	{"e", "ni:in"},
+	{"al", "na"},
+}
+
+// AuditKeys scans the keys under prefix, or the whole keyspace if prefix is
+// empty, and reports them by key family and graph. Only keys are read, and
+// the scan keeps counts rather than keys, so it is safe on large databases.
+// Index counts are only compared when the whole keyspace is scanned, as a
+// prefix may cover an entity family but not its indexes.
+func (e *BadgerEngine) AuditKeys(prefix string) (*KeyAudit, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	audit := &KeyAudit{
+		Prefix:          prefix,
+		Families:        make(map[string]int64),
+		UnknownPrefixes: make(map[string]int64),
+		Orphans:         make(map[string]int64),
+		Graphs:          make(map[models.GraphID]map[string]int64),
+		Mismatches:      []KeyAuditMismatch{},
+	}
+
+	err := e.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false // Only keys are audited
+
+		// Graph records are read first, as they sort after the node and
+		// edge keys they own
+		graphPrefix := []byte(utils.GraphPrefix)
+		it := txn.NewIterator(opts)
+		for it.Seek(graphPrefix); it.ValidForPrefix(graphPrefix); it.Next() {
+			audit.Graphs[utils.DecodeGraphID(it.Item().Key())] = make(map[string]int64)
+		}
+		it.Close()
+
+		it = txn.NewIterator(opts)
+		defer it.Close()
+		scanPrefix := []byte(prefix)
+		for it.Seek(scanPrefix); it.ValidForPrefix(scanPrefix); it.Next() {
+			audit.count(string(it.Item().Key()))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to audit keys: %w", err)
+	}
+
+	if prefix == "" {
+		audit.compareIndexes()
+	}
+	return audit, nil
+}
+
+// count adds one key to the audit
+func (a *KeyAudit) count(key string) {
+	a.Keys++
+	if family, ok := familyOf(key); ok {
+		a.Families[family.name]++
+		if family.scope == scopeNone {
+			return
+		}
+		if graph := a.owner(key[len(family.prefix):], family.scope); graph != nil {
+			graph[family.name]++
+			return
+		}
+		a.Orphaned++
+		a.Orphans[family.name]++
+		return
+	}
+
+	a.Unknown++
+	name, _, _ := strings.Cut(key, ":")
+	if _, tracked := a.UnknownPrefixes[name]; !tracked && len(a.UnknownPrefixes) >= maxUnknownPrefixes {
+		name = "(other)"
+	}
+	a.UnknownPrefixes[name]++
+}
+
+// owner returns the counts of the graph a key belongs to, given the key
+// without its family prefix, or nil if that graph has no record
+func (a *KeyAudit) owner(rest string, scope keyScope) map[string]int64 {
+	graphID, ok := keyOwner(rest, scope, func(graphID models.GraphID) bool {
+		_, ok := a.Graphs[graphID]
+		return ok
+	})
+	if !ok {
+		return nil
+	}
+	return a.Graphs[graphID]
+}
+
+// keyOwner returns the graph a key belongs to, given the key without its
+// family prefix, among the graphs exists reports. Graph IDs may contain
+// ":", so the longest matching graph ID wins, as in backups.
+func keyOwner(rest string, scope keyScope, exists func(models.GraphID) bool) (models.GraphID, bool) {
+	switch scope {
+	case scopeExact:
+		return models.GraphID(rest), exists(models.GraphID(rest))
+	case scopeExpiry:
+		width := len("2006-01-02T15:04:05Z:")
+		if len(rest) < width {
+			return "", false
+		}
+		rest = rest[width:]
+	}
+	for i := strings.LastIndexByte(rest, ':'); i >= 0; i = strings.LastIndexByte(rest[:i], ':') {
+		if graphID := models.GraphID(rest[:i]); exists(graphID) {
+			return graphID, true
+		}
+	}
+	return "", false
+}
+
+// familyOf returns the key family a key belongs to
+func familyOf(key string) (keyFamily, bool) {
+	for _, family := range keyFamilies {
+		if strings.HasPrefix(key, family.prefix) {
+			return family, true
+		}
+	}
+	return keyFamily{}, false
+}
+
+// compareIndexes records the graphs whose entity and index counts differ
+func (a *KeyAudit) compareIndexes() {
+	graphIDs := make([]models.GraphID, 0, len(a.Graphs))
+	for graphID := range a.Graphs {
+		graphIDs = append(graphIDs, graphID)
+	}
+	sort.Slice(graphIDs, func(i, j int) bool { return graphIDs[i] < graphIDs[j] })
+
+	for _, graphID := range graphIDs {
+		counts := a.Graphs[graphID]
+		for _, check := range indexChecks {
+			if counts[check.family] != counts[check.index] {
+				a.Mismatches = append(a.Mismatches, KeyAuditMismatch{
+					Graph:    graphID,
+					Family:   check.family,
+					Index:    check.index,
+					Entities: counts[check.family],
+					Entries:  counts[check.index],
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3/pb"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// BackupFileName is the name of the backup file written by Backup
+const BackupFileName = "backup.db"
+
+// BackupFormatVersion is the version of the backup envelope written by this
+// build. Restore refuses files with any other version.
+const BackupFormatVersion = 1
+
+// backupMagic is the first line of every manifest-wrapped backup. Files
+// without it are legacy raw Badger streams.
+const backupMagic = "PATHWAYDB-BACKUP\n"
+
+// badgerBitDelete mirrors Badger's tombstone flag in a streamed key's meta
+const badgerBitDelete byte = 1 << 0
+
+var (
+	// ErrBackupChecksum is returned when a backup payload does not match the
+	// checksum or size recorded in its manifest
+	ErrBackupChecksum = errors.New("backup checksum mismatch")
+
+	// ErrLegacyBackup is returned when a file has no manifest header
+	ErrLegacyBackup = errors.New("backup has no manifest header")
+)
+
+// BackupManifest describes the contents of a backup file
+type BackupManifest struct {
+	FormatVersion int           `json:"format_version"`
+	CreatedAt     time.Time     `json:"created_at"`
+	BadgerVersion string        `json:"badger_version"`
+	Graphs        []BackupGraph `json:"graphs"`
+	TotalKeys     int64         `json:"total_keys"`
+	PayloadSize   int64         `json:"payload_size"`
+	SHA256        string        `json:"sha256"`
+}
+
+// BackupGraph holds the per-graph counts recorded in a backup manifest
+type BackupGraph struct {
+	ID    models.GraphID `json:"id"`
+	Nodes int            `json:"nodes"`
+	Edges int            `json:"edges"`
+}
+
+// Backup writes a backup of the database to backup.db in backupPath. The
+// file holds a JSON manifest followed by the Badger backup stream. It is
+// written to a temporary file and renamed into place, so an interrupted
+// backup never replaces a good one.
+func (e *BadgerEngine) Backup(backupPath string) error {
+	return e.BackupFile(filepath.Join(backupPath, BackupFileName))
+}
+
+// BackupFile writes a backup as Backup does, to backupFile instead of
+// backup.db in a directory. Its temporary files are written next to it.
+func (e *BadgerEngine) BackupFile(backupFile string) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	backupPath := filepath.Dir(backupFile)
+	payload, err := os.CreateTemp(backupPath, ".backup-payload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer func() {
+		payload.Close()
+		os.Remove(payload.Name())
+	}()
+
+	hasher := sha256.New()
+	counter := &countingWriter{}
+	if _, err := e.db.Backup(io.MultiWriter(payload, hasher, counter), 0); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	manifest := &BackupManifest{
+		FormatVersion: BackupFormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		BadgerVersion: badgerVersion(),
+		PayloadSize:   counter.n,
+		SHA256:        hex.EncodeToString(hasher.Sum(nil)),
+	}
+	if err := summarizePayload(payload, manifest); err != nil {
+		return fmt.Errorf("failed to read backup payload: %w", err)
+	}
+	header, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to serialize backup manifest: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(backupPath, ".backup-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeBackupFile(tmp, header, payload); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), backupFile); err != nil {
+		return fmt.Errorf("failed to move backup into place: %w", err)
+	}
+
+	e.logger.Info("Database backup created", "file", backupFile, "keys", manifest.TotalKeys)
+	return nil
+}
+
+// Restore loads a manifest-wrapped backup into the open database. The whole
+// payload is checked against the manifest's size and SHA-256 before anything
+// is loaded, so a damaged file leaves the database untouched. backupFile may
+// be the backup file itself or the directory Backup wrote it to.
+func (e *BadgerEngine) Restore(backupFile string) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	f, manifest, payload, err := openBackup(backupFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	offset -= int64(payload.Buffered())
+
+	if err := verifyPayload(payload, manifest); err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+
+	err = e.db.Load(bufio.NewReader(f), 256)
+	e.generations.advanceAll()
+	e.ClearCache()
+	if err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+	// The loaded records may not match the counts loaded with them, or
+	// the ones the database already held
+	e.backfillTypeCounts(true)
+
+	e.logger.Info("Database restored from backup", "file", f.Name(), "keys", manifest.TotalKeys)
+	return nil
+}
+
+// RestoreLegacy loads a headerless backup written before backups carried a
+// manifest. Nothing is verified beforehand.
+func (e *BadgerEngine) RestoreLegacy(backupFile string) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	f, err := os.Open(resolveBackupFile(backupFile))
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer f.Close()
+
+	err = e.db.Load(f, 256)
+	e.generations.advanceAll()
+	e.ClearCache()
+	if err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+	// The loaded records may not match the counts loaded with them, or
+	// the ones the database already held
+	e.backfillTypeCounts(true)
+
+	e.logger.Info("Database restored from legacy backup", "file", f.Name())
+	return nil
+}
+
+// ReadBackupManifest returns the manifest of a backup without restoring it.
+// backupFile may be the backup file itself or the directory holding it.
+func ReadBackupManifest(backupFile string) (*BackupManifest, error) {
+	f, manifest, _, err := openBackup(backupFile)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return manifest, nil
+}
+
+// VerifyBackup checks a backup's payload against its manifest's size and
+// SHA-256 without restoring it, and returns the manifest. backupFile may be
+// the backup file itself or the directory holding it.
+func VerifyBackup(backupFile string) (*BackupManifest, error) {
+	f, manifest, payload, err := openBackup(backupFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := verifyPayload(payload, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// openBackup opens a backup file and reads its header. The returned reader
+// is positioned at the start of the payload.
+func openBackup(backupFile string) (*os.File, *BackupManifest, *bufio.Reader, error) {
+	f, err := os.Open(resolveBackupFile(backupFile))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(backupMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != backupMagic {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("%w: %s (use LEGACY to restore files from older versions)", ErrLegacyBackup, f.Name())
+	}
+
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	manifest := &BackupManifest{}
+	if err := json.Unmarshal(line, manifest); err != nil {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+	if manifest.FormatVersion != BackupFormatVersion {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("unsupported backup format version %d: this server reads version %d", manifest.FormatVersion, BackupFormatVersion)
+	}
+
+	return f, manifest, r, nil
+}
+
+// resolveBackupFile accepts either a backup file or a directory written by
+// Backup
+func resolveBackupFile(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, BackupFileName)
+	}
+	return path
+}
+
+// verifyPayload reads the payload to the end and compares its size and
+// checksum with the manifest
+func verifyPayload(r io.Reader, manifest *BackupManifest) error {
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, r)
+	if err != nil {
+		return fmt.Errorf("failed to read backup payload: %w", err)
+	}
+	if n != manifest.PayloadSize {
+		return fmt.Errorf("%w: payload is %d bytes, manifest expects %d", ErrBackupChecksum, n, manifest.PayloadSize)
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != manifest.SHA256 {
+		return fmt.Errorf("%w: payload sha256 %s, manifest expects %s", ErrBackupChecksum, sum, manifest.SHA256)
+	}
+	return nil
+}
+
+// writeBackupFile writes the header and payload to f and syncs it
+func writeBackupFile(f *os.File, header []byte, payload *os.File) error {
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if _, err := w.WriteString(backupMagic); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(header, '\n')); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, payload); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// summarizePayload fills in the key and per-graph counts of a manifest from
+// a Badger backup stream. Graph keys sort after edge keys, so the graphs are
+// collected in a first pass and nodes and edges are attributed in a second.
+func summarizePayload(payload *os.File, manifest *BackupManifest) error {
+	var graphIDs []models.GraphID
+	err := scanPayload(payload, func(key []byte) {
+		manifest.TotalKeys++
+		if strings.HasPrefix(string(key), utils.GraphPrefix) {
+			graphIDs = append(graphIDs, utils.DecodeGraphID(key))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Prefer the longest graph ID so one that is a prefix of another does
+	// not claim its keys
+	sort.Slice(graphIDs, func(i, j int) bool {
+		return len(graphIDs[i]) > len(graphIDs[j])
+	})
+	counts := make(map[models.GraphID]*BackupGraph, len(graphIDs))
+	for _, graphID := range graphIDs {
+		counts[graphID] = &BackupGraph{ID: graphID}
+	}
+	owner := func(key []byte, prefix string) *BackupGraph {
+		rest := strings.TrimPrefix(string(key), prefix)
+		for _, graphID := range graphIDs {
+			if strings.HasPrefix(rest, string(graphID)+":") {
+				return counts[graphID]
+			}
+		}
+		return nil
+	}
+
+	err = scanPayload(payload, func(key []byte) {
+		switch {
+		case strings.HasPrefix(string(key), utils.NodePrefix):
+			if graph := owner(key, utils.NodePrefix); graph != nil {
+				graph.Nodes++
+			}
+		case strings.HasPrefix(string(key), utils.EdgePrefix):
+			if graph := owner(key, utils.EdgePrefix); graph != nil {
+				graph.Edges++
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest.Graphs = make([]BackupGraph, 0, len(counts))
+	for _, graph := range counts {
+		manifest.Graphs = append(manifest.Graphs, *graph)
+	}
+	sort.Slice(manifest.Graphs, func(i, j int) bool {
+		return manifest.Graphs[i].ID < manifest.Graphs[j].ID
+	})
+	return nil
+}
+
+// scanPayload calls fn for each live key in a Badger backup stream. The
+// stream holds length-prefixed KV lists with every version of a key, newest
+// first; only the newest version is considered.
+func scanPayload(payload *os.File, fn func(key []byte)) error {
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(payload)
+	now := uint64(time.Now().Unix())
+
+	var lastKey []byte
+	var buf []byte
+	for {
+		var size uint64
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if uint64(cap(buf)) < size {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+
+		list := &pb.KVList{}
+		if err := list.Unmarshal(buf); err != nil {
+			return err
+		}
+		for _, kv := range list.Kv {
+			if lastKey != nil && string(kv.Key) == string(lastKey) {
+				continue
+			}
+			lastKey = append(lastKey[:0], kv.Key...)
+
+			deleted := len(kv.Meta) > 0 && kv.Meta[0]&badgerBitDelete != 0
+			if deleted || (kv.ExpiresAt != 0 && kv.ExpiresAt <= now) {
+				continue
+			}
+			fn(kv.Key)
+		}
+	}
+}
+
+// badgerVersion returns the Badger module version this binary was built
+// with
+func badgerVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/dgraph-io/badger/v3" {
+				return dep.Version
+			}
+		}
+	}
+	return "v3"
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// cacheShards is the number of independently locked parts of the record
+// cache. Each holds an equal share of the entry and memory limits.
+const cacheShards = 16
+
+// cacheEntryOverhead approximates the memory a cache entry takes besides its
+// key, IDs and attribute values
+const cacheEntryOverhead = 256
+
+// DefaultCacheMemory is the memory estimate, in bytes, the record cache may
+// hold by default once it is enabled
+const DefaultCacheMemory = 64 << 20
+
+// CacheStats reports the node and edge record cache
+type CacheStats struct {
+	Enabled    bool   `json:"enabled"`
+	Entries    int    `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+	MaxEntries int    `json:"max_entries"`
+	MaxBytes   int64  `json:"max_bytes"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	// Evictions counts entries dropped to stay within the limits, and
+	// Invalidations entries dropped because their record was written
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+// cacheEntry is one cached record. Entries are never modified once cached,
+// so they are read outside the shard lock and copied for callers.
+type cacheEntry struct {
+	key  string
+	node *models.Node
+	edge *models.Edge
+	size int64
+}
+
+// cacheShard is a least recently used list of entries, most recent first
+type cacheShard struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List
+	bytes   int64
+}
+
+// recordCache is a read-through LRU cache of node and edge records keyed by
+// their storage keys. Only reads outside write transactions use it.
+// Committed writes drop the records they wrote, and a read only fills the
+// cache if its graph's generation has not moved since the read began, so a
+// read racing a write cannot cache the record the write replaced.
+type recordCache struct {
+	maxEntries    int
+	maxBytes      int64
+	shards        [cacheShards]cacheShard
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+// newRecordCache creates a cache of at most maxEntries records and an
+// estimated maxBytes of memory; maxBytes of 0 limits entries only
+func newRecordCache(maxEntries int, maxBytes int64) *recordCache {
+	return &recordCache{maxEntries: maxEntries, maxBytes: maxBytes}
+}
+
+// shard returns the shard of a key using FNV-1a
+func (c *recordCache) shard(key string) *cacheShard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return &c.shards[hash%cacheShards]
+}
+
+// get returns the entry cached under key and marks it recently used
+func (c *recordCache) get(key string) (*cacheEntry, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	element, ok := s.entries[key]
+	if ok {
+		s.lru.MoveToFront(element)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return element.Value.(*cacheEntry), true
+}
+
+// put caches entry if current, called under the shard lock, still reports
+// that nothing was written since the entry was read. Least recently used
+// entries are dropped to make room.
+func (c *recordCache) put(entry *cacheEntry, current func() bool) {
+	s := c.shard(entry.key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !current() {
+		return
+	}
+
+	if s.entries == nil {
+		s.entries = make(map[string]*list.Element)
+	}
+	if element, ok := s.entries[entry.key]; ok {
+		s.bytes -= element.Value.(*cacheEntry).size
+		s.lru.Remove(element)
+	}
+	s.entries[entry.key] = s.lru.PushFront(entry)
+	s.bytes += entry.size
+
+	maxEntries := (c.maxEntries + cacheShards - 1) / cacheShards
+	maxBytes := c.maxBytes / cacheShards
+	for s.lru.Len() > maxEntries || (maxBytes > 0 && s.bytes > maxBytes && s.lru.Len() > 1) {
+		s.remove(s.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove drops an element from the shard; the caller holds the lock
+func (s *cacheShard) remove(element *list.Element) {
+	entry := s.lru.Remove(element).(*cacheEntry)
+	delete(s.entries, entry.key)
+	s.bytes -= entry.size
+}
+
+// invalidate drops the entries cached under the given keys
+func (c *recordCache) invalidate(keys map[string]struct{}) {
+	for key := range keys {
+		s := c.shard(key)
+		s.mu.Lock()
+		if element, ok := s.entries[key]; ok {
+			s.remove(element)
+			c.invalidations.Add(1)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// clear drops every entry
+func (c *recordCache) clear() {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		c.invalidations.Add(uint64(s.lru.Len()))
+		s.entries = nil
+		s.lru.Init()
+		s.bytes = 0
+		s.mu.Unlock()
+	}
+}
+
+// stats returns the cache's counters and current size
+func (c *recordCache) stats() CacheStats {
+	stats := CacheStats{
+		Enabled:       true,
+		MaxEntries:    c.maxEntries,
+		MaxBytes:      c.maxBytes,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		stats.Entries += s.lru.Len()
+		stats.Bytes += s.bytes
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// cachedNode returns a copy of a node from the cache
+func (e *BadgerEngine) cachedNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, bool) {
+	if e.cache == nil {
+		return nil, false
+	}
+	entry, ok := e.cache.get(string(utils.EncodeNodeKey(graphID, nodeID)))
+	if !ok {
+		return nil, false
+	}
+	node := *entry.node
+	node.Attributes = cloneAttributes(entry.node.Attributes)
+	return &node, true
+}
+
+// cacheNode caches a copy of a node read at the given generation of its
+// graph. Nodes with a TTL are not cached, so expiry is always read from
+// storage.
+func (e *BadgerEngine) cacheNode(graphID models.GraphID, node *models.Node, generation uint64) {
+	if e.cache == nil || node.ExpiresAt != nil {
+		return
+	}
+	cached := *node
+	cached.Attributes = cloneAttributes(node.Attributes)
+	key := string(utils.EncodeNodeKey(graphID, node.ID))
+	size := int64(cacheEntryOverhead+len(key)+len(node.ID)+len(node.Type)) + attributesSize(node.Attributes)
+	e.cache.put(&cacheEntry{key: key, node: &cached, size: size}, func() bool {
+		return e.generations.get(graphID) == generation
+	})
+}
+
+// cachedEdge returns a copy of an edge from the cache
+func (e *BadgerEngine) cachedEdge(graphID models.GraphID, edgeID models.EdgeID) (*models.Edge, bool) {
+	if e.cache == nil {
+		return nil, false
+	}
+	entry, ok := e.cache.get(string(utils.EncodeEdgeKey(graphID, edgeID)))
+	if !ok {
+		return nil, false
+	}
+	edge := *entry.edge
+	edge.Attributes = cloneAttributes(entry.edge.Attributes)
+	return &edge, true
+}
+
+// cacheEdge caches a copy of an edge read at the given generation of its
+// graph. Edges with a TTL are not cached.
+func (e *BadgerEngine) cacheEdge(graphID models.GraphID, edge *models.Edge, generation uint64) {
+	if e.cache == nil || edge.ExpiresAt != nil {
+		return
+	}
+	cached := *edge
+	cached.Attributes = cloneAttributes(edge.Attributes)
+	key := string(utils.EncodeEdgeKey(graphID, edge.ID))
+	size := int64(cacheEntryOverhead+len(key)+len(edge.ID)+len(edge.Type)+len(edge.FromNodeID)+len(edge.ToNodeID)) +
+		attributesSize(edge.Attributes)
+	e.cache.put(&cacheEntry{key: key, edge: &cached, size: size}, func() bool {
+		return e.generations.get(graphID) == generation
+	})
+}
+
+// CacheStats reports the record cache's counters and size. The cache is
+// disabled unless the engine was created with WithRecordCache.
+func (e *BadgerEngine) CacheStats() CacheStats {
+	if e.cache == nil {
+		return CacheStats{}
+	}
+	return e.cache.stats()
+}
+
+// ClearCache drops every record from the record cache
+func (e *BadgerEngine) ClearCache() {
+	if e.cache != nil {
+		e.cache.clear()
+	}
+}
+
+// recordWrite notes a written node or edge key, so its cache entry can be
+// dropped once the transaction commits
+func (t *BadgerTransaction) recordWrite(key []byte) {
+	if !bytes.HasPrefix(key, []byte(utils.NodePrefix)) && !bytes.HasPrefix(key, []byte(utils.EdgePrefix)) {
+		return
+	}
+	if t.written == nil {
+		t.written = make(map[string]struct{})
+	}
+	t.written[string(key)] = struct{}{}
+}
+
+// cloneAttributes deep-copies attributes decoded from JSON, so callers can
+// modify what they are given without changing the cached record
+func cloneAttributes(attributes models.Attributes) models.Attributes {
+	if attributes == nil {
+		return nil
+	}
+	clone := make(models.Attributes, len(attributes))
+	for key, value := range attributes {
+		clone[key] = cloneValue(value)
+	}
+	return clone
+}
+
+// cloneValue deep-copies a value decoded from JSON
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneValue(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// attributesSize estimates the memory held by attributes decoded from JSON
+func attributesSize(attributes models.Attributes) int64 {
+	return valueSize(map[string]interface{}(attributes))
+}
+
+// valueSize estimates the memory held by a value decoded from JSON
+func valueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(16 + len(v))
+	case map[string]interface{}:
+		size := int64(48)
+		for key, item := range v {
+			size += int64(16+len(key)) + valueSize(item)
+		}
+		return size
+	case []interface{}:
+		size := int64(24)
+		for _, item := range v {
+			size += valueSize(item)
+		}
+		return size
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"bytes"
+	"sync/atomic"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// CompressionStats reports the compression of stored node and edge records
+type CompressionStats struct {
+	Enabled bool `json:"enabled"`
+	// Above is the size in bytes a serialized record must exceed to be
+	// compressed
+	Above int `json:"above"`
+	// Compressed counts the records written compressed since the database
+	// was opened, and BytesSaved the bytes compression kept out of them
+	Compressed uint64 `json:"compressed"`
+	BytesSaved int64  `json:"bytes_saved"`
+}
+
+// compressionPolicy compresses node and edge records larger than above
+// bytes when they are written. Indexes and other keys are stored as they
+// are. A zero above leaves compression disabled.
+type compressionPolicy struct {
+	above      int
+	compressed atomic.Uint64
+	saved      atomic.Int64
+}
+
+// compress returns the value to store for a key: the record compressed
+// with models.CompressRecord if the key is a node or edge record, the value
+// exceeds the threshold and compression makes it smaller, and the value
+// unchanged otherwise. Compressed records are counted on the transaction
+// until it commits.
+func (t *BadgerTransaction) compress(key []byte, value []byte) ([]byte, error) {
+	if t.compression == nil || t.compression.above <= 0 || len(value) <= t.compression.above {
+		return value, nil
+	}
+	if !bytes.HasPrefix(key, []byte(utils.NodePrefix)) && !bytes.HasPrefix(key, []byte(utils.EdgePrefix)) {
+		return value, nil
+	}
+	compressed, err := models.CompressRecord(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) >= len(value) {
+		return value, nil
+	}
+	t.compressed++
+	t.compressionSaved += int64(len(value) - len(compressed))
+	return compressed, nil
+}
+
+// recordCompression adds the records a committed transaction compressed to
+// the engine's counters
+func (e *BadgerEngine) recordCompression(tx *BadgerTransaction) {
+	if tx.compressed == 0 {
+		return
+	}
+	e.compression.compressed.Add(uint64(tx.compressed))
+	e.compression.saved.Add(tx.compressionSaved)
+}
+
+// CompressionStats reports the compression threshold and how many records
+// have been compressed since the database was opened. Compression is
+// disabled unless the engine was created with WithCompressAbove.
+func (e *BadgerEngine) CompressionStats() CompressionStats {
+	return CompressionStats{
+		Enabled:    e.compression.above > 0,
+		Above:      e.compression.above,
+		Compressed: e.compression.compressed.Load(),
+		BytesSaved: e.compression.saved.Load(),
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// checkSelfLoop rejects edge if it connects a node to itself and the graph
+// forbids self-loops for its type
+func (t *BadgerTransaction) checkSelfLoop(graphID models.GraphID, edge *models.Edge) error {
+	if edge.FromNodeID != edge.ToNodeID {
+		return nil
+	}
+
+	value, err := t.get(utils.EncodeGraphKey(graphID))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			// Edges of unknown graphs have no constraints to check
+			return nil
+		}
+		return fmt.Errorf("failed to get graph: %w", err)
+	}
+	graph := &models.Graph{}
+	if err := graph.FromJSON(value); err != nil {
+		return fmt.Errorf("failed to deserialize graph: %w", err)
+	}
+
+	if !graph.SelfLoopsAllowed(edge.Type) {
+		return fmt.Errorf("self-loop rejected: edge %s connects node %s to itself and graph %s forbids self-loops for edge type %s", edge.ID, edge.FromNodeID, graphID, edge.Type)
+	}
+	return nil
+}
+
+// ListSelfLoops returns the edges in a graph that connect a node to itself
+func (e *BadgerEngine) ListSelfLoops(graphID models.GraphID) ([]*models.Edge, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	var loops []*models.Edge
+	err := e.ScanEdges(graphID, func(edge *models.Edge) error {
+		if edge.FromNodeID == edge.ToNodeID {
+			loops = append(loops, edge)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list self-loops: %w", err)
+	}
+
+	return loops, nil
+}
+
+// DeleteSelfLoops deletes every self-loop edge in a graph, in batches of
+// rewriteBatchSize edges per transaction, and returns the deleted edges. If
+// a batch fails, the edges deleted by earlier batches are returned with the
+// error.
+func (e *BadgerEngine) DeleteSelfLoops(graphID models.GraphID) ([]*models.Edge, error) {
+	loops, err := e.ListSelfLoops(graphID)
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(loops); start += rewriteBatchSize {
+		end := min(start+rewriteBatchSize, len(loops))
+		err := e.update(func(tx *BadgerTransaction) error {
+			for _, edge := range loops[start:end] {
+				if err := tx.DeleteEdge(graphID, edge.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return loops[:start], fmt.Errorf("failed to delete self-loops: %w", err)
+		}
+	}
+
+	return loops, nil
+}
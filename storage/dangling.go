@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// detachEdge handles an edge of nodeID while the node is deleted. Weak edges
+// are kept with their indexes and marked dangling at each endpoint that is
+// nodeID; other edges are deleted.
+func (t *BadgerTransaction) detachEdge(graphID models.GraphID, edgeID models.EdgeID, nodeID models.NodeID) error {
+	edge, err := t.GetEdge(graphID, edgeID)
+	if err != nil || !edge.Weak {
+		return t.DeleteEdge(graphID, edgeID)
+	}
+
+	if edge.FromNodeID == nodeID {
+		edge.SetAttribute(models.DanglingFromAttribute, string(nodeID))
+	}
+	if edge.ToNodeID == nodeID {
+		edge.SetAttribute(models.DanglingToAttribute, string(nodeID))
+	}
+	return t.putEdge(graphID, edge)
+}
+
+// reattachEdges clears the dangling markers naming nodeID from the weak
+// edges it kept while deleted, now that it exists again
+func (t *BadgerTransaction) reattachEdges(graphID models.GraphID, nodeID models.NodeID) error {
+	edgeIDs, err := t.incidentEdgeIDs(graphID, nodeID)
+	if err != nil {
+		return err
+	}
+	for _, edgeID := range edgeIDs {
+		edge, err := t.GetEdge(graphID, edgeID)
+		if err != nil || !edge.DanglingEnd(nodeID) {
+			continue
+		}
+		if edge.FromNodeID == nodeID {
+			delete(edge.Attributes, models.DanglingFromAttribute)
+		}
+		if edge.ToNodeID == nodeID {
+			delete(edge.Attributes, models.DanglingToAttribute)
+		}
+		edge.UpdatedAt = time.Now()
+		if err := t.putEdge(graphID, edge); err != nil {
+			return fmt.Errorf("failed to reattach edge %s: %w", edgeID, err)
+		}
+	}
+	return nil
+}
+
+// refreshDangling sets the dangling markers of an edge about to be written
+// from whether its endpoints exist, so they can be neither forged nor lost
+// by replacing the attributes. Only weak edges are ever dangling.
+func (t *BadgerTransaction) refreshDangling(graphID models.GraphID, edge *models.Edge) error {
+	for _, end := range []struct {
+		nodeID    models.NodeID
+		attribute string
+	}{
+		{edge.FromNodeID, models.DanglingFromAttribute},
+		{edge.ToNodeID, models.DanglingToAttribute},
+	} {
+		missing := false
+		if edge.Weak {
+			if _, err := t.GetNode(graphID, end.nodeID); err != nil {
+				if !errors.Is(err, ErrNodeNotFound) {
+					return err
+				}
+				missing = true
+			}
+		}
+		if missing {
+			if edge.Attributes == nil {
+				edge.Attributes = make(models.Attributes)
+			}
+			edge.Attributes[end.attribute] = string(end.nodeID)
+		} else {
+			delete(edge.Attributes, end.attribute)
+		}
+	}
+	return nil
+}
+
+// incidentEdgeIDs returns the IDs of the edges indexed as leaving or
+// reaching nodeID
+func (t *BadgerTransaction) incidentEdgeIDs(graphID models.GraphID, nodeID models.NodeID) ([]models.EdgeID, error) {
+	var edgeIDs []models.EdgeID
+	for _, direction := range []string{"out", "in"} {
+		prefix := []byte(fmt.Sprintf("%s%s:%s:%s:", utils.NodeIndexPrefix, direction, graphID, nodeID))
+		iter := t.txn.NewIterator(badger.DefaultIteratorOptions)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			err := iter.Item().Value(func(val []byte) error {
+				edgeIDs = append(edgeIDs, models.EdgeID(val))
+				return nil
+			})
+			if err != nil {
+				iter.Close()
+				return nil, fmt.Errorf("failed to read edge index: %w", err)
+			}
+		}
+		iter.Close()
+	}
+	return edgeIDs, nil
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// DefaultDeletionLogRetention is how long node and edge deletions are kept
+// in the deletion log by default, which bounds how far back an incremental
+// export can start
+const DefaultDeletionLogRetention = 30 * 24 * time.Hour
+
+// logDeletion records in the deletion log that a node ("n") or edge ("e")
+// of the graph was deleted, at the engine clock's current time
+func (t *BadgerTransaction) logDeletion(graphID models.GraphID, kind string, id string) error {
+	if err := t.set(utils.EncodeDeletionLogKey(graphID, t.clock(), kind, id), nil); err != nil {
+		return fmt.Errorf("failed to log deletion: %w", err)
+	}
+	return nil
+}
+
+// Tombstones lists the IDs of the nodes and edges an incremental export
+// reports deleted
+type Tombstones struct {
+	Nodes []models.NodeID `json:"nodes"`
+	Edges []models.EdgeID `json:"edges"`
+}
+
+// ExportGraphSince writes the changes to a graph since a time to w as a
+// single JSON document: {"since":...,"until":...,"graph":...,"nodes":[...],
+// "edges":[...],"tombstones":{"nodes":[...],"edges":[...]}}. Nodes and edges
+// are those created or updated at or after since; tombstones are the IDs
+// the deletion log has deleted since then that do not exist again. until is
+// the engine clock's time when the export started, so passing it as the
+// next export's since chains exports without gaps. Changes made while the
+// export runs may be in both. Applying a document means upserting its
+// nodes and edges, then deleting its tombstones. since must be within the
+// deletion log retention, as older deletions may have been pruned.
+func (e *BadgerEngine) ExportGraphSince(graphID models.GraphID, since time.Time, w io.Writer) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	until := e.clock().UTC()
+	if e.deletionLogRetention > 0 && since.Before(until.Add(-e.deletionLogRetention)) {
+		return fmt.Errorf("since %s is older than the deletion log retention of %s", since.UTC().Format(time.RFC3339), e.deletionLogRetention)
+	}
+
+	graph, err := e.GetGraph(graphID)
+	if err != nil {
+		return err
+	}
+	graphJSON, err := graph.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize graph: %w", err)
+	}
+
+	out := bufio.NewWriterSize(w, exportBufferSize)
+	fmt.Fprintf(out, `{"since":%q,"until":%q,"graph":`, since.UTC().Format(time.RFC3339Nano), until.Format(time.RFC3339Nano))
+	out.Write(graphJSON)
+	x := &exportWriter{out: out}
+	err = e.exportEntities(graphID, x, func(created, updated time.Time) bool {
+		if updated.IsZero() {
+			updated = created
+		}
+		return !updated.Before(since)
+	})
+	if err != nil {
+		return err
+	}
+
+	tombstones, err := e.deletedSince(graphID, since)
+	if err != nil {
+		return fmt.Errorf("failed to export tombstones: %w", err)
+	}
+	data, err := json.Marshal(tombstones)
+	if err != nil {
+		return fmt.Errorf("failed to serialize tombstones: %w", err)
+	}
+	out.WriteString(`],"tombstones":`)
+	out.Write(data)
+
+	out.WriteString(`}`)
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// deletedSince returns the nodes and edges of a graph the deletion log has
+// deleted at or after since, leaving out those that exist again
+func (e *BadgerEngine) deletedSince(graphID models.GraphID, since time.Time) (*Tombstones, error) {
+	tombstones := &Tombstones{Nodes: []models.NodeID{}, Edges: []models.EdgeID{}}
+	err := e.db.View(func(txn *badger.Txn) error {
+		nodes := make(map[models.NodeID]struct{})
+		edges := make(map[models.EdgeID]struct{})
+
+		// Entries sort by time, so the scan can start at since
+		prefix := utils.CreateDeletionLogIteratorPrefix(graphID)
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		start := append(append([]byte(nil), prefix...), since.UTC().Format(utils.DeletionLogTimeLayout)...)
+		for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+			deletedAt, kind, id, ok := utils.DecodeDeletionLogKey(graphID, it.Item().Key())
+			if !ok || deletedAt.Before(since) {
+				continue
+			}
+			if kind == "n" {
+				nodes[models.NodeID(id)] = struct{}{}
+			} else {
+				edges[models.EdgeID(id)] = struct{}{}
+			}
+		}
+
+		for nodeID := range nodes {
+			if _, err := txn.Get(utils.EncodeNodeKey(graphID, nodeID)); err == badger.ErrKeyNotFound {
+				tombstones.Nodes = append(tombstones.Nodes, nodeID)
+			} else if err != nil {
+				return err
+			}
+		}
+		for edgeID := range edges {
+			if _, err := txn.Get(utils.EncodeEdgeKey(graphID, edgeID)); err == badger.ErrKeyNotFound {
+				tombstones.Edges = append(tombstones.Edges, edgeID)
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tombstones.Nodes, func(i, j int) bool { return tombstones.Nodes[i] < tombstones.Nodes[j] })
+	sort.Slice(tombstones.Edges, func(i, j int) bool { return tombstones.Edges[i] < tombstones.Edges[j] })
+	return tombstones, nil
+}
+
+// pruneDeletionLog deletes a graph's deletion log entries older than
+// cutoff, up to rewriteBatchSize per transaction, and returns how many
+func (e *BadgerEngine) pruneDeletionLog(graphID models.GraphID, cutoff time.Time) (int, error) {
+	prefix := utils.CreateDeletionLogIteratorPrefix(graphID)
+	pruned := 0
+	for {
+		var expired [][]byte
+		err := e.db.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			opts.Prefix = prefix
+			it := txn.NewIterator(opts)
+			defer it.Close()
+
+			for it.Seek(prefix); it.ValidForPrefix(prefix) && len(expired) < rewriteBatchSize; it.Next() {
+				// Keys of graphs whose ID starts with the graph's ID and ":"
+				// share the prefix and are skipped
+				deletedAt, _, _, ok := utils.DecodeDeletionLogKey(graphID, it.Item().Key())
+				if !ok {
+					continue
+				}
+				if !deletedAt.Before(cutoff) {
+					return nil
+				}
+				expired = append(expired, it.Item().KeyCopy(nil))
+			}
+			return nil
+		})
+		if err != nil || len(expired) == 0 {
+			return pruned, err
+		}
+
+		err = e.update(func(tx *BadgerTransaction) error {
+			for _, key := range expired {
+				if err := tx.delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return pruned, err
+		}
+		pruned += len(expired)
+	}
+}
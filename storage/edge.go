@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,52 +14,149 @@ import (
 // CreateEdge creates a new edge in the specified graph
 func (e *BadgerEngine) CreateEdge(graphID models.GraphID, edge *models.Edge) error {
 	if e.db == nil {
-		return fmt.Errorf("database not opened")
+		return ErrClosed
 	}
 
-	return e.db.Update(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
+	return e.update(func(tx *BadgerTransaction) error {
 		return tx.CreateEdge(graphID, edge)
 	})
 }
 
+// CreateEdges creates edges in one transaction, so either all of them are
+// created or, if one fails, none are. The error names the edge that failed,
+// such as one whose endpoint does not exist.
+func (e *BadgerEngine) CreateEdges(graphID models.GraphID, edges []*models.Edge) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	return e.update(func(tx *BadgerTransaction) error {
+		for _, edge := range edges {
+			if err := tx.CreateEdge(graphID, edge); err != nil {
+				return fmt.Errorf("edge %s: %w", edge.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
 // GetEdge retrieves an edge by ID from the specified graph
 func (e *BadgerEngine) GetEdge(graphID models.GraphID, edgeID models.EdgeID) (*models.Edge, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
+	}
+
+	edge, cached := e.cachedEdge(graphID, edgeID)
+	if !cached {
+		// As in GetNode, the generation is read before the snapshot
+		generation := e.generations.get(graphID)
+		err := e.db.View(func(txn *badger.Txn) error {
+			tx := e.newTransaction(txn)
+			var err error
+			edge, err = tx.GetEdge(graphID, edgeID)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		e.cacheEdge(graphID, edge, generation)
 	}
 
-	var edge *models.Edge
-	err := e.db.View(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
-		var err error
-		edge, err = tx.GetEdge(graphID, edgeID)
-		return err
-	})
+	// Same lazy expiry check as GetNode.
+	if edge.IsExpired() {
+		e.ttlManager.enqueueEdge(graphID, edgeID)
+		return nil, fmt.Errorf("%w: %s", ErrEdgeNotFound, edgeID)
+	}
+
+	return edge, nil
+}
+
+// GetEdges retrieves edges by ID in one read transaction. The result has
+// an entry per ID, in order, which is nil if no edge has the ID or it has
+// expired.
+func (e *BadgerEngine) GetEdges(graphID models.GraphID, edgeIDs []models.EdgeID) ([]*models.Edge, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
 
-	return edge, err
+	edges := make([]*models.Edge, len(edgeIDs))
+	var missed []int
+	for i, edgeID := range edgeIDs {
+		if edge, cached := e.cachedEdge(graphID, edgeID); cached {
+			edges[i] = edge
+		} else {
+			missed = append(missed, i)
+		}
+	}
+	if len(missed) > 0 {
+		generation := e.generations.get(graphID)
+		err := e.db.View(func(txn *badger.Txn) error {
+			tx := e.newTransaction(txn)
+			for _, i := range missed {
+				edge, err := tx.GetEdge(graphID, edgeIDs[i])
+				if errors.Is(err, ErrEdgeNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				edges[i] = edge
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range missed {
+			if edges[i] != nil {
+				e.cacheEdge(graphID, edges[i], generation)
+			}
+		}
+	}
+
+	for i, edge := range edges {
+		if edge != nil && edge.IsExpired() {
+			e.ttlManager.enqueueEdge(graphID, edge.ID)
+			edges[i] = nil
+		}
+	}
+	return edges, nil
 }
 
 // UpdateEdge updates an existing edge
 func (e *BadgerEngine) UpdateEdge(graphID models.GraphID, edge *models.Edge) error {
 	if e.db == nil {
-		return fmt.Errorf("database not opened")
+		return ErrClosed
 	}
 
-	return e.db.Update(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
+	return e.update(func(tx *BadgerTransaction) error {
 		return tx.UpdateEdge(graphID, edge)
 	})
 }
 
+// UpdateEdgeWithResult updates an existing edge and reports whether it was
+// written, false when the update changes nothing but UpdatedAt
+func (e *BadgerEngine) UpdateEdgeWithResult(graphID models.GraphID, edge *models.Edge) (bool, error) {
+	if e.db == nil {
+		return false, ErrClosed
+	}
+
+	var written bool
+	err := e.update(func(tx *BadgerTransaction) error {
+		var err error
+		written, err = tx.UpdateEdgeWithResult(graphID, edge)
+		return err
+	})
+	return written, err
+}
+
 // DeleteEdge deletes an edge
 func (e *BadgerEngine) DeleteEdge(graphID models.GraphID, edgeID models.EdgeID) error {
 	if e.db == nil {
-		return fmt.Errorf("database not opened")
+		return ErrClosed
 	}
 
-	return e.db.Update(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
+	return e.update(func(tx *BadgerTransaction) error {
 		return tx.DeleteEdge(graphID, edgeID)
 	})
 }
@@ -65,18 +164,22 @@ func (e *BadgerEngine) DeleteEdge(graphID models.GraphID, edgeID models.EdgeID)
 // ListEdges returns all edges in the specified graph
 func (e *BadgerEngine) ListEdges(graphID models.GraphID) ([]*models.Edge, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
 	var edges []*models.Edge
 	prefix := utils.CreateEdgeIteratorPrefix(graphID)
 
-	err := e.iterateWithPrefix(prefix, func(key []byte, value []byte) error {
+	err := e.listWithPrefix(prefix, func(key []byte, value []byte) error {
 		edge := &models.Edge{}
 		err := edge.FromJSON(value)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize edge: %w", err)
 		}
+		if edge.IsExpired() {
+			e.ttlManager.enqueueEdge(graphID, edge.ID)
+			return nil
+		}
 		edges = append(edges, edge)
 		return nil
 	})
@@ -88,10 +191,61 @@ func (e *BadgerEngine) ListEdges(graphID models.GraphID) ([]*models.Edge, error)
 	return edges, nil
 }
 
+// ScanEdges calls fn with each edge in the specified graph. It works like
+// ScanNodes.
+func (e *BadgerEngine) ScanEdges(graphID models.GraphID, fn func(edge *models.Edge) error) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	prefix := utils.CreateEdgeIteratorPrefix(graphID)
+	err := e.listWithPrefix(prefix, func(key []byte, value []byte) error {
+		edge := &models.Edge{}
+		if err := edge.FromJSON(value); err != nil {
+			return fmt.Errorf("failed to deserialize edge: %w", err)
+		}
+		if edge.IsExpired() {
+			e.ttlManager.enqueueEdge(graphID, edge.ID)
+			return nil
+		}
+		return fn(edge)
+	})
+	if err == ErrStopScan {
+		return nil
+	}
+	return err
+}
+
+// ListEdgesPage returns a page of the edges of the specified graph in ID
+// order. It works like ListNodesPage.
+func (e *BadgerEngine) ListEdgesPage(graphID models.GraphID, after models.EdgeID, limit int) ([]*models.Edge, bool, error) {
+	if e.db == nil {
+		return nil, false, ErrClosed
+	}
+
+	var edges []*models.Edge
+	more, err := e.listPage(utils.CreateEdgeIteratorPrefix(graphID), string(after), limit, func(value []byte) (bool, error) {
+		edge := &models.Edge{}
+		if err := edge.FromJSON(value); err != nil {
+			return false, fmt.Errorf("failed to deserialize edge: %w", err)
+		}
+		if edge.IsExpired() {
+			e.ttlManager.enqueueEdge(graphID, edge.ID)
+			return false, nil
+		}
+		edges = append(edges, edge)
+		return true, nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list edges: %w", err)
+	}
+	return edges, more, nil
+}
+
 // ListEdgesByType returns all edges of a specific type in the specified graph
 func (e *BadgerEngine) ListEdgesByType(graphID models.GraphID, edgeType models.EdgeType) ([]*models.Edge, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
 	var edges []*models.Edge
@@ -119,7 +273,7 @@ func (e *BadgerEngine) ListEdgesByType(graphID models.GraphID, edgeType models.E
 // GetOutgoingEdges returns all edges going out from a specific node
 func (e *BadgerEngine) GetOutgoingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
 	var edges []*models.Edge
@@ -141,13 +295,17 @@ func (e *BadgerEngine) GetOutgoingEdges(graphID models.GraphID, nodeID models.No
 		return nil, fmt.Errorf("failed to get outgoing edges: %w", err)
 	}
 
+	// Expanding a node during a traversal counts as reading it
+	if e.reads.enabled.Load() {
+		e.reads.record(graphID, nodeID)
+	}
 	return edges, nil
 }
 
 // GetIncomingEdges returns all edges coming into a specific node
 func (e *BadgerEngine) GetIncomingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
 	var edges []*models.Edge
@@ -169,13 +327,17 @@ func (e *BadgerEngine) GetIncomingEdges(graphID models.GraphID, nodeID models.No
 		return nil, fmt.Errorf("failed to get incoming edges: %w", err)
 	}
 
+	// Expanding a node during a traversal counts as reading it
+	if e.reads.enabled.Load() {
+		e.reads.record(graphID, nodeID)
+	}
 	return edges, nil
 }
 
 // GetConnectedNodes returns all nodes connected to a specific node (both incoming and outgoing)
 func (e *BadgerEngine) GetConnectedNodes(graphID models.GraphID, nodeID models.NodeID) ([]*models.Node, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
 	nodeMap := make(map[models.NodeID]*models.Node)
@@ -221,10 +383,53 @@ func (e *BadgerEngine) GetConnectedNodes(graphID models.GraphID, nodeID models.N
 	return nodes, nil
 }
 
-// FindEdgesByAttribute finds edges that have a specific attribute value
+// RenameEdgeType changes the type of every edge of oldType in a graph to
+// newType. It works like RenameNodeType and returns the number of edges
+// changed.
+func (e *BadgerEngine) RenameEdgeType(graphID models.GraphID, oldType, newType models.EdgeType) (int, error) {
+	if e.db == nil {
+		return 0, ErrClosed
+	}
+	if newType == "" {
+		return 0, fmt.Errorf("new edge type cannot be empty")
+	}
+	if err := models.ValidateType("edge", string(newType)); err != nil {
+		return 0, err
+	}
+	if oldType == newType {
+		return 0, fmt.Errorf("edge type is already %s", newType)
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	prefix := utils.CreateTypeIteratorPrefix(graphID, "e", string(oldType))
+	count, err := e.rewriteIndex(prefix, func(t *BadgerTransaction, key []byte, id string) (bool, error) {
+		edge, err := t.GetEdge(graphID, models.EdgeID(id))
+		if errors.Is(err, ErrEdgeNotFound) || (err == nil && edge.Type != oldType) {
+			// Stale index entry, e.g. left behind by an expired edge
+			return false, t.delete(key)
+		}
+		if err != nil {
+			return false, err
+		}
+		edge.Type = newType
+		edge.UpdatedAt = now
+		return true, t.UpdateEdge(graphID, edge)
+	})
+	if err != nil {
+		return count, fmt.Errorf("failed to rename edge type: %w", err)
+	}
+
+	return count, nil
+}
+
+// FindEdgesByAttribute finds edges that have a specific attribute value,
+// compared and looked up as FindNodesByAttribute does
 func (e *BadgerEngine) FindEdgesByAttribute(graphID models.GraphID, attrKey string, attrValue interface{}) ([]*models.Edge, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
 	// For now, we'll do a full scan of edges and filter by attribute
@@ -236,10 +441,8 @@ func (e *BadgerEngine) FindEdgesByAttribute(graphID models.GraphID, attrKey stri
 
 	var matchingEdges []*models.Edge
 	for _, edge := range allEdges {
-		if value, exists := edge.GetAttribute(attrKey); exists {
-			if value == attrValue {
-				matchingEdges = append(matchingEdges, edge)
-			}
+		if value, exists := edge.Attributes.Lookup(attrKey); exists && models.MatchValue(value, attrValue) {
+			matchingEdges = append(matchingEdges, edge)
 		}
 	}
 
@@ -250,17 +453,41 @@ func (e *BadgerEngine) FindEdgesByAttribute(graphID models.GraphID, attrKey stri
 
 // CreateEdge creates an edge within a transaction
 func (t *BadgerTransaction) CreateEdge(graphID models.GraphID, edge *models.Edge) error {
+	return t.createEdge(graphID, edge, false)
+}
+
+// createEdge creates an edge. With keepDangling, as when importing, a weak
+// edge may be created dangling at an endpoint it is marked dangling at.
+func (t *BadgerTransaction) createEdge(graphID models.GraphID, edge *models.Edge, keepDangling bool) error {
+	if err := edge.Validate(); err != nil {
+		return err
+	}
+	if err := t.attributeKeys.check("edge", edge.Attributes, nil); err != nil {
+		return err
+	}
+	t.touch(graphID)
+
 	// Verify that both nodes exist
+	dangling := func(nodeID models.NodeID) bool {
+		return keepDangling && edge.Weak && edge.DanglingEnd(nodeID)
+	}
 	_, err := t.GetNode(graphID, edge.FromNodeID)
-	if err != nil {
+	if err != nil && !dangling(edge.FromNodeID) {
 		return fmt.Errorf("source node does not exist: %w", err)
 	}
 
 	_, err = t.GetNode(graphID, edge.ToNodeID)
-	if err != nil {
+	if err != nil && !dangling(edge.ToNodeID) {
 		return fmt.Errorf("target node does not exist: %w", err)
 	}
 
+	if err := t.checkSelfLoop(graphID, edge); err != nil {
+		return err
+	}
+	if err := t.refreshDangling(graphID, edge); err != nil {
+		return err
+	}
+
 	// Store the edge
 	edgeKey := utils.EncodeEdgeKey(graphID, edge.ID)
 	edgeValue, err := edge.ToJSON()
@@ -268,20 +495,21 @@ func (t *BadgerTransaction) CreateEdge(graphID models.GraphID, edge *models.Edge
 		return fmt.Errorf("failed to serialize edge: %w", err)
 	}
 
-	if edge.ExpiresAt != nil {
-		ttl := time.Until(*edge.ExpiresAt)
-		if ttl > 0 {
-			err = t.setWithTTL(edgeKey, edgeValue, ttl)
-		} else {
-			// If TTL is already expired, don't even add it.
-			return nil
-		}
-	} else {
-		err = t.set(edgeKey, edgeValue)
+	if edge.IsExpired() {
+		// If TTL is already expired, don't even add it.
+		return nil
+	}
+	op := EventCreated
+	if existingEdge, err := t.GetEdge(graphID, edge.ID); err == nil {
+		t.countEdge(graphID, existingEdge.Type, -1)
+		op = EventUpdated
 	}
+	err = t.set(edgeKey, edgeValue)
 	if err != nil {
 		return fmt.Errorf("failed to store edge: %w", err)
 	}
+	t.countEdge(graphID, edge.Type, 1)
+	t.notifyEdge(graphID, edge, op)
 
 	// Create type index
 	typeIndexKey := utils.EncodeEdgeTypeIndexKey(graphID, edge.Type, edge.ID)
@@ -304,6 +532,15 @@ func (t *BadgerTransaction) CreateEdge(graphID models.GraphID, edge *models.Edge
 		return fmt.Errorf("failed to create incoming edge index: %w", err)
 	}
 
+	// Add to expiry index if TTL is set
+	if edge.ExpiresAt != nil {
+		key := utils.EncodeEdgeExpiryIndexKey(graphID, edge.ID, *edge.ExpiresAt)
+		err = t.set(key, []byte(edge.ID))
+		if err != nil {
+			return fmt.Errorf("failed to create expiry index: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -313,7 +550,7 @@ func (t *BadgerTransaction) GetEdge(graphID models.GraphID, edgeID models.EdgeID
 	edgeValue, err := t.get(edgeKey)
 	if err != nil {
 		if err == badger.ErrKeyNotFound {
-			return nil, fmt.Errorf("edge not found: %s", edgeID)
+			return nil, fmt.Errorf("%w: %s", ErrEdgeNotFound, edgeID)
 		}
 		return nil, fmt.Errorf("failed to get edge: %w", err)
 	}
@@ -327,16 +564,69 @@ func (t *BadgerTransaction) GetEdge(graphID models.GraphID, edgeID models.EdgeID
 	return edge, nil
 }
 
-// UpdateEdge updates an edge within a transaction
+// UpdateEdge updates an edge within a transaction. Unlike
+// UpdateEdgeWithResult it writes the edge even if nothing but UpdatedAt
+// changed, so it can refresh that alone.
 func (t *BadgerTransaction) UpdateEdge(graphID models.GraphID, edge *models.Edge) error {
-	// Get the existing edge to check if connections or type changed
-	existingEdge, err := t.GetEdge(graphID, edge.ID)
+	existing, err := t.GetEdge(graphID, edge.ID)
 	if err != nil {
 		return fmt.Errorf("edge does not exist: %w", err)
 	}
+	return t.updateEdge(graphID, existing, edge)
+}
+
+// UpdateEdgeWithResult updates an edge within a transaction and reports
+// whether it was written. An edge whose canonical JSON, UpdatedAt aside, is
+// that of the stored edge is not written: its record, indexes and the
+// graph's generation are left as they are, stored UpdatedAt included.
+func (t *BadgerTransaction) UpdateEdgeWithResult(graphID models.GraphID, edge *models.Edge) (bool, error) {
+	// Get the existing edge to compare the update with
+	existingEdge, err := t.GetEdge(graphID, edge.ID)
+	if err != nil {
+		return false, fmt.Errorf("edge does not exist: %w", err)
+	}
+	if edgeUnchanged(existingEdge, edge) {
+		return false, nil
+	}
+	return true, t.updateEdge(graphID, existingEdge, edge)
+}
+
+// edgeUnchanged reports whether edge serializes as existing does once its
+// UpdatedAt is ignored
+func edgeUnchanged(existing, edge *models.Edge) bool {
+	candidate := *edge
+	candidate.UpdatedAt = existing.UpdatedAt
+	before, err := existing.ToJSON()
+	if err != nil {
+		return false
+	}
+	after, err := candidate.ToJSON()
+	return err == nil && bytes.Equal(before, after)
+}
+
+// updateEdge replaces existingEdge, the stored edge, with edge
+func (t *BadgerTransaction) updateEdge(graphID models.GraphID, existingEdge, edge *models.Edge) error {
+	var err error
+	if err := t.attributeKeys.check("edge", edge.Attributes, existingEdge.Attributes); err != nil {
+		return err
+	}
+	t.touch(graphID)
+
+	// Existing self-loops stay editable so they can be migrated, but an
+	// update may not create one or move one to a type that forbids it
+	wasSelfLoop := existingEdge.FromNodeID == existingEdge.ToNodeID
+	if !wasSelfLoop || existingEdge.Type != edge.Type {
+		if err := t.checkSelfLoop(graphID, edge); err != nil {
+			return err
+		}
+	}
 
 	// If type changed, update the type index
 	if existingEdge.Type != edge.Type {
+		if err := models.ValidateType("edge", string(edge.Type)); err != nil {
+			return err
+		}
+
 		// Remove old type index
 		oldTypeIndexKey := utils.EncodeEdgeTypeIndexKey(graphID, existingEdge.Type, edge.ID)
 		err = t.delete(oldTypeIndexKey)
@@ -350,6 +640,8 @@ func (t *BadgerTransaction) UpdateEdge(graphID models.GraphID, edge *models.Edge
 		if err != nil {
 			return fmt.Errorf("failed to create new type index: %w", err)
 		}
+		t.countEdge(graphID, existingEdge.Type, -1)
+		t.countEdge(graphID, edge.Type, 1)
 	}
 
 	// If connections changed, update the node indexes
@@ -392,33 +684,73 @@ func (t *BadgerTransaction) UpdateEdge(graphID models.GraphID, edge *models.Edge
 		}
 	}
 
-	// Update the edge
+	// If the TTL was added, removed or changed, move the expiry index
+	// entry. An expired edge is deleted by putEdge, with the entry of the
+	// stored edge.
+	if !edge.IsExpired() && !sameExpiry(existingEdge.ExpiresAt, edge.ExpiresAt) {
+		if existingEdge.ExpiresAt != nil {
+			oldExpiryKey := utils.EncodeEdgeExpiryIndexKey(graphID, existingEdge.ID, *existingEdge.ExpiresAt)
+			if err := t.delete(oldExpiryKey); err != nil {
+				return fmt.Errorf("failed to remove old expiry index: %w", err)
+			}
+		}
+		if edge.ExpiresAt != nil {
+			newExpiryKey := utils.EncodeEdgeExpiryIndexKey(graphID, edge.ID, *edge.ExpiresAt)
+			if err := t.set(newExpiryKey, []byte(edge.ID)); err != nil {
+				return fmt.Errorf("failed to create new expiry index: %w", err)
+			}
+		}
+	}
+
+	if err := t.refreshDangling(graphID, edge); err != nil {
+		return err
+	}
+	return t.putEdge(graphID, edge)
+}
+
+// sameExpiry reports whether two expiry times, nil for none, are equal
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// putEdge writes the record of an edge whose indexes are already in place.
+// An edge whose TTL has elapsed is deleted instead.
+func (t *BadgerTransaction) putEdge(graphID models.GraphID, edge *models.Edge) error {
+	if edge.IsExpired() {
+		// If TTL is expired, this update effectively becomes a delete.
+		return t.DeleteEdge(graphID, edge.ID)
+	}
+
 	edgeKey := utils.EncodeEdgeKey(graphID, edge.ID)
 	edgeValue, err := edge.ToJSON()
 	if err != nil {
 		return fmt.Errorf("failed to serialize edge: %w", err)
 	}
-
-	if edge.ExpiresAt != nil {
-		ttl := time.Until(*edge.ExpiresAt)
-		if ttl > 0 {
-			return t.setWithTTL(edgeKey, edgeValue, ttl)
-		} else {
-			// If TTL is expired, this update effectively becomes a delete.
-			return t.DeleteEdge(graphID, edge.ID)
-		}
-	}
-
+	t.notifyEdge(graphID, edge, EventUpdated)
 	return t.set(edgeKey, edgeValue)
 }
 
-// DeleteEdge deletes an edge within a transaction
+// DeleteEdge deletes an edge within a transaction, recording it in the
+// deletion log
 func (t *BadgerTransaction) DeleteEdge(graphID models.GraphID, edgeID models.EdgeID) error {
+	if err := t.deleteEdgeRecord(graphID, edgeID); err != nil {
+		return err
+	}
+	return t.logDeletion(graphID, "e", string(edgeID))
+}
+
+// deleteEdgeRecord deletes an edge with its indexes without logging it, as
+// DeleteGraph does
+func (t *BadgerTransaction) deleteEdgeRecord(graphID models.GraphID, edgeID models.EdgeID) error {
 	// Get the edge first to access its properties
 	edge, err := t.GetEdge(graphID, edgeID)
 	if err != nil {
 		return fmt.Errorf("edge does not exist: %w", err)
 	}
+	t.touch(graphID)
 
 	// Delete the edge
 	edgeKey := utils.EncodeEdgeKey(graphID, edgeID)
@@ -433,6 +765,8 @@ func (t *BadgerTransaction) DeleteEdge(graphID models.GraphID, edgeID models.Edg
 	if err != nil {
 		return fmt.Errorf("failed to delete type index: %w", err)
 	}
+	t.countEdge(graphID, edge.Type, -1)
+	t.notifyEdge(graphID, edge, EventDeleted)
 
 	// Delete outgoing edge index
 	outIndexKey := utils.EncodeNodeOutEdgeIndexKey(graphID, edge.FromNodeID, edgeID)
@@ -448,5 +782,13 @@ func (t *BadgerTransaction) DeleteEdge(graphID models.GraphID, edgeID models.Edg
 		return fmt.Errorf("failed to delete incoming edge index: %w", err)
 	}
 
+	// Delete from expiry index if TTL was set
+	if edge.ExpiresAt != nil {
+		expiryKey := utils.EncodeEdgeExpiryIndexKey(graphID, edgeID, *edge.ExpiresAt)
+		if err := t.delete(expiryKey); err != nil {
+			return fmt.Errorf("failed to delete expiry index: %w", err)
+		}
+	}
+
 	return nil
 }
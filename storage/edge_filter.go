@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// EdgeFilter selects edges by endpoint, type and attribute. Empty fields
+// match every edge; set fields must all match.
+type EdgeFilter struct {
+	From     models.NodeID
+	To       models.NodeID
+	Type     models.EdgeType
+	FromType models.NodeType
+	ToType   models.NodeType
+
+	// AttrKey, when set, requires the attribute to equal AttrValue, compared
+	// as in FindEdgesByAttribute
+	AttrKey   string
+	AttrValue interface{}
+
+	// Limit, when positive, stops after this many matches
+	Limit int
+}
+
+// FilterEdges returns the edges matching filter in edge ID order. It scans
+// the most selective index the filter allows, the out index of From, the
+// in index of To or the type index of Type, falling back to all edges, and
+// checks the remaining conditions on each edge as it is read. Endpoint
+// types are looked up once per node.
+func (e *BadgerEngine) FilterEdges(graphID models.GraphID, filter EdgeFilter) ([]*models.Edge, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	nodeTypes := make(map[models.NodeID]models.NodeType)
+	endpointType := func(nodeID models.NodeID) models.NodeType {
+		nodeType, cached := nodeTypes[nodeID]
+		if !cached {
+			// A missing endpoint has no type, so it never matches
+			if node, err := e.GetNode(graphID, nodeID); err == nil {
+				nodeType = node.Type
+			}
+			nodeTypes[nodeID] = nodeType
+		}
+		return nodeType
+	}
+
+	var edges []*models.Edge
+	match := func(edge *models.Edge) error {
+		if filter.From != "" && edge.FromNodeID != filter.From ||
+			filter.To != "" && edge.ToNodeID != filter.To ||
+			filter.Type != "" && edge.Type != filter.Type {
+			return nil
+		}
+		if filter.AttrKey != "" {
+			value, exists := edge.Attributes.Lookup(filter.AttrKey)
+			if !exists || !models.MatchValue(value, filter.AttrValue) {
+				return nil
+			}
+		}
+		if filter.FromType != "" && endpointType(edge.FromNodeID) != filter.FromType ||
+			filter.ToType != "" && endpointType(edge.ToNodeID) != filter.ToType {
+			return nil
+		}
+		edges = append(edges, edge)
+		if filter.Limit > 0 && len(edges) >= filter.Limit {
+			return ErrStopScan
+		}
+		return nil
+	}
+
+	prefix, err := e.edgeFilterIndex(graphID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if prefix == nil {
+		if err := e.ScanEdges(graphID, match); err != nil {
+			return nil, fmt.Errorf("failed to filter edges: %w", err)
+		}
+		return edges, nil
+	}
+
+	err = e.iterateWithPrefix(prefix, func(key []byte, value []byte) error {
+		// Index values are edge IDs
+		edge, err := e.GetEdge(graphID, models.EdgeID(value))
+		if errors.Is(err, ErrEdgeNotFound) {
+			// The edge was deleted or has expired since the index was read
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return match(edge)
+	})
+	if err != nil && err != ErrStopScan {
+		return nil, fmt.Errorf("failed to filter edges: %w", err)
+	}
+	return edges, nil
+}
+
+// edgeFilterIndex returns the prefix of the index with the fewest entries
+// among those filter can use, or nil if it can use none. Entries are counted
+// from keys only, and each count stops once it exceeds the best so far.
+func (e *BadgerEngine) edgeFilterIndex(graphID models.GraphID, filter EdgeFilter) ([]byte, error) {
+	var candidates [][]byte
+	if filter.From != "" {
+		candidates = append(candidates, []byte(fmt.Sprintf("%sout:%s:%s:", utils.NodeIndexPrefix, graphID, filter.From)))
+	}
+	if filter.To != "" {
+		candidates = append(candidates, []byte(fmt.Sprintf("%sin:%s:%s:", utils.NodeIndexPrefix, graphID, filter.To)))
+	}
+	if filter.Type != "" {
+		candidates = append(candidates, utils.CreateTypeIteratorPrefix(graphID, "e", string(filter.Type)))
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, nil
+	case 1:
+		return candidates[0], nil
+	}
+
+	var best []byte
+	bestCount := -1
+	err := e.db.View(func(txn *badger.Txn) error {
+		for _, prefix := range candidates {
+			count := countWithPrefix(txn, prefix, bestCount)
+			if bestCount < 0 || count < bestCount {
+				best, bestCount = prefix, count
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to choose edge index: %w", err)
+	}
+	return best, nil
+}
@@ -1,26 +1,201 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"log/slog"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/logging"
+	"github.com/ywadi/PathwayDB/models"
 )
 
 // BadgerEngine implements the StorageEngine interface using Badger v3
 type BadgerEngine struct {
-	db         *badger.DB
-	path       string
-	ttlManager *TTLManager
+	db           *badger.DB
+	path         string
+	ttlManager   *TTLManager
+	maintenance  *MaintenanceManager
+	reindex      *ReindexManager
+	logger       *slog.Logger
+	maxSnapshots int
+	reads        *readTracker
+	activity     activityTracker
+	cache        *recordCache
+	generations  generations
+	// typeCountLocks serializes the commits changing a graph's type counts
+	typeCountLocks typeCountLocks
+	// events holds the listeners AddEventListener registered
+	events eventListeners
+
+	maintenanceInterval time.Duration
+	clock               func() time.Time
+	metaQuota           int
+	attributeKeys       attributeKeyPolicy
+	compression         *compressionPolicy
+	tracer              atomic.Pointer[transactionTracer]
+
+	// deletionLogRetention is how long deletion log entries are kept
+	deletionLogRetention time.Duration
+
+	// scanPrefetch and listPrefetch are how many values prefix scans and
+	// node and edge listings read ahead
+	scanPrefetch int
+	listPrefetch int
+
+	// offline and readOnly are set by WithOffline
+	offline  bool
+	readOnly bool
+}
+
+// DefaultMaxSnapshots is the number of snapshots kept per graph by default
+const DefaultMaxSnapshots = 10
+
+// ErrStopScan can be returned by a ScanNodes or ScanEdges callback to end the
+// scan early without an error
+var ErrStopScan = errors.New("stop scan")
+
+// Option configures a BadgerEngine
+type Option func(*BadgerEngine)
+
+// WithLogger sets the logger used by the engine and its TTL manager
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *BadgerEngine) {
+		e.logger = logger
+	}
+}
+
+// WithMaxSnapshots sets how many snapshots are kept per graph. Creating a
+// snapshot beyond the limit deletes the oldest ones; 0 keeps all of them.
+func WithMaxSnapshots(n int) Option {
+	return func(e *BadgerEngine) {
+		e.maxSnapshots = n
+	}
+}
+
+// WithMaintenanceInterval sets how often graph maintenance policies are
+// applied
+func WithMaintenanceInterval(interval time.Duration) Option {
+	return func(e *BadgerEngine) {
+		e.maintenanceInterval = interval
+	}
+}
+
+// WithClock sets the function maintenance policies, activity buckets, stats
+// history dates and deletion log entries read the current time from, so
+// tests can age nodes without waiting
+func WithClock(now func() time.Time) Option {
+	return func(e *BadgerEngine) {
+		e.clock = now
+	}
+}
+
+// WithDeletionLogRetention sets how long the deletion log keeps the node
+// and edge deletions incremental exports list; 0 keeps them all. Entries
+// are pruned by the maintenance loop.
+func WithDeletionLogRetention(retention time.Duration) Option {
+	return func(e *BadgerEngine) {
+		e.deletionLogRetention = retention
+	}
+}
+
+// WithMetaQuota sets how many bytes each metadata namespace of a graph may
+// hold, counting keys and values; 0 removes the limit.
+func WithMetaQuota(bytes int) Option {
+	return func(e *BadgerEngine) {
+		e.metaQuota = bytes
+	}
+}
+
+// WithMaxAttributeKeyLength sets the longest attribute key, in bytes, that
+// writes accept; 0 removes the limit.
+func WithMaxAttributeKeyLength(n int) Option {
+	return func(e *BadgerEngine) {
+		e.attributeKeys.maxLength = n
+	}
+}
+
+// WithAttributeLimits sets how deeply attribute values may nest, how many
+// keys they may hold and how long their strings may be, see
+// models.AttributeLimits
+func WithAttributeLimits(limits models.AttributeLimits) Option {
+	return func(e *BadgerEngine) {
+		e.attributeKeys.limits = limits
+	}
+}
+
+// WithStrictAttributeKeys makes updates check every attribute key and value
+// of the entity written rather than only the keys it did not already hold
+// and the values it changed
+func WithStrictAttributeKeys(strict bool) Option {
+	return func(e *BadgerEngine) {
+		e.attributeKeys.strict = strict
+	}
+}
+
+// WithRecordCache caches up to maxEntries node and edge records read
+// outside write transactions, using an estimated maxBytes of memory at most
+// (0 for no memory limit). maxEntries of 0 leaves the cache disabled.
+func WithRecordCache(maxEntries int, maxBytes int64) Option {
+	return func(e *BadgerEngine) {
+		if maxEntries > 0 {
+			e.cache = newRecordCache(maxEntries, maxBytes)
+		} else {
+			e.cache = nil
+		}
+	}
+}
+
+// WithCompressAbove gzips node and edge records whose serialized form is
+// larger than bytes when they are written; 0, the default, leaves records
+// uncompressed. Records are readable whether or not they were compressed,
+// so the threshold can be changed between runs.
+func WithCompressAbove(bytes int) Option {
+	return func(e *BadgerEngine) {
+		e.compression.above = bytes
+	}
+}
+
+// WithOffline prepares the engine for offline tools working on a data
+// directory while no server runs: Open starts no TTL, maintenance, reindex
+// or flushing goroutines and leaves interrupted graph deletions for Repair.
+// With readOnly, the database is opened read-only, so writes fail and the
+// directory is left as it was.
+func WithOffline(readOnly bool) Option {
+	return func(e *BadgerEngine) {
+		e.offline = true
+		e.readOnly = readOnly
+	}
 }
 
 // NewBadgerEngine creates a new BadgerEngine instance
-func NewBadgerEngine() *BadgerEngine {
-	engine := &BadgerEngine{}
+func NewBadgerEngine(opts ...Option) *BadgerEngine {
+	engine := &BadgerEngine{
+		maxSnapshots:        DefaultMaxSnapshots,
+		reads:               &readTracker{},
+		maintenanceInterval: DefaultMaintenanceInterval,
+		clock:               time.Now,
+		metaQuota:           DefaultMetaQuota,
+		attributeKeys:       attributeKeyPolicy{maxLength: DefaultMaxAttributeKeyLength, limits: models.DefaultAttributeLimits()},
+		compression:         &compressionPolicy{},
+
+		deletionLogRetention: DefaultDeletionLogRetention,
+		scanPrefetch:         DefaultScanPrefetch,
+		listPrefetch:         DefaultListPrefetch,
+	}
+	for _, opt := range opts {
+		opt(engine)
+	}
+	if engine.logger == nil {
+		engine.logger = logging.Default()
+	}
+	engine.logger = engine.logger.With("subsystem", "storage")
 	engine.ttlManager = NewTTLManager(engine)
+	engine.maintenance = NewMaintenanceManager(engine)
+	engine.reindex = NewReindexManager(engine)
 	return engine
 }
 
@@ -30,39 +205,68 @@ func (e *BadgerEngine) Open(path string) error {
 	
 	opts := badger.DefaultOptions(path)
 	opts.Logger = nil // Disable badger logging for cleaner output
+	opts.ReadOnly = e.readOnly
 	
 	var err error
 	e.db, err = badger.Open(opts)
+	// Badger flattens the errors it wraps into text, stack trace included
+	if e.readOnly && err != nil && strings.Contains(err.Error(), badger.ErrTruncateNeeded.Error()) {
+		return fmt.Errorf("database was not closed cleanly and cannot be opened read-only until it is opened for writing: %w", badger.ErrTruncateNeeded)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to open badger database: %w", err)
 	}
 	
-	log.Printf("Badger database opened at: %s", path)
+	e.logger.Info("Badger database opened", "path", path)
 
-	// Start the TTL manager
+	// The database may have changed since it was last open
+	e.ClearCache()
+	e.forgetActivity("")
+	if e.offline {
+		return nil
+	}
+
+	// Finish deleting and renaming the graphs a crash interrupted
+	e.resumeGraphDeletions()
+	e.resumeGraphRenames()
+	e.warnReservedGraphIDs()
+	e.backfillTypeCounts(false)
+
+	// Start the TTL, maintenance and reindex managers
 	e.ttlManager.Start()
+	e.maintenance.Start()
+	e.reindex.Start()
+	e.startReadFlusher()
+	e.startActivityFlusher()
 
 	return nil
 }
 
 // Close closes the Badger database
 func (e *BadgerEngine) Close() error {
-	// Stop the TTL manager first
+	// Stop the TTL, maintenance and reindex managers first
 	if e.ttlManager != nil {
 		e.ttlManager.Stop()
 	}
+	if e.maintenance != nil {
+		e.maintenance.Stop()
+	}
+	if e.reindex != nil {
+		e.reindex.Stop()
+	}
 
 	if e.db != nil {
+		e.stopReadFlusher()
+		e.stopActivityFlusher()
 		err := e.db.Close()
 		if err != nil {
 			return fmt.Errorf("failed to close badger database: %w", err)
 		}
-		log.Printf("Badger database closed")
+		e.logger.Info("Badger database closed", "path", e.path)
 	}
 	return nil
 }
 
-// Backup creates a backup of the database
 // Cleanup is a test helper to manually trigger TTL cleanup.
 func (e *BadgerEngine) Cleanup() {
 	if e.ttlManager != nil {
@@ -70,35 +274,13 @@ func (e *BadgerEngine) Cleanup() {
 	}
 }
 
-func (e *BadgerEngine) Backup(backupPath string) error {
-	if e.db == nil {
-		return fmt.Errorf("database not opened")
-	}
-	
-	backupFile := filepath.Join(backupPath, "backup.db")
-	f, err := os.Create(backupFile)
-	if err != nil {
-		return fmt.Errorf("failed to create backup file: %w", err)
-	}
-	defer f.Close()
-	
-	_, err = e.db.Backup(f, 0)
-	if err != nil {
-		return fmt.Errorf("failed to create backup: %w", err)
-	}
-	
-	log.Printf("Database backup created at: %s", backupFile)
-	return nil
-}
-
 // RunTransaction executes a function within a Badger transaction
 func (e *BadgerEngine) RunTransaction(fn TransactionFunc) error {
 	if e.db == nil {
-		return fmt.Errorf("database not opened")
+		return ErrClosed
 	}
 	
-	return e.db.Update(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
+	return e.update(func(tx *BadgerTransaction) error {
 		return fn(tx)
 	})
 }
@@ -106,7 +288,7 @@ func (e *BadgerEngine) RunTransaction(fn TransactionFunc) error {
 // RunReadOnlyTransaction executes a read-only function within a Badger transaction
 func (e *BadgerEngine) RunReadOnlyTransaction(fn func(*badger.Txn) error) error {
 	if e.db == nil {
-		return fmt.Errorf("database not opened")
+		return ErrClosed
 	}
 	
 	return e.db.View(fn)
@@ -142,33 +324,87 @@ func (e *BadgerEngine) delete(key []byte) error {
 	})
 }
 
-// iterateWithPrefix iterates over keys with a given prefix
-func (e *BadgerEngine) iterateWithPrefix(prefix []byte, fn func(key []byte, value []byte) error) error {
-	return e.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 10
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			key := item.Key()
-			
-			err := item.Value(func(value []byte) error {
-				return fn(key, value)
-			})
-			if err != nil {
-				return err
+// rewriteBatchSize is the number of index entries rewritten per transaction
+const rewriteBatchSize = 500
+
+// rewriteIndex calls fn with each key under prefix and the ID stored in it,
+// in transactions of up to rewriteBatchSize keys. fn must remove the key it
+// is given, either directly or by moving its entity to another index key, so
+// each batch starts where the previous one ended. It returns the number of
+// calls for which fn reported a change.
+func (e *BadgerEngine) rewriteIndex(prefix []byte, fn func(t *BadgerTransaction, key []byte, id string) (bool, error)) (int, error) {
+	total := 0
+	for {
+		changed, scanned := 0, 0
+		err := e.update(func(tx *BadgerTransaction) error {
+			var keys, ids [][]byte
+			it := tx.txn.NewIterator(badger.DefaultIteratorOptions)
+			for it.Seek(prefix); it.ValidForPrefix(prefix) && len(keys) < rewriteBatchSize; it.Next() {
+				item := it.Item()
+				id, err := item.ValueCopy(nil)
+				if err != nil {
+					it.Close()
+					return err
+				}
+				keys = append(keys, item.KeyCopy(nil))
+				ids = append(ids, id)
+			}
+			it.Close()
+
+			for i, key := range keys {
+				ok, err := fn(tx, key, string(ids[i]))
+				if err != nil {
+					return err
+				}
+				if ok {
+					changed++
+				}
 			}
+			scanned = len(keys)
+			return nil
+		})
+		if err != nil {
+			return total, err
 		}
-		
-		return nil
-	})
+		total += changed
+		if scanned < rewriteBatchSize {
+			return total, nil
+		}
+	}
 }
 
 // BadgerTransaction wraps a Badger transaction to implement the Transaction interface
 type BadgerTransaction struct {
-	txn *badger.Txn
+	txn           *badger.Txn
+	logger        *slog.Logger
+	touched       map[models.GraphID]struct{}
+	written       map[string]struct{}
+	deleted       int
+	attributeKeys attributeKeyPolicy
+	compression   *compressionPolicy
+	// compressed and compressionSaved count the records this transaction
+	// compressed, added to the engine's counters when it commits
+	compressed       int
+	compressionSaved int64
+	// requiresGeneration is set by RequireGeneration, so a commit conflict
+	// is reported as ErrGenerationConflict
+	requiresGeneration bool
+	// clock times the deletions the transaction logs
+	clock func() time.Time
+	// typeCounts holds the changes to the type counts of each graph the
+	// transaction wrote to, by count key, written as it commits
+	typeCounts map[models.GraphID]map[string]int64
+	// events holds the node and edge writes of the transaction, collected
+	// while collectEvents is set and published once it commits
+	events        []Event
+	collectEvents bool
+}
+
+// newTransaction wraps a Badger transaction, sharing the engine's logger,
+// attribute key policy, compression policy and clock. It collects events
+// while a listener is registered.
+func (e *BadgerEngine) newTransaction(txn *badger.Txn) *BadgerTransaction {
+	return &BadgerTransaction{txn: txn, logger: e.logger, attributeKeys: e.attributeKeys, compression: e.compression, clock: e.clock, collectEvents: e.events.active.Load()}
 }
 
 // Commit commits the transaction
@@ -181,17 +417,17 @@ func (t *BadgerTransaction) Discard() {
 	t.txn.Discard()
 }
 
-// set is a helper method for setting values within a transaction
+// set is a helper method for setting values within a transaction. Node
+// and edge records may be stored compressed.
 func (t *BadgerTransaction) set(key []byte, value []byte) error {
+	t.recordWrite(key)
+	value, err := t.compress(key, value)
+	if err != nil {
+		return err
+	}
 	return t.txn.Set(key, value)
 }
 
-// setWithTTL is a helper method for setting values with a TTL within a transaction
-func (t *BadgerTransaction) setWithTTL(key []byte, value []byte, ttl time.Duration) error {
-	e := badger.NewEntry(key, value).WithTTL(ttl)
-	return t.txn.SetEntry(e)
-}
-
 // get is a helper method for getting values within a transaction
 func (t *BadgerTransaction) get(key []byte) ([]byte, error) {
 	item, err := t.txn.Get(key)
@@ -202,7 +438,13 @@ func (t *BadgerTransaction) get(key []byte) ([]byte, error) {
 	return item.ValueCopy(nil)
 }
 
-// delete is a helper method for deleting keys within a transaction
+// delete is a helper method for deleting keys within a transaction. It
+// counts the keys deleted, which DeleteGraph reports.
 func (t *BadgerTransaction) delete(key []byte) error {
-	return t.txn.Delete(key)
+	t.recordWrite(key)
+	if err := t.txn.Delete(key); err != nil {
+		return err
+	}
+	t.deleted++
+	return nil
 }
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Errors returned by the engine, wrapped with the ID of the graph, node or
+// edge involved, so callers can tell them apart with errors.Is. The
+// messages read as before they were typed: "node not found: <id>".
+var (
+	// ErrGraphNotFound is returned when a graph does not exist
+	ErrGraphNotFound = errors.New("graph not found")
+
+	// ErrNodeNotFound is returned when a node does not exist, or has expired
+	ErrNodeNotFound = errors.New("node not found")
+
+	// ErrEdgeNotFound is returned when an edge does not exist, or has expired
+	ErrEdgeNotFound = errors.New("edge not found")
+
+	// ErrAlreadyExists is returned when creating something that must be new,
+	// such as the graph of an import, finds it exists
+	ErrAlreadyExists = errors.New("already exists")
+
+	// ErrClosed is returned by calls made before Open or after Close
+	ErrClosed = errors.New("database not opened")
+
+	// ErrConflict is returned when a transaction conflicts with one that
+	// committed while it ran; retrying it may succeed. It is Badger's error,
+	// passed through.
+	ErrConflict = badger.ErrConflict
+
+	// ErrTxnTooBig is returned when a transaction holds more writes than
+	// Badger commits at once. It is Badger's error, passed through.
+	ErrTxnTooBig = badger.ErrTxnTooBig
+)
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ywadi/PathwayDB/models"
+)
+
+// EventOp is what a write did to a node or edge
+type EventOp string
+
+const (
+	EventCreated EventOp = "created"
+	EventUpdated EventOp = "updated"
+	EventDeleted EventOp = "deleted"
+)
+
+// Event describes a node or edge written by a committed transaction
+type Event struct {
+	Graph models.GraphID `json:"graph"`
+	// Entity is "node" or "edge"
+	Entity string  `json:"entity"`
+	ID     string  `json:"id"`
+	Type   string  `json:"type"`
+	Op     EventOp `json:"op"`
+}
+
+// EventListener receives the events of a committed transaction, in the
+// order the transaction made the writes. It is called on the goroutine that
+// committed, after the commit, so it must return quickly and must not write
+// to the engine. Listeners of transactions committing concurrently may be
+// called concurrently.
+type EventListener func(events []Event)
+
+// eventListeners holds the registered listeners. Transactions only collect
+// events while there is one.
+type eventListeners struct {
+	mu        sync.RWMutex
+	next      int
+	listeners map[int]EventListener
+	active    atomic.Bool
+}
+
+// AddEventListener registers a listener for the node and edge writes of
+// every committed transaction and returns the function that removes it
+func (e *BadgerEngine) AddEventListener(listener EventListener) func() {
+	e.events.mu.Lock()
+	defer e.events.mu.Unlock()
+	if e.events.listeners == nil {
+		e.events.listeners = make(map[int]EventListener)
+	}
+	id := e.events.next
+	e.events.next++
+	e.events.listeners[id] = listener
+	e.events.active.Store(true)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.events.mu.Lock()
+			defer e.events.mu.Unlock()
+			delete(e.events.listeners, id)
+			e.events.active.Store(len(e.events.listeners) > 0)
+		})
+	}
+}
+
+// publish calls every listener with the events of a committed transaction
+func (l *eventListeners) publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, listener := range l.listeners {
+		listener(events)
+	}
+}
+
+// notifyNode records an event for a node the transaction wrote
+func (t *BadgerTransaction) notifyNode(graphID models.GraphID, node *models.Node, op EventOp) {
+	if t.collectEvents {
+		t.events = append(t.events, Event{Graph: graphID, Entity: "node", ID: string(node.ID), Type: string(node.Type), Op: op})
+	}
+}
+
+// notifyEdge records an event for an edge the transaction wrote
+func (t *BadgerTransaction) notifyEdge(graphID models.GraphID, edge *models.Edge, op EventOp) {
+	if t.collectEvents {
+		t.events = append(t.events, Event{Graph: graphID, Entity: "edge", ID: string(edge.ID), Type: string(edge.Type), Op: op})
+	}
+}
@@ -0,0 +1,409 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// exportBufferSize is the buffer between the export writer and its
+// destination, so nodes and edges are not written one small piece at a time
+const exportBufferSize = 32 << 10
+
+// ExportGraph writes a graph to w as a single JSON document with the same
+// layout as snapshot contents: {"graph":...,"nodes":[...],"edges":[...]}.
+// With withMeta set, the graph's metadata follows as "meta":[...].
+// Nodes and edges are streamed from the store one at a time, so the
+// document is never held in memory; a slow w holds back the scan.
+func (e *BadgerEngine) ExportGraph(graphID models.GraphID, w io.Writer, withMeta bool) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	graph, err := e.GetGraph(graphID)
+	if err != nil {
+		return err
+	}
+	graphJSON, err := graph.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize graph: %w", err)
+	}
+
+	out := bufio.NewWriterSize(w, exportBufferSize)
+	out.WriteString(`{"graph":`)
+	out.Write(graphJSON)
+	x := &exportWriter{out: out}
+	if err := e.exportEntities(graphID, x, nil); err != nil {
+		return err
+	}
+
+	if withMeta {
+		x.array("meta")
+		err = e.ScanMeta(graphID, func(entry *models.MetaEntry) error {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("failed to serialize metadata: %w", err)
+			}
+			return x.entity(data)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export metadata: %w", err)
+		}
+	}
+
+	out.WriteString(`]}`)
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// exportWriter writes the arrays of an export document
+type exportWriter struct {
+	out   *bufio.Writer
+	open  bool // an array is open
+	first bool // the next element is the first of its array
+}
+
+// array closes the open array, if any, and opens the named one
+func (x *exportWriter) array(name string) {
+	if x.open {
+		x.out.WriteByte(']')
+	}
+	x.out.WriteString(`,"` + name + `":[`)
+	x.open, x.first = true, true
+}
+
+// entity writes one array element, separated from the previous one
+func (x *exportWriter) entity(data []byte) error {
+	if !x.first {
+		x.out.WriteByte(',')
+	}
+	x.first = false
+	_, err := x.out.Write(data)
+	return err
+}
+
+// exportEntities writes a graph's "nodes" and "edges" arrays, leaving the
+// edges array open. If changed is set, only the nodes and edges it reports
+// true for, given their creation and update times, are written.
+func (e *BadgerEngine) exportEntities(graphID models.GraphID, x *exportWriter, changed func(created, updated time.Time) bool) error {
+	x.array("nodes")
+	err := e.ScanNodes(graphID, func(node *models.Node) error {
+		if changed != nil && !changed(node.CreatedAt, node.UpdatedAt) {
+			return nil
+		}
+		data, err := node.ToJSON()
+		if err != nil {
+			return fmt.Errorf("failed to serialize node: %w", err)
+		}
+		return x.entity(data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export nodes: %w", err)
+	}
+
+	x.array("edges")
+	err = e.ScanEdges(graphID, func(edge *models.Edge) error {
+		if changed != nil && !changed(edge.CreatedAt, edge.UpdatedAt) {
+			return nil
+		}
+		data, err := edge.ToJSON()
+		if err != nil {
+			return fmt.Errorf("failed to serialize edge: %w", err)
+		}
+		return x.entity(data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export edges: %w", err)
+	}
+	return nil
+}
+
+// ImportGraph creates graph graphID from a document written by ExportGraph,
+// keeping the exported graph settings, nodes, edges and any metadata but not
+// the exported graph ID or name. The document is read twice: the first pass validates all of it
+// without writing anything, the second writes it in transactions of
+// rewriteBatchSize entities. If a write fails, the partly imported graph is
+// deleted. It returns the number of nodes and edges imported.
+func (e *BadgerEngine) ImportGraph(graphID models.GraphID, r io.ReadSeeker) (int, int, error) {
+	if e.db == nil {
+		return 0, 0, ErrClosed
+	}
+
+	if err := models.ValidateID("graph", string(graphID)); err != nil {
+		return 0, 0, err
+	}
+	if err := utils.CheckGraphID(graphID); err != nil {
+		return 0, 0, err
+	}
+	if _, err := e.GetGraph(graphID); err == nil {
+		return 0, 0, fmt.Errorf("graph %w: %s", ErrAlreadyExists, graphID)
+	}
+
+	// Validate: every entity is well formed, IDs are unique and edges only
+	// connect nodes of the document in ways the graph allows
+	var schema *models.Graph
+	nodeIDs := make(map[models.NodeID]struct{})
+	edgeIDs := make(map[models.EdgeID]struct{})
+	metaKeys := make(map[string]struct{})
+	metaUsed := make(map[string]int)
+	err := readExportDocument(r, exportVisitor{
+		graph: func(graph *models.Graph) error {
+			schema = graph
+			return e.attributeKeys.check("graph", graph.Attributes, nil)
+		},
+		node: func(node *models.Node) error {
+			if node.ID == "" || node.Type == "" {
+				return fmt.Errorf("node %q needs an ID and a type", node.ID)
+			}
+			if err := node.Validate(); err != nil {
+				return err
+			}
+			if err := e.attributeKeys.check("node", node.Attributes, nil); err != nil {
+				return err
+			}
+			if _, exists := nodeIDs[node.ID]; exists {
+				return fmt.Errorf("duplicate node: %s", node.ID)
+			}
+			nodeIDs[node.ID] = struct{}{}
+			return nil
+		},
+		edge: func(edge *models.Edge) error {
+			if edge.ID == "" || edge.Type == "" {
+				return fmt.Errorf("edge %q needs an ID and a type", edge.ID)
+			}
+			if err := edge.Validate(); err != nil {
+				return err
+			}
+			if err := e.attributeKeys.check("edge", edge.Attributes, nil); err != nil {
+				return err
+			}
+			if _, exists := edgeIDs[edge.ID]; exists {
+				return fmt.Errorf("duplicate edge: %s", edge.ID)
+			}
+			edgeIDs[edge.ID] = struct{}{}
+			for _, nodeID := range []models.NodeID{edge.FromNodeID, edge.ToNodeID} {
+				// Weak edges may name the node they are dangling at
+				if _, exists := nodeIDs[nodeID]; !exists && !(edge.Weak && edge.DanglingEnd(nodeID)) {
+					return fmt.Errorf("edge %s references unknown node: %s", edge.ID, nodeID)
+				}
+			}
+			if edge.FromNodeID == edge.ToNodeID && !schema.SelfLoopsAllowed(edge.Type) {
+				return fmt.Errorf("self-loop rejected: edge %s connects node %s to itself", edge.ID, edge.FromNodeID)
+			}
+			return nil
+		},
+		meta: func(entry *models.MetaEntry) error {
+			if err := entry.Validate(); err != nil {
+				return err
+			}
+			key := entry.Namespace + ":" + entry.Key
+			if _, exists := metaKeys[key]; exists {
+				return fmt.Errorf("duplicate metadata: %s %s", entry.Namespace, entry.Key)
+			}
+			metaKeys[key] = struct{}{}
+			metaUsed[entry.Namespace] += entry.Size()
+			if e.metaQuota > 0 && metaUsed[entry.Namespace] > e.metaQuota {
+				return fmt.Errorf("%w: namespace %s holds more than %d bytes", ErrMetaQuota, entry.Namespace, e.metaQuota)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid export document: %w", err)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("failed to rewind export document: %w", err)
+	}
+
+	// Write
+	imported := &importBatch{engine: e}
+	err = readExportDocument(r, exportVisitor{
+		graph: func(graph *models.Graph) error {
+			graph.ID = graphID
+			graph.Name = string(graphID)
+			return e.CreateGraph(graph)
+		},
+		node: func(node *models.Node) error {
+			return imported.add(func(tx *BadgerTransaction) error {
+				return tx.CreateNode(graphID, node)
+			})
+		},
+		edge: func(edge *models.Edge) error {
+			return imported.add(func(tx *BadgerTransaction) error {
+				return tx.createEdge(graphID, edge, true)
+			})
+		},
+		meta: func(entry *models.MetaEntry) error {
+			var value bytes.Buffer
+			if err := json.Compact(&value, entry.Value); err != nil {
+				return fmt.Errorf("failed to compact metadata value: %w", err)
+			}
+			return imported.add(func(tx *BadgerTransaction) error {
+				return tx.txn.Set(utils.EncodeMetaKey(graphID, entry.Namespace, entry.Key), value.Bytes())
+			})
+		},
+	})
+	if err == nil {
+		err = imported.flush()
+	}
+	if err != nil {
+		if _, deleteErr := e.DeleteGraph(graphID); deleteErr != nil {
+			e.logger.Warn("Failed to remove partly imported graph", "graph", graphID, "error", deleteErr)
+		}
+		return 0, 0, fmt.Errorf("failed to import graph: %w", err)
+	}
+	return len(nodeIDs), len(edgeIDs), nil
+}
+
+// importBatch collects writes until rewriteBatchSize of them can be
+// committed together
+type importBatch struct {
+	engine  *BadgerEngine
+	pending []func(tx *BadgerTransaction) error
+}
+
+// add queues a write and commits the batch once it is full
+func (b *importBatch) add(write func(tx *BadgerTransaction) error) error {
+	b.pending = append(b.pending, write)
+	if len(b.pending) < rewriteBatchSize {
+		return nil
+	}
+	return b.flush()
+}
+
+// flush commits the queued writes
+func (b *importBatch) flush() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	err := b.engine.update(func(tx *BadgerTransaction) error {
+		for _, write := range b.pending {
+			if err := write(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	b.pending = b.pending[:0]
+	return err
+}
+
+// exportVisitor receives the parts of an export document as they are read
+type exportVisitor struct {
+	graph func(graph *models.Graph) error
+	node  func(node *models.Node) error
+	edge  func(edge *models.Edge) error
+	meta  func(entry *models.MetaEntry) error
+}
+
+// readExportDocument decodes an export document one entity at a time. The
+// graph must come first and nodes before edges, as ExportGraph writes them,
+// so edges can be checked against the nodes already seen. Metadata is
+// optional and comes last.
+func readExportDocument(r io.Reader, visitor exportVisitor) error {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	for _, field := range []string{"graph", "nodes", "edges"} {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if name, _ := token.(string); name != field {
+			return fmt.Errorf("expected field %q, got %v", field, token)
+		}
+
+		if field == "graph" {
+			graph := &models.Graph{}
+			if err := decoder.Decode(graph); err != nil {
+				return fmt.Errorf("failed to decode graph: %w", err)
+			}
+			if err := visitor.graph(graph); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := expectDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			if field == "nodes" {
+				node := &models.Node{}
+				if err := decoder.Decode(node); err != nil {
+					return fmt.Errorf("failed to decode node: %w", err)
+				}
+				if err := visitor.node(node); err != nil {
+					return err
+				}
+			} else {
+				edge := &models.Edge{}
+				if err := decoder.Decode(edge); err != nil {
+					return fmt.Errorf("failed to decode edge: %w", err)
+				}
+				if err := visitor.edge(edge); err != nil {
+					return err
+				}
+			}
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return err
+		}
+	}
+
+	if decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if name, _ := token.(string); name != "meta" {
+			return fmt.Errorf("expected field %q, got %v", "meta", token)
+		}
+		if err := expectDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			entry := &models.MetaEntry{}
+			if err := decoder.Decode(entry); err != nil {
+				return fmt.Errorf("failed to decode metadata: %w", err)
+			}
+			if err := visitor.meta(entry); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return err
+		}
+	}
+
+	if err := expectDelim(decoder, '}'); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the document")
+	}
+	return nil
+}
+
+// expectDelim reads the next token and checks that it is delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %q, got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// ErrGenerationConflict is returned by a transaction that required a graph
+// generation the graph has moved past, before or while it ran. Errors
+// wrapping it start with "CONFLICT" and are sent to clients without the
+// generic ERR prefix.
+var ErrGenerationConflict = errors.New("CONFLICT")
+
+// generations counts committed writes per graph. The counters live in memory
+// only: they start at 0 when the engine is created and are meant for
+// detecting changes within one process, such as invalidating cached results.
+type generations struct {
+	mu     sync.RWMutex
+	base   uint64
+	counts map[models.GraphID]uint64
+}
+
+// advance moves the generation of each given graph forward
+func (g *generations) advance(graphIDs map[models.GraphID]struct{}) {
+	if len(graphIDs) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.counts == nil {
+		g.counts = make(map[models.GraphID]uint64)
+	}
+	for graphID := range graphIDs {
+		g.counts[graphID]++
+	}
+}
+
+// advanceAll moves every graph's generation forward, including graphs that
+// have not been written yet, for changes that bypass transactions
+func (g *generations) advanceAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.base++
+}
+
+// get returns the current generation of a graph
+func (g *generations) get(graphID models.GraphID) uint64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.base + g.counts[graphID]
+}
+
+// Generation returns a counter that advances after every committed write
+// that creates, updates or deletes a node or edge of the graph. Edges that
+// Badger expires on its own do not advance it.
+func (e *BadgerEngine) Generation(graphID models.GraphID) uint64 {
+	return e.generations.get(graphID)
+}
+
+// CommittedGeneration returns the generation clients check writes against
+// with RequireGeneration. Every transaction that touches a node or edge of
+// the graph writes the graph's generation key, and the generation is the
+// version Badger committed that key at, so it is stored with the data and
+// changes with each committed write, across restarts too. It is 0 for a
+// graph that has not been written. Unlike Generation it is not a count:
+// only equality between two generations is meaningful.
+func (e *BadgerEngine) CommittedGeneration(graphID models.GraphID) (uint64, error) {
+	if e.db == nil {
+		return 0, ErrClosed
+	}
+	var generation uint64
+	err := e.db.View(func(txn *badger.Txn) error {
+		var err error
+		generation, err = committedGeneration(txn, graphID)
+		return err
+	})
+	return generation, err
+}
+
+// committedGeneration reads the generation of a graph within txn
+func committedGeneration(txn *badger.Txn, graphID models.GraphID) (uint64, error) {
+	item, err := txn.Get(utils.EncodeGenerationKey(graphID))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return item.Version(), nil
+}
+
+// RequireGeneration fails the transaction with ErrGenerationConflict unless
+// the graph is still at generation, as returned by CommittedGeneration. The
+// generation key is read within the transaction, so a write to the graph
+// committed after the check and before this transaction commits also fails
+// it with ErrGenerationConflict.
+func (t *BadgerTransaction) RequireGeneration(graphID models.GraphID, generation uint64) error {
+	current, err := committedGeneration(t.txn, graphID)
+	if err != nil {
+		return err
+	}
+	t.requiresGeneration = true
+	if current != generation {
+		return fmt.Errorf("%w graph %s is at generation %d, not %d", ErrGenerationConflict, graphID, current, generation)
+	}
+	return nil
+}
+
+// update runs fn in a read-write transaction and, once it has committed,
+// advances the generation of every graph fn wrote to, drops the node and
+// edge records it wrote from the record cache and publishes its events. The
+// generation moves first, so a read that began before the commit cannot
+// cache what it replaced.
+// Transactions sampled by SetTracing are traced from begin to commit.
+func (e *BadgerEngine) update(fn func(tx *BadgerTransaction) error) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	span := e.traceTransaction()
+	var tx *BadgerTransaction
+	unlock := func() {}
+	err := e.db.Update(func(txn *badger.Txn) error {
+		tx = e.newTransaction(txn)
+		err := fn(tx)
+		if err == nil {
+			unlock, err = e.writeTypeCounts(tx)
+		}
+		if span != nil && err == nil {
+			span.AddEvent("commit")
+		}
+		return err
+	})
+	unlock()
+	endTransaction(span, tx, err)
+	if errors.Is(err, badger.ErrConflict) && tx != nil && tx.requiresGeneration {
+		return fmt.Errorf("%w graph changed while the write was applied", ErrGenerationConflict)
+	}
+	if err != nil {
+		return err
+	}
+	e.generations.advance(tx.touched)
+	if e.cache != nil {
+		e.cache.invalidate(tx.written)
+	}
+	e.recordCompression(tx)
+	e.events.publish(tx.events)
+	return nil
+}
+
+// touch records that the transaction wrote to a graph, and writes the
+// graph's generation key the first time, which CommittedGeneration reads
+// the commit version of. The key is written without being read, so
+// transactions writing to the same graph do not conflict over it.
+func (t *BadgerTransaction) touch(graphID models.GraphID) {
+	if _, ok := t.touched[graphID]; ok {
+		return
+	}
+	t.invalidate(graphID)
+	if err := t.txn.Set(utils.EncodeGenerationKey(graphID), nil); err != nil {
+		t.logger.Warn("failed to write graph generation", "graph", graphID, "error", err)
+	}
+}
+
+// invalidate records that the transaction changed a graph, so its
+// generation advances once it commits, without writing its generation key.
+// Deleting a graph uses it to leave no generation key behind.
+func (t *BadgerTransaction) invalidate(graphID models.GraphID) {
+	if t.touched == nil {
+		t.touched = make(map[models.GraphID]struct{})
+	}
+	t.touched[graphID] = struct{}{}
+}
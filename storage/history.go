@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// DefaultStatsHistoryDays is how many days of stats snapshots are kept per
+// graph by default
+const DefaultStatsHistoryDays = 90
+
+// RecordStatsSnapshot stores a graph's stats snapshot under the current
+// date, replacing a snapshot recorded earlier the same day, and deletes the
+// graph's snapshots older than the last retainDays days in the same
+// transaction. A retainDays of 0 keeps every snapshot.
+func (e *BadgerEngine) RecordStatsSnapshot(graphID models.GraphID, snapshot *models.StatsSnapshot, retainDays int) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+	if retainDays < 0 {
+		return fmt.Errorf("retention must not be negative: %d", retainDays)
+	}
+
+	now := e.clock().UTC()
+	snapshot.Date = now.Format(models.StatsDateLayout)
+	snapshot.RecordedAt = now
+	value, err := snapshot.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize stats snapshot: %w", err)
+	}
+
+	return e.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(utils.EncodeGraphKey(graphID)); err != nil {
+			if err == badger.ErrKeyNotFound {
+				return fmt.Errorf("%w: %s", ErrGraphNotFound, graphID)
+			}
+			return err
+		}
+		if err := txn.Set(utils.EncodeStatsHistoryKey(graphID, snapshot.Date), value); err != nil {
+			return fmt.Errorf("failed to store stats snapshot: %w", err)
+		}
+		if retainDays == 0 {
+			return nil
+		}
+
+		// Dates sort in key order, so the expired snapshots come first
+		cutoff := now.AddDate(0, 0, 1-retainDays).Format(models.StatsDateLayout)
+		var expired [][]byte
+		err := scanStatsHistory(txn, graphID, func(key []byte, date string) (bool, error) {
+			if date >= cutoff {
+				return false, nil
+			}
+			expired = append(expired, key)
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := txn.Delete(key); err != nil {
+				return fmt.Errorf("failed to prune stats history: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// StatsHistory returns a graph's stats snapshots of the last days days,
+// including today, oldest first. Days without a snapshot are skipped.
+func (e *BadgerEngine) StatsHistory(graphID models.GraphID, days int) ([]*models.StatsSnapshot, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1: %d", days)
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+
+	first := e.clock().UTC().AddDate(0, 0, 1-days).Format(models.StatsDateLayout)
+	var snapshots []*models.StatsSnapshot
+	err := e.db.View(func(txn *badger.Txn) error {
+		return scanStatsHistory(txn, graphID, func(key []byte, date string) (bool, error) {
+			if date < first {
+				return true, nil
+			}
+			item, err := txn.Get(key)
+			if err != nil {
+				return false, err
+			}
+			snapshot := &models.StatsSnapshot{}
+			if err := item.Value(snapshot.FromJSON); err != nil {
+				return false, fmt.Errorf("failed to deserialize stats snapshot: %w", err)
+			}
+			snapshots = append(snapshots, snapshot)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats history: %w", err)
+	}
+	return snapshots, nil
+}
+
+// scanStatsHistory calls fn with the key and date of each of a graph's stats
+// snapshots in date order, until fn returns false. Keys of other graphs
+// whose ID starts with the graph's ID and ":" share the prefix and are
+// skipped, as what follows the prefix is not a bare date.
+func scanStatsHistory(txn *badger.Txn, graphID models.GraphID, fn func(key []byte, date string) (bool, error)) error {
+	prefix := utils.CreateStatsHistoryIteratorPrefix(graphID)
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().KeyCopy(nil)
+		date := string(key[len(prefix):])
+		if len(date) != len(models.StatsDateLayout) || strings.Contains(date, ":") {
+			continue
+		}
+		more, err := fn(key, date)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
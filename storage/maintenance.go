@@ -0,0 +1,396 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// DefaultMaintenanceInterval is how often graph maintenance policies are
+// applied by default
+const DefaultMaintenanceInterval = time.Minute
+
+// defaultPruneBatchSize is the number of nodes deleted per transaction when
+// a policy does not set one
+const defaultPruneBatchSize = 100
+
+// maxListedPrunes bounds the node IDs a MaintenanceRun lists per rule
+const maxListedPrunes = 100
+
+// MaintenanceManager applies graph maintenance policies in the background,
+// alongside the TTL manager.
+type MaintenanceManager struct {
+	engine *BadgerEngine
+	stop   chan struct{}
+	done   chan struct{}
+
+	// runMu serializes runs, so the loop and GRAPH.POLICY STATUS PREVIEW
+	// never evaluate a graph at the same time
+	runMu sync.Mutex
+
+	// orphanSince holds when each graph's nodes were first seen without
+	// edges. It is kept in memory, so after a restart orphans wait their
+	// full age again before they are pruned.
+	orphanSince map[models.GraphID]map[models.NodeID]time.Time
+}
+
+// pruneCandidate is a node a run intends to delete
+type pruneCandidate struct {
+	nodeID models.NodeID
+	orphan bool
+}
+
+// NewMaintenanceManager creates a new maintenance manager
+func NewMaintenanceManager(engine *BadgerEngine) *MaintenanceManager {
+	return &MaintenanceManager{
+		engine:      engine,
+		orphanSince: make(map[models.GraphID]map[models.NodeID]time.Time),
+	}
+}
+
+// Start begins applying policies every maintenance interval
+func (m *MaintenanceManager) Start() {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(m.stop, m.done)
+}
+
+// Stop halts the background loop and waits for an in-flight run to finish
+func (m *MaintenanceManager) Stop() {
+	if m.done == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.done = nil
+}
+
+// run is the main loop for the maintenance manager
+func (m *MaintenanceManager) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(m.engine.maintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.sweep()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sweep applies the policy of every graph that has a rule enabled, records
+// each run and prunes every graph's deletion log
+func (m *MaintenanceManager) sweep() {
+	graphs, err := m.engine.ListGraphs()
+	if err != nil {
+		m.engine.logger.Warn("failed to list graphs for maintenance", "error", err)
+		return
+	}
+
+	active := make(map[models.GraphID]bool)
+	for _, graph := range graphs {
+		if !graph.Maintenance.Enabled() {
+			continue
+		}
+		active[graph.ID] = true
+		run, err := m.apply(graph, false)
+		if err != nil {
+			m.engine.logger.Warn("maintenance run failed", "graph", graph.ID, "error", err)
+			continue
+		}
+
+		// Quiet runs are only interesting when debugging.
+		level := slog.LevelDebug
+		if run.Orphans+run.Stale+run.Failed > 0 {
+			level = slog.LevelInfo
+		}
+		m.engine.logger.Log(context.Background(), level, "Maintenance run completed", "graph", graph.ID,
+			"orphans", run.Orphans, "stale", run.Stale, "failed", run.Failed, "dry_run", run.DryRun, "duration", run.Duration)
+	}
+
+	// Prune deletion log entries past the retention, whatever the policy
+	if retention := m.engine.deletionLogRetention; retention > 0 {
+		cutoff := m.engine.clock().Add(-retention)
+		for _, graph := range graphs {
+			pruned, err := m.engine.pruneDeletionLog(graph.ID, cutoff)
+			if err != nil {
+				m.engine.logger.Warn("failed to prune deletion log", "graph", graph.ID, "error", err)
+				continue
+			}
+			if pruned > 0 {
+				m.engine.logger.Debug("Deletion log pruned", "graph", graph.ID, "entries", pruned)
+			}
+		}
+	}
+
+	// Forget the orphans of graphs whose policy was removed or disabled
+	m.runMu.Lock()
+	for graphID := range m.orphanSince {
+		if !active[graphID] {
+			delete(m.orphanSince, graphID)
+		}
+	}
+	m.runMu.Unlock()
+}
+
+// apply evaluates a graph's policy and deletes the qualifying nodes, unless
+// preview is set or the policy is a dry run. Runs other than previews are
+// recorded for GetMaintenanceRun.
+func (m *MaintenanceManager) apply(graph *models.Graph, preview bool) (*models.MaintenanceRun, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	policy := graph.Maintenance
+	if policy == nil {
+		policy = &models.MaintenancePolicy{}
+	}
+	now := m.engine.clock()
+	run := &models.MaintenanceRun{StartedAt: now, DryRun: preview || policy.DryRun}
+
+	candidates, err := m.candidates(graph.ID, policy, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if run.DryRun {
+		for _, candidate := range candidates {
+			recordPrune(run, candidate)
+		}
+	} else {
+		batchSize := policy.BatchSize
+		if batchSize == 0 {
+			batchSize = defaultPruneBatchSize
+		}
+		staleCutoff := now.Add(-time.Duration(policy.PruneStaleAfter) * time.Second)
+		for start := 0; start < len(candidates); start += batchSize {
+			end := start + batchSize
+			if end > len(candidates) {
+				end = len(candidates)
+			}
+			batch := candidates[start:end]
+			var deleted []pruneCandidate
+			err := m.engine.update(func(tx *BadgerTransaction) error {
+				deleted = deleted[:0]
+				for _, candidate := range batch {
+					// The node may have changed since it was scanned
+					node, err := tx.GetNode(graph.ID, candidate.nodeID)
+					if err != nil {
+						continue
+					}
+					if candidate.orphan {
+						connected, err := hasEdges(tx.txn, graph.ID, node.ID)
+						if err != nil {
+							return err
+						}
+						if connected {
+							continue
+						}
+					} else if !node.UpdatedBefore(staleCutoff) {
+						continue
+					}
+					if err := tx.DeleteNode(graph.ID, node.ID); err != nil {
+						return err
+					}
+					deleted = append(deleted, candidate)
+				}
+				return nil
+			})
+			if err != nil {
+				m.engine.logger.Warn("failed to prune nodes", "graph", graph.ID, "nodes", len(batch), "error", err)
+				run.Failed += len(batch)
+				continue
+			}
+			for _, candidate := range deleted {
+				recordPrune(run, candidate)
+				if candidate.orphan {
+					delete(m.orphanSince[graph.ID], candidate.nodeID)
+				}
+			}
+		}
+	}
+	run.Duration = m.engine.clock().Sub(now)
+
+	if !preview {
+		value, err := json.Marshal(run)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize maintenance run: %w", err)
+		}
+		if err := m.engine.set(utils.EncodeMaintenanceKey(graph.ID), value); err != nil {
+			return nil, fmt.Errorf("failed to record maintenance run: %w", err)
+		}
+	}
+	return run, nil
+}
+
+// candidates scans a graph for the nodes its policy would prune, in node
+// key order. Orphans are checked first, so a node that is both an old
+// orphan and stale counts as an orphan. It also updates when the graph's
+// current orphans were first seen.
+func (m *MaintenanceManager) candidates(graphID models.GraphID, policy *models.MaintenancePolicy, now time.Time) ([]pruneCandidate, error) {
+	if !policy.Enabled() {
+		delete(m.orphanSince, graphID)
+		return nil, nil
+	}
+
+	orphanCutoff := now.Add(-time.Duration(policy.PruneOrphansAfter) * time.Second)
+	staleCutoff := now.Add(-time.Duration(policy.PruneStaleAfter) * time.Second)
+	previous := m.orphanSince[graphID]
+	seen := make(map[models.NodeID]time.Time)
+
+	var candidates []pruneCandidate
+	err := m.engine.db.View(func(txn *badger.Txn) error {
+		prefix := utils.CreateNodeIteratorPrefix(graphID)
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			node := &models.Node{}
+			if err := it.Item().Value(node.FromJSON); err != nil {
+				return fmt.Errorf("failed to deserialize node: %w", err)
+			}
+			if node.IsExpired() {
+				// The TTL manager deletes it
+				continue
+			}
+
+			if policy.PruneOrphans {
+				connected, err := hasEdges(txn, graphID, node.ID)
+				if err != nil {
+					return err
+				}
+				if !connected {
+					since, ok := previous[node.ID]
+					if !ok {
+						since = now
+					}
+					seen[node.ID] = since
+					if !since.After(orphanCutoff) {
+						candidates = append(candidates, pruneCandidate{nodeID: node.ID, orphan: true})
+						continue
+					}
+				}
+			}
+			if policy.PruneStale && node.UpdatedBefore(staleCutoff) {
+				candidates = append(candidates, pruneCandidate{nodeID: node.ID})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan nodes: %w", err)
+	}
+
+	m.orphanSince[graphID] = seen
+	return candidates, nil
+}
+
+// forget drops what the manager remembers about a graph
+func (m *MaintenanceManager) forget(graphID models.GraphID) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	delete(m.orphanSince, graphID)
+}
+
+// hasEdges reports whether a node has an edge in either direction that
+// exists, has not expired and is not dangling. Index entries are checked against the edge,
+// as the index prefix of a node also matches IDs it is a prefix of.
+func hasEdges(txn *badger.Txn, graphID models.GraphID, nodeID models.NodeID) (bool, error) {
+	for _, direction := range []string{"out", "in"} {
+		prefix := []byte(fmt.Sprintf("%s%s:%s:%s:", utils.NodeIndexPrefix, direction, graphID, nodeID))
+		found, err := func() (bool, error) {
+			it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+			defer it.Close()
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				edgeID, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					return false, err
+				}
+				item, err := txn.Get(utils.EncodeEdgeKey(graphID, models.EdgeID(edgeID)))
+				if err == badger.ErrKeyNotFound {
+					continue
+				}
+				if err != nil {
+					return false, err
+				}
+				edge := &models.Edge{}
+				if err := item.Value(edge.FromJSON); err != nil {
+					return false, fmt.Errorf("failed to deserialize edge: %w", err)
+				}
+				if !edge.IsExpired() && !edge.Dangling() && (edge.FromNodeID == nodeID || edge.ToNodeID == nodeID) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}()
+		if found || err != nil {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// recordPrune counts a pruned node in run and lists it while there is room
+func recordPrune(run *models.MaintenanceRun, candidate pruneCandidate) {
+	if candidate.orphan {
+		run.Orphans++
+		if len(run.OrphanNodes) < maxListedPrunes {
+			run.OrphanNodes = append(run.OrphanNodes, candidate.nodeID)
+		}
+		return
+	}
+	run.Stale++
+	if len(run.StaleNodes) < maxListedPrunes {
+		run.StaleNodes = append(run.StaleNodes, candidate.nodeID)
+	}
+}
+
+// PruneGraph applies a graph's maintenance policy now, as the background
+// loop does, and returns what it pruned. With preview set nothing is
+// deleted or recorded, and the result lists what would be pruned.
+func (e *BadgerEngine) PruneGraph(graphID models.GraphID, preview bool) (*models.MaintenanceRun, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	graph, err := e.GetGraph(graphID)
+	if err != nil {
+		return nil, err
+	}
+	return e.maintenance.apply(graph, preview)
+}
+
+// GetMaintenanceRun returns the last recorded maintenance run of a graph,
+// or nil if its policy has not run yet
+func (e *BadgerEngine) GetMaintenanceRun(graphID models.GraphID) (*models.MaintenanceRun, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	if _, err := e.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+	value, err := e.get(utils.EncodeMaintenanceKey(graphID))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance run: %w", err)
+	}
+
+	run := &models.MaintenanceRun{}
+	if err := json.Unmarshal(value, run); err != nil {
+		return nil, fmt.Errorf("failed to deserialize maintenance run: %w", err)
+	}
+	return run, nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// MergePolicy decides what MergeGraph does with a node or edge ID both
+// graphs hold
+type MergePolicy string
+
+const (
+	// MergeSkip keeps the destination's version
+	MergeSkip MergePolicy = "skip"
+	// MergeOverwrite replaces the destination's version with the source's
+	MergeOverwrite MergePolicy = "overwrite"
+	// MergeError aborts the merge before anything is written
+	MergeError MergePolicy = "error"
+)
+
+// ErrMergeConflict is returned when a merge with MergeError finds an ID both
+// graphs hold
+var ErrMergeConflict = errors.New("merge conflict")
+
+// MergeResult counts what a merge did with the source's nodes and edges
+type MergeResult struct {
+	NodesAdded       int `json:"nodes_added"`
+	NodesSkipped     int `json:"nodes_skipped"`
+	NodesOverwritten int `json:"nodes_overwritten"`
+	EdgesAdded       int `json:"edges_added"`
+	EdgesSkipped     int `json:"edges_skipped"`
+	EdgesOverwritten int `json:"edges_overwritten"`
+}
+
+// MergeGraph copies the nodes and edges of src into dst, nodes first so
+// every edge finds its endpoints. A node or edge is taken whole from one
+// side; with MergeSkip dst keeps its version of an ID both graphs hold and
+// with MergeOverwrite src's replaces it. Conflicts under MergeError,
+// self-loops dst forbids and attribute keys the policy rejects are found
+// before anything is written, so such a merge leaves dst unchanged. Otherwise writes are committed in batches,
+// and a failure part way leaves earlier batches merged. Expired entities,
+// and edges with an expired endpoint, are not copied.
+func (e *BadgerEngine) MergeGraph(dst, src models.GraphID, policy MergePolicy) (*MergeResult, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+	if policy != MergeSkip && policy != MergeOverwrite && policy != MergeError {
+		return nil, fmt.Errorf("invalid merge policy: %s", policy)
+	}
+	if dst == src {
+		return nil, fmt.Errorf("cannot merge graph %s into itself", dst)
+	}
+	dstGraph, err := e.GetGraph(dst)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := e.GetGraph(src); err != nil {
+		return nil, err
+	}
+
+	// Read src at one point in time and check it against dst
+	var nodes []*models.Node
+	var edges []*models.Edge
+	err = e.db.View(func(txn *badger.Txn) error {
+		copied := make(map[models.NodeID]bool)
+		if err := iterateTxnPrefix(txn, utils.CreateNodeIteratorPrefix(src), func(value []byte) error {
+			node := &models.Node{}
+			if err := node.FromJSON(value); err != nil {
+				return fmt.Errorf("failed to deserialize node: %w", err)
+			}
+			if !node.IsExpired() {
+				nodes = append(nodes, node)
+				copied[node.ID] = true
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := iterateTxnPrefix(txn, utils.CreateEdgeIteratorPrefix(src), func(value []byte) error {
+			edge := &models.Edge{}
+			if err := edge.FromJSON(value); err != nil {
+				return fmt.Errorf("failed to deserialize edge: %w", err)
+			}
+			if !edge.IsExpired() && copied[edge.FromNodeID] && copied[edge.ToNodeID] {
+				edges = append(edges, edge)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			if err := e.attributeKeys.check("node", node.Attributes, nil); err != nil {
+				return err
+			}
+		}
+		for _, edge := range edges {
+			if err := e.attributeKeys.check("edge", edge.Attributes, nil); err != nil {
+				return err
+			}
+			if edge.FromNodeID == edge.ToNodeID && !dstGraph.SelfLoopsAllowed(edge.Type) {
+				return fmt.Errorf("self-loop rejected: edge %s connects node %s to itself and graph %s forbids self-loops for edge type %s",
+					edge.ID, edge.FromNodeID, dst, edge.Type)
+			}
+		}
+		if policy != MergeError {
+			return nil
+		}
+		tx := e.newTransaction(txn)
+		for _, node := range nodes {
+			if existing, err := tx.GetNode(dst, node.ID); err == nil && !existing.IsExpired() {
+				return fmt.Errorf("%w: node %s exists in both graphs", ErrMergeConflict, node.ID)
+			}
+		}
+		for _, edge := range edges {
+			if existing, err := tx.GetEdge(dst, edge.ID); err == nil && !existing.IsExpired() {
+				return fmt.Errorf("%w: edge %s exists in both graphs", ErrMergeConflict, edge.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge graph: %w", err)
+	}
+
+	// An expired entity dst still holds is replaced as if it were absent
+	result := &MergeResult{}
+	merged := &importBatch{engine: e}
+	for _, node := range nodes {
+		node := node
+		err := merged.add(func(tx *BadgerTransaction) error {
+			existing, err := tx.GetNode(dst, node.ID)
+			switch {
+			case err != nil:
+				result.NodesAdded++
+				return tx.CreateNode(dst, node)
+			case existing.IsExpired():
+				result.NodesAdded++
+			case policy == MergeSkip:
+				result.NodesSkipped++
+				return nil
+			case policy == MergeError:
+				return fmt.Errorf("%w: node %s exists in both graphs", ErrMergeConflict, node.ID)
+			default:
+				result.NodesOverwritten++
+			}
+			return tx.UpdateNode(dst, node)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge graph: %w", err)
+		}
+	}
+	for _, edge := range edges {
+		edge := edge
+		err := merged.add(func(tx *BadgerTransaction) error {
+			existing, err := tx.GetEdge(dst, edge.ID)
+			switch {
+			case err != nil:
+				result.EdgesAdded++
+				return tx.CreateEdge(dst, edge)
+			case existing.IsExpired():
+				result.EdgesAdded++
+			case policy == MergeSkip:
+				result.EdgesSkipped++
+				return nil
+			case policy == MergeError:
+				return fmt.Errorf("%w: edge %s exists in both graphs", ErrMergeConflict, edge.ID)
+			default:
+				result.EdgesOverwritten++
+			}
+			return tx.UpdateEdge(dst, edge)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge graph: %w", err)
+		}
+	}
+	if err := merged.flush(); err != nil {
+		return nil, fmt.Errorf("failed to merge graph: %w", err)
+	}
+	return result, nil
+}
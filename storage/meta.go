@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// DefaultMetaQuota is the number of bytes each metadata namespace of a graph
+// may hold by default
+const DefaultMetaQuota = 1 << 20
+
+// ErrMetaQuota is returned when a write would take a metadata namespace
+// over its quota
+var ErrMetaQuota = errors.New("metadata quota exceeded")
+
+// SetMeta stores a metadata value, replacing any value under the same key.
+// The value is stored in compact form and counts against the quota of its
+// namespace. Metadata is not part of the graph's data, so writing it does
+// not advance the graph's generation.
+func (e *BadgerEngine) SetMeta(graphID models.GraphID, entry *models.MetaEntry) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, entry.Value); err != nil {
+		return fmt.Errorf("failed to compact metadata value: %w", err)
+	}
+	entry.Value = compact.Bytes()
+
+	if _, err := e.GetGraph(graphID); err != nil {
+		return err
+	}
+
+	return e.db.Update(func(txn *badger.Txn) error {
+		if e.metaQuota > 0 {
+			if size := metaUsage(txn, graphID, entry.Namespace, entry.Key) + entry.Size(); size > e.metaQuota {
+				return fmt.Errorf("%w: namespace %s of graph %s would hold %d bytes (limit %d)",
+					ErrMetaQuota, entry.Namespace, graphID, size, e.metaQuota)
+			}
+		}
+		return txn.Set(utils.EncodeMetaKey(graphID, entry.Namespace, entry.Key), entry.Value)
+	})
+}
+
+// metaUsage returns the bytes a metadata namespace holds, not counting the
+// entry under except, which is about to be replaced
+func metaUsage(txn *badger.Txn, graphID models.GraphID, namespace string, except string) int {
+	prefix := utils.CreateMetaIteratorPrefix(graphID, namespace)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false // Value sizes are known without reading them
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	used := 0
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		key := string(item.Key()[len(prefix):])
+		if key == except {
+			continue
+		}
+		used += len(key) + int(item.ValueSize())
+	}
+	return used
+}
+
+// GetMeta returns a metadata value, or nil if none is stored under the key
+func (e *BadgerEngine) GetMeta(graphID models.GraphID, namespace string, key string) (*models.MetaEntry, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	if err := models.ValidateMetaNamespace(namespace); err != nil {
+		return nil, err
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+	value, err := e.get(utils.EncodeMetaKey(graphID, namespace, key))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	return &models.MetaEntry{Namespace: namespace, Key: key, Value: value}, nil
+}
+
+// DeleteMeta deletes a metadata value and reports whether it existed
+func (e *BadgerEngine) DeleteMeta(graphID models.GraphID, namespace string, key string) (bool, error) {
+	if e.db == nil {
+		return false, ErrClosed
+	}
+
+	if err := models.ValidateMetaNamespace(namespace); err != nil {
+		return false, err
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return false, err
+	}
+	existed := false
+	err := e.db.Update(func(txn *badger.Txn) error {
+		metaKey := utils.EncodeMetaKey(graphID, namespace, key)
+		_, err := txn.Get(metaKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		existed = true
+		return txn.Delete(metaKey)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete metadata: %w", err)
+	}
+	return existed, nil
+}
+
+// ListMeta returns the metadata of one namespace of a graph, sorted by key
+func (e *BadgerEngine) ListMeta(graphID models.GraphID, namespace string) ([]*models.MetaEntry, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	if err := models.ValidateMetaNamespace(namespace); err != nil {
+		return nil, err
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+	var entries []*models.MetaEntry
+	prefix := utils.CreateMetaIteratorPrefix(graphID, namespace)
+	err := e.iterateWithPrefix(prefix, func(key []byte, value []byte) error {
+		entries = append(entries, &models.MetaEntry{
+			Namespace: namespace,
+			Key:       string(key[len(prefix):]),
+			Value:     append(json.RawMessage(nil), value...),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metadata: %w", err)
+	}
+	return entries, nil
+}
+
+// ScanMeta calls fn for every metadata value of a graph, ordered by
+// namespace and key. Returning ErrStopScan from fn ends the scan early
+// without an error.
+func (e *BadgerEngine) ScanMeta(graphID models.GraphID, fn func(entry *models.MetaEntry) error) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	prefix := utils.CreateGraphMetaIteratorPrefix(graphID)
+	err := e.iterateWithPrefix(prefix, func(key []byte, value []byte) error {
+		namespace, metaKey, ok := strings.Cut(string(key[len(prefix):]), ":")
+		if !ok {
+			return nil
+		}
+		return fn(&models.MetaEntry{
+			Namespace: namespace,
+			Key:       metaKey,
+			Value:     append(json.RawMessage(nil), value...),
+		})
+	})
+	if err == ErrStopScan {
+		return nil
+	}
+	return err
+}
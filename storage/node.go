@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 	"github.com/ywadi/PathwayDB/models"
@@ -12,52 +15,169 @@ import (
 // CreateNode creates a new node in the specified graph
 func (e *BadgerEngine) CreateNode(graphID models.GraphID, node *models.Node) error {
 	if e.db == nil {
-		return fmt.Errorf("database not opened")
+		return ErrClosed
 	}
 
-	return e.db.Update(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
+	return e.update(func(tx *BadgerTransaction) error {
 		return tx.CreateNode(graphID, node)
 	})
 }
 
+// CreateNodes creates nodes in one transaction, so either all of them are
+// created or, if one fails, none are. The error names the node that failed.
+func (e *BadgerEngine) CreateNodes(graphID models.GraphID, nodes []*models.Node) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	return e.update(func(tx *BadgerTransaction) error {
+		for _, node := range nodes {
+			if err := tx.CreateNode(graphID, node); err != nil {
+				return fmt.Errorf("node %s: %w", node.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
 // GetNode retrieves a node by ID from the specified graph
 func (e *BadgerEngine) GetNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
-	var node *models.Node
-	err := e.db.View(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
-		var err error
-		node, err = tx.GetNode(graphID, nodeID)
-		return err
-	})
+	node, cached := e.cachedNode(graphID, nodeID)
+	if !cached {
+		// Read the generation before the snapshot is taken, so a write
+		// committed during the read keeps its result out of the cache
+		generation := e.generations.get(graphID)
+		err := e.db.View(func(txn *badger.Txn) error {
+			tx := e.newTransaction(txn)
+			var err error
+			node, err = tx.GetNode(graphID, nodeID)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		e.cacheNode(graphID, node, generation)
+	}
+
+	// Never serve expired data, even if the sweep hasn't run yet. The deletion
+	// itself is handed to the TTL manager so reads stay read-only.
+	if node.IsExpired() {
+		e.ttlManager.enqueueNode(graphID, nodeID)
+		return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeID)
+	}
 
-	return node, err
+	if e.reads.enabled.Load() {
+		e.reads.record(graphID, nodeID)
+	}
+	return node, nil
+}
+
+// GetNodes retrieves nodes by ID in one read transaction. The result has
+// an entry per ID, in order, which is nil if no node has the ID or it has
+// expired. IDs that name no node are resolved as aliases, as ResolveNodeID
+// does.
+func (e *BadgerEngine) GetNodes(graphID models.GraphID, nodeIDs []models.NodeID) ([]*models.Node, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	nodes := make([]*models.Node, len(nodeIDs))
+	var missed []int
+	for i, nodeID := range nodeIDs {
+		if node, cached := e.cachedNode(graphID, nodeID); cached {
+			nodes[i] = node
+		} else {
+			missed = append(missed, i)
+		}
+	}
+	if len(missed) > 0 {
+		generation := e.generations.get(graphID)
+		err := e.db.View(func(txn *badger.Txn) error {
+			tx := e.newTransaction(txn)
+			for _, i := range missed {
+				node, err := tx.GetNode(graphID, nodeIDs[i])
+				if errors.Is(err, ErrNodeNotFound) {
+					owner, aliasErr := tx.aliasOwner(graphID, string(nodeIDs[i]))
+					if aliasErr != nil || owner == "" {
+						err = aliasErr
+					} else {
+						node, err = tx.GetNode(graphID, owner)
+					}
+				}
+				if errors.Is(err, ErrNodeNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				nodes[i] = node
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range missed {
+			if nodes[i] != nil {
+				e.cacheNode(graphID, nodes[i], generation)
+			}
+		}
+	}
+
+	for i, node := range nodes {
+		if node == nil {
+			continue
+		}
+		if node.IsExpired() {
+			e.ttlManager.enqueueNode(graphID, node.ID)
+			nodes[i] = nil
+			continue
+		}
+		if e.reads.enabled.Load() {
+			e.reads.record(graphID, node.ID)
+		}
+	}
+	return nodes, nil
 }
 
 // UpdateNode updates an existing node
 func (e *BadgerEngine) UpdateNode(graphID models.GraphID, node *models.Node) error {
 	if e.db == nil {
-		return fmt.Errorf("database not opened")
+		return ErrClosed
 	}
 
-	return e.db.Update(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
+	return e.update(func(tx *BadgerTransaction) error {
 		return tx.UpdateNode(graphID, node)
 	})
 }
 
+// UpdateNodeWithResult updates an existing node and reports whether it was
+// written, false when the update changes nothing but UpdatedAt
+func (e *BadgerEngine) UpdateNodeWithResult(graphID models.GraphID, node *models.Node) (bool, error) {
+	if e.db == nil {
+		return false, ErrClosed
+	}
+
+	var written bool
+	err := e.update(func(tx *BadgerTransaction) error {
+		var err error
+		written, err = tx.UpdateNodeWithResult(graphID, node)
+		return err
+	})
+	return written, err
+}
+
 // DeleteNode deletes a node and all its associated edges
 func (e *BadgerEngine) DeleteNode(graphID models.GraphID, nodeID models.NodeID) error {
 	if e.db == nil {
-		return fmt.Errorf("database not opened")
+		return ErrClosed
 	}
 
-	return e.db.Update(func(txn *badger.Txn) error {
-		tx := &BadgerTransaction{txn: txn}
+	return e.update(func(tx *BadgerTransaction) error {
 		return tx.DeleteNode(graphID, nodeID)
 	})
 }
@@ -65,18 +185,22 @@ func (e *BadgerEngine) DeleteNode(graphID models.GraphID, nodeID models.NodeID)
 // ListNodes returns all nodes in the specified graph
 func (e *BadgerEngine) ListNodes(graphID models.GraphID) ([]*models.Node, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
 	var nodes []*models.Node
 	prefix := utils.CreateNodeIteratorPrefix(graphID)
 
-	err := e.iterateWithPrefix(prefix, func(key []byte, value []byte) error {
+	err := e.listWithPrefix(prefix, func(key []byte, value []byte) error {
 		node := &models.Node{}
 		err := node.FromJSON(value)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize node: %w", err)
 		}
+		if node.IsExpired() {
+			e.ttlManager.enqueueNode(graphID, node.ID)
+			return nil
+		}
 		nodes = append(nodes, node)
 		return nil
 	})
@@ -88,10 +212,64 @@ func (e *BadgerEngine) ListNodes(graphID models.GraphID) ([]*models.Node, error)
 	return nodes, nil
 }
 
+// ScanNodes calls fn with each node in the specified graph without loading
+// them all into memory. Returning ErrStopScan from fn ends the scan early;
+// any other error aborts it and is returned.
+func (e *BadgerEngine) ScanNodes(graphID models.GraphID, fn func(node *models.Node) error) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	prefix := utils.CreateNodeIteratorPrefix(graphID)
+	err := e.listWithPrefix(prefix, func(key []byte, value []byte) error {
+		node := &models.Node{}
+		if err := node.FromJSON(value); err != nil {
+			return fmt.Errorf("failed to deserialize node: %w", err)
+		}
+		if node.IsExpired() {
+			e.ttlManager.enqueueNode(graphID, node.ID)
+			return nil
+		}
+		return fn(node)
+	})
+	if err == ErrStopScan {
+		return nil
+	}
+	return err
+}
+
+// ListNodesPage returns at most limit nodes of the specified graph in ID
+// order, starting after the node with ID after, or at the first node if
+// after is empty, and whether more nodes follow. Only the page is read, so
+// a large graph can be listed a page at a time.
+func (e *BadgerEngine) ListNodesPage(graphID models.GraphID, after models.NodeID, limit int) ([]*models.Node, bool, error) {
+	if e.db == nil {
+		return nil, false, ErrClosed
+	}
+
+	var nodes []*models.Node
+	more, err := e.listPage(utils.CreateNodeIteratorPrefix(graphID), string(after), limit, func(value []byte) (bool, error) {
+		node := &models.Node{}
+		if err := node.FromJSON(value); err != nil {
+			return false, fmt.Errorf("failed to deserialize node: %w", err)
+		}
+		if node.IsExpired() {
+			e.ttlManager.enqueueNode(graphID, node.ID)
+			return false, nil
+		}
+		nodes = append(nodes, node)
+		return true, nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	return nodes, more, nil
+}
+
 // ListNodesByType returns all nodes of a specific type in the specified graph
 func (e *BadgerEngine) ListNodesByType(graphID models.GraphID, nodeType models.NodeType) ([]*models.Node, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return nil, ErrClosed
 	}
 
 	var nodes []*models.Node
@@ -104,6 +282,10 @@ func (e *BadgerEngine) ListNodesByType(graphID models.GraphID, nodeType models.N
 		if len(parts) >= 4 {
 			nodeID := models.NodeID(parts[len(parts)-1])
 			node, err := e.GetNode(graphID, nodeID)
+			if errors.Is(err, ErrNodeNotFound) {
+				// The node may have expired while its index entry remains, so we can skip.
+				return nil
+			}
 			if err != nil {
 				return err
 			}
@@ -119,25 +301,122 @@ func (e *BadgerEngine) ListNodesByType(graphID models.GraphID, nodeType models.N
 	return nodes, nil
 }
 
-// FindNodesByAttribute finds nodes that have a specific attribute value
-func (e *BadgerEngine) FindNodesByAttribute(graphID models.GraphID, attrKey string, attrValue interface{}) ([]*models.Node, error) {
+// RenameNodeType changes the type of every node of oldType in a graph to
+// newType, updating the type index along with each node. Nodes are rewritten
+// in batches, so a failure part way leaves earlier batches renamed; running
+// the rename again completes it. It returns the number of nodes changed.
+func (e *BadgerEngine) RenameNodeType(graphID models.GraphID, oldType, newType models.NodeType) (int, error) {
 	if e.db == nil {
-		return nil, fmt.Errorf("database not opened")
+		return 0, ErrClosed
+	}
+	if newType == "" {
+		return 0, fmt.Errorf("new node type cannot be empty")
+	}
+	if err := models.ValidateType("node", string(newType)); err != nil {
+		return 0, err
+	}
+	if oldType == newType {
+		return 0, fmt.Errorf("node type is already %s", newType)
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return 0, err
 	}
 
-	// For now, we'll do a full scan of nodes and filter by attribute
-	// In a production system, you'd want to maintain attribute indexes
-	allNodes, err := e.ListNodes(graphID)
+	now := time.Now()
+	prefix := utils.CreateTypeIteratorPrefix(graphID, "n", string(oldType))
+	count, err := e.rewriteIndex(prefix, func(t *BadgerTransaction, key []byte, id string) (bool, error) {
+		node, err := t.GetNode(graphID, models.NodeID(id))
+		if errors.Is(err, ErrNodeNotFound) || (err == nil && node.Type != oldType) {
+			// Stale index entry, e.g. left behind by an expired node
+			return false, t.delete(key)
+		}
+		if err != nil {
+			return false, err
+		}
+		node.Type = newType
+		node.UpdatedAt = now
+		return true, t.UpdateNode(graphID, node)
+	})
 	if err != nil {
-		return nil, err
+		return count, fmt.Errorf("failed to rename node type: %w", err)
+	}
+
+	return count, nil
+}
+
+// FindNodesByAttribute finds nodes that have a specific attribute value,
+// compared with models.MatchValue. A dotted key such as meta.owner matches
+// a key of an object attribute, as models.Attributes.Lookup finds it. Once
+// the attribute index of the graph is complete it is used for the lookup;
+// until then, and for dotted keys and values too long to index, nodes are
+// scanned.
+func (e *BadgerEngine) FindNodesByAttribute(graphID models.GraphID, attrKey string, attrValue interface{}) ([]*models.Node, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	matches := func(node *models.Node) bool {
+		value, exists := node.Attributes.Lookup(attrKey)
+		return exists && models.MatchValue(value, attrValue)
 	}
 
 	var matchingNodes []*models.Node
-	for _, node := range allNodes {
-		if value, exists := node.GetAttribute(attrKey); exists {
-			if value == attrValue {
+	// The index holds top-level keys only, so a dotted key is scanned for
+	encoded, indexed := attributeIndexValue(attrKey, attrValue)
+	if strings.Contains(attrKey, ".") {
+		indexed = false
+	}
+	err := e.db.View(func(txn *badger.Txn) error {
+		if indexed {
+			ready, err := indexComplete(txn, graphID, attributeIndex)
+			if err != nil {
+				return err
+			}
+			indexed = ready
+		}
+		if !indexed {
+			return nil
+		}
+
+		// Entries are checked against the node, as the prefix of one
+		// graph, key or value can also match longer ones
+		tx := e.newTransaction(txn)
+		seen := make(map[models.NodeID]bool)
+		prefix := utils.CreateAttributeIteratorPrefix(graphID, "n", attrKey, encoded)
+		return iterateTxnPrefix(txn, prefix, func(value []byte) error {
+			nodeID := models.NodeID(value)
+			if seen[nodeID] {
+				return nil
+			}
+			node, err := tx.GetNode(graphID, nodeID)
+			if err != nil {
+				return nil
+			}
+			if node.IsExpired() {
+				e.ttlManager.enqueueNode(graphID, node.ID)
+				return nil
+			}
+			if matches(node) {
+				seen[nodeID] = true
 				matchingNodes = append(matchingNodes, node)
 			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find nodes by attribute: %w", err)
+	}
+	if indexed {
+		return matchingNodes, nil
+	}
+
+	allNodes, err := e.ListNodes(graphID)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range allNodes {
+		if matches(node) {
+			matchingNodes = append(matchingNodes, node)
 		}
 	}
 
@@ -148,6 +427,33 @@ func (e *BadgerEngine) FindNodesByAttribute(graphID models.GraphID, attrKey stri
 
 // CreateNode creates a node within a transaction
 func (t *BadgerTransaction) CreateNode(graphID models.GraphID, node *models.Node) error {
+	if err := node.Validate(); err != nil {
+		return err
+	}
+	if err := t.attributeKeys.check("node", node.Attributes, nil); err != nil {
+		return err
+	}
+	if owner, err := t.aliasOwner(graphID, string(node.ID)); err != nil {
+		return err
+	} else if owner != "" {
+		return fmt.Errorf("%w: %s is an alias of node %s", ErrAliasConflict, node.ID, owner)
+	}
+	t.touch(graphID)
+
+	// Creating a node that exists replaces it, so drop the attribute
+	// index entries of the node being replaced. A new node may be the
+	// missing endpoint of weak edges, which are no longer dangling.
+	op := EventCreated
+	if existingNode, err := t.GetNode(graphID, node.ID); err == nil {
+		if err := t.unindexNodeAttributes(graphID, existingNode); err != nil {
+			return err
+		}
+		t.countNode(graphID, existingNode.Type, -1)
+		op = EventUpdated
+	} else if err := t.reattachEdges(graphID, node.ID); err != nil {
+		return err
+	}
+
 	// Store the node
 	nodeKey := utils.EncodeNodeKey(graphID, node.ID)
 	nodeValue, err := node.ToJSON()
@@ -159,6 +465,12 @@ func (t *BadgerTransaction) CreateNode(graphID models.GraphID, node *models.Node
 	if err != nil {
 		return fmt.Errorf("failed to store node: %w", err)
 	}
+	t.countNode(graphID, node.Type, 1)
+	t.notifyNode(graphID, node, op)
+
+	if _, err := t.indexNodeAttributes(graphID, node); err != nil {
+		return err
+	}
 
 	// Create type index
 	typeIndexKey := utils.EncodeNodeTypeIndexKey(graphID, node.Type, node.ID)
@@ -185,7 +497,7 @@ func (t *BadgerTransaction) GetNode(graphID models.GraphID, nodeID models.NodeID
 	nodeValue, err := t.get(nodeKey)
 	if err != nil {
 		if err == badger.ErrKeyNotFound {
-			return nil, fmt.Errorf("node not found: %s", nodeID)
+			return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeID)
 		}
 		return nil, fmt.Errorf("failed to get node: %w", err)
 	}
@@ -199,16 +511,60 @@ func (t *BadgerTransaction) GetNode(graphID models.GraphID, nodeID models.NodeID
 	return node, nil
 }
 
-// UpdateNode updates a node within a transaction
+// UpdateNode updates a node within a transaction. Unlike
+// UpdateNodeWithResult it writes the node even if nothing but UpdatedAt
+// changed, so it can refresh that alone.
 func (t *BadgerTransaction) UpdateNode(graphID models.GraphID, node *models.Node) error {
-	// Get the existing node to check if type changed
-	existingNode, err := t.GetNode(graphID, node.ID)
+	existing, err := t.GetNode(graphID, node.ID)
 	if err != nil {
 		return fmt.Errorf("node does not exist: %w", err)
 	}
+	return t.updateNode(graphID, existing, node)
+}
+
+// UpdateNodeWithResult updates a node within a transaction and reports
+// whether it was written. A node whose canonical JSON, UpdatedAt aside, is
+// that of the stored node is not written: its record, indexes and the
+// graph's generation are left as they are, stored UpdatedAt included.
+func (t *BadgerTransaction) UpdateNodeWithResult(graphID models.GraphID, node *models.Node) (bool, error) {
+	// Get the existing node to compare the update with
+	existingNode, err := t.GetNode(graphID, node.ID)
+	if err != nil {
+		return false, fmt.Errorf("node does not exist: %w", err)
+	}
+	if nodeUnchanged(existingNode, node) {
+		return false, nil
+	}
+	return true, t.updateNode(graphID, existingNode, node)
+}
+
+// nodeUnchanged reports whether node serializes as existing does once its
+// UpdatedAt is ignored
+func nodeUnchanged(existing, node *models.Node) bool {
+	candidate := *node
+	candidate.UpdatedAt = existing.UpdatedAt
+	before, err := existing.ToJSON()
+	if err != nil {
+		return false
+	}
+	after, err := candidate.ToJSON()
+	return err == nil && bytes.Equal(before, after)
+}
+
+// updateNode replaces existingNode, the stored node, with node
+func (t *BadgerTransaction) updateNode(graphID models.GraphID, existingNode, node *models.Node) error {
+	var err error
+	if err := t.attributeKeys.check("node", node.Attributes, existingNode.Attributes); err != nil {
+		return err
+	}
+	t.touch(graphID)
 
 	// If type changed, update the type index
 	if existingNode.Type != node.Type {
+		if err := models.ValidateType("node", string(node.Type)); err != nil {
+			return err
+		}
+
 		// Remove old type index
 		oldTypeIndexKey := utils.EncodeNodeTypeIndexKey(graphID, existingNode.Type, node.ID)
 		err = t.delete(oldTypeIndexKey)
@@ -222,6 +578,8 @@ func (t *BadgerTransaction) UpdateNode(graphID models.GraphID, node *models.Node
 		if err != nil {
 			return fmt.Errorf("failed to create new type index: %w", err)
 		}
+		t.countNode(graphID, existingNode.Type, -1)
+		t.countNode(graphID, node.Type, 1)
 	}
 
 	// Handle expiry index update
@@ -240,6 +598,14 @@ func (t *BadgerTransaction) UpdateNode(graphID models.GraphID, node *models.Node
 		}
 	}
 
+	// Replace the attribute index entries
+	if err := t.unindexNodeAttributes(graphID, existingNode); err != nil {
+		return err
+	}
+	if _, err := t.indexNodeAttributes(graphID, node); err != nil {
+		return err
+	}
+
 	// Update the node
 	nodeKey := utils.EncodeNodeKey(graphID, node.ID)
 	nodeValue, err := node.ToJSON()
@@ -247,39 +613,18 @@ func (t *BadgerTransaction) UpdateNode(graphID models.GraphID, node *models.Node
 		return fmt.Errorf("failed to serialize node: %w", err)
 	}
 
+	t.notifyNode(graphID, node, EventUpdated)
 	return t.set(nodeKey, nodeValue)
 }
 
-// DeleteNode deletes a node within a transaction
+// DeleteNode deletes a node and its edges within a transaction, recording
+// them in the deletion log. Weak edges are kept and marked dangling.
 func (t *BadgerTransaction) DeleteNode(graphID models.GraphID, nodeID models.NodeID) error {
-	// Get the node first to access its type
-	node, err := t.GetNode(graphID, nodeID)
-	if err != nil {
-		return fmt.Errorf("node does not exist: %w", err)
-	}
-
-	// Delete the node
-	nodeKey := utils.EncodeNodeKey(graphID, nodeID)
-	err = t.delete(nodeKey)
-	if err != nil {
-		return fmt.Errorf("failed to delete node: %w", err)
-	}
-
-	// Delete type index
-	typeIndexKey := utils.EncodeNodeTypeIndexKey(graphID, node.Type, nodeID)
-	err = t.delete(typeIndexKey)
-	if err != nil {
-		return fmt.Errorf("failed to delete type index: %w", err)
+	if err := t.deleteNodeRecord(graphID, nodeID); err != nil {
+		return err
 	}
-
-	// Delete from expiry index if TTL was set
-	if node.ExpiresAt != nil {
-		expiryKey := utils.EncodeExpiryIndexKey(graphID, node.ID, *node.ExpiresAt)
-		if err := t.delete(expiryKey); err != nil {
-			// This is not a critical failure, as the node is being deleted anyway.
-			// A log is sufficient.
-			fmt.Printf("warn: failed to remove expiry index for node %s: %v\n", nodeID, err)
-		}
+	if err := t.logDeletion(graphID, "n", string(nodeID)); err != nil {
+		return err
 	}
 
 	// Delete outgoing edges
@@ -291,7 +636,7 @@ func (t *BadgerTransaction) DeleteNode(graphID models.GraphID, nodeID models.Nod
 	for outIter.Seek(outgoingPrefix); outIter.ValidForPrefix(outgoingPrefix); outIter.Next() {
 		item := outIter.Item()
 		err := item.Value(func(val []byte) error {
-			return t.DeleteEdge(graphID, models.EdgeID(val))
+			return t.detachEdge(graphID, models.EdgeID(val), nodeID)
 		})
 		if err != nil {
 			return fmt.Errorf("failed to delete outgoing edge during node deletion: %w", err)
@@ -307,7 +652,7 @@ func (t *BadgerTransaction) DeleteNode(graphID models.GraphID, nodeID models.Nod
 	for inIter.Seek(incomingPrefix); inIter.ValidForPrefix(incomingPrefix); inIter.Next() {
 		item := inIter.Item()
 		err := item.Value(func(val []byte) error {
-			return t.DeleteEdge(graphID, models.EdgeID(val))
+			return t.detachEdge(graphID, models.EdgeID(val), nodeID)
 		})
 		if err != nil {
 			return fmt.Errorf("failed to delete incoming edge during node deletion: %w", err)
@@ -316,3 +661,58 @@ func (t *BadgerTransaction) DeleteNode(graphID models.GraphID, nodeID models.Nod
 
 	return nil
 }
+
+// deleteNodeRecord deletes a node with its indexes and aliases but not its
+// edges, which DeleteGraph deletes in a phase of their own
+func (t *BadgerTransaction) deleteNodeRecord(graphID models.GraphID, nodeID models.NodeID) error {
+	// Get the node first to access its type
+	node, err := t.GetNode(graphID, nodeID)
+	if err != nil {
+		return fmt.Errorf("node does not exist: %w", err)
+	}
+	t.touch(graphID)
+
+	// Delete the node
+	nodeKey := utils.EncodeNodeKey(graphID, nodeID)
+	err = t.delete(nodeKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete node: %w", err)
+	}
+
+	// Delete type index
+	typeIndexKey := utils.EncodeNodeTypeIndexKey(graphID, node.Type, nodeID)
+	err = t.delete(typeIndexKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete type index: %w", err)
+	}
+	t.countNode(graphID, node.Type, -1)
+	t.notifyNode(graphID, node, EventDeleted)
+
+	// Delete attribute index entries
+	if err := t.unindexNodeAttributes(graphID, node); err != nil {
+		return err
+	}
+
+	// Delete from expiry index if TTL was set
+	if node.ExpiresAt != nil {
+		expiryKey := utils.EncodeExpiryIndexKey(graphID, node.ID, *node.ExpiresAt)
+		if err := t.delete(expiryKey); err != nil {
+			// This is not a critical failure, as the node is being deleted anyway.
+			// A log is sufficient.
+			t.logger.Warn("failed to remove expiry index", "graph", graphID, "node", nodeID, "error", err)
+		}
+	}
+
+	// Delete the node's aliases
+	aliases, err := t.nodeAliases(graphID, nodeID)
+	if err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		if err := t.removeAlias(graphID, nodeID, alias); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// SaveQuery creates or replaces a named query
+func (e *BadgerEngine) SaveQuery(query *models.NamedQuery) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	value, err := query.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize query: %w", err)
+	}
+
+	return e.set(utils.EncodeQueryKey(query.Name), value)
+}
+
+// GetQuery retrieves a named query
+func (e *BadgerEngine) GetQuery(name string) (*models.NamedQuery, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	value, err := e.get(utils.EncodeQueryKey(name))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, fmt.Errorf("query not found: %s", name)
+		}
+		return nil, fmt.Errorf("failed to get query: %w", err)
+	}
+
+	query := &models.NamedQuery{}
+	if err := query.FromJSON(value); err != nil {
+		return nil, fmt.Errorf("failed to deserialize query: %w", err)
+	}
+
+	return query, nil
+}
+
+// ListQueries returns all named queries ordered by name
+func (e *BadgerEngine) ListQueries() ([]*models.NamedQuery, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	var queries []*models.NamedQuery
+	err := e.iterateWithPrefix([]byte(utils.QueryPrefix), func(key []byte, value []byte) error {
+		query := &models.NamedQuery{}
+		if err := query.FromJSON(value); err != nil {
+			return fmt.Errorf("failed to deserialize query: %w", err)
+		}
+		queries = append(queries, query)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queries: %w", err)
+	}
+
+	return queries, nil
+}
+
+// DeleteQuery deletes a named query
+func (e *BadgerEngine) DeleteQuery(name string) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	if _, err := e.GetQuery(name); err != nil {
+		return err
+	}
+
+	return e.delete(utils.EncodeQueryKey(name))
+}
@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// readShards is the number of independently locked read counter maps
+const readShards = 32
+
+// readFlushInterval is how often in-memory read counts are added to the
+// stored totals
+const readFlushInterval = 10 * time.Second
+
+// NodeReads is the number of times a node was read since the last reset
+type NodeReads struct {
+	NodeID models.NodeID
+	Reads  uint64
+}
+
+// readKey identifies a node across graphs
+type readKey struct {
+	graphID models.GraphID
+	nodeID  models.NodeID
+}
+
+// readShard holds the counters of the keys that hash to it. Counters are
+// incremented under the read lock; the write lock is only taken to add a key
+// or to drain the shard.
+type readShard struct {
+	mu     sync.RWMutex
+	counts map[readKey]*atomic.Uint64
+}
+
+// readTracker counts node reads in memory until they are flushed
+type readTracker struct {
+	enabled atomic.Bool
+	shards  [readShards]readShard
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// shard returns the shard of a key using FNV-1a over its IDs
+func (r *readTracker) shard(key readKey) *readShard {
+	hash := uint32(2166136261)
+	for _, s := range [2]string{string(key.graphID), string(key.nodeID)} {
+		for i := 0; i < len(s); i++ {
+			hash ^= uint32(s[i])
+			hash *= 16777619
+		}
+	}
+	return &r.shards[hash%readShards]
+}
+
+// record counts one read of a node
+func (r *readTracker) record(graphID models.GraphID, nodeID models.NodeID) {
+	key := readKey{graphID: graphID, nodeID: nodeID}
+	s := r.shard(key)
+
+	s.mu.RLock()
+	counter, exists := s.counts[key]
+	if exists {
+		counter.Add(1)
+	}
+	s.mu.RUnlock()
+	if exists {
+		return
+	}
+
+	s.mu.Lock()
+	if s.counts == nil {
+		s.counts = make(map[readKey]*atomic.Uint64)
+	}
+	if counter, exists = s.counts[key]; !exists {
+		counter = new(atomic.Uint64)
+		s.counts[key] = counter
+	}
+	counter.Add(1)
+	s.mu.Unlock()
+}
+
+// drain removes and returns all in-memory counts
+func (r *readTracker) drain() map[readKey]uint64 {
+	drained := make(map[readKey]uint64)
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.Lock()
+		counts := s.counts
+		s.counts = nil
+		s.mu.Unlock()
+		for key, counter := range counts {
+			drained[key] = counter.Load()
+		}
+	}
+	return drained
+}
+
+// pending returns the in-memory counts of a graph without removing them
+func (r *readTracker) pending(graphID models.GraphID) map[models.NodeID]uint64 {
+	counts := make(map[models.NodeID]uint64)
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		for key, counter := range s.counts {
+			if key.graphID == graphID {
+				counts[key.nodeID] += counter.Load()
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return counts
+}
+
+// discard drops the in-memory counts of a graph, or of every graph when
+// graphID is empty
+func (r *readTracker) discard(graphID models.GraphID) {
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.Lock()
+		for key := range s.counts {
+			if graphID == "" || key.graphID == graphID {
+				delete(s.counts, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// SetReadTracking turns counting of node reads on or off. Counts already
+// recorded are kept.
+func (e *BadgerEngine) SetReadTracking(enabled bool) {
+	e.reads.enabled.Store(enabled)
+}
+
+// startReadFlusher periodically adds in-memory read counts to the stored
+// totals until stopReadFlusher is called
+func (e *BadgerEngine) startReadFlusher() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	e.reads.stop, e.reads.done = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(readFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := e.flushReads(); err != nil {
+					e.logger.Warn("Failed to flush read counts", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopReadFlusher stops the flusher and writes the remaining counts
+func (e *BadgerEngine) stopReadFlusher() {
+	if e.reads.done == nil {
+		return
+	}
+	close(e.reads.stop)
+	<-e.reads.done
+	e.reads.done = nil
+	if err := e.flushReads(); err != nil {
+		e.logger.Warn("Failed to flush read counts", "error", err)
+	}
+}
+
+// flushReads adds the in-memory read counts to the stored totals
+func (e *BadgerEngine) flushReads() error {
+	counts := e.reads.drain()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	keys := make([]readKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	for start := 0; start < len(keys); start += rewriteBatchSize {
+		end := start + rewriteBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		err := e.db.Update(func(txn *badger.Txn) error {
+			for _, key := range keys[start:end] {
+				countKey := utils.EncodeReadCountKey(key.graphID, key.nodeID)
+				total := counts[key]
+				item, err := txn.Get(countKey)
+				if err == nil {
+					err = item.Value(func(value []byte) error {
+						if len(value) == 8 {
+							total += binary.BigEndian.Uint64(value)
+						}
+						return nil
+					})
+				}
+				if err != nil && err != badger.ErrKeyNotFound {
+					return err
+				}
+				value := make([]byte, 8)
+				binary.BigEndian.PutUint64(value, total)
+				if err := txn.Set(countKey, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store read counts: %w", err)
+		}
+	}
+	return nil
+}
+
+// HotNodes returns the most-read nodes of a graph since the last reset,
+// highest count first with ties broken by node ID. A limit of 0 returns
+// every node that was read.
+func (e *BadgerEngine) HotNodes(graphID models.GraphID, limit int) ([]NodeReads, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	counts := e.reads.pending(graphID)
+	prefix := utils.CreateReadCountIteratorPrefix(graphID)
+	err := e.iterateWithPrefix(prefix, func(key []byte, value []byte) error {
+		if len(value) == 8 {
+			counts[models.NodeID(key[len(prefix):])] += binary.BigEndian.Uint64(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read counts: %w", err)
+	}
+
+	hot := make([]NodeReads, 0, len(counts))
+	for nodeID, reads := range counts {
+		hot = append(hot, NodeReads{NodeID: nodeID, Reads: reads})
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].Reads != hot[j].Reads {
+			return hot[i].Reads > hot[j].Reads
+		}
+		return hot[i].NodeID < hot[j].NodeID
+	})
+	if limit > 0 && limit < len(hot) {
+		hot = hot[:limit]
+	}
+	return hot, nil
+}
+
+// ResetReads clears the read counts of every graph
+func (e *BadgerEngine) ResetReads() error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	e.reads.discard("")
+	err := e.db.Update(func(txn *badger.Txn) error {
+		return e.deleteWithPrefix(txn, []byte(utils.ReadCountPrefix))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset read counts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,539 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// DefaultReindexRate is how many entity keys per second a reindex scans when
+// it is started without a rate
+const DefaultReindexRate = 1000
+
+// reindexer backfills one index over the existing entities of a graph
+type reindexer struct {
+	// prefix returns the prefix of the entity keys the index covers
+	prefix func(graphID models.GraphID) []byte
+	// index writes the index entries of the entity stored under key and
+	// returns how many it wrote
+	index func(t *BadgerTransaction, graphID models.GraphID, key, value []byte) (int, error)
+}
+
+// reindexers lists the indexes SYSTEM.REINDEX can backfill by name
+var reindexers = map[string]reindexer{
+	attributeIndex: {
+		prefix: utils.CreateNodeIteratorPrefix,
+		index: func(t *BadgerTransaction, graphID models.GraphID, key, value []byte) (int, error) {
+			node := &models.Node{}
+			if err := node.FromJSON(value); err != nil {
+				return 0, fmt.Errorf("failed to deserialize node: %w", err)
+			}
+			if !bytes.Equal(key, utils.EncodeNodeKey(graphID, node.ID)) {
+				// A node of a graph whose ID extends this one
+				return 0, nil
+			}
+			return t.indexNodeAttributes(graphID, node)
+		},
+	},
+}
+
+// ReindexManager runs reindex jobs in the background, alongside the TTL and
+// maintenance managers. Jobs persist their cursor after every batch, so the
+// jobs running when the engine closes resume when it opens again.
+type ReindexManager struct {
+	engine *BadgerEngine
+
+	// controlMu serializes starting, pausing, resuming and cancelling jobs
+	controlMu sync.Mutex
+
+	mu      sync.Mutex
+	workers map[string]*reindexWorker
+}
+
+// reindexWorker is the goroutine running one job
+type reindexWorker struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReindexManager creates a new reindex manager
+func NewReindexManager(engine *BadgerEngine) *ReindexManager {
+	return &ReindexManager{
+		engine:  engine,
+		workers: make(map[string]*reindexWorker),
+	}
+}
+
+// Start resumes the jobs that were running when the engine last closed.
+// Their throughput is measured afresh, so the time the engine was closed
+// does not count against their ETA.
+func (m *ReindexManager) Start() {
+	var jobs []*models.ReindexJob
+	err := m.engine.db.Update(func(txn *badger.Txn) error {
+		prefix := []byte(utils.ReindexPrefix)
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			job := &models.ReindexJob{}
+			if err := it.Item().Value(func(value []byte) error {
+				return json.Unmarshal(value, job)
+			}); err != nil {
+				it.Close()
+				return fmt.Errorf("failed to deserialize reindex job: %w", err)
+			}
+			if job.State == models.ReindexRunning {
+				jobs = append(jobs, job)
+			}
+		}
+		it.Close()
+
+		now := time.Now().UTC()
+		for _, job := range jobs {
+			job.ResumedAt = now
+			job.ResumedScanned = job.Scanned
+			if err := putReindexJob(txn, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		m.engine.logger.Warn("failed to resume reindex jobs", "error", err)
+		return
+	}
+
+	for _, job := range jobs {
+		m.engine.logger.Info("Resuming reindex", "graph", job.Graph, "index", job.Index,
+			"scanned", job.Scanned, "total", job.Total)
+		m.spawn(job.Graph, job.Index)
+	}
+}
+
+// Stop halts every job and waits for in-flight batches to finish. The jobs
+// stay running in storage, so Start resumes them.
+func (m *ReindexManager) Stop() {
+	m.mu.Lock()
+	workers := m.workers
+	m.workers = make(map[string]*reindexWorker)
+	m.mu.Unlock()
+
+	for _, worker := range workers {
+		close(worker.stop)
+	}
+	for _, worker := range workers {
+		<-worker.done
+	}
+}
+
+// spawn runs a job unless it is already running
+func (m *ReindexManager) spawn(graphID models.GraphID, index string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := string(utils.EncodeReindexKey(graphID, index))
+	if _, running := m.workers[key]; running {
+		return
+	}
+	worker := &reindexWorker{stop: make(chan struct{}), done: make(chan struct{})}
+	m.workers[key] = worker
+	go m.run(graphID, index, worker)
+}
+
+// halt stops a job's worker, if it has one, and waits for it to exit
+func (m *ReindexManager) halt(graphID models.GraphID, index string) {
+	key := string(utils.EncodeReindexKey(graphID, index))
+	m.mu.Lock()
+	worker, running := m.workers[key]
+	delete(m.workers, key)
+	m.mu.Unlock()
+
+	if running {
+		close(worker.stop)
+		<-worker.done
+	}
+}
+
+// forget stops the workers of a graph's jobs
+func (m *ReindexManager) forget(graphID models.GraphID) {
+	for index := range reindexers {
+		m.halt(graphID, index)
+	}
+}
+
+// run indexes batches of a job until it is done, stops running or its
+// worker is stopped. Batches are spaced so the job scans no more than its
+// rate of keys per second.
+func (m *ReindexManager) run(graphID models.GraphID, index string, worker *reindexWorker) {
+	defer close(worker.done)
+	defer func() {
+		m.mu.Lock()
+		key := string(utils.EncodeReindexKey(graphID, index))
+		if m.workers[key] == worker {
+			delete(m.workers, key)
+		}
+		m.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-worker.stop:
+			return
+		default:
+		}
+
+		start := time.Now()
+		job, scanned, err := m.engine.reindexBatch(graphID, index)
+		if errors.Is(err, badger.ErrConflict) {
+			// An entity of the batch was written meanwhile; scan it again
+			continue
+		}
+		if err != nil {
+			m.engine.logger.Warn("reindex batch failed", "graph", graphID, "index", index, "error", err)
+			m.engine.failReindex(graphID, index, err)
+			return
+		}
+		if job == nil || job.State != models.ReindexRunning {
+			if job != nil && job.State == models.ReindexDone {
+				m.engine.logger.Info("Reindex completed", "graph", graphID, "index", index,
+					"scanned", job.Scanned, "written", job.Written)
+			}
+			return
+		}
+
+		wait := time.Duration(scanned)*time.Second/time.Duration(job.Rate) - time.Since(start)
+		select {
+		case <-worker.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reindexBatch indexes the next batch of a running job and records its
+// progress in the same transaction. It returns the job as saved, or nil if
+// it no longer exists, and the number of entity keys scanned.
+func (e *BadgerEngine) reindexBatch(graphID models.GraphID, index string) (*models.ReindexJob, int, error) {
+	r := reindexers[index]
+	var job *models.ReindexJob
+	var scanned int
+	err := e.db.Update(func(txn *badger.Txn) error {
+		var err error
+		job, err = getReindexJob(txn, graphID, index)
+		if err != nil || job == nil || job.State != models.ReindexRunning {
+			return err
+		}
+
+		// Read the batch before writing to the transaction
+		prefix := r.prefix(graphID)
+		seek := prefix
+		if job.Cursor != nil {
+			// The first key after the cursor
+			seek = append(append([]byte{}, job.Cursor...), 0)
+		}
+		limit := min(job.Rate, rewriteBatchSize)
+		var keys, values [][]byte
+		finished := true
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if bytes.Compare(item.Key(), job.Marker) > 0 {
+				break
+			}
+			if len(keys) == limit {
+				finished = false
+				break
+			}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				it.Close()
+				return err
+			}
+			keys = append(keys, item.KeyCopy(nil))
+			values = append(values, value)
+		}
+		it.Close()
+
+		tx := e.newTransaction(txn)
+		for i, key := range keys {
+			written, err := r.index(tx, graphID, key, values[i])
+			if err != nil {
+				return err
+			}
+			job.Written += int64(written)
+		}
+		scanned = len(keys)
+		if scanned > 0 {
+			job.Cursor = keys[scanned-1]
+			job.Scanned += int64(scanned)
+		}
+
+		now := time.Now().UTC()
+		job.UpdatedAt = now
+		if finished {
+			job.State = models.ReindexDone
+			job.FinishedAt = &now
+		}
+		return putReindexJob(txn, job)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return job, scanned, nil
+}
+
+// failReindex records why a job stopped, so RESUME can retry it
+func (e *BadgerEngine) failReindex(graphID models.GraphID, index string, cause error) {
+	err := e.db.Update(func(txn *badger.Txn) error {
+		job, err := getReindexJob(txn, graphID, index)
+		if err != nil || job == nil || job.State != models.ReindexRunning {
+			return err
+		}
+		job.State = models.ReindexFailed
+		job.Error = cause.Error()
+		job.UpdatedAt = time.Now().UTC()
+		return putReindexJob(txn, job)
+	})
+	if err != nil {
+		e.logger.Warn("failed to record reindex failure", "graph", graphID, "index", index, "error", err)
+	}
+}
+
+// StartReindex begins backfilling an index over the entities a graph holds
+// now, scanning at most rate entity keys per second. Entities written from
+// then on maintain their own entries, so the job ends at the last entity
+// key that exists when it starts. A job that is done, cancelled or failed
+// is started over.
+func (e *BadgerEngine) StartReindex(graphID models.GraphID, index string, rate int) (*models.ReindexJob, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+	r, ok := reindexers[index]
+	if !ok {
+		return nil, fmt.Errorf("unknown index: %s", index)
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("reindex rate must be positive, got %d", rate)
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+
+	e.reindex.controlMu.Lock()
+	defer e.reindex.controlMu.Unlock()
+
+	var job *models.ReindexJob
+	err := e.db.Update(func(txn *badger.Txn) error {
+		existing, err := getReindexJob(txn, graphID, index)
+		if err != nil {
+			return err
+		}
+		if existing != nil && (existing.State == models.ReindexRunning || existing.State == models.ReindexPaused) {
+			return fmt.Errorf("reindex of %s for graph %s is already %s", index, graphID, existing.State)
+		}
+
+		now := time.Now().UTC()
+		job = &models.ReindexJob{
+			Index:     index,
+			Graph:     graphID,
+			State:     models.ReindexRunning,
+			Rate:      rate,
+			StartedAt: now,
+			UpdatedAt: now,
+			ResumedAt: now,
+		}
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false // Only the keys are counted
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := r.prefix(graphID)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			job.Marker = it.Item().KeyCopy(job.Marker)
+			job.Total++
+		}
+		return putReindexJob(txn, job)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start reindex: %w", err)
+	}
+
+	e.reindex.spawn(graphID, index)
+	return job, nil
+}
+
+// ReindexStatus returns the job of an index for a graph, or nil if the
+// index has never been backfilled
+func (e *BadgerEngine) ReindexStatus(graphID models.GraphID, index string) (*models.ReindexJob, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+	if _, ok := reindexers[index]; !ok {
+		return nil, fmt.Errorf("unknown index: %s", index)
+	}
+	if _, err := e.GetGraph(graphID); err != nil {
+		return nil, err
+	}
+
+	var job *models.ReindexJob
+	err := e.db.View(func(txn *badger.Txn) error {
+		var err error
+		job, err = getReindexJob(txn, graphID, index)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reindex status: %w", err)
+	}
+	return job, nil
+}
+
+// PauseReindex stops a running job after its current batch, keeping its
+// cursor for ResumeReindex
+func (e *BadgerEngine) PauseReindex(graphID models.GraphID, index string) (*models.ReindexJob, error) {
+	return e.controlReindex(graphID, index, func(job *models.ReindexJob, now time.Time) error {
+		if job.State != models.ReindexRunning {
+			return fmt.Errorf("reindex of %s for graph %s is %s, not running", index, graphID, job.State)
+		}
+		job.State = models.ReindexPaused
+		return nil
+	})
+}
+
+// ResumeReindex continues a paused or failed job from its cursor
+func (e *BadgerEngine) ResumeReindex(graphID models.GraphID, index string) (*models.ReindexJob, error) {
+	job, err := e.controlReindex(graphID, index, func(job *models.ReindexJob, now time.Time) error {
+		if job.State != models.ReindexPaused && job.State != models.ReindexFailed {
+			return fmt.Errorf("reindex of %s for graph %s is %s, not paused", index, graphID, job.State)
+		}
+		job.State = models.ReindexRunning
+		job.Error = ""
+		job.ResumedAt = now
+		job.ResumedScanned = job.Scanned
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.reindex.spawn(graphID, index)
+	return job, nil
+}
+
+// CancelReindex stops a running or paused job for good. The entries it
+// wrote stay, but the index is not used until a new job completes.
+func (e *BadgerEngine) CancelReindex(graphID models.GraphID, index string) (*models.ReindexJob, error) {
+	return e.controlReindex(graphID, index, func(job *models.ReindexJob, now time.Time) error {
+		if job.State != models.ReindexRunning && job.State != models.ReindexPaused {
+			return fmt.Errorf("reindex of %s for graph %s is %s, not running or paused", index, graphID, job.State)
+		}
+		job.State = models.ReindexCancelled
+		job.FinishedAt = &now
+		return nil
+	})
+}
+
+// controlReindex stops the worker of a job, then changes the job with fn
+// and saves it
+func (e *BadgerEngine) controlReindex(graphID models.GraphID, index string, fn func(job *models.ReindexJob, now time.Time) error) (*models.ReindexJob, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+	if _, ok := reindexers[index]; !ok {
+		return nil, fmt.Errorf("unknown index: %s", index)
+	}
+
+	e.reindex.controlMu.Lock()
+	defer e.reindex.controlMu.Unlock()
+
+	// The worker finishes its batch first, so it cannot overwrite the change
+	e.reindex.halt(graphID, index)
+
+	var job *models.ReindexJob
+	err := e.db.Update(func(txn *badger.Txn) error {
+		var err error
+		job, err = getReindexJob(txn, graphID, index)
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			return fmt.Errorf("no reindex of %s for graph %s", index, graphID)
+		}
+		now := time.Now().UTC()
+		if err := fn(job, now); err != nil {
+			return err
+		}
+		job.UpdatedAt = now
+		return putReindexJob(txn, job)
+	})
+	if err != nil {
+		// A job the change was refused for keeps running
+		if job != nil && job.State == models.ReindexRunning {
+			e.reindex.spawn(graphID, index)
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// indexComplete reports whether an index covers every entity of a graph,
+// which is the case once a reindex is done or the index existed when the
+// graph was created
+func indexComplete(txn *badger.Txn, graphID models.GraphID, index string) (bool, error) {
+	job, err := getReindexJob(txn, graphID, index)
+	if err != nil {
+		return false, err
+	}
+	return job != nil && job.State == models.ReindexDone, nil
+}
+
+// markIndexesComplete records every index as done for a graph that holds no
+// entities yet
+func markIndexesComplete(txn *badger.Txn, graphID models.GraphID) error {
+	now := time.Now().UTC()
+	for index := range reindexers {
+		job := &models.ReindexJob{
+			Index:      index,
+			Graph:      graphID,
+			State:      models.ReindexDone,
+			StartedAt:  now,
+			UpdatedAt:  now,
+			FinishedAt: &now,
+			ResumedAt:  now,
+		}
+		if err := putReindexJob(txn, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getReindexJob reads a job within a transaction, or returns nil if there
+// is none
+func getReindexJob(txn *badger.Txn, graphID models.GraphID, index string) (*models.ReindexJob, error) {
+	item, err := txn.Get(utils.EncodeReindexKey(graphID, index))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := &models.ReindexJob{}
+	if err := item.Value(func(value []byte) error {
+		return json.Unmarshal(value, job)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to deserialize reindex job: %w", err)
+	}
+	return job, nil
+}
+
+// putReindexJob saves a job within a transaction
+func putReindexJob(txn *badger.Txn, job *models.ReindexJob) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to serialize reindex job: %w", err)
+	}
+	return txn.Set(utils.EncodeReindexKey(job.Graph, job.Index), value)
+}
@@ -0,0 +1,382 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// graphRename is the marker stored under the old ID while a graph is being
+// renamed, so a rename interrupted by a crash is finished when the database
+// is opened
+type graphRename struct {
+	To        models.GraphID `json:"to"`
+	StartedAt time.Time      `json:"started_at"`
+	KeysMoved int            `json:"keys_moved"`
+}
+
+// RenameGraph gives a graph a new ID, moving every key it owns to the new
+// ID and returning the number of keys moved. Like DeleteGraph it runs in
+// bounded transactions: the graph is marked as being renamed, its keys are
+// moved prefix by prefix, and finally its record and the marker. Until the
+// last phase commits, the graph's contents are split between the two IDs;
+// if the process stops part way, the rename is resumed when the database is
+// next opened, or by renaming the graph to the same ID again. Snapshots keep
+// the graph record they were taken of.
+func (e *BadgerEngine) RenameGraph(oldID, newID models.GraphID) (int, error) {
+	if e.db == nil {
+		return 0, ErrClosed
+	}
+
+	if err := models.ValidateID("graph", string(newID)); err != nil {
+		return 0, err
+	}
+	if err := utils.CheckGraphID(newID); err != nil {
+		return 0, err
+	}
+	if oldID == newID {
+		return 0, fmt.Errorf("%w graph %s cannot be renamed to itself", models.ErrBadArgument, oldID)
+	}
+
+	rename, err := e.markGraphRenaming(oldID, newID)
+	if err != nil {
+		return 0, err
+	}
+	return e.finishGraphRename(oldID, rename)
+}
+
+// markGraphRenaming stores the rename marker of a graph, or returns the one
+// left by an interrupted rename to the same ID
+func (e *BadgerEngine) markGraphRenaming(oldID, newID models.GraphID) (*graphRename, error) {
+	rename := &graphRename{To: newID, StartedAt: e.clock()}
+	err := e.update(func(tx *BadgerTransaction) error {
+		value, err := tx.get(utils.EncodeGraphRenameKey(oldID))
+		if err == nil {
+			if err := json.Unmarshal(value, rename); err != nil {
+				return fmt.Errorf("failed to read rename marker: %w", err)
+			}
+			if rename.To != newID {
+				return fmt.Errorf("graph %s is being renamed to %s", oldID, rename.To)
+			}
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		if _, err := tx.txn.Get(utils.EncodeGraphKey(oldID)); errors.Is(err, badger.ErrKeyNotFound) {
+			return fmt.Errorf("%w: %s", ErrGraphNotFound, oldID)
+		} else if err != nil {
+			return err
+		}
+		if _, err := tx.txn.Get(utils.EncodeGraphKey(newID)); err == nil {
+			return fmt.Errorf("graph %w: %s", ErrAlreadyExists, newID)
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		for _, graphID := range []models.GraphID{oldID, newID} {
+			if _, err := tx.txn.Get(utils.EncodeGraphDeletionKey(graphID)); err == nil {
+				return fmt.Errorf("graph %s is being deleted", graphID)
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+		}
+		if pending, err := renamesTo(tx.txn, newID); err != nil {
+			return err
+		} else if pending != "" {
+			return fmt.Errorf("graph %w: %s is being renamed to it", ErrAlreadyExists, pending)
+		}
+
+		value, err = json.Marshal(rename)
+		if err != nil {
+			return err
+		}
+		return tx.set(utils.EncodeGraphRenameKey(oldID), value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rename, nil
+}
+
+// renamesTo returns the graph being renamed to graphID, if any
+func renamesTo(txn *badger.Txn, graphID models.GraphID) (models.GraphID, error) {
+	prefix := []byte(utils.RenamePrefix)
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		rename := &graphRename{}
+		if err := it.Item().Value(func(value []byte) error {
+			return json.Unmarshal(value, rename)
+		}); err != nil {
+			continue // Ignored on open too
+		}
+		if rename.To == graphID {
+			return models.GraphID(it.Item().Key()[len(prefix):]), nil
+		}
+	}
+	return "", nil
+}
+
+// finishGraphRename runs the phases of RenameGraph after the graph has been
+// marked. Every phase only moves what is left under the old ID, so it can
+// be rerun.
+func (e *BadgerEngine) finishGraphRename(oldID models.GraphID, rename *graphRename) (int, error) {
+	newID := rename.To
+
+	// Persist the read counts and activity held in memory, so they move
+	// with the graph, and stop background work on the old ID
+	if err := e.flushReads(); err != nil {
+		return rename.KeysMoved, fmt.Errorf("failed to flush read counts: %w", err)
+	}
+	if err := e.flushActivity(); err != nil {
+		return rename.KeysMoved, fmt.Errorf("failed to flush activity: %w", err)
+	}
+	e.reads.discard(oldID)
+	e.maintenance.forget(oldID)
+	e.reindex.forget(oldID)
+	e.forgetActivity(oldID)
+
+	// Graph IDs may extend each other, so a key under the old ID's prefix
+	// belongs to the longest graph ID it starts with
+	graphs, err := e.ListGraphs()
+	if err != nil {
+		return rename.KeysMoved, err
+	}
+	known := map[models.GraphID]bool{oldID: true, newID: true}
+	for _, graph := range graphs {
+		known[graph.ID] = true
+	}
+	owned := func(rest string, scope keyScope) bool {
+		owner, ok := keyOwner(rest, scope, func(graphID models.GraphID) bool { return known[graphID] })
+		return ok && owner == oldID
+	}
+
+	// 1. Move the nodes, edges and every other key under a prefix of the
+	// graph, rewriting the values that name the graph.
+	var running []string
+	prefixes := append([][]byte{utils.CreateNodeIteratorPrefix(oldID), utils.CreateEdgeIteratorPrefix(oldID)}, graphKeyPrefixes(oldID)...)
+	for _, prefix := range prefixes {
+		family := string(prefix[:len(prefix)-len(oldID)-1])
+		err := e.moveGraphKeys(oldID, rename, prefix, func(tx *BadgerTransaction, key []byte) error {
+			rest := string(key[len(family):])
+			if !owned(rest, scopeGraph) {
+				return nil
+			}
+			value, err := tx.get(key)
+			if err != nil {
+				return err
+			}
+			switch family {
+			case utils.ReindexPrefix:
+				var state models.ReindexState
+				value, state, err = renameReindexJob(value, oldID, newID)
+				if state == models.ReindexRunning {
+					running = append(running, rest[len(oldID)+1:])
+				}
+			case utils.SnapshotPrefix:
+				value, err = renameField(value, "graph_id", newID)
+			}
+			if err != nil {
+				return err
+			}
+			return tx.move(key, []byte(family+string(newID)+rest[len(oldID):]), value)
+		})
+		if err != nil {
+			return rename.KeysMoved, fmt.Errorf("failed to move keys under %s: %w", prefix, err)
+		}
+	}
+
+	// 2. Move the graph's expiry index entries, whose keys start with their
+	// time rather than the graph.
+	width := len("2006-01-02T15:04:05Z:")
+	for _, family := range []string{utils.ExpiryIndexPrefix, utils.EdgeExpiryPrefix} {
+		err := e.moveGraphKeys(oldID, rename, []byte(family), func(tx *BadgerTransaction, key []byte) error {
+			rest := string(key[len(family):])
+			if !owned(rest, scopeExpiry) {
+				return nil
+			}
+			value, err := tx.get(key)
+			if err != nil {
+				return err
+			}
+			return tx.move(key, []byte(family+rest[:width]+string(newID)+rest[width+len(oldID):]), value)
+		})
+		if err != nil {
+			return rename.KeysMoved, fmt.Errorf("failed to move expiry index: %w", err)
+		}
+	}
+
+	// 3. Move the graph's own keys and its record, and drop the marker.
+	moved := 0
+	err = e.update(func(tx *BadgerTransaction) error {
+		tx.invalidate(oldID)
+		tx.invalidate(newID)
+		for _, key := range []func(models.GraphID) []byte{
+			utils.EncodeMaintenanceKey,
+			utils.EncodeActivityKey,
+			utils.EncodeGenerationKey,
+			utils.EncodeTypeCountMarkerKey,
+			utils.EncodeGraphKey,
+		} {
+			value, err := tx.get(key(oldID))
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+			if bytes.HasPrefix(key(oldID), []byte(utils.GraphPrefix)) {
+				if value, err = renameField(value, "id", newID); err != nil {
+					return err
+				}
+			}
+			if err := tx.move(key(oldID), key(newID), value); err != nil {
+				return err
+			}
+		}
+		moved = tx.deleted
+		return tx.delete(utils.EncodeGraphRenameKey(oldID))
+	})
+	if err != nil {
+		return rename.KeysMoved, fmt.Errorf("failed to move graph record: %w", err)
+	}
+	rename.KeysMoved += moved
+
+	// Resume the reindex jobs that were running under the old ID
+	for _, index := range running {
+		e.reindex.spawn(newID, index)
+	}
+	return rename.KeysMoved, nil
+}
+
+// moveGraphKeys calls fn with up to rewriteBatchSize keys under prefix per
+// transaction until every key has been seen, adding the keys each
+// transaction moves to the rename marker in the same transaction. Keys fn
+// leaves in place, such as those of a graph whose ID extends this one, are
+// skipped by the next scan.
+func (e *BadgerEngine) moveGraphKeys(graphID models.GraphID, rename *graphRename, prefix []byte, fn func(tx *BadgerTransaction, key []byte) error) error {
+	start := prefix
+	batch := rewriteBatchSize
+	for {
+		keys, err := e.scanKeys(prefix, start, batch)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		moved := 0
+		err = e.update(func(tx *BadgerTransaction) error {
+			tx.invalidate(graphID)
+			tx.invalidate(rename.To)
+			for _, key := range keys {
+				if err := fn(tx, key); err != nil {
+					return err
+				}
+			}
+			moved = tx.deleted
+			updated := *rename
+			updated.KeysMoved += moved
+			value, err := json.Marshal(&updated)
+			if err != nil {
+				return err
+			}
+			return tx.set(utils.EncodeGraphRenameKey(graphID), value)
+		})
+		if errors.Is(err, badger.ErrTxnTooBig) && batch > 1 {
+			batch /= 2
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rename.KeysMoved += moved
+		start = append(keys[len(keys)-1], 0)
+	}
+}
+
+// move writes a value under a new key and deletes the old one. Values are
+// written as stored, so compressed records stay compressed.
+func (t *BadgerTransaction) move(from, to, value []byte) error {
+	t.recordWrite(to)
+	if err := t.txn.Set(to, value); err != nil {
+		return err
+	}
+	return t.delete(from)
+}
+
+// renameField sets a field of a JSON object to a graph ID, keeping the
+// fields this build does not know about
+func renameField(value []byte, field string, graphID models.GraphID) ([]byte, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, fmt.Errorf("failed to deserialize %s: %w", field, err)
+	}
+	encoded, err := json.Marshal(graphID)
+	if err != nil {
+		return nil, err
+	}
+	fields[field] = encoded
+	return json.Marshal(fields)
+}
+
+// renameReindexJob points a reindex job, with the entity keys its cursor
+// and marker hold, at a graph's new ID and returns the job's state
+func renameReindexJob(value []byte, oldID, newID models.GraphID) ([]byte, models.ReindexState, error) {
+	job := &models.ReindexJob{}
+	if err := json.Unmarshal(value, job); err != nil {
+		return nil, "", fmt.Errorf("failed to deserialize reindex job: %w", err)
+	}
+	job.Graph = newID
+	if reindexer, ok := reindexers[job.Index]; ok {
+		oldPrefix, newPrefix := reindexer.prefix(oldID), reindexer.prefix(newID)
+		for _, key := range []*[]byte{&job.Cursor, &job.Marker} {
+			if bytes.HasPrefix(*key, oldPrefix) {
+				*key = append(append([]byte{}, newPrefix...), (*key)[len(oldPrefix):]...)
+			}
+		}
+	}
+	value, err := json.Marshal(job)
+	return value, job.State, err
+}
+
+// resumeGraphRenames finishes the renames a previous run of the process
+// left interrupted and returns how many it finished. Failures are logged,
+// and retried the next time the database is opened or the graph is renamed.
+func (e *BadgerEngine) resumeGraphRenames() int {
+	renames := make(map[models.GraphID]*graphRename)
+	prefix := []byte(utils.RenamePrefix)
+	err := e.iterateWithPrefix(prefix, func(key []byte, value []byte) error {
+		rename := &graphRename{}
+		if err := json.Unmarshal(value, rename); err != nil || rename.To == "" {
+			e.logger.Warn("Ignoring unreadable graph rename marker", "key", string(key), "error", err)
+			return nil
+		}
+		renames[models.GraphID(key[len(prefix):])] = rename
+		return nil
+	})
+	if err != nil {
+		e.logger.Warn("Failed to read graph rename markers", "error", err)
+		return 0
+	}
+
+	resumed := 0
+	for graphID, rename := range renames {
+		moved, err := e.finishGraphRename(graphID, rename)
+		if err != nil {
+			e.logger.Warn("Failed to resume graph rename", "graph", graphID, "to", rename.To, "error", err)
+			continue
+		}
+		e.logger.Info("Resumed graph rename", "graph", graphID, "to", rename.To, "keys_moved", moved)
+		resumed++
+	}
+	return resumed
+}
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// RepairReport describes what Repair changed
+type RepairReport struct {
+	DeletionsResumed int   `json:"deletions_resumed"`
+	OrphansDeleted   int64 `json:"orphans_deleted"`
+	// IndexesRebuilt lists the index mismatches found before the repair
+	// whose indexes were rebuilt from the node and edge records
+	IndexesRebuilt []KeyAuditMismatch `json:"indexes_rebuilt"`
+}
+
+// indexBuilders return the index entries a node or edge record should have,
+// by index family. Alias indexes are not rebuilt, as the alias records
+// cannot tell a stale index entry from a missing alias.
+var indexBuilders = map[string]func(graphID models.GraphID, value []byte) (map[string]string, error){
+	"ti:n": func(graphID models.GraphID, value []byte) (map[string]string, error) {
+		node := &models.Node{}
+		if err := node.FromJSON(value); err != nil {
+			return nil, fmt.Errorf("failed to deserialize node: %w", err)
+		}
+		return map[string]string{string(utils.EncodeNodeTypeIndexKey(graphID, node.Type, node.ID)): string(node.ID)}, nil
+	},
+	"ti:e": func(graphID models.GraphID, value []byte) (map[string]string, error) {
+		edge := &models.Edge{}
+		if err := edge.FromJSON(value); err != nil {
+			return nil, fmt.Errorf("failed to deserialize edge: %w", err)
+		}
+		return map[string]string{string(utils.EncodeEdgeTypeIndexKey(graphID, edge.Type, edge.ID)): string(edge.ID)}, nil
+	},
+	"ni:out": func(graphID models.GraphID, value []byte) (map[string]string, error) {
+		edge := &models.Edge{}
+		if err := edge.FromJSON(value); err != nil {
+			return nil, fmt.Errorf("failed to deserialize edge: %w", err)
+		}
+		return map[string]string{string(utils.EncodeNodeOutEdgeIndexKey(graphID, edge.FromNodeID, edge.ID)): string(edge.ID)}, nil
+	},
+	"ni:in": func(graphID models.GraphID, value []byte) (map[string]string, error) {
+		edge := &models.Edge{}
+		if err := edge.FromJSON(value); err != nil {
+			return nil, fmt.Errorf("failed to deserialize edge: %w", err)
+		}
+		return map[string]string{string(utils.EncodeNodeInEdgeIndexKey(graphID, edge.ToNodeID, edge.ID)): string(edge.ID)}, nil
+	},
+}
+
+// Repair fixes what AuditKeys finds wrong with the keyspace, for offline
+// tools run while no server uses the database. It finishes interrupted
+// graph deletions, deletes the keys of graphs that have no graph record and
+// rebuilds the node type, edge type and edge endpoint indexes whose entry
+// counts differ from their records. Unknown keys and alias index mismatches
+// are left alone; auditing again after Repair reports what remains.
+func (e *BadgerEngine) Repair() (*RepairReport, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	report := &RepairReport{IndexesRebuilt: []KeyAuditMismatch{}}
+	report.DeletionsResumed = e.resumeGraphDeletions()
+
+	audit, err := e.AuditKeys("")
+	if err != nil {
+		return report, err
+	}
+	exists := func(graphID models.GraphID) bool {
+		_, ok := audit.Graphs[graphID]
+		return ok
+	}
+
+	if audit.Orphaned > 0 {
+		report.OrphansDeleted, err = e.deleteOrphans(exists)
+		if err != nil {
+			return report, fmt.Errorf("failed to delete orphaned keys: %w", err)
+		}
+	}
+
+	for _, mismatch := range audit.Mismatches {
+		if _, ok := indexBuilders[mismatch.Index]; !ok {
+			continue
+		}
+		if err := e.rebuildIndex(mismatch.Graph, mismatch.Family, mismatch.Index, exists); err != nil {
+			return report, fmt.Errorf("failed to rebuild %s index of graph %s: %w", mismatch.Index, mismatch.Graph, err)
+		}
+		report.IndexesRebuilt = append(report.IndexesRebuilt, mismatch)
+	}
+
+	e.ClearCache()
+	return report, nil
+}
+
+// deleteOrphans deletes the graph-scoped keys of graphs exists does not
+// report, up to rewriteBatchSize per transaction, and returns how many
+func (e *BadgerEngine) deleteOrphans(exists func(models.GraphID) bool) (int64, error) {
+	var deleted int64
+	var cursor []byte
+	for {
+		var orphans [][]byte
+		done := true
+		err := e.db.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			defer it.Close()
+
+			for it.Seek(cursor); it.Valid(); it.Next() {
+				key := it.Item().KeyCopy(nil)
+				if cursor != nil && string(key) == string(cursor) {
+					continue
+				}
+				if len(orphans) == rewriteBatchSize {
+					done = false
+					return nil
+				}
+				cursor = key
+				family, ok := familyOf(string(key))
+				if !ok || family.scope == scopeNone {
+					continue
+				}
+				if _, ok := keyOwner(string(key[len(family.prefix):]), family.scope, exists); !ok {
+					orphans = append(orphans, key)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+
+		err = e.update(func(tx *BadgerTransaction) error {
+			for _, key := range orphans {
+				if err := tx.delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int64(len(orphans))
+		if done {
+			return deleted, nil
+		}
+	}
+}
+
+// rebuildIndex makes a graph's index hold exactly the entries its records
+// call for: entries without a matching record are deleted and missing ones
+// are added, up to rewriteBatchSize per transaction
+func (e *BadgerEngine) rebuildIndex(graphID models.GraphID, family, index string, exists func(models.GraphID) bool) error {
+	recordFamily, indexFamily := familyByName(family), familyByName(index)
+	build := indexBuilders[index]
+
+	// owned reports whether a key of the family belongs to the graph rather
+	// than to a graph whose ID starts with the graph's ID and ":"
+	owned := func(key []byte, f keyFamily) bool {
+		owner, ok := keyOwner(string(key[len(f.prefix):]), f.scope, exists)
+		return ok && owner == graphID
+	}
+
+	missing := make(map[string]string)
+	err := e.iterateWithPrefix([]byte(recordFamily.prefix+string(graphID)+":"), func(key []byte, value []byte) error {
+		if !owned(key, recordFamily) {
+			return nil
+		}
+		entries, err := build(graphID, value)
+		if err != nil {
+			return err
+		}
+		for key, id := range entries {
+			missing[key] = id
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var stale [][]byte
+	err = e.iterateWithPrefix([]byte(indexFamily.prefix+string(graphID)+":"), func(key []byte, value []byte) error {
+		if !owned(key, indexFamily) {
+			return nil
+		}
+		if id, ok := missing[string(key)]; ok && id == string(value) {
+			delete(missing, string(key))
+			return nil
+		}
+		stale = append(stale, append([]byte(nil), key...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	type write struct {
+		key []byte
+		id  []byte // nil deletes the key
+	}
+	writes := make([]write, 0, len(stale)+len(missing))
+	for _, key := range stale {
+		writes = append(writes, write{key: key})
+	}
+	for key, id := range missing {
+		writes = append(writes, write{key: []byte(key), id: []byte(id)})
+	}
+
+	for start := 0; start < len(writes); start += rewriteBatchSize {
+		batch := writes[start:min(start+rewriteBatchSize, len(writes))]
+		err := e.update(func(tx *BadgerTransaction) error {
+			tx.invalidate(graphID)
+			for _, w := range batch {
+				var err error
+				if w.id == nil {
+					err = tx.delete(w.key)
+				} else {
+					err = tx.set(w.key, w.id)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(writes) > 0 {
+		e.logger.Info("Index rebuilt", "graph", graphID, "index", index, "deleted", len(stale), "added", len(writes)-len(stale))
+	}
+	return nil
+}
+
+// familyByName returns the key family with the given name
+func familyByName(name string) keyFamily {
+	for _, family := range keyFamilies {
+		if family.name == name {
+			return family
+		}
+	}
+	panic("unknown key family: " + name)
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// DefaultScanPrefetch is how many values prefix scans read ahead by default
+const DefaultScanPrefetch = 10
+
+// DefaultListPrefetch is how many values ListNodes, ListEdges, ScanNodes and
+// ScanEdges read ahead by default. These read every value under their
+// prefix, so a larger read-ahead overlaps more value reads with decoding.
+const DefaultListPrefetch = 100
+
+// WithScanPrefetch sets how many values prefix scans read ahead; 0 reads
+// each value only when the scan reaches it
+func WithScanPrefetch(size int) Option {
+	return func(e *BadgerEngine) {
+		e.scanPrefetch = size
+	}
+}
+
+// WithListPrefetch sets how many values full node and edge listings and
+// scans read ahead; 0 reads each value only when the listing reaches it
+func WithListPrefetch(size int) Option {
+	return func(e *BadgerEngine) {
+		e.listPrefetch = size
+	}
+}
+
+// scanOptions tunes how a prefix scan reads from Badger
+type scanOptions struct {
+	prefetchValues bool // Read values ahead of the iterator
+	prefetchSize   int  // How many values are read ahead
+	keysOnly       bool // Never read values; fn is called with a nil value
+}
+
+// prefetching returns scan options reading size values ahead, or none when
+// size is 0
+func prefetching(size int) scanOptions {
+	return scanOptions{prefetchValues: size > 0, prefetchSize: size}
+}
+
+// keysOnly is the scan options of scans that only look at keys
+var keysOnly = scanOptions{keysOnly: true}
+
+// iterator opens an iterator over prefix with the scan options
+func (s scanOptions) iterator(txn *badger.Txn, prefix []byte) *badger.Iterator {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.PrefetchValues = s.prefetchValues && !s.keysOnly
+	if s.prefetchSize > 0 {
+		opts.PrefetchSize = s.prefetchSize
+	}
+	return txn.NewIterator(opts)
+}
+
+// scanWithPrefix calls fn with each key under prefix and its value, read
+// as the scan options say. Returning ErrStopScan from fn ends the scan; it
+// is returned like any other error.
+func (e *BadgerEngine) scanWithPrefix(prefix []byte, scan scanOptions, fn func(key []byte, value []byte) error) error {
+	return e.db.View(func(txn *badger.Txn) error {
+		it := scan.iterator(txn, prefix)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if scan.keysOnly {
+				if err := fn(item.Key(), nil); err != nil {
+					return err
+				}
+				continue
+			}
+			err := item.Value(func(value []byte) error {
+				return fn(item.Key(), value)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// iterateWithPrefix iterates over keys with a given prefix
+func (e *BadgerEngine) iterateWithPrefix(prefix []byte, fn func(key []byte, value []byte) error) error {
+	return e.scanWithPrefix(prefix, prefetching(e.scanPrefetch), fn)
+}
+
+// listWithPrefix iterates over the node or edge records under prefix,
+// reading values ahead as WithListPrefetch sets
+func (e *BadgerEngine) listWithPrefix(prefix []byte, fn func(key []byte, value []byte) error) error {
+	return e.scanWithPrefix(prefix, prefetching(e.listPrefetch), fn)
+}
+
+// listPage calls decode with the values of at most limit records under
+// prefix, in key order, starting after the record prefix+after, or at the
+// first one if after is empty. decode reports whether it kept a record;
+// records it skips, such as expired ones, do not count toward limit. Only
+// the keys of the page are read, and the key after it, whose presence is
+// reported as whether more records follow.
+func (e *BadgerEngine) listPage(prefix []byte, after string, limit int, decode func(value []byte) (bool, error)) (bool, error) {
+	seek := prefix
+	if after != "" {
+		seek = append(append([]byte{}, prefix...), after...)
+	}
+
+	more := false
+	err := e.db.View(func(txn *badger.Txn) error {
+		it := prefetching(min(e.listPrefetch, limit)).iterator(txn, prefix)
+		defer it.Close()
+
+		kept := 0
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if after != "" && bytes.Equal(item.Key(), seek) {
+				continue
+			}
+			if kept == limit {
+				more = true
+				return nil
+			}
+			err := item.Value(func(value []byte) error {
+				ok, err := decode(value)
+				if ok {
+					kept++
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return more, err
+}
+
+// iterateKeysWithPrefix calls fn with each key under prefix without reading
+// any values
+func (e *BadgerEngine) iterateKeysWithPrefix(prefix []byte, fn func(key []byte) error) error {
+	return e.scanWithPrefix(prefix, keysOnly, func(key []byte, _ []byte) error {
+		return fn(key)
+	})
+}
+
+// countWithPrefix counts the keys under prefix without reading any values,
+// stopping at limit unless it is negative
+func countWithPrefix(txn *badger.Txn, prefix []byte, limit int) int {
+	it := keysOnly.iterator(txn, prefix)
+	defer it.Close()
+
+	count := 0
+	for it.Seek(prefix); it.ValidForPrefix(prefix) && (limit < 0 || count < limit); it.Next() {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// CreateSnapshot stores an immutable copy of a graph's nodes and edges. The
+// copy is read in a single transaction, so it reflects one point in time.
+// When the graph has more snapshots than the configured limit, the oldest
+// are deleted.
+func (e *BadgerEngine) CreateSnapshot(graphID models.GraphID, label string) (*models.Snapshot, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	data := &models.SnapshotData{}
+	err := e.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(utils.EncodeGraphKey(graphID))
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return fmt.Errorf("%w: %s", ErrGraphNotFound, graphID)
+			}
+			return err
+		}
+		data.Graph = &models.Graph{}
+		if err := item.Value(data.Graph.FromJSON); err != nil {
+			return fmt.Errorf("failed to deserialize graph: %w", err)
+		}
+
+		if err := iterateTxnPrefix(txn, utils.CreateNodeIteratorPrefix(graphID), func(value []byte) error {
+			node := &models.Node{}
+			if err := node.FromJSON(value); err != nil {
+				return fmt.Errorf("failed to deserialize node: %w", err)
+			}
+			if !node.IsExpired() {
+				data.Nodes = append(data.Nodes, node)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		return iterateTxnPrefix(txn, utils.CreateEdgeIteratorPrefix(graphID), func(value []byte) error {
+			edge := &models.Edge{}
+			if err := edge.FromJSON(value); err != nil {
+				return fmt.Errorf("failed to deserialize edge: %w", err)
+			}
+			if !edge.IsExpired() {
+				data.Edges = append(data.Edges, edge)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read graph: %w", err)
+	}
+
+	compressed, err := data.Compress()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize snapshot: %w", err)
+	}
+
+	now := time.Now().UTC()
+	snapshot := &models.Snapshot{
+		// Zero-padded hex timestamps sort in creation order
+		ID:        fmt.Sprintf("snap-%016x", now.UnixNano()),
+		GraphID:   graphID,
+		Label:     label,
+		CreatedAt: now,
+		NodeCount: len(data.Nodes),
+		EdgeCount: len(data.Edges),
+		Size:      len(compressed),
+	}
+	meta, err := snapshot.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize snapshot: %w", err)
+	}
+
+	err = e.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(utils.EncodeSnapshotDataKey(graphID, snapshot.ID), compressed); err != nil {
+			return err
+		}
+		return txn.Set(utils.EncodeSnapshotKey(graphID, snapshot.ID), meta)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+
+	if err := e.pruneSnapshots(graphID); err != nil {
+		return nil, err
+	}
+
+	e.logger.Info("Snapshot created", "graph", graphID, "snapshot", snapshot.ID, "size", snapshot.Size)
+	return snapshot, nil
+}
+
+// ListSnapshots returns the snapshots of a graph, oldest first
+func (e *BadgerEngine) ListSnapshots(graphID models.GraphID) ([]*models.Snapshot, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	var snapshots []*models.Snapshot
+	err := e.iterateWithPrefix(utils.CreateSnapshotIteratorPrefix(graphID), func(key []byte, value []byte) error {
+		snapshot := &models.Snapshot{}
+		if err := snapshot.FromJSON(value); err != nil {
+			return fmt.Errorf("failed to deserialize snapshot: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// ReadSnapshot loads the contents of a snapshot
+func (e *BadgerEngine) ReadSnapshot(graphID models.GraphID, snapshotID string) (*models.SnapshotData, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	value, err := e.get(utils.EncodeSnapshotDataKey(graphID, snapshotID))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, fmt.Errorf("snapshot not found: %s", snapshotID)
+		}
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+
+	data := &models.SnapshotData{}
+	if err := data.Decompress(value); err != nil {
+		return nil, fmt.Errorf("failed to deserialize snapshot: %w", err)
+	}
+
+	return data, nil
+}
+
+// DeleteSnapshot deletes a snapshot and its contents
+func (e *BadgerEngine) DeleteSnapshot(graphID models.GraphID, snapshotID string) error {
+	if e.db == nil {
+		return ErrClosed
+	}
+
+	return e.db.Update(func(txn *badger.Txn) error {
+		metaKey := utils.EncodeSnapshotKey(graphID, snapshotID)
+		if _, err := txn.Get(metaKey); err != nil {
+			if err == badger.ErrKeyNotFound {
+				return fmt.Errorf("snapshot not found: %s", snapshotID)
+			}
+			return fmt.Errorf("failed to get snapshot: %w", err)
+		}
+		if err := txn.Delete(metaKey); err != nil {
+			return err
+		}
+		return txn.Delete(utils.EncodeSnapshotDataKey(graphID, snapshotID))
+	})
+}
+
+// pruneSnapshots deletes the oldest snapshots of a graph over the limit
+func (e *BadgerEngine) pruneSnapshots(graphID models.GraphID) error {
+	if e.maxSnapshots <= 0 {
+		return nil
+	}
+
+	snapshots, err := e.ListSnapshots(graphID)
+	if err != nil {
+		return err
+	}
+
+	for len(snapshots) > e.maxSnapshots {
+		if err := e.DeleteSnapshot(graphID, snapshots[0].ID); err != nil {
+			return fmt.Errorf("failed to prune snapshot %s: %w", snapshots[0].ID, err)
+		}
+		e.logger.Debug("Snapshot pruned", "graph", graphID, "snapshot", snapshots[0].ID)
+		snapshots = snapshots[1:]
+	}
+
+	return nil
+}
+
+// iterateTxnPrefix calls fn with the value of every key with the given prefix
+func iterateTxnPrefix(txn *badger.Txn, prefix []byte, fn func(value []byte) error) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := it.Item().Value(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
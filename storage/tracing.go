@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/ywadi/PathwayDB/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// transactionTracer starts spans for a sample of write transactions
+type transactionTracer struct {
+	tracer trace.Tracer
+	rate   float64
+}
+
+// SetTracing traces the given fraction of write transactions, from 0 to 1,
+// with spans from provider. The engine's API carries no context, so each
+// span is the root of its own trace. A nil provider or a rate of 0 turns
+// storage tracing off.
+func (e *BadgerEngine) SetTracing(provider trace.TracerProvider, rate float64) {
+	if provider == nil || rate <= 0 {
+		e.tracer.Store(nil)
+		return
+	}
+	e.tracer.Store(&transactionTracer{tracer: provider.Tracer(tracing.TracerName), rate: min(rate, 1)})
+}
+
+// traceTransaction starts the span of a write transaction if it is sampled,
+// and returns nil otherwise
+func (e *BadgerEngine) traceTransaction() trace.Span {
+	t := e.tracer.Load()
+	if t == nil || (t.rate < 1 && rand.Float64() >= t.rate) {
+		return nil
+	}
+	_, span := t.tracer.Start(context.Background(), "storage.transaction", trace.WithSpanKind(trace.SpanKindInternal))
+	return span
+}
+
+// endTransaction records what a traced transaction wrote and ends its span.
+// tx is nil if the transaction could not begin.
+func endTransaction(span trace.Span, tx *BadgerTransaction, err error) {
+	if span == nil {
+		return
+	}
+	if tx != nil {
+		span.SetAttributes(
+			attribute.Int("graphs", len(tx.touched)),
+			attribute.Int("records_changed", len(tx.written)),
+			attribute.Int("keys_deleted", tx.deleted),
+		)
+	}
+	tracing.End(span, err)
+}
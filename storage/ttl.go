@@ -1,8 +1,9 @@
 package storage
 
 import (
-	"fmt"
-	"strings"
+	"context"
+	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
@@ -10,87 +11,205 @@ import (
 	"github.com/ywadi/PathwayDB/utils"
 )
 
-// TTLManager handles the expiration of nodes.
+// TTLManager handles the expiration of nodes and edges.
 type TTLManager struct {
-	engine *BadgerEngine
-	stop   chan struct{}
+	engine  *BadgerEngine
+	stop    chan struct{}
+	done    chan struct{}
+	pending chan expiredEntity
 }
 
+// expiredEntity identifies a node or edge found expired on the read path
+// that is waiting to be deleted by the TTL manager.
+type expiredEntity struct {
+	graphID models.GraphID
+	nodeID  models.NodeID
+	edgeID  models.EdgeID
+}
+
+// pendingQueueSize bounds the number of lazily detected expirations waiting
+// for deletion. Anything dropped on overflow is picked up by the next sweep.
+const pendingQueueSize = 1024
+
 // NewTTLManager creates a new TTL manager.
 func NewTTLManager(engine *BadgerEngine) *TTLManager {
 	return &TTLManager{
-		engine: engine,
-		stop:   make(chan struct{}),
+		engine:  engine,
+		stop:    make(chan struct{}),
+		pending: make(chan expiredEntity, pendingQueueSize),
 	}
 }
 
-// Start begins the background TTL cleanup process.
+// Start begins the background TTL cleanup process. A first sweep runs
+// immediately so entities that expired while the database was closed are
+// removed without waiting for the first tick.
 func (tm *TTLManager) Start() {
-	go tm.run()
+	tm.stop = make(chan struct{})
+	tm.done = make(chan struct{})
+	go tm.run(tm.stop, tm.done)
 }
 
-// Stop halts the background TTL cleanup process.
+// Stop halts the background TTL cleanup process and waits for an in-flight
+// sweep to finish so the database can be closed safely.
 func (tm *TTLManager) Stop() {
+	if tm.done == nil {
+		return
+	}
 	close(tm.stop)
+	<-tm.done
+	tm.done = nil
 }
 
-// run is the main loop for the TTL manager.
 // Cleanup is an exported method to manually trigger a cleanup for testing.
+// Entities queued from the read path are deleted first, then a sweep runs.
 func (tm *TTLManager) Cleanup() {
-	tm.cleanupExpiredNodes()
+	for drained := false; !drained; {
+		select {
+		case entity := <-tm.pending:
+			tm.deleteExpired(entity)
+		default:
+			drained = true
+		}
+	}
+	tm.cleanupExpired()
+}
+
+// enqueueNode schedules an expired node for deletion without blocking the caller.
+func (tm *TTLManager) enqueueNode(graphID models.GraphID, nodeID models.NodeID) {
+	select {
+	case tm.pending <- expiredEntity{graphID: graphID, nodeID: nodeID}:
+	default:
+	}
 }
 
-func (tm *TTLManager) run() {
-	ticker := time.NewTicker(1 * time.Minute) // Check for expired nodes every minute
+// enqueueEdge schedules an expired edge for deletion without blocking the caller.
+func (tm *TTLManager) enqueueEdge(graphID models.GraphID, edgeID models.EdgeID) {
+	select {
+	case tm.pending <- expiredEntity{graphID: graphID, edgeID: edgeID}:
+	default:
+	}
+}
+
+// run is the main loop for the TTL manager.
+func (tm *TTLManager) run(stop, done chan struct{}) {
+	defer close(done)
+
+	tm.cleanupExpired()
+
+	ticker := time.NewTicker(1 * time.Minute) // Check for expired entities every minute
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			tm.cleanupExpiredNodes()
-		case <-tm.stop:
+			tm.cleanupExpired()
+		case entity := <-tm.pending:
+			tm.deleteExpired(entity)
+		case <-stop:
 			return
 		}
 	}
 }
 
-// cleanupExpiredNodes scans for and deletes expired nodes.
-func (tm *TTLManager) cleanupExpiredNodes() {
-	var expiredKeys [][]byte
-	prefix := utils.CreateExpiryIteratorPrefix()
-	now := time.Now().UTC().Format(time.RFC3339)
-
-	// Phase 1: Collect keys in a read-only transaction.
-	tm.engine.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			key := item.Key()
-			keyStr := string(key)
+// deleteExpired removes an entity queued from the read path.
+func (tm *TTLManager) deleteExpired(entity expiredEntity) {
+	if _, err := tm.deleteIfExpired(entity); err != nil {
+		tm.engine.logger.Warn("failed to delete expired entity", "graph", entity.graphID, "node", entity.nodeID, "edge", entity.edgeID, "error", err)
+	}
+}
 
-			parts := strings.Split(keyStr, ":")
-			if len(parts) >= 2 && parts[1] > now {
-				break // Stop if we've passed the current time.
+// deleteIfExpired deletes an entity if it is still expired when the delete
+// transaction reads it, and reports whether it did. The entity may have been
+// refreshed, recreated or deleted since it was found expired, so the check
+// is made again in the same transaction as the delete.
+func (tm *TTLManager) deleteIfExpired(entity expiredEntity) (bool, error) {
+	deleted := false
+	err := tm.engine.update(func(tx *BadgerTransaction) error {
+		if entity.nodeID != "" {
+			node, err := tx.GetNode(entity.graphID, entity.nodeID)
+			if errors.Is(err, ErrNodeNotFound) {
+				return nil
+			}
+			if err != nil || !node.IsExpired() {
+				return err
 			}
-			expiredKeys = append(expiredKeys, item.KeyCopy(nil))
+			deleted = true
+			return tx.DeleteNode(entity.graphID, entity.nodeID)
 		}
-		return nil
+		edge, err := tx.GetEdge(entity.graphID, entity.edgeID)
+		if errors.Is(err, ErrEdgeNotFound) {
+			return nil
+		}
+		if err != nil || !edge.IsExpired() {
+			return err
+		}
+		deleted = true
+		return tx.DeleteEdge(entity.graphID, entity.edgeID)
 	})
+	return deleted && err == nil, err
+}
+
+// cleanupExpired scans for and deletes expired nodes, then expired edges.
+// Edges go through BadgerTransaction.DeleteEdge like nodes, so their type
+// and node indexes are removed with them.
+func (tm *TTLManager) cleanupExpired() {
+	if tm.engine.db == nil {
+		return
+	}
+
+	start := time.Now()
+	now := time.Now().UTC().Format(time.RFC3339)
 
-	// Phase 2: Delete the collected keys in a separate write transaction.
-	if len(expiredKeys) > 0 {
-		for _, key := range expiredKeys {
-			graphID, nodeID := utils.DecodeExpiryIndexKey(key)
-			if graphID != "" && nodeID != "" {
-				// Each deletion gets its own transaction to ensure atomicity.
-				if err := tm.engine.DeleteNode(graphID, nodeID); err != nil {
-					fmt.Printf("warn: failed to delete expired node %s: %v\n", nodeID, err)
-				}
+	// Phase 1: Collect keys in a read-only transaction. The expiry time is
+	// in the key, so no values are read.
+	collect := func(prefix []byte) [][]byte {
+		var keys [][]byte
+		tm.engine.iterateKeysWithPrefix(prefix, func(key []byte) error {
+			if utils.DecodeExpiryIndexTime(key) > now {
+				return ErrStopScan // Stop if we've passed the current time.
 			}
+			keys = append(keys, append([]byte(nil), key...))
+			return nil
+		})
+		return keys
+	}
+	expiredNodes := collect(utils.CreateExpiryIteratorPrefix())
+	expiredEdges := collect(utils.CreateEdgeExpiryIteratorPrefix())
+
+	// Phase 2: Delete the collected entities, each in its own write
+	// transaction that checks the entity is still expired.
+	deleted, failed := 0, 0
+	remove := func(entity expiredEntity) {
+		ok, err := tm.deleteIfExpired(entity)
+		if err != nil {
+			tm.engine.logger.Warn("failed to delete expired entity", "graph", entity.graphID, "node", entity.nodeID, "edge", entity.edgeID, "error", err)
+			failed++
+			return
+		}
+		if ok {
+			deleted++
+		}
+	}
+	for _, key := range expiredNodes {
+		graphID, nodeID := utils.DecodeExpiryIndexKey(key)
+		if graphID != "" && nodeID != "" {
+			remove(expiredEntity{graphID: graphID, nodeID: nodeID})
+		}
+	}
+	for _, key := range expiredEdges {
+		graphID, edgeID := utils.DecodeEdgeExpiryIndexKey(key)
+		if graphID != "" && edgeID != "" {
+			remove(expiredEntity{graphID: graphID, edgeID: edgeID})
 		}
 	}
+
+	// Quiet sweeps are only interesting when debugging.
+	level := slog.LevelDebug
+	if len(expiredNodes)+len(expiredEdges) > 0 {
+		level = slog.LevelInfo
+	}
+	tm.engine.logger.Log(context.Background(), level, "TTL sweep completed",
+		"expired", deleted, "failed", failed, "duration", time.Since(start))
 }
 
 // AddNodeToExpiryIndex adds a node to the expiration index.
@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// Every graph keeps the number of its nodes and edges of each type under
+// type count keys, so stats need not list the graph to count it. A
+// transaction collects the changes its node and edge writes make to the
+// counts, and update adds them to the stored counts as it commits. Counts
+// include entities that have expired but not yet been cleaned up, as the
+// records are still stored.
+
+// typeCountLocks serializes the commits that change the type counts of a
+// graph. The stored counts are read outside the transaction that writes
+// them, so writers to the same graph do not conflict over them, and the
+// lock keeps a count from being read while another commit changes it.
+type typeCountLocks struct {
+	locks sync.Map // models.GraphID -> *sync.Mutex
+}
+
+// lock locks the counts of graphs, in sorted order so two commits never
+// wait on each other, and returns the function that unlocks them
+func (l *typeCountLocks) lock(graphIDs []models.GraphID) func() {
+	sort.Slice(graphIDs, func(i, j int) bool { return graphIDs[i] < graphIDs[j] })
+	mutexes := make([]*sync.Mutex, len(graphIDs))
+	for i, graphID := range graphIDs {
+		mu, _ := l.locks.LoadOrStore(graphID, &sync.Mutex{})
+		mutexes[i] = mu.(*sync.Mutex)
+		mutexes[i].Lock()
+	}
+	return func() {
+		for _, mu := range mutexes {
+			mu.Unlock()
+		}
+	}
+}
+
+// countNode records that the transaction changed the number of nodes of a
+// type by delta
+func (t *BadgerTransaction) countNode(graphID models.GraphID, nodeType models.NodeType, delta int64) {
+	t.countType(graphID, utils.EncodeNodeTypeCountKey(graphID, nodeType), delta)
+}
+
+// countEdge records that the transaction changed the number of edges of a
+// type by delta
+func (t *BadgerTransaction) countEdge(graphID models.GraphID, edgeType models.EdgeType, delta int64) {
+	t.countType(graphID, utils.EncodeEdgeTypeCountKey(graphID, edgeType), delta)
+}
+
+func (t *BadgerTransaction) countType(graphID models.GraphID, key []byte, delta int64) {
+	if t.typeCounts == nil {
+		t.typeCounts = make(map[models.GraphID]map[string]int64)
+	}
+	if t.typeCounts[graphID] == nil {
+		t.typeCounts[graphID] = make(map[string]int64)
+	}
+	t.typeCounts[graphID][string(key)] += delta
+}
+
+// writeTypeCounts adds the transaction's changes to the stored type counts
+// and returns the function, never nil, that unlocks the counts once the
+// transaction has committed or failed. Counts that drop to zero are kept, so
+// DeleteGraph removes the keys CountGraphKeys counted beforehand.
+func (e *BadgerEngine) writeTypeCounts(tx *BadgerTransaction) (func(), error) {
+	if len(tx.typeCounts) == 0 {
+		return func() {}, nil
+	}
+	graphIDs := make([]models.GraphID, 0, len(tx.typeCounts))
+	for graphID := range tx.typeCounts {
+		graphIDs = append(graphIDs, graphID)
+	}
+	unlock := e.typeCountLocks.lock(graphIDs)
+
+	totals := make(map[string]int64)
+	err := e.db.View(func(txn *badger.Txn) error {
+		for _, deltas := range tx.typeCounts {
+			for key, delta := range deltas {
+				if delta == 0 {
+					continue
+				}
+				count, err := readTypeCount(txn, []byte(key))
+				if err != nil {
+					return err
+				}
+				totals[key] = count + delta
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		unlock()
+		return func() {}, fmt.Errorf("failed to read type counts: %w", err)
+	}
+	for key, total := range totals {
+		if total < 0 {
+			e.logger.Warn("Type count dropped below zero, resetting it", "key", key, "count", total)
+			total = 0
+		}
+		if err := tx.txn.Set([]byte(key), encodeTypeCount(total)); err != nil {
+			unlock()
+			return func() {}, fmt.Errorf("failed to write type count: %w", err)
+		}
+	}
+	return unlock, nil
+}
+
+func encodeTypeCount(count int64) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(count))
+	return value
+}
+
+func decodeTypeCount(value []byte) int64 {
+	if len(value) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(value))
+}
+
+// readTypeCount reads a stored type count, 0 if there is none
+func readTypeCount(txn *badger.Txn, key []byte) (int64, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = item.Value(func(value []byte) error {
+		count = decodeTypeCount(value)
+		return nil
+	})
+	return count, err
+}
+
+// markTypeCountsComplete records that a graph's type counts cover all of its
+// nodes and edges
+func markTypeCountsComplete(txn *badger.Txn, graphID models.GraphID) error {
+	return txn.Set(utils.EncodeTypeCountMarkerKey(graphID), nil)
+}
+
+// CountNodesByType returns the number of nodes of each type in a graph,
+// read from its type counts. Types with no nodes are left out.
+func (e *BadgerEngine) CountNodesByType(graphID models.GraphID) (map[models.NodeType]int, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	counts, err := e.typeCounts(graphID, "n")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count nodes by type: %w", err)
+	}
+	result := make(map[models.NodeType]int, len(counts))
+	for typeName, count := range counts {
+		result[models.NodeType(typeName)] = count
+	}
+	return result, nil
+}
+
+// CountEdgesByType returns the number of edges of each type in a graph,
+// read from its type counts. Types with no edges are left out.
+func (e *BadgerEngine) CountEdgesByType(graphID models.GraphID) (map[models.EdgeType]int, error) {
+	if e.db == nil {
+		return nil, ErrClosed
+	}
+
+	counts, err := e.typeCounts(graphID, "e")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count edges by type: %w", err)
+	}
+	result := make(map[models.EdgeType]int, len(counts))
+	for typeName, count := range counts {
+		result[models.EdgeType(typeName)] = count
+	}
+	return result, nil
+}
+
+// typeCounts reads the counts of a graph's node ("n") or edge ("e") types.
+// A graph whose counts have not been backfilled yet, as in a database
+// opened offline, is counted from its records instead.
+func (e *BadgerEngine) typeCounts(graphID models.GraphID, entityType string) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := e.db.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get(utils.EncodeTypeCountMarkerKey(graphID)); errors.Is(err, badger.ErrKeyNotFound) {
+			var err error
+			counts, err = scanTypeCounts(txn, graphID, entityType)
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		prefix := utils.CreateTypeCountIteratorPrefix(graphID, entityType)
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			typeName := string(it.Item().Key()[len(prefix):])
+			if strings.Contains(typeName, ":") {
+				// A count of a graph whose ID extends this one
+				continue
+			}
+			err := it.Item().Value(func(value []byte) error {
+				if count := decodeTypeCount(value); count > 0 {
+					counts[typeName] = int(count)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return counts, err
+}
+
+// scanTypeCounts counts the node ("n") or edge ("e") types of a graph from
+// its records
+func scanTypeCounts(txn *badger.Txn, graphID models.GraphID, entityType string) (map[string]int, error) {
+	prefix := utils.CreateNodeIteratorPrefix(graphID)
+	if entityType == "e" {
+		prefix = utils.CreateEdgeIteratorPrefix(graphID)
+	}
+
+	counts := make(map[string]int)
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().Key()
+		err := it.Item().Value(func(value []byte) error {
+			if entityType == "e" {
+				edge := &models.Edge{}
+				if err := edge.FromJSON(value); err != nil {
+					return fmt.Errorf("failed to deserialize edge: %w", err)
+				}
+				// Skip the edges of a graph whose ID extends this one
+				if bytes.Equal(key, utils.EncodeEdgeKey(graphID, edge.ID)) {
+					counts[string(edge.Type)]++
+				}
+				return nil
+			}
+			node := &models.Node{}
+			if err := node.FromJSON(value); err != nil {
+				return fmt.Errorf("failed to deserialize node: %w", err)
+			}
+			if bytes.Equal(key, utils.EncodeNodeKey(graphID, node.ID)) {
+				counts[string(node.Type)]++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// backfillTypeCounts counts the nodes and edges of the graphs that have no
+// type counts yet, such as graphs created before the counts were kept, or
+// of every graph with all set, as after a restore loaded records over the
+// counts. It returns how many graphs it counted.
+func (e *BadgerEngine) backfillTypeCounts(all bool) int {
+	graphs, err := e.ListGraphs()
+	if err != nil {
+		e.logger.Warn("Failed to list graphs to count their types", "error", err)
+		return 0
+	}
+
+	backfilled := 0
+	for _, graph := range graphs {
+		done, err := e.backfillGraphTypeCounts(graph.ID, all)
+		if err != nil {
+			e.logger.Warn("Failed to count graph types", "graph", graph.ID, "error", err)
+			continue
+		}
+		if done {
+			backfilled++
+		}
+	}
+	if backfilled > 0 {
+		e.logger.Info("Counted node and edge types", "graphs", backfilled)
+	}
+	return backfilled
+}
+
+// backfillGraphTypeCounts replaces the type counts of a graph with counts
+// of its records and marks them complete, unless they already are and
+// force is false. The counts are locked throughout, so a write committing
+// meanwhile is either counted from its records or added to the new counts.
+func (e *BadgerEngine) backfillGraphTypeCounts(graphID models.GraphID, force bool) (bool, error) {
+	unlock := e.typeCountLocks.lock([]models.GraphID{graphID})
+	defer unlock()
+
+	marker := utils.EncodeTypeCountMarkerKey(graphID)
+	counts := make(map[string]map[string]int)
+	var stale [][]byte
+	err := e.db.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get(marker); err == nil && !force {
+			return nil
+		} else if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		for _, entityType := range []string{"n", "e"} {
+			scanned, err := scanTypeCounts(txn, graphID, entityType)
+			if err != nil {
+				return err
+			}
+			counts[entityType] = scanned
+
+			prefix := utils.CreateTypeCountIteratorPrefix(graphID, entityType)
+			it := keysOnly.iterator(txn, prefix)
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				if !strings.Contains(string(it.Item().Key()[len(prefix):]), ":") {
+					stale = append(stale, it.Item().KeyCopy(nil))
+				}
+			}
+			it.Close()
+		}
+		return nil
+	})
+	if err != nil || len(counts) == 0 {
+		return false, err
+	}
+
+	err = e.db.Update(func(txn *badger.Txn) error {
+		for _, key := range stale {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		for typeName, count := range counts["n"] {
+			if err := txn.Set(utils.EncodeNodeTypeCountKey(graphID, models.NodeType(typeName)), encodeTypeCount(int64(count))); err != nil {
+				return err
+			}
+		}
+		for typeName, count := range counts["e"] {
+			if err := txn.Set(utils.EncodeEdgeTypeCountKey(graphID, models.EdgeType(typeName)), encodeTypeCount(int64(count))); err != nil {
+				return err
+			}
+		}
+		return markTypeCountsComplete(txn, graphID)
+	})
+	return err == nil, err
+}
@@ -1,7 +1,11 @@
 package storage
 
 import (
+	"io"
+	"time"
+
 	"github.com/ywadi/PathwayDB/models"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // StorageEngine defines the interface for the storage layer
@@ -10,26 +14,52 @@ type StorageEngine interface {
 	CreateGraph(graph *models.Graph) error
 	GetGraph(graphID models.GraphID) (*models.Graph, error)
 	UpdateGraph(graph *models.Graph) error
-	DeleteGraph(graphID models.GraphID) error
+	DeleteGraph(graphID models.GraphID) (int, error)
+	RenameGraph(oldID, newID models.GraphID) (int, error)
+	AddEventListener(listener EventListener) func()
 	ListGraphs() ([]*models.Graph, error)
 	CountNodes(graphID models.GraphID) (int, error)
 	CountEdges(graphID models.GraphID) (int, error)
+	CountNodesByType(graphID models.GraphID) (map[models.NodeType]int, error)
+	CountEdgesByType(graphID models.GraphID) (map[models.EdgeType]int, error)
+	CountOrphanNodes(graphID models.GraphID) (int, error)
+	CountGraphKeys(graphID models.GraphID) (int, error)
 
 	// Node operations
 	CreateNode(graphID models.GraphID, node *models.Node) error
+	CreateNodes(graphID models.GraphID, nodes []*models.Node) error
 	GetNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, error)
+	GetNodes(graphID models.GraphID, nodeIDs []models.NodeID) ([]*models.Node, error)
 	UpdateNode(graphID models.GraphID, node *models.Node) error
+	UpdateNodeWithResult(graphID models.GraphID, node *models.Node) (bool, error)
 	DeleteNode(graphID models.GraphID, nodeID models.NodeID) error
 	ListNodes(graphID models.GraphID) ([]*models.Node, error)
+	ScanNodes(graphID models.GraphID, fn func(node *models.Node) error) error
+	ListNodesPage(graphID models.GraphID, after models.NodeID, limit int) ([]*models.Node, bool, error)
 	ListNodesByType(graphID models.GraphID, nodeType models.NodeType) ([]*models.Node, error)
+	RenameNodeType(graphID models.GraphID, oldType, newType models.NodeType) (int, error)
+
+	// Node aliases
+	AddNodeAlias(graphID models.GraphID, nodeID models.NodeID, alias string) error
+	RemoveNodeAlias(graphID models.GraphID, nodeID models.NodeID, alias string) (bool, error)
+	ListNodeAliases(graphID models.GraphID, nodeID models.NodeID) ([]string, error)
+	ResolveNodeID(graphID models.GraphID, id models.NodeID) (models.NodeID, error)
 
 	// Edge operations
 	CreateEdge(graphID models.GraphID, edge *models.Edge) error
+	CreateEdges(graphID models.GraphID, edges []*models.Edge) error
 	GetEdge(graphID models.GraphID, edgeID models.EdgeID) (*models.Edge, error)
+	GetEdges(graphID models.GraphID, edgeIDs []models.EdgeID) ([]*models.Edge, error)
 	UpdateEdge(graphID models.GraphID, edge *models.Edge) error
+	UpdateEdgeWithResult(graphID models.GraphID, edge *models.Edge) (bool, error)
 	DeleteEdge(graphID models.GraphID, edgeID models.EdgeID) error
 	ListEdges(graphID models.GraphID) ([]*models.Edge, error)
+	ScanEdges(graphID models.GraphID, fn func(edge *models.Edge) error) error
+	ListEdgesPage(graphID models.GraphID, after models.EdgeID, limit int) ([]*models.Edge, bool, error)
 	ListEdgesByType(graphID models.GraphID, edgeType models.EdgeType) ([]*models.Edge, error)
+	RenameEdgeType(graphID models.GraphID, oldType, newType models.EdgeType) (int, error)
+	ListSelfLoops(graphID models.GraphID) ([]*models.Edge, error)
+	DeleteSelfLoops(graphID models.GraphID) ([]*models.Edge, error)
 
 	// Relationship operations
 	GetOutgoingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error)
@@ -39,11 +69,84 @@ type StorageEngine interface {
 	// Attribute filtering
 	FindNodesByAttribute(graphID models.GraphID, attrKey string, attrValue interface{}) ([]*models.Node, error)
 	FindEdgesByAttribute(graphID models.GraphID, attrKey string, attrValue interface{}) ([]*models.Edge, error)
+	FilterEdges(graphID models.GraphID, filter EdgeFilter) ([]*models.Edge, error)
+
+	// Named queries
+	SaveQuery(query *models.NamedQuery) error
+	GetQuery(name string) (*models.NamedQuery, error)
+	ListQueries() ([]*models.NamedQuery, error)
+	DeleteQuery(name string) error
+
+	// Snapshots
+	CreateSnapshot(graphID models.GraphID, label string) (*models.Snapshot, error)
+	ListSnapshots(graphID models.GraphID) ([]*models.Snapshot, error)
+	ReadSnapshot(graphID models.GraphID, snapshotID string) (*models.SnapshotData, error)
+	DeleteSnapshot(graphID models.GraphID, snapshotID string) error
+
+	// Export and import
+	ExportGraph(graphID models.GraphID, w io.Writer, withMeta bool) error
+	ExportGraphSince(graphID models.GraphID, since time.Time, w io.Writer) error
+	ImportGraph(graphID models.GraphID, r io.ReadSeeker) (int, int, error)
+	MergeGraph(dst, src models.GraphID, policy MergePolicy) (*MergeResult, error)
+
+	// Graph metadata
+	SetMeta(graphID models.GraphID, entry *models.MetaEntry) error
+	GetMeta(graphID models.GraphID, namespace string, key string) (*models.MetaEntry, error)
+	DeleteMeta(graphID models.GraphID, namespace string, key string) (bool, error)
+	ListMeta(graphID models.GraphID, namespace string) ([]*models.MetaEntry, error)
+	ScanMeta(graphID models.GraphID, fn func(entry *models.MetaEntry) error) error
+
+	// Change tracking
+	Generation(graphID models.GraphID) uint64
+	CommittedGeneration(graphID models.GraphID) (uint64, error)
+
+	// Transactions
+	RunTransaction(fn TransactionFunc) error
+
+	// Maintenance
+	PruneGraph(graphID models.GraphID, preview bool) (*models.MaintenanceRun, error)
+	GetMaintenanceRun(graphID models.GraphID) (*models.MaintenanceRun, error)
+
+	// Read statistics
+	SetReadTracking(enabled bool)
+	HotNodes(graphID models.GraphID, limit int) ([]NodeReads, error)
+	ResetReads() error
+
+	// Mutation activity
+	RecordActivity(graphID models.GraphID, kind ActivityKind, n int)
+	Activity(graphID models.GraphID, hours int) ([]ActivityBucket, error)
+
+	// Stats history
+	RecordStatsSnapshot(graphID models.GraphID, snapshot *models.StatsSnapshot, retainDays int) error
+	StatsHistory(graphID models.GraphID, days int) ([]*models.StatsSnapshot, error)
+
+	// Record cache
+	CacheStats() CacheStats
+	ClearCache()
+
+	// Record compression
+	CompressionStats() CompressionStats
+
+	// Tracing
+	SetTracing(provider trace.TracerProvider, rate float64)
+
+	// Diagnostics
+	AuditKeys(prefix string) (*KeyAudit, error)
+	Repair() (*RepairReport, error)
+	ValidateAttributeKeys(graphID models.GraphID) (*AttributeKeyReport, error)
+
+	// Index backfill
+	StartReindex(graphID models.GraphID, index string, rate int) (*models.ReindexJob, error)
+	ReindexStatus(graphID models.GraphID, index string) (*models.ReindexJob, error)
+	PauseReindex(graphID models.GraphID, index string) (*models.ReindexJob, error)
+	ResumeReindex(graphID models.GraphID, index string) (*models.ReindexJob, error)
+	CancelReindex(graphID models.GraphID, index string) (*models.ReindexJob, error)
 
 	// Database lifecycle
 	Open(path string) error
 	Close() error
 	Backup(path string) error
+	Restore(backupFile string) error
 }
 
 // FilterOptions represents options for filtering nodes/edges
@@ -64,14 +167,19 @@ type Transaction interface {
 	CreateNode(graphID models.GraphID, node *models.Node) error
 	GetNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, error)
 	UpdateNode(graphID models.GraphID, node *models.Node) error
+	UpdateNodeWithResult(graphID models.GraphID, node *models.Node) (bool, error)
 	DeleteNode(graphID models.GraphID, nodeID models.NodeID) error
 
 	// Edge operations within transaction
 	CreateEdge(graphID models.GraphID, edge *models.Edge) error
 	GetEdge(graphID models.GraphID, edgeID models.EdgeID) (*models.Edge, error)
 	UpdateEdge(graphID models.GraphID, edge *models.Edge) error
+	UpdateEdgeWithResult(graphID models.GraphID, edge *models.Edge) (bool, error)
 	DeleteEdge(graphID models.GraphID, edgeID models.EdgeID) error
 
+	// Optimistic concurrency
+	RequireGeneration(graphID models.GraphID, generation uint64) error
+
 	// Transaction control
 	Commit() error
 	Discard()
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestGraphActivity tests hourly mutation counts and GRAPH.ACTIVITY
+func TestGraphActivity(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_activity_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)}
+	engine := storage.NewBadgerEngine(storage.WithClock(clock.Now))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { engine.Close() }()
+	handler := redis.NewCommandHandler(engine)
+
+	run := func(t *testing.T, args ...string) {
+		t.Helper()
+		if _, err := handler.Handle(args[0], args[1:]); err != nil {
+			t.Fatalf("%s failed: %v", strings.Join(args, " "), err)
+		}
+	}
+	// activity returns the buckets of GRAPH.ACTIVITY as rows of strings
+	activity := func(t *testing.T, args ...string) [][]string {
+		t.Helper()
+		resp, err := handler.Handle("GRAPH.ACTIVITY", append([]string{"services"}, args...))
+		if err != nil {
+			t.Fatalf("GRAPH.ACTIVITY failed: %v", err)
+		}
+		rows := make([][]string, len(resp.NestedArrayValue))
+		for i, row := range resp.NestedArrayValue {
+			rows[i] = row.([]string)
+		}
+		return rows
+	}
+
+	run(t, "GRAPH.CREATE", "services")
+
+	// 12:30: two nodes and an edge are created
+	run(t, "NODE.CREATE", "services", "checkout", "service")
+	run(t, "NODE.CREATE", "services", "payments", "service")
+	run(t, "EDGE.CREATE", "services", "calls", "checkout", "payments", "calls")
+
+	// 13:59:59: a node and an edge are updated, and two nodes retyped
+	clock.Advance(89*time.Minute + 59*time.Second)
+	run(t, "NODE.UPDATE", "services", "checkout", "ATTRIBUTES", `{"tier":"1"}`)
+	run(t, "EDGE.UPDATE", "services", "calls", `{"weight":2}`)
+	run(t, "NODE.RETYPE", "services", "service", "microservice")
+
+	// 14:00:00: the edge and a node are deleted
+	clock.Advance(time.Second)
+	run(t, "EDGE.DELETE", "services", "calls")
+	run(t, "NODE.DELETE", "services", "payments")
+
+	expected := [][]string{
+		{"2024-01-01T12:00:00Z", "2", "0", "0", "1", "0", "0"},
+		{"2024-01-01T13:00:00Z", "0", "3", "0", "0", "1", "0"},
+		{"2024-01-01T14:00:00Z", "0", "0", "1", "0", "0", "1"},
+	}
+
+	t.Run("Buckets", func(t *testing.T) {
+		if rows := activity(t, "HOURS", "3"); !reflect.DeepEqual(rows, expected) {
+			t.Errorf("Expected %v, got %v", expected, rows)
+		}
+		rows := activity(t)
+		if len(rows) != storage.ActivityHours {
+			t.Fatalf("Expected %d buckets by default, got %d", storage.ActivityHours, len(rows))
+		}
+		if !reflect.DeepEqual(rows[len(rows)-3:], expected) || rows[0][0] != "2023-12-25T15:00:00Z" {
+			t.Errorf("Expected the week up to 14:00, oldest first, got %v ... %v", rows[0], rows[len(rows)-3:])
+		}
+	})
+
+	t.Run("Reads Not Counted", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"NODE.GET", "services", "checkout"},
+			{"NODE.LIST", "services"},
+			{"NODE.EXISTS", "services", "checkout"},
+			{"EDGE.LIST", "services"},
+			{"GRAPH.GET", "services"},
+			{"ANALYSIS.TRAVERSE", "services", "checkout"},
+		} {
+			run(t, args...)
+		}
+		// Failed writes are not counted either
+		if _, err := handler.Handle("NODE.UPDATE", []string{"services", "missing", "ATTRIBUTES", "{}"}); err == nil {
+			t.Fatal("Expected updating a missing node to fail")
+		}
+		if rows := activity(t, "HOURS", "3"); !reflect.DeepEqual(rows, expected) {
+			t.Errorf("Expected reads to leave the counts unchanged, got %v", rows)
+		}
+	})
+
+	t.Run("Persisted", func(t *testing.T) {
+		if err := engine.Close(); err != nil {
+			t.Fatalf("Failed to close: %v", err)
+		}
+		engine = storage.NewBadgerEngine(storage.WithClock(clock.Now))
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen: %v", err)
+		}
+		handler = redis.NewCommandHandler(engine)
+		if rows := activity(t, "HOURS", "3"); !reflect.DeepEqual(rows, expected) {
+			t.Errorf("Expected the counts to survive a reopen, got %v", rows)
+		}
+	})
+
+	t.Run("Week Rollover", func(t *testing.T) {
+		// A week after 14:00 its slot is reused for the new hour
+		clock.Advance(storage.ActivityHours * time.Hour)
+		run(t, "NODE.CREATE", "services", "ledger", "service")
+		rows := activity(t)
+		if rows[0][0] != "2024-01-01T15:00:00Z" {
+			t.Errorf("Expected the window to start the hour after 14:00, got %v", rows[0])
+		}
+		for _, row := range rows[:len(rows)-1] {
+			if !reflect.DeepEqual(row[1:], []string{"0", "0", "0", "0", "0", "0"}) {
+				t.Errorf("Expected the last week to be empty, got %v", row)
+			}
+		}
+		if last := rows[len(rows)-1]; !reflect.DeepEqual(last, []string{"2024-01-08T14:00:00Z", "1", "0", "0", "0", "0", "0"}) {
+			t.Errorf("Expected only the new node in the current hour, got %v", last)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"services", "HOURS"},
+			{"services", "HOURS", "0"},
+			{"services", "HOURS", "169"},
+			{"services", "DAYS", "1"},
+			{"missing"},
+		} {
+			if _, err := handler.Handle("GRAPH.ACTIVITY", args); err == nil {
+				t.Errorf("Expected GRAPH.ACTIVITY %v to fail", args)
+			}
+		}
+	})
+
+	t.Run("Deletion", func(t *testing.T) {
+		if err := engine.Close(); err != nil {
+			t.Fatalf("Failed to close: %v", err)
+		}
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen: %v", err)
+		}
+		audit, err := engine.AuditKeys(utils.ActivityPrefix)
+		if err != nil || audit.Families["act"] != 1 {
+			t.Fatalf("Expected the graph's activity key, got %v, %v", audit, err)
+		}
+		run(t, "GRAPH.DELETE", "services")
+		if audit, err = engine.AuditKeys(utils.ActivityPrefix); err != nil || audit.Families["act"] != 0 {
+			t.Errorf("Expected GRAPH.DELETE to remove the activity key, got %v, %v", audit, err)
+		}
+	})
+}
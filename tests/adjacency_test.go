@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestGraphAdjacency tests GRAPH.ADJACENCY against EDGE.NEIGHBORS, its
+// filters and its pagination
+func TestGraphAdjacency(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_adjacency_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("services")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "services"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "api", Type: "service"},
+		{ID: "auth", Type: "service"},
+		{ID: "billing", Type: "service"},
+		{ID: "db", Type: "database"},
+		{ID: "queue", Type: "broker"},
+		{ID: "standalone", Type: "service"},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "api-auth", Type: "calls", FromNodeID: "api", ToNodeID: "auth"},
+		{ID: "api-billing", Type: "calls", FromNodeID: "api", ToNodeID: "billing"},
+		{ID: "auth-api", Type: "calls", FromNodeID: "auth", ToNodeID: "api"},
+		{ID: "auth-db", Type: "reads", FromNodeID: "auth", ToNodeID: "db"},
+		{ID: "billing-db", Type: "reads", FromNodeID: "billing", ToNodeID: "db"},
+		{ID: "billing-db-2", Type: "reads", FromNodeID: "billing", ToNodeID: "db"},
+		{ID: "billing-queue", Type: "publishes", FromNodeID: "billing", ToNodeID: "queue"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	adjacency := func(t *testing.T, args ...string) [][]string {
+		t.Helper()
+		resp, err := handler.Handle("GRAPH.ADJACENCY", args)
+		if err != nil {
+			t.Fatalf("GRAPH.ADJACENCY %s failed: %v", strings.Join(args, " "), err)
+		}
+		rows := make([][]string, len(resp.NestedArrayValue))
+		for i, value := range resp.NestedArrayValue {
+			row, ok := value.([]string)
+			if !ok {
+				t.Fatalf("Expected arrays of strings, got %#v", value)
+			}
+			rows[i] = row
+		}
+		return rows
+	}
+	// sorted returns the neighbor entries of a row in a stable order
+	sorted := func(entries []string) []string {
+		entries = append([]string(nil), entries...)
+		sort.Strings(entries)
+		return entries
+	}
+
+	full := adjacency(t, "services")
+
+	t.Run("Matches Neighbors", func(t *testing.T) {
+		var listed []string
+		for _, row := range full {
+			listed = append(listed, row[0])
+			nodeID, _, _ := strings.Cut(row[0], ":")
+
+			// Rewrite the detailed EDGE.NEIGHBORS replies, such as
+			// "auth:service<-api-auth:calls", as adjacency entries
+			resp, err := handler.Handle("EDGE.NEIGHBORS", []string{"services", nodeID})
+			if err != nil {
+				t.Fatalf("EDGE.NEIGHBORS %s failed: %v", nodeID, err)
+			}
+			var expected []string
+			for _, neighbor := range resp.ArrayValue {
+				direction, arrow := "out", "->"
+				if strings.Contains(neighbor, "<-") {
+					direction, arrow = "in", "<-"
+				}
+				node, edge, _ := strings.Cut(neighbor, arrow)
+				neighborID, _, _ := strings.Cut(node, ":")
+				edgeType := edge[strings.LastIndex(edge, ":")+1:]
+				expected = append(expected, direction+":"+edgeType+":"+neighborID)
+			}
+			if got := sorted(row[1:]); !reflect.DeepEqual(got, sorted(expected)) {
+				t.Errorf("Expected %s to have neighbors %v, got %v", nodeID, sorted(expected), got)
+			}
+		}
+
+		expected := []string{"api:service", "auth:service", "billing:service", "db:database", "queue:broker", "standalone:service"}
+		if !reflect.DeepEqual(listed, expected) {
+			t.Errorf("Expected every node in ID order %v, got %v", expected, listed)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		// Only edges between services are listed
+		rows := adjacency(t, "services", "NODETYPES", "service")
+		expected := [][]string{
+			{"api:service", "in:calls:auth", "out:calls:auth", "out:calls:billing"},
+			{"auth:service", "in:calls:api", "out:calls:api"},
+			{"billing:service", "in:calls:api"},
+			{"standalone:service"},
+		}
+		for i := range rows {
+			rows[i] = append(rows[i][:1], sorted(rows[i][1:])...)
+		}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("Expected %v, got %v", expected, rows)
+		}
+
+		rows = adjacency(t, "services", "EDGETYPES", "reads", "publishes", "NODETYPES", "database", "broker", "service")
+		if len(rows) != 6 || !reflect.DeepEqual(rows[2], []string{"billing:service", "out:reads:db", "out:reads:db", "out:publishes:queue"}) {
+			t.Errorf("Expected billing's parallel reads and its publish, got %v", rows)
+		}
+		if !reflect.DeepEqual(rows[0], []string{"api:service"}) {
+			t.Errorf("Expected api to have no reads or publishes, got %v", rows[0])
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		for _, count := range []int{1, 2, 4, 6, 10} {
+			var rows [][]string
+			cursor := "0"
+			for pages := 0; ; pages++ {
+				if pages > len(full) {
+					t.Fatalf("COUNT %d: too many pages", count)
+				}
+				page := adjacency(t, "services", "CURSOR", cursor, "COUNT", strconv.Itoa(count))
+				if len(page) == 0 || len(page[0]) != 1 {
+					t.Fatalf("COUNT %d: expected the reply to start with the cursor, got %v", count, page)
+				}
+				if len(page)-1 > count {
+					t.Errorf("COUNT %d: got %d nodes", count, len(page)-1)
+				}
+				rows = append(rows, page[1:]...)
+				if cursor = page[0][0]; cursor == "0" {
+					break
+				}
+			}
+			// Every node's row is whole and on exactly one page
+			if !reflect.DeepEqual(rows, full) {
+				t.Errorf("COUNT %d: expected the pages to add up to %v, got %v", count, full, rows)
+			}
+		}
+
+		// The default page holds every node of this graph
+		if rows := adjacency(t, "services", "CURSOR", "0"); len(rows) != 7 || rows[0][0] != "0" {
+			t.Errorf("Expected one page of 6 nodes, got %v", rows)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"missing"},
+			{"services", "COUNT", "5"},
+			{"services", "CURSOR", "api"},
+			{"services", "CURSOR", "0", "COUNT", "0"},
+			{"services", "CURSOR"},
+			{"services", "VERBOSE"},
+		} {
+			if _, err := handler.Handle("GRAPH.ADJACENCY", args); err == nil {
+				t.Errorf("Expected GRAPH.ADJACENCY %v to be rejected", args)
+			}
+		}
+	})
+}
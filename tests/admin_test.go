@@ -0,0 +1,326 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/admin"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestAdminSubcommands tests the offline subcommands of the server binary
+// against a data directory no server has open
+func TestAdminSubcommands(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_admin_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	dataDir := filepath.Join(testPath, "data")
+	if err := os.MkdirAll(testPath, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(dataDir); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, graphID := range []models.GraphID{"infra", "web"} {
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+	}
+	for _, node := range []*models.Node{{ID: "api", Type: "service"}, {ID: "db", Type: "database"}, {ID: "cache", Type: "cache"}} {
+		if err := engine.CreateNode("infra", node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	if err := engine.CreateNode("web", &models.Node{ID: "api", Type: "service"}); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "reads", Type: "reads", FromNodeID: "api", ToNodeID: "db"},
+		{ID: "caches", Type: "caches", FromNodeID: "api", ToNodeID: "cache"},
+	} {
+		if err := engine.CreateEdge("infra", edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+	if err := engine.SetMeta("infra", &models.MetaEntry{Namespace: "ci", Key: "owner", Value: json.RawMessage(`"platform"`)}); err != nil {
+		t.Fatalf("Failed to set metadata: %v", err)
+	}
+	engine.Close()
+
+	// run runs a subcommand and returns its exit code and output
+	run := func(t *testing.T, args ...string) (int, string, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := admin.Run(args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	t.Run("Usage", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"mystery", dataDir},
+			{"inspect"},
+			{"inspect", dataDir, "extra"},
+			{"export", dataDir, "infra"},
+			{"fsck", "--fix", dataDir},
+			{"inspect", "-log-level", "loud", dataDir},
+		} {
+			if code, _, stderr := run(t, args...); code != admin.ExitUsage || stderr == "" {
+				t.Errorf("Expected %v to exit %d with usage, got %d: %q", args, admin.ExitUsage, code, stderr)
+			}
+		}
+		if code, _, stderr := run(t, "fsck", "-h"); code != admin.ExitOK || !strings.Contains(stderr, "-repair") {
+			t.Errorf("Expected -h to print the flags and exit 0, got %d: %q", code, stderr)
+		}
+	})
+
+	t.Run("Inspect", func(t *testing.T) {
+		code, stdout, stderr := run(t, "inspect", dataDir)
+		if code != admin.ExitOK {
+			t.Fatalf("Expected inspect to succeed, got %d: %s", code, stderr)
+		}
+		lines := strings.Split(stdout, "\n")
+		if fields := strings.Fields(lines[1]); strings.Join(fields[:3], " ") != "infra 3 2" {
+			t.Errorf("Expected infra with 3 nodes and 2 edges, got %q", lines[1])
+		}
+		if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "web 1 0 7" {
+			t.Errorf("Expected web with 1 node and 7 keys, got %q", lines[2])
+		}
+		for _, row := range []string{"FAMILY KEYS", "n 4", "e 2", "ti:n 4", "ni:out 2", "m 1"} {
+			if !strings.Contains(strings.Join(strings.Fields(stdout), " "), row) {
+				t.Errorf("Expected the family histogram to include %q, got:\n%s", row, stdout)
+			}
+		}
+		if !strings.Contains(stdout, "2 graphs,") || !strings.Contains(stdout, "(0 unknown, 0 orphaned)") {
+			t.Errorf("Expected the totals, got:\n%s", stdout)
+		}
+
+		// A missing data directory is not created
+		missing := filepath.Join(testPath, "missing")
+		if code, _, _ := run(t, "inspect", missing); code != admin.ExitFailure {
+			t.Errorf("Expected inspecting a missing directory to fail, got %d", code)
+		}
+		if _, err := os.Stat(missing); !os.IsNotExist(err) {
+			t.Error("Expected inspect not to create the data directory")
+		}
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		out := filepath.Join(testPath, "infra.json")
+		if code, _, stderr := run(t, "export", "-meta", dataDir, "infra", out); code != admin.ExitOK {
+			t.Fatalf("Expected export to succeed, got %d: %s", code, stderr)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("Failed to read export: %v", err)
+		}
+		var doc struct {
+			Graph models.Graph      `json:"graph"`
+			Nodes []models.Node     `json:"nodes"`
+			Edges []models.Edge     `json:"edges"`
+			Meta  []json.RawMessage `json:"meta"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("Failed to parse export: %v", err)
+		}
+		if doc.Graph.ID != "infra" || len(doc.Nodes) != 3 || len(doc.Edges) != 2 || len(doc.Meta) != 1 {
+			t.Errorf("Expected the graph with 3 nodes, 2 edges and its metadata, got %+v", doc)
+		}
+
+		// Flags may follow the arguments, and "-" writes to stdout
+		code, stdout, _ := run(t, "export", dataDir, "infra", "-")
+		if code != admin.ExitOK || !json.Valid([]byte(stdout)) || strings.Contains(stdout, `"meta"`) {
+			t.Errorf("Expected the export on stdout without metadata, got %d: %q", code, stdout)
+		}
+
+		missing := filepath.Join(testPath, "missing.json")
+		if code, _, stderr := run(t, "export", dataDir, "missing", missing); code != admin.ExitFailure || !strings.Contains(stderr, "not found") {
+			t.Errorf("Expected exporting a missing graph to fail, got %d: %q", code, stderr)
+		}
+		if _, err := os.Stat(missing); !os.IsNotExist(err) {
+			t.Error("Expected a failed export not to leave a file")
+		}
+	})
+
+	t.Run("Backup And Restore", func(t *testing.T) {
+		backupFile := filepath.Join(testPath, "nightly.db")
+		code, stdout, stderr := run(t, "backup", dataDir, backupFile)
+		if code != admin.ExitOK || !strings.Contains(stdout, "of 2 graphs to") {
+			t.Fatalf("Expected backup to succeed, got %d: %s%s", code, stdout, stderr)
+		}
+		manifest, err := storage.ReadBackupManifest(backupFile)
+		if err != nil || len(manifest.Graphs) != 2 {
+			t.Fatalf("Expected a manifest-wrapped backup of 2 graphs, got %+v, %v", manifest, err)
+		}
+
+		restored := filepath.Join(testPath, "restored")
+		if code, _, stderr := run(t, "restore", restored, backupFile); code != admin.ExitOK {
+			t.Fatalf("Expected restore to succeed, got %d: %s", code, stderr)
+		}
+		_, original, _ := run(t, "inspect", dataDir)
+		_, copied, _ := run(t, "inspect", restored)
+		if original != copied {
+			t.Errorf("Expected the restored directory to match the original:\n%s\n%s", original, copied)
+		}
+
+		// A damaged backup is rejected before the directory is opened
+		data, err := os.ReadFile(backupFile)
+		if err != nil {
+			t.Fatalf("Failed to read backup: %v", err)
+		}
+		data[len(data)-1] ^= 0xff
+		damaged := filepath.Join(testPath, "damaged.db")
+		if err := os.WriteFile(damaged, data, 0644); err != nil {
+			t.Fatalf("Failed to write damaged backup: %v", err)
+		}
+		untouched := filepath.Join(testPath, "untouched")
+		if code, _, _ := run(t, "restore", untouched, damaged); code != admin.ExitFailure {
+			t.Errorf("Expected restoring a damaged backup to fail, got %d", code)
+		}
+		if _, err := os.Stat(untouched); !os.IsNotExist(err) {
+			t.Error("Expected a damaged backup not to create the data directory")
+		}
+	})
+
+	t.Run("Fsck", func(t *testing.T) {
+		code, stdout, _ := run(t, "fsck", dataDir)
+		if code != admin.ExitOK || !strings.Contains(stdout, "no problems found") {
+			t.Fatalf("Expected a clean fsck, got %d:\n%s", code, stdout)
+		}
+
+		// Break the keyspace: keys of a graph with no record, a node
+		// missing from the type index, a stale endpoint index entry and
+		// an interrupted deletion
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(dataDir); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		if err := engine.CreateGraph(&models.Graph{ID: "old", Name: "old"}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		if err := engine.CreateNode("old", &models.Node{ID: "legacy", Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		engine.Close()
+
+		db, err := badger.Open(badger.DefaultOptions(dataDir).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open raw database: %v", err)
+		}
+		err = db.Update(func(txn *badger.Txn) error {
+			for key, value := range map[string]string{
+				"n:gone:api":             `{"id":"api","type":"service"}`,
+				"ti:n:gone:service:api":  "api",
+				"ni:out:infra:api:ghost": "ghost",
+				"gd:old":                 `{"started_at":"2024-06-01T00:00:00Z","keys_removed":0}`,
+				"legacy:infra:api":       "x",
+			} {
+				if err := txn.Set([]byte(key), []byte(value)); err != nil {
+					return err
+				}
+			}
+			return txn.Delete([]byte("ti:n:infra:database:db"))
+		})
+		if err != nil {
+			t.Fatalf("Failed to seed raw keys: %v", err)
+		}
+		db.Close()
+
+		// Without --repair, the problems are reported and left alone
+		for i := 0; i < 2; i++ {
+			code, stdout, _ = run(t, "fsck", dataDir)
+			if code != admin.ExitFailure || !strings.Contains(stdout, "5 problems found") {
+				t.Fatalf("Expected fsck to report 5 problems, got %d:\n%s", code, stdout)
+			}
+		}
+		for _, problem := range []string{
+			"problem: 1 interrupted graph deletions",
+			"problem: 1 n keys of graphs without a graph record",
+			"problem: 1 ti:n keys of graphs without a graph record",
+			"problem: graph infra has 3 n records but 2 ti:n index entries",
+			"problem: graph infra has 2 e records but 3 ni:out index entries",
+		} {
+			if !strings.Contains(stdout, problem) {
+				t.Errorf("Expected %q, got:\n%s", problem, stdout)
+			}
+		}
+		if !strings.Contains(stdout, "unknown keys: 1") {
+			t.Errorf("Expected the unknown key to be counted, got:\n%s", stdout)
+		}
+
+		code, stdout, stderr := run(t, "fsck", dataDir, "--repair")
+		if code != admin.ExitOK || !strings.HasSuffix(stdout, "no problems found\n") {
+			t.Fatalf("Expected --repair to fix every problem, got %d:\n%s%s", code, stdout, stderr)
+		}
+		for _, action := range []string{
+			"repair: resumed 1 graph deletions",
+			"repair: deleted 2 orphaned keys",
+			"repair: rebuilt ti:n index of graph infra",
+			"repair: rebuilt ni:out index of graph infra",
+		} {
+			if !strings.Contains(stdout, action) {
+				t.Errorf("Expected %q, got:\n%s", action, stdout)
+			}
+		}
+		if code, _, _ := run(t, "fsck", dataDir); code != admin.ExitOK {
+			t.Errorf("Expected fsck to pass after the repair, got %d", code)
+		}
+
+		// The repaired indexes answer queries again, and other graphs kept
+		// theirs
+		engine = storage.NewBadgerEngine()
+		if err := engine.Open(dataDir); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+		if nodes, err := engine.ListNodesByType("infra", "database"); err != nil || len(nodes) != 1 {
+			t.Errorf("Expected the type index to find db again, got %v, %v", nodes, err)
+		}
+		if edges, err := engine.GetOutgoingEdges("infra", "api"); err != nil || len(edges) != 2 {
+			t.Errorf("Expected api's 2 outgoing edges without the stale entry, got %v, %v", edges, err)
+		}
+		if _, err := engine.GetGraph("old"); err == nil {
+			t.Error("Expected the interrupted deletion to be finished")
+		}
+		if nodes, err := engine.ListNodesByType("web", "service"); err != nil || len(nodes) != 1 {
+			t.Errorf("Expected web to keep its index, got %v, %v", nodes, err)
+		}
+	})
+
+	t.Run("Generate", func(t *testing.T) {
+		generated := filepath.Join(testPath, "generated")
+		args := []string{"generate", "-shape", "scalefree", "-nodes", "100", "-degree", "2", "-seed", "7", "-node-types", "service,database", "-attributes", "2", generated, "load"}
+		code, stdout, stderr := run(t, args...)
+		if code != admin.ExitOK || !strings.Contains(stdout, "Generated graph load with 100 nodes and 196 edges") {
+			t.Fatalf("Expected generate to succeed, got %d: %s%s", code, stdout, stderr)
+		}
+		if code, _, stderr := run(t, args...); code != admin.ExitFailure || !strings.Contains(stderr, "already exists") {
+			t.Errorf("Expected generating into an existing graph to fail, got %d: %s", code, stderr)
+		}
+		if code, _, _ := run(t, "generate", "-shape", "ring", generated, "other"); code != admin.ExitUsage {
+			t.Errorf("Expected an unknown shape to exit %d, got %d", admin.ExitUsage, code)
+		}
+
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(generated); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+		services, err := engine.ListNodesByType("load", "service")
+		databases, err2 := engine.ListNodesByType("load", "database")
+		if err != nil || err2 != nil || len(services)+len(databases) != 100 || len(services) == 0 || len(databases) == 0 {
+			t.Errorf("Expected 100 nodes of both types, got %d and %d, %v, %v", len(services), len(databases), err, err2)
+		}
+		if node, err := engine.GetNode("load", "n000042"); err != nil || len(node.Attributes) != 2 {
+			t.Errorf("Expected n000042 with 2 attributes, got %v, %v", node, err)
+		}
+	})
+}
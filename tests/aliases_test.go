@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestCommandCaseAndAliases tests case-insensitive command names and
+// keywords, namespace aliases, and that user data keeps its case
+func TestCommandCaseAndAliases(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_aliases_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	handler := redis.NewCommandHandler(engine)
+
+	t.Run("Normalize", func(t *testing.T) {
+		tests := []struct {
+			command  string
+			expected string
+		}{
+			{"node.create", "NODE.CREATE"},
+			{"Analysis.Traverse", "ANALYSIS.TRAVERSE"},
+			{"n.create", "NODE.CREATE"},
+			{"A.TRAVERSE", "ANALYSIS.TRAVERSE"},
+			{"g.list", "GRAPH.LIST"},
+			{"e.Neighbors", "EDGE.NEIGHBORS"},
+			{"q.run", "QUERY.RUN"},
+			{"ping", "PING"},
+			{"N", "N"},
+			{"x.list", "X.LIST"},
+		}
+		for _, tt := range tests {
+			if got := commands.NormalizeCommand(tt.command); got != tt.expected {
+				t.Errorf("NormalizeCommand(%q) = %q, expected %q", tt.command, got, tt.expected)
+			}
+		}
+	})
+
+	// Build a small graph through mixed-case commands. IDs, types and
+	// JSON attributes are deliberately mixed-case too.
+	for _, step := range []struct {
+		command string
+		args    []string
+	}{
+		{"graph.create", []string{"MixedGraph"}},
+		{"Node.Create", []string{"MixedGraph", "Svc-A", "WebService", `{"Owner":"TeamX"}`, "ttl", "3600"}},
+		{"n.create", []string{"MixedGraph", "Svc-B", "DataBase"}},
+		{"N.CREATE", []string{"MixedGraph", "Svc-C", "WebService"}},
+		{"edge.create", []string{"MixedGraph", "Edge-AB", "Svc-A", "Svc-B", "ReadsFrom"}},
+		{"e.create", []string{"MixedGraph", "Edge-BC", "Svc-B", "Svc-C", "Notifies"}},
+		{"E.create", []string{"MixedGraph", "Edge-CA", "Svc-C", "Svc-A", "Calls"}},
+	} {
+		if _, err := handler.Handle(step.command, step.args); err != nil {
+			t.Fatalf("%s %v failed: %v", step.command, step.args, err)
+		}
+	}
+
+	t.Run("Commands", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			command  string
+			args     []string
+			expected []string
+			sorted   bool
+		}{
+			{
+				name:     "TraverseKeywords",
+				command:  "analysis.traverse",
+				args:     []string{"MixedGraph", "Svc-A", "direction", "OUT", "nodetypes", "DataBase", "format", "Simple"},
+				expected: []string{"Svc-B:DataBase"},
+			},
+			{
+				name:     "TraverseAlias",
+				command:  "a.Traverse",
+				args:     []string{"MixedGraph", "Svc-B", "Direction", "In", "Format", "SIMPLE"},
+				expected: []string{"Svc-A:WebService", "Svc-B:DataBase", "Svc-C:WebService"},
+				sorted:   true,
+			},
+			{
+				name:     "Neighbors",
+				command:  "edge.neighbors",
+				args:     []string{"MixedGraph", "Svc-A", "Out", "format", "simple"},
+				expected: []string{"Svc-B:DataBase"},
+			},
+			{
+				name:     "Cycles",
+				command:  "Analysis.Cycles",
+				args:     []string{"MixedGraph", "edgetypes", "ReadsFrom", "Notifies", "Calls", "format", "simple"},
+				expected: []string{"Svc-A:WebService", "Svc-B:DataBase", "Svc-C:WebService"},
+				sorted:   true,
+			},
+			{
+				name:     "SearchKeywords",
+				command:  "search.text",
+				args:     []string{"MixedGraph", "teamx", "limit", "1", "nodetypes", "WebService"},
+				expected: []string{"Svc-A:WebService:Owner"},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, err := handler.Handle(tt.command, tt.args)
+				if err != nil {
+					t.Fatalf("%s failed: %v", tt.command, err)
+				}
+				got := append([]string{}, resp.ArrayValue...)
+				if tt.sorted {
+					sort.Strings(got)
+				}
+				if !reflect.DeepEqual(got, tt.expected) {
+					t.Errorf("Expected %v, got %v", tt.expected, got)
+				}
+			})
+		}
+	})
+
+	t.Run("UserDataKeepsCase", func(t *testing.T) {
+		resp, err := handler.Handle("n.get", []string{"MixedGraph", "Svc-A"})
+		if err != nil || len(resp.ArrayValue) < 2 || resp.ArrayValue[0] != "Svc-A" || resp.ArrayValue[1] != "WebService" {
+			t.Fatalf("Expected N.GET to return the node as created, got %v, %v", resp, err)
+		}
+		node, err := engine.GetNode("MixedGraph", "Svc-A")
+		if err != nil {
+			t.Fatalf("Failed to get node: %v", err)
+		}
+		if node.Type != "WebService" || node.Attributes["Owner"] != "TeamX" {
+			t.Errorf("Expected type and attributes to keep their case, got %+v", node)
+		}
+		if _, err := engine.GetNode("MixedGraph", "svc-a"); err == nil {
+			t.Error("Expected node IDs to stay case-sensitive")
+		}
+		if _, err := engine.GetGraph("mixedgraph"); err == nil {
+			t.Error("Expected graph IDs to stay case-sensitive")
+		}
+	})
+
+	t.Run("SavedQueryAlias", func(t *testing.T) {
+		// The alias resolves before the read-only check, so no admin role
+		// is needed to save it
+		if _, err := handler.Handle("q.save", []string{"down", "a.traverse $1 $2 direction out format simple"}); err != nil {
+			t.Fatalf("QUERY.SAVE failed: %v", err)
+		}
+		resp, err := handler.Handle("Query.Run", []string{"down", "MixedGraph", "Svc-B"})
+		if err != nil {
+			t.Fatalf("QUERY.RUN failed: %v", err)
+		}
+		// The graph is a cycle, so the traversal comes back to Svc-B
+		got := append([]string{}, resp.ArrayValue...)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, []string{"Svc-A:WebService", "Svc-B:DataBase", "Svc-C:WebService"}) {
+			t.Errorf("Expected downstream of Svc-B, got %v", got)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		resp, err := handler.Handle("info", []string{"CommandStats"})
+		if err != nil {
+			t.Fatalf("INFO failed: %v", err)
+		}
+		if !strings.Contains(resp.StringValue, "cmdstat_node.create:calls=3,") {
+			t.Errorf("Expected aliased and mixed-case calls to share one entry, got %q", resp.StringValue)
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		if _, err := handler.Handle("x.list", nil); err == nil {
+			t.Error("Expected error for an unknown namespace")
+		}
+		if _, err := handler.Handle("n", nil); err == nil {
+			t.Error("Expected error for a bare alias")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/jobs"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestAnalysisLimits tests that costly analyses are refused on graphs above
+// their size thresholds unless forced
+func TestAnalysisLimits(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_analysis_limits_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	// 5 nodes and 20 edges, above every threshold, and 3 nodes and 6
+	// edges, below them
+	bigGraph := models.GraphID("limits-big")
+	smallGraph := models.GraphID("limits-small")
+	createCompleteGraph(t, engine, bigGraph, 5)
+	createCompleteGraph(t, engine, smallGraph, 3)
+
+	limits := commands.AnalysisLimits{
+		PathEnumeration:  commands.AnalysisLimit{MaxEdges: 10},
+		CycleEnumeration: commands.AnalysisLimit{MaxEdges: 10},
+		Clustering:       commands.AnalysisLimit{MaxNodes: 4},
+	}
+	handler := redis.NewCommandHandler(engine, redis.WithAnalysisLimits(limits))
+
+	t.Run("Refused", func(t *testing.T) {
+		for _, tc := range []struct {
+			command string
+			args    []string
+			size    string
+			advice  string
+		}{
+			{"ANALYSIS.TRAVERSE", []string{"limits-big", "n0"}, "has 20 edges, more than the 10 path enumeration", "FORMAT simple"},
+			{"ANALYSIS.CYCLES", []string{"limits-big", "EDGETYPE", "calls"}, "has 20 edges, more than the 10 cycle enumeration", "NODETYPE and EDGETYPE"},
+			{"ANALYSIS.CLUSTERING", []string{"limits-big"}, "has 5 nodes, more than the 4 clustering", ""},
+		} {
+			_, err := handler.Handle(tc.command, tc.args)
+			if !errors.Is(err, commands.ErrGraphTooLarge) {
+				t.Errorf("Expected %s to be refused, got %v", tc.command, err)
+				continue
+			}
+			message := err.Error()
+			if !strings.HasPrefix(message, "TOOLARGE graph limits-big ") || !strings.Contains(message, tc.size) {
+				t.Errorf("Expected %s to give the graph's size and the threshold, got %s", tc.command, message)
+			}
+			if !strings.Contains(message, tc.advice) || !strings.Contains(message, "ANALYSIS.SUBMIT") || !strings.Contains(message, "FORCE") {
+				t.Errorf("Expected %s to name the options that make it feasible, got %s", tc.command, message)
+			}
+		}
+	})
+
+	t.Run("Force", func(t *testing.T) {
+		for _, tc := range []struct {
+			command string
+			args    []string
+		}{
+			{"ANALYSIS.TRAVERSE", []string{"limits-big", "n0", "COUNT", "force"}},
+			{"ANALYSIS.CYCLES", []string{"limits-big", "COUNT", "FORCE"}},
+			{"ANALYSIS.CLUSTERING", []string{"limits-big", "FORCE"}},
+		} {
+			if _, err := handler.Handle(tc.command, tc.args); err != nil {
+				t.Errorf("Expected %s %v to run, got %v", tc.command, tc.args, err)
+			}
+		}
+
+		// FORCE before the last argument is not taken as the keyword
+		if _, err := handler.Handle("ANALYSIS.CYCLES", []string{"limits-big", "FORCE", "COUNT"}); err == nil {
+			t.Error("Expected FORCE to only be taken as the last argument")
+		}
+	})
+
+	t.Run("Not Guarded", func(t *testing.T) {
+		// Visiting each node once is not path enumeration
+		if _, err := handler.Handle("ANALYSIS.TRAVERSE", []string{"limits-big", "n0", "FORMAT", "simple"}); err != nil {
+			t.Errorf("Expected a simple traversal to run, got %v", err)
+		}
+		if _, err := handler.Handle("ANALYSIS.CYCLES", []string{"limits-small"}); err != nil {
+			t.Errorf("Expected cycles of a small graph to run, got %v", err)
+		}
+
+		// Submitted jobs are never refused
+		resp, err := handler.Handle("ANALYSIS.SUBMIT", []string{"CYCLES", string(bigGraph), "COUNT"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SUBMIT failed: %v", err)
+		}
+		waitForState(t, handler, resp.StringValue, jobs.StateDone)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		if !reflect.DeepEqual(redis.DefaultConfig().AnalysisLimits, commands.DefaultAnalysisLimits()) {
+			t.Errorf("Expected the server to default to DefaultAnalysisLimits, got %+v", redis.DefaultConfig().AnalysisLimits)
+		}
+		if _, err := redis.NewCommandHandler(engine).Handle("ANALYSIS.CYCLES", []string{"limits-big", "COUNT"}); err != nil {
+			t.Errorf("Expected the default thresholds to allow a small graph, got %v", err)
+		}
+	})
+
+	t.Run("Server", func(t *testing.T) {
+		config := redis.DefaultConfig()
+		config.AnalysisLimits.Clustering = commands.AnalysisLimit{MaxEdges: 10}
+		conn, err := net.Dial("tcp", startTestServer(t, engine, config))
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		if _, err := conn.Write([]byte(encodeCommand("ANALYSIS.CLUSTERING", "limits-big"))); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		reply, err := readReply(r)
+		if err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+		if !strings.HasPrefix(reply[0], "-TOOLARGE graph limits-big has 20 edges") {
+			t.Errorf("Expected the configured threshold to refuse clustering with a TOOLARGE code, got %s", reply[0])
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestAttributeKeys tests the attribute key policy on writes and the report
+// of stored keys that break it
+func TestAttributeKeys(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_attrkeys_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	open := func(t *testing.T, opts ...storage.Option) *storage.BadgerEngine {
+		t.Helper()
+		engine := storage.NewBadgerEngine(append([]storage.Option{storage.WithMaxAttributeKeyLength(16)}, opts...)...)
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		return engine
+	}
+	engine := open(t)
+
+	if err := engine.CreateGraph(&models.Graph{ID: "keys", Name: "keys"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "web", Type: "service", Attributes: models.Attributes{"owner": "a"}},
+		{ID: "api", Type: "service", Attributes: models.Attributes{"owner": "a"}},
+		{ID: "legacy", Type: "service", Attributes: models.Attributes{"owner": "b"}},
+	} {
+		if err := engine.CreateNode("keys", node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	if err := engine.CreateEdge("keys", &models.Edge{ID: "web-api", Type: "calls", FromNodeID: "web", ToNodeID: "api"}); err != nil {
+		t.Fatalf("Failed to create edge: %v", err)
+	}
+	engine.Close()
+
+	// Seed keys written before the policy existed through raw Badger writes
+	db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open raw database: %v", err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		legacy := &models.Node{ID: "legacy", Type: "service", Attributes: models.Attributes{
+			"owner": "b", "owner ": "c", "": "empty", "line\nbreak": true,
+		}}
+		value, err := legacy.ToJSON()
+		if err != nil {
+			return err
+		}
+		if err := txn.Set(utils.EncodeNodeKey("keys", "legacy"), value); err != nil {
+			return err
+		}
+		edge := &models.Edge{ID: "web-api", Type: "calls", FromNodeID: "web", ToNodeID: "api",
+			Attributes: models.Attributes{"a-very-long-attribute-key": 1}}
+		if value, err = edge.ToJSON(); err != nil {
+			return err
+		}
+		return txn.Set(utils.EncodeEdgeKey("keys", "web-api"), value)
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed raw keys: %v", err)
+	}
+	db.Close()
+
+	engine = open(t)
+	defer func() { engine.Close() }()
+
+	t.Run("Rejections", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			key     string
+			problem string
+		}{
+			{"Empty", "", "must not be empty"},
+			{"Too Long", "an-even-longer-attribute-key", "is longer than 16 bytes"},
+			{"Control Character", "line\nbreak", "contains control character '\\n'"},
+			{"Leading Whitespace", " owner", "has leading or trailing whitespace"},
+			{"Trailing Whitespace", "owner ", "has leading or trailing whitespace"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				attributes := models.Attributes{"team": "a", tc.key: "x"}
+				checkRejected := func(what string, err error) {
+					t.Helper()
+					if !errors.Is(err, models.ErrBadArgument) || !strings.Contains(err.Error(), tc.problem) {
+						t.Errorf("Expected %s to fail with BADARG naming the problem, got %v", what, err)
+					}
+				}
+				checkRejected("CreateNode", engine.CreateNode("keys", &models.Node{ID: "new", Type: "service", Attributes: attributes}))
+				checkRejected("UpdateNode", engine.UpdateNode("keys", &models.Node{ID: "api", Type: "service", Attributes: attributes}))
+				checkRejected("CreateEdge", engine.CreateEdge("keys", &models.Edge{ID: "api-web", Type: "calls", FromNodeID: "api", ToNodeID: "web", Attributes: attributes}))
+				checkRejected("CreateGraph", engine.CreateGraph(&models.Graph{ID: "keys-new", Name: "keys-new", Attributes: attributes}))
+				graph, err := engine.GetGraph("keys")
+				if err != nil {
+					t.Fatalf("GetGraph failed: %v", err)
+				}
+				graph.Attributes = attributes
+				checkRejected("UpdateGraph", engine.UpdateGraph(graph))
+
+				checkRejected("Transaction UpdateEdge", engine.RunTransaction(func(tx storage.Transaction) error {
+					return tx.UpdateEdge("keys", &models.Edge{ID: "web-api", Type: "calls", FromNodeID: "web", ToNodeID: "api", Attributes: attributes})
+				}))
+			})
+		}
+
+		if node, err := engine.GetNode("keys", "api"); err != nil || !reflect.DeepEqual(node.Attributes, models.Attributes{"owner": "a"}) {
+			t.Errorf("Expected api to be unchanged, got %+v, %v", node, err)
+		}
+		if _, err := engine.GetNode("keys", "new"); err == nil {
+			t.Error("Expected the rejected node not to be created")
+		}
+	})
+
+	t.Run("Separator", func(t *testing.T) {
+		// Keys may contain ":", which the attribute index escapes so a key
+		// cannot read as a shorter key followed by part of a value
+		prefix := string(utils.CreateAttributeIteratorPrefix("keys", "n", "a", `"x"`))
+		key := string(utils.EncodeAttributeIndexKey("keys", "n", `a:"x"`, "1", "web"))
+		if strings.HasPrefix(key, prefix) {
+			t.Errorf("Expected %q not to fall under %q", key, prefix)
+		}
+
+		if err := engine.CreateNode("keys", &models.Node{ID: "pod", Type: "workload", Attributes: models.Attributes{"k8s:ns": "prod", "k8s": "ns:prod"}}); err != nil {
+			t.Fatalf("CreateNode failed: %v", err)
+		}
+		for _, attr := range []struct {
+			key   string
+			value interface{}
+		}{{"k8s:ns", "prod"}, {"k8s", "ns:prod"}} {
+			nodes, err := engine.FindNodesByAttribute("keys", attr.key, attr.value)
+			if err != nil {
+				t.Fatalf("FindNodesByAttribute failed: %v", err)
+			}
+			if len(nodes) != 1 || nodes[0].ID != "pod" {
+				t.Errorf("Expected pod for %s, got %v", attr.key, nodes)
+			}
+		}
+		if err := engine.DeleteNode("keys", "pod"); err != nil {
+			t.Fatalf("DeleteNode failed: %v", err)
+		}
+	})
+
+	t.Run("Existing Keys", func(t *testing.T) {
+		// Keys an entity already holds stay editable until strict mode
+		legacy, err := engine.GetNode("keys", "legacy")
+		if err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		legacy.Type = "gateway"
+		if err := engine.UpdateNode("keys", legacy); err != nil {
+			t.Errorf("Expected an update keeping existing keys to succeed, got %v", err)
+		}
+		legacy.Attributes["\tnew"] = 1
+		if err := engine.UpdateNode("keys", legacy); !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected a new bad key to be rejected, got %v", err)
+		}
+
+		engine.Close()
+		engine = open(t, storage.WithStrictAttributeKeys(true))
+		legacy, err = engine.GetNode("keys", "legacy")
+		if err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		if err := engine.UpdateNode("keys", legacy); !errors.Is(err, models.ErrBadArgument) || !strings.Contains(err.Error(), `node attribute key ""`) {
+			t.Errorf("Expected strict mode to reject existing keys, got %v", err)
+		}
+		engine.Close()
+		engine = open(t)
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		report, err := engine.ValidateAttributeKeys("keys")
+		if err != nil {
+			t.Fatalf("ValidateAttributeKeys failed: %v", err)
+		}
+		expected := &storage.AttributeKeyReport{Graph: "keys", Nodes: 3, Edges: 1, Violations: []storage.AttributeKeyViolation{
+			{Entity: "node", ID: "legacy", Key: "", Problem: "must not be empty"},
+			{Entity: "node", ID: "legacy", Key: "line\nbreak", Problem: "contains control character '\\n'"},
+			{Entity: "node", ID: "legacy", Key: "owner ", Problem: "has leading or trailing whitespace"},
+			{Entity: "edge", ID: "web-api", Key: "a-very-long-attribute-key", Problem: "is longer than 16 bytes"},
+		}}
+		if !reflect.DeepEqual(report, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, report)
+		}
+		if _, err := engine.ValidateAttributeKeys("missing"); err == nil {
+			t.Error("Expected an error for a missing graph")
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+
+		for _, args := range [][]string{
+			{"NODE.CREATE", "keys", "new", "service", `{" owner":"a"}`},
+			{"NODE.UPDATE", "keys", "api", "ATTRIBUTES", `{"owner\u0000":"a"}`},
+			{"EDGE.CREATE", "keys", "api-web", "api", "web", "calls", `{"":1}`},
+			{"EDGE.UPDATE", "keys", "web-api", `{"owner ":"a"}`},
+			{"GRAPH.SETATTR", "keys", "owner ", `"a"`},
+		} {
+			_, err := handler.Handle(args[0], args[1:])
+			if err == nil || !strings.HasPrefix(err.Error(), "BADARG") || !strings.Contains(err.Error(), "attribute key") {
+				t.Errorf("Expected %s to fail with BADARG naming the key, got %v", args[0], err)
+			}
+		}
+
+		resp, err := handler.Handle("SYSTEM.VALIDATEATTRS", []string{"keys"})
+		if err != nil {
+			t.Fatalf("SYSTEM.VALIDATEATTRS failed: %v", err)
+		}
+		expected := []string{
+			"graph", "keys", "nodes", "3", "edges", "1", "violations", "4",
+			"node:legacy", `"" must not be empty`,
+			"node:legacy", `"line\nbreak" contains control character '\n'`,
+			"node:legacy", `"owner " has leading or trailing whitespace`,
+			"edge:web-api", `"a-very-long-attribute-key" is longer than 16 bytes`,
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %q, got %q", expected, resp.ArrayValue)
+		}
+		for _, args := range [][]string{{}, {"keys", "extra"}, {"missing"}} {
+			if _, err := handler.Handle("SYSTEM.VALIDATEATTRS", args); err == nil {
+				t.Errorf("Expected SYSTEM.VALIDATEATTRS %v to fail", args)
+			}
+		}
+	})
+}
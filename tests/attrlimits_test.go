@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// nestedAttributes returns depth objects nested under "a", counting the
+// attributes object itself, with a string in the innermost one
+func nestedAttributes(depth int) models.Attributes {
+	attributes := models.Attributes{"a": "leaf"}
+	for i := 1; i < depth; i++ {
+		attributes = models.Attributes{"a": map[string]interface{}(attributes)}
+	}
+	return attributes
+}
+
+// TestAttributeLimits tests the nesting, key count, string length and UTF-8
+// limits on attribute values, in the validator, the engine and the commands
+func TestAttributeLimits(t *testing.T) {
+	t.Run("Boundaries", func(t *testing.T) {
+		limits := models.AttributeLimits{MaxDepth: 3, MaxKeys: 5, MaxStringLength: 4}
+		tests := []struct {
+			name       string
+			attributes models.Attributes
+			path       string // "" if the attributes are within the limits
+		}{
+			{"DepthAtLimit", models.Attributes{"a": map[string]interface{}{"b": map[string]interface{}{}}}, ""},
+			{"DepthPastLimit", models.Attributes{"a": map[string]interface{}{"b": map[string]interface{}{"c": map[string]interface{}{}}}}, "attributes.a.b.c is nested deeper than 3 levels"},
+			{"ArrayDepthAtLimit", models.Attributes{"a": []interface{}{[]interface{}{}}}, ""},
+			{"ArrayDepthPastLimit", models.Attributes{"a": []interface{}{[]interface{}{"x", []interface{}{}}}}, "attributes.a[0][1] is nested deeper than 3 levels"},
+			{"KeysAtLimit", models.Attributes{"a": 1.0, "b": []interface{}{1.0, 2.0}, "c": map[string]interface{}{}}, ""},
+			{"KeysPastLimit", models.Attributes{"a": 1.0, "b": []interface{}{1.0, 2.0}, "c": map[string]interface{}{"d": true}}, "attributes.c.d is past the limit of 5 keys"},
+			{"StringAtLimit", models.Attributes{"a": "abcd", "b": "éé"}, ""},
+			{"StringPastLimit", models.Attributes{"labels": []interface{}{"a", "b", "c", "abcde"}}, "attributes.labels[3] is longer than 4 bytes"},
+			{"InvalidValue", models.Attributes{"a": "ok", "b": "\xff"}, "attributes.b is not valid UTF-8"},
+			{"InvalidKey", models.Attributes{"a": map[string]interface{}{"\xff": 1.0}}, "attributes.a has a key that is not valid UTF-8"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := models.ValidateAttributes("node", tt.attributes, limits)
+				if tt.path == "" {
+					if err != nil {
+						t.Errorf("Expected the attributes to pass, got %v", err)
+					}
+					return
+				}
+				if !errors.Is(err, models.ErrBadArgument) || err.Error() != "BADARG node "+tt.path {
+					t.Errorf("Expected BADARG node %s, got %v", tt.path, err)
+				}
+			})
+		}
+
+		if err := models.ValidateAttributes("node", nestedAttributes(40), models.AttributeLimits{}); err != nil {
+			t.Errorf("Expected no limits to accept any depth, got %v", err)
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		limits := models.DefaultAttributeLimits()
+		if err := models.ValidateAttributes("node", nestedAttributes(10), limits); err != nil {
+			t.Errorf("Expected 10 levels to pass, got %v", err)
+		}
+		err := models.ValidateAttributes("node", nestedAttributes(40), limits)
+		expected := "attributes" + strings.Repeat(".a", 10) + " is nested deeper than 10 levels"
+		if err == nil || !strings.HasSuffix(err.Error(), expected) {
+			t.Errorf("Expected %q, got %v", expected, err)
+		}
+
+		// A large payload at every limit: 1000 keys, of which 900 array
+		// elements and 10 in nested, nested 10 levels deep
+		large := models.Attributes{"description": strings.Repeat("x", models.DefaultMaxAttributeStringLength)}
+		var items []interface{}
+		for i := 0; i < 900; i++ {
+			items = append(items, float64(i))
+		}
+		large["items"] = items
+		for i := 0; i < models.DefaultMaxAttributeKeys-912; i++ {
+			large[fmt.Sprintf("key%03d", i)] = true
+		}
+		large["nested"] = nestedAttributes(9)
+		if err := models.ValidateAttributes("node", large, limits); err != nil {
+			t.Errorf("Expected the large payload to pass, got %v", err)
+		}
+		large["one_more"] = true
+		if err := models.ValidateAttributes("node", large, limits); !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected one key more to fail, got %v", err)
+		}
+	})
+
+	t.Run("Engine", func(t *testing.T) {
+		testPath := filepath.Join(os.TempDir(), "pathwaydb_attrlimits_test")
+		os.RemoveAll(testPath)
+		defer os.RemoveAll(testPath)
+		open := func(t *testing.T, opts ...storage.Option) *storage.BadgerEngine {
+			t.Helper()
+			engine := storage.NewBadgerEngine(opts...)
+			if err := engine.Open(testPath); err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			return engine
+		}
+
+		engine := open(t)
+		if err := engine.CreateGraph(&models.Graph{ID: "limits", Name: "limits"}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		payload := models.Attributes{"description": strings.Repeat("é", 1000), "tags": []interface{}{"a", "b"}, "nested": nestedAttributes(9)}
+		if err := engine.CreateNode("limits", &models.Node{ID: "web", Type: "service", Attributes: payload}); err != nil {
+			t.Fatalf("CreateNode failed: %v", err)
+		}
+		stored, err := engine.GetNode("limits", "web")
+		if err != nil || !models.AttributesEqual(stored.Attributes, payload) {
+			t.Errorf("Expected the payload to be stored unchanged, got %v, %v", stored, err)
+		}
+		if err := engine.CreateNode("limits", &models.Node{ID: "api", Type: "service", Attributes: nestedAttributes(11)}); !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected 11 levels to fail, got %v", err)
+		}
+		if err := engine.CreateEdge("limits", &models.Edge{ID: "bad", Type: "calls", FromNodeID: "web", ToNodeID: "web", Attributes: models.Attributes{"note": "\xff"}}); !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected invalid UTF-8 to fail, got %v", err)
+		}
+		var document bytes.Buffer
+		if err := engine.ExportGraph("limits", &document, false); err != nil {
+			t.Fatalf("ExportGraph failed: %v", err)
+		}
+		engine.Close()
+
+		// Tighter limits reject the payload on import and when it changes,
+		// but not on an update leaving it as it was
+		engine = open(t, storage.WithAttributeLimits(models.AttributeLimits{MaxDepth: 3, MaxStringLength: 100}))
+		if _, _, err := engine.ImportGraph("copy", bytes.NewReader(document.Bytes())); !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected the import to fail, got %v", err)
+		}
+		node, _ := engine.GetNode("limits", "web")
+		node.Attributes["owner"] = "team-a"
+		if err := engine.UpdateNode("limits", node); err != nil {
+			t.Errorf("Expected an update keeping the held values to pass, got %v", err)
+		}
+		node.Attributes["description"] = strings.Repeat("x", 101)
+		if err := engine.UpdateNode("limits", node); err == nil || !strings.Contains(err.Error(), "attributes.description is longer than 100 bytes") {
+			t.Errorf("Expected the changed description to fail, got %v", err)
+		}
+		engine.Close()
+
+		engine = open(t, storage.WithAttributeLimits(models.AttributeLimits{MaxDepth: 3}), storage.WithStrictAttributeKeys(true))
+		defer engine.Close()
+		node, _ = engine.GetNode("limits", "web")
+		node.Attributes["owner"] = "team-b"
+		if err := engine.UpdateNode("limits", node); !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected strict checking to reject the held payload, got %v", err)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		testPath := filepath.Join(os.TempDir(), "pathwaydb_attrlimits_command_test")
+		os.RemoveAll(testPath)
+		defer os.RemoveAll(testPath)
+		engine := storage.NewBadgerEngine(storage.WithAttributeLimits(models.AttributeLimits{MaxDepth: 2, MaxKeys: 3, MaxStringLength: 8}))
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+		handler := redis.NewCommandHandler(engine)
+		if _, err := handler.Handle("GRAPH.CREATE", []string{"limits"}); err != nil {
+			t.Fatalf("GRAPH.CREATE failed: %v", err)
+		}
+		if _, err := handler.Handle("NODE.CREATE", []string{"limits", "web", "service", `{"tags":["a","b"]}`}); err != nil {
+			t.Fatalf("NODE.CREATE failed: %v", err)
+		}
+		if _, err := handler.Handle("EDGE.CREATE", []string{"limits", "loop", "web", "web", "calls"}); err != nil {
+			t.Fatalf("EDGE.CREATE failed: %v", err)
+		}
+
+		for _, tt := range []struct {
+			command string
+			args    []string
+			reply   string
+		}{
+			{"NODE.CREATE", []string{"limits", "api", "service", `{"a":{"b":{}}}`}, "BADARG node attributes.a.b is nested deeper than 2 levels"},
+			{"NODE.CREATE", []string{"limits", "api", "service", "{\"name\":\"caf\xe9\"}"}, "BADARG node attributes.name is not valid UTF-8"},
+			{"NODE.UPDATE", []string{"limits", "web", "ATTRIBUTES", `{"tags":["a","b","c"]}`}, "BADARG node attributes.tags[2] is past the limit of 3 keys"},
+			{"EDGE.UPDATE", []string{"limits", "loop", `{"note":"too long a note"}`}, "BADARG edge attributes.note is longer than 8 bytes"},
+			{"EDGE.CREATE", []string{"limits", "loop2", "web", "web", "calls", "{\"\xff\":1}"}, "BADARG edge attributes.� is not valid UTF-8"},
+			{"NODE.MCREATE", []string{"limits", "[{\"id\":\"db\",\"type\":\"database\",\"attributes\":{\"n\":[\"\xff\"]}}]"}, "BADARG node db attributes.n[0] is not valid UTF-8"},
+		} {
+			_, err := handler.Handle(tt.command, tt.args)
+			if err == nil || redis.ErrorReply(err) != tt.reply {
+				t.Errorf("Expected %s to reply %q, got %v", tt.command, tt.reply, err)
+			}
+		}
+		if node, err := engine.GetNode("limits", "web"); err != nil || len(node.Attributes["tags"].([]interface{})) != 2 {
+			t.Errorf("Expected web to be left unchanged, got %v, %v", node, err)
+		}
+	})
+}
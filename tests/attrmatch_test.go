@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestAttributeMatching tests how FindNodesByAttribute, FindEdgesByAttribute
+// and the FILTER commands compare attribute values: numbers by value
+// whatever their type, booleans and strings only with their own type, and
+// dotted keys against the keys of object attributes
+func TestAttributeMatching(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_attrmatch_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("match")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "match"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	// api is written through the Go API with Go integers, the others
+	// through NODE.CREATE, so their numbers are stored as decoded JSON
+	if err := engine.CreateNode(graphID, &models.Node{ID: "api", Type: "service", Attributes: models.Attributes{
+		"port":    8080,
+		"enabled": true,
+		"meta":    map[string]interface{}{"owner": "alice", "tier": 1},
+	}}); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	for _, args := range [][]string{
+		{"NODE.CREATE", "web", "service", `{"port":8080.0,"enabled":"true","meta":{"owner":"bob","tier":2}}`},
+		{"NODE.CREATE", "db", "database", `{"port":"8080","enabled":false,"meta.owner":"alice"}`},
+		{"EDGE.CREATE", "web-api", "web", "api", "calls", `{"meta":{"owner":"alice","retries":3},"secure":true}`},
+		{"EDGE.CREATE", "api-db", "api", "db", "reads", `{"meta":{"owner":"carol","retries":3.0},"secure":false}`},
+	} {
+		if _, err := handler.Handle(args[0], append([]string{string(graphID)}, args[1:]...)); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+	}
+
+	findNodes := func(t *testing.T, key string, value interface{}) []string {
+		t.Helper()
+		nodes, err := engine.FindNodesByAttribute(graphID, key, value)
+		if err != nil {
+			t.Fatalf("FindNodesByAttribute failed: %v", err)
+		}
+		ids := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			ids = append(ids, string(node.ID))
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	findEdges := func(t *testing.T, key string, value interface{}) []string {
+		t.Helper()
+		edges, err := engine.FindEdgesByAttribute(graphID, key, value)
+		if err != nil {
+			t.Fatalf("FindEdgesByAttribute failed: %v", err)
+		}
+		ids := make([]string, 0, len(edges))
+		for _, edge := range edges {
+			ids = append(ids, string(edge.ID))
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	// filterNodes runs NODE.FILTER and returns the IDs of its reply of
+	// id, type and attributes triples
+	filterNodes := func(t *testing.T, key, value string) []string {
+		t.Helper()
+		resp, err := handler.Handle("NODE.FILTER", []string{string(graphID), key, value, "ORDERBY", "id"})
+		if err != nil {
+			t.Fatalf("NODE.FILTER %s %s failed: %v", key, value, err)
+		}
+		ids := []string{}
+		for i := 0; i < len(resp.ArrayValue); i += 3 {
+			ids = append(ids, resp.ArrayValue[i])
+		}
+		return ids
+	}
+
+	t.Run("Numbers", func(t *testing.T) {
+		for _, value := range []interface{}{8080, int64(8080), uint16(8080), 8080.0, float32(8080), json.Number("8080")} {
+			if got, expected := findNodes(t, "port", value), []string{"api", "web"}; !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %T 8080 to match %v, got %v", value, expected, got)
+			}
+		}
+		if got, expected := findNodes(t, "port", "8080"), []string{"db"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected the string 8080 to match only %v, got %v", expected, got)
+		}
+		if got, expected := filterNodes(t, "port", "8080"), []string{"api", "web"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected NODE.FILTER port 8080 to match %v, got %v", expected, got)
+		}
+		if got, expected := filterNodes(t, "port", `"8080"`), []string{"db"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf(`Expected NODE.FILTER port "8080" to match %v, got %v`, expected, got)
+		}
+		if got, expected := findEdges(t, "meta.retries", 3), []string{"api-db", "web-api"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected 3 to match 3.0 in %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Booleans", func(t *testing.T) {
+		if got, expected := findNodes(t, "enabled", true), []string{"api"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected true to match only %v, got %v", expected, got)
+		}
+		if got, expected := findNodes(t, "enabled", false), []string{"db"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected false to match only %v, got %v", expected, got)
+		}
+		if got, expected := filterNodes(t, "enabled", "true"), []string{"api"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected NODE.FILTER enabled true to match %v, got %v", expected, got)
+		}
+		if got, expected := filterNodes(t, "enabled", `"true"`), []string{"web"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf(`Expected NODE.FILTER enabled "true" to match %v, got %v`, expected, got)
+		}
+		resp, err := handler.Handle("EDGE.FILTER", []string{string(graphID), "secure", "true"})
+		if err != nil {
+			t.Fatalf("EDGE.FILTER failed: %v", err)
+		}
+		if len(resp.ArrayValue) == 0 || resp.ArrayValue[0] != "web-api" || len(resp.ArrayValue) != 5 {
+			t.Errorf("Expected EDGE.FILTER secure true to match web-api, got %v", resp.ArrayValue)
+		}
+	})
+
+	t.Run("DottedKeys", func(t *testing.T) {
+		// A key stored with a dot in it is matched as it is, besides the
+		// nested keys it names
+		if got, expected := findNodes(t, "meta.owner", "alice"), []string{"api", "db"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected meta.owner alice to match %v, got %v", expected, got)
+		}
+		if got, expected := findNodes(t, "meta.tier", 2), []string{"web"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected meta.tier 2 to match %v, got %v", expected, got)
+		}
+		if got := findNodes(t, "meta.tier.level", 1); len(got) != 0 {
+			t.Errorf("Expected only one level of nesting to be looked into, got %v", got)
+		}
+		if got, expected := filterNodes(t, "meta.owner", "bob"), []string{"web"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected NODE.FILTER meta.owner bob to match %v, got %v", expected, got)
+		}
+		if got, expected := findEdges(t, "meta.owner", "alice"), []string{"web-api"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected edge meta.owner alice to match %v, got %v", expected, got)
+		}
+		edges, err := engine.FilterEdges(graphID, storage.EdgeFilter{AttrKey: "meta.owner", AttrValue: "carol"})
+		if err != nil || len(edges) != 1 || edges[0].ID != "api-db" {
+			t.Errorf("Expected FilterEdges meta.owner carol to match api-db, got %v, %v", edges, err)
+		}
+	})
+}
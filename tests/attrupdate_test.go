@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestAttributeUpdates tests NODE.UPDATE and EDGE.UPDATE with MERGE and the
+// single-attribute ATTR.SET and ATTR.DEL commands, and that concurrent
+// updates of different keys all survive
+func TestAttributeUpdates(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_attrupdate_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("attrs")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "attrs"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+
+	run := func(t *testing.T, command string, args ...string) *redis.Response {
+		t.Helper()
+		resp, err := handler.Handle(command, append([]string{string(graphID)}, args...))
+		if err != nil {
+			t.Fatalf("%s %v failed: %v", command, args, err)
+		}
+		return resp
+	}
+	// attributesJSON returns the attributes as JSON, with sorted keys
+	attributesJSON := func(t *testing.T, attributes models.Attributes) string {
+		t.Helper()
+		encoded, err := json.Marshal(attributes)
+		if err != nil {
+			t.Fatalf("Failed to encode attributes: %v", err)
+		}
+		return string(encoded)
+	}
+	getNode := func(t *testing.T, id models.NodeID) *models.Node {
+		t.Helper()
+		node, err := engine.GetNode(graphID, id)
+		if err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		return node
+	}
+	getEdge := func(t *testing.T, id models.EdgeID) *models.Edge {
+		t.Helper()
+		edge, err := engine.GetEdge(graphID, id)
+		if err != nil {
+			t.Fatalf("GetEdge failed: %v", err)
+		}
+		return edge
+	}
+
+	run(t, "NODE.CREATE", "api", "service", `{"meta":{"owner":"alice","team":"core"},"port":8080}`)
+	run(t, "NODE.CREATE", "db", "database")
+	run(t, "EDGE.CREATE", "api-db", "api", "db", "reads", `{"meta":{"pool":4},"protocol":"tcp"}`)
+
+	t.Run("NodeMerge", func(t *testing.T) {
+		before := getNode(t, "api")
+		time.Sleep(time.Millisecond)
+		run(t, "NODE.UPDATE", "api", "MERGE", `{"meta":{"owner":"bob"},"version":"2.0"}`)
+		node := getNode(t, "api")
+		// Nested objects are replaced whole, not merged
+		if got, expected := attributesJSON(t, node.Attributes), `{"meta":{"owner":"bob"},"port":8080,"version":"2.0"}`; got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+		if !node.UpdatedAt.After(before.UpdatedAt) {
+			t.Errorf("Expected the update time to move past %v, got %v", before.UpdatedAt, node.UpdatedAt)
+		}
+
+		run(t, "NODE.UPDATE", "api", "TYPE", "gateway", "MERGE", `{"port":9090}`)
+		node = getNode(t, "api")
+		if got, expected := attributesJSON(t, node.Attributes), `{"meta":{"owner":"bob"},"port":9090,"version":"2.0"}`; got != expected || node.Type != "gateway" {
+			t.Errorf("Expected type gateway with %s, got %s with %s", expected, node.Type, got)
+		}
+		if resp := run(t, "NODE.UPDATE", "api", "MERGE", `{"port":9090}`); resp.StringValue != "NOCHANGE" {
+			t.Errorf("Expected merging stored values to reply NOCHANGE, got %+v", resp)
+		}
+	})
+
+	t.Run("NodeAttr", func(t *testing.T) {
+		before := getNode(t, "api")
+		time.Sleep(time.Millisecond)
+		if resp := run(t, "NODE.ATTR.SET", "api", "meta", `{"owner":"carol","tags":["a","b"]}`); resp.StringValue != "OK" {
+			t.Errorf("Expected OK, got %+v", resp)
+		}
+		node := getNode(t, "api")
+		if got, expected := attributesJSON(t, node.Attributes), `{"meta":{"owner":"carol","tags":["a","b"]},"port":9090,"version":"2.0"}`; got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+		if !node.UpdatedAt.After(before.UpdatedAt) {
+			t.Errorf("Expected the update time to move past %v, got %v", before.UpdatedAt, node.UpdatedAt)
+		}
+
+		before = node
+		time.Sleep(time.Millisecond)
+		if resp := run(t, "NODE.ATTR.DEL", "api", "version"); resp.IntValue != 1 {
+			t.Errorf("Expected 1 for a removed key, got %+v", resp)
+		}
+		node = getNode(t, "api")
+		if _, ok := node.Attributes["version"]; ok || !node.UpdatedAt.After(before.UpdatedAt) {
+			t.Errorf("Expected version removed and the update time moved, got %v at %v", node.Attributes, node.UpdatedAt)
+		}
+
+		// Removing a missing key writes nothing
+		before = node
+		if resp := run(t, "NODE.ATTR.DEL", "api", "missing"); resp.IntValue != 0 {
+			t.Errorf("Expected 0 for a missing key, got %+v", resp)
+		}
+		if node = getNode(t, "api"); !node.UpdatedAt.Equal(before.UpdatedAt) {
+			t.Errorf("Expected the update time to stay %v, got %v", before.UpdatedAt, node.UpdatedAt)
+		}
+	})
+
+	t.Run("EdgeMerge", func(t *testing.T) {
+		before := getEdge(t, "api-db")
+		time.Sleep(time.Millisecond)
+		run(t, "EDGE.UPDATE", "api-db", "MERGE", `{"meta":{"timeout":30}}`, "TTL", "3600")
+		edge := getEdge(t, "api-db")
+		if got, expected := attributesJSON(t, edge.Attributes), `{"meta":{"timeout":30},"protocol":"tcp"}`; got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+		if !edge.UpdatedAt.After(before.UpdatedAt) || edge.ExpiresAt == nil {
+			t.Errorf("Expected the update time to move and a TTL set, got %v and %v", edge.UpdatedAt, edge.ExpiresAt)
+		}
+
+		run(t, "EDGE.UPDATE", "api-db", `{"protocol":"udp"}`)
+		if got, expected := attributesJSON(t, getEdge(t, "api-db").Attributes), `{"protocol":"udp"}`; got != expected {
+			t.Errorf("Expected EDGE.UPDATE without MERGE to replace the attributes with %s, got %s", expected, got)
+		}
+	})
+
+	t.Run("EdgeAttr", func(t *testing.T) {
+		before := getEdge(t, "api-db")
+		time.Sleep(time.Millisecond)
+		run(t, "EDGE.ATTR.SET", "api-db", "weight", "1.5")
+		edge := getEdge(t, "api-db")
+		if got, expected := attributesJSON(t, edge.Attributes), `{"protocol":"udp","weight":1.5}`; got != expected || !edge.UpdatedAt.After(before.UpdatedAt) {
+			t.Errorf("Expected %s and the update time moved, got %s at %v", expected, got, edge.UpdatedAt)
+		}
+		if resp := run(t, "EDGE.ATTR.DEL", "api-db", "weight"); resp.IntValue != 1 {
+			t.Errorf("Expected 1 for a removed key, got %+v", resp)
+		}
+		if resp := run(t, "EDGE.ATTR.DEL", "api-db", "weight"); resp.IntValue != 0 {
+			t.Errorf("Expected 0 for a missing key, got %+v", resp)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		// Each writer sets its own key; none may be lost to another
+		// writer's read-modify-write
+		const writers = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, writers*2)
+		for i := 0; i < writers; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				_, err := handler.Handle("NODE.ATTR.SET", []string{string(graphID), "db", fmt.Sprintf("k%d", i), fmt.Sprint(i)})
+				errs <- err
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				_, err := handler.Handle("NODE.UPDATE", []string{string(graphID), "db", "MERGE", fmt.Sprintf(`{"m%d":%d}`, i, i)})
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("Concurrent update failed: %v", err)
+			}
+		}
+		if got := len(getNode(t, "db").Attributes); got != writers*2 {
+			t.Errorf("Expected %d attributes, got %d: %v", writers*2, got, getNode(t, "db").Attributes)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, c := range []struct {
+			command string
+			args    []string
+		}{
+			{"NODE.UPDATE", []string{"api", "MERGE"}},
+			{"NODE.UPDATE", []string{"api", "MERGE", "[1]"}},
+			{"NODE.UPDATE", []string{"api", "ATTRIBUTES", "{}", "MERGE", "{}"}},
+			{"NODE.ATTR.SET", []string{"api", "key"}},
+			{"NODE.ATTR.SET", []string{"api", "key", "not json"}},
+			{"NODE.ATTR.SET", []string{"missing", "key", "1"}},
+			{"NODE.ATTR.DEL", []string{"missing", "key"}},
+			{"EDGE.UPDATE", []string{"api-db", "MERGE"}},
+			{"EDGE.ATTR.SET", []string{"missing", "key", "1"}},
+			{"EDGE.ATTR.DEL", []string{"api-db"}},
+		} {
+			if _, err := handler.Handle(c.command, append([]string{string(graphID)}, c.args...)); err == nil {
+				t.Errorf("Expected %s %v to fail", c.command, c.args)
+			}
+		}
+	})
+}
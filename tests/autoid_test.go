@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestAutoIDs tests NODE.CREATE and EDGE.CREATE with server-generated IDs
+// and the NX flag
+func TestAutoIDs(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_autoid_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	if err := engine.CreateGraph(&models.Graph{ID: "ids", Name: "ids"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+
+	ulid := regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`)
+	create := func(t *testing.T, command string, args ...string) string {
+		t.Helper()
+		resp, err := handler.Handle(command, args)
+		if err != nil {
+			t.Fatalf("%s %v failed: %v", command, args, err)
+		}
+		if resp.Type != protocol.ResponseTypeBulk || !ulid.MatchString(resp.StringValue) {
+			t.Fatalf("Expected %s AUTO to reply with a ULID, got %+v", command, resp)
+		}
+		return resp.StringValue
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		from := create(t, "NODE.CREATE", "ids", "AUTO", "service", `{"name":"api"}`)
+		to := create(t, "NODE.CREATE", "ids", "auto", "database")
+		node, err := engine.GetNode("ids", models.NodeID(from))
+		if err != nil || node.Type != "service" || node.Attributes["name"] != "api" {
+			t.Errorf("Expected the node to be stored under its generated ID, got %+v, %v", node, err)
+		}
+
+		edgeID := create(t, "EDGE.CREATE", "ids", "AUTO", from, to, "reads", "WEAK")
+		edge, err := engine.GetEdge("ids", models.EdgeID(edgeID))
+		if err != nil || string(edge.FromNodeID) != from || string(edge.ToNodeID) != to || !edge.Weak {
+			t.Errorf("Expected the edge to be stored under its generated ID, got %+v, %v", edge, err)
+		}
+	})
+
+	t.Run("Sortable", func(t *testing.T) {
+		// Many IDs fall in one millisecond and must still sort in order
+		var ids []string
+		for i := 0; i < 200; i++ {
+			command, args := "NODE.CREATE", []string{"ids", "AUTO", "worker"}
+			if i%2 == 1 {
+				command, args = "EDGE.CREATE", []string{"ids", "AUTO", ids[0], ids[0], "self"}
+			}
+			ids = append(ids, create(t, command, args...))
+		}
+		if !sort.StringsAreSorted(ids) {
+			t.Error("Expected successive node and edge IDs to sort in the order they were made")
+		}
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("Expected unique IDs, got %s twice", id)
+			}
+			seen[id] = true
+		}
+	})
+
+	t.Run("Collision", func(t *testing.T) {
+		// A generator that repeats itself gets another try rather than
+		// replacing the node
+		next := 0
+		repeating := redis.NewCommandHandler(engine, redis.WithIDGenerator(func() (string, error) {
+			next++
+			return fmt.Sprintf("fixed-%d", min(next, 2)), nil
+		}))
+		if err := engine.CreateNode("ids", &models.Node{ID: "fixed-1", Type: "existing"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		resp, err := repeating.Handle("NODE.CREATE", []string{"ids", "AUTO", "service"})
+		if err != nil || resp.StringValue != "fixed-2" {
+			t.Fatalf("Expected the taken ID to be skipped, got %+v, %v", resp, err)
+		}
+		if node, err := engine.GetNode("ids", "fixed-1"); err != nil || node.Type != "existing" {
+			t.Errorf("Expected fixed-1 to be kept, got %+v, %v", node, err)
+		}
+
+		// and gives up when every ID it makes is taken
+		if _, err := repeating.Handle("NODE.CREATE", []string{"ids", "AUTO", "service"}); err == nil {
+			t.Error("Expected a generator of taken IDs to fail")
+		}
+		if node, err := engine.GetNode("ids", "fixed-2"); err != nil || node.Type != "service" {
+			t.Errorf("Expected fixed-2 to be kept, got %+v, %v", node, err)
+		}
+	})
+
+	t.Run("NX", func(t *testing.T) {
+		if _, err := handler.Handle("NODE.CREATE", []string{"ids", "db", "database", "NX"}); err != nil {
+			t.Fatalf("Expected NX to create a new node, got %v", err)
+		}
+		_, err := handler.Handle("NODE.CREATE", []string{"ids", "db", "cache", `{"v":2}`, "nx"})
+		if !errors.Is(err, commands.ErrIDExists) || err.Error() != "EXISTS node db already exists" {
+			t.Errorf("Expected EXISTS, got %v", err)
+		}
+		if node, err := engine.GetNode("ids", "db"); err != nil || node.Type != "database" {
+			t.Errorf("Expected NX to leave the node alone, got %+v, %v", node, err)
+		}
+
+		if _, err := handler.Handle("EDGE.CREATE", []string{"ids", "db-db", "db", "db", "replicates", "NX"}); err != nil {
+			t.Fatalf("Expected NX to create a new edge, got %v", err)
+		}
+		if _, err := handler.Handle("EDGE.CREATE", []string{"ids", "db-db", "db", "db", "mirrors", "NX"}); !errors.Is(err, commands.ErrIDExists) {
+			t.Errorf("Expected EXISTS, got %v", err)
+		}
+
+		// Without NX, creating still replaces
+		if resp, err := handler.Handle("NODE.CREATE", []string{"ids", "db", "cache"}); err != nil || resp.StringValue != "OK" {
+			t.Fatalf("Expected the upsert to reply OK, got %+v, %v", resp, err)
+		}
+		if node, err := engine.GetNode("ids", "db"); err != nil || node.Type != "cache" {
+			t.Errorf("Expected the node to be replaced, got %+v, %v", node, err)
+		}
+	})
+}
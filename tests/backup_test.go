@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestBackupManifest tests manifest-wrapped backups, SYSTEM.BACKUP INFO and
+// checksum verification on restore
+func TestBackupManifest(t *testing.T) {
+	basePath := filepath.Join(os.TempDir(), "pathwaydb_backup_manifest_test")
+	os.RemoveAll(basePath)
+	defer os.RemoveAll(basePath)
+
+	sourcePath := filepath.Join(basePath, "source")
+	backupDir := filepath.Join(basePath, "backup")
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup directory: %v", err)
+	}
+
+	source := storage.NewBadgerEngine()
+	if err := source.Open(sourcePath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer source.Close()
+
+	for _, graphID := range []models.GraphID{"backup-a", "backup-ab"} {
+		if err := source.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+	}
+	for _, node := range []*models.Node{
+		{ID: "app", Type: "service"},
+		{ID: "db", Type: "database"},
+		{ID: "cache", Type: "cache"},
+	} {
+		if err := source.CreateNode("backup-a", node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	if err := source.CreateNode("backup-ab", &models.Node{ID: "solo", Type: "service"}); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "app-db", FromNodeID: "app", ToNodeID: "db", Type: "reads"},
+		{ID: "app-cache", FromNodeID: "app", ToNodeID: "cache", Type: "reads"},
+	} {
+		if err := source.CreateEdge("backup-a", edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+	// Deleted data must not be counted
+	if err := source.CreateNode("backup-a", &models.Node{ID: "gone", Type: "service"}); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	if err := source.DeleteNode("backup-a", "gone"); err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	if err := source.Backup(backupDir); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	backupFile := filepath.Join(backupDir, storage.BackupFileName)
+
+	// openTarget opens an empty database to restore into
+	openTarget := func(t *testing.T, name string) *storage.BadgerEngine {
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(filepath.Join(basePath, name)); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		t.Cleanup(func() { engine.Close() })
+		return engine
+	}
+
+	t.Run("AtomicWrite", func(t *testing.T) {
+		entries, err := os.ReadDir(backupDir)
+		if err != nil {
+			t.Fatalf("Failed to read backup directory: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Name() != storage.BackupFileName {
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			t.Errorf("Expected only %s in the backup directory, got %v", storage.BackupFileName, names)
+		}
+	})
+
+	t.Run("Info", func(t *testing.T) {
+		handler := redis.NewCommandHandler(source)
+		resp, err := handler.Handle("SYSTEM.BACKUP", []string{"INFO", backupDir})
+		if err != nil {
+			t.Fatalf("SYSTEM.BACKUP INFO failed: %v", err)
+		}
+
+		manifest := &storage.BackupManifest{}
+		if err := json.Unmarshal([]byte(resp.StringValue), manifest); err != nil {
+			t.Fatalf("Failed to parse manifest: %v", err)
+		}
+		if manifest.FormatVersion != storage.BackupFormatVersion {
+			t.Errorf("Expected format version %d, got %d", storage.BackupFormatVersion, manifest.FormatVersion)
+		}
+		if manifest.CreatedAt.IsZero() || manifest.BadgerVersion == "" || len(manifest.SHA256) != 64 {
+			t.Errorf("Expected timestamp, badger version and checksum, got %+v", manifest)
+		}
+		if manifest.TotalKeys == 0 || manifest.PayloadSize == 0 {
+			t.Errorf("Expected key count and payload size, got %+v", manifest)
+		}
+
+		expected := []storage.BackupGraph{
+			{ID: "backup-a", Nodes: 3, Edges: 2},
+			{ID: "backup-ab", Nodes: 1, Edges: 0},
+		}
+		if len(manifest.Graphs) != len(expected) {
+			t.Fatalf("Expected graphs %+v, got %+v", expected, manifest.Graphs)
+		}
+		for i := range expected {
+			if manifest.Graphs[i] != expected[i] {
+				t.Errorf("Expected graph %+v, got %+v", expected[i], manifest.Graphs[i])
+			}
+		}
+
+		if _, err := handler.Handle("SYSTEM.BACKUP", []string{"INFO", filepath.Join(basePath, "missing.db")}); err == nil {
+			t.Error("Expected error for a missing backup file")
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		target := openTarget(t, "restored")
+		if err := target.Restore(backupFile); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+
+		graph, err := target.GetGraph("backup-a")
+		if err != nil || graph.Name != "backup-a" {
+			t.Fatalf("Expected restored graph, got %v, %v", graph, err)
+		}
+		nodes, err := target.ListNodes("backup-a")
+		if err != nil || len(nodes) != 3 {
+			t.Errorf("Expected 3 restored nodes, got %d (%v)", len(nodes), err)
+		}
+		edges, err := target.ListEdges("backup-a")
+		if err != nil || len(edges) != 2 {
+			t.Errorf("Expected 2 restored edges, got %d (%v)", len(edges), err)
+		}
+	})
+
+	t.Run("CorruptPayload", func(t *testing.T) {
+		data, err := os.ReadFile(backupFile)
+		if err != nil {
+			t.Fatalf("Failed to read backup: %v", err)
+		}
+		manifest, err := storage.ReadBackupManifest(backupFile)
+		if err != nil {
+			t.Fatalf("Failed to read manifest: %v", err)
+		}
+		payloadStart := len(data) - int(manifest.PayloadSize)
+		data[payloadStart+int(manifest.PayloadSize)/2] ^= 0xff
+
+		corruptFile := filepath.Join(basePath, "corrupt.db")
+		if err := os.WriteFile(corruptFile, data, 0644); err != nil {
+			t.Fatalf("Failed to write corrupt backup: %v", err)
+		}
+
+		target := openTarget(t, "corrupt-target")
+		err = target.Restore(corruptFile)
+		if !errors.Is(err, storage.ErrBackupChecksum) {
+			t.Fatalf("Expected checksum error, got %v", err)
+		}
+		graphs, err := target.ListGraphs()
+		if err != nil {
+			t.Fatalf("Failed to list graphs: %v", err)
+		}
+		if len(graphs) != 0 {
+			t.Errorf("Expected nothing loaded from a corrupt backup, got %d graphs", len(graphs))
+		}
+
+		// A truncated payload fails the same way
+		truncatedFile := filepath.Join(basePath, "truncated.db")
+		if err := os.WriteFile(truncatedFile, data[:len(data)-10], 0644); err != nil {
+			t.Fatalf("Failed to write truncated backup: %v", err)
+		}
+		if err := target.Restore(truncatedFile); !errors.Is(err, storage.ErrBackupChecksum) {
+			t.Errorf("Expected checksum error for a truncated backup, got %v", err)
+		}
+	})
+
+	t.Run("IncompatibleVersion", func(t *testing.T) {
+		manifest, err := storage.ReadBackupManifest(backupFile)
+		if err != nil {
+			t.Fatalf("Failed to read manifest: %v", err)
+		}
+		manifest.FormatVersion = storage.BackupFormatVersion + 1
+		header, _ := json.Marshal(manifest)
+
+		futureFile := filepath.Join(basePath, "future.db")
+		if err := os.WriteFile(futureFile, append([]byte("PATHWAYDB-BACKUP\n"), append(header, '\n')...), 0644); err != nil {
+			t.Fatalf("Failed to write backup: %v", err)
+		}
+		target := openTarget(t, "future-target")
+		if err := target.Restore(futureFile); err == nil {
+			t.Error("Expected restore to refuse an unsupported format version")
+		}
+	})
+
+	t.Run("Legacy", func(t *testing.T) {
+		// Write a headerless stream the way Backup used to
+		legacyFile := filepath.Join(basePath, "legacy.db")
+		f, err := os.Create(legacyFile)
+		if err != nil {
+			t.Fatalf("Failed to create legacy backup: %v", err)
+		}
+		source.Close()
+		db, err := badger.Open(badger.DefaultOptions(sourcePath).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open badger: %v", err)
+		}
+		_, err = db.Backup(f, 0)
+		db.Close()
+		f.Close()
+		if err != nil {
+			t.Fatalf("Failed to write legacy backup: %v", err)
+		}
+		if err := source.Open(sourcePath); err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+
+		target := openTarget(t, "legacy-target")
+		if err := target.Restore(legacyFile); !errors.Is(err, storage.ErrLegacyBackup) {
+			t.Fatalf("Expected legacy backup error, got %v", err)
+		}
+		if err := target.RestoreLegacy(legacyFile); err != nil {
+			t.Fatalf("RestoreLegacy failed: %v", err)
+		}
+		if _, err := target.GetGraph("backup-ab"); err != nil {
+			t.Errorf("Expected graph from legacy backup: %v", err)
+		}
+	})
+}
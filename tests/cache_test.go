@@ -0,0 +1,460 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestRecordCache tests that cached node and edge reads never return values
+// a committed write replaced
+func TestRecordCache(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_cache_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine(storage.WithRecordCache(1000, storage.DefaultCacheMemory))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { engine.Close() }()
+
+	graphID := models.GraphID("cache")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "cache"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"web", "api", "db"} {
+		if err := engine.CreateNode(graphID, &models.Node{ID: id, Type: "service", Attributes: models.Attributes{"version": 1}}); err != nil {
+			t.Fatalf("Failed to create node %s: %v", id, err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "web-api", Type: "calls", FromNodeID: "web", ToNodeID: "api", Attributes: models.Attributes{"weight": 1}},
+		{ID: "api-db", Type: "calls", FromNodeID: "api", ToNodeID: "db"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	nodeVersion := func(t *testing.T, id models.NodeID) interface{} {
+		t.Helper()
+		node, err := engine.GetNode(graphID, id)
+		if err != nil {
+			t.Fatalf("GetNode %s failed: %v", id, err)
+		}
+		return node.Attributes["version"]
+	}
+
+	t.Run("Hits", func(t *testing.T) {
+		engine.ClearCache()
+		before := engine.CacheStats()
+		nodeVersion(t, "web")
+		node, err := engine.GetNode(graphID, "web")
+		if err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		stats := engine.CacheStats()
+		if stats.Hits != before.Hits+1 || stats.Misses != before.Misses+1 || stats.Entries != 1 {
+			t.Errorf("Expected one miss, one hit and one entry, got %+v", stats)
+		}
+
+		// Callers get copies, so changing one leaves the cache alone
+		node.Attributes["version"] = 99
+		if version := nodeVersion(t, "web"); version != float64(1) {
+			t.Errorf("Expected the cached node to be unchanged, got version %v", version)
+		}
+	})
+
+	t.Run("Updates And Deletes", func(t *testing.T) {
+		nodeVersion(t, "api")
+		if err := engine.UpdateNode(graphID, &models.Node{ID: "api", Type: "service", Attributes: models.Attributes{"version": 2}}); err != nil {
+			t.Fatalf("UpdateNode failed: %v", err)
+		}
+		if version := nodeVersion(t, "api"); version != float64(2) {
+			t.Errorf("Expected version 2 after UpdateNode, got %v", version)
+		}
+
+		if _, err := engine.GetEdge(graphID, "web-api"); err != nil {
+			t.Fatalf("GetEdge failed: %v", err)
+		}
+		if err := engine.UpdateEdge(graphID, &models.Edge{ID: "web-api", Type: "calls", FromNodeID: "web", ToNodeID: "api", Attributes: models.Attributes{"weight": 5}}); err != nil {
+			t.Fatalf("UpdateEdge failed: %v", err)
+		}
+		if edge, err := engine.GetEdge(graphID, "web-api"); err != nil || edge.Attributes["weight"] != float64(5) {
+			t.Errorf("Expected weight 5 after UpdateEdge, got %+v, %v", edge, err)
+		}
+
+		if err := engine.DeleteEdge(graphID, "web-api"); err != nil {
+			t.Fatalf("DeleteEdge failed: %v", err)
+		}
+		if _, err := engine.GetEdge(graphID, "web-api"); err == nil {
+			t.Error("Expected the deleted edge not to be found")
+		}
+
+		// Deleting a node also deletes its edges
+		if _, err := engine.GetEdge(graphID, "api-db"); err != nil {
+			t.Fatalf("GetEdge failed: %v", err)
+		}
+		nodeVersion(t, "db")
+		if err := engine.DeleteNode(graphID, "db"); err != nil {
+			t.Fatalf("DeleteNode failed: %v", err)
+		}
+		if _, err := engine.GetNode(graphID, "db"); err == nil {
+			t.Error("Expected the deleted node not to be found")
+		}
+		if _, err := engine.GetEdge(graphID, "api-db"); err == nil {
+			t.Error("Expected the edge of the deleted node not to be found")
+		}
+	})
+
+	t.Run("Bulk Operations", func(t *testing.T) {
+		nodeVersion(t, "web")
+		if _, err := engine.RenameNodeType(graphID, "service", "component"); err != nil {
+			t.Fatalf("RenameNodeType failed: %v", err)
+		}
+		if node, err := engine.GetNode(graphID, "web"); err != nil || node.Type != "component" {
+			t.Errorf("Expected the renamed type, got %+v, %v", node, err)
+		}
+
+		if err := engine.CreateGraph(&models.Graph{ID: "scratch", Name: "scratch"}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		if err := engine.CreateNode("scratch", &models.Node{ID: "web", Type: "service", Attributes: models.Attributes{"version": 1}}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		if _, err := engine.GetNode("scratch", "web"); err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		if _, err := engine.DeleteGraph("scratch"); err != nil {
+			t.Fatalf("DeleteGraph failed: %v", err)
+		}
+		if err := engine.CreateGraph(&models.Graph{ID: "scratch", Name: "scratch"}); err != nil {
+			t.Fatalf("Failed to recreate graph: %v", err)
+		}
+		if _, err := engine.GetNode("scratch", "web"); err == nil {
+			t.Error("Expected the node of the deleted graph not to be found")
+		}
+	})
+
+	t.Run("Transactions", func(t *testing.T) {
+		nodeVersion(t, "web")
+		err := engine.RunTransaction(func(tx storage.Transaction) error {
+			if err := tx.UpdateNode(graphID, &models.Node{ID: "web", Type: "component", Attributes: models.Attributes{"version": 3}}); err != nil {
+				return err
+			}
+			node, err := tx.GetNode(graphID, "web")
+			if err != nil {
+				return err
+			}
+			if node.Attributes["version"] != float64(3) {
+				t.Errorf("Expected the transaction to read its own write, got %v", node.Attributes["version"])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Transaction failed: %v", err)
+		}
+		if version := nodeVersion(t, "web"); version != float64(3) {
+			t.Errorf("Expected version 3 after commit, got %v", version)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		if err := engine.CreateNode(graphID, &models.Node{ID: "temp", Type: "service", ExpiresAt: &expiresAt}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		engine.ClearCache()
+		nodeVersion(t, "temp")
+		if stats := engine.CacheStats(); stats.Entries != 0 {
+			t.Errorf("Expected nodes with a TTL not to be cached, got %d entries", stats.Entries)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		backupDir := filepath.Join(os.TempDir(), "pathwaydb_cache_backup")
+		os.RemoveAll(backupDir)
+		defer os.RemoveAll(backupDir)
+		if err := os.MkdirAll(backupDir, 0755); err != nil {
+			t.Fatalf("Failed to create backup directory: %v", err)
+		}
+		if err := engine.Backup(backupDir); err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		// Restore writes below the engine, so it drops every cached record
+		nodeVersion(t, "web")
+		if err := engine.Restore(backupDir); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if stats := engine.CacheStats(); stats.Entries != 0 {
+			t.Errorf("Expected Restore to clear the cache, got %d entries", stats.Entries)
+		}
+		if version := nodeVersion(t, "web"); version != float64(3) {
+			t.Errorf("Expected version 3, got %v", version)
+		}
+	})
+
+	t.Run("Concurrent Reads", func(t *testing.T) {
+		const writes = 200
+		var wg sync.WaitGroup
+		done := make(chan struct{})
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					if _, err := engine.GetNode(graphID, "api"); err != nil {
+						t.Errorf("GetNode failed: %v", err)
+						return
+					}
+				}
+			}()
+		}
+		for i := 1; i <= writes; i++ {
+			if err := engine.UpdateNode(graphID, &models.Node{ID: "api", Type: "component", Attributes: models.Attributes{"version": i}}); err != nil {
+				t.Fatalf("UpdateNode failed: %v", err)
+			}
+		}
+		close(done)
+		wg.Wait()
+		if version := nodeVersion(t, "api"); version != float64(writes) {
+			t.Errorf("Expected the last written version %d, got %v", writes, version)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+		if _, err := handler.Handle("SYSTEM.CACHE", []string{"clear"}); err != nil {
+			t.Fatalf("SYSTEM.CACHE CLEAR failed: %v", err)
+		}
+		nodeVersion(t, "web")
+		nodeVersion(t, "web")
+
+		resp, err := handler.Handle("SYSTEM.CACHE", []string{"STATS"})
+		if err != nil {
+			t.Fatalf("SYSTEM.CACHE STATS failed: %v", err)
+		}
+		fields := map[string]string{}
+		for i := 0; i+1 < len(resp.ArrayValue); i += 2 {
+			fields[resp.ArrayValue[i]] = resp.ArrayValue[i+1]
+		}
+		stats := engine.CacheStats()
+		for field, expected := range map[string]string{
+			"enabled":     "1",
+			"entries":     "1",
+			"max_entries": "1000",
+			"hits":        fmt.Sprint(stats.Hits),
+			"misses":      fmt.Sprint(stats.Misses),
+		} {
+			if fields[field] != expected {
+				t.Errorf("Expected %s %s, got %q", field, expected, fields[field])
+			}
+		}
+
+		resp, err = handler.Handle("INFO", []string{"cache"})
+		if err != nil {
+			t.Fatalf("INFO cache failed: %v", err)
+		}
+		for _, line := range []string{"# Cache", "cache_enabled:1", "cache_entries:1", fmt.Sprintf("cache_hits:%d", stats.Hits)} {
+			if !strings.Contains(resp.StringValue, line) {
+				t.Errorf("Expected INFO cache to contain %q, got %q", line, resp.StringValue)
+			}
+		}
+
+		for _, args := range [][]string{{}, {"RESET"}, {"STATS", "extra"}} {
+			if _, err := handler.Handle("SYSTEM.CACHE", args); err == nil {
+				t.Errorf("Expected SYSTEM.CACHE %v to fail", args)
+			}
+		}
+	})
+}
+
+// TestRecordCacheLimits tests eviction and the disabled cache
+func TestRecordCacheLimits(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_cache_limits_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	open := func(t *testing.T, opts ...storage.Option) *storage.BadgerEngine {
+		t.Helper()
+		engine := storage.NewBadgerEngine(opts...)
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		return engine
+	}
+	engine := open(t)
+	if err := engine.CreateGraph(&models.Graph{ID: "limits", Name: "limits"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	const size = 64
+	for i := 0; i < size; i++ {
+		node := &models.Node{ID: models.NodeID(fmt.Sprintf("n%d", i)), Type: "service", Attributes: models.Attributes{"payload": strings.Repeat("x", 512)}}
+		if err := engine.CreateNode("limits", node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	readAll := func(t *testing.T, engine *storage.BadgerEngine) {
+		t.Helper()
+		for i := 0; i < size; i++ {
+			if _, err := engine.GetNode("limits", models.NodeID(fmt.Sprintf("n%d", i))); err != nil {
+				t.Fatalf("GetNode failed: %v", err)
+			}
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		readAll(t, engine)
+		if stats := engine.CacheStats(); stats != (storage.CacheStats{}) {
+			t.Errorf("Expected a disabled cache by default, got %+v", stats)
+		}
+		engine.Close()
+	})
+
+	t.Run("Entries", func(t *testing.T) {
+		engine := open(t, storage.WithRecordCache(16, 0))
+		defer engine.Close()
+		readAll(t, engine)
+		stats := engine.CacheStats()
+		if stats.Entries > 16 || stats.Evictions == 0 {
+			t.Errorf("Expected at most 16 entries and some evictions, got %+v", stats)
+		}
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		engine := open(t, storage.WithRecordCache(size, 16*1024))
+		defer engine.Close()
+		readAll(t, engine)
+		stats := engine.CacheStats()
+		if stats.Entries >= size || stats.Evictions == 0 || stats.Bytes == 0 {
+			t.Errorf("Expected the memory limit to evict entries, got %+v", stats)
+		}
+	})
+}
+
+// BenchmarkTraversalCache compares depth-first search over the sample
+// microservices graph, repeated to about 100k nodes, with the record cache
+// off and on
+func BenchmarkTraversalCache(b *testing.B) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_cache_bench")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	createMicroservicesGraph(b, engine, "template")
+	templateNodes, err := engine.ListNodes("template")
+	if err != nil {
+		b.Fatalf("Failed to list nodes: %v", err)
+	}
+	templateEdges, err := engine.ListEdges("template")
+	if err != nil {
+		b.Fatalf("Failed to list edges: %v", err)
+	}
+
+	// Every copy shares one logger and hangs off a root node, so a search
+	// from the root reaches the whole graph
+	graphID := models.GraphID("bench-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		b.Fatalf("Failed to create graph: %v", err)
+	}
+	if err := engine.CreateNode(graphID, &models.Node{ID: "root", Type: "application"}); err != nil {
+		b.Fatalf("Failed to create node: %v", err)
+	}
+	if err := engine.CreateNode(graphID, &models.Node{ID: "logger", Type: "library"}); err != nil {
+		b.Fatalf("Failed to create node: %v", err)
+	}
+	rename := func(id models.NodeID, copy int) models.NodeID {
+		if id == "logger" {
+			return id
+		}
+		return models.NodeID(fmt.Sprintf("%s-%d", id, copy))
+	}
+	const copies = 100000 / 11
+	const batch = 100
+	for start := 0; start < copies; start += batch {
+		err := engine.RunTransaction(func(tx storage.Transaction) error {
+			for copy := start; copy < start+batch && copy < copies; copy++ {
+				for _, node := range templateNodes {
+					if node.ID == "logger" {
+						continue
+					}
+					if err := tx.CreateNode(graphID, &models.Node{ID: rename(node.ID, copy), Type: node.Type, Attributes: node.Attributes}); err != nil {
+						return err
+					}
+				}
+				for _, edge := range templateEdges {
+					err := tx.CreateEdge(graphID, &models.Edge{
+						ID:         models.EdgeID(fmt.Sprintf("%s-%d", edge.ID, copy)),
+						Type:       edge.Type,
+						FromNodeID: rename(edge.FromNodeID, copy),
+						ToNodeID:   rename(edge.ToNodeID, copy),
+						Attributes: edge.Attributes,
+					})
+					if err != nil {
+						return err
+					}
+				}
+				err := tx.CreateEdge(graphID, &models.Edge{
+					ID:         models.EdgeID(fmt.Sprintf("root-%d", copy)),
+					Type:       "depends_on",
+					FromNodeID: "root",
+					ToNodeID:   rename("frontend", copy),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatalf("Failed to build graph: %v", err)
+		}
+	}
+	engine.Close()
+
+	options := &types.TraversalOptions{MaxDepth: -1, Direction: types.DirectionForward}
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("Cache=%v", cached), func(b *testing.B) {
+			var opts []storage.Option
+			if cached {
+				opts = append(opts, storage.WithRecordCache(500000, 0))
+			}
+			engine := storage.NewBadgerEngine(opts...)
+			if err := engine.Open(testPath); err != nil {
+				b.Fatalf("Failed to open database: %v", err)
+			}
+			defer engine.Close()
+			analyzer := analysis.NewGraphAnalyzer(engine)
+
+			// The first search fills the cache
+			if _, err := analyzer.DepthFirstSearch(graphID, "root", options); err != nil {
+				b.Fatalf("Traversal failed: %v", err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := analyzer.DepthFirstSearch(graphID, "root", options); err != nil {
+					b.Fatalf("Traversal failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestCanonicalJSON tests deterministic serialization and semantic attribute
+// comparison
+func TestCanonicalJSON(t *testing.T) {
+	// shuffledAttributes builds the same attributes inserting keys in a
+	// random order and mixing the Go types used for equal values
+	shuffledAttributes := func(r *rand.Rand) models.Attributes {
+		keys := []string{"zone", "owner", "replicas", "ratio", "tags", "limits", "enabled", "note"}
+		r.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+		attrs := models.Attributes{}
+		for _, key := range keys {
+			switch key {
+			case "replicas":
+				if r.Intn(2) == 0 {
+					attrs[key] = 3
+				} else {
+					attrs[key] = float64(3)
+				}
+			case "ratio":
+				attrs[key] = 0.25
+			case "tags":
+				attrs[key] = []interface{}{"a", "b"}
+			case "limits":
+				limits := map[string]interface{}{}
+				for _, k := range r.Perm(3) {
+					limits[fmt.Sprintf("l%d", k)] = int64(k * 100)
+				}
+				attrs[key] = limits
+			case "enabled":
+				attrs[key] = true
+			case "note":
+				attrs[key] = "<cpu & memory>"
+			default:
+				attrs[key] = key + "-value"
+			}
+		}
+		return attrs
+	}
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("ByteIdentical", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		var first []byte
+		for i := 0; i < 200; i++ {
+			node := &models.Node{ID: "n1", Type: "service", Attributes: shuffledAttributes(r), CreatedAt: createdAt, UpdatedAt: createdAt}
+			data, err := node.ToJSON()
+			if err != nil {
+				t.Fatalf("ToJSON failed: %v", err)
+			}
+			if first == nil {
+				first = data
+				continue
+			}
+			if !bytes.Equal(first, data) {
+				t.Fatalf("Expected identical bytes, got\n%s\n%s", first, data)
+			}
+		}
+		if bytes.Contains(first, []byte("\n")) || bytes.Contains(first, []byte(`": `)) {
+			t.Errorf("Expected no insignificant whitespace, got %s", first)
+		}
+	})
+
+	t.Run("NumberForms", func(t *testing.T) {
+		a, err := models.CanonicalJSON(map[string]interface{}{"v": 1, "w": 1e2})
+		if err != nil {
+			t.Fatalf("CanonicalJSON failed: %v", err)
+		}
+		b, err := models.CanonicalJSON(map[string]interface{}{"w": float64(100), "v": 1.0})
+		if err != nil {
+			t.Fatalf("CanonicalJSON failed: %v", err)
+		}
+		if string(a) != `{"v":1,"w":100}` || !bytes.Equal(a, b) {
+			t.Errorf("Expected a single number form, got %s and %s", a, b)
+		}
+
+		// Large integers keep their exact value
+		big, err := models.CanonicalJSON(map[string]interface{}{"id": int64(9007199254740993)})
+		if err != nil || string(big) != `{"id":9007199254740993}` {
+			t.Errorf("Expected exact integer, got %s (%v)", big, err)
+		}
+	})
+
+	t.Run("PermissiveRead", func(t *testing.T) {
+		node := &models.Node{}
+		data := []byte("{\n  \"type\": \"service\",\n  \"attributes\": {\"b\": 1.0, \"a\": [1, 2]},\n  \"id\": \"n1\"\n}")
+		if err := node.FromJSON(data); err != nil {
+			t.Fatalf("FromJSON failed: %v", err)
+		}
+		if node.ID != "n1" || node.Attributes["b"] != float64(1) {
+			t.Errorf("Expected parsed node, got %+v", node)
+		}
+	})
+
+	t.Run("DiffIgnoresRepresentation", func(t *testing.T) {
+		r := rand.New(rand.NewSource(2))
+		before := &models.SnapshotData{
+			Nodes: []*models.Node{{ID: "n1", Type: "service", Attributes: shuffledAttributes(r)}},
+			Edges: []*models.Edge{{ID: "e1", Type: "calls", FromNodeID: "n1", ToNodeID: "n1", Attributes: models.Attributes{"weight": 2}}},
+		}
+		after := &models.SnapshotData{
+			Nodes: []*models.Node{{ID: "n1", Type: "service", Attributes: shuffledAttributes(r)}},
+			Edges: []*models.Edge{{ID: "e1", Type: "calls", FromNodeID: "n1", ToNodeID: "n1", Attributes: models.Attributes{"weight": 2.0}}},
+		}
+		diff := analysis.DiffGraphData(before, after)
+		if len(diff.ChangedNodes) != 0 || len(diff.ChangedEdges) != 0 {
+			t.Errorf("Expected no changes, got %+v", diff)
+		}
+
+		after.Nodes[0].Attributes["replicas"] = 4
+		diff = analysis.DiffGraphData(before, after)
+		if len(diff.ChangedNodes) != 1 {
+			t.Errorf("Expected the changed node to be reported, got %+v", diff)
+		}
+	})
+
+	t.Run("FindByAttribute", func(t *testing.T) {
+		testPath := filepath.Join(os.TempDir(), "pathwaydb_canonical_test")
+		os.RemoveAll(testPath)
+		engine := storage.NewBadgerEngine()
+		defer func() {
+			engine.Close()
+			os.RemoveAll(testPath)
+		}()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+
+		graphID := models.GraphID("canonical-test-graph")
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		node := &models.Node{ID: "n1", Type: "service", Attributes: models.Attributes{
+			"replicas": 3,
+			"limits":   map[string]interface{}{"cpu": 2, "memory": "1Gi"},
+		}}
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+
+		nodes, err := engine.FindNodesByAttribute(graphID, "replicas", int64(3))
+		if err != nil || len(nodes) != 1 {
+			t.Errorf("Expected an integer to match the stored number, got %d (%v)", len(nodes), err)
+		}
+		nodes, err = engine.FindNodesByAttribute(graphID, "limits", map[string]interface{}{"memory": "1Gi", "cpu": 2.0})
+		if err != nil || len(nodes) != 1 {
+			t.Errorf("Expected an equal object to match, got %d (%v)", len(nodes), err)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/jobs"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestCentralityPaging tests paging through degree centrality with PAGE and
+// COUNT, and that cursors go stale when the graph changes
+func TestCentralityPaging(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_centrality_page_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	createLargeGraph(t, engine, "big", 10000)
+	handler := redis.NewCommandHandler(engine)
+
+	t.Run("PagesCoverRanking", func(t *testing.T) {
+		resp, err := handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.CENTRALITY failed: %v", err)
+		}
+		expected := resp.ArrayValue
+
+		var got []string
+		seen := make(map[string]bool)
+		cursor, pages := "0", 0
+		for {
+			resp, err := handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree", "PAGE", cursor, "COUNT", "1000"})
+			if err != nil {
+				t.Fatalf("Page %d failed: %v", pages, err)
+			}
+			pages++
+			cursor = resp.ArrayValue[0]
+			entries := resp.ArrayValue[1:]
+			if cursor != "0" && len(entries) != 2000 {
+				t.Fatalf("Expected 1000 pairs on page %d, got %d", pages, len(entries)/2)
+			}
+			for i := 0; i < len(entries); i += 2 {
+				if seen[entries[i]] {
+					t.Fatalf("Node %s returned twice", entries[i])
+				}
+				seen[entries[i]] = true
+			}
+			got = append(got, entries...)
+			if cursor == "0" {
+				break
+			}
+		}
+
+		if pages != 10 {
+			t.Errorf("Expected 10 pages, got %d", pages)
+		}
+		if len(seen) != 10000 {
+			t.Errorf("Expected 10000 distinct nodes, got %d", len(seen))
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Error("Expected the pages to concatenate to the unpaged ranking")
+		}
+	})
+
+	t.Run("CachedRanking", func(t *testing.T) {
+		first, err := handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree", "PAGE", "0", "COUNT", "10"})
+		if err != nil {
+			t.Fatalf("First page failed: %v", err)
+		}
+		again, err := handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree", "PAGE", "0", "COUNT", "10"})
+		if err != nil {
+			t.Fatalf("Second first page failed: %v", err)
+		}
+		if first.ArrayValue[0] != again.ArrayValue[0] {
+			t.Errorf("Expected an unchanged graph to reuse the cached ranking, got cursors %s and %s", first.ArrayValue[0], again.ArrayValue[0])
+		}
+
+		jobID, _, _ := strings.Cut(first.ArrayValue[0], ":")
+		resp, err := handler.Handle("ANALYSIS.RESULT", []string{jobID})
+		if err != nil {
+			t.Fatalf("ANALYSIS.RESULT failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 20000 {
+			t.Errorf("Expected the cached ranking of 10000 nodes, got %d values", len(resp.ArrayValue))
+		}
+	})
+
+	t.Run("StaleAfterMutation", func(t *testing.T) {
+		resp, err := handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree", "PAGE", "0", "COUNT", "1000"})
+		if err != nil {
+			t.Fatalf("First page failed: %v", err)
+		}
+		cursor := resp.ArrayValue[0]
+
+		before := engine.Generation("big")
+		if _, err := handler.Handle("NODE.CREATE", []string{"big", "late", "service"}); err != nil {
+			t.Fatalf("NODE.CREATE failed: %v", err)
+		}
+		if engine.Generation("big") <= before {
+			t.Fatal("Expected NODE.CREATE to advance the graph generation")
+		}
+
+		_, err = handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree", "PAGE", cursor, "COUNT", "1000"})
+		if !errors.Is(err, commands.ErrCursorStale) || !strings.HasPrefix(err.Error(), "CURSORSTALE ") {
+			t.Fatalf("Expected a CURSORSTALE error, got %v", err)
+		}
+
+		// Restarting ranks the changed graph
+		resp, err = handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree", "PAGE", "0", "COUNT", "1000"})
+		if err != nil {
+			t.Fatalf("Restarted page failed: %v", err)
+		}
+		if resp.ArrayValue[0] == cursor {
+			t.Error("Expected a new cursor after the graph changed")
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			args []string
+		}{
+			{"PageRank", []string{"big", "pagerank", "PAGE", "0"}},
+			{"WithTop", []string{"big", "degree", "TOP", "5", "PAGE", "0"}},
+			{"WithNode", []string{"big", "degree", "node-000001", "PAGE", "0"}},
+			{"CountWithoutPage", []string{"big", "degree", "COUNT", "10"}},
+			{"ZeroCount", []string{"big", "degree", "PAGE", "0", "COUNT", "0"}},
+			{"MalformedCursor", []string{"big", "degree", "PAGE", "abc"}},
+			{"UnknownCursor", []string{"big", "degree", "PAGE", "job-0:10"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := handler.Handle("ANALYSIS.CENTRALITY", tt.args); err == nil {
+					t.Errorf("Expected an error for %v", tt.args)
+				}
+			})
+		}
+
+		resp, err := handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree", "PAGE", "0", "COUNT", "10"})
+		if err != nil {
+			t.Fatalf("First page failed: %v", err)
+		}
+		_, err = handler.Handle("ANALYSIS.CENTRALITY", []string{"big", "degree", "DIRECTION", "out", "PAGE", resp.ArrayValue[0]})
+		if err == nil || errors.Is(err, commands.ErrCursorStale) {
+			t.Errorf("Expected a cursor from another query to be rejected, got %v", err)
+		}
+	})
+}
+
+// TestCentralityPagingKeepsJobResults tests that rankings cached for PAGE
+// cursors do not evict the results of submitted jobs
+func TestCentralityPagingKeepsJobResults(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_centrality_page_jobs_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	createLargeGraph(t, engine, "small", 100)
+	handler := redis.NewCommandHandler(engine, redis.WithJobConfig(jobs.Config{MaxResults: 1}))
+
+	resp, err := handler.Handle("ANALYSIS.SUBMIT", []string{"CENTRALITY", "small", "degree", "TOP", "3"})
+	if err != nil {
+		t.Fatalf("ANALYSIS.SUBMIT failed: %v", err)
+	}
+	jobID := resp.StringValue
+	waitForState(t, handler, jobID, jobs.StateDone)
+
+	// Each direction caches another ranking
+	for _, direction := range []string{"in", "out", "both"} {
+		if _, err := handler.Handle("ANALYSIS.CENTRALITY", []string{"small", "degree", "DIRECTION", direction, "PAGE", "0", "COUNT", "10"}); err != nil {
+			t.Fatalf("ANALYSIS.CENTRALITY PAGE failed: %v", err)
+		}
+	}
+
+	resp, err = handler.Handle("ANALYSIS.RESULT", []string{jobID})
+	if err != nil {
+		t.Fatalf("Expected the job result to outlive the cached rankings, got %v", err)
+	}
+	if len(resp.ArrayValue) != 6 {
+		t.Errorf("Expected the top 3 nodes and their scores, got %v", resp.ArrayValue)
+	}
+}
@@ -0,0 +1,300 @@
+package tests
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestPageRankAndEigenvector tests PageRank and eigenvector centrality
+func TestPageRankAndEigenvector(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_centrality_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("centrality-test-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"a", "b", "c", "d", "e"} {
+		if err := engine.CreateNode(graphID, &models.Node{ID: id, Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node %s: %v", id, err)
+		}
+	}
+	// e has no outgoing edges, so its rank is redistributed
+	for _, edge := range []*models.Edge{
+		{ID: "a-b", FromNodeID: "a", ToNodeID: "b", Type: "calls"},
+		{ID: "a-c", FromNodeID: "a", ToNodeID: "c", Type: "calls"},
+		{ID: "b-c", FromNodeID: "b", ToNodeID: "c", Type: "calls"},
+		{ID: "c-a", FromNodeID: "c", ToNodeID: "a", Type: "calls"},
+		{ID: "d-c", FromNodeID: "d", ToNodeID: "c", Type: "calls"},
+		{ID: "c-e", FromNodeID: "c", ToNodeID: "e", Type: "calls"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	analysisCommands := commands.NewAnalysisCommands(engine)
+
+	t.Run("PageRankReference", func(t *testing.T) {
+		scores, err := analyzer.CalculatePageRank(graphID, 0.85, 200, 1e-10, types.DirectionForward)
+		if err != nil {
+			t.Fatalf("CalculatePageRank failed: %v", err)
+		}
+		// Reference values from an independent power-iteration implementation
+		expected := map[models.NodeID]float64{
+			"a": 0.214201,
+			"b": 0.157450,
+			"c": 0.347734,
+			"d": 0.066414,
+			"e": 0.214201,
+		}
+		sum := 0.0
+		for id, want := range expected {
+			if got := scores[id]; math.Abs(got-want) > 1e-5 {
+				t.Errorf("Expected PageRank %f for %s, got %f", want, id, got)
+			}
+			sum += scores[id]
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("Expected PageRank scores to sum to 1, got %f", sum)
+		}
+	})
+
+	t.Run("PageRankCommand", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("CENTRALITY", []string{string(graphID), "pagerank", "TOP", "3", `{"damping":0.85,"iterations":200,"tolerance":1e-10}`})
+		if err != nil {
+			t.Fatalf("ANALYSIS.CENTRALITY pagerank failed: %v", err)
+		}
+		expected := []string{"c", "0.347734", "a", "0.214201", "e", "0.214201"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		resp, err = analysisCommands.Handle("CENTRALITY", []string{string(graphID), "pagerank", "d", `{"iterations":200,"tolerance":1e-10}`})
+		if err != nil {
+			t.Fatalf("ANALYSIS.CENTRALITY pagerank failed: %v", err)
+		}
+		if expected := []string{"d", "0.066414"}; !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		args := []string{string(graphID), "pagerank"}
+		first, err := analysisCommands.Handle("CENTRALITY", args)
+		if err != nil {
+			t.Fatalf("ANALYSIS.CENTRALITY pagerank failed: %v", err)
+		}
+		for run := 0; run < 5; run++ {
+			again, err := analysisCommands.Handle("CENTRALITY", args)
+			if err != nil {
+				t.Fatalf("ANALYSIS.CENTRALITY pagerank failed: %v", err)
+			}
+			if !reflect.DeepEqual(first.ArrayValue, again.ArrayValue) {
+				t.Fatalf("Expected identical results across runs, got %v and %v", first.ArrayValue, again.ArrayValue)
+			}
+		}
+	})
+
+	t.Run("NotConverged", func(t *testing.T) {
+		scores, err := analyzer.CalculatePageRank(graphID, 0.85, 1, 1e-12, types.DirectionForward)
+		if !errors.Is(err, analysis.ErrNotConverged) {
+			t.Fatalf("Expected ErrNotConverged, got %v", err)
+		}
+		if len(scores) != 5 {
+			t.Errorf("Expected a best estimate for all nodes, got %v", scores)
+		}
+
+		resp, err := analysisCommands.Handle("CENTRALITY", []string{string(graphID), "pagerank", `{"iterations":1}`})
+		if err != nil {
+			t.Fatalf("Expected a result with a warning, got error: %v", err)
+		}
+		values := resp.ArrayValue
+		if len(values) != 12 || values[10] != "warning" {
+			t.Errorf("Expected 5 scores followed by a warning, got %v", values)
+		}
+	})
+
+	t.Run("Eigenvector", func(t *testing.T) {
+		scores, err := analyzer.CalculateEigenvectorCentrality(graphID, 1000, 1e-9, types.DirectionBoth)
+		if err != nil {
+			t.Fatalf("CalculateEigenvectorCentrality failed: %v", err)
+		}
+		// c is connected to every other node
+		for _, id := range []models.NodeID{"a", "b", "d", "e"} {
+			if scores["c"] <= scores[id] {
+				t.Errorf("Expected c to outrank %s, got %f <= %f", id, scores["c"], scores[id])
+			}
+		}
+		if math.Abs(scores["d"]-scores["e"]) > 1e-6 {
+			t.Errorf("Expected symmetric nodes d and e to score equally, got %f and %f", scores["d"], scores["e"])
+		}
+
+		resp, err := analysisCommands.Handle("CENTRALITY", []string{string(graphID), "eigenvector", "DIRECTION", "both", "TOP", "1"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.CENTRALITY eigenvector failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 2 || resp.ArrayValue[0] != "c" {
+			t.Errorf("Expected c to rank first, got %v", resp.ArrayValue)
+		}
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		if _, err := analysisCommands.Handle("CENTRALITY", []string{string(graphID), "pagerank", `{"damping":1.5}`}); err == nil {
+			t.Error("Expected error for damping outside (0, 1)")
+		}
+		if _, err := analysisCommands.Handle("CENTRALITY", []string{string(graphID), "pagerank", `{"alpha":0.5}`}); err == nil {
+			t.Error("Expected error for an unknown parameter")
+		}
+	})
+}
+
+// TestBetweennessAndCloseness tests betweenness and harmonic closeness
+// centrality against values worked out by hand
+func TestBetweennessAndCloseness(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_shortest_centrality_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	// A diamond a -> b|c -> d leading to e, and f on its own. The parallel
+	// edge and the self-loop add no shortest paths.
+	graphID := models.GraphID("shortest-centrality")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"a", "b", "c", "d", "e", "f"} {
+		if err := engine.CreateNode(graphID, &models.Node{ID: id, Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node %s: %v", id, err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "a-b", FromNodeID: "a", ToNodeID: "b", Type: "calls"},
+		{ID: "a-c", FromNodeID: "a", ToNodeID: "c", Type: "calls"},
+		{ID: "b-d", FromNodeID: "b", ToNodeID: "d", Type: "calls"},
+		{ID: "b-d-2", FromNodeID: "b", ToNodeID: "d", Type: "reads"},
+		{ID: "c-d", FromNodeID: "c", ToNodeID: "d", Type: "calls"},
+		{ID: "d-d", FromNodeID: "d", ToNodeID: "d", Type: "retries"},
+		{ID: "d-e", FromNodeID: "d", ToNodeID: "e", Type: "calls"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	analysisCommands := commands.NewAnalysisCommands(engine)
+
+	check := func(t *testing.T, name string, scores map[models.NodeID]float64, expected map[models.NodeID]float64) {
+		t.Helper()
+		if len(scores) != len(expected) {
+			t.Errorf("Expected %s scores for %d nodes, got %v", name, len(expected), scores)
+		}
+		for id, want := range expected {
+			if got, ok := scores[id]; !ok || math.IsNaN(got) || math.Abs(got-want) > 1e-6 {
+				t.Errorf("Expected %s %f for %s, got %f", name, want, id, got)
+			}
+		}
+	}
+
+	t.Run("Betweenness", func(t *testing.T) {
+		scores, err := analyzer.CalculateBetweennessCentrality(graphID, nil, types.DirectionForward)
+		if err != nil {
+			t.Fatalf("CalculateBetweennessCentrality failed: %v", err)
+		}
+		// b and c each carry half of a's paths to d and e; d carries every
+		// path to e
+		check(t, "forward betweenness", scores, map[models.NodeID]float64{"a": 0, "b": 1, "c": 1, "d": 3, "e": 0, "f": 0})
+
+		// Undirected, a and d also each carry half of the paths between b
+		// and c, and each pair counts once
+		scores, err = analyzer.CalculateBetweennessCentrality(graphID, nil, types.DirectionBoth)
+		if err != nil {
+			t.Fatalf("CalculateBetweennessCentrality failed: %v", err)
+		}
+		check(t, "undirected betweenness", scores, map[models.NodeID]float64{"a": 0.5, "b": 1, "c": 1, "d": 3.5, "e": 0, "f": 0})
+
+		node := models.NodeID("d")
+		scores, err = analyzer.CalculateBetweennessCentrality(graphID, &node, types.DirectionBackward)
+		if err != nil {
+			t.Fatalf("CalculateBetweennessCentrality failed: %v", err)
+		}
+		check(t, "backward betweenness", scores, map[models.NodeID]float64{"d": 3})
+	})
+
+	t.Run("Closeness", func(t *testing.T) {
+		// Unreachable nodes add nothing, so f and e score 0 forward rather
+		// than NaN
+		scores, err := analyzer.CalculateClosenessCentrality(graphID, nil, types.DirectionForward)
+		if err != nil {
+			t.Fatalf("CalculateClosenessCentrality failed: %v", err)
+		}
+		check(t, "forward closeness", scores, map[models.NodeID]float64{"a": (1 + 1 + 1.0/2 + 1.0/3) / 5, "b": 0.3, "c": 0.3, "d": 0.2, "e": 0, "f": 0})
+
+		scores, err = analyzer.CalculateClosenessCentrality(graphID, nil, types.DirectionBoth)
+		if err != nil {
+			t.Fatalf("CalculateClosenessCentrality failed: %v", err)
+		}
+		check(t, "undirected closeness", scores, map[models.NodeID]float64{"a": (1 + 1 + 1.0/2 + 1.0/3) / 5, "b": 0.6, "c": 0.6, "d": 0.7, "e": (1 + 1.0/2 + 1.0/2 + 1.0/3) / 5, "f": 0})
+
+		node := models.NodeID("e")
+		scores, err = analyzer.CalculateClosenessCentrality(graphID, &node, types.DirectionBackward)
+		if err != nil {
+			t.Fatalf("CalculateClosenessCentrality failed: %v", err)
+		}
+		check(t, "backward closeness", scores, map[models.NodeID]float64{"e": (1 + 1.0/2 + 1.0/2 + 1.0/3) / 5})
+
+		missing := models.NodeID("missing")
+		if _, err := analyzer.CalculateClosenessCentrality(graphID, &missing, types.DirectionBoth); !errors.Is(err, storage.ErrNodeNotFound) {
+			t.Errorf("Expected ErrNodeNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("CENTRALITY", []string{string(graphID), "betweenness"})
+		expected := []string{"d", "3.500000", "b", "1.000000", "c", "1.000000", "a", "0.500000", "e", "0.000000", "f", "0.000000"}
+		if err != nil || !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+
+		resp, err = analysisCommands.Handle("CENTRALITY", []string{string(graphID), "closeness", "DIRECTION", "out", "TOP", "2"})
+		if expected := []string{"a", "0.566667", "b", "0.300000"}; err != nil || !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+
+		resp, err = analysisCommands.Handle("CENTRALITY", []string{string(graphID), "closeness", "d"})
+		if expected := []string{"d", "0.700000"}; err != nil || !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+
+		if _, err := analysisCommands.Handle("CENTRALITY", []string{string(graphID), "betweenness", "missing"}); err == nil {
+			t.Error("Expected a missing node to fail")
+		}
+	})
+}
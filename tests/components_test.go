@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestComponents tests ComputeComponents and ANALYSIS.COMPONENTS
+func TestComponents(t *testing.T) {
+	te := setupTestAnalysisEngine(t)
+	defer te.cleanup()
+	te.createSampleGraph()
+
+	// The sample graph is connected through depends_on edges. Add a second
+	// edge type that only links app, queue and cache.
+	for _, edge := range []*models.Edge{
+		{ID: "app-queue", Type: "publishes", FromNodeID: "app", ToNodeID: "queue"},
+		{ID: "queue-cache", Type: "publishes", FromNodeID: "queue", ToNodeID: "cache"},
+	} {
+		if err := te.engine.CreateEdge(te.graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	analysisCommands := commands.NewAnalysisCommands(te.engine)
+
+	t.Run("Unfiltered", func(t *testing.T) {
+		count, err := te.analyzer.GetConnectedComponentCount(te.graphID, nil)
+		if err != nil || count != 1 {
+			t.Errorf("Expected 1 component, got %d (%v)", count, err)
+		}
+	})
+
+	t.Run("EdgeTypeFilter", func(t *testing.T) {
+		components, err := te.analyzer.ComputeComponents(te.graphID, &types.TraversalOptions{EdgeTypes: []models.EdgeType{"publishes"}})
+		if err != nil {
+			t.Fatalf("ComputeComponents failed: %v", err)
+		}
+		// Labels follow the smallest node ID of each component
+		expected := map[models.NodeID]int{"app": 0, "cache": 0, "queue": 0, "auth": 1, "db": 2, "logger": 3}
+		if !reflect.DeepEqual(components, expected) {
+			t.Errorf("Expected %v, got %v", expected, components)
+		}
+
+		count, err := te.analyzer.GetConnectedComponentCount(te.graphID, &types.TraversalOptions{EdgeTypes: []models.EdgeType{"publishes"}})
+		if err != nil || count != 4 {
+			t.Errorf("Expected the count to honor the filter and return 4, got %d (%v)", count, err)
+		}
+	})
+
+	t.Run("LabelsFormat", func(t *testing.T) {
+		expected := []string{
+			"app:application:0",
+			"auth:service:1",
+			"cache:cache:0",
+			"db:database:2",
+			"logger:library:3",
+			"queue:service:0",
+		}
+		for run := 0; run < 5; run++ {
+			resp, err := analysisCommands.Handle("COMPONENTS", []string{string(te.graphID), "EDGETYPES", "publishes"})
+			if err != nil {
+				t.Fatalf("ANALYSIS.COMPONENTS failed: %v", err)
+			}
+			if !reflect.DeepEqual(resp.ArrayValue, expected) {
+				t.Fatalf("Run %d: expected %v, got %v", run, expected, resp.ArrayValue)
+			}
+		}
+	})
+
+	t.Run("GroupsFormat", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("COMPONENTS", []string{string(te.graphID), "EDGETYPES", "publishes", "FORMAT", "groups"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.COMPONENTS failed: %v", err)
+		}
+		expected := []interface{}{
+			[]string{"app", "cache", "queue"},
+			[]string{"auth"},
+			[]string{"db"},
+			[]string{"logger"},
+		}
+		if !reflect.DeepEqual(resp.NestedArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.NestedArrayValue)
+		}
+	})
+
+	t.Run("InsertionOrder", func(t *testing.T) {
+		// The same graph built in reverse order gets the same labels
+		graphID := models.GraphID("components-reversed")
+		if err := te.engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		nodes, err := te.engine.ListNodes(te.graphID)
+		if err != nil {
+			t.Fatalf("Failed to list nodes: %v", err)
+		}
+		for i := len(nodes) - 1; i >= 0; i-- {
+			if err := te.engine.CreateNode(graphID, &models.Node{ID: nodes[i].ID, Type: nodes[i].Type}); err != nil {
+				t.Fatalf("Failed to create node: %v", err)
+			}
+		}
+		edges, err := te.engine.ListEdges(te.graphID)
+		if err != nil {
+			t.Fatalf("Failed to list edges: %v", err)
+		}
+		for i := len(edges) - 1; i >= 0; i-- {
+			edge := &models.Edge{ID: edges[i].ID, Type: edges[i].Type, FromNodeID: edges[i].FromNodeID, ToNodeID: edges[i].ToNodeID}
+			if err := te.engine.CreateEdge(graphID, edge); err != nil {
+				t.Fatalf("Failed to create edge: %v", err)
+			}
+		}
+
+		options := &types.TraversalOptions{EdgeTypes: []models.EdgeType{"publishes"}}
+		original, err := te.analyzer.ComputeComponents(te.graphID, options)
+		if err != nil {
+			t.Fatalf("ComputeComponents failed: %v", err)
+		}
+		reversed, err := te.analyzer.ComputeComponents(graphID, options)
+		if err != nil {
+			t.Fatalf("ComputeComponents failed: %v", err)
+		}
+		if !reflect.DeepEqual(original, reversed) {
+			t.Errorf("Expected identical labels, got %v and %v", original, reversed)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := analysisCommands.Handle("COMPONENTS", []string{"missing-graph"}); err == nil {
+			t.Error("Expected error for a missing graph")
+		}
+		if _, err := analysisCommands.Handle("COMPONENTS", []string{string(te.graphID), "FORMAT", "colors"}); err == nil {
+			t.Error("Expected error for an unknown format")
+		}
+	})
+}
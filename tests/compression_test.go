@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestRecordCompression tests that node and edge records above the
+// threshold are stored compressed and read back transparently, alongside
+// records written before compression was enabled
+func TestRecordCompression(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_compression_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	// A node written before compression is enabled
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := engine.CreateGraph(&models.Graph{ID: "cluster", Name: "cluster"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	manifest := strings.Repeat(`{"apiVersion":"apps/v1","kind":"Deployment","spec":{"replicas":3}}`, 50)
+	legacy := &models.Node{ID: "legacy", Type: "deployment", Attributes: models.Attributes{"kind": "Deployment", "manifest": manifest}}
+	if err := engine.CreateNode("cluster", legacy); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	engine.Close()
+
+	engine = storage.NewBadgerEngine(storage.WithCompressAbove(512))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	// stored returns the raw value of a key
+	stored := func(t *testing.T, key []byte) []byte {
+		t.Helper()
+		var value []byte
+		err := engine.RunReadOnlyTransaction(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
+			if err != nil {
+				return err
+			}
+			value, err = item.ValueCopy(nil)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", key, err)
+		}
+		return value
+	}
+	compressed := func(value []byte) bool {
+		return len(value) > 0 && value[0] == models.CompressedRecord
+	}
+
+	large := models.Attributes{"kind": "Deployment", "manifest": manifest}
+	for _, node := range []*models.Node{
+		{ID: "web", Type: "deployment", Attributes: large},
+		{ID: "small", Type: "service", Attributes: models.Attributes{"kind": "Service"}},
+	} {
+		if err := engine.CreateNode("cluster", node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	edge := &models.Edge{ID: "exposes", Type: "exposes", FromNodeID: "small", ToNodeID: "web", Attributes: models.Attributes{"manifest": manifest}}
+	if err := engine.CreateEdge("cluster", edge); err != nil {
+		t.Fatalf("Failed to create edge: %v", err)
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		if value := stored(t, utils.EncodeNodeKey("cluster", "web")); !compressed(value) || len(value) >= len(manifest) {
+			t.Errorf("Expected the large node to be stored compressed, got %d bytes", len(value))
+		}
+		if value := stored(t, utils.EncodeEdgeKey("cluster", "exposes")); !compressed(value) {
+			t.Error("Expected the large edge to be stored compressed")
+		}
+		if value := stored(t, utils.EncodeNodeKey("cluster", "small")); compressed(value) || value[0] != '{' {
+			t.Errorf("Expected the small node to be stored as JSON, got %q", value)
+		}
+
+		node, err := engine.GetNode("cluster", "web")
+		if err != nil || !reflect.DeepEqual(node.Attributes, large) {
+			t.Errorf("Expected the large node's attributes back, got %v", err)
+		}
+		small, err := engine.GetNode("cluster", "small")
+		if err != nil || small.Attributes["kind"] != "Service" {
+			t.Errorf("Expected the small node back, got %+v, %v", small, err)
+		}
+		stored, err := engine.GetEdge("cluster", "exposes")
+		if err != nil || stored.Attributes["manifest"] != manifest {
+			t.Errorf("Expected the large edge's attributes back, got %v", err)
+		}
+		nodes, err := engine.ListNodes("cluster")
+		if err != nil || len(nodes) != 3 {
+			t.Errorf("Expected 3 nodes listed, got %d, %v", len(nodes), err)
+		}
+	})
+
+	t.Run("Legacy Values", func(t *testing.T) {
+		key := utils.EncodeNodeKey("cluster", "legacy")
+		if compressed(stored(t, key)) {
+			t.Fatal("Expected the node written before compression to be stored as JSON")
+		}
+		node, err := engine.GetNode("cluster", "legacy")
+		if err != nil || !reflect.DeepEqual(node.Attributes, legacy.Attributes) {
+			t.Fatalf("Expected the legacy node back, got %v", err)
+		}
+
+		// Rewriting it compresses it
+		node.Attributes["replicas"] = 5.0
+		if err := engine.UpdateNode("cluster", node); err != nil {
+			t.Fatalf("Failed to update node: %v", err)
+		}
+		if !compressed(stored(t, key)) {
+			t.Error("Expected the updated node to be stored compressed")
+		}
+		if node, err := engine.GetNode("cluster", "legacy"); err != nil || node.Attributes["replicas"] != 5.0 {
+			t.Errorf("Expected the updated node back, got %v", err)
+		}
+	})
+
+	t.Run("Find By Attribute", func(t *testing.T) {
+		nodes, err := engine.FindNodesByAttribute("cluster", "kind", "Deployment")
+		if err != nil {
+			t.Fatalf("Failed to find nodes: %v", err)
+		}
+		var ids []string
+		for _, node := range nodes {
+			ids = append(ids, string(node.ID))
+		}
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, []string{"legacy", "web"}) {
+			t.Errorf("Expected the compressed nodes to match, got %v", ids)
+		}
+		if nodes, err := engine.FindNodesByAttribute("cluster", "manifest", manifest); err != nil || len(nodes) != 2 {
+			t.Errorf("Expected both manifests to match, got %d, %v", len(nodes), err)
+		}
+		// NODE.FILTER replies with the ID, type and attributes of each node
+		resp, err := handler.Handle("NODE.FILTER", []string{"cluster", "kind", "Deployment"})
+		if err != nil || len(resp.ArrayValue) != 6 || resp.ArrayValue[0] != "legacy" || resp.ArrayValue[3] != "web" {
+			t.Errorf("Expected NODE.FILTER to match the compressed nodes, got %v", err)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		stats := engine.CompressionStats()
+		if !stats.Enabled || stats.Above != 512 || stats.Compressed != 3 || stats.BytesSaved <= 0 {
+			t.Errorf("Expected the node, edge and update counted, got %+v", stats)
+		}
+		resp, err := handler.Handle("INFO", []string{"compression"})
+		if err != nil {
+			t.Fatalf("INFO compression failed: %v", err)
+		}
+		for _, line := range []string{"# Compression", "compression_enabled:1", "compression_above_bytes:512", "compression_values:3", fmt.Sprintf("compression_bytes_saved:%d", stats.BytesSaved)} {
+			if !strings.Contains(resp.StringValue, line) {
+				t.Errorf("Expected INFO compression to contain %q, got %q", line, resp.StringValue)
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestTableFormats tests the csv and tsv formats of list and analysis
+// commands by parsing their output back with encoding/csv
+func TestTableFormats(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_csv_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	// table runs a command and parses its table, tab-separated for tsv
+	table := func(t *testing.T, separator rune, args ...string) [][]string {
+		t.Helper()
+		resp, err := handler.Handle(args[0], args[1:])
+		if err != nil {
+			t.Fatalf("%s failed: %v", strings.Join(args, " "), err)
+		}
+		reader := csv.NewReader(strings.NewReader(resp.StringValue))
+		reader.Comma = separator
+		records, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", resp.StringValue, err)
+		}
+		return records
+	}
+
+	if err := engine.CreateGraph(&models.Graph{ID: "inventory", Name: "inventory", DisplayNodeAttr: "name"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: `svc,"a"`, Type: "service", Attributes: models.Attributes{"name": "Billing, \"core\"\nteam"}},
+		{ID: "db", Type: "database"},
+	} {
+		if err := engine.CreateNode("inventory", node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	edge := &models.Edge{ID: "reads", Type: "reads", FromNodeID: `svc,"a"`, ToNodeID: "db", Attributes: models.Attributes{"note": "a\tb"}}
+	if err := engine.CreateEdge("inventory", edge); err != nil {
+		t.Fatalf("Failed to create edge: %v", err)
+	}
+
+	t.Run("Node List", func(t *testing.T) {
+		expected := [][]string{
+			{"id", "type", "label", "attributes"},
+			{"db", "database", "", "{}"},
+			{`svc,"a"`, "service", "Billing, \"core\"\nteam", `{"name":"Billing, \"core\"\nteam"}`},
+		}
+		for _, tc := range []struct {
+			format    string
+			separator rune
+		}{{"csv", ','}, {"TSV", '\t'}} {
+			if records := table(t, tc.separator, "NODE.LIST", "inventory", "LABELS", "FORMAT", tc.format); !reflect.DeepEqual(records, expected) {
+				t.Errorf("Expected %q as %s, got %q", expected, tc.format, records)
+			}
+		}
+
+		records := table(t, ',', "NODE.LIST", "inventory", "AGE", "FORMAT", "csv")
+		if !reflect.DeepEqual(records[0], []string{"id", "type", "updated_at", "attributes"}) || len(records) != 3 {
+			t.Fatalf("Expected an updated_at column, got %q", records)
+		}
+		// Nodes created without a timestamp report it as unknown
+		if updatedAt := records[1][2]; updatedAt != "unknown" {
+			if _, err := time.Parse(time.RFC3339, updatedAt); err != nil {
+				t.Errorf("Expected an RFC3339 update time, got %q", updatedAt)
+			}
+		}
+	})
+
+	t.Run("Edge List", func(t *testing.T) {
+		expected := [][]string{
+			{"id", "type", "from", "to", "attributes"},
+			{"reads", "reads", `svc,"a"`, "db", `{"note":"a\tb"}`},
+		}
+		if records := table(t, '\t', "EDGE.LIST", "inventory", "FORMAT", "tsv"); !reflect.DeepEqual(records, expected) {
+			t.Errorf("Expected %q, got %q", expected, records)
+		}
+	})
+
+	t.Run("Node Filter", func(t *testing.T) {
+		expected := [][]string{
+			{"id", "type", "attributes"},
+			{`svc,"a"`, "service", `{"name":"Billing, \"core\"\nteam"}`},
+		}
+		records := table(t, ',', "NODE.FILTER", "inventory", "name", "Billing, \"core\"\nteam", "FORMAT", "csv")
+		if !reflect.DeepEqual(records, expected) {
+			t.Errorf("Expected %q, got %q", expected, records)
+		}
+
+		// A lone FORMAT pair is an attribute filter
+		resp, err := handler.Handle("NODE.FILTER", []string{"inventory", "FORMAT", "csv"})
+		if err != nil || len(resp.ArrayValue) != 0 {
+			t.Errorf("Expected no nodes with attribute FORMAT, got %+v, %v", resp, err)
+		}
+	})
+
+	t.Run("Centrality", func(t *testing.T) {
+		expected := [][]string{{"node", "score"}, {"db", "1"}, {`svc,"a"`, "1"}}
+		if records := table(t, ',', "ANALYSIS.CENTRALITY", "inventory", "degree", "FORMAT", "csv"); !reflect.DeepEqual(records, expected) {
+			t.Errorf("Expected %q, got %q", expected, records)
+		}
+		records := table(t, ',', "ANALYSIS.CENTRALITY", "inventory", "pagerank", "TOP", "1", "FORMAT", "csv")
+		if len(records) != 2 || records[1][0] != "db" {
+			t.Errorf("Expected db to rank first, got %q", records)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"NODE.LIST", "inventory", "FORMAT", "json"},
+			{"NODE.LIST", "inventory", "FORMAT"},
+			{"EDGE.LIST", "inventory", "FORMAT", "xml"},
+			{"EDGE.LIST", "inventory", "csv"},
+			{"ANALYSIS.CENTRALITY", "inventory", "degree", "PAGE", "0", "FORMAT", "csv"},
+		} {
+			if _, err := handler.Handle(args[0], args[1:]); err == nil {
+				t.Errorf("Expected %v to fail", args)
+			}
+		}
+	})
+}
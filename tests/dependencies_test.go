@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestDependenciesWithDepth tests listing dependencies and dependents with
+// their depth, sorted by depth and node ID, on the microservices graph
+func TestDependenciesWithDepth(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_dependencies_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	graphID := models.GraphID("microservices")
+	createMicroservicesGraph(t, engine, graphID)
+	analyzer := analysis.NewGraphAnalyzer(engine)
+
+	// entries writes entries as id:depth
+	entries := func(found []types.DependencyEntry) []string {
+		var result []string
+		for _, entry := range found {
+			if entry.Direct != (entry.Depth == 1) {
+				t.Errorf("Expected %s at depth %d to have Direct %v", entry.Node.ID, entry.Depth, entry.Depth == 1)
+			}
+			result = append(result, fmt.Sprintf("%s:%d", entry.Node.ID, entry.Depth))
+		}
+		return result
+	}
+
+	t.Run("Depths", func(t *testing.T) {
+		auth, err := analyzer.GetDependenciesWithDepth(graphID, "auth-service", nil)
+		if err != nil {
+			t.Fatalf("GetDependenciesWithDepth failed: %v", err)
+		}
+		if expected := []string{"logger:1", "redis-cache:1", "user-db:1"}; !reflect.DeepEqual(entries(auth), expected) {
+			t.Errorf("Expected %v, got %v", expected, entries(auth))
+		}
+
+		// logger is direct for auth-service but transitive for the gateway
+		// and frontend, reached at its shortest depth
+		frontend, err := analyzer.GetDependenciesWithDepth(graphID, "frontend", nil)
+		if err != nil {
+			t.Fatalf("GetDependenciesWithDepth failed: %v", err)
+		}
+		expected := []string{
+			"api-gateway:1",
+			"auth-service:2", "order-service:2", "user-service:2",
+			"logger:3", "notification-service:3", "order-db:3", "payment-service:3", "redis-cache:3", "user-db:3",
+			"message-queue:4",
+		}
+		if !reflect.DeepEqual(entries(frontend), expected) {
+			t.Errorf("Expected %v, got %v", expected, entries(frontend))
+		}
+		gateway, err := analyzer.GetDependenciesWithDepth(graphID, "api-gateway", nil)
+		if err != nil || len(gateway) == 0 || entries(gateway)[3] != "logger:2" {
+			t.Errorf("Expected logger at depth 2 for api-gateway, got %v, %v", entries(gateway), err)
+		}
+
+		// The order is the same on every call
+		for i := 0; i < 5; i++ {
+			again, err := analyzer.GetDependenciesWithDepth(graphID, "frontend", nil)
+			if err != nil || !reflect.DeepEqual(entries(again), expected) {
+				t.Fatalf("Expected the same order on every call, got %v, %v", entries(again), err)
+			}
+		}
+
+		dependents, err := analyzer.GetDependentsWithDepth(graphID, "logger", nil)
+		if err != nil {
+			t.Fatalf("GetDependentsWithDepth failed: %v", err)
+		}
+		expected = []string{
+			"auth-service:1", "notification-service:1", "order-service:1", "payment-service:1", "user-service:1",
+			"api-gateway:2", "frontend:3",
+		}
+		if !reflect.DeepEqual(entries(dependents), expected) {
+			t.Errorf("Expected %v, got %v", expected, entries(dependents))
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		// Nodes of other types are walked through but not listed
+		options := &types.TraversalOptions{MaxDepth: -1, NodeTypes: []models.NodeType{"library", "database"}}
+		found, err := analyzer.GetDependenciesWithDepth(graphID, "frontend", options)
+		if err != nil {
+			t.Fatalf("GetDependenciesWithDepth failed: %v", err)
+		}
+		if expected := []string{"logger:3", "order-db:3", "user-db:3"}; !reflect.DeepEqual(entries(found), expected) {
+			t.Errorf("Expected %v, got %v", expected, entries(found))
+		}
+		if options.Direction != types.DirectionForward || options.NodeTypes == nil {
+			t.Errorf("Expected the options to be left unchanged, got %+v", options)
+		}
+
+		found, err = analyzer.GetDependenciesWithDepth(graphID, "frontend", &types.TraversalOptions{MaxDepth: 2})
+		if err != nil || len(found) != 4 || found[3].Depth != 2 {
+			t.Errorf("Expected 4 dependencies within depth 2, got %v, %v", entries(found), err)
+		}
+		if _, err := analyzer.GetDependenciesWithDepth(graphID, "missing", nil); err == nil {
+			t.Error("Expected a missing node to fail")
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+		resp, err := handler.Handle("ANALYSIS.DEPENDENCIES", []string{string(graphID), "api-gateway", "MAXDEPTH", "2", "NODETYPES", "library"})
+		if expected := []string{"logger:library:2:transitive"}; err != nil || !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+		resp, err = handler.Handle("ANALYSIS.DEPENDENCIES", []string{string(graphID), "auth-service", "dependents", "FORMAT", "simple"})
+		if expected := []string{"api-gateway:service", "frontend:application"}; err != nil || !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+		resp, err = handler.Handle("ANALYSIS.DEPENDENCIES", []string{string(graphID), "auth-service"})
+		if err != nil || len(resp.ArrayValue) != 3 || resp.ArrayValue[0] != "logger:library:1:direct" {
+			t.Errorf("Expected logger first as a direct dependency, got %v, %v", resp, err)
+		}
+
+		for _, args := range [][]string{
+			{string(graphID)},
+			{string(graphID), "missing"},
+			{string(graphID), "frontend", "MAXDEPTH", "-1"},
+			{string(graphID), "frontend", "FORMAT", "json"},
+			{string(graphID), "frontend", "SIDEWAYS"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.DEPENDENCIES", args); err == nil {
+				t.Errorf("Expected ANALYSIS.DEPENDENCIES %v to fail", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestTraversalDepthRange tests MinDepth and MaxDepth in DepthFirstSearch
+// and AllPathsTraversal, and the MINDEPTH and MAXDEPTH options of
+// ANALYSIS.TRAVERSE
+func TestTraversalDepthRange(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_depth_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	graphID := models.GraphID("depth")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "depth"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	if err := engine.CreateNodes(graphID, []*models.Node{
+		{ID: "s", Type: "service"},
+		{ID: "a", Type: "service"},
+		{ID: "b", Type: "service"},
+		{ID: "c", Type: "database"},
+		{ID: "d", Type: "cache"},
+	}); err != nil {
+		t.Fatalf("CreateNodes failed: %v", err)
+	}
+	// From s: a chain a, b, c three deep, b leading back to s, and the leaf
+	// d one deep
+	if err := engine.CreateEdges(graphID, []*models.Edge{
+		{ID: "e1", Type: "calls", FromNodeID: "s", ToNodeID: "a"},
+		{ID: "e2", Type: "calls", FromNodeID: "a", ToNodeID: "b"},
+		{ID: "e3", Type: "calls", FromNodeID: "b", ToNodeID: "c"},
+		{ID: "e4", Type: "calls", FromNodeID: "s", ToNodeID: "d"},
+		{ID: "e5", Type: "calls", FromNodeID: "b", ToNodeID: "s"},
+	}); err != nil {
+		t.Fatalf("CreateEdges failed: %v", err)
+	}
+
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	options := func(minDepth, maxDepth int) *types.TraversalOptions {
+		return &types.TraversalOptions{Direction: types.DirectionForward, MinDepth: minDepth, MaxDepth: maxDepth}
+	}
+
+	t.Run("DepthFirstSearch", func(t *testing.T) {
+		for _, tt := range []struct {
+			name            string
+			minDepth        int
+			maxDepth        int
+			expectedNodes   []models.NodeID
+			expectedEdgeIDs []models.EdgeID
+		}{
+			{"Unbounded", 0, -1, []models.NodeID{"s", "a", "b", "c", "d"}, []models.EdgeID{"e1", "e2", "e3", "e4"}},
+			{"MinDepth", 2, -1, []models.NodeID{"b", "c"}, []models.EdgeID{"e2", "e3"}},
+			{"Range", 2, 2, []models.NodeID{"b"}, []models.EdgeID{"e2"}},
+			{"MaxDepthZero", 0, 0, []models.NodeID{"s"}, nil},
+			{"PastTheDeepest", 4, -1, nil, nil},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				result, err := analyzer.DepthFirstSearch(graphID, "s", options(tt.minDepth, tt.maxDepth))
+				if err != nil {
+					t.Fatalf("DepthFirstSearch failed: %v", err)
+				}
+				var edgeIDs []models.EdgeID
+				for _, edge := range result.Edges {
+					edgeIDs = append(edgeIDs, edge.ID)
+				}
+				if !reflect.DeepEqual(result.Path, tt.expectedNodes) || !reflect.DeepEqual(edgeIDs, tt.expectedEdgeIDs) {
+					t.Errorf("Expected nodes %v and edges %v, got %v and %v", tt.expectedNodes, tt.expectedEdgeIDs, result.Path, edgeIDs)
+				}
+			})
+		}
+	})
+
+	t.Run("AllPathsTraversal", func(t *testing.T) {
+		paths := func(t *testing.T, minDepth, maxDepth int) map[string]types.TerminalReason {
+			t.Helper()
+			found, err := analyzer.AllPathsTraversal(graphID, "s", options(minDepth, maxDepth))
+			if err != nil {
+				t.Fatalf("AllPathsTraversal failed: %v", err)
+			}
+			got := make(map[string]types.TerminalReason)
+			for _, path := range found {
+				ids := make([]string, len(path.Path))
+				for i, nodeID := range path.Path {
+					ids[i] = string(nodeID)
+				}
+				got[strings.Join(ids, ">")] = path.Terminal
+			}
+			return got
+		}
+
+		if got := paths(t, 0, -1); len(got) != 3 || got["s>d"] != types.TerminalLeaf {
+			t.Errorf("Expected every path without MinDepth, got %v", got)
+		}
+		expected := map[string]types.TerminalReason{
+			"s>a>b>c": types.TerminalLeaf,
+			"s>a>b>s": types.TerminalCycle,
+		}
+		if got := paths(t, 2, -1); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected the paths of 2 edges or more, got %v", got)
+		}
+		if got := paths(t, 2, 2); got["s>a>b"] != types.TerminalMaxDepth || got["s>d"] != "" {
+			t.Errorf("Expected s>a>b cut at MaxDepth and s>d left out, got %v", got)
+		}
+		if got := paths(t, 0, 0); !reflect.DeepEqual(got, map[string]types.TerminalReason{"s": types.TerminalMaxDepth}) {
+			t.Errorf("Expected only the start node with MaxDepth 0, got %v", got)
+		}
+		if got := paths(t, 4, -1); len(got) != 0 {
+			t.Errorf("Expected no path of 4 edges, got %v", got)
+		}
+	})
+
+	t.Run("Traverse", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+		run := func(t *testing.T, args ...string) *protocol.Response {
+			t.Helper()
+			resp, err := handler.Handle("ANALYSIS.TRAVERSE", append([]string{string(graphID), "s"}, args...))
+			if err != nil {
+				t.Fatalf("ANALYSIS.TRAVERSE %v failed: %v", args, err)
+			}
+			return resp
+		}
+
+		if resp := run(t, "FORMAT", "simple", "mindepth", "2"); !reflect.DeepEqual(resp.ArrayValue, []string{"b:service", "c:database"}) {
+			t.Errorf("Expected b and c, got %v", resp.ArrayValue)
+		}
+		if resp := run(t, "FORMAT", "simple", "MAXDEPTH", "0"); !reflect.DeepEqual(resp.ArrayValue, []string{"s:service"}) {
+			t.Errorf("Expected only the start node, got %v", resp.ArrayValue)
+		}
+		resp := run(t, "MINDEPTH", "3", "TERMINAL")
+		got := append([]string(nil), resp.ArrayValue...)
+		sort.Strings(got)
+		expected := []string{
+			"s:service->e1:calls->a:service->e2:calls->b:service->e3:calls->c:database|leaf",
+			"s:service->e1:calls->a:service->e2:calls->b:service->e5:calls->s:service|cycle",
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+		if resp := run(t, "MINDEPTH", "4"); resp.Type != protocol.ResponseTypeNull {
+			t.Errorf("Expected a null reply with no path long enough, got %v", resp.ArrayValue)
+		}
+
+		for _, args := range [][]string{
+			{"MINDEPTH"},
+			{"MAXDEPTH", "-1"},
+			{"MINDEPTH", "two"},
+			{"MINDEPTH", "3", "MAXDEPTH", "2"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.TRAVERSE", append([]string{string(graphID), "s"}, args...)); err == nil {
+				t.Errorf("Expected %v to fail", args)
+			}
+		}
+	})
+
+	t.Run("ShortestDepth", func(t *testing.T) {
+		// A shortcut puts c one edge from s, though the depth-first walk
+		// still reaches it first through a and b, three deep
+		if err := engine.CreateEdge(graphID, &models.Edge{ID: "e6", Type: "calls", FromNodeID: "s", ToNodeID: "c"}); err != nil {
+			t.Fatalf("CreateEdge failed: %v", err)
+		}
+		defer engine.DeleteEdge(graphID, "e6")
+
+		result, err := analyzer.DepthFirstSearch(graphID, "s", options(2, -1))
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		if !reflect.DeepEqual(result.Path, []models.NodeID{"b"}) {
+			t.Errorf("Expected only b two edges or more from s, got %v", result.Path)
+		}
+	})
+}
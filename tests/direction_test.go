@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestDirectionParsing tests the direction tokens and defaults shared by
+// every command that takes a direction
+func TestDirectionParsing(t *testing.T) {
+	te := setupTestAnalysisEngine(t)
+	defer te.cleanup()
+	te.createSampleGraph()
+	handler := redis.NewCommandHandler(te.engine)
+	graph := string(te.graphID)
+
+	// Each direction with its canonical token and synonyms
+	directions := []struct {
+		direction types.TraversalDirection
+		tokens    []string
+	}{
+		{types.DirectionForward, []string{"out", "forward"}},
+		{types.DirectionBackward, []string{"in", "backward"}},
+		{types.DirectionBoth, []string{"both", "bidirectional"}},
+	}
+	casings := func(token string) []string {
+		return []string{token, strings.ToUpper(token), strings.ToUpper(token[:1]) + token[1:]}
+	}
+
+	// commandArgs builds a command using a direction token
+	commandArgs := map[string]func(direction string) []string{
+		"ANALYSIS.TRAVERSE": func(direction string) []string {
+			return []string{graph, "auth", "DIRECTION", direction, "FORMAT", "simple"}
+		},
+		"ANALYSIS.CENTRALITY": func(direction string) []string {
+			return []string{graph, "degree", "DIRECTION", direction}
+		},
+		"EDGE.NEIGHBORS": func(direction string) []string {
+			return []string{graph, "auth", direction, "FORMAT", "simple"}
+		},
+	}
+	run := func(t *testing.T, command string, args []string) []string {
+		t.Helper()
+		resp, err := handler.Handle(command, args)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", command, strings.Join(args, " "), err)
+		}
+		return resp.ArrayValue
+	}
+
+	t.Run("Tokens", func(t *testing.T) {
+		for _, d := range directions {
+			for _, token := range d.tokens {
+				for _, spelling := range casings(token) {
+					direction, err := commands.ParseDirection(spelling)
+					if err != nil || direction != d.direction {
+						t.Errorf("Expected %q to parse as %v, got %v, %v", spelling, d.direction, direction, err)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Commands", func(t *testing.T) {
+		for command, build := range commandArgs {
+			for _, d := range directions {
+				expected := run(t, command, build(d.tokens[0]))
+				for _, token := range d.tokens {
+					for _, spelling := range casings(token) {
+						if got := run(t, command, build(spelling)); !reflect.DeepEqual(got, expected) {
+							t.Errorf("Expected %s with %q to match %q: %v, got %v", command, spelling, d.tokens[0], expected, got)
+						}
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		for _, tc := range []struct {
+			command    string
+			omitted    []string
+			equivalent []string
+		}{
+			{"ANALYSIS.TRAVERSE", []string{graph, "auth", "FORMAT", "simple"}, commandArgs["ANALYSIS.TRAVERSE"]("out")},
+			{"ANALYSIS.CENTRALITY", []string{graph, "degree"}, commandArgs["ANALYSIS.CENTRALITY"]("both")},
+			{"ANALYSIS.CENTRALITY", []string{graph, "pagerank"}, []string{graph, "pagerank", "DIRECTION", "out"}},
+			{"EDGE.NEIGHBORS", []string{graph, "auth", "FORMAT", "simple"}, commandArgs["EDGE.NEIGHBORS"]("both")},
+		} {
+			if got, expected := run(t, tc.command, tc.omitted), run(t, tc.command, tc.equivalent); !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %v to default like %v: %v, got %v", tc.omitted, tc.equivalent, expected, got)
+			}
+		}
+
+		for command, expected := range map[string]string{
+			"ANALYSIS.TRAVERSE":   "Defaults: DIRECTION out",
+			"ANALYSIS.CENTRALITY": "Defaults: DIRECTION both for degree, betweenness and closeness, out for pagerank and eigenvector",
+			"EDGE.NEIGHBORS":      "Defaults: direction both",
+		} {
+			if help := run(t, "HELP", []string{command}); !strings.Contains(strings.Join(help, "\n"), expected) {
+				t.Errorf("Expected HELP %s to document %q, got %v", command, expected, help)
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for command, build := range commandArgs {
+			for _, token := range []string{"sideways", "outward", "IN-OUT"} {
+				_, err := handler.Handle(command, build(token))
+				if err == nil {
+					t.Errorf("Expected %s with %q to fail", command, token)
+					continue
+				}
+				message := err.Error()
+				if !strings.Contains(message, "invalid DIRECTION: "+token) {
+					t.Errorf("Expected %s to name the invalid direction, got %q", command, message)
+				}
+				for _, accepted := range []string{"'in'", "'out'", "'both'", "'forward'", "'backward'", "'bidirectional'"} {
+					if !strings.Contains(message, accepted) {
+						t.Errorf("Expected %s's error to list %s, got %q", command, accepted, message)
+					}
+				}
+			}
+		}
+	})
+}
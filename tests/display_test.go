@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestDisplayLabels tests GRAPH.DISPLAY and the LABELS output flag
+func TestDisplayLabels(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_display_test")
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("display-test-graph")
+	graphCommands := commands.NewGraphCommands(engine)
+	nodeCommands := commands.NewNodeCommands(engine)
+	edgeCommands := commands.NewEdgeCommands(engine)
+	analysisCommands := commands.NewAnalysisCommands(engine)
+
+	if _, err := graphCommands.Handle("CREATE", []string{string(graphID)}); err != nil {
+		t.Fatalf("GRAPH.CREATE failed: %v", err)
+	}
+
+	nodes := []*models.Node{
+		{ID: "a", Type: "service", Attributes: models.Attributes{"name": "Gateway"}},
+		{ID: "b", Type: "service", Attributes: models.Attributes{"name": "host:8080"}},
+		{ID: "c", Type: "database", Attributes: models.Attributes{}},
+	}
+	for _, node := range nodes {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	edges := []*models.Edge{
+		{ID: "a-b", FromNodeID: "a", ToNodeID: "b", Type: "calls", Attributes: models.Attributes{"protocol": "http"}},
+		{ID: "b-c", FromNodeID: "b", ToNodeID: "c", Type: "writes_to"},
+	}
+	for _, edge := range edges {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	if _, err := graphCommands.Handle("DISPLAY", []string{"SET", string(graphID), "name", "protocol"}); err != nil {
+		t.Fatalf("GRAPH.DISPLAY SET failed: %v", err)
+	}
+
+	t.Run("DisplayGet", func(t *testing.T) {
+		resp, err := graphCommands.Handle("DISPLAY", []string{"GET", string(graphID)})
+		if err != nil {
+			t.Fatalf("GRAPH.DISPLAY GET failed: %v", err)
+		}
+		expected := []string{"name", "protocol"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("NodeListLabels", func(t *testing.T) {
+		resp, err := nodeCommands.Handle("LIST", []string{string(graphID), "LABELS"})
+		if err != nil {
+			t.Fatalf("NODE.LIST LABELS failed: %v", err)
+		}
+		values := resp.ArrayValue
+		sort.Strings(values)
+		// Missing attributes fall back to an empty label; separators are JSON-escaped.
+		expected := []string{"a:service:Gateway", `b:service:"host:8080"`, "c:database:"}
+		if !reflect.DeepEqual(values, expected) {
+			t.Errorf("Expected %v, got %v", expected, values)
+		}
+	})
+
+	t.Run("NodeListWithoutLabels", func(t *testing.T) {
+		resp, err := nodeCommands.Handle("LIST", []string{string(graphID)})
+		if err != nil {
+			t.Fatalf("NODE.LIST failed: %v", err)
+		}
+		values := resp.ArrayValue
+		sort.Strings(values)
+		expected := []string{"a:service", "b:service", "c:database"}
+		if !reflect.DeepEqual(values, expected) {
+			t.Errorf("Expected %v, got %v", expected, values)
+		}
+	})
+
+	t.Run("TraverseLabels", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("TRAVERSE", []string{string(graphID), "a", "LABELS"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE LABELS failed: %v", err)
+		}
+		expected := []string{`a:service:Gateway->a-b:calls:http->b:service:"host:8080"->b-c:writes_to:->c:database:`}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("ShortestPathLabels", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("SHORTESTPATH", []string{string(graphID), "a", "c", "FORMAT", "simple", "LABELS"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SHORTESTPATH LABELS failed: %v", err)
+		}
+		expected := []string{"a:service:Gateway", `b:service:"host:8080"`, "c:database:"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("NeighborsLabels", func(t *testing.T) {
+		resp, err := edgeCommands.Handle("NEIGHBORS", []string{string(graphID), "a", "out", "LABELS"})
+		if err != nil {
+			t.Fatalf("EDGE.NEIGHBORS LABELS failed: %v", err)
+		}
+		expected := []string{`b:service:"host:8080"->a-b:calls:http`}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// dotStatement is a node or edge statement of a parsed DOT graph
+type dotStatement struct {
+	from, to string // to is "" for a node statement
+	attrs    map[string]string
+}
+
+// parseDot parses the subset of DOT that GRAPH.EXPORT.DOT writes: a
+// digraph of node, edge and default node attribute statements, each
+// followed by a bracketed attribute list and a semicolon. Unquoted IDs may
+// hold only letters, digits and underscores, so an unquoted ID with a colon
+// fails as DOT would read it as a port.
+func parseDot(document string) (string, []dotStatement, error) {
+	var tokens []string
+	for i := 0; i < len(document); {
+		c := document[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '"':
+			var b strings.Builder
+			b.WriteByte('"')
+			for i++; ; i++ {
+				if i >= len(document) {
+					return "", nil, fmt.Errorf("unterminated string")
+				}
+				if document[i] == '\\' && i+1 < len(document) {
+					b.WriteByte(document[i+1])
+					i++
+					continue
+				}
+				if document[i] == '"' {
+					i++
+					break
+				}
+				b.WriteByte(document[i])
+			}
+			tokens = append(tokens, b.String())
+		case strings.HasPrefix(document[i:], "->"):
+			tokens = append(tokens, "->")
+			i += 2
+		case strings.ContainsRune("{}[];,=", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			start := i
+			for i < len(document) && (c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))) {
+				i++
+				if i < len(document) {
+					c = document[i]
+				}
+			}
+			if i == start {
+				return "", nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, document[start:i])
+		}
+	}
+
+	pos := 0
+	next := func() string {
+		if pos >= len(tokens) {
+			return ""
+		}
+		pos++
+		return tokens[pos-1]
+	}
+	id := func(token string) (string, error) {
+		if token == "" || strings.ContainsAny(token[:1], "{}[];,=-") {
+			return "", fmt.Errorf("expected an ID, got %q", token)
+		}
+		return strings.TrimPrefix(token, `"`), nil
+	}
+
+	if next() != "digraph" {
+		return "", nil, fmt.Errorf("expected digraph")
+	}
+	name, err := id(next())
+	if err != nil {
+		return "", nil, err
+	}
+	if next() != "{" {
+		return "", nil, fmt.Errorf("expected {")
+	}
+	var statements []dotStatement
+	for {
+		token := next()
+		if token == "}" {
+			break
+		}
+		from, err := id(token)
+		if err != nil {
+			return "", nil, err
+		}
+		statement := dotStatement{from: from, attrs: make(map[string]string)}
+		token = next()
+		if token == "->" {
+			if statement.to, err = id(next()); err != nil {
+				return "", nil, err
+			}
+			token = next()
+		}
+		if token != "[" {
+			return "", nil, fmt.Errorf("expected [ after %s, got %q", from, token)
+		}
+		for token != "]" {
+			key, err := id(next())
+			if err != nil {
+				return "", nil, err
+			}
+			if next() != "=" {
+				return "", nil, fmt.Errorf("expected = after %s", key)
+			}
+			if statement.attrs[key], err = id(next()); err != nil {
+				return "", nil, err
+			}
+			if token = next(); token != "," && token != "]" {
+				return "", nil, fmt.Errorf("expected , or ], got %q", token)
+			}
+		}
+		if next() != ";" {
+			return "", nil, fmt.Errorf("expected ; after the statement of %s", from)
+		}
+		statements = append(statements, statement)
+	}
+	if pos != len(tokens) {
+		return "", nil, fmt.Errorf("unexpected %q after the closing brace", tokens[pos])
+	}
+	return name, statements, nil
+}
+
+// TestDotOutput tests the Graphviz DOT documents of GRAPH.EXPORT.DOT and
+// ANALYSIS.TRAVERSE FORMAT dot
+func TestDotOutput(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_dot_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("deploy")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "deploy"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	if err := engine.CreateNodes(graphID, []*models.Node{
+		{ID: "db:5432", Type: "database", Attributes: models.Attributes{"name": `Orders "main" DB`, "team": "data"}},
+		{ID: "api", Type: "service", Attributes: models.Attributes{"name": "API", "team": "core"}},
+		{ID: "web", Type: "service"},
+	}); err != nil {
+		t.Fatalf("CreateNodes failed: %v", err)
+	}
+	if err := engine.CreateEdges(graphID, []*models.Edge{
+		{ID: "api-db", Type: "reads", FromNodeID: "api", ToNodeID: "db:5432"},
+		{ID: "web-api", Type: "calls", FromNodeID: "web", ToNodeID: "api"},
+	}); err != nil {
+		t.Fatalf("CreateEdges failed: %v", err)
+	}
+
+	// document runs a command and parses its DOT reply into the node
+	// statements by ID and the edge statements
+	document := func(t *testing.T, command string, args ...string) (map[string]dotStatement, []dotStatement) {
+		t.Helper()
+		resp, err := handler.Handle(command, args)
+		if err != nil {
+			t.Fatalf("%s failed: %v", command, err)
+		}
+		if strings.Count(resp.StringValue, "{") != strings.Count(resp.StringValue, "}") {
+			t.Errorf("Expected balanced braces, got %s", resp.StringValue)
+		}
+		name, statements, err := parseDot(resp.StringValue)
+		if err != nil {
+			t.Fatalf("Expected %s to reply valid DOT, got %v in\n%s", command, err, resp.StringValue)
+		}
+		if name != string(graphID) {
+			t.Errorf("Expected the digraph to be named %s, got %s", graphID, name)
+		}
+		nodes := make(map[string]dotStatement)
+		var edges []dotStatement
+		for _, statement := range statements {
+			switch {
+			case statement.to != "":
+				edges = append(edges, statement)
+			case statement.from != "node":
+				nodes[statement.from] = statement
+			}
+		}
+		return nodes, edges
+	}
+
+	t.Run("Export", func(t *testing.T) {
+		nodes, edges := document(t, "GRAPH.EXPORT.DOT", string(graphID))
+		if len(nodes) != 3 || len(edges) != 2 {
+			t.Fatalf("Expected 3 node and 2 edge statements, got %v and %v", nodes, edges)
+		}
+		if edges[0].from != "api" || edges[0].to != "db:5432" || edges[0].attrs["label"] != "reads" {
+			t.Errorf("Expected api -> db:5432 labelled reads, got %+v", edges[0])
+		}
+		for id, label := range map[string]string{"db:5432": `Orders "main" DB`, "api": "API", "web": "web"} {
+			if nodes[id].attrs["label"] != label {
+				t.Errorf("Expected %s to be labelled %q, got %q", id, label, nodes[id].attrs["label"])
+			}
+		}
+		if nodes["api"].attrs["shape"] != nodes["web"].attrs["shape"] || nodes["api"].attrs["fillcolor"] != nodes["web"].attrs["fillcolor"] {
+			t.Errorf("Expected both services styled alike, got %v and %v", nodes["api"].attrs, nodes["web"].attrs)
+		}
+		if nodes["api"].attrs["shape"] == nodes["db:5432"].attrs["shape"] || nodes["api"].attrs["fillcolor"] == nodes["db:5432"].attrs["fillcolor"] {
+			t.Errorf("Expected services and databases styled apart, got %v and %v", nodes["api"].attrs, nodes["db:5432"].attrs)
+		}
+	})
+
+	t.Run("Labels", func(t *testing.T) {
+		nodes, _ := document(t, "GRAPH.EXPORT.DOT", string(graphID), "LABEL", "team")
+		if nodes["api"].attrs["label"] != "core" || nodes["web"].attrs["label"] != "web" {
+			t.Errorf("Expected team labels, got %v", nodes)
+		}
+		if _, err := handler.Handle("GRAPH.DISPLAY", []string{"SET", string(graphID), "team"}); err != nil {
+			t.Fatalf("GRAPH.DISPLAY failed: %v", err)
+		}
+		defer handler.Handle("GRAPH.DISPLAY", []string{"SET", string(graphID), ""})
+		nodes, _ = document(t, "GRAPH.EXPORT.DOT", string(graphID))
+		if nodes["db:5432"].attrs["label"] != "data" {
+			t.Errorf("Expected the display attribute to label nodes, got %v", nodes["db:5432"])
+		}
+	})
+
+	t.Run("Traverse", func(t *testing.T) {
+		nodes, edges := document(t, "ANALYSIS.TRAVERSE", string(graphID), "api", "FORMAT", "dot")
+		if len(nodes) != 2 || len(edges) != 1 || nodes["db:5432"].attrs["label"] != `Orders "main" DB` {
+			t.Errorf("Expected api and db:5432 and the edge between them, got %v and %v", nodes, edges)
+		}
+		nodes, edges = document(t, "ANALYSIS.TRAVERSE", string(graphID), "db:5432", "DIRECTION", "in", "FORMAT", "dot")
+		if len(nodes) != 3 || len(edges) != 2 {
+			t.Errorf("Expected every node and edge upstream of db:5432, got %v and %v", nodes, edges)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, tt := range []struct {
+			command string
+			args    []string
+		}{
+			{"GRAPH.EXPORT.DOT", []string{"missing"}},
+			{"GRAPH.EXPORT.DOT", []string{string(graphID), "COLOR", "team"}},
+			{"GRAPH.EXPORT.DOT", []string{string(graphID), "LABEL"}},
+			{"ANALYSIS.TRAVERSE", []string{string(graphID), "api", "FORMAT", "dot", "COUNT"}},
+		} {
+			if _, err := handler.Handle(tt.command, tt.args); err == nil {
+				t.Errorf("Expected %s %v to fail", tt.command, tt.args)
+			}
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestFilterEdges tests structured edge filtering by endpoint, type and
+// attribute, alone and combined, against brute-force filtering
+func TestFilterEdges(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_edge_filter_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("filter")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "filter"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	// "api" and "api:v2" share an index prefix, so FROM api must not
+	// return the edges of api:v2
+	nodes := map[models.NodeID]models.NodeType{
+		"api":     "service",
+		"api:v2":  "service",
+		"billing": "service",
+		"db":      "database",
+		"cache":   "database",
+		"events":  "queue",
+	}
+	for id, nodeType := range nodes {
+		if err := engine.CreateNode(graphID, &models.Node{ID: id, Type: nodeType}); err != nil {
+			t.Fatalf("Failed to create node %s: %v", id, err)
+		}
+	}
+	edges := []struct {
+		id, from, to, edgeType string
+		weight                 int
+	}{
+		{"e01", "api", "db", "depends_on", 1},
+		{"e02", "api", "cache", "depends_on", 2},
+		{"e03", "api", "billing", "calls", 1},
+		{"e04", "api:v2", "db", "depends_on", 1},
+		{"e05", "api:v2", "billing", "calls", 2},
+		{"e06", "billing", "db", "depends_on", 1},
+		{"e07", "billing", "events", "publishes", 1},
+		{"e08", "events", "api", "calls", 2},
+		{"e09", "cache", "db", "depends_on", 2},
+		{"e10", "billing", "api", "calls", 1},
+	}
+	for _, e := range edges {
+		edge := &models.Edge{
+			ID:         models.EdgeID(e.id),
+			Type:       models.EdgeType(e.edgeType),
+			FromNodeID: models.NodeID(e.from),
+			ToNodeID:   models.NodeID(e.to),
+			Attributes: models.Attributes{"weight": e.weight},
+		}
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", e.id, err)
+		}
+	}
+
+	all, err := engine.ListEdges(graphID)
+	if err != nil {
+		t.Fatalf("Failed to list edges: %v", err)
+	}
+	bruteForce := func(filter storage.EdgeFilter) []string {
+		var ids []string
+		for _, edge := range all {
+			if filter.From != "" && edge.FromNodeID != filter.From ||
+				filter.To != "" && edge.ToNodeID != filter.To ||
+				filter.Type != "" && edge.Type != filter.Type ||
+				filter.FromType != "" && nodes[edge.FromNodeID] != filter.FromType ||
+				filter.ToType != "" && nodes[edge.ToNodeID] != filter.ToType {
+				continue
+			}
+			if filter.AttrKey != "" && fmt.Sprint(edge.Attributes[filter.AttrKey]) != fmt.Sprint(filter.AttrValue) {
+				continue
+			}
+			ids = append(ids, string(edge.ID))
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	filtered := func(t *testing.T, filter storage.EdgeFilter) []string {
+		t.Helper()
+		result, err := engine.FilterEdges(graphID, filter)
+		if err != nil {
+			t.Fatalf("FilterEdges(%+v) failed: %v", filter, err)
+		}
+		var ids []string
+		for _, edge := range result {
+			ids = append(ids, string(edge.ID))
+		}
+		return ids
+	}
+
+	t.Run("Selectors", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			filter   storage.EdgeFilter
+			expected []string
+		}{
+			{"From", storage.EdgeFilter{From: "api"}, []string{"e01", "e02", "e03"}},
+			{"To", storage.EdgeFilter{To: "db"}, []string{"e01", "e04", "e06", "e09"}},
+			{"Type", storage.EdgeFilter{Type: "calls"}, []string{"e03", "e05", "e08", "e10"}},
+			{"FromType", storage.EdgeFilter{FromType: "database"}, []string{"e09"}},
+			{"ToType", storage.EdgeFilter{ToType: "queue"}, []string{"e07"}},
+			{"Attr", storage.EdgeFilter{AttrKey: "weight", AttrValue: 2.0}, []string{"e02", "e05", "e08", "e09"}},
+			{"TypeFromType", storage.EdgeFilter{Type: "depends_on", FromType: "service"}, []string{"e01", "e02", "e04", "e06"}},
+			{"ToTypeCalls", storage.EdgeFilter{To: "api", Type: "calls"}, []string{"e08", "e10"}},
+			{"FromTo", storage.EdgeFilter{From: "api:v2", To: "billing"}, []string{"e05"}},
+			{"NoMatch", storage.EdgeFilter{From: "db"}, nil},
+			{"Limit", storage.EdgeFilter{Type: "depends_on", Limit: 2}, []string{"e01", "e02"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := filtered(t, tt.filter); !reflect.DeepEqual(got, tt.expected) {
+					t.Errorf("Expected %v, got %v", tt.expected, got)
+				}
+			})
+		}
+	})
+
+	t.Run("MatchesBruteForce", func(t *testing.T) {
+		froms := []models.NodeID{"", "api", "api:v2", "billing", "missing"}
+		tos := []models.NodeID{"", "db", "api", "billing"}
+		edgeTypes := []models.EdgeType{"", "depends_on", "calls", "publishes"}
+		fromTypes := []models.NodeType{"", "service", "database"}
+		toTypes := []models.NodeType{"", "database", "service"}
+		attrs := []interface{}{nil, 1.0, 2.0}
+
+		checked := 0
+		for _, from := range froms {
+			for _, to := range tos {
+				for _, edgeType := range edgeTypes {
+					for _, fromType := range fromTypes {
+						for _, toType := range toTypes {
+							for _, attr := range attrs {
+								filter := storage.EdgeFilter{From: from, To: to, Type: edgeType, FromType: fromType, ToType: toType}
+								if attr != nil {
+									filter.AttrKey, filter.AttrValue = "weight", attr
+								}
+								if got, expected := filtered(t, filter), bruteForce(filter); !reflect.DeepEqual(got, expected) {
+									t.Fatalf("Filter %+v: expected %v, got %v", filter, expected, got)
+								}
+								checked++
+							}
+						}
+					}
+				}
+			}
+		}
+		if checked != 5*4*4*3*3*3 {
+			t.Errorf("Expected every combination to be checked, checked %d", checked)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+
+		resp, err := handler.Handle("EDGE.FILTER", []string{"filter", "FROMTYPE", "service", "TYPE", "depends_on", "TOTYPE", "database", "LIMIT", "2"})
+		if err != nil {
+			t.Fatalf("EDGE.FILTER failed: %v", err)
+		}
+		expected := []string{
+			"e01", "api", "db", "depends_on", `{"weight":1}`,
+			"e02", "api", "cache", "depends_on", `{"weight":2}`,
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		resp, err = handler.Handle("EDGE.FILTER", []string{"filter", "to", "api", "ATTR", "weight", "2"})
+		if err != nil {
+			t.Fatalf("EDGE.FILTER failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 5 || resp.ArrayValue[0] != "e08" {
+			t.Errorf("Expected only e08, got %v", resp.ArrayValue)
+		}
+
+		// The attribute form is unchanged
+		resp, err = handler.Handle("EDGE.FILTER", []string{"filter", "weight", "2"})
+		if err != nil {
+			t.Fatalf("EDGE.FILTER failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 20 {
+			t.Errorf("Expected 4 edges with weight 2, got %d values", len(resp.ArrayValue))
+		}
+
+		for _, args := range [][]string{
+			{"filter"},
+			{"filter", "FROM"},
+			{"filter", "ATTR", "weight"},
+			{"filter", "LIMIT", "0"},
+			{"filter", "TYPE", "calls", "SORT", "id"},
+		} {
+			if _, err := handler.Handle("EDGE.FILTER", args); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+	})
+}
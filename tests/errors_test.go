@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestTypedErrors tests that the storage and analysis sentinel errors match
+// with errors.Is through the command handlers, the analyzer and the engine,
+// and that the wrapped messages still name the entity
+func TestTypedErrors(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_errors_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	for _, command := range [][]string{
+		{"GRAPH.CREATE", "infra"},
+		{"NODE.CREATE", "infra", "api", "service"},
+		{"NODE.CREATE", "infra", "db", "database"},
+		{"NODE.CREATE", "infra", "batch", "job"},
+		{"EDGE.CREATE", "infra", "reads", "api", "db", "reads"},
+	} {
+		if _, err := handler.Handle(command[0], command[1:]); err != nil {
+			t.Fatalf("%v failed: %v", command, err)
+		}
+	}
+
+	t.Run("Handlers", func(t *testing.T) {
+		document, err := handler.Handle("GRAPH.EXPORT", []string{"infra"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT failed: %v", err)
+		}
+
+		for _, tc := range []struct {
+			args   []string
+			target error
+			id     string
+		}{
+			// Handler -> analyzer -> storage
+			{[]string{"ANALYSIS.TRAVERSE", "infra", "missing"}, storage.ErrNodeNotFound, "missing"},
+			{[]string{"ANALYSIS.SHORTESTPATH", "infra", "api", "batch"}, analysis.ErrNoPath, "batch"},
+			{[]string{"ANALYSIS.WHATIF", "infra", "REMOVE", "EDGES", "gone", "CHECK", "REACHABLE", "api", "db"}, storage.ErrEdgeNotFound, "gone"},
+			// Handler -> storage
+			{[]string{"NODE.GET", "infra", "missing"}, storage.ErrNodeNotFound, "missing"},
+			{[]string{"EDGE.GET", "infra", "missing"}, storage.ErrEdgeNotFound, "missing"},
+			{[]string{"GRAPH.GET", "missing"}, storage.ErrGraphNotFound, "missing"},
+			{[]string{"ANALYSIS.COMPONENTS", "missing"}, storage.ErrGraphNotFound, "missing"},
+			{[]string{"GRAPH.IMPORT", "infra", document.StringValue}, storage.ErrAlreadyExists, "infra"},
+		} {
+			_, err := handler.Handle(tc.args[0], tc.args[1:])
+			if !errors.Is(err, tc.target) {
+				t.Errorf("Expected %v to fail with %q, got %v", tc.args[:3], tc.target, err)
+				continue
+			}
+			if !strings.Contains(err.Error(), tc.id) {
+				t.Errorf("Expected the error of %v to name %s, got %v", tc.args[:3], tc.id, err)
+			}
+		}
+	})
+
+	t.Run("Engine", func(t *testing.T) {
+		if _, err := engine.GetNode("infra", "missing"); !errors.Is(err, storage.ErrNodeNotFound) || err.Error() != "node not found: missing" {
+			t.Errorf("Expected ErrNodeNotFound with an unchanged message, got %v", err)
+		}
+		if err := engine.UpdateGraph(&models.Graph{ID: "missing"}); !errors.Is(err, storage.ErrGraphNotFound) {
+			t.Errorf("Expected ErrGraphNotFound, got %v", err)
+		}
+		if err := engine.DeleteEdge("infra", "missing"); !errors.Is(err, storage.ErrEdgeNotFound) {
+			t.Errorf("Expected ErrEdgeNotFound, got %v", err)
+		}
+		if errors.Is(storage.ErrNodeNotFound, storage.ErrGraphNotFound) || errors.Is(storage.ErrEdgeNotFound, storage.ErrNodeNotFound) {
+			t.Error("Expected the not found errors to be distinct")
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		err := engine.RunTransaction(func(tx storage.Transaction) error {
+			node, err := tx.GetNode("infra", "api")
+			if err != nil {
+				return err
+			}
+			// Another write commits after the transaction read the node
+			concurrent := *node
+			concurrent.Attributes = models.Attributes{"replicas": 2}
+			if err := engine.UpdateNode("infra", &concurrent); err != nil {
+				return err
+			}
+			node.Attributes = models.Attributes{"replicas": 3}
+			return tx.UpdateNode("infra", node)
+		})
+		if !errors.Is(err, storage.ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("TxnTooBig", func(t *testing.T) {
+		payload := strings.Repeat("x", 4<<10)
+		err := engine.RunTransaction(func(tx storage.Transaction) error {
+			for i := 0; i < 100000; i++ {
+				node := &models.Node{ID: models.NodeID(fmt.Sprintf("bulk-%06d", i)), Type: "bulk", Attributes: models.Attributes{"payload": payload}}
+				if err := tx.CreateNode("infra", node); err != nil {
+					return fmt.Errorf("failed to create node %s: %w", node.ID, err)
+				}
+			}
+			return nil
+		})
+		if !errors.Is(err, storage.ErrTxnTooBig) {
+			t.Errorf("Expected ErrTxnTooBig, got %v", err)
+		}
+	})
+
+	t.Run("Error Codes", func(t *testing.T) {
+		conn, err := net.Dial("tcp", startTestServer(t, engine, redis.DefaultConfig()))
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		send := func(args ...string) string {
+			t.Helper()
+			if _, err := conn.Write([]byte(encodeCommand(args...))); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+			reply, err := readReply(r)
+			if err != nil {
+				t.Fatalf("Failed to read reply: %v", err)
+			}
+			return reply[0]
+		}
+
+		// A BADARG wrapped in a handler's message keeps the ERR prefix
+		document := fmt.Sprintf(`{"graph":{"id":"x"},"nodes":[{"id":"a","type":"t","attributes":{%q:1}}],"edges":[]}`, strings.Repeat("k", 300))
+		if reply := send("GRAPH.IMPORT", "copy", document); !strings.HasPrefix(reply, "-ERR failed to import graph") || !strings.Contains(reply, "BADARG") {
+			t.Errorf("Expected a wrapped BADARG to be sent as ERR, got %s", reply)
+		}
+		if reply := send("NODE.CREATE", "infra", "bad", "service", fmt.Sprintf(`{%q:1}`, strings.Repeat("k", 300))); !strings.HasPrefix(reply, "-BADARG") {
+			t.Errorf("Expected BADARG, got %s", reply)
+		}
+		if reply := send("NODE.GET", "infra", "missing"); reply != "-ERR failed to get node: node not found: missing" {
+			t.Errorf("Expected an unchanged not found reply, got %s", reply)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		closed := storage.NewBadgerEngine()
+		if _, err := redis.NewCommandHandler(closed).Handle("GRAPH.GET", []string{"infra"}); !errors.Is(err, storage.ErrClosed) {
+			t.Errorf("Expected ErrClosed through the handler, got %v", err)
+		}
+		if err := closed.CreateGraph(&models.Graph{ID: "infra"}); !errors.Is(err, storage.ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	})
+}
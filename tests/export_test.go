@@ -0,0 +1,441 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// createLargeGraph creates a graph of n nodes linked into a chain by n-1
+// edges, in transactions of 1000 entities
+func createLargeGraph(t *testing.T, engine *storage.BadgerEngine, graphID models.GraphID, n int) {
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID), Description: "generated"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for start := 0; start < n; start += 1000 {
+		err := engine.RunTransaction(func(tx storage.Transaction) error {
+			for i := start; i < start+1000 && i < n; i++ {
+				node := &models.Node{
+					ID:         models.NodeID(fmt.Sprintf("node-%06d", i)),
+					Type:       "service",
+					Attributes: models.Attributes{"index": i, "name": fmt.Sprintf("Service %d", i)},
+				}
+				if err := tx.CreateNode(graphID, node); err != nil {
+					return err
+				}
+				if i == 0 {
+					continue
+				}
+				edge := &models.Edge{
+					ID:         models.EdgeID(fmt.Sprintf("edge-%06d", i)),
+					Type:       "calls",
+					FromNodeID: models.NodeID(fmt.Sprintf("node-%06d", i-1)),
+					ToNodeID:   node.ID,
+					Attributes: models.Attributes{"weight": 1.5},
+				}
+				if err := tx.CreateEdge(graphID, edge); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to create graph contents: %v", err)
+		}
+	}
+}
+
+// TestChunkedExportImport tests GRAPH.EXPORT and GRAPH.IMPORT, whole and in
+// chunks
+func TestChunkedExportImport(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_export_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	handler := redis.NewCommandHandler(engine)
+	// Chunked transfers belong to the session that started them
+	session := &commands.Session{}
+
+	const chunkBytes = 64 << 10
+	createLargeGraph(t, engine, "large", 50000)
+
+	var document strings.Builder
+	t.Run("ChunkedExport", func(t *testing.T) {
+		resp, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"large", "CHUNKED", strconv.Itoa(chunkBytes), "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT BEGIN failed: %v", err)
+		}
+		sessionID := resp.ArrayValue[0]
+		estimated, _ := strconv.Atoi(resp.ArrayValue[1])
+
+		chunks := 0
+		for {
+			resp, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"NEXT", sessionID})
+			if err != nil {
+				t.Fatalf("GRAPH.EXPORT NEXT failed after %d chunks: %v", chunks, err)
+			}
+			chunk, seq, last := resp.ArrayValue[0], resp.ArrayValue[1], resp.ArrayValue[2]
+			chunks++
+			if seq != strconv.Itoa(chunks) {
+				t.Fatalf("Expected sequence number %d, got %s", chunks, seq)
+			}
+			if len(chunk) > chunkBytes || (last == "0" && len(chunk) != chunkBytes) {
+				t.Fatalf("Chunk %d has %d bytes", chunks, len(chunk))
+			}
+			document.WriteString(chunk)
+			if last == "1" {
+				break
+			}
+		}
+
+		if _, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"NEXT", sessionID}); err == nil {
+			t.Error("Expected the session to end after the last chunk")
+		}
+		if estimated < chunks*3/4 || estimated > chunks*4/3 {
+			t.Errorf("Estimated %d chunks, got %d", estimated, chunks)
+		}
+
+		var data models.SnapshotData
+		if err := json.Unmarshal([]byte(document.String()), &data); err != nil {
+			t.Fatalf("Reassembled export is not valid JSON: %v", err)
+		}
+		if len(data.Nodes) != 50000 || len(data.Edges) != 49999 || data.Graph.Description != "generated" {
+			t.Errorf("Expected 50000 nodes and 49999 edges, got %d and %d", len(data.Nodes), len(data.Edges))
+		}
+
+		whole, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"large"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT failed: %v", err)
+		}
+		if whole.StringValue != document.String() {
+			t.Error("Expected the chunks to form the same document as a whole export")
+		}
+	})
+
+	t.Run("ChunkedImport", func(t *testing.T) {
+		resp, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"large-copy", "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.IMPORT BEGIN failed: %v", err)
+		}
+		sessionID := resp.StringValue
+
+		data := document.String()
+		for start := 0; start < len(data); start += chunkBytes {
+			end := min(start+chunkBytes, len(data))
+			resp, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"APPEND", sessionID, data[start:end]})
+			if err != nil {
+				t.Fatalf("GRAPH.IMPORT APPEND failed: %v", err)
+			}
+			if resp.IntValue != int64(end) {
+				t.Fatalf("Expected %d bytes received, got %d", end, resp.IntValue)
+			}
+		}
+		if _, err := engine.GetGraph("large-copy"); err == nil {
+			t.Fatal("Expected nothing to be written before COMMIT")
+		}
+
+		resp, err = handler.HandleSession(session, "GRAPH.IMPORT", []string{"COMMIT", sessionID})
+		if err != nil {
+			t.Fatalf("GRAPH.IMPORT COMMIT failed: %v", err)
+		}
+		if resp.ArrayValue[0] != "50000" || resp.ArrayValue[1] != "49999" {
+			t.Errorf("Expected 50000 nodes and 49999 edges imported, got %v", resp.ArrayValue)
+		}
+
+		for _, graphID := range []models.GraphID{"large", "large-copy"} {
+			nodes, _ := engine.CountNodes(graphID)
+			edges, _ := engine.CountEdges(graphID)
+			if nodes != 50000 || edges != 49999 {
+				t.Errorf("Expected %s to have 50000 nodes and 49999 edges, got %d and %d", graphID, nodes, edges)
+			}
+		}
+		edge, err := engine.GetEdge("large-copy", "edge-012345")
+		if err != nil || edge.FromNodeID != "node-012344" || edge.Attributes["weight"] != 1.5 {
+			t.Errorf("Expected imported edge to match, got %+v, %v", edge, err)
+		}
+		outgoing, err := engine.GetOutgoingEdges("large-copy", "node-012344")
+		if err != nil || len(outgoing) != 1 {
+			t.Errorf("Expected imported edges to be indexed, got %v, %v", outgoing, err)
+		}
+	})
+
+	t.Run("WholeImport", func(t *testing.T) {
+		doc := `{"graph":{"description":"small"},"nodes":[{"id":"a","type":"service"},{"id":"b","type":"service"}],"edges":[{"id":"ab","type":"calls","from_node_id":"a","to_node_id":"b"}]}`
+		resp, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"small", doc})
+		if err != nil {
+			t.Fatalf("GRAPH.IMPORT failed: %v", err)
+		}
+		if resp.ArrayValue[0] != "2" || resp.ArrayValue[1] != "1" {
+			t.Errorf("Expected 2 nodes and 1 edge imported, got %v", resp.ArrayValue)
+		}
+		graph, err := engine.GetGraph("small")
+		if err != nil || graph.Name != "small" || graph.Description != "small" {
+			t.Errorf("Expected imported graph metadata, got %+v, %v", graph, err)
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"small", doc}); err == nil {
+			t.Error("Expected error importing over an existing graph")
+		}
+	})
+
+	t.Run("ValidatedAtCommit", func(t *testing.T) {
+		tests := []struct {
+			name string
+			doc  string
+		}{
+			{"UnknownNode", `{"graph":{},"nodes":[{"id":"a","type":"service"}],"edges":[{"id":"ab","type":"calls","from_node_id":"a","to_node_id":"b"}]}`},
+			{"DuplicateNode", `{"graph":{},"nodes":[{"id":"a","type":"service"},{"id":"a","type":"service"}],"edges":[]}`},
+			{"ForbiddenSelfLoop", `{"graph":{"allow_self_loops":false},"nodes":[{"id":"a","type":"service"}],"edges":[{"id":"aa","type":"calls","from_node_id":"a","to_node_id":"a"}]}`},
+			{"Truncated", `{"graph":{},"nodes":[{"id":"a","type":"service"}`},
+			{"TrailingData", `{"graph":{},"nodes":[],"edges":[]}{}`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"invalid", "BEGIN"})
+				if err != nil {
+					t.Fatalf("GRAPH.IMPORT BEGIN failed: %v", err)
+				}
+				sessionID := resp.StringValue
+				half := len(tt.doc) / 2
+				for _, part := range []string{tt.doc[:half], tt.doc[half:]} {
+					if _, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"APPEND", sessionID, part}); err != nil {
+						t.Fatalf("Expected APPEND to accept any data, got %v", err)
+					}
+				}
+				if _, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"COMMIT", sessionID}); err == nil {
+					t.Fatal("Expected COMMIT to reject the document")
+				}
+				if _, err := engine.GetGraph("invalid"); err == nil {
+					t.Error("Expected no graph after a rejected import")
+				}
+				if _, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"COMMIT", sessionID}); err == nil {
+					t.Error("Expected the session to end after COMMIT")
+				}
+			})
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		resp, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"large", "CHUNKED", "1024", "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT BEGIN failed: %v", err)
+		}
+		exportID := resp.ArrayValue[0]
+		if _, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"NEXT", exportID}); err != nil {
+			t.Fatalf("GRAPH.EXPORT NEXT failed: %v", err)
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"ABORT", exportID}); err != nil {
+			t.Fatalf("GRAPH.EXPORT ABORT failed: %v", err)
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"NEXT", exportID}); err == nil {
+			t.Error("Expected NEXT to fail after ABORT")
+		}
+
+		resp, err = handler.HandleSession(session, "GRAPH.IMPORT", []string{"aborted", "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.IMPORT BEGIN failed: %v", err)
+		}
+		importID := resp.StringValue
+		if _, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"ABORT", importID}); err != nil {
+			t.Fatalf("GRAPH.IMPORT ABORT failed: %v", err)
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"APPEND", importID, "{}"}); err == nil {
+			t.Error("Expected APPEND to fail after ABORT")
+		}
+	})
+
+	t.Run("Ownership", func(t *testing.T) {
+		resp, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"large", "CHUNKED", "1024", "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT BEGIN failed: %v", err)
+		}
+		exportID := resp.ArrayValue[0]
+		other := &commands.Session{}
+		for _, args := range [][]string{{"NEXT", exportID}, {"ABORT", exportID}} {
+			if _, err := handler.HandleSession(other, "GRAPH.EXPORT", args); err == nil || !strings.Contains(err.Error(), "unknown export session") {
+				t.Errorf("Expected %s from another connection to fail, got %v", args[0], err)
+			}
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"NEXT", exportID}); err != nil {
+			t.Errorf("Expected the owner to keep its session: %v", err)
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"ABORT", exportID}); err != nil {
+			t.Errorf("GRAPH.EXPORT ABORT failed: %v", err)
+		}
+
+		resp, err = handler.HandleSession(session, "GRAPH.IMPORT", []string{"owned", "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.IMPORT BEGIN failed: %v", err)
+		}
+		importID := resp.StringValue
+		for _, args := range [][]string{{"APPEND", importID, "{}"}, {"COMMIT", importID}, {"ABORT", importID}} {
+			if _, err := handler.HandleSession(other, "GRAPH.IMPORT", args); err == nil || !strings.Contains(err.Error(), "unknown import session") {
+				t.Errorf("Expected %s from another connection to fail, got %v", args[0], err)
+			}
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"ABORT", importID}); err != nil {
+			t.Errorf("Expected the owner to abort its import: %v", err)
+		}
+	})
+
+	t.Run("SessionLimits", func(t *testing.T) {
+		begin := func(session *commands.Session) (string, error) {
+			resp, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"large", "CHUNKED", "1024", "BEGIN"})
+			if err != nil {
+				return "", err
+			}
+			return resp.ArrayValue[0], nil
+		}
+		type open struct {
+			session *commands.Session
+			id      string
+		}
+		var opened []open
+		defer func() {
+			for _, o := range opened {
+				handler.HandleSession(o.session, "GRAPH.EXPORT", []string{"ABORT", o.id})
+			}
+		}()
+
+		// Each connection may hold 4 sessions
+		for i := 0; i < 4; i++ {
+			id, err := begin(session)
+			if err != nil {
+				t.Fatalf("GRAPH.EXPORT BEGIN %d failed: %v", i+1, err)
+			}
+			opened = append(opened, open{session, id})
+		}
+		if _, err := begin(session); !errors.Is(err, commands.ErrTransferBusy) || !strings.HasPrefix(err.Error(), "BUSY") {
+			t.Errorf("Expected a fifth session to be rejected with BUSY, got %v", err)
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.IMPORT", []string{"busy", "BEGIN"}); !errors.Is(err, commands.ErrTransferBusy) {
+			t.Errorf("Expected an import over the limit to be rejected with BUSY, got %v", err)
+		}
+		if _, err := handler.HandleSession(session, "GRAPH.EXPORT", []string{"ABORT", opened[0].id}); err != nil {
+			t.Fatalf("GRAPH.EXPORT ABORT failed: %v", err)
+		}
+		opened = opened[1:]
+		id, err := begin(session)
+		if err != nil {
+			t.Fatalf("Expected a session to be accepted after one ended: %v", err)
+		}
+		opened = append(opened, open{session, id})
+
+		// The server holds 64 sessions across all connections
+		for len(opened) < 64 {
+			other := &commands.Session{}
+			for i := 0; i < 4 && len(opened) < 64; i++ {
+				id, err := begin(other)
+				if err != nil {
+					t.Fatalf("GRAPH.EXPORT BEGIN %d failed: %v", len(opened)+1, err)
+				}
+				opened = append(opened, open{other, id})
+			}
+		}
+		if _, err := begin(&commands.Session{}); !errors.Is(err, commands.ErrTransferBusy) {
+			t.Errorf("Expected a session over the server limit to be rejected with BUSY, got %v", err)
+		}
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		shortHandler := redis.NewCommandHandler(engine, redis.WithTransferTimeout(50*time.Millisecond))
+		resp, err := shortHandler.HandleSession(session, "GRAPH.EXPORT", []string{"large", "CHUNKED", "1024", "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT BEGIN failed: %v", err)
+		}
+		exportID := resp.ArrayValue[0]
+		resp, err = shortHandler.HandleSession(session, "GRAPH.IMPORT", []string{"expired", "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.IMPORT BEGIN failed: %v", err)
+		}
+		importID := resp.StringValue
+
+		time.Sleep(200 * time.Millisecond)
+		if _, err := shortHandler.HandleSession(session, "GRAPH.EXPORT", []string{"NEXT", exportID}); err == nil {
+			t.Error("Expected the idle export session to expire")
+		}
+		if _, err := shortHandler.HandleSession(session, "GRAPH.IMPORT", []string{"APPEND", importID, "{}"}); err == nil {
+			t.Error("Expected the idle import session to expire")
+		}
+	})
+
+	t.Run("ConnectionClose", func(t *testing.T) {
+		addr := startTestServer(t, engine, redis.DefaultConfig())
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		reader := bufio.NewReader(conn)
+		fmt.Fprint(conn, encodeCommand("GRAPH.EXPORT", "large", "CHUNKED", "1024", "BEGIN"))
+		reply, err := readReply(reader)
+		if err != nil || len(reply) != 3 {
+			t.Fatalf("GRAPH.EXPORT BEGIN failed: %v, %v", reply, err)
+		}
+		sessionID := reply[1]
+		fmt.Fprint(conn, encodeCommand("GRAPH.EXPORT", "NEXT", sessionID))
+		if reply, err := readReply(reader); err != nil || len(reply) != 4 || reply[3] != "0" {
+			t.Fatalf("GRAPH.EXPORT NEXT failed: %v, %v", reply, err)
+		}
+		conn.Close()
+
+		other, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer other.Close()
+		otherReader := bufio.NewReader(other)
+		for attempt := 0; ; attempt++ {
+			fmt.Fprint(other, encodeCommand("GRAPH.EXPORT", "NEXT", sessionID))
+			reply, err := readReply(otherReader)
+			if err != nil {
+				t.Fatalf("Failed to read reply: %v", err)
+			}
+			if strings.HasPrefix(reply[0], "-") {
+				break
+			}
+			if attempt == 50 {
+				t.Fatal("Expected the session to end when its connection closed")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			command string
+			args    []string
+		}{
+			{"MissingGraph", "GRAPH.EXPORT", []string{"missing-graph"}},
+			{"MissingGraphChunked", "GRAPH.EXPORT", []string{"missing-graph", "CHUNKED", "1024", "BEGIN"}},
+			{"ZeroChunk", "GRAPH.EXPORT", []string{"large", "CHUNKED", "0", "BEGIN"}},
+			{"UnknownExportSession", "GRAPH.EXPORT", []string{"NEXT", "missing-session"}},
+			{"ImportOverExisting", "GRAPH.IMPORT", []string{"large", "BEGIN"}},
+			{"UnknownImportSession", "GRAPH.IMPORT", []string{"COMMIT", "missing-session"}},
+			{"BadArguments", "GRAPH.IMPORT", []string{"large"}},
+		}
+		for _, tt := range tests {
+			if _, err := handler.Handle(tt.command, tt.args); err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+		}
+	})
+}
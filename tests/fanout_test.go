@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// createStarGraph creates a hub node with an outgoing "uses" edge to each of
+// n leaves. Leaf i is leaf-%05d, reached by edge-%05d.
+func createStarGraph(t *testing.T, engine *storage.BadgerEngine, graphID models.GraphID, n int) {
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID), Description: "star"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	if err := engine.CreateNode(graphID, &models.Node{ID: "hub", Type: "library"}); err != nil {
+		t.Fatalf("Failed to create hub: %v", err)
+	}
+	for start := 0; start < n; start += 1000 {
+		err := engine.RunTransaction(func(tx storage.Transaction) error {
+			for i := start; i < start+1000 && i < n; i++ {
+				leaf := &models.Node{ID: models.NodeID(fmt.Sprintf("leaf-%05d", i)), Type: "service"}
+				if err := tx.CreateNode(graphID, leaf); err != nil {
+					return err
+				}
+				edge := &models.Edge{
+					ID:         models.EdgeID(fmt.Sprintf("edge-%05d", i)),
+					Type:       "uses",
+					FromNodeID: "hub",
+					ToNodeID:   leaf.ID,
+				}
+				if err := tx.CreateEdge(graphID, edge); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to create star graph: %v", err)
+		}
+	}
+}
+
+// TestTraversalFanout tests that MaxFanout bounds the edges expanded from a
+// hub node and reports the hub as truncated
+func TestTraversalFanout(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_fanout_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	createStarGraph(t, engine, "star", 10000)
+	analyzer := analysis.NewGraphAnalyzer(engine)
+
+	limited := func(strategy types.FanoutStrategy, seed int64) *types.TraversalOptions {
+		return &types.TraversalOptions{
+			MaxDepth:       -1,
+			Direction:      types.DirectionForward,
+			MaxFanout:      5,
+			FanoutStrategy: strategy,
+			FanoutSeed:     seed,
+		}
+	}
+
+	t.Run("DepthFirstSearch", func(t *testing.T) {
+		start := time.Now()
+		result, err := analyzer.DepthFirstSearch("star", "hub", limited(types.FanoutFirst, 0))
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected a limited traversal to complete quickly, took %v", elapsed)
+		}
+
+		expected := []models.NodeID{"hub", "leaf-00000", "leaf-00001", "leaf-00002", "leaf-00003", "leaf-00004"}
+		if !reflect.DeepEqual(result.Path, expected) {
+			t.Errorf("Expected %v, got %v", expected, result.Path)
+		}
+		if !reflect.DeepEqual(result.FanoutLimitedNodes, []models.NodeID{"hub"}) {
+			t.Errorf("Expected the hub to be limited, got %v", result.FanoutLimitedNodes)
+		}
+	})
+
+	t.Run("RandomIsSeeded", func(t *testing.T) {
+		first, err := analyzer.DepthFirstSearch("star", "hub", limited(types.FanoutRandom, 42))
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		again, err := analyzer.DepthFirstSearch("star", "hub", limited(types.FanoutRandom, 42))
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		if len(first.Path) != 6 {
+			t.Fatalf("Expected 6 nodes, got %d", len(first.Path))
+		}
+		if !reflect.DeepEqual(first.Path, again.Path) {
+			t.Errorf("Expected the same seed to sample the same leaves, got %v and %v", first.Path, again.Path)
+		}
+
+		other, err := analyzer.DepthFirstSearch("star", "hub", limited(types.FanoutRandom, 7))
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		if reflect.DeepEqual(first.Path, other.Path) {
+			t.Errorf("Expected different seeds to sample different leaves, got %v twice", first.Path)
+		}
+	})
+
+	t.Run("AllPathsTraversal", func(t *testing.T) {
+		paths, err := analyzer.AllPathsTraversal("star", "hub", limited(types.FanoutFirst, 0))
+		if err != nil {
+			t.Fatalf("AllPathsTraversal failed: %v", err)
+		}
+		if len(paths) != 5 {
+			t.Fatalf("Expected 5 paths, got %d", len(paths))
+		}
+		for _, path := range paths {
+			if !reflect.DeepEqual(path.FanoutLimitedNodes, []models.NodeID{"hub"}) {
+				t.Errorf("Expected every path to report the hub, got %v", path.FanoutLimitedNodes)
+			}
+		}
+	})
+
+	t.Run("ShortestPath", func(t *testing.T) {
+		if _, err := analyzer.GetShortestPath("star", "hub", "leaf-09999", limited(types.FanoutFirst, 0)); err == nil {
+			t.Error("Expected a leaf outside the expanded edges to be unreachable")
+		}
+		result, err := analyzer.GetShortestPath("star", "hub", "leaf-00003", limited(types.FanoutFirst, 0))
+		if err != nil {
+			t.Fatalf("GetShortestPath failed: %v", err)
+		}
+		if !reflect.DeepEqual(result.FanoutLimitedNodes, []models.NodeID{"hub"}) {
+			t.Errorf("Expected the hub to be limited, got %v", result.FanoutLimitedNodes)
+		}
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		result, err := analyzer.DepthFirstSearch("star", "hub", &types.TraversalOptions{MaxDepth: -1, MaxFanout: 10000})
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		if len(result.Path) != 10001 || result.FanoutLimitedNodes != nil {
+			t.Errorf("Expected a fanout at the limit to be untouched, got %d nodes and %v", len(result.Path), result.FanoutLimitedNodes)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+
+		resp, err := handler.Handle("ANALYSIS.TRAVERSE", []string{"star", "hub", "FORMAT", "simple", "MAXFANOUT", "3"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		expected := []string{"hub:library", "leaf-00000:service", "leaf-00001:service", "leaf-00002:service", "fanout_limited", "hub"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		resp, err = handler.Handle("ANALYSIS.TRAVERSE", []string{"star", "hub", "MAXFANOUT", "2", "STRATEGY", "random", "SEED", "9"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 4 || resp.ArrayValue[2] != "fanout_limited" {
+			t.Errorf("Expected 2 paths followed by the limited hub, got %v", resp.ArrayValue)
+		}
+
+		for _, args := range [][]string{
+			{"star", "hub", "MAXFANOUT", "0"},
+			{"star", "hub", "MAXFANOUT", "x"},
+			{"star", "hub", "STRATEGY", "first"},
+			{"star", "hub", "MAXFANOUT", "2", "STRATEGY", "widest"},
+			{"star", "hub", "MAXFANOUT", "2", "SEED", "9"},
+			{"star", "hub", "MAXFANOUT", "2", "STRATEGY", "random", "SEED", "x"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.TRAVERSE", args); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestUpdatedBeforeAndAge tests the UPDATEDBEFORE filter and the AGE output flag
+func TestUpdatedBeforeAndAge(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_freshness_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("freshness-test-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+
+	cutoff := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	nodes := []*models.Node{
+		{ID: "root", Type: "service", UpdatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Attributes: map[string]interface{}{"team": "core"}},
+		{ID: "stale", Type: "service", UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Attributes: map[string]interface{}{"team": "core"}},
+		{ID: "boundary", Type: "service", UpdatedAt: cutoff, Attributes: map[string]interface{}{"team": "core"}},
+		{ID: "legacy", Type: "database", Attributes: map[string]interface{}{"team": "data"}},
+	}
+	for _, node := range nodes {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "root-stale", FromNodeID: "root", ToNodeID: "stale", Type: "calls"},
+		{ID: "stale-boundary", FromNodeID: "stale", ToNodeID: "boundary", Type: "calls"},
+		{ID: "boundary-legacy", FromNodeID: "boundary", ToNodeID: "legacy", Type: "reads"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	nodeCommands := commands.NewNodeCommands(engine)
+	analysisCommands := commands.NewAnalysisCommands(engine)
+
+	// filteredIDs extracts the node IDs from a NODE.FILTER response
+	filteredIDs := func(values []string) []string {
+		var ids []string
+		for i := 0; i < len(values); i += 3 {
+			ids = append(ids, values[i])
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	t.Run("FilterCutoffBoundary", func(t *testing.T) {
+		resp, err := nodeCommands.Handle("FILTER", []string{string(graphID), "UPDATEDBEFORE", cutoff.Format(time.RFC3339)})
+		if err != nil {
+			t.Fatalf("NODE.FILTER failed: %v", err)
+		}
+		// The boundary node is not strictly before the cutoff; the legacy node
+		// has no timestamp and always matches.
+		expected := []string{"legacy", "stale"}
+		if got := filteredIDs(resp.ArrayValue); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+
+		resp, err = nodeCommands.Handle("FILTER", []string{string(graphID), "UPDATEDBEFORE", cutoff.Add(time.Second).Format(time.RFC3339)})
+		if err != nil {
+			t.Fatalf("NODE.FILTER failed: %v", err)
+		}
+		expected = []string{"boundary", "legacy", "stale"}
+		if got := filteredIDs(resp.ArrayValue); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("FilterWithAttribute", func(t *testing.T) {
+		resp, err := nodeCommands.Handle("FILTER", []string{string(graphID), "team", "core", "UPDATEDBEFORE", cutoff.Format(time.RFC3339)})
+		if err != nil {
+			t.Fatalf("NODE.FILTER failed: %v", err)
+		}
+		expected := []string{"stale"}
+		if got := filteredIDs(resp.ArrayValue); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("FilterSeconds", func(t *testing.T) {
+		// Everything with a timestamp is older than a minute ago
+		resp, err := nodeCommands.Handle("FILTER", []string{string(graphID), "UPDATEDBEFORE", "60"})
+		if err != nil {
+			t.Fatalf("NODE.FILTER failed: %v", err)
+		}
+		expected := []string{"boundary", "legacy", "root", "stale"}
+		if got := filteredIDs(resp.ArrayValue); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+
+		if _, err := nodeCommands.Handle("FILTER", []string{string(graphID), "UPDATEDBEFORE", "yesterday"}); err == nil {
+			t.Error("Expected error for an invalid UPDATEDBEFORE value")
+		}
+	})
+
+	t.Run("TraverseUpdatedBefore", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("TRAVERSE", []string{string(graphID), "root", "DIRECTION", "out", "FORMAT", "simple", "UPDATEDBEFORE", cutoff.Format(time.RFC3339)})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		// Traversal passes through fresh nodes but only reports stale ones
+		expected := []string{"stale:service", "legacy:database"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("TraverseAge", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("TRAVERSE", []string{string(graphID), "root", "DIRECTION", "out", "FORMAT", "simple", "AGE", "NODETYPES", "database", "service"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		expected := []string{
+			"root:service@2025-01-01T00:00:00Z",
+			"stale:service@2024-01-01T00:00:00Z",
+			"boundary:service@2024-06-01T00:00:00Z",
+			"legacy:database@unknown",
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("ListAge", func(t *testing.T) {
+		resp, err := nodeCommands.Handle("LIST", []string{string(graphID), "AGE"})
+		if err != nil {
+			t.Fatalf("NODE.LIST failed: %v", err)
+		}
+		got := append([]string(nil), resp.ArrayValue...)
+		sort.Strings(got)
+		expected := []string{
+			"boundary:service@2024-06-01T00:00:00Z",
+			"legacy:database@unknown",
+			"root:service@2025-01-01T00:00:00Z",
+			"stale:service@2024-01-01T00:00:00Z",
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("CreateSetsTimestamps", func(t *testing.T) {
+		if _, err := nodeCommands.Handle("CREATE", []string{string(graphID), "fresh", "service"}); err != nil {
+			t.Fatalf("NODE.CREATE failed: %v", err)
+		}
+		node, err := engine.GetNode(graphID, "fresh")
+		if err != nil {
+			t.Fatalf("Failed to get node: %v", err)
+		}
+		if node.CreatedAt.IsZero() || node.UpdatedAt.IsZero() {
+			t.Errorf("Expected NODE.CREATE to record timestamps, got created %v updated %v", node.CreatedAt, node.UpdatedAt)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestGenerationConcurrency tests GRAPH.GENERATION and the IFGEN option of
+// node and edge writes with two clients writing to the same graph
+func TestGenerationConcurrency(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_generation_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	// Two clients on the same database
+	alice := redis.NewCommandHandler(engine)
+	bob := redis.NewCommandHandler(engine)
+	if _, err := alice.Handle("GRAPH.CREATE", []string{"shared"}); err != nil {
+		t.Fatalf("GRAPH.CREATE failed: %v", err)
+	}
+
+	generation := func(t *testing.T) string {
+		t.Helper()
+		resp, err := alice.Handle("GRAPH.GENERATION", []string{"shared"})
+		if err != nil {
+			t.Fatalf("GRAPH.GENERATION failed: %v", err)
+		}
+		return strconv.FormatInt(resp.IntValue, 10)
+	}
+	expectConflict := func(t *testing.T, err error) {
+		t.Helper()
+		if !errors.Is(err, storage.ErrGenerationConflict) || !strings.HasPrefix(err.Error(), "CONFLICT") {
+			t.Fatalf("Expected a CONFLICT error, got %v", err)
+		}
+	}
+
+	t.Run("Unwritten Graph", func(t *testing.T) {
+		if gen := generation(t); gen != "0" {
+			t.Errorf("Expected a graph never written to to be at generation 0, got %s", gen)
+		}
+		if _, err := alice.Handle("GRAPH.GENERATION", []string{"missing"}); err == nil {
+			t.Error("Expected GRAPH.GENERATION of a missing graph to fail")
+		}
+	})
+
+	t.Run("Interleaved Update", func(t *testing.T) {
+		if _, err := alice.Handle("NODE.CREATE", []string{"shared", "api", "service", "IFGEN", "0"}); err != nil {
+			t.Fatalf("NODE.CREATE at generation 0 failed: %v", err)
+		}
+		read := generation(t)
+		if read == "0" {
+			t.Fatal("Expected the write to advance the generation")
+		}
+
+		// Bob writes between Alice's read and her write
+		if _, err := bob.Handle("NODE.UPDATE", []string{"shared", "api", "ATTRIBUTES", `{"owner":"bob"}`}); err != nil {
+			t.Fatalf("Bob's NODE.UPDATE failed: %v", err)
+		}
+		_, err := alice.Handle("NODE.UPDATE", []string{"shared", "api", "ATTRIBUTES", `{"owner":"alice"}`, "IFGEN", read})
+		expectConflict(t, err)
+		if node, err := engine.GetNode("shared", "api"); err != nil || node.Attributes["owner"] != "bob" {
+			t.Fatalf("Expected the conflicting write to change nothing, got %v, %v", node, err)
+		}
+
+		// Retrying with the fresh generation succeeds
+		fresh := generation(t)
+		if fresh == read {
+			t.Fatal("Expected Bob's write to advance the generation")
+		}
+		if _, err := alice.Handle("NODE.UPDATE", []string{"shared", "api", "ATTRIBUTES", `{"owner":"alice"}`, "IFGEN", fresh}); err != nil {
+			t.Fatalf("Retry with the fresh generation failed: %v", err)
+		}
+		if node, err := engine.GetNode("shared", "api"); err != nil || node.Attributes["owner"] != "alice" {
+			t.Errorf("Expected the retried write to apply, got %v, %v", node, err)
+		}
+	})
+
+	t.Run("Node And Edge Writes", func(t *testing.T) {
+		if _, err := bob.Handle("NODE.CREATE", []string{"shared", "db", "database"}); err != nil {
+			t.Fatalf("NODE.CREATE failed: %v", err)
+		}
+		commands := []struct {
+			command string
+			args    []string
+		}{
+			{"NODE.CREATE", []string{"shared", "cache", "cache", `{"size":1}`, "TTL", "3600"}},
+			{"EDGE.CREATE", []string{"shared", "reads", "api", "db", "reads"}},
+			{"EDGE.UPDATE", []string{"shared", "reads", `{"pool":10}`}},
+			{"EDGE.DELETE", []string{"shared", "reads"}},
+			{"NODE.DELETE", []string{"shared", "cache"}},
+		}
+		for _, c := range commands {
+			stale := generation(t)
+			if _, err := bob.Handle("NODE.UPDATE", []string{"shared", "db", "ATTRIBUTES", `{"touched":"` + c.command + `"}`}); err != nil {
+				t.Fatalf("Bob's NODE.UPDATE failed: %v", err)
+			}
+			_, err := alice.Handle(c.command, append(append([]string{}, c.args...), "IFGEN", stale))
+			expectConflict(t, err)
+			if _, err := alice.Handle(c.command, append(append([]string{}, c.args...), "IFGEN", generation(t))); err != nil {
+				t.Fatalf("%s with the fresh generation failed: %v", c.command, err)
+			}
+		}
+		if _, err := engine.GetNode("shared", "cache"); err == nil {
+			t.Error("Expected NODE.DELETE with IFGEN to delete the node")
+		}
+	})
+
+	t.Run("Invalid Generation", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"shared", "api", "IFGEN"},
+			{"shared", "api", "IFGEN", "-1"},
+			{"shared", "api", "IFGEN", "latest"},
+		} {
+			if _, err := alice.Handle("NODE.DELETE", args); err == nil || errors.Is(err, storage.ErrGenerationConflict) {
+				t.Errorf("Expected %v to fail as invalid, got %v", args, err)
+			}
+		}
+		if _, err := engine.GetNode("shared", "api"); err != nil {
+			t.Error("Expected invalid IFGEN values to delete nothing")
+		}
+	})
+
+	t.Run("Write During Transaction", func(t *testing.T) {
+		gen, err := engine.CommittedGeneration("shared")
+		if err != nil {
+			t.Fatalf("CommittedGeneration failed: %v", err)
+		}
+		// Another write commits after the generation was checked but before
+		// the checking transaction commits
+		err = engine.RunTransaction(func(tx storage.Transaction) error {
+			if err := tx.RequireGeneration("shared", gen); err != nil {
+				return err
+			}
+			if err := engine.CreateNode("shared", &models.Node{ID: "worker", Type: "service"}); err != nil {
+				t.Fatalf("Concurrent CreateNode failed: %v", err)
+			}
+			return tx.CreateNode("shared", &models.Node{ID: "queue", Type: "queue"})
+		})
+		expectConflict(t, err)
+		if _, err := engine.GetNode("shared", "queue"); err == nil {
+			t.Error("Expected the conflicting transaction to write nothing")
+		}
+		if _, err := engine.GetNode("shared", "worker"); err != nil {
+			t.Error("Expected the concurrent write to commit")
+		}
+
+		// Writes without IFGEN do not conflict with each other
+		err = engine.RunTransaction(func(tx storage.Transaction) error {
+			if err := engine.CreateNode("shared", &models.Node{ID: "scheduler", Type: "service"}); err != nil {
+				t.Fatalf("Concurrent CreateNode failed: %v", err)
+			}
+			return tx.CreateNode("shared", &models.Node{ID: "queue", Type: "queue"})
+		})
+		if err != nil {
+			t.Errorf("Expected writes without IFGEN not to conflict, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestGraphAttributes tests graph metadata attributes and the GRAPH.*ATTR commands
+func TestGraphAttributes(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_graph_attr_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	// Write a graph record as an older version (no attributes) and a newer
+	// version (an unknown field) would have stored it
+	legacy := `{"id":"legacy","name":"legacy","description":"old graph","created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z"}`
+	future := `{"id":"future","name":"future","description":"","created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z","attributes":{},"retention":{"days":30}}`
+	db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open raw database: %v", err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(utils.EncodeGraphKey("legacy"), []byte(legacy)); err != nil {
+			return err
+		}
+		return txn.Set(utils.EncodeGraphKey("future"), []byte(future))
+	})
+	if err != nil {
+		t.Fatalf("Failed to write raw graphs: %v", err)
+	}
+	db.Close()
+
+	engine := storage.NewBadgerEngine()
+	defer engine.Close()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphCommands := commands.NewGraphCommands(engine)
+	for _, name := range []string{"payments", "search"} {
+		if _, err := graphCommands.Handle("CREATE", []string{name}); err != nil {
+			t.Fatalf("GRAPH.CREATE failed: %v", err)
+		}
+	}
+
+	t.Run("LegacyGraph", func(t *testing.T) {
+		graph, err := engine.GetGraph("legacy")
+		if err != nil {
+			t.Fatalf("Failed to get legacy graph: %v", err)
+		}
+		if graph.Attributes == nil || len(graph.Attributes) != 0 {
+			t.Errorf("Expected empty attributes map, got %#v", graph.Attributes)
+		}
+
+		resp, err := graphCommands.Handle("GET", []string{"legacy"})
+		if err != nil {
+			t.Fatalf("GRAPH.GET failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 6 || resp.ArrayValue[5] != "{}" {
+			t.Errorf("Expected empty attributes JSON in GRAPH.GET, got %v", resp.ArrayValue)
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"payments", "owner", "payments-team"},
+			{"payments", "environment", `"prod"`},
+			{"payments", "schedule", `{"cron":"0 * * * *","enabled":true}`},
+			{"search", "environment", `"staging"`},
+		} {
+			if _, err := graphCommands.Handle("SETATTR", args); err != nil {
+				t.Fatalf("GRAPH.SETATTR %v failed: %v", args, err)
+			}
+		}
+
+		resp, err := graphCommands.Handle("GETATTR", []string{"payments", "schedule"})
+		if err != nil {
+			t.Fatalf("GRAPH.GETATTR failed: %v", err)
+		}
+		if resp.StringValue != `{"cron":"0 * * * *","enabled":true}` {
+			t.Errorf("Unexpected schedule attribute: %s", resp.StringValue)
+		}
+
+		resp, err = graphCommands.Handle("GET", []string{"payments"})
+		if err != nil {
+			t.Fatalf("GRAPH.GET failed: %v", err)
+		}
+		var attributes models.Attributes
+		if err := json.Unmarshal([]byte(resp.ArrayValue[5]), &attributes); err != nil {
+			t.Fatalf("Invalid attributes JSON %q: %v", resp.ArrayValue[5], err)
+		}
+		if attributes["owner"] != "payments-team" || attributes["environment"] != "prod" {
+			t.Errorf("Unexpected attributes: %v", attributes)
+		}
+
+		resp, err = graphCommands.Handle("DELATTR", []string{"payments", "owner"})
+		if err != nil || resp.IntValue != 1 {
+			t.Fatalf("Expected GRAPH.DELATTR to remove owner, got %v, %v", resp, err)
+		}
+		resp, err = graphCommands.Handle("DELATTR", []string{"payments", "owner"})
+		if err != nil || resp.IntValue != 0 {
+			t.Errorf("Expected GRAPH.DELATTR of a missing key to return 0, got %v, %v", resp, err)
+		}
+		resp, err = graphCommands.Handle("GETATTR", []string{"payments", "owner"})
+		if err != nil {
+			t.Fatalf("GRAPH.GETATTR failed: %v", err)
+		}
+		if resp.Type != protocol.ResponseTypeNull {
+			t.Errorf("Expected null for a deleted attribute, got %v", resp)
+		}
+	})
+
+	t.Run("MatchAttr", func(t *testing.T) {
+		resp, err := graphCommands.Handle("LIST", []string{"MATCHATTR", "environment", "prod"})
+		if err != nil {
+			t.Fatalf("GRAPH.LIST MATCHATTR failed: %v", err)
+		}
+		if len(resp.NestedArrayValue) != 1 || resp.NestedArrayValue[0].([]string)[0] != "payments" {
+			t.Errorf("Expected only payments, got %v", resp.NestedArrayValue)
+		}
+
+		resp, err = graphCommands.Handle("LIST", []string{"MATCHATTR", "schedule", `{"enabled":true,"cron":"0 * * * *"}`})
+		if err != nil {
+			t.Fatalf("GRAPH.LIST MATCHATTR failed: %v", err)
+		}
+		if len(resp.NestedArrayValue) != 1 || resp.NestedArrayValue[0].([]string)[0] != "payments" {
+			t.Errorf("Expected only payments, got %v", resp.NestedArrayValue)
+		}
+
+		if _, err := graphCommands.Handle("LIST", []string{"MATCHATTR", "environment"}); err == nil {
+			t.Error("Expected error for MATCHATTR without a value")
+		}
+	})
+
+	t.Run("UpdatePreservesUnknownFields", func(t *testing.T) {
+		if _, err := graphCommands.Handle("SETATTR", []string{"future", "owner", "infra"}); err != nil {
+			t.Fatalf("GRAPH.SETATTR failed: %v", err)
+		}
+		if _, err := graphCommands.Handle("DISPLAY", []string{"SET", "future", "name"}); err != nil {
+			t.Fatalf("GRAPH.DISPLAY SET failed: %v", err)
+		}
+
+		if err := engine.Close(); err != nil {
+			t.Fatalf("Failed to close database: %v", err)
+		}
+		db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open raw database: %v", err)
+		}
+		var stored map[string]interface{}
+		err = db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(utils.EncodeGraphKey("future"))
+			if err != nil {
+				return err
+			}
+			return item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &stored)
+			})
+		})
+		db.Close()
+		if err != nil {
+			t.Fatalf("Failed to read raw graph: %v", err)
+		}
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+
+		if !reflect.DeepEqual(stored["retention"], map[string]interface{}{"days": float64(30)}) {
+			t.Errorf("Expected unknown field to be preserved, got %v", stored)
+		}
+		if attributes, _ := stored["attributes"].(map[string]interface{}); attributes["owner"] != "infra" {
+			t.Errorf("Expected owner attribute to be stored, got %v", stored["attributes"])
+		}
+		if stored["display_node_attr"] != "name" {
+			t.Errorf("Expected display setting to be stored, got %v", stored)
+		}
+	})
+}
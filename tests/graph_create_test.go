@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestGraphCreateDescription tests that a multi-word description is stored
+// whole when sent as one argument and rejected when split into several
+func TestGraphCreateDescription(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_graph_create_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	handler := redis.NewCommandHandler(engine)
+
+	description := func(t *testing.T, graphID models.GraphID) string {
+		t.Helper()
+		graph, err := engine.GetGraph(graphID)
+		if err != nil {
+			t.Fatalf("Failed to get graph: %v", err)
+		}
+		return graph.Description
+	}
+
+	t.Run("Handler", func(t *testing.T) {
+		if _, err := handler.Handle("GRAPH.CREATE", []string{"whole", "This is my graph"}); err != nil {
+			t.Fatalf("GRAPH.CREATE failed: %v", err)
+		}
+		if got := description(t, "whole"); got != "This is my graph" {
+			t.Errorf("Expected the whole description, got %q", got)
+		}
+
+		_, err := handler.Handle("GRAPH.CREATE", []string{"split", "This", "is", "my", "graph"})
+		if !errors.Is(err, models.ErrBadArgument) || !strings.Contains(err.Error(), "quote a description") {
+			t.Errorf("Expected a BADARG error asking to quote the description, got %v", err)
+		}
+		if _, err := engine.GetGraph("split"); err == nil {
+			t.Error("Expected no graph to be created")
+		}
+
+		_, err = handler.Handle("GRAPH.SNAPSHOT", []string{"CREATE", "whole", "before", "cleanup"})
+		if !errors.Is(err, models.ErrBadArgument) || !strings.Contains(err.Error(), "quote a label") {
+			t.Errorf("Expected a BADARG error asking to quote the label, got %v", err)
+		}
+		if _, err := handler.Handle("GRAPH.SNAPSHOT", []string{"CREATE", "whole", "before cleanup"}); err != nil {
+			t.Errorf("Expected a quoted label to be accepted: %v", err)
+		}
+	})
+
+	t.Run("RESP", func(t *testing.T) {
+		conn, err := net.Dial("tcp", startTestServer(t, engine, redis.DefaultConfig()))
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		send := func(request string) string {
+			t.Helper()
+			if _, err := conn.Write([]byte(request)); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+			reply, err := readReply(r)
+			if err != nil {
+				t.Fatalf("Failed to read reply: %v", err)
+			}
+			return reply[0]
+		}
+
+		if reply := send(encodeCommand("GRAPH.CREATE", "resp", "Payments services and queues")); reply != "OK" {
+			t.Fatalf("Expected OK, got %s", reply)
+		}
+		if got := description(t, "resp"); got != "Payments services and queues" {
+			t.Errorf("Expected the whole description, got %q", got)
+		}
+
+		reply := send(encodeCommand("GRAPH.CREATE", "resp-split", "Payments", "services"))
+		if !strings.HasPrefix(reply, "-BADARG GRAPH.CREATE requires 1 or 2 arguments") {
+			t.Errorf("Expected a BADARG error, got %s", reply)
+		}
+
+		// Inline commands quote the description as redis-cli does
+		if reply := send("GRAPH.CREATE inline 'Payments services'\r\n"); reply != "OK" {
+			t.Fatalf("Expected OK, got %s", reply)
+		}
+		if got := description(t, "inline"); got != "Payments services" {
+			t.Errorf("Expected the quoted description, got %q", got)
+		}
+		if reply := send("GRAPH.CREATE inline-split Payments services\r\n"); !strings.HasPrefix(reply, "-BADARG") {
+			t.Errorf("Expected a BADARG error, got %s", reply)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestGraphDeletion tests that DeleteGraph removes large graphs in bounded
+// transactions and that an interrupted deletion is finished on reopen
+func TestGraphDeletion(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_graphdelete_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { engine.Close() }()
+
+	// graphKeys returns the number of keys a graph owns
+	graphKeys := func(t *testing.T, graphID models.GraphID) int {
+		t.Helper()
+		audit, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("Failed to audit keys: %v", err)
+		}
+		total := 0
+		for _, count := range audit.Graphs[graphID] {
+			total += int(count)
+		}
+		return total
+	}
+	// generate creates a graph of nodes with an attribute each, and edges
+	// from every node to the next one and to a hub
+	generate := func(t *testing.T, graphID models.GraphID, nodes int) {
+		t.Helper()
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		if err := engine.CreateNode(graphID, &models.Node{ID: "hub", Type: "hub"}); err != nil {
+			t.Fatalf("Failed to create hub: %v", err)
+		}
+		const batch = 1000
+		for start := 0; start < nodes; start += batch {
+			err := engine.RunTransaction(func(tx storage.Transaction) error {
+				for i := start; i < start+batch && i < nodes; i++ {
+					node := &models.Node{ID: models.NodeID(fmt.Sprintf("n%d", i)), Type: "service", Attributes: models.Attributes{"shard": fmt.Sprint(i % 16)}}
+					if err := tx.CreateNode(graphID, node); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to create nodes: %v", err)
+			}
+		}
+		for start := 0; start < nodes; start += batch {
+			err := engine.RunTransaction(func(tx storage.Transaction) error {
+				for i := start; i < start+batch && i < nodes; i++ {
+					from := models.NodeID(fmt.Sprintf("n%d", i))
+					for _, to := range []models.NodeID{models.NodeID(fmt.Sprintf("n%d", (i+1)%nodes)), "hub"} {
+						edge := &models.Edge{ID: models.EdgeID(fmt.Sprintf("%s-%s", from, to)), Type: "calls", FromNodeID: from, ToNodeID: to}
+						if err := tx.CreateEdge(graphID, edge); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to create edges: %v", err)
+			}
+		}
+	}
+
+	t.Run("Large Graph", func(t *testing.T) {
+		// 3 keys per node and 4 per edge: about 330k keys
+		generate(t, "large", 30000)
+		expected := graphKeys(t, "large")
+		if expected < 300000 {
+			t.Fatalf("Expected at least 300000 keys, got %d", expected)
+		}
+
+		removed, err := engine.DeleteGraph("large")
+		if err != nil {
+			t.Fatalf("Failed to delete graph: %v", err)
+		}
+		if removed != expected {
+			t.Errorf("Expected %d keys removed, got %d", expected, removed)
+		}
+		if remaining := graphKeys(t, "large"); remaining != 0 {
+			t.Errorf("Expected no keys left, got %d", remaining)
+		}
+		if _, err := engine.GetGraph("large"); err == nil {
+			t.Error("Expected the graph to be deleted")
+		}
+	})
+
+	t.Run("Resume After Crash", func(t *testing.T) {
+		generate(t, "crashed", 500)
+		if err := engine.CreateGraph(&models.Graph{ID: "kept", Name: "kept"}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		if err := engine.CreateNode("kept", &models.Node{ID: "api", Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		if err := engine.Close(); err != nil {
+			t.Fatalf("Failed to close: %v", err)
+		}
+
+		// Leave the graph as a crash after its edges and nodes were deleted
+		// would: marked, with its indexes and graph record still stored
+		db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open raw database: %v", err)
+		}
+		err = db.Update(func(txn *badger.Txn) error {
+			if err := txn.Set(utils.EncodeGraphDeletionKey("crashed"), []byte(`{}`)); err != nil {
+				return err
+			}
+			var keys [][]byte
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			for _, prefix := range [][]byte{utils.CreateEdgeIteratorPrefix("crashed"), utils.CreateNodeIteratorPrefix("crashed")} {
+				for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+					keys = append(keys, it.Item().KeyCopy(nil))
+				}
+			}
+			it.Close()
+			for _, key := range keys {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		db.Close()
+		if err != nil {
+			t.Fatalf("Failed to simulate the crash: %v", err)
+		}
+
+		engine = storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen: %v", err)
+		}
+		if remaining := graphKeys(t, "crashed"); remaining != 0 {
+			t.Errorf("Expected the deletion to be finished on open, got %d keys left", remaining)
+		}
+		if _, err := engine.GetGraph("crashed"); err == nil {
+			t.Error("Expected the graph to be deleted")
+		}
+		audit, err := engine.AuditKeys(utils.DeletionPrefix)
+		if err != nil || audit.Keys != 0 {
+			t.Errorf("Expected the deletion marker to be removed, got %v, %v", audit, err)
+		}
+		if _, err := engine.GetNode("kept", "api"); err != nil {
+			t.Errorf("Expected other graphs to be kept: %v", err)
+		}
+
+		// Deleting the graph again is a no-op
+		if removed, err := engine.DeleteGraph("crashed"); err != nil || removed != 0 {
+			t.Errorf("Expected nothing left to remove, got %d, %v", removed, err)
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/testutil/graphgen"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestGraphGen tests the shapes the synthetic graph generators build, that
+// they and their decorators are deterministic for a seed, and writing them
+func TestGraphGen(t *testing.T) {
+	// degrees counts the edges out of and into each node, failing on self
+	// loops and repeated edges
+	degrees := func(t *testing.T, graph *graphgen.Graph) (map[models.NodeID]int, map[models.NodeID]int) {
+		t.Helper()
+		out, in := make(map[models.NodeID]int), make(map[models.NodeID]int)
+		seen := make(map[[2]models.NodeID]bool)
+		for _, edge := range graph.Edges {
+			pair := [2]models.NodeID{edge.FromNodeID, edge.ToNodeID}
+			if edge.FromNodeID == edge.ToNodeID || seen[pair] {
+				t.Errorf("Expected no self loops or repeated edges, got %s -> %s", edge.FromNodeID, edge.ToNodeID)
+			}
+			seen[pair] = true
+			out[edge.FromNodeID]++
+			in[edge.ToNodeID]++
+		}
+		return out, in
+	}
+
+	t.Run("Shapes", func(t *testing.T) {
+		chain := graphgen.Chain(5)()
+		if len(chain.Nodes) != 5 || len(chain.Edges) != 4 || chain.Edges[3].FromNodeID != "n000003" || chain.Edges[3].ToNodeID != "n000004" {
+			t.Errorf("Expected a chain of 5 nodes, got %d nodes and edges %v", len(chain.Nodes), chain.Edges)
+		}
+
+		star := graphgen.Star("hub", 4)()
+		out, in := degrees(t, star)
+		if len(star.Nodes) != 5 || out["hub"] != 4 || in["n000003"] != 1 {
+			t.Errorf("Expected a hub with 4 leaves, got %d nodes, out %v", len(star.Nodes), out)
+		}
+
+		dag := graphgen.RandomDAG(50, 3, 1)()
+		out, _ = degrees(t, dag)
+		if len(dag.Nodes) != 50 || len(dag.Edges) != 47*3+2+1 {
+			t.Errorf("Expected 50 nodes and %d edges, got %d and %d", 47*3+2+1, len(dag.Nodes), len(dag.Edges))
+		}
+		for _, edge := range dag.Edges {
+			if edge.FromNodeID >= edge.ToNodeID {
+				t.Errorf("Expected DAG edges to lead to later nodes, got %s -> %s", edge.FromNodeID, edge.ToNodeID)
+			}
+		}
+		if out["n000000"] != 3 || out["n000048"] != 1 || out["n000049"] != 0 {
+			t.Errorf("Expected 3 edges out of the first node, 1 and 0 out of the last two, got %v", out)
+		}
+
+		// Hubs gather far more edges than the average of 2 per node
+		scaleFree := graphgen.ScaleFree(200, 2, 1)()
+		_, in = degrees(t, scaleFree)
+		if len(scaleFree.Nodes) != 200 || len(scaleFree.Edges) != 198*2 {
+			t.Errorf("Expected 200 nodes and 396 edges, got %d and %d", len(scaleFree.Nodes), len(scaleFree.Edges))
+		}
+		maxIn := 0
+		for _, count := range in {
+			maxIn = max(maxIn, count)
+		}
+		if maxIn < 10 {
+			t.Errorf("Expected a hub with at least 10 edges in, got at most %d", maxIn)
+		}
+		for _, edge := range scaleFree.Edges {
+			if edge.FromNodeID <= edge.ToNodeID {
+				t.Errorf("Expected scale-free edges to lead to earlier nodes, got %s -> %s", edge.FromNodeID, edge.ToNodeID)
+			}
+		}
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		decorate := func(g graphgen.Generator, seed int64) graphgen.Generator {
+			g = graphgen.WithTypes(g, []models.NodeType{"service", "database", "cache"}, []models.EdgeType{"calls", "reads"}, seed)
+			return graphgen.WithAttributes(g, 3, seed)
+		}
+		for name, generate := range map[string]func(seed int64) graphgen.Generator{
+			"Chain":     func(seed int64) graphgen.Generator { return decorate(graphgen.Chain(100), seed) },
+			"Star":      func(seed int64) graphgen.Generator { return decorate(graphgen.Star("hub", 100), seed) },
+			"RandomDAG": func(seed int64) graphgen.Generator { return decorate(graphgen.RandomDAG(100, 4, seed), seed) },
+			"ScaleFree": func(seed int64) graphgen.Generator { return decorate(graphgen.ScaleFree(100, 3, seed), seed) },
+		} {
+			t.Run(name, func(t *testing.T) {
+				first, second := generate(42)(), generate(42)()
+				if !reflect.DeepEqual(first, second) {
+					t.Error("Expected the same graph for the same seed")
+				}
+				if reflect.DeepEqual(first, generate(43)()) {
+					t.Error("Expected a different graph for another seed")
+				}
+				node := first.Nodes[7]
+				if node.Type == graphgen.DefaultNodeType || len(node.Attributes) != 3 {
+					t.Errorf("Expected a decorated node, got %+v", node)
+				}
+				if _, ok := node.Attributes["attr1"].(string); !ok {
+					t.Errorf("Expected attr1 to be a string, got %v", node.Attributes)
+				}
+			})
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		testPath := filepath.Join(os.TempDir(), "pathwaydb_graphgen_test")
+		os.RemoveAll(testPath)
+		defer os.RemoveAll(testPath)
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+
+		graphID := models.GraphID("generated")
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "generated"}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		// More than one batch of nodes and edges
+		graph := graphgen.WithAttributes(graphgen.ScaleFree(1500, 2, 1), 2, 1)()
+		if err := graphgen.Write(engine, graphID, graph); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if nodes, err := engine.CountNodes(graphID); err != nil || nodes != 1500 {
+			t.Errorf("Expected 1500 nodes, got %d, %v", nodes, err)
+		}
+		if edges, err := engine.CountEdges(graphID); err != nil || edges != 2996 {
+			t.Errorf("Expected 2996 edges, got %d, %v", edges, err)
+		}
+		stored, err := engine.GetNode(graphID, "n001234")
+		if err != nil || !models.AttributesEqual(stored.Attributes, graph.Nodes[1234].Attributes) || stored.CreatedAt.IsZero() {
+			t.Errorf("Expected n001234 as generated, got %+v, %v", stored, err)
+		}
+		if cyclic, err := analysis.NewGraphAnalyzer(engine).HasCycles(graphID, nil); err != nil || cyclic {
+			t.Errorf("Expected no cycles, got %v, %v", cyclic, err)
+		}
+	})
+}
+
+// BenchmarkGeneratedGraphs measures analyses on generated graphs of fixed
+// shapes and seeds, so results are comparable between runs and machines
+func BenchmarkGeneratedGraphs(b *testing.B) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_graphgen_bench")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	// GetGraphStats and FindAllCycles enumerate paths, which grow
+	// exponentially in the number of nodes of a DAG, so the DAGs are small
+	shapes := []struct {
+		name      string
+		generator graphgen.Generator
+	}{
+		{"Chain", graphgen.Chain(500)},
+		{"RandomDAG", graphgen.RandomDAG(40, 2, 1)},
+		{"ScaleFree", graphgen.ScaleFree(40, 2, 1)},
+	}
+	for _, shape := range shapes {
+		graphID := models.GraphID(shape.name)
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: shape.name}); err != nil {
+			b.Fatalf("Failed to create graph: %v", err)
+		}
+		if err := graphgen.Write(engine, graphID, shape.generator()); err != nil {
+			b.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	for _, shape := range shapes {
+		graphID := models.GraphID(shape.name)
+		b.Run(shape.name+"/Traversal", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := analyzer.DepthFirstSearch(graphID, "n000000", &types.TraversalOptions{MaxDepth: -1, Direction: types.DirectionBoth}); err != nil {
+					b.Fatalf("DepthFirstSearch failed: %v", err)
+				}
+			}
+		})
+		b.Run(shape.name+"/Stats", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := analyzer.GetGraphStats(graphID, nil); err != nil {
+					b.Fatalf("GetGraphStats failed: %v", err)
+				}
+			}
+		})
+		b.Run(shape.name+"/Cycles", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := analyzer.FindAllCycles(graphID, nil); err != nil {
+					b.Fatalf("FindAllCycles failed: %v", err)
+				}
+			}
+		})
+		b.Run(shape.name+"/Centrality", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := analyzer.CalculatePageRank(graphID, 0.85, 100, 1e-6, types.DirectionForward); err != nil {
+					b.Fatalf("CalculatePageRank failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestHelp tests the command registry, HELP and suggestions for unknown
+// commands
+func TestHelp(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_help_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	handler := redis.NewCommandHandler(engine)
+	registry := handler.Registry()
+
+	t.Run("Registry", func(t *testing.T) {
+		for _, spec := range registry.Commands() {
+			if spec.Summary == "" || !strings.HasPrefix(spec.Example, spec.Name) {
+				t.Errorf("Expected %s to have a summary and an example of itself, got %q, %q", spec.Name, spec.Summary, spec.Example)
+			}
+			usage := strings.ToUpper(spec.Usage())
+			for _, keyword := range spec.Keywords {
+				if !strings.Contains(usage, strings.ToUpper(keyword)) {
+					t.Errorf("Expected the usage of %s to show keyword %s: %s", spec.Name, keyword, spec.Usage())
+				}
+			}
+		}
+	})
+
+	t.Run("EveryCommandRegistered", func(t *testing.T) {
+		// Every documented command is routed, and every routed command
+		// other than PING, INFO and the <FAMILY>.HELP commands is documented
+		reference, err := os.ReadFile(filepath.Join("..", "docs", "COMMANDS.md"))
+		if err != nil {
+			t.Fatalf("Failed to read command reference: %v", err)
+		}
+		documented := make(map[string]bool)
+		for _, match := range regexp.MustCompile("(?m)^### `([A-Z.]+)").FindAllStringSubmatch(string(reference), -1) {
+			documented[match[1]] = true
+			if _, ok := registry.Lookup(match[1]); !ok {
+				t.Errorf("Expected documented command %s to be registered", match[1])
+			}
+		}
+		for _, spec := range registry.Commands() {
+			if spec.Name != "PING" && spec.Name != "INFO" && !strings.HasSuffix(spec.Name, ".HELP") && !documented[spec.Name] {
+				t.Errorf("Expected registered command %s to be documented", spec.Name)
+			}
+		}
+
+		// The family handlers route every command the handler registers
+		families := map[string]func(command string, args []string) (*protocol.Response, error){
+			"GRAPH":    commands.NewGraphCommands(engine).Handle,
+			"NODE":     commands.NewNodeCommands(engine).Handle,
+			"EDGE":     commands.NewEdgeCommands(engine).Handle,
+			"ANALYSIS": commands.NewAnalysisCommands(engine).Handle,
+			"SEARCH":   commands.NewSearchCommands(engine).Handle,
+			"META":     commands.NewMetaCommands(engine).Handle,
+			"SYSTEM":   commands.NewSystemCommands(engine).Handle,
+			"QUERY": func(command string, args []string) (*protocol.Response, error) {
+				return commands.NewQueryCommands(engine, handler.Registry()).Handle(&commands.Session{}, command, args)
+			},
+		}
+		for _, spec := range registry.Commands() {
+			family, command, _ := strings.Cut(spec.Name, ".")
+			handle, ok := families[family]
+			if !ok || command == "HELP" {
+				continue
+			}
+			if _, err := handle(command, nil); err != nil && strings.Contains(err.Error(), "unknown") {
+				t.Errorf("Expected the %s handler to route %s, got %v", family, spec.Name, err)
+			}
+		}
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		for _, tc := range []struct {
+			args     []string
+			readOnly bool
+		}{
+			{[]string{"NODE.GET", "g", "a"}, true},
+			{[]string{"NODE.CREATE", "g", "a", "service"}, false},
+			{[]string{"GRAPH.SNAPSHOT", "list", "g"}, true},
+			{[]string{"GRAPH.SNAPSHOT", "CREATE", "g"}, false},
+			{[]string{"GRAPH.SNAPSHOT"}, false},
+			{[]string{"GRAPH.SELFLOOPS", "g"}, true},
+			{[]string{"GRAPH.SELFLOOPS", "g", "DELETE"}, false},
+			{[]string{"NODE.HELP"}, true},
+			{[]string{"NODE.NOSUCH", "g"}, false},
+		} {
+			if readOnly := registry.IsReadOnly(tc.args[0], tc.args[1:]); readOnly != tc.readOnly {
+				t.Errorf("Expected %s to be read-only: %v, got %v", strings.Join(tc.args, " "), tc.readOnly, readOnly)
+			}
+		}
+	})
+
+	t.Run("Help", func(t *testing.T) {
+		resp, err := handler.Handle("HELP", nil)
+		if err != nil {
+			t.Fatalf("HELP failed: %v", err)
+		}
+		lines := make(map[string]bool)
+		for _, line := range resp.ArrayValue {
+			lines[line] = true
+		}
+		for _, line := range []string{"NODE: 13 commands, see NODE.HELP", "SEARCH: 1 command, see SEARCH.HELP", "AUTH <password> - Grants the connection the admin role"} {
+			if !lines[line] {
+				t.Errorf("Expected HELP to include %q, got %v", line, resp.ArrayValue)
+			}
+		}
+
+		for command, keywords := range map[string][]string{
+			"ANALYSIS.TRAVERSE": {"DIRECTION", "MAXFANOUT", "STRATEGY", "TRANSITIONS"},
+			"n.create":          {"TTL"},
+			"EDGE.FILTER":       {"FROMTYPE", "TOTYPE", "LIMIT"},
+			"SYSTEM.KEYAUDIT":   {"PREFIX", "FORMAT"},
+		} {
+			resp, err := handler.Handle("HELP", []string{command})
+			if err != nil {
+				t.Fatalf("HELP %s failed: %v", command, err)
+			}
+			text := strings.Join(resp.ArrayValue, "\n")
+			name := commands.NormalizeCommand(command)
+			if !strings.HasPrefix(text, name+" <graph>") && !strings.HasPrefix(text, name+" [") {
+				t.Errorf("Expected HELP %s to start with the usage, got %q", command, text)
+			}
+			if !strings.Contains(text, "Example: "+name) {
+				t.Errorf("Expected HELP %s to include an example, got %q", command, text)
+			}
+			for _, keyword := range keywords {
+				if !strings.Contains(text, keyword) {
+					t.Errorf("Expected HELP %s to include %s, got %q", command, keyword, text)
+				}
+			}
+		}
+
+		family, err := handler.Handle("meta.help", nil)
+		if err != nil {
+			t.Fatalf("META.HELP failed: %v", err)
+		}
+		if len(family.ArrayValue) != 4 || !strings.HasPrefix(family.ArrayValue[0], "META.DEL <graph> <namespace> <key> - ") {
+			t.Errorf("Expected the 4 META commands, got %v", family.ArrayValue)
+		}
+		if resp, err := handler.Handle("HELP", []string{"META"}); err != nil || strings.Join(resp.ArrayValue, "\n") != strings.Join(family.ArrayValue, "\n") {
+			t.Errorf("Expected HELP META to match META.HELP, got %v, %v", resp, err)
+		}
+
+		if _, err := handler.Handle("HELP", []string{"NODE.CRATE"}); err == nil || !strings.Contains(err.Error(), "did you mean NODE.CREATE?") {
+			t.Errorf("Expected HELP to suggest NODE.CREATE, got %v", err)
+		}
+		if _, err := handler.Handle("HELP", []string{"NODE.GET", "NODE.LIST"}); err == nil {
+			t.Error("Expected an error for 2 arguments")
+		}
+	})
+
+	t.Run("Suggestions", func(t *testing.T) {
+		tests := []struct {
+			command  string
+			expected string
+		}{
+			{"NODE.CRAETE", "unknown NODE command: CRAETE (did you mean NODE.CREATE?)"},
+			{"a.travrse", "unknown ANALYSIS command: TRAVRSE (did you mean ANALYSIS.TRAVERSE?)"},
+			{"GRPAH.LIST", "unknown command: GRPAH.LIST (did you mean GRAPH.LIST?)"},
+			{"NODE.SHORTESTPATH", "unknown NODE command: SHORTESTPATH"},
+			{"FOO", "unknown command: FOO"},
+			{"NODE", "incomplete NODE command"},
+		}
+		for _, tt := range tests {
+			_, err := handler.Handle(tt.command, nil)
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("%s: expected %q, got %v", tt.command, tt.expected, err)
+			}
+		}
+	})
+}
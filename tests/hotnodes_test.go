@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestHotNodes tests read tracking, ANALYSIS.HOTNODES and SYSTEM.HOTNODES
+func TestHotNodes(t *testing.T) {
+	te := setupTestAnalysisEngine(t)
+	defer te.cleanup()
+	te.createSampleGraph()
+
+	handler := redis.NewCommandHandler(te.engine)
+	hotNodes := func(t *testing.T, args ...string) []string {
+		resp, err := handler.Handle("ANALYSIS.HOTNODES", append([]string{string(te.graphID)}, args...))
+		if err != nil {
+			t.Fatalf("ANALYSIS.HOTNODES failed: %v", err)
+		}
+		return resp.ArrayValue
+	}
+	// traverseAll runs a downstream traversal from every node of the sample
+	// graph. logger, which three nodes depend on, is reached most often.
+	traverseAll := func(t *testing.T, rounds int) {
+		for i := 0; i < rounds; i++ {
+			for _, start := range []models.NodeID{"app", "auth", "db", "cache", "logger", "queue"} {
+				if _, err := te.analyzer.DepthFirstSearch(te.graphID, start, &types.TraversalOptions{MaxDepth: -1, Direction: types.DirectionForward}); err != nil {
+					t.Fatalf("Traversal from %s failed: %v", start, err)
+				}
+			}
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		traverseAll(t, 1)
+		if got := hotNodes(t); len(got) != 0 {
+			t.Errorf("Expected no reads counted while tracking is off, got %v", got)
+		}
+	})
+
+	te.engine.SetReadTracking(true)
+
+	t.Run("HubsFirst", func(t *testing.T) {
+		traverseAll(t, 10)
+		got := hotNodes(t, "TOP", "3")
+		if len(got) != 6 {
+			t.Fatalf("Expected 3 entries, got %v", got)
+		}
+		if got[0] != "logger" {
+			t.Errorf("Expected logger to be the hottest node, got %v", got)
+		}
+		for _, id := range []string{got[2], got[4]} {
+			if id != "db" && id != "cache" {
+				t.Errorf("Expected db and cache next, got %v", got)
+			}
+		}
+		if all := hotNodes(t, "TOP", "100"); len(all) != 12 {
+			t.Errorf("Expected all 6 nodes to have been read, got %v", all)
+		}
+	})
+
+	t.Run("Persisted", func(t *testing.T) {
+		before := hotNodes(t)
+		testPath := te.testPath
+		if err := te.engine.Close(); err != nil {
+			t.Fatalf("Failed to close: %v", err)
+		}
+		if err := te.engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen: %v", err)
+		}
+		if after := hotNodes(t); !reflect.DeepEqual(before, after) {
+			t.Errorf("Expected counts to survive a restart, got %v then %v", before, after)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		resp, err := handler.Handle("SYSTEM.HOTNODES", []string{"RESET"})
+		if err != nil || resp.StringValue != "OK" {
+			t.Fatalf("SYSTEM.HOTNODES RESET failed: %v, %v", resp, err)
+		}
+		if got := hotNodes(t); len(got) != 0 {
+			t.Errorf("Expected no counts after reset, got %v", got)
+		}
+
+		if _, err := te.engine.GetNode(te.graphID, "queue"); err != nil {
+			t.Fatalf("Failed to get node: %v", err)
+		}
+		if got := hotNodes(t); !reflect.DeepEqual(got, []string{"queue", "1"}) {
+			t.Errorf("Expected a single read of queue, got %v", got)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := handler.Handle("ANALYSIS.HOTNODES", []string{"missing-graph"}); err == nil {
+			t.Error("Expected error for a missing graph")
+		}
+		if _, err := handler.Handle("ANALYSIS.HOTNODES", []string{string(te.graphID), "TOP", "0"}); err == nil {
+			t.Error("Expected error for a non-positive TOP")
+		}
+		if _, err := handler.Handle("SYSTEM.HOTNODES", []string{"CLEAR"}); err == nil {
+			t.Error("Expected error for an unknown subcommand")
+		}
+	})
+}
+
+// BenchmarkTraversalReadTracking compares traversal speed with read tracking
+// off and on
+func BenchmarkTraversalReadTracking(b *testing.B) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_hotnodes_bench")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+
+	// A binary tree of 255 nodes
+	graphID := models.GraphID("bench-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		b.Fatalf("Failed to create graph: %v", err)
+	}
+	const size = 255
+	for i := 0; i < size; i++ {
+		if err := engine.CreateNode(graphID, &models.Node{ID: models.NodeID(fmt.Sprintf("n%d", i)), Type: "service"}); err != nil {
+			b.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	for i := 1; i < size; i++ {
+		edge := &models.Edge{
+			ID:         models.EdgeID(fmt.Sprintf("e%d", i)),
+			FromNodeID: models.NodeID(fmt.Sprintf("n%d", (i-1)/2)),
+			ToNodeID:   models.NodeID(fmt.Sprintf("n%d", i)),
+			Type:       "calls",
+		}
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			b.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	options := &types.TraversalOptions{MaxDepth: -1, Direction: types.DirectionForward}
+	for _, tracking := range []bool{false, true} {
+		b.Run(fmt.Sprintf("TrackReads=%v", tracking), func(b *testing.B) {
+			engine.SetReadTracking(tracking)
+			for i := 0; i < b.N; i++ {
+				if _, err := analyzer.DepthFirstSearch(graphID, "n0", options); err != nil {
+					b.Fatalf("Traversal failed: %v", err)
+				}
+			}
+		})
+	}
+}
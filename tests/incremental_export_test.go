@@ -0,0 +1,291 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// incrementalExport is a GRAPH.EXPORT SINCE document
+type incrementalExport struct {
+	Since      time.Time          `json:"since"`
+	Until      time.Time          `json:"until"`
+	Graph      *models.Graph      `json:"graph"`
+	Nodes      []*models.Node     `json:"nodes"`
+	Edges      []*models.Edge     `json:"edges"`
+	Tombstones storage.Tombstones `json:"tombstones"`
+}
+
+// graphContents reads a graph's nodes and edges for DiffGraphData
+func graphContents(t *testing.T, engine *storage.BadgerEngine, graphID models.GraphID) *models.SnapshotData {
+	t.Helper()
+	data := &models.SnapshotData{}
+	if err := engine.ScanNodes(graphID, func(node *models.Node) error {
+		data.Nodes = append(data.Nodes, node)
+		return nil
+	}); err != nil {
+		t.Fatalf("ScanNodes failed: %v", err)
+	}
+	if err := engine.ScanEdges(graphID, func(edge *models.Edge) error {
+		data.Edges = append(data.Edges, edge)
+		return nil
+	}); err != nil {
+		t.Fatalf("ScanEdges failed: %v", err)
+	}
+	return data
+}
+
+// TestIncrementalExport tests GRAPH.EXPORT SINCE by applying two chained
+// incremental exports to a replica and comparing it with the source
+func TestIncrementalExport(t *testing.T) {
+	sourcePath := filepath.Join(os.TempDir(), "pathwaydb_incremental_source_test")
+	replicaPath := filepath.Join(os.TempDir(), "pathwaydb_incremental_replica_test")
+	os.RemoveAll(sourcePath)
+	os.RemoveAll(replicaPath)
+	defer os.RemoveAll(sourcePath)
+	defer os.RemoveAll(replicaPath)
+
+	source := storage.NewBadgerEngine()
+	if err := source.Open(sourcePath); err != nil {
+		t.Fatalf("Failed to open source: %v", err)
+	}
+	defer source.Close()
+	replica := storage.NewBadgerEngine()
+	if err := replica.Open(replicaPath); err != nil {
+		t.Fatalf("Failed to open replica: %v", err)
+	}
+	defer replica.Close()
+	handler := redis.NewCommandHandler(source)
+
+	run := func(t *testing.T, command string, args ...string) {
+		t.Helper()
+		if _, err := handler.Handle(command, args); err != nil {
+			t.Fatalf("%s %v failed: %v", command, args, err)
+		}
+	}
+	export := func(t *testing.T, since time.Time) *incrementalExport {
+		t.Helper()
+		resp, err := handler.Handle("GRAPH.EXPORT", []string{"infra", "SINCE", since.Format(time.RFC3339Nano)})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT SINCE failed: %v", err)
+		}
+		doc := &incrementalExport{}
+		if err := json.Unmarshal([]byte(resp.StringValue), doc); err != nil {
+			t.Fatalf("Failed to parse incremental export: %v", err)
+		}
+		if !doc.Since.Equal(since) || doc.Until.Before(since) {
+			t.Errorf("Expected the document to cover %v to until, got %v to %v", since, doc.Since, doc.Until)
+		}
+		return doc
+	}
+	// apply upserts the document's nodes and edges, then deletes its
+	// tombstones, edges first as deleting a node deletes its edges
+	apply := func(t *testing.T, doc *incrementalExport) {
+		t.Helper()
+		for _, node := range doc.Nodes {
+			write := replica.CreateNode
+			if _, err := replica.GetNode("infra", node.ID); err == nil {
+				write = replica.UpdateNode
+			}
+			if err := write("infra", node); err != nil {
+				t.Fatalf("Failed to apply node %s: %v", node.ID, err)
+			}
+		}
+		for _, edge := range doc.Edges {
+			write := replica.CreateEdge
+			if _, err := replica.GetEdge("infra", edge.ID); err == nil {
+				write = replica.UpdateEdge
+			}
+			if err := write("infra", edge); err != nil {
+				t.Fatalf("Failed to apply edge %s: %v", edge.ID, err)
+			}
+		}
+		for _, edgeID := range doc.Tombstones.Edges {
+			if _, err := replica.GetEdge("infra", edgeID); err == nil {
+				if err := replica.DeleteEdge("infra", edgeID); err != nil {
+					t.Fatalf("Failed to delete edge %s: %v", edgeID, err)
+				}
+			}
+		}
+		for _, nodeID := range doc.Tombstones.Nodes {
+			if err := replica.DeleteNode("infra", nodeID); err != nil {
+				t.Fatalf("Failed to delete node %s: %v", nodeID, err)
+			}
+		}
+	}
+	assertReplicated := func(t *testing.T) {
+		t.Helper()
+		diff := analysis.DiffGraphData(graphContents(t, replica, "infra"), graphContents(t, source, "infra"))
+		if len(diff.AddedNodes)+len(diff.RemovedNodes)+len(diff.ChangedNodes)+
+			len(diff.AddedEdges)+len(diff.RemovedEdges)+len(diff.ChangedEdges) > 0 {
+			t.Errorf("Expected the replica to match the source, got %+v", diff)
+		}
+	}
+
+	start := time.Now()
+	run(t, "GRAPH.CREATE", "infra")
+	run(t, "NODE.CREATE", "infra", "api", "service", `{"replicas":2}`)
+	run(t, "NODE.CREATE", "infra", "db", "database")
+	run(t, "NODE.CREATE", "infra", "cache", "cache")
+	run(t, "NODE.CREATE", "infra", "legacy", "service")
+	run(t, "EDGE.CREATE", "infra", "reads", "api", "db", "reads")
+	run(t, "EDGE.CREATE", "infra", "uses", "api", "cache", "uses")
+	run(t, "EDGE.CREATE", "infra", "syncs", "cache", "db", "syncs")
+	if err := replica.CreateGraph(&models.Graph{ID: "infra", Name: "infra"}); err != nil {
+		t.Fatalf("Failed to create replica graph: %v", err)
+	}
+
+	var first *incrementalExport
+	t.Run("First", func(t *testing.T) {
+		first = export(t, start)
+		if len(first.Nodes) != 4 || len(first.Edges) != 3 {
+			t.Errorf("Expected every entity created since the start, got %d nodes and %d edges", len(first.Nodes), len(first.Edges))
+		}
+		if len(first.Tombstones.Nodes)+len(first.Tombstones.Edges) != 0 {
+			t.Errorf("Expected no tombstones, got %+v", first.Tombstones)
+		}
+		apply(t, first)
+		assertReplicated(t)
+	})
+
+	t.Run("Second", func(t *testing.T) {
+		run(t, "NODE.UPDATE", "infra", "api", "ATTRIBUTES", `{"replicas":4}`)
+		run(t, "EDGE.UPDATE", "infra", "reads", `{"weight":3}`)
+		run(t, "NODE.DELETE", "infra", "cache")
+		run(t, "NODE.DELETE", "infra", "legacy")
+		run(t, "NODE.CREATE", "infra", "legacy", "service", `{"rebuilt":true}`)
+		run(t, "NODE.CREATE", "infra", "queue", "queue")
+		run(t, "EDGE.CREATE", "infra", "publishes", "api", "queue", "publishes")
+
+		second := export(t, first.Until)
+		nodeIDs := func(nodes []*models.Node) []models.NodeID {
+			ids := []models.NodeID{}
+			for _, node := range nodes {
+				ids = append(ids, node.ID)
+			}
+			return ids
+		}
+		edgeIDs := func(edges []*models.Edge) []models.EdgeID {
+			ids := []models.EdgeID{}
+			for _, edge := range edges {
+				ids = append(ids, edge.ID)
+			}
+			return ids
+		}
+		if got := nodeIDs(second.Nodes); !reflect.DeepEqual(got, []models.NodeID{"api", "legacy", "queue"}) {
+			t.Errorf("Expected only the changed nodes, got %v", got)
+		}
+		if got := edgeIDs(second.Edges); !reflect.DeepEqual(got, []models.EdgeID{"publishes", "reads"}) {
+			t.Errorf("Expected only the changed edges, got %v", got)
+		}
+		// legacy was recreated, so it is not a tombstone
+		want := storage.Tombstones{Nodes: []models.NodeID{"cache"}, Edges: []models.EdgeID{"syncs", "uses"}}
+		if !reflect.DeepEqual(second.Tombstones, want) {
+			t.Errorf("Expected tombstones %+v, got %+v", want, second.Tombstones)
+		}
+
+		apply(t, second)
+		assertReplicated(t)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"infra", "SINCE"},
+			{"infra", "SINCE", "yesterday"},
+			{"infra", "WITHMETA", "SINCE", start.Format(time.RFC3339)},
+			{"infra", "SINCE", start.AddDate(0, 0, -31).Format(time.RFC3339)},
+			{"missing", "SINCE", start.Format(time.RFC3339)},
+		} {
+			if _, err := handler.Handle("GRAPH.EXPORT", args); err == nil {
+				t.Errorf("Expected GRAPH.EXPORT %v to fail", args)
+			}
+		}
+	})
+}
+
+// TestDeletionLogRetention tests that the maintenance loop prunes deletion
+// log entries past the retention and that DeleteGraph removes the rest
+func TestDeletionLogRetention(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_deletionlog_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	var mu sync.Mutex
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	engine := storage.NewBadgerEngine(storage.WithClock(clock), storage.WithDeletionLogRetention(24*time.Hour),
+		storage.WithMaintenanceInterval(10*time.Millisecond))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	if err := engine.CreateGraph(&models.Graph{ID: "infra", Name: "infra"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"old", "new"} {
+		if err := engine.CreateNode("infra", &models.Node{ID: id, Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	if err := engine.DeleteNode("infra", "old"); err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+	mu.Lock()
+	now = now.Add(30 * time.Hour)
+	mu.Unlock()
+	if err := engine.DeleteNode("infra", "new"); err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	entries := func() int64 {
+		audit, err := engine.AuditKeys("dl:")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		return audit.Families["dl"]
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for entries() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := entries(); n != 1 {
+		t.Fatalf("Expected only the deletion within the retention to be kept, got %d entries", n)
+	}
+
+	var doc incrementalExport
+	var buf strings.Builder
+	if err := engine.ExportGraphSince("infra", clock().Add(-time.Hour), &buf); err != nil {
+		t.Fatalf("ExportGraphSince failed: %v", err)
+	}
+	if err := json.Unmarshal([]byte(buf.String()), &doc); err != nil {
+		t.Fatalf("Failed to parse incremental export: %v", err)
+	}
+	if !reflect.DeepEqual(doc.Tombstones.Nodes, []models.NodeID{"new"}) || !doc.Until.Equal(clock()) {
+		t.Errorf("Expected the kept deletion until the clock's time, got %+v until %v", doc.Tombstones, doc.Until)
+	}
+	if err := engine.ExportGraphSince("infra", clock().Add(-25*time.Hour), &buf); err == nil {
+		t.Error("Expected an export from before the retention to fail")
+	}
+
+	if _, err := engine.DeleteGraph("infra"); err != nil {
+		t.Fatalf("DeleteGraph failed: %v", err)
+	}
+	if n := entries(); n != 0 {
+		t.Errorf("Expected deleting the graph to delete its deletion log, got %d entries", n)
+	}
+}
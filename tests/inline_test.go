@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestSplitInline tests the inline command tokenizer against redis-cli
+// quoting conventions
+func TestSplitInline(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+	}{
+		{"Empty", "", nil},
+		{"Blank", " \t \r\n", nil},
+		{"Plain", "NODE.GET g n1", []string{"NODE.GET", "g", "n1"}},
+		{"Whitespace", "  PING \t hello\r\n", []string{"PING", "hello"}},
+		{"DoubleQuoted", `PING "hello world"`, []string{"PING", "hello world"}},
+		{"SingleQuoted", `PING 'hello world'`, []string{"PING", "hello world"}},
+		{"EmptyQuoted", `SET "" ''`, []string{"SET", "", ""}},
+		{"JSON", `NODE.CREATE g n1 service '{"name": "auth", "port": 80}'`,
+			[]string{"NODE.CREATE", "g", "n1", "service", `{"name": "auth", "port": 80}`}},
+		{"EscapedJSON", `NODE.CREATE g n1 service "{\"name\": \"auth\"}"`,
+			[]string{"NODE.CREATE", "g", "n1", "service", `{"name": "auth"}`}},
+		{"SingleInDouble", `PING "it's"`, []string{"PING", "it's"}},
+		{"DoubleInSingle", `PING 'say "hi"'`, []string{"PING", `say "hi"`}},
+		{"EscapedDouble", `PING "a\"b"`, []string{"PING", `a"b`}},
+		{"EscapedSingle", `PING 'it\'s'`, []string{"PING", "it's"}},
+		{"EscapedBackslash", `PING "a\\b"`, []string{"PING", `a\b`}},
+		{"ControlEscapes", `PING "a\nb\rc\td\be\af"`, []string{"PING", "a\nb\rc\td\be\af"}},
+		{"OtherEscape", `PING "\q"`, []string{"PING", "q"}},
+		{"HexEscape", `PING "\x41\x7a\x00"`, []string{"PING", "Az\x00"}},
+		{"ShortHexEscape", `PING "\x4"`, []string{"PING", "x4"}},
+		{"SingleQuotedLiteral", `PING 'a\nb\\c'`, []string{"PING", `a\nb\\c`}},
+		{"UnquotedBackslash", `PING a\b\n`, []string{"PING", `a\b\n`}},
+		{"MidArgumentQuote", `PING a"b c"`, []string{"PING", "ab c"}},
+		{"MidArgumentSingleQuote", `PING key='x y'`, []string{"PING", "key=x y"}},
+		{"QuoteAtEnd", `PING "x"`, []string{"PING", "x"}},
+		{"QuoteBeforeTab", "PING \"x\"\ty", []string{"PING", "x", "y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := redis.SplitInline(tt.line)
+			if err != nil {
+				t.Fatalf("SplitInline(%q) failed: %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("SplitInline(%q): expected %q, got %q", tt.line, tt.expected, args)
+			}
+		})
+	}
+
+	for _, line := range []string{
+		`PING "hello`,
+		`PING 'hello`,
+		`PING "it's`,
+		`PING 'say "hi"`,
+		`PING "a\"`,
+		`PING 'a\'`,
+		`PING "a"b`,
+		`PING 'a'b`,
+		`PING "a""b"`,
+		`PING a"b`,
+		`PING a\"b`,
+	} {
+		if args, err := redis.SplitInline(line); !errors.Is(err, redis.ErrUnbalancedQuotes) {
+			t.Errorf("SplitInline(%q): expected ErrUnbalancedQuotes, got %q, %v", line, args, err)
+		}
+	}
+}
+
+// TestInlineCommands tests inline commands over a connection, and that RESP
+// clients are unaffected
+func TestInlineCommands(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_inline_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	addr := startTestServer(t, engine, redis.DefaultConfig())
+
+	dial := func(t *testing.T) (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+	roundTrip := func(t *testing.T, conn net.Conn, r *bufio.Reader, request string) []string {
+		t.Helper()
+		if _, err := conn.Write([]byte(request)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		reply, err := readReply(r)
+		if err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+		return reply
+	}
+
+	t.Run("Quoted", func(t *testing.T) {
+		conn, r := dial(t)
+		if reply := roundTrip(t, conn, r, "GRAPH.CREATE inline 'Inline graph'\r\n"); reply[0] != "OK" {
+			t.Fatalf("Expected OK, got %v", reply)
+		}
+		// Empty lines are skipped, and a bare newline ends a line
+		roundTrip(t, conn, r, "\r\n\nNODE.CREATE inline n1 service '{\"name\": \"auth service\"}'\n")
+		reply := roundTrip(t, conn, r, "NODE.GET inline \"n1\"\r\n")
+		if reply[1] != "n1" || reply[3] != `{"name":"auth service"}` {
+			t.Errorf("Expected the quoted JSON attributes, got %v", reply)
+		}
+	})
+
+	t.Run("RESP", func(t *testing.T) {
+		conn, r := dial(t)
+		// Quotes in RESP arguments are data, never tokenized
+		reply := roundTrip(t, conn, r, encodeCommand("PING", `'a "b`))
+		if !reflect.DeepEqual(reply, []string{`'a "b`}) {
+			t.Errorf("Expected the argument echoed unchanged, got %v", reply)
+		}
+		// Pipelined RESP commands are passed through as one stream
+		conn.Write([]byte(encodeCommand("PING", "one") + encodeCommand("PING", "two")))
+		for _, expected := range []string{"one", "two"} {
+			if reply, err := readReply(r); err != nil || reply[0] != expected {
+				t.Errorf("Expected %s, got %v, %v", expected, reply, err)
+			}
+		}
+	})
+
+	t.Run("Unbalanced", func(t *testing.T) {
+		conn, r := dial(t)
+		conn.Write([]byte("PING ok\r\nPING \"unterminated\r\nPING never\r\n"))
+		if reply, err := readReply(r); err != nil || reply[0] != "ok" {
+			t.Errorf("Expected the reply before the bad line, got %v, %v", reply, err)
+		}
+		reply, err := readReply(r)
+		if err != nil || reply[0] != "-ERR Protocol error: unbalanced quotes in request" {
+			t.Errorf("Expected a protocol error, got %v, %v", reply, err)
+		}
+		if _, err := r.ReadByte(); err != io.EOF {
+			t.Errorf("Expected the connection to be closed, got %v", err)
+		}
+	})
+}
+
+// TestHumanReadable tests that array replies are logged item by item
+func TestHumanReadable(t *testing.T) {
+	engine := storage.NewBadgerEngine()
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_human_readable_test")
+	os.RemoveAll(testPath)
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	capture := newCaptureHandler()
+	config := redis.DefaultConfig()
+	config.HumanReadable = true
+	server := redis.NewServer(config, engine, redis.WithLogger(slog.New(capture)))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go server.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, line := range []string{"GRAPH.CREATE human 'for people'\r\n", "GRAPH.LIST\r\n"} {
+		conn.Write([]byte(line))
+		if _, err := readReply(r); err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+	}
+
+	// Only the array reply of GRAPH.LIST is logged, the row of each graph
+	// as a group
+	rec, ok := capture.find("reply", slog.LevelInfo)
+	if !ok {
+		t.Fatal("Expected the reply to be logged")
+	}
+	row := make(map[string]string)
+	for _, attr := range rec.attrs["1"].Group() {
+		row[attr.Key] = attr.Value.String()
+	}
+	if rec.attrs["command"].String() != "GRAPH.LIST" || row["1"] != "human" || row["3"] != "for people" {
+		t.Errorf("Expected the GRAPH.LIST items as numbered attributes, got %v", rec.attrs)
+	}
+}
@@ -3,11 +3,15 @@ package tests
 import (
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ywadi/PathwayDB/analysis"
 	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
 	"github.com/ywadi/PathwayDB/storage"
 	"github.com/ywadi/PathwayDB/types"
 )
@@ -30,77 +34,7 @@ func TestCompleteWorkflow(t *testing.T) {
 	graphID := models.GraphID("integration-graph")
 	
 	// Step 1: Create graph and populate with complex dependency structure
-	graph := &models.Graph{
-		ID:          graphID,
-		Name:        "Integration Test Graph",
-		Description: "Complex dependency graph for integration testing",
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-	
-	err = engine.CreateGraph(graph)
-	if err != nil {
-		t.Fatalf("Failed to create graph: %v", err)
-	}
-	
-	// Create nodes representing a microservices architecture
-	nodes := []*models.Node{
-		{ID: "frontend", Type: "application", Attributes: models.Attributes{"name": "Frontend App", "tech": "react"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "api-gateway", Type: "service", Attributes: models.Attributes{"name": "API Gateway", "tech": "nginx"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "auth-service", Type: "service", Attributes: models.Attributes{"name": "Auth Service", "tech": "go"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "user-service", Type: "service", Attributes: models.Attributes{"name": "User Service", "tech": "go"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "order-service", Type: "service", Attributes: models.Attributes{"name": "Order Service", "tech": "java"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "payment-service", Type: "service", Attributes: models.Attributes{"name": "Payment Service", "tech": "python"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "notification-service", Type: "service", Attributes: models.Attributes{"name": "Notification Service", "tech": "node"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "user-db", Type: "database", Attributes: models.Attributes{"name": "User Database", "tech": "postgresql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "order-db", Type: "database", Attributes: models.Attributes{"name": "Order Database", "tech": "postgresql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "redis-cache", Type: "cache", Attributes: models.Attributes{"name": "Redis Cache", "tech": "redis"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "message-queue", Type: "queue", Attributes: models.Attributes{"name": "Message Queue", "tech": "rabbitmq"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "logger", Type: "library", Attributes: models.Attributes{"name": "Logger", "tech": "logrus"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-	}
-	
-	for _, node := range nodes {
-		err = engine.CreateNode(graphID, node)
-		if err != nil {
-			t.Fatalf("Failed to create node %s: %v", node.ID, err)
-		}
-	}
-	
-	// Create edges representing dependencies
-	edges := []*models.Edge{
-		// Frontend dependencies
-		{ID: "frontend-gateway", Type: "depends_on", FromNodeID: "frontend", ToNodeID: "api-gateway", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		
-		// API Gateway dependencies
-		{ID: "gateway-auth", Type: "depends_on", FromNodeID: "api-gateway", ToNodeID: "auth-service", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "gateway-user", Type: "depends_on", FromNodeID: "api-gateway", ToNodeID: "user-service", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "gateway-order", Type: "depends_on", FromNodeID: "api-gateway", ToNodeID: "order-service", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		
-		// Service dependencies
-		{ID: "auth-userdb", Type: "depends_on", FromNodeID: "auth-service", ToNodeID: "user-db", Attributes: models.Attributes{"type": "sql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "auth-cache", Type: "depends_on", FromNodeID: "auth-service", ToNodeID: "redis-cache", Attributes: models.Attributes{"type": "tcp"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "auth-logger", Type: "depends_on", FromNodeID: "auth-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		
-		{ID: "user-userdb", Type: "depends_on", FromNodeID: "user-service", ToNodeID: "user-db", Attributes: models.Attributes{"type": "sql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "user-logger", Type: "depends_on", FromNodeID: "user-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		
-		{ID: "order-orderdb", Type: "depends_on", FromNodeID: "order-service", ToNodeID: "order-db", Attributes: models.Attributes{"type": "sql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "order-payment", Type: "depends_on", FromNodeID: "order-service", ToNodeID: "payment-service", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "order-notification", Type: "depends_on", FromNodeID: "order-service", ToNodeID: "notification-service", Attributes: models.Attributes{"type": "async"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "order-logger", Type: "depends_on", FromNodeID: "order-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		
-		{ID: "payment-logger", Type: "depends_on", FromNodeID: "payment-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		
-		{ID: "notification-queue", Type: "depends_on", FromNodeID: "notification-service", ToNodeID: "message-queue", Attributes: models.Attributes{"type": "amqp"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "notification-logger", Type: "depends_on", FromNodeID: "notification-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-	}
-	
-	for _, edge := range edges {
-		err = engine.CreateEdge(graphID, edge)
-		if err != nil {
-			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
-		}
-	}
+	createMicroservicesGraph(t, engine, graphID)
 	
 	// Step 2: Test comprehensive analysis
 	t.Run("CompleteAnalysis", func(t *testing.T) {
@@ -379,20 +313,51 @@ func TestBackupRestore(t *testing.T) {
 	// Add test data
 	node := &models.Node{ID: "test-node", Type: "service", Attributes: models.Attributes{"name": "Test"}, CreatedAt: time.Now(), UpdatedAt: time.Now()}
 	engine1.CreateNode(graphID, node)
-	
+	createMicroservicesGraph(t, engine1, "microservices")
+
 	// Create backup directory and backup
 	err = os.MkdirAll(backupPath, 0755)
 	if err != nil {
 		t.Errorf("Failed to create backup directory: %v", err)
 	}
 	defer os.RemoveAll(backupPath)
-	
+
 	err = engine1.Backup(backupPath)
 	if err != nil {
 		t.Errorf("Backup failed: %v", err)
 	}
+
+	// Read everything back from engine1 to compare with the restore
+	type contents struct {
+		graph *models.Graph
+		nodes []*models.Node
+		edges []*models.Edge
+	}
+	read := func(engine storage.StorageEngine) map[models.GraphID]contents {
+		t.Helper()
+		graphs, err := engine.ListGraphs()
+		if err != nil {
+			t.Fatalf("Failed to list graphs: %v", err)
+		}
+		result := make(map[models.GraphID]contents)
+		for _, g := range graphs {
+			nodes, err := engine.ListNodes(g.ID)
+			if err != nil {
+				t.Fatalf("Failed to list nodes: %v", err)
+			}
+			edges, err := engine.ListEdges(g.ID)
+			if err != nil {
+				t.Fatalf("Failed to list edges: %v", err)
+			}
+			sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+			sort.Slice(edges, func(i, j int) bool { return edges[i].ID < edges[j].ID })
+			result[g.ID] = contents{graph: g, nodes: nodes, edges: edges}
+		}
+		return result
+	}
+	original := read(engine1)
 	engine1.Close()
-	
+
 	// Restore to new location
 	engine2 := storage.NewBadgerEngine()
 	err = engine2.Open(restorePath)
@@ -400,10 +365,155 @@ func TestBackupRestore(t *testing.T) {
 		t.Fatalf("Failed to open restored database: %v", err)
 	}
 	defer engine2.Close()
+	if err := engine2.Restore(backupPath); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored := read(engine2)
+	if len(restored) != 2 || len(original) != 2 {
+		t.Fatalf("Expected 2 graphs before and after restore, got %d and %d", len(original), len(restored))
+	}
+	for id, want := range original {
+		got := restored[id]
+		if got.graph == nil || got.graph.Name != want.graph.Name || got.graph.Description != want.graph.Description {
+			t.Errorf("Expected graph %s to be restored, got %+v", id, got.graph)
+			continue
+		}
+		if len(got.nodes) != len(want.nodes) || len(got.edges) != len(want.edges) {
+			t.Errorf("Expected graph %s to have %d nodes and %d edges, got %d and %d", id, len(want.nodes), len(want.edges), len(got.nodes), len(got.edges))
+			continue
+		}
+		for i := range want.nodes {
+			if got.nodes[i].ID != want.nodes[i].ID || got.nodes[i].Type != want.nodes[i].Type || !models.AttributesEqual(got.nodes[i].Attributes, want.nodes[i].Attributes) {
+				t.Errorf("Expected node %+v, got %+v", want.nodes[i], got.nodes[i])
+			}
+		}
+		for i := range want.edges {
+			if got.edges[i].ID != want.edges[i].ID || got.edges[i].FromNodeID != want.edges[i].FromNodeID || got.edges[i].ToNodeID != want.edges[i].ToNodeID || got.edges[i].Type != want.edges[i].Type {
+				t.Errorf("Expected edge %+v, got %+v", want.edges[i], got.edges[i])
+			}
+		}
+	}
+	if outgoing, err := engine2.GetOutgoingEdges("microservices", "frontend"); err != nil || len(outgoing) != 1 {
+		t.Errorf("Expected the restored edge indexes to find frontend's edge, got %v, %v", outgoing, err)
+	}
+
+	// SYSTEM.RESTORE refuses the database now it holds graphs, unless
+	// forced, and keeps the graphs the backup does not hold
+	handler := redis.NewCommandHandler(engine2)
+	if err := engine2.CreateGraph(&models.Graph{ID: "extra", Name: "extra"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	if _, err := handler.Handle("SYSTEM.RESTORE", []string{backupPath}); err == nil || !strings.Contains(err.Error(), "database is not empty: it holds 3 graphs") {
+		t.Errorf("Expected SYSTEM.RESTORE to refuse a database with graphs, got %v", err)
+	}
+	if resp, err := handler.Handle("SYSTEM.RESTORE", []string{backupPath, "force"}); err != nil || resp.StringValue != "OK" {
+		t.Errorf("Expected SYSTEM.RESTORE FORCE to succeed, got %v, %v", resp, err)
+	}
+	if graphs, err := engine2.ListGraphs(); err != nil || len(graphs) != 3 {
+		t.Errorf("Expected the backup's graphs and extra, got %v, %v", graphs, err)
+	}
+	if restoredNode, err := engine2.GetNode(graphID, "test-node"); err != nil || restoredNode.Attributes["name"] != "Test" {
+		t.Errorf("Expected test-node after the forced restore, got %v, %v", restoredNode, err)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{backupPath, "LEGACY"},
+		{filepath.Join(backupPath, "missing.db"), "FORCE"},
+	} {
+		if _, err := handler.Handle("SYSTEM.RESTORE", args); err == nil {
+			t.Errorf("Expected SYSTEM.RESTORE %v to fail", args)
+		}
+	}
+
+	// With an admin password configured, only the admin role may restore
+	secured := redis.NewCommandHandler(engine2, redis.WithAdminPassword("secret"))
+	session := &commands.Session{}
+	if _, err := secured.HandleSession(session, "SYSTEM.RESTORE", []string{backupPath, "FORCE"}); err == nil || !strings.Contains(err.Error(), "requires the admin role") {
+		t.Errorf("Expected SYSTEM.RESTORE to require the admin role, got %v", err)
+	}
+	if _, err := secured.HandleSession(session, "AUTH", []string{"secret"}); err != nil {
+		t.Fatalf("AUTH failed: %v", err)
+	}
+	if resp, err := secured.HandleSession(session, "SYSTEM.RESTORE", []string{backupPath, "FORCE"}); err != nil || resp.StringValue != "OK" {
+		t.Errorf("Expected SYSTEM.RESTORE to succeed for the admin role, got %v, %v", resp, err)
+	}
+}
+
+// createMicroservicesGraph creates the microservices architecture used by the
+// integration tests: 12 nodes and 16 depends_on edges from frontend through
+// api-gateway to services, databases, a cache, a queue and a shared logger
+func createMicroservicesGraph(t testing.TB, engine storage.StorageEngine, graphID models.GraphID) {
+	graph := &models.Graph{
+		ID:          graphID,
+		Name:        "Integration Test Graph",
+		Description: "Complex dependency graph for integration testing",
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
 	
-	// Verify data exists after restore (this is a simplified test)
-	restoredGraph, err := engine2.GetGraph(graphID)
-	if err == nil && restoredGraph.ID == graphID {
-		t.Log("Backup/restore verification passed")
+	err := engine.CreateGraph(graph)
+	if err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	
+	// Create nodes representing a microservices architecture
+	nodes := []*models.Node{
+		{ID: "frontend", Type: "application", Attributes: models.Attributes{"name": "Frontend App", "tech": "react"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "api-gateway", Type: "service", Attributes: models.Attributes{"name": "API Gateway", "tech": "nginx"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "auth-service", Type: "service", Attributes: models.Attributes{"name": "Auth Service", "tech": "go"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "user-service", Type: "service", Attributes: models.Attributes{"name": "User Service", "tech": "go"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "order-service", Type: "service", Attributes: models.Attributes{"name": "Order Service", "tech": "java"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "payment-service", Type: "service", Attributes: models.Attributes{"name": "Payment Service", "tech": "python"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "notification-service", Type: "service", Attributes: models.Attributes{"name": "Notification Service", "tech": "node"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "user-db", Type: "database", Attributes: models.Attributes{"name": "User Database", "tech": "postgresql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "order-db", Type: "database", Attributes: models.Attributes{"name": "Order Database", "tech": "postgresql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "redis-cache", Type: "cache", Attributes: models.Attributes{"name": "Redis Cache", "tech": "redis"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "message-queue", Type: "queue", Attributes: models.Attributes{"name": "Message Queue", "tech": "rabbitmq"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "logger", Type: "library", Attributes: models.Attributes{"name": "Logger", "tech": "logrus"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	}
+	
+	for _, node := range nodes {
+		err = engine.CreateNode(graphID, node)
+		if err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	
+	// Create edges representing dependencies
+	edges := []*models.Edge{
+		// Frontend dependencies
+		{ID: "frontend-gateway", Type: "depends_on", FromNodeID: "frontend", ToNodeID: "api-gateway", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		
+		// API Gateway dependencies
+		{ID: "gateway-auth", Type: "depends_on", FromNodeID: "api-gateway", ToNodeID: "auth-service", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "gateway-user", Type: "depends_on", FromNodeID: "api-gateway", ToNodeID: "user-service", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "gateway-order", Type: "depends_on", FromNodeID: "api-gateway", ToNodeID: "order-service", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		
+		// Service dependencies
+		{ID: "auth-userdb", Type: "depends_on", FromNodeID: "auth-service", ToNodeID: "user-db", Attributes: models.Attributes{"type": "sql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "auth-cache", Type: "depends_on", FromNodeID: "auth-service", ToNodeID: "redis-cache", Attributes: models.Attributes{"type": "tcp"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "auth-logger", Type: "depends_on", FromNodeID: "auth-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		
+		{ID: "user-userdb", Type: "depends_on", FromNodeID: "user-service", ToNodeID: "user-db", Attributes: models.Attributes{"type": "sql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "user-logger", Type: "depends_on", FromNodeID: "user-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		
+		{ID: "order-orderdb", Type: "depends_on", FromNodeID: "order-service", ToNodeID: "order-db", Attributes: models.Attributes{"type": "sql"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "order-payment", Type: "depends_on", FromNodeID: "order-service", ToNodeID: "payment-service", Attributes: models.Attributes{"type": "http"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "order-notification", Type: "depends_on", FromNodeID: "order-service", ToNodeID: "notification-service", Attributes: models.Attributes{"type": "async"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "order-logger", Type: "depends_on", FromNodeID: "order-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		
+		{ID: "payment-logger", Type: "depends_on", FromNodeID: "payment-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		
+		{ID: "notification-queue", Type: "depends_on", FromNodeID: "notification-service", ToNodeID: "message-queue", Attributes: models.Attributes{"type": "amqp"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "notification-logger", Type: "depends_on", FromNodeID: "notification-service", ToNodeID: "logger", Attributes: models.Attributes{"type": "library"}, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	}
+	
+	for _, edge := range edges {
+		err = engine.CreateEdge(graphID, edge)
+		if err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
 	}
 }
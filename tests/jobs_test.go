@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/jobs"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// createCompleteGraph generates a graph where every node links to every
+// other node, so enumerating its cycles takes a long time
+func createCompleteGraph(t *testing.T, engine storage.StorageEngine, graphID models.GraphID, size int) {
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for i := 0; i < size; i++ {
+		node := &models.Node{ID: models.NodeID(fmt.Sprintf("n%d", i)), Type: "service"}
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			if i == j {
+				continue
+			}
+			edge := &models.Edge{
+				ID:         models.EdgeID(fmt.Sprintf("n%d-n%d", i, j)),
+				FromNodeID: models.NodeID(fmt.Sprintf("n%d", i)),
+				ToNodeID:   models.NodeID(fmt.Sprintf("n%d", j)),
+				Type:       "calls",
+			}
+			if err := engine.CreateEdge(graphID, edge); err != nil {
+				t.Fatalf("Failed to create edge: %v", err)
+			}
+		}
+	}
+}
+
+// waitForState polls ANALYSIS.STATUS until the job reaches state
+func waitForState(t *testing.T, handler *redis.CommandHandler, jobID string, state jobs.State) []string {
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := handler.Handle("ANALYSIS.STATUS", []string{jobID})
+		if err != nil {
+			t.Fatalf("ANALYSIS.STATUS failed: %v", err)
+		}
+		if resp.ArrayValue[0] == string(state) {
+			return resp.ArrayValue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not reach state %s", jobID, state)
+	return nil
+}
+
+// TestAnalysisJobs tests ANALYSIS.SUBMIT, STATUS, RESULT and CANCEL
+func TestAnalysisJobs(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_jobs_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	slowGraph := models.GraphID("jobs-slow-graph")
+	smallGraph := models.GraphID("jobs-small-graph")
+	createCompleteGraph(t, engine, slowGraph, 10)
+	createCompleteGraph(t, engine, smallGraph, 3)
+
+	handler := redis.NewCommandHandler(engine, redis.WithJobConfig(jobs.Config{
+		Workers:    1,
+		MaxActive:  2,
+		ResultTTL:  time.Minute,
+		MaxResults: 10,
+	}))
+
+	t.Run("CompletedResult", func(t *testing.T) {
+		resp, err := handler.Handle("ANALYSIS.SUBMIT", []string{"CYCLES", string(smallGraph)})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SUBMIT failed: %v", err)
+		}
+		jobID := resp.StringValue
+
+		status := waitForState(t, handler, jobID, jobs.StateDone)
+		if progress, _ := strconv.Atoi(status[1]); progress == 0 {
+			t.Error("Expected job progress to be reported")
+		}
+
+		result, err := handler.Handle("ANALYSIS.RESULT", []string{jobID})
+		if err != nil {
+			t.Fatalf("ANALYSIS.RESULT failed: %v", err)
+		}
+		direct, err := handler.Handle("ANALYSIS.CYCLES", []string{string(smallGraph)})
+		if err != nil {
+			t.Fatalf("ANALYSIS.CYCLES failed: %v", err)
+		}
+		got, want := append([]string(nil), result.ArrayValue...), append([]string(nil), direct.ArrayValue...)
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected job result %v to match synchronous result %v", got, want)
+		}
+	})
+
+	t.Run("CancelRunningJob", func(t *testing.T) {
+		resp, err := handler.Handle("ANALYSIS.SUBMIT", []string{"cycles", string(slowGraph)})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SUBMIT failed: %v", err)
+		}
+		slowJob := resp.StringValue
+		waitForState(t, handler, slowJob, jobs.StateRunning)
+
+		// The single worker is busy, so the next job waits in the queue and
+		// the active job limit is reached.
+		resp, err = handler.Handle("ANALYSIS.SUBMIT", []string{"CYCLES", string(smallGraph)})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SUBMIT failed: %v", err)
+		}
+		queuedJob := resp.StringValue
+		if status := waitForState(t, handler, queuedJob, jobs.StateQueued); status[0] != "queued" {
+			t.Errorf("Expected queued job, got %v", status)
+		}
+		if _, err := handler.Handle("ANALYSIS.SUBMIT", []string{"CYCLES", string(smallGraph)}); err == nil {
+			t.Error("Expected submit to fail when the active job limit is reached")
+		}
+		if _, err := handler.Handle("ANALYSIS.RESULT", []string{slowJob}); err == nil {
+			t.Error("Expected ANALYSIS.RESULT to fail for a running job")
+		}
+
+		if _, err := handler.Handle("ANALYSIS.CANCEL", []string{slowJob}); err != nil {
+			t.Fatalf("ANALYSIS.CANCEL failed: %v", err)
+		}
+		waitForState(t, handler, slowJob, jobs.StateCancelled)
+		if _, err := handler.Handle("ANALYSIS.RESULT", []string{slowJob}); err == nil {
+			t.Error("Expected ANALYSIS.RESULT to fail for a cancelled job")
+		}
+
+		// The queued job runs once the worker is free.
+		waitForState(t, handler, queuedJob, jobs.StateDone)
+	})
+
+	t.Run("UnknownJob", func(t *testing.T) {
+		if _, err := handler.Handle("ANALYSIS.STATUS", []string{"job-missing"}); err == nil {
+			t.Error("Expected error for unknown job")
+		}
+	})
+}
+
+// TestJobCancelQueued tests that cancelling a queued job frees its slot, so
+// later submits are accepted or rejected by the active job limit instead of
+// blocking the manager
+func TestJobCancelQueued(t *testing.T) {
+	manager := jobs.NewManager(jobs.Config{Workers: 1, MaxActive: 2})
+	defer func() {
+		// A deadlocked manager would block Close too
+		if !t.Failed() {
+			manager.Close()
+		}
+	}()
+
+	release := make(chan struct{})
+	blocking := func(ctx context.Context, progress *jobs.Progress) (interface{}, error) {
+		select {
+		case <-release:
+			return "done", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	quick := func(ctx context.Context, progress *jobs.Progress) (interface{}, error) {
+		return "done", nil
+	}
+	waitFor := func(id string, state jobs.State) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if status, err := manager.Status(id); err == nil && status.State == state {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("Job %s did not reach state %s", id, state)
+	}
+
+	running, err := manager.Submit("long", blocking)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	waitFor(running, jobs.StateRunning)
+
+	// More jobs are cancelled while queued than the limit allows active
+	for i := 0; i < 2; i++ {
+		queued, err := manager.Submit("queued", quick)
+		if err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		if err := manager.Cancel(queued); err != nil {
+			t.Fatalf("Cancel failed: %v", err)
+		}
+		waitFor(queued, jobs.StateCancelled)
+	}
+
+	// Submits run in a goroutine so a deadlock fails the test instead of
+	// hanging it
+	type submitted struct {
+		id  string
+		err error
+	}
+	results := make(chan submitted, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			id, err := manager.Submit("next", quick)
+			results <- submitted{id, err}
+		}
+	}()
+	var accepted []string
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-results:
+			if result.err == nil {
+				accepted = append(accepted, result.id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Submit blocked after a queued job was cancelled")
+		}
+	}
+	if len(accepted) != 1 {
+		t.Fatalf("Expected the cancelled job's slot to take one more job, got %d accepted", len(accepted))
+	}
+
+	close(release)
+	waitFor(running, jobs.StateDone)
+	waitFor(accepted[0], jobs.StateDone)
+	if _, err := manager.Submit("after", quick); err != nil {
+		t.Errorf("Expected submits to be accepted once jobs finish, got %v", err)
+	}
+}
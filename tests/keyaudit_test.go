@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestKeyAudit tests SYSTEM.KEYAUDIT against a database seeded with keys
+// in unknown layouts, keys of deleted graphs and out-of-step indexes
+func TestKeyAudit(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_keyaudit_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// A graph whose ID extends another's must keep its own keys
+	for _, graphID := range []models.GraphID{"web", "web:v2"} {
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		if err := engine.CreateNode(graphID, &models.Node{ID: "api", Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	for _, node := range []*models.Node{{ID: "db", Type: "service"}, {ID: "cache", Type: "service", ExpiresAt: &expiresAt}} {
+		if err := engine.CreateNode("web", node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	if err := engine.CreateEdge("web", &models.Edge{ID: "api-db", Type: "uses", FromNodeID: "api", ToNodeID: "db"}); err != nil {
+		t.Fatalf("Failed to create edge: %v", err)
+	}
+	engine.Close()
+
+	db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open raw database: %v", err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		for _, key := range []string{
+			// Layouts the current build does not read
+			"legacy:web:api", "ei:web:api-db", "no-colon",
+			// Keys of a graph that no longer exists
+			"n:gone:api", "ti:n:gone:service:api", "mr:gone",
+			// A stale index entry for an edge that does not exist
+			"ni:out:web:api:ghost",
+		} {
+			if err := txn.Set([]byte(key), []byte("x")); err != nil {
+				return err
+			}
+		}
+		// A node missing from the type index
+		return txn.Delete([]byte("ti:n:web:service:db"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed raw keys: %v", err)
+	}
+	db.Close()
+
+	engine = storage.NewBadgerEngine()
+	defer engine.Close()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+
+	t.Run("Storage", func(t *testing.T) {
+		audit, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+
+		total := audit.Unknown
+		for _, count := range audit.Families {
+			total += count
+		}
+		if audit.Keys != total {
+			t.Errorf("Expected %d keys to be counted once each, got %d", total, audit.Keys)
+		}
+		if expected := map[string]int64{"legacy": 1, "ei": 1, "no-colon": 1}; audit.Unknown != 3 || !reflect.DeepEqual(audit.UnknownPrefixes, expected) {
+			t.Errorf("Expected unknown prefixes %v, got %d %v", expected, audit.Unknown, audit.UnknownPrefixes)
+		}
+		if expected := map[string]int64{"n": 1, "ti:n": 1, "mr": 1}; audit.Orphaned != 3 || !reflect.DeepEqual(audit.Orphans, expected) {
+			t.Errorf("Expected orphans %v, got %d %v", expected, audit.Orphaned, audit.Orphans)
+		}
+
+		web := audit.Graphs["web"]
+		if web["g"] != 1 || web["n"] != 3 || web["e"] != 1 || web["xi"] != 1 || web["ni:out"] != 2 {
+			t.Errorf("Unexpected counts for web: %v", web)
+		}
+		if v2 := audit.Graphs["web:v2"]; v2["n"] != 1 || v2["ti:n"] != 1 {
+			t.Errorf("Expected web:v2 to own its keys, got %v", v2)
+		}
+
+		expected := []storage.KeyAuditMismatch{
+			{Graph: "web", Family: "n", Index: "ti:n", Entities: 3, Entries: 2},
+			{Graph: "web", Family: "e", Index: "ni:out", Entities: 1, Entries: 2},
+		}
+		if !reflect.DeepEqual(audit.Mismatches, expected) {
+			t.Errorf("Expected mismatches %v, got %v", expected, audit.Mismatches)
+		}
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		audit, err := engine.AuditKeys("n:")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		if audit.Keys != 5 || !reflect.DeepEqual(audit.Families, map[string]int64{"n": 5}) || audit.Orphaned != 1 {
+			t.Errorf("Expected only the 5 node keys, got %+v", audit)
+		}
+		if len(audit.Mismatches) != 0 {
+			t.Errorf("Expected a scoped audit not to compare indexes, got %v", audit.Mismatches)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+
+		resp, err := handler.Handle("SYSTEM.KEYAUDIT", nil)
+		if err != nil {
+			t.Fatalf("SYSTEM.KEYAUDIT failed: %v", err)
+		}
+		fields := make(map[string]string)
+		for i := 0; i+1 < len(resp.ArrayValue); i += 2 {
+			fields[resp.ArrayValue[i]] = resp.ArrayValue[i+1]
+		}
+		for field, value := range map[string]string{
+			"unknown":               "3",
+			"orphaned":              "3",
+			"mismatches":            "2",
+			"unknown:legacy":        "1",
+			"orphaned:ti:n":         "1",
+			"graph:web:n":           "3",
+			"graph:web:v2:n":        "1",
+			"mismatch:web:n:ti:n":   "3/2",
+			"mismatch:web:e:ni:out": "1/2",
+		} {
+			if fields[field] != value {
+				t.Errorf("Expected %s to be %s, got %q", field, value, fields[field])
+			}
+		}
+
+		resp, err = handler.Handle("SYSTEM.KEYAUDIT", []string{"PREFIX", "mr:", "FORMAT", "json"})
+		if err != nil {
+			t.Fatalf("SYSTEM.KEYAUDIT FORMAT json failed: %v", err)
+		}
+		var audit storage.KeyAudit
+		if err := json.Unmarshal([]byte(resp.StringValue), &audit); err != nil {
+			t.Fatalf("Failed to decode audit: %v", err)
+		}
+		if audit.Prefix != "mr:" || audit.Keys != 1 || audit.Orphans["mr"] != 1 {
+			t.Errorf("Expected the one orphaned maintenance key, got %+v", audit)
+		}
+
+		for _, args := range [][]string{
+			{"PREFIX"},
+			{"FORMAT", "xml"},
+			{"LIMIT", "10"},
+		} {
+			if _, err := handler.Handle("SYSTEM.KEYAUDIT", args); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestKeyspaceReservations tests the registry of key prefixes and that
+// graph IDs starting with one are reserved
+func TestKeyspaceReservations(t *testing.T) {
+	t.Run("Prefix Free", func(t *testing.T) {
+		for i, a := range utils.KeyPrefixes {
+			if !strings.HasSuffix(a, ":") || strings.Count(a, ":") != 1 {
+				t.Errorf("Expected prefix %q to be a name followed by one colon", a)
+			}
+			for j, b := range utils.KeyPrefixes {
+				if i != j && strings.HasPrefix(b, a) {
+					t.Errorf("Prefix %q is a prefix of %q", a, b)
+				}
+			}
+		}
+	})
+
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_keyspace_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	handler := redis.NewCommandHandler(engine)
+
+	t.Run("Create", func(t *testing.T) {
+		for _, graphID := range []string{"n:web", "ti:", "act:team", "gen:"} {
+			_, err := handler.Handle("GRAPH.CREATE", []string{graphID})
+			if !errors.Is(err, models.ErrBadArgument) || !strings.Contains(err.Error(), "reserved key prefix") {
+				t.Errorf("Expected GRAPH.CREATE %s to fail with BADARG, got %v", graphID, err)
+			}
+			if err := engine.CreateGraph(&models.Graph{ID: models.GraphID(graphID)}); !errors.Is(err, models.ErrBadArgument) {
+				t.Errorf("Expected CreateGraph %s to fail with BADARG, got %v", graphID, err)
+			}
+			if _, err := engine.GetGraph(models.GraphID(graphID)); err == nil {
+				t.Errorf("Expected graph %s not to be created", graphID)
+			}
+		}
+
+		// Prefix names alone, and prefixes later in the ID, are not reserved
+		for _, graphID := range []string{"n", "gen", "web:n:", "nodes:v2"} {
+			if _, err := handler.Handle("GRAPH.CREATE", []string{graphID}); err != nil {
+				t.Errorf("Expected GRAPH.CREATE %s to succeed, got %v", graphID, err)
+			}
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		var document bytes.Buffer
+		if err := engine.ExportGraph("n", &document, false); err != nil {
+			t.Fatalf("ExportGraph failed: %v", err)
+		}
+		_, _, err := engine.ImportGraph("e:copy", bytes.NewReader(document.Bytes()))
+		if !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected ImportGraph to reject a reserved ID, got %v", err)
+		}
+	})
+	engine.Close()
+
+	t.Run("Existing", func(t *testing.T) {
+		// A graph created before its ID was reserved
+		legacy := &models.Graph{ID: "sd:legacy", Name: "legacy"}
+		value, err := legacy.ToJSON()
+		if err != nil {
+			t.Fatalf("Failed to serialize graph: %v", err)
+		}
+		db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open raw database: %v", err)
+		}
+		if err := db.Update(func(txn *badger.Txn) error {
+			return txn.Set(utils.EncodeGraphKey(legacy.ID), value)
+		}); err != nil {
+			t.Fatalf("Failed to seed graph: %v", err)
+		}
+		db.Close()
+
+		capture := newCaptureHandler()
+		engine := storage.NewBadgerEngine(storage.WithLogger(slog.New(capture)))
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+		defer engine.Close()
+
+		var warned []string
+		for _, rec := range *capture.records {
+			if rec.message == "Graph ID starts with a reserved key prefix" && rec.level == slog.LevelWarn {
+				warned = append(warned, rec.attrs["graph"].String())
+			}
+		}
+		if len(warned) != 1 || warned[0] != "sd:legacy" {
+			t.Errorf("Expected one warning naming sd:legacy, got %v", warned)
+		}
+
+		// The graph keeps working
+		legacy.Name = "renamed"
+		if err := engine.CreateGraph(legacy); err != nil {
+			t.Errorf("Expected an existing graph to stay updatable, got %v", err)
+		}
+		if err := engine.CreateNode(legacy.ID, &models.Node{ID: "api", Type: "service"}); err != nil {
+			t.Errorf("Expected an existing graph to take nodes, got %v", err)
+		}
+	})
+}
@@ -220,19 +220,12 @@ func TestEdgeNeighborsCommand(t *testing.T) {
 			t.Fatalf("Expected array response, got %d", response.Type)
 		}
 
-		items := response.ArrayValue
-		if len(items) < 3 { // Count + at least 2 neighbors
-			t.Fatalf("Expected at least 3 items (count + 2 neighbors), got %d", len(items))
-		}
-
-		// First item should be count
-		count := items[0]
-		if count != "2" {
-			t.Errorf("Expected count to be 2, got %s", count)
+		neighborItems := response.ArrayValue
+		if len(neighborItems) != 2 {
+			t.Fatalf("Expected 2 neighbors, got %v", neighborItems)
 		}
 
-		// Verify arrow notation format for neighbor items (skip count)
-		neighborItems := items[1:]
+		// Verify arrow notation format for neighbor items
 		for i, item := range neighborItems {
 			if !strings.Contains(item, "->") {
 				t.Errorf("Outgoing neighbor %d (%s) should contain '->' arrow", i, item)
@@ -274,19 +267,12 @@ func TestEdgeNeighborsCommand(t *testing.T) {
 			t.Fatalf("Expected array response, got %d", response.Type)
 		}
 
-		items := response.ArrayValue
-		if len(items) < 2 { // Count + at least 1 neighbor
-			t.Fatalf("Expected at least 2 items (count + 1 neighbor), got %d", len(items))
-		}
-
-		// First item should be count
-		count := items[0]
-		if count != "1" {
-			t.Errorf("Expected count to be 1, got %s", count)
+		neighborItems := response.ArrayValue
+		if len(neighborItems) != 1 {
+			t.Fatalf("Expected 1 neighbor, got %v", neighborItems)
 		}
 
-		// Verify arrow notation format for incoming edges (skip count)
-		neighborItems := items[1:]
+		// Verify arrow notation format for incoming edges
 		incomingFound := false
 		for _, item := range neighborItems {
 			if strings.Contains(item, "<-") {
@@ -310,19 +296,12 @@ func TestEdgeNeighborsCommand(t *testing.T) {
 			t.Fatalf("Expected array response, got %d", response.Type)
 		}
 
-		items := response.ArrayValue
-		if len(items) < 4 { // Count + at least 3 neighbors
-			t.Fatalf("Expected at least 4 items (count + 3 neighbors), got %d", len(items))
-		}
-
-		// First item should be count
-		count := items[0]
-		if count != "3" {
-			t.Errorf("Expected count to be 3, got %s", count)
+		neighborItems := response.ArrayValue
+		if len(neighborItems) != 3 {
+			t.Fatalf("Expected 3 neighbors, got %v", neighborItems)
 		}
 
-		// Count arrows to verify mix of directions (skip count)
-		neighborItems := items[1:]
+		// Count arrows to verify mix of directions
 		outgoingCount := 0
 		incomingCount := 0
 		for _, item := range neighborItems {
@@ -341,3 +320,96 @@ func TestEdgeNeighborsCommand(t *testing.T) {
 		}
 	})
 }
+
+// TestGraphListCommand tests GRAPH.LIST rows with and without WITHCOUNTS
+func TestGraphListCommand(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_graph_list_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	graphCommands := commands.NewGraphCommands(engine)
+
+	before := time.Now().UTC().Truncate(time.Second)
+	if _, err := graphCommands.Handle("CREATE", []string{"alpha", "first graph"}); err != nil {
+		t.Fatalf("GRAPH.CREATE failed: %v", err)
+	}
+	if _, err := graphCommands.Handle("SETATTR", []string{"alpha", "team", `"payments"`}); err != nil {
+		t.Fatalf("GRAPH.SETATTR failed: %v", err)
+	}
+	// A graph record written before timestamps were set has none
+	if err := engine.CreateGraph(&models.Graph{ID: "beta", Name: "Beta"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	createMicroservicesGraph(t, engine, "gamma")
+
+	rows := func(t *testing.T, args ...string) [][]string {
+		t.Helper()
+		resp, err := graphCommands.Handle("LIST", args)
+		if err != nil {
+			t.Fatalf("GRAPH.LIST %v failed: %v", args, err)
+		}
+		var result [][]string
+		for _, row := range resp.NestedArrayValue {
+			result = append(result, row.([]string))
+		}
+		return result
+	}
+
+	t.Run("WithoutCounts", func(t *testing.T) {
+		list := rows(t)
+		if len(list) != 3 {
+			t.Fatalf("Expected 3 graphs, got %v", list)
+		}
+		alpha := list[0]
+		if len(alpha) != 5 || alpha[0] != "alpha" || alpha[1] != "alpha" || alpha[2] != "first graph" {
+			t.Fatalf("Expected the alpha row without counts, got %v", alpha)
+		}
+		createdAt, err := time.Parse(time.RFC3339, alpha[3])
+		if err != nil || createdAt.Before(before) || !strings.HasSuffix(alpha[3], "Z") {
+			t.Errorf("Expected an RFC3339 UTC creation time, got %q, %v", alpha[3], err)
+		}
+		if updatedAt, err := time.Parse(time.RFC3339, alpha[4]); err != nil || updatedAt.Before(createdAt) {
+			t.Errorf("Expected an update time after creation, got %q, %v", alpha[4], err)
+		}
+		if beta := list[1]; beta[0] != "beta" || beta[1] != "Beta" || beta[3] != "" || beta[4] != "" {
+			t.Errorf("Expected empty times for beta, got %v", beta)
+		}
+	})
+
+	t.Run("WithCounts", func(t *testing.T) {
+		list := rows(t, "WITHCOUNTS")
+		if len(list) != 3 || len(list[0]) != 7 {
+			t.Fatalf("Expected 3 rows with counts, got %v", list)
+		}
+		if alpha := list[0]; alpha[5] != "0" || alpha[6] != "0" {
+			t.Errorf("Expected no nodes or edges in alpha, got %v", alpha)
+		}
+		if gamma := list[2]; gamma[0] != "gamma" || gamma[5] != "12" || gamma[6] != "16" {
+			t.Errorf("Expected 12 nodes and 16 edges in gamma, got %v", gamma)
+		}
+
+		// Options may come in either order
+		list = rows(t, "MATCHATTR", "team", "payments", "withcounts")
+		if len(list) != 1 || list[0][0] != "alpha" || len(list[0]) != 7 {
+			t.Errorf("Expected only alpha with counts, got %v", list)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"COUNTS"},
+			{"MATCHATTR", "team"},
+			{"WITHCOUNTS", "MATCHATTR"},
+		} {
+			if _, err := graphCommands.Handle("LIST", args); err == nil {
+				t.Errorf("Expected GRAPH.LIST %v to fail", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestListCursor tests paging through NODE.LIST and EDGE.LIST with CURSOR,
+// and the storage pages they read
+func TestListCursor(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_list_cursor_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	// A graph whose name prefixes another's, so a page must not run into
+	// the next graph's keys
+	const size = 2500
+	for _, graphID := range []models.GraphID{"big", "big2"} {
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+	}
+	var nodes, edges []string
+	for i := 0; i < size; i++ {
+		id := fmt.Sprintf("n%05d", i)
+		if err := engine.CreateNode("big", &models.Node{ID: models.NodeID(id), Type: "host"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		nodes = append(nodes, id+":host")
+		if i > 0 {
+			edgeID := fmt.Sprintf("e%05d", i)
+			edge := &models.Edge{ID: models.EdgeID(edgeID), Type: "links", FromNodeID: "n00000", ToNodeID: models.NodeID(id)}
+			if err := engine.CreateEdge("big", edge); err != nil {
+				t.Fatalf("Failed to create edge: %v", err)
+			}
+			edges = append(edges, edgeID+":links")
+		}
+	}
+	if err := engine.CreateNode("big2", &models.Node{ID: "other", Type: "host"}); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	// pages follows cursors from 0 until the reply's cursor is 0
+	pages := func(t *testing.T, command string, args ...string) ([]string, int) {
+		t.Helper()
+		var all []string
+		cursor, calls := "0", 0
+		for {
+			callArgs := append([]string{"big"}, args...)
+			resp, err := handler.Handle(command, append(callArgs, "CURSOR", cursor, "COUNT", "1000"))
+			if err != nil {
+				t.Fatalf("%s CURSOR %s failed: %v", command, cursor, err)
+			}
+			calls++
+			if len(resp.ArrayValue) > 1001 {
+				t.Fatalf("Expected at most 1000 items, got %d", len(resp.ArrayValue)-1)
+			}
+			all = append(all, resp.ArrayValue[1:]...)
+			cursor = resp.ArrayValue[0]
+			if cursor == "0" || calls > 10 {
+				return all, calls
+			}
+		}
+	}
+
+	t.Run("Nodes", func(t *testing.T) {
+		got, calls := pages(t, "NODE.LIST")
+		if !reflect.DeepEqual(got, nodes) || calls != 3 {
+			t.Errorf("Expected %d nodes in 3 pages, got %d in %d", len(nodes), len(got), calls)
+		}
+
+		resp, err := handler.Handle("NODE.LIST", []string{"big", "CURSOR", ">n00999", "COUNT", "2"})
+		if err != nil {
+			t.Fatalf("NODE.LIST CURSOR failed: %v", err)
+		}
+		if expected := []string{">n01001", "n01000:host", "n01001:host"}; !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		// Without COUNT a page holds 100 nodes
+		resp, err = handler.Handle("NODE.LIST", []string{"big", "CURSOR", "0"})
+		if err != nil || len(resp.ArrayValue) != 101 || resp.ArrayValue[0] != ">n00099" {
+			t.Errorf("Expected a default page of 100, got %d items, %v", len(resp.ArrayValue)-1, err)
+		}
+	})
+
+	t.Run("Edges", func(t *testing.T) {
+		got, calls := pages(t, "EDGE.LIST")
+		if !reflect.DeepEqual(got, edges) || calls != 3 {
+			t.Errorf("Expected %d edges in 3 pages, got %d in %d", len(edges), len(got), calls)
+		}
+	})
+
+	t.Run("Storage", func(t *testing.T) {
+		// A page ending on the last node reports no more
+		page, more, err := engine.ListNodesPage("big", "n02497", 2)
+		if err != nil || more || len(page) != 2 || page[1].ID != "n02499" {
+			t.Errorf("Expected the last 2 nodes and no more, got %d nodes, %v, %v", len(page), more, err)
+		}
+		page, more, err = engine.ListNodesPage("big", "n02499", 10)
+		if err != nil || more || len(page) != 0 {
+			t.Errorf("Expected an empty last page, got %d nodes, %v, %v", len(page), more, err)
+		}
+
+		// The cursor need not be an existing ID
+		page, more, err = engine.ListNodesPage("big", "n00004x", 1)
+		if err != nil || !more || len(page) != 1 || page[0].ID != "n00005" {
+			t.Errorf("Expected n00005, got %v, %v, %v", page, more, err)
+		}
+
+		edgePage, more, err := engine.ListEdgesPage("big", "", 3)
+		if err != nil || !more || len(edgePage) != 3 || edgePage[0].ID != "e00001" {
+			t.Errorf("Expected the first 3 edges, got %d, %v, %v", len(edgePage), more, err)
+		}
+
+		page, more, err = engine.ListNodesPage("big2", "", 10)
+		if err != nil || more || len(page) != 1 || page[0].ID != "other" {
+			t.Errorf("Expected big2's one node, got %d nodes, %v, %v", len(page), more, err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			command string
+			args    []string
+		}{
+			{"NODE.LIST", []string{"big", "CURSOR"}},
+			{"NODE.LIST", []string{"big", "CURSOR", "5"}},
+			{"NODE.LIST", []string{"big", "CURSOR", ">"}},
+			{"NODE.LIST", []string{"big", "COUNT", "10"}},
+			{"NODE.LIST", []string{"big", "CURSOR", "0", "COUNT", "0"}},
+			{"NODE.LIST", []string{"big", "CURSOR", "0", "FORMAT", "csv"}},
+			{"NODE.LIST", []string{"big", "CURSOR", "0", "ORDERBY", "id"}},
+			{"EDGE.LIST", []string{"big", "CURSOR", "0", "VERBOSE"}},
+			{"EDGE.LIST", []string{"big", "ORPHANS", "CURSOR", "0"}},
+			{"EDGE.LIST", []string{"big", "CURSOR", "0", "COUNT", "x"}},
+		} {
+			if _, err := handler.Handle(tc.command, tc.args); err == nil {
+				t.Errorf("Expected %s %s to be rejected", tc.command, strings.Join(tc.args, " "))
+			}
+		}
+	})
+}
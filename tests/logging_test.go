@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// capturedRecord is a log record with its attributes flattened by key
+type capturedRecord struct {
+	level   slog.Level
+	message string
+	attrs   map[string]slog.Value
+}
+
+// captureHandler is a slog.Handler that records everything it receives
+type captureHandler struct {
+	mu      *sync.Mutex
+	records *[]capturedRecord
+	attrs   []slog.Attr
+}
+
+func newCaptureHandler() *captureHandler {
+	return &captureHandler{mu: &sync.Mutex{}, records: &[]capturedRecord{}}
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := capturedRecord{level: r.Level, message: r.Message, attrs: map[string]slog.Value{}}
+	for _, a := range h.attrs {
+		rec.attrs[a.Key] = a.Value
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		rec.attrs[a.Key] = a.Value
+		return true
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.records = append(*h.records, rec)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+func (h *captureHandler) WithGroup(string) slog.Handler { return h }
+
+// find returns the first record with the given message and level
+func (h *captureHandler) find(message string, level slog.Level) (capturedRecord, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, rec := range *h.records {
+		if rec.message == message && rec.level == level {
+			return rec, true
+		}
+	}
+	return capturedRecord{}, false
+}
+
+// TestStructuredLogging tests that key events are logged with their fields
+func TestStructuredLogging(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_logging_test")
+	os.RemoveAll(testPath)
+	capture := newCaptureHandler()
+	logger := slog.New(capture)
+
+	engine := storage.NewBadgerEngine(storage.WithLogger(logger))
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	t.Run("Open", func(t *testing.T) {
+		rec, ok := capture.find("Badger database opened", slog.LevelInfo)
+		if !ok {
+			t.Fatal("Expected open event to be logged at info level")
+		}
+		if got := rec.attrs["path"].String(); got != testPath {
+			t.Errorf("Expected path %s, got %s", testPath, got)
+		}
+		if got := rec.attrs["subsystem"].String(); got != "storage" {
+			t.Errorf("Expected subsystem storage, got %s", got)
+		}
+	})
+
+	t.Run("CommandFailure", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine, redis.WithLogger(logger))
+		if _, err := handler.Handle("GRAPH.GET", []string{"missing-graph"}); err == nil {
+			t.Fatal("Expected GRAPH.GET on a missing graph to fail")
+		}
+		rec, ok := capture.find("command failed", slog.LevelWarn)
+		if !ok {
+			t.Fatal("Expected command failure to be logged at warn level")
+		}
+		if got := rec.attrs["command"].String(); got != "GRAPH.GET" {
+			t.Errorf("Expected command GRAPH.GET, got %s", got)
+		}
+		if got := rec.attrs["graph"].String(); got != "missing-graph" {
+			t.Errorf("Expected graph missing-graph, got %s", got)
+		}
+		if _, ok := rec.attrs["duration"]; !ok {
+			t.Error("Expected duration field")
+		}
+	})
+
+	t.Run("TTLSweep", func(t *testing.T) {
+		graphID := models.GraphID("logging-ttl-graph")
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		expiresAt := time.Now().Add(-time.Second)
+		node := &models.Node{ID: "short-lived", Type: "temp", ExpiresAt: &expiresAt}
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+
+		engine.Cleanup()
+
+		// The startup sweep found nothing and logged at debug; this one expires a node.
+		rec, ok := capture.find("TTL sweep completed", slog.LevelInfo)
+		if !ok {
+			t.Fatal("Expected TTL sweep summary at info level")
+		}
+		if got := rec.attrs["expired"].Int64(); got != 1 {
+			t.Errorf("Expected 1 expired node, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,298 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// fakeClock is a clock tests move forward by hand
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestGraphMaintenance tests per-graph orphan and stale node pruning
+func TestGraphMaintenance(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_maintenance_test")
+	os.RemoveAll(testPath)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	engine := storage.NewBadgerEngine(storage.WithClock(clock.Now))
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	handler := redis.NewCommandHandler(engine)
+
+	// setup creates a graph where api -> db and orphan has no edges, all
+	// updated at the start time
+	setup := func(t *testing.T, graphID models.GraphID) {
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		for _, id := range []models.NodeID{"api", "db", "orphan"} {
+			node := &models.Node{ID: id, Type: "service", CreatedAt: start, UpdatedAt: start}
+			if err := engine.CreateNode(graphID, node); err != nil {
+				t.Fatalf("Failed to create node %s: %v", id, err)
+			}
+		}
+		edge := &models.Edge{ID: "api-db", Type: "uses", FromNodeID: "api", ToNodeID: "db", CreatedAt: start, UpdatedAt: start}
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+	exists := func(graphID models.GraphID, nodeID models.NodeID) bool {
+		_, err := engine.GetNode(graphID, nodeID)
+		return err == nil
+	}
+	setPolicy := func(t *testing.T, graphID models.GraphID, policy string) {
+		t.Helper()
+		if _, err := handler.Handle("GRAPH.POLICY", []string{"SET", string(graphID), policy}); err != nil {
+			t.Fatalf("GRAPH.POLICY SET failed: %v", err)
+		}
+	}
+
+	t.Run("Orphans", func(t *testing.T) {
+		setup(t, "orphans")
+		setPolicy(t, "orphans", `{"prune_orphans": true, "prune_orphans_after": 60}`)
+
+		// The first run only notes when the orphan was seen
+		run, err := engine.PruneGraph("orphans", false)
+		if err != nil {
+			t.Fatalf("PruneGraph failed: %v", err)
+		}
+		if run.Orphans != 0 || !exists("orphans", "orphan") {
+			t.Fatalf("Expected a new orphan to be kept, pruned %d", run.Orphans)
+		}
+
+		clock.Advance(61 * time.Second)
+		resp, err := handler.Handle("GRAPH.POLICY", []string{"STATUS", "orphans", "PREVIEW"})
+		if err != nil {
+			t.Fatalf("GRAPH.POLICY STATUS PREVIEW failed: %v", err)
+		}
+		if resp.ArrayValue[5] != "true" || resp.ArrayValue[7] != "1" || resp.ArrayValue[13] != `["orphan"]` {
+			t.Errorf("Expected a preview of the orphan, got %v", resp.ArrayValue)
+		}
+		if !exists("orphans", "orphan") {
+			t.Fatal("Expected a preview to delete nothing")
+		}
+
+		run, err = engine.PruneGraph("orphans", false)
+		if err != nil {
+			t.Fatalf("PruneGraph failed: %v", err)
+		}
+		if run.Orphans != 1 || !reflect.DeepEqual(run.OrphanNodes, []models.NodeID{"orphan"}) {
+			t.Errorf("Expected the orphan to be pruned, got %+v", run)
+		}
+		if exists("orphans", "orphan") {
+			t.Error("Expected the orphan to be deleted")
+		}
+		if !exists("orphans", "api") || !exists("orphans", "db") {
+			t.Error("Expected connected nodes to be untouched")
+		}
+		if _, err := engine.GetEdge("orphans", "api-db"); err != nil {
+			t.Errorf("Expected the edge to be untouched: %v", err)
+		}
+
+		// Removing the edge orphans both ends, which then wait their turn
+		if err := engine.DeleteEdge("orphans", "api-db"); err != nil {
+			t.Fatalf("Failed to delete edge: %v", err)
+		}
+		if run, _ := engine.PruneGraph("orphans", false); run.Orphans != 0 {
+			t.Errorf("Expected new orphans to be kept, pruned %v", run.OrphanNodes)
+		}
+		clock.Advance(time.Minute)
+		if run, _ := engine.PruneGraph("orphans", false); run.Orphans != 2 {
+			t.Errorf("Expected both new orphans to be pruned, got %v", run.OrphanNodes)
+		}
+
+		resp, err = handler.Handle("GRAPH.POLICY", []string{"STATUS", "orphans"})
+		if err != nil {
+			t.Fatalf("GRAPH.POLICY STATUS failed: %v", err)
+		}
+		expected := []string{
+			"started_at", clock.Now().Format(time.RFC3339),
+			"duration_ms", "0",
+			"dry_run", "false",
+			"orphans", "2",
+			"stale", "0",
+			"failed", "0",
+			"orphan_nodes", `["api","db"]`,
+			"stale_nodes", "[]",
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		setup(t, "stale")
+		setPolicy(t, "stale", `{"prune_stale": true, "prune_stale_after": 3600, "batch_size": 1}`)
+
+		// db is updated after the others, so only api and orphan go stale
+		clock.Advance(30 * time.Minute)
+		db, _ := engine.GetNode("stale", "db")
+		db.UpdatedAt = clock.Now()
+		if err := engine.UpdateNode("stale", db); err != nil {
+			t.Fatalf("Failed to update node: %v", err)
+		}
+		clock.Advance(31 * time.Minute)
+
+		run, err := engine.PruneGraph("stale", false)
+		if err != nil {
+			t.Fatalf("PruneGraph failed: %v", err)
+		}
+		if !reflect.DeepEqual(run.StaleNodes, []models.NodeID{"api", "orphan"}) {
+			t.Errorf("Expected api and orphan to be pruned, got %v", run.StaleNodes)
+		}
+		if !exists("stale", "db") || exists("stale", "api") {
+			t.Error("Expected only stale nodes to be deleted")
+		}
+		// Deletion cascades as for NODE.DELETE
+		if _, err := engine.GetEdge("stale", "api-db"); err == nil {
+			t.Error("Expected the edge of a pruned node to be deleted")
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		setup(t, "dryrun")
+		setPolicy(t, "dryrun", `{"prune_orphans": true, "dry_run": true}`)
+
+		run, err := engine.PruneGraph("dryrun", false)
+		if err != nil {
+			t.Fatalf("PruneGraph failed: %v", err)
+		}
+		if !run.DryRun || run.Orphans != 1 || !exists("dryrun", "orphan") {
+			t.Errorf("Expected the orphan to be reported but kept, got %+v", run)
+		}
+		recorded, err := engine.GetMaintenanceRun("dryrun")
+		if err != nil || recorded == nil || recorded.Orphans != 1 {
+			t.Errorf("Expected the dry run to be recorded, got %+v, %v", recorded, err)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		setup(t, "command")
+
+		resp, err := handler.Handle("GRAPH.POLICY", []string{"STATUS", "command"})
+		if err != nil || resp.Type != protocol.ResponseTypeNull {
+			t.Errorf("Expected no status before the first run, got %v, %v", resp, err)
+		}
+		resp, err = handler.Handle("GRAPH.POLICY", []string{"GET", "command"})
+		if err != nil || resp.Type != protocol.ResponseTypeNull {
+			t.Errorf("Expected no policy, got %v, %v", resp, err)
+		}
+
+		setPolicy(t, "command", `{"prune_stale": true, "prune_stale_after": 86400}`)
+		resp, err = handler.Handle("GRAPH.POLICY", []string{"GET", "command"})
+		expected := `{"prune_orphans":false,"prune_orphans_after":0,"prune_stale":true,"prune_stale_after":86400,"dry_run":false}`
+		if err != nil || resp.StringValue != expected {
+			t.Errorf("Expected %s, got %v, %v", expected, resp, err)
+		}
+
+		for _, args := range [][]string{
+			{"SET", "command"},
+			{"SET", "command", `{"prune_orphans": true`},
+			{"SET", "command", `{"prune_orphan": true}`},
+			{"SET", "command", `{"prune_stale_after": -1}`},
+			{"SET", "missing", `{}`},
+			{"STATUS", "command", "NOW"},
+			{"STATUS", "missing"},
+			{"RUN", "command"},
+		} {
+			if _, err := handler.Handle("GRAPH.POLICY", args); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+	})
+
+	t.Run("DeleteGraph", func(t *testing.T) {
+		setup(t, "deleted")
+		setPolicy(t, "deleted", `{"prune_orphans": true}`)
+		if _, err := engine.PruneGraph("deleted", false); err != nil {
+			t.Fatalf("PruneGraph failed: %v", err)
+		}
+		if _, err := engine.DeleteGraph("deleted"); err != nil {
+			t.Fatalf("Failed to delete graph: %v", err)
+		}
+		setup(t, "deleted")
+		if run, err := engine.GetMaintenanceRun("deleted"); err != nil || run != nil {
+			t.Errorf("Expected a recreated graph to have no runs, got %+v, %v", run, err)
+		}
+	})
+}
+
+// TestMaintenanceLoop tests that the background loop applies policies
+func TestMaintenanceLoop(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_maintenance_loop_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine(storage.WithMaintenanceInterval(10 * time.Millisecond))
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graph := &models.Graph{
+		ID:          "loop",
+		Name:        "loop",
+		Maintenance: &models.MaintenancePolicy{PruneOrphans: true},
+	}
+	if err := engine.CreateGraph(graph); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"a", "b", "lonely"} {
+		if err := engine.CreateNode("loop", &models.Node{ID: id, Type: "service", UpdatedAt: time.Now()}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	if err := engine.CreateEdge("loop", &models.Edge{ID: "a-b", Type: "uses", FromNodeID: "a", ToNodeID: "b"}); err != nil {
+		t.Fatalf("Failed to create edge: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := engine.GetNode("loop", "lonely"); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the loop to prune the orphan")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, id := range []models.NodeID{"a", "b"} {
+		if _, err := engine.GetNode("loop", id); err != nil {
+			t.Errorf("Expected %s to be untouched: %v", id, err)
+		}
+	}
+	run, err := engine.GetMaintenanceRun("loop")
+	if err != nil || run == nil {
+		t.Fatalf("Expected the run to be recorded, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestBatchCreate tests creating nodes and edges in batches with
+// NODE.MCREATE and EDGE.MCREATE, and that a failing element creates nothing
+func TestBatchCreate(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_mcreate_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("services")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "services"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	counts := func(t *testing.T) (int, int) {
+		t.Helper()
+		nodes, err := engine.CountNodes(graphID)
+		if err != nil {
+			t.Fatalf("CountNodes failed: %v", err)
+		}
+		edges, err := engine.CountEdges(graphID)
+		if err != nil {
+			t.Fatalf("CountEdges failed: %v", err)
+		}
+		return nodes, edges
+	}
+
+	t.Run("Nodes", func(t *testing.T) {
+		resp, err := handler.Handle("NODE.MCREATE", []string{string(graphID),
+			`[{"id":"api","type":"service","attributes":{"tier":1}},{"id":"db","type":"database","ttl":3600},{"id":"cache","type":"database"}]`})
+		if err != nil || resp.IntValue != 3 {
+			t.Fatalf("Expected NODE.MCREATE to create 3 nodes, got %+v, %v", resp, err)
+		}
+		api, err := engine.GetNode(graphID, "api")
+		if err != nil || api.Type != "service" || api.Attributes["tier"] != float64(1) || api.ExpiresAt != nil {
+			t.Errorf("Expected api to be stored as given, got %+v, %v", api, err)
+		}
+		if db, err := engine.GetNode(graphID, "db"); err != nil || db.ExpiresAt == nil {
+			t.Errorf("Expected db to expire, got %+v, %v", db, err)
+		}
+	})
+
+	t.Run("Edges", func(t *testing.T) {
+		resp, err := handler.Handle("EDGE.MCREATE", []string{string(graphID),
+			`[{"id":"api-db","from":"api","to":"db","type":"reads","attributes":{"pool":10}},{"id":"api-cache","from":"api","to":"cache","type":"reads"}]`})
+		if err != nil || resp.IntValue != 2 {
+			t.Fatalf("Expected EDGE.MCREATE to create 2 edges, got %+v, %v", resp, err)
+		}
+		edge, err := engine.GetEdge(graphID, "api-db")
+		if err != nil || edge.FromNodeID != "api" || edge.ToNodeID != "db" || edge.Attributes["pool"] != float64(10) {
+			t.Errorf("Expected api-db to be stored as given, got %+v, %v", edge, err)
+		}
+	})
+
+	t.Run("RollBack", func(t *testing.T) {
+		nodes, edges := counts(t)
+
+		// The second edge's target is missing, so the first is not created
+		_, err := handler.Handle("EDGE.MCREATE", []string{string(graphID),
+			`[{"id":"db-cache","from":"db","to":"cache","type":"replicates"},{"id":"api-queue","from":"api","to":"queue","type":"writes"}]`})
+		if err == nil || !strings.Contains(err.Error(), "edge api-queue") || !errors.Is(err, storage.ErrNodeNotFound) {
+			t.Errorf("Expected the error to name api-queue, got %v", err)
+		}
+		if _, err := engine.GetEdge(graphID, "db-cache"); err == nil {
+			t.Error("Expected db-cache to be rolled back")
+		}
+
+		for _, tt := range []struct {
+			command, batch string
+		}{
+			{"NODE.MCREATE", `[{"id":"queue","type":"queue"},{"id":"bad\u0000id","type":"queue"}]`},
+			{"NODE.MCREATE", `[{"id":"queue","type":"queue"},{"id":"queue","type":"queue"}]`},
+			{"NODE.MCREATE", `[{"id":"queue","type":"queue"},{"type":"queue"}]`},
+			{"NODE.MCREATE", `{"id":"queue","type":"queue"}`},
+			{"EDGE.MCREATE", `[{"id":"api-db2","from":"api","to":"db","type":"reads"},{"id":"db-x","from":"db","type":"reads"}]`},
+		} {
+			if _, err := handler.Handle(tt.command, []string{string(graphID), tt.batch}); err == nil {
+				t.Errorf("Expected %s %s to fail", tt.command, tt.batch)
+			}
+		}
+		if gotNodes, gotEdges := counts(t); gotNodes != nodes || gotEdges != edges {
+			t.Errorf("Expected failed batches to leave %d nodes and %d edges, got %d and %d", nodes, edges, gotNodes, gotEdges)
+		}
+		if _, err := handler.Handle("NODE.MCREATE", []string{"missing", `[{"id":"a","type":"service"}]`}); !errors.Is(err, storage.ErrGraphNotFound) {
+			t.Errorf("Expected a missing graph to fail, got %v", err)
+		}
+	})
+
+	t.Run("Storage", func(t *testing.T) {
+		err := engine.CreateNodes(graphID, []*models.Node{
+			{ID: "worker", Type: "service"},
+			{ID: "bad\x00id", Type: "service"},
+		})
+		if !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected CreateNodes to reject the bad ID, got %v", err)
+		}
+		if _, err := engine.GetNode(graphID, "worker"); err == nil {
+			t.Error("Expected worker to be rolled back")
+		}
+
+		if err := engine.CreateNodes(graphID, []*models.Node{{ID: "worker", Type: "service"}}); err != nil {
+			t.Fatalf("CreateNodes failed: %v", err)
+		}
+		if err := engine.CreateEdges(graphID, []*models.Edge{{ID: "worker-db", FromNodeID: "worker", ToNodeID: "db", Type: "reads"}}); err != nil {
+			t.Errorf("CreateEdges failed: %v", err)
+		}
+		if out, err := engine.GetOutgoingEdges(graphID, "worker"); err != nil || len(out) != 1 {
+			t.Errorf("Expected worker to have 1 outgoing edge, got %v, %v", out, err)
+		}
+	})
+}
+
+// TestBatchGet tests reading several nodes or edges with NODE.MGET and
+// EDGE.MGET, with nulls for missing ones, and GetNodes and GetEdges
+func TestBatchGet(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_mget_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("services")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "services"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	if err := engine.CreateNodes(graphID, []*models.Node{
+		{ID: "api", Type: "service", Attributes: models.Attributes{"tier": 1.0}},
+		{ID: "db", Type: "database", Attributes: models.Attributes{}},
+		{ID: "old", Type: "service", ExpiresAt: &expired},
+	}); err != nil {
+		t.Fatalf("CreateNodes failed: %v", err)
+	}
+	if err := engine.CreateEdges(graphID, []*models.Edge{{ID: "api-db", Type: "reads", FromNodeID: "api", ToNodeID: "db", Attributes: models.Attributes{"pool": 10.0}}}); err != nil {
+		t.Fatalf("CreateEdges failed: %v", err)
+	}
+	if err := engine.AddNodeAlias(graphID, "db", "primary"); err != nil {
+		t.Fatalf("AddNodeAlias failed: %v", err)
+	}
+
+	t.Run("Storage", func(t *testing.T) {
+		nodes, err := engine.GetNodes(graphID, []models.NodeID{"db", "missing", "api", "old", "primary"})
+		if err != nil || len(nodes) != 5 {
+			t.Fatalf("Expected 5 entries, got %v, %v", nodes, err)
+		}
+		if nodes[0].ID != "db" || nodes[1] != nil || nodes[2].Attributes["tier"] != 1.0 || nodes[3] != nil || nodes[4].ID != "db" {
+			t.Errorf("Expected db, nil, api, nil for the expired node and db by its alias, got %v", nodes)
+		}
+		edges, err := engine.GetEdges(graphID, []models.EdgeID{"missing", "api-db"})
+		if err != nil || len(edges) != 2 || edges[0] != nil || edges[1].ToNodeID != "db" {
+			t.Errorf("Expected nil and api-db, got %v, %v", edges, err)
+		}
+		if nodes, err := engine.GetNodes(graphID, nil); err != nil || len(nodes) != 0 {
+			t.Errorf("Expected no nodes for no IDs, got %v, %v", nodes, err)
+		}
+	})
+
+	t.Run("Commands", func(t *testing.T) {
+		resp, err := handler.Handle("NODE.MGET", []string{string(graphID), "api", "missing", "db"})
+		expected := []interface{}{[]string{"api", "service", `{"tier":1}`, ""}, nil, []string{"db", "database", "{}", ""}}
+		if err != nil || !reflect.DeepEqual(resp.NestedArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+		resp, err = handler.Handle("EDGE.MGET", []string{string(graphID), "api-db", "missing"})
+		expected = []interface{}{[]string{"api-db", "api", "db", "reads", `{"pool":10}`, ""}, nil}
+		if err != nil || !reflect.DeepEqual(resp.NestedArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+		for _, command := range []string{"NODE.MGET", "EDGE.MGET"} {
+			if _, err := handler.Handle(command, []string{string(graphID)}); err == nil {
+				t.Errorf("Expected %s without IDs to fail", command)
+			}
+		}
+	})
+
+	t.Run("Server", func(t *testing.T) {
+		conn, err := net.Dial("tcp", startTestServer(t, engine, redis.DefaultConfig()))
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Write([]byte(encodeCommand("NODE.MGET", string(graphID), "missing", "db"))); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		reply, err := readReply(bufio.NewReader(conn))
+		if expected := []string{"2", "(nil)", "4", "db", "database", "{}", ""}; err != nil || !reflect.DeepEqual(reply, expected) {
+			t.Errorf("Expected %q, got %q, %v", expected, reply, err)
+		}
+	})
+}
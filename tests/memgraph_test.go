@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/internal/memgraph"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// Both the engine and the in-memory fixtures can back an analyzer
+var (
+	_ analysis.GraphReader = (*storage.BadgerEngine)(nil)
+	_ analysis.GraphReader = (*memgraph.Graph)(nil)
+)
+
+// TestMemGraph tests building in-memory graphs from edge lists and that
+// the analyzer finds the same results on them as on the Badger engine
+func TestMemGraph(t *testing.T) {
+	graphID := models.GraphID("fixture")
+
+	t.Run("EdgeList", func(t *testing.T) {
+		graph, err := memgraph.LoadFromEdgeList(graphID, `
+			api:service -[calls]-> auth:service -> db:database
+			api -[reads]-> cache, worker:job`)
+		if err != nil {
+			t.Fatalf("LoadFromEdgeList failed: %v", err)
+		}
+
+		nodes, err := graph.ListNodes(graphID)
+		if err != nil {
+			t.Fatalf("ListNodes failed: %v", err)
+		}
+		var labels []string
+		for _, node := range nodes {
+			labels = append(labels, string(node.ID)+":"+string(node.Type))
+		}
+		if expected := []string{"api:service", "auth:service", "cache:node", "db:database", "worker:job"}; !reflect.DeepEqual(labels, expected) {
+			t.Errorf("Expected nodes %v, got %v", expected, labels)
+		}
+
+		outgoing, err := graph.GetOutgoingEdges(graphID, "api")
+		if err != nil || len(outgoing) != 2 {
+			t.Fatalf("Expected 2 outgoing edges, got %v, %v", outgoing, err)
+		}
+		if outgoing[0].ID != "api-auth-calls" || outgoing[0].Type != "calls" || outgoing[1].ID != "api-cache-reads" || outgoing[1].Type != "reads" {
+			t.Errorf("Expected api-auth-calls and api-cache-reads, got %s and %s", outgoing[0].ID, outgoing[1].ID)
+		}
+		if incoming, err := graph.GetIncomingEdges(graphID, "db"); err != nil || len(incoming) != 1 || incoming[0].ID != "auth-db-edge" {
+			t.Errorf("Expected the untyped edge auth-db-edge into db, got %v, %v", incoming, err)
+		}
+
+		if _, err := graph.GetNode(graphID, "missing"); !errors.Is(err, storage.ErrNodeNotFound) {
+			t.Errorf("Expected ErrNodeNotFound, got %v", err)
+		}
+		if nodes, err := graph.ListNodes("other"); err != nil || len(nodes) != 0 {
+			t.Errorf("Expected another graph to be empty, got %v, %v", nodes, err)
+		}
+
+		for _, list := range []string{
+			"a:service -> a:database",
+			"a -> b, a -> b",
+			"a -> ",
+			"a -> b\x00c",
+		} {
+			if _, err := memgraph.LoadFromEdgeList(graphID, list); err == nil {
+				t.Errorf("Expected %q to fail", list)
+			}
+		}
+	})
+
+	t.Run("MatchesBadger", func(t *testing.T) {
+		graph, err := memgraph.LoadFromEdgeList(graphID, sampleEdgeList+", db -[replicates]-> cache -> auth")
+		if err != nil {
+			t.Fatalf("LoadFromEdgeList failed: %v", err)
+		}
+
+		testPath := filepath.Join(os.TempDir(), "pathwaydb_memgraph_test")
+		os.RemoveAll(testPath)
+		defer os.RemoveAll(testPath)
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+		nodes, _ := graph.ListNodes(graphID)
+		edges, _ := graph.ListEdges(graphID)
+		if err := engine.CreateNodes(graphID, nodes); err != nil {
+			t.Fatalf("CreateNodes failed: %v", err)
+		}
+		if err := engine.CreateEdges(graphID, edges); err != nil {
+			t.Fatalf("CreateEdges failed: %v", err)
+		}
+
+		inMemory, stored := analysis.NewGraphAnalyzer(graph), analysis.NewGraphAnalyzer(engine)
+		options := &types.TraversalOptions{Direction: types.DirectionForward}
+		for name, run := range map[string]func(a *analysis.GraphAnalyzer) (interface{}, error){
+			"DepthFirstSearch": func(a *analysis.GraphAnalyzer) (interface{}, error) {
+				result, err := a.DepthFirstSearch(graphID, "app", options)
+				if err != nil {
+					return nil, err
+				}
+				return result.Path, nil
+			},
+			"ShortestPath": func(a *analysis.GraphAnalyzer) (interface{}, error) {
+				result, err := a.GetShortestPath(graphID, "app", "cache", options)
+				if err != nil {
+					return nil, err
+				}
+				return result.Path, nil
+			},
+			"Cycles": func(a *analysis.GraphAnalyzer) (interface{}, error) {
+				cycles, err := a.FindAllCycles(graphID, options)
+				var found []string
+				for _, cycle := range cycles {
+					found = append(found, fmt.Sprint(cycle))
+				}
+				sort.Strings(found)
+				return found, err
+			},
+			"Dependents": func(a *analysis.GraphAnalyzer) (interface{}, error) {
+				return a.TransitiveClosureSize(graphID, "logger", types.DirectionBackward, nil)
+			},
+		} {
+			want, err := run(stored)
+			if err != nil {
+				t.Fatalf("%s on Badger failed: %v", name, err)
+			}
+			if got, err := run(inMemory); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %s in memory to give %v, got %v, %v", name, want, got, err)
+			}
+		}
+	})
+}
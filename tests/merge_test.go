@@ -0,0 +1,273 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestMerge tests merging two overlapping graphs under each conflict policy
+func TestMerge(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_merge_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	analyzer := analysis.NewGraphAnalyzer(engine)
+
+	createGraph := func(t *testing.T, graphID models.GraphID, nodes []*models.Node, edges []*models.Edge) {
+		t.Helper()
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		for _, node := range nodes {
+			if err := engine.CreateNode(graphID, node); err != nil {
+				t.Fatalf("Failed to create node %s: %v", node.ID, err)
+			}
+		}
+		for _, edge := range edges {
+			if err := engine.CreateEdge(graphID, edge); err != nil {
+				t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+			}
+		}
+	}
+
+	// Team A's view of the platform
+	createTeamA := func(t *testing.T, graphID models.GraphID) {
+		t.Helper()
+		createGraph(t, graphID, []*models.Node{
+			{ID: "web", Type: "service", Attributes: models.Attributes{"team": "a"}},
+			{ID: "api", Type: "service", Attributes: models.Attributes{"team": "a"}},
+			{ID: "db", Type: "database", Attributes: models.Attributes{"engine": "postgres"}},
+		}, []*models.Edge{
+			{ID: "web-api", Type: "calls", FromNodeID: "web", ToNodeID: "api"},
+			{ID: "api-db", Type: "uses", FromNodeID: "api", ToNodeID: "db"},
+		})
+	}
+	// Team B shares api and db, and the edge IDs api-db and web-api, the
+	// latter connecting other nodes
+	createGraph(t, "team-b", []*models.Node{
+		{ID: "api", Type: "gateway", Attributes: models.Attributes{"team": "b"}},
+		{ID: "db", Type: "database", Attributes: models.Attributes{"engine": "mysql"}},
+		{ID: "cache", Type: "cache"},
+		{ID: "queue", Type: "queue"},
+	}, []*models.Edge{
+		{ID: "api-db", Type: "uses", FromNodeID: "api", ToNodeID: "db", Attributes: models.Attributes{"pool": 10}},
+		{ID: "api-cache", Type: "uses", FromNodeID: "api", ToNodeID: "cache"},
+		{ID: "cache-queue", Type: "feeds", FromNodeID: "cache", ToNodeID: "queue"},
+		{ID: "web-api", Type: "calls", FromNodeID: "api", ToNodeID: "cache"},
+	})
+
+	checkIndexes := func(t *testing.T) {
+		t.Helper()
+		audit, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		if len(audit.Mismatches) != 0 {
+			t.Errorf("Expected consistent indexes, got %+v", audit.Mismatches)
+		}
+	}
+	nodeIDs := func(nodes []*models.Node) []models.NodeID {
+		ids := []models.NodeID{}
+		for _, node := range nodes {
+			ids = append(ids, node.ID)
+		}
+		return ids
+	}
+	edgeIDs := func(edges []*models.Edge) []models.EdgeID {
+		ids := []models.EdgeID{}
+		for _, edge := range edges {
+			ids = append(ids, edge.ID)
+		}
+		return ids
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		createTeamA(t, "merged-skip")
+		result, err := engine.MergeGraph("merged-skip", "team-b", storage.MergeSkip)
+		if err != nil {
+			t.Fatalf("MergeGraph failed: %v", err)
+		}
+		expected := &storage.MergeResult{NodesAdded: 2, NodesSkipped: 2, EdgesAdded: 2, EdgesSkipped: 2}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+
+		// dst keeps its versions, and edges to nodes only team B had are
+		// carried over with them
+		api, err := engine.GetNode("merged-skip", "api")
+		if err != nil || api.Type != "service" || api.Attributes["team"] != "a" {
+			t.Errorf("Expected api to keep team A's version, got %+v, %v", api, err)
+		}
+		out, err := engine.GetOutgoingEdges("merged-skip", "api")
+		if err != nil {
+			t.Fatalf("GetOutgoingEdges failed: %v", err)
+		}
+		if ids := edgeIDs(out); !reflect.DeepEqual(ids, []models.EdgeID{"api-cache", "api-db"}) {
+			t.Errorf("Expected api-cache and api-db, got %v", ids)
+		}
+		if edge, err := engine.GetEdge("merged-skip", "api-db"); err != nil || len(edge.Attributes) != 0 {
+			t.Errorf("Expected api-db to keep team A's attributes, got %+v, %v", edge, err)
+		}
+		if count, _ := engine.CountNodes("merged-skip"); count != 5 {
+			t.Errorf("Expected 5 nodes, got %d", count)
+		}
+		if count, _ := engine.CountEdges("merged-skip"); count != 4 {
+			t.Errorf("Expected 4 edges, got %d", count)
+		}
+		if count, _ := engine.CountNodes("team-b"); count != 4 {
+			t.Errorf("Expected team B to keep its 4 nodes, got %d", count)
+		}
+		checkIndexes(t)
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		createTeamA(t, "merged-overwrite")
+		result, err := engine.MergeGraph("merged-overwrite", "team-b", storage.MergeOverwrite)
+		if err != nil {
+			t.Fatalf("MergeGraph failed: %v", err)
+		}
+		expected := &storage.MergeResult{NodesAdded: 2, NodesOverwritten: 2, EdgesAdded: 2, EdgesOverwritten: 2}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+
+		// Attributes are taken whole from team B, and the type, attribute
+		// and edge indexes follow the overwritten entities
+		api, err := engine.GetNode("merged-overwrite", "api")
+		if err != nil || api.Type != "gateway" || !reflect.DeepEqual(api.Attributes, models.Attributes{"team": "b"}) {
+			t.Errorf("Expected api to be team B's version, got %+v, %v", api, err)
+		}
+		services, err := engine.ListNodesByType("merged-overwrite", "service")
+		if err != nil || !reflect.DeepEqual(nodeIDs(services), []models.NodeID{"web"}) {
+			t.Errorf("Expected only web to be a service, got %v, %v", nodeIDs(services), err)
+		}
+		for value, expected := range map[string][]models.NodeID{"postgres": {}, "mysql": {"db"}} {
+			nodes, err := engine.FindNodesByAttribute("merged-overwrite", "engine", value)
+			if err != nil || !reflect.DeepEqual(nodeIDs(nodes), expected) {
+				t.Errorf("Expected %v for engine %s, got %v, %v", expected, value, nodeIDs(nodes), err)
+			}
+		}
+		out, err := engine.GetOutgoingEdges("merged-overwrite", "web")
+		if err != nil || len(out) != 0 {
+			t.Errorf("Expected web-api to have moved off web, got %v, %v", edgeIDs(out), err)
+		}
+		in, err := engine.GetIncomingEdges("merged-overwrite", "cache")
+		if err != nil || !reflect.DeepEqual(edgeIDs(in), []models.EdgeID{"api-cache", "web-api"}) {
+			t.Errorf("Expected api-cache and web-api into cache, got %v, %v", edgeIDs(in), err)
+		}
+		checkIndexes(t)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		createTeamA(t, "merged-error")
+		snapshot, err := engine.CreateSnapshot("merged-error", "before")
+		if err != nil {
+			t.Fatalf("CreateSnapshot failed: %v", err)
+		}
+		before, err := engine.ReadSnapshot("merged-error", snapshot.ID)
+		if err != nil {
+			t.Fatalf("ReadSnapshot failed: %v", err)
+		}
+		generation := engine.Generation("merged-error")
+
+		_, err = engine.MergeGraph("merged-error", "team-b", storage.MergeError)
+		if !errors.Is(err, storage.ErrMergeConflict) || !strings.Contains(err.Error(), "node api") {
+			t.Fatalf("Expected a conflict on node api, got %v", err)
+		}
+
+		// Nothing was written
+		diff, err := analyzer.DiffSnapshot("merged-error", before)
+		if err != nil {
+			t.Fatalf("DiffSnapshot failed: %v", err)
+		}
+		if !reflect.DeepEqual(diff, &types.GraphDiff{}) {
+			t.Errorf("Expected dst to be unchanged, got %+v", diff)
+		}
+		if engine.Generation("merged-error") != generation {
+			t.Error("Expected the generation to be unchanged")
+		}
+
+		// Without overlap the merge goes ahead
+		createGraph(t, "team-c", []*models.Node{{ID: "search", Type: "service"}, {ID: "index", Type: "database"}},
+			[]*models.Edge{{ID: "search-index", Type: "uses", FromNodeID: "search", ToNodeID: "index"}})
+		result, err := engine.MergeGraph("merged-error", "team-c", storage.MergeError)
+		if err != nil {
+			t.Fatalf("MergeGraph failed: %v", err)
+		}
+		if expected := (&storage.MergeResult{NodesAdded: 2, EdgesAdded: 1}); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+		diff, err = analyzer.DiffSnapshot("merged-error", before)
+		if err != nil {
+			t.Fatalf("DiffSnapshot failed: %v", err)
+		}
+		expected := &types.GraphDiff{AddedNodes: []models.NodeID{"index", "search"}, AddedEdges: []models.EdgeID{"search-index"}}
+		if !reflect.DeepEqual(diff, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, diff)
+		}
+		checkIndexes(t)
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+		createTeamA(t, "merged-command")
+
+		resp, err := handler.Handle("GRAPH.MERGE", []string{"merged-command", "from", "team-b", "ONCONFLICT", "SKIP"})
+		if err != nil {
+			t.Fatalf("GRAPH.MERGE failed: %v", err)
+		}
+		expected := []string{"nodes_added", "2", "nodes_skipped", "2", "nodes_overwritten", "0",
+			"edges_added", "2", "edges_skipped", "2", "edges_overwritten", "0"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+		// The default policy is error
+		if _, err := handler.Handle("GRAPH.MERGE", []string{"merged-command", "FROM", "team-b"}); err == nil || !strings.Contains(err.Error(), "merge conflict") {
+			t.Errorf("Expected a merge conflict, got %v", err)
+		}
+
+		// A self-loop the destination forbids fails the merge up front
+		createGraph(t, "loopy", []*models.Node{{ID: "worker", Type: "service"}},
+			[]*models.Edge{{ID: "retry", Type: "calls", FromNodeID: "worker", ToNodeID: "worker"}})
+		if _, err := handler.Handle("GRAPH.CONSTRAINT", []string{"SET", "merged-command", "SELFLOOPS", "FORBID"}); err != nil {
+			t.Fatalf("GRAPH.CONSTRAINT failed: %v", err)
+		}
+		if _, err := handler.Handle("GRAPH.MERGE", []string{"merged-command", "FROM", "loopy", "ONCONFLICT", "skip"}); err == nil || !strings.Contains(err.Error(), "self-loop") {
+			t.Errorf("Expected the self-loop to be rejected, got %v", err)
+		}
+		if _, err := engine.GetNode("merged-command", "worker"); err == nil {
+			t.Error("Expected nothing to be merged from loopy")
+		}
+
+		for _, args := range [][]string{
+			{"merged-command", "team-b"},
+			{"merged-command", "INTO", "team-b"},
+			{"merged-command", "FROM", "team-b", "ONCONFLICT"},
+			{"merged-command", "FROM", "team-b", "ONCONFLICT", "merge"},
+			{"merged-command", "FROM", "team-b", "POLICY", "skip"},
+			{"merged-command", "FROM", "merged-command"},
+			{"merged-command", "FROM", "missing"},
+			{"missing", "FROM", "team-b"},
+		} {
+			if _, err := handler.Handle("GRAPH.MERGE", args); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+	})
+}
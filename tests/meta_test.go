@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestGraphMeta tests graph-scoped metadata through the META commands
+func TestGraphMeta(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_meta_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine(storage.WithMetaQuota(64))
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	handler := redis.NewCommandHandler(engine)
+
+	setup := func(t *testing.T, graphID models.GraphID) {
+		t.Helper()
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		for _, id := range []models.NodeID{"api", "db"} {
+			if err := engine.CreateNode(graphID, &models.Node{ID: id, Type: "service"}); err != nil {
+				t.Fatalf("Failed to create node: %v", err)
+			}
+		}
+		if err := engine.CreateEdge(graphID, &models.Edge{ID: "api-db", Type: "uses", FromNodeID: "api", ToNodeID: "db"}); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+	set := func(t *testing.T, args ...string) {
+		t.Helper()
+		if _, err := handler.Handle("META.SET", args); err != nil {
+			t.Fatalf("META.SET %v failed: %v", args, err)
+		}
+	}
+
+	t.Run("CRUD", func(t *testing.T) {
+		setup(t, "crud")
+		generation := engine.Generation("crud")
+
+		set(t, "crud", "ide-layout", "db", `{"x": 300, "y": 80}`)
+		set(t, "crud", "ide-layout", "api", `{"x": 120, "y": 80}`)
+		set(t, "crud", "notes", "api", `"owned by payments"`)
+
+		resp, err := handler.Handle("META.GET", []string{"crud", "ide-layout", "db"})
+		if err != nil || resp.StringValue != `{"x":300,"y":80}` {
+			t.Errorf("Expected the compacted value, got %v, %v", resp, err)
+		}
+		resp, err = handler.Handle("META.LIST", []string{"crud", "ide-layout"})
+		expected := []string{"api", `{"x":120,"y":80}`, "db", `{"x":300,"y":80}`}
+		if err != nil || !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+
+		// Overwriting replaces the value
+		set(t, "crud", "ide-layout", "db", `{"x": 310, "y": 90}`)
+		entry, err := engine.GetMeta("crud", "ide-layout", "db")
+		if err != nil || entry == nil || string(entry.Value) != `{"x":310,"y":90}` {
+			t.Errorf("Expected the new value, got %+v, %v", entry, err)
+		}
+
+		resp, err = handler.Handle("META.DEL", []string{"crud", "ide-layout", "db"})
+		if err != nil || resp.IntValue != 1 {
+			t.Errorf("Expected 1 for an existing key, got %v, %v", resp, err)
+		}
+		resp, err = handler.Handle("META.DEL", []string{"crud", "ide-layout", "db"})
+		if err != nil || resp.IntValue != 0 {
+			t.Errorf("Expected 0 for a missing key, got %v, %v", resp, err)
+		}
+		resp, err = handler.Handle("META.GET", []string{"crud", "ide-layout", "db"})
+		if err != nil || resp.Type != protocol.ResponseTypeNull {
+			t.Errorf("Expected null for a deleted key, got %v, %v", resp, err)
+		}
+
+		// Metadata stays out of the graph's data
+		if got := engine.Generation("crud"); got != generation {
+			t.Errorf("Expected metadata writes to leave the generation at %d, got %d", generation, got)
+		}
+		if count, _ := engine.CountNodes("crud"); count != 2 {
+			t.Errorf("Expected 2 nodes, got %d", count)
+		}
+		nodes, _ := engine.ListNodes("crud")
+		for _, node := range nodes {
+			if len(node.Attributes) != 0 {
+				t.Errorf("Expected metadata not to touch node %s, got %v", node.ID, node.Attributes)
+			}
+		}
+
+		for _, tc := range []struct {
+			command string
+			args    []string
+			badArg  bool
+		}{
+			{"META.SET", []string{"crud", "ide:layout", "api", `{}`}, true},
+			{"META.SET", []string{"crud", "", "api", `{}`}, true},
+			{"META.SET", []string{"crud", "ide-layout", "", `{}`}, true},
+			{"META.SET", []string{"crud", "ide-layout", "api", `{"x":`}, true},
+			{"META.SET", []string{"crud", "ide-layout", "api"}, false},
+			{"META.SET", []string{"missing", "ide-layout", "api", `{}`}, false},
+			{"META.GET", []string{"missing", "ide-layout", "api"}, false},
+			{"META.LIST", []string{"crud", "a:b"}, true},
+			{"META.RENAME", []string{"crud", "ide-layout"}, false},
+		} {
+			_, err := handler.Handle(tc.command, tc.args)
+			if err == nil {
+				t.Errorf("Expected %s %v to fail", tc.command, tc.args)
+			} else if tc.badArg != errors.Is(err, models.ErrBadArgument) {
+				t.Errorf("Expected %s %v BADARG to be %v, got %v", tc.command, tc.args, tc.badArg, err)
+			}
+		}
+	})
+
+	t.Run("Quota", func(t *testing.T) {
+		setup(t, "quota")
+
+		// 3 + 16 bytes each, so three fit in 64 but not four
+		for _, key := range []string{"k01", "k02", "k03"} {
+			set(t, "quota", "ide-layout", key, `{"x":100,"y":10}`)
+		}
+		_, err := handler.Handle("META.SET", []string{"quota", "ide-layout", "k04", `{"x":100,"y":10}`})
+		if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+			t.Errorf("Expected a quota error, got %v", err)
+		}
+		if err := engine.SetMeta("quota", &models.MetaEntry{Namespace: "ide-layout", Key: "k04", Value: []byte(`{"x":100,"y":10}`)}); !errors.Is(err, storage.ErrMetaQuota) {
+			t.Errorf("Expected ErrMetaQuota, got %v", err)
+		}
+
+		// Replacing a value only counts it once, and other namespaces have
+		// their own quota
+		set(t, "quota", "ide-layout", "k01", `{"x":200,"y":20}`)
+		set(t, "quota", "notes", "k04", `{"x":100,"y":10}`)
+
+		// Deleting frees room
+		if _, err := handler.Handle("META.DEL", []string{"quota", "ide-layout", "k01"}); err != nil {
+			t.Fatalf("META.DEL failed: %v", err)
+		}
+		set(t, "quota", "ide-layout", "k04", `{"x":100,"y":10}`)
+	})
+
+	t.Run("DeleteGraph", func(t *testing.T) {
+		setup(t, "deleted")
+		set(t, "deleted", "ide-layout", "api", `{"x":1,"y":2}`)
+		set(t, "deleted", "notes", "db", `"primary"`)
+		// A graph whose ID extends this one keeps its metadata
+		setup(t, "deleted-other")
+		set(t, "deleted-other", "ide-layout", "api", `{"x":1,"y":2}`)
+
+		if _, err := handler.Handle("GRAPH.DELETE", []string{"deleted"}); err != nil {
+			t.Fatalf("GRAPH.DELETE failed: %v", err)
+		}
+		setup(t, "deleted")
+		for _, namespace := range []string{"ide-layout", "notes"} {
+			entries, err := engine.ListMeta("deleted", namespace)
+			if err != nil || len(entries) != 0 {
+				t.Errorf("Expected no %s metadata on the recreated graph, got %v, %v", namespace, entries, err)
+			}
+		}
+		if entry, _ := engine.GetMeta("deleted-other", "ide-layout", "api"); entry == nil {
+			t.Error("Expected another graph's metadata to be kept")
+		}
+	})
+
+	t.Run("ExportImport", func(t *testing.T) {
+		setup(t, "exported")
+		set(t, "exported", "ide-layout", "api", `{"x":120,"y":80}`)
+		set(t, "exported", "notes", "db", `"primary"`)
+
+		resp, err := handler.Handle("GRAPH.EXPORT", []string{"exported"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT failed: %v", err)
+		}
+		if strings.Contains(resp.StringValue, `"meta"`) {
+			t.Errorf("Expected no metadata without WITHMETA, got %s", resp.StringValue)
+		}
+		if _, err := handler.Handle("GRAPH.IMPORT", []string{"plain-copy", resp.StringValue}); err != nil {
+			t.Fatalf("GRAPH.IMPORT failed: %v", err)
+		}
+		if entries, _ := engine.ListMeta("plain-copy", "ide-layout"); len(entries) != 0 {
+			t.Errorf("Expected no metadata in the plain copy, got %v", entries)
+		}
+
+		resp, err = handler.Handle("GRAPH.EXPORT", []string{"exported", "WITHMETA"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT WITHMETA failed: %v", err)
+		}
+		document := resp.StringValue
+		if !strings.HasSuffix(document, `,"meta":[{"namespace":"ide-layout","key":"api","value":{"x":120,"y":80}},{"namespace":"notes","key":"db","value":"primary"}]}`) {
+			t.Errorf("Expected the metadata after the edges, got %s", document)
+		}
+		resp, err = handler.Handle("GRAPH.IMPORT", []string{"meta-copy", document})
+		if err != nil || !reflect.DeepEqual(resp.ArrayValue, []string{"2", "1"}) {
+			t.Fatalf("GRAPH.IMPORT failed: %v, %v", resp, err)
+		}
+		for _, expected := range []models.MetaEntry{
+			{Namespace: "ide-layout", Key: "api", Value: []byte(`{"x":120,"y":80}`)},
+			{Namespace: "notes", Key: "db", Value: []byte(`"primary"`)},
+		} {
+			entry, err := engine.GetMeta("meta-copy", expected.Namespace, expected.Key)
+			if err != nil || entry == nil || string(entry.Value) != string(expected.Value) {
+				t.Errorf("Expected %s %s to be imported, got %+v, %v", expected.Namespace, expected.Key, entry, err)
+			}
+		}
+
+		// The chunked form takes the flag before CHUNKED
+		session := &commands.Session{}
+		resp, err = handler.HandleSession(session, "GRAPH.EXPORT", []string{"exported", "WITHMETA", "CHUNKED", "65536", "BEGIN"})
+		if err != nil {
+			t.Fatalf("GRAPH.EXPORT WITHMETA CHUNKED failed: %v", err)
+		}
+		resp, err = handler.HandleSession(session, "GRAPH.EXPORT", []string{"NEXT", resp.ArrayValue[0]})
+		if err != nil || resp.ArrayValue[0] != document || resp.ArrayValue[2] != "1" {
+			t.Errorf("Expected the chunk to match the whole export, got %v, %v", resp, err)
+		}
+
+		// Imports are checked against the quota before anything is written
+		large := strings.Replace(document, `"value":"primary"`, `"value":"`+strings.Repeat("p", 64)+`"`, 1)
+		if _, err := handler.Handle("GRAPH.IMPORT", []string{"too-large", large}); err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+			t.Errorf("Expected a quota error, got %v", err)
+		}
+		if _, err := engine.GetGraph("too-large"); err == nil {
+			t.Error("Expected no graph to be created")
+		}
+	})
+}
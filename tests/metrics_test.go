@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// Lines of the Prometheus text exposition format, as written by
+// SYSTEM.METRICS: comments, and samples with at most one label
+var (
+	metricHelpLine   = regexp.MustCompile(`^# HELP ([a-zA-Z_:][a-zA-Z0-9_:]*) (.+)$`)
+	metricTypeLine   = regexp.MustCompile(`^# TYPE ([a-zA-Z_:][a-zA-Z0-9_:]*) (counter|gauge|histogram|summary|untyped)$`)
+	metricSampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(?:\{([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\\n]|\\[\\"n])*)"\})? (\S+)$`)
+)
+
+// parseExposition parses text in the exposition format into the value of
+// each sample by metric name and label value, failing the test on any line
+// a Prometheus parser would reject
+func parseExposition(t *testing.T, text string) map[string]map[string]float64 {
+	t.Helper()
+	if !strings.HasSuffix(text, "\n") {
+		t.Fatalf("Expected the exposition to end with a newline, got %q", text)
+	}
+	samples := make(map[string]map[string]float64)
+	typed := make(map[string]bool)
+	unescape := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if match := metricHelpLine.FindStringSubmatch(line); match != nil {
+			continue
+		}
+		if match := metricTypeLine.FindStringSubmatch(line); match != nil {
+			if typed[match[1]] {
+				t.Errorf("Metric %s is typed twice", match[1])
+			}
+			typed[match[1]] = true
+			continue
+		}
+		match := metricSampleLine.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("Invalid exposition line %q", line)
+		}
+		if !typed[match[1]] {
+			t.Errorf("Sample of %s comes before its TYPE line", match[1])
+		}
+		value, err := strconv.ParseFloat(match[4], 64)
+		if err != nil {
+			t.Fatalf("Invalid sample value in %q", line)
+		}
+		if samples[match[1]] == nil {
+			samples[match[1]] = make(map[string]float64)
+		}
+		label := unescape.Replace(match[3])
+		if _, seen := samples[match[1]][label]; seen {
+			t.Errorf("Duplicate sample %q", line)
+		}
+		samples[match[1]][label] = value
+	}
+	return samples
+}
+
+// TestSystemMetrics tests that SYSTEM.METRICS TEXT reports the per-graph
+// gauges in valid exposition format
+func TestSystemMetrics(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_metrics_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	// A graph ID that needs escaping in a label value
+	quoted := models.GraphID(`team "a" \ ops`)
+	for _, graphID := range []models.GraphID{"services", quoted} {
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+	}
+	for _, id := range []models.NodeID{"api", "db", "cache", "standalone", "spare"} {
+		if err := engine.CreateNode("services", &models.Node{ID: id, Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	for i, pair := range [][2]models.NodeID{{"api", "db"}, {"api", "cache"}, {"cache", "db"}} {
+		edge := &models.Edge{ID: models.EdgeID(fmt.Sprintf("e%d", i)), Type: "uses", FromNodeID: pair[0], ToNodeID: pair[1]}
+		if err := engine.CreateEdge("services", edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+	if err := engine.CreateNode(quoted, &models.Node{ID: "lonely", Type: "service"}); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	metrics := func(t *testing.T) map[string]map[string]float64 {
+		t.Helper()
+		resp, err := handler.Handle("SYSTEM.METRICS", []string{"text"})
+		if err != nil {
+			t.Fatalf("SYSTEM.METRICS TEXT failed: %v", err)
+		}
+		return parseExposition(t, resp.StringValue)
+	}
+
+	t.Run("Gauges", func(t *testing.T) {
+		expected := map[string]map[string]float64{
+			"pathwaydb_graphs":             {"": 2},
+			"pathwaydb_graph_nodes":        {"services": 5, string(quoted): 1},
+			"pathwaydb_graph_edges":        {"services": 3, string(quoted): 0},
+			"pathwaydb_graph_orphan_nodes": {"services": 2, string(quoted): 1},
+		}
+		if got := metrics(t); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Follows Writes", func(t *testing.T) {
+		// Deleting cache takes its edges with it, leaving api and db with
+		// one edge between them
+		if err := engine.DeleteNode("services", "cache"); err != nil {
+			t.Fatalf("Failed to delete node: %v", err)
+		}
+		got := metrics(t)
+		if got["pathwaydb_graph_nodes"]["services"] != 4 || got["pathwaydb_graph_edges"]["services"] != 1 || got["pathwaydb_graph_orphan_nodes"]["services"] != 2 {
+			t.Errorf("Expected 4 nodes, 1 edge and 2 orphans, got %v", got)
+		}
+
+		count, err := engine.CountOrphanNodes("services")
+		if err != nil {
+			t.Fatalf("CountOrphanNodes failed: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("Expected CountOrphanNodes to count standalone and spare, got %d", count)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{{}, {"JSON"}, {"TEXT", "services"}} {
+			if _, err := handler.Handle("SYSTEM.METRICS", args); err == nil {
+				t.Errorf("Expected SYSTEM.METRICS %v to be rejected", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestNodeAliases tests adding, resolving and removing node aliases
+func TestNodeAliases(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_nodealias_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	run := func(t *testing.T, args ...string) []string {
+		t.Helper()
+		resp, err := handler.Handle(args[0], args[1:])
+		if err != nil {
+			t.Fatalf("%s failed: %v", strings.Join(args, " "), err)
+		}
+		return resp.ArrayValue
+	}
+
+	if err := engine.CreateGraph(&models.Graph{ID: "services", Name: "services"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"checkout", "payments", "ledger"} {
+		if err := engine.CreateNode("services", &models.Node{ID: id, Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node %s: %v", id, err)
+		}
+	}
+	for _, alias := range []struct {
+		node  models.NodeID
+		alias string
+	}{
+		{"checkout", "acme/checkout"},
+		{"checkout", "checkout.svc.cluster.local"},
+		{"payments", "SVC-0042"},
+	} {
+		if err := engine.AddNodeAlias("services", alias.node, alias.alias); err != nil {
+			t.Fatalf("AddNodeAlias %s failed: %v", alias.alias, err)
+		}
+	}
+
+	t.Run("Resolution", func(t *testing.T) {
+		for id, expected := range map[models.NodeID]models.NodeID{
+			"checkout":      "checkout",
+			"acme/checkout": "checkout",
+			"SVC-0042":      "payments",
+			"missing":       "missing",
+		} {
+			resolved, err := engine.ResolveNodeID("services", id)
+			if err != nil || resolved != expected {
+				t.Errorf("Expected %s to resolve to %s, got %s, %v", id, expected, resolved, err)
+			}
+		}
+		aliases, err := engine.ListNodeAliases("services", "checkout")
+		if err != nil || !reflect.DeepEqual(aliases, []string{"acme/checkout", "checkout.svc.cluster.local"}) {
+			t.Errorf("Expected both aliases of checkout, got %v, %v", aliases, err)
+		}
+		if _, err := engine.ListNodeAliases("services", "missing"); err == nil {
+			t.Error("Expected an error listing the aliases of a missing node")
+		}
+	})
+
+	t.Run("Commands", func(t *testing.T) {
+		run(t, "EDGE.CREATE", "services", "checkout-payments", "acme/checkout", "SVC-0042", "calls")
+		run(t, "EDGE.CREATE", "services", "payments-ledger", "SVC-0042", "ledger", "writes_to")
+		edge, err := engine.GetEdge("services", "checkout-payments")
+		if err != nil || edge.FromNodeID != "checkout" || edge.ToNodeID != "payments" {
+			t.Fatalf("Expected the edge endpoints to be node IDs, got %+v, %v", edge, err)
+		}
+
+		if node := run(t, "NODE.GET", "services", "SVC-0042"); len(node) == 0 || node[0] != "payments" {
+			t.Errorf("Expected NODE.GET by alias to return payments, got %v", node)
+		}
+		run(t, "NODE.UPDATE", "services", "SVC-0042", "ATTRIBUTES", `{"tier":"1"}`)
+		if node, err := engine.GetNode("services", "payments"); err != nil || node.Attributes["tier"] != "1" {
+			t.Errorf("Expected NODE.UPDATE by alias to update payments, got %+v, %v", node, err)
+		}
+
+		expected := []string{"checkout:service->checkout-payments:calls->payments:service->payments-ledger:writes_to->ledger:service"}
+		if paths := run(t, "ANALYSIS.TRAVERSE", "services", "checkout.svc.cluster.local"); !reflect.DeepEqual(paths, expected) {
+			t.Errorf("Expected a traversal from the alias to start at checkout, got %v", paths)
+		}
+		if path := run(t, "ANALYSIS.SHORTESTPATH", "services", "acme/checkout", "ledger", "FORMAT", "simple"); len(path) == 0 || path[0] != "checkout:service" {
+			t.Errorf("Expected a shortest path from checkout, got %v", path)
+		}
+		if neighbors := run(t, "EDGE.NEIGHBORS", "services", "SVC-0042", "out", "FORMAT", "simple"); !reflect.DeepEqual(neighbors, []string{"ledger:service"}) {
+			t.Errorf("Expected the neighbors of payments, got %v", neighbors)
+		}
+
+		if aliases := run(t, "NODE.ALIAS", "LIST", "services", "acme/checkout"); !reflect.DeepEqual(aliases, []string{"acme/checkout", "checkout.svc.cluster.local"}) {
+			t.Errorf("Expected NODE.ALIAS LIST to list checkout's aliases, got %v", aliases)
+		}
+		run(t, "NODE.ALIAS", "ADD", "services", "ledger", "ledger-v2")
+		for _, tc := range []struct {
+			alias    string
+			expected int64
+		}{{"ledger-v2", 1}, {"ledger-v2", 0}, {"SVC-0042", 0}} {
+			resp, err := handler.Handle("NODE.ALIAS", []string{"REMOVE", "services", "ledger", tc.alias})
+			if err != nil || resp.IntValue != tc.expected {
+				t.Errorf("Expected NODE.ALIAS REMOVE %s to reply %d, got %+v, %v", tc.alias, tc.expected, resp, err)
+			}
+		}
+
+		for _, args := range [][]string{
+			{},
+			{"RENAME", "services", "ledger", "x"},
+			{"ADD", "services", "ledger"},
+			{"LIST", "services"},
+			{"ADD", "services", "missing", "x"},
+			{"ADD", "services", "ledger", " padded"},
+		} {
+			if _, err := handler.Handle("NODE.ALIAS", args); err == nil {
+				t.Errorf("Expected NODE.ALIAS %v to fail", args)
+			}
+		}
+	})
+
+	t.Run("Collisions", func(t *testing.T) {
+		for _, tc := range []struct {
+			name  string
+			node  models.NodeID
+			alias string
+		}{
+			{"Alias Of Another Node", "ledger", "SVC-0042"},
+			{"Node ID", "ledger", "payments"},
+			{"Own Node ID", "ledger", "ledger"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				if err := engine.AddNodeAlias("services", tc.node, tc.alias); !errors.Is(err, storage.ErrAliasConflict) {
+					t.Errorf("Expected ErrAliasConflict, got %v", err)
+				}
+			})
+		}
+		if err := engine.AddNodeAlias("services", "payments", "SVC-0042"); err != nil {
+			t.Errorf("Expected re-adding an alias to the same node to succeed, got %v", err)
+		}
+		if err := engine.CreateNode("services", &models.Node{ID: "SVC-0042", Type: "service"}); !errors.Is(err, storage.ErrAliasConflict) {
+			t.Errorf("Expected creating a node with an alias as its ID to fail, got %v", err)
+		}
+		if err := engine.AddNodeAlias("services", "ledger", ""); !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected an empty alias to be rejected, got %v", err)
+		}
+		if _, err := handler.Handle("NODE.ALIAS", []string{"ADD", "services", "ledger", "acme/checkout"}); err == nil || !strings.Contains(err.Error(), "alias of node checkout") {
+			t.Errorf("Expected NODE.ALIAS ADD to name the node holding the alias, got %v", err)
+		}
+	})
+
+	t.Run("Deletion", func(t *testing.T) {
+		run(t, "NODE.DELETE", "services", "checkout.svc.cluster.local")
+		if _, err := engine.GetNode("services", "checkout"); err == nil {
+			t.Fatal("Expected NODE.DELETE by alias to delete checkout")
+		}
+		for _, alias := range []models.NodeID{"acme/checkout", "checkout.svc.cluster.local"} {
+			if resolved, err := engine.ResolveNodeID("services", alias); err != nil || resolved != alias {
+				t.Errorf("Expected alias %s to be removed with its node, got %s, %v", alias, resolved, err)
+			}
+		}
+
+		// A freed alias can become a node ID
+		if err := engine.CreateNode("services", &models.Node{ID: "acme/checkout", Type: "service"}); err != nil {
+			t.Errorf("Expected a freed alias to be usable as a node ID, got %v", err)
+		}
+
+		audit, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		if audit.Families["al"] != 1 || audit.Families["na"] != 1 || len(audit.Mismatches) != 0 {
+			t.Errorf("Expected the alias of payments and its index entry only, got %v, %v", audit.Families, audit.Mismatches)
+		}
+
+		if _, err := engine.DeleteGraph("services"); err != nil {
+			t.Fatalf("DeleteGraph failed: %v", err)
+		}
+		if audit, err = engine.AuditKeys(""); err != nil || audit.Families["al"] != 0 || audit.Families["na"] != 0 {
+			t.Errorf("Expected DeleteGraph to remove every alias, got %v, %v", audit.Families, err)
+		}
+	})
+}
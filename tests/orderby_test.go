@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestOrderBy tests sorting the list, filter and neighbor commands with
+// ORDERBY, and the limit on how many results it sorts
+func TestOrderBy(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_orderby_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	// a and c, and e1 and e3, are created at the same time; d and e4
+	// predate timestamps
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return t0.Add(time.Duration(hours) * time.Hour) }
+	if err := engine.CreateGraph(&models.Graph{ID: "g", Name: "g"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "a", Type: "svc", CreatedAt: at(2), UpdatedAt: at(5)},
+		{ID: "b", Type: "db", CreatedAt: at(0), UpdatedAt: at(1)},
+		{ID: "c", Type: "svc", CreatedAt: at(2), UpdatedAt: at(3)},
+		{ID: "d", Type: "cache"},
+	} {
+		node.Attributes = models.Attributes{"tier": 1.0}
+		if err := engine.CreateNode("g", node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "e1", Type: "uses", FromNodeID: "a", ToNodeID: "b", CreatedAt: at(1), UpdatedAt: at(1)},
+		{ID: "e2", Type: "calls", FromNodeID: "a", ToNodeID: "c", CreatedAt: at(3), UpdatedAt: at(4)},
+		{ID: "e3", Type: "uses", FromNodeID: "d", ToNodeID: "a", CreatedAt: at(1), UpdatedAt: at(6)},
+		{ID: "e4", Type: "calls", FromNodeID: "b", ToNodeID: "c"},
+	} {
+		edge.Attributes = models.Attributes{"weight": 1.0}
+		if err := engine.CreateEdge("g", edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	// ids returns the IDs of a reply, taking every stride-th item and
+	// cutting it at the first colon
+	ids := func(t *testing.T, stride int, command string, args ...string) []string {
+		t.Helper()
+		resp, err := handler.Handle(command, args)
+		if err != nil {
+			t.Fatalf("%s %v failed: %v", command, args, err)
+		}
+		var got []string
+		for i := 0; i < len(resp.ArrayValue); i += stride {
+			id, _, _ := strings.Cut(resp.ArrayValue[i], ":")
+			got = append(got, id)
+		}
+		return got
+	}
+
+	t.Run("Keys", func(t *testing.T) {
+		for _, tc := range []struct {
+			command  string
+			stride   int
+			args     []string
+			expected []string
+		}{
+			// Ties by ascending ID in both directions, and no timestamp is oldest
+			{"NODE.LIST", 1, []string{"g"}, []string{"a", "b", "c", "d"}},
+			{"NODE.LIST", 1, []string{"g", "ORDERBY", "id"}, []string{"a", "b", "c", "d"}},
+			{"NODE.LIST", 1, []string{"g", "ORDERBY", "id", "DESC"}, []string{"d", "c", "b", "a"}},
+			{"NODE.LIST", 1, []string{"g", "ORDERBY", "type"}, []string{"d", "b", "a", "c"}},
+			{"NODE.LIST", 1, []string{"g", "ORDERBY", "type", "DESC"}, []string{"a", "c", "b", "d"}},
+			{"NODE.LIST", 1, []string{"g", "ORDERBY", "created"}, []string{"d", "b", "a", "c"}},
+			{"NODE.LIST", 1, []string{"g", "ORDERBY", "created", "DESC"}, []string{"a", "c", "b", "d"}},
+			{"NODE.LIST", 1, []string{"g", "ORDERBY", "updated"}, []string{"d", "b", "c", "a"}},
+			{"NODE.LIST", 1, []string{"g", "orderby", "UPDATED", "desc"}, []string{"a", "c", "b", "d"}},
+			{"NODE.LIST", 1, []string{"g", "LABELS", "ORDERBY", "created", "DESC"}, []string{"a", "c", "b", "d"}},
+
+			{"EDGE.LIST", 1, []string{"g", "ORDERBY", "created"}, []string{"e4", "e1", "e3", "e2"}},
+			{"EDGE.LIST", 1, []string{"g", "ORDERBY", "created", "DESC"}, []string{"e2", "e1", "e3", "e4"}},
+			{"EDGE.LIST", 1, []string{"g", "ORDERBY", "type"}, []string{"e2", "e4", "e1", "e3"}},
+			{"EDGE.LIST", 1, []string{"g", "ORDERBY", "updated", "DESC"}, []string{"e3", "e2", "e1", "e4"}},
+
+			// Neighbors sort by their connecting edges
+			{"EDGE.NEIGHBORS", 1, []string{"g", "a", "FORMAT", "simple", "ORDERBY", "created", "DESC"}, []string{"c", "b", "d"}},
+			{"EDGE.NEIGHBORS", 1, []string{"g", "a", "out", "FORMAT", "simple", "ORDERBY", "type"}, []string{"c", "b"}},
+			{"EDGE.NEIGHBORS", 1, []string{"g", "a", "FORMAT", "simple", "ORDERBY", "updated", "DESC"}, []string{"d", "c", "b"}},
+
+			{"NODE.FILTER", 3, []string{"g", "tier", "1", "ORDERBY", "created", "DESC"}, []string{"a", "c", "b", "d"}},
+			{"NODE.FILTER", 3, []string{"g", "UPDATEDBEFORE", at(4).Format(time.RFC3339), "ORDERBY", "updated", "DESC"}, []string{"c", "b", "d"}},
+			{"EDGE.FILTER", 5, []string{"g", "weight", "1", "ORDERBY", "id", "DESC"}, []string{"e4", "e3", "e2", "e1"}},
+			{"EDGE.FILTER", 5, []string{"g", "TYPE", "uses", "ORDERBY", "created", "DESC"}, []string{"e1", "e3"}},
+		} {
+			if got := ids(t, tc.stride, tc.command, tc.args...); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("%s %v: expected %v, got %v", tc.command, tc.args, tc.expected, got)
+			}
+		}
+	})
+
+	t.Run("Formats", func(t *testing.T) {
+		resp, err := handler.Handle("EDGE.LIST", []string{"g", "VERBOSE", "ORDERBY", "created", "DESC"})
+		if err != nil {
+			t.Fatalf("EDGE.LIST VERBOSE failed: %v", err)
+		}
+		var got []string
+		for _, row := range resp.NestedArrayValue {
+			got = append(got, row.([]string)[0])
+		}
+		if expected := []string{"e2", "e1", "e3", "e4"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected VERBOSE rows %v, got %v", expected, got)
+		}
+
+		resp, err = handler.Handle("NODE.LIST", []string{"g", "FORMAT", "csv", "ORDERBY", "type"})
+		if err != nil {
+			t.Fatalf("NODE.LIST FORMAT csv failed: %v", err)
+		}
+		var rows []string
+		for _, line := range strings.Split(strings.TrimSpace(resp.StringValue), "\n") {
+			id, _, _ := strings.Cut(line, ",")
+			rows = append(rows, id)
+		}
+		if expected := []string{"id", "d", "b", "a", "c"}; !reflect.DeepEqual(rows, expected) {
+			t.Errorf("Expected CSV rows %v, got %v", expected, rows)
+		}
+
+		// A lone pair after the graph is still an attribute filter
+		if got := ids(t, 3, "NODE.FILTER", "g", "ORDERBY", "created"); len(got) != 0 {
+			t.Errorf("Expected ORDERBY created to filter on an attribute, got %v", got)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			command string
+			args    []string
+		}{
+			{"NODE.LIST", []string{"g", "ORDERBY"}},
+			{"NODE.LIST", []string{"g", "ORDERBY", "name"}},
+			{"NODE.LIST", []string{"g", "ORDERBY", "id", "ASC"}},
+			{"NODE.LIST", []string{"g", "ORDERBY", "id", "LABELS"}},
+			{"EDGE.LIST", []string{"g", "ORDERBY", "DESC"}},
+			{"EDGE.NEIGHBORS", []string{"g", "a", "ORDERBY", "weight"}},
+			{"EDGE.FILTER", []string{"g", "TYPE", "uses", "ORDERBY"}},
+		} {
+			if _, err := handler.Handle(tc.command, tc.args); err == nil {
+				t.Errorf("Expected %s %v to be rejected", tc.command, tc.args)
+			}
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		capped := redis.NewCommandHandler(engine, redis.WithSortLimit(3))
+		for _, tc := range []struct {
+			command string
+			args    []string
+		}{
+			{"NODE.LIST", []string{"g", "ORDERBY", "id"}},
+			{"NODE.FILTER", []string{"g", "tier", "1", "ORDERBY", "created"}},
+			{"EDGE.LIST", []string{"g", "ORDERBY", "created", "DESC"}},
+			{"EDGE.FILTER", []string{"g", "weight", "1", "ORDERBY", "type"}},
+		} {
+			_, err := capped.Handle(tc.command, tc.args)
+			if !errors.Is(err, commands.ErrGraphTooLarge) || !strings.Contains(err.Error(), "Drop ORDERBY") {
+				t.Errorf("Expected %s %v to be refused with TOOLARGE, got %v", tc.command, tc.args, err)
+			}
+			// The default order streams them
+			if _, err := capped.Handle(tc.command, tc.args[:slices.Index(tc.args, "ORDERBY")]); err != nil {
+				t.Errorf("Expected %s without ORDERBY to succeed, got %v", tc.command, err)
+			}
+		}
+
+		// Results up to the limit are sorted
+		if _, err := capped.Handle("EDGE.NEIGHBORS", []string{"g", "a", "ORDERBY", "created"}); err != nil {
+			t.Errorf("Expected 3 neighbors to be sorted, got %v", err)
+		}
+
+		unlimited := redis.NewCommandHandler(engine, redis.WithSortLimit(0))
+		if _, err := unlimited.Handle("NODE.LIST", []string{"g", "ORDERBY", "id"}); err != nil {
+			t.Errorf("Expected a limit of 0 not to be checked, got %v", err)
+		}
+	})
+}
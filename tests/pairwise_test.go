@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestPairwiseDistances tests the shortest path length matrix of
+// PairwiseDistances and ANALYSIS.PAIRWISE on the sample graph
+func TestPairwiseDistances(t *testing.T) {
+	te := setupTestAnalysisEngine(t)
+	defer te.cleanup()
+	te.createSampleGraph()
+
+	nodeIDs := []models.NodeID{"app", "auth", "logger", "queue", "db"}
+
+	t.Run("Forward", func(t *testing.T) {
+		distances, err := te.analyzer.PairwiseDistances(te.graphID, nodeIDs, &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: -1})
+		if err != nil {
+			t.Fatalf("PairwiseDistances failed: %v", err)
+		}
+		// Edges point from dependents to dependencies, so the matrix is not
+		// symmetric
+		expected := map[models.NodeID]map[models.NodeID]int{
+			"app":    {"app": 0, "auth": 1, "logger": 1, "queue": -1, "db": 2},
+			"auth":   {"app": -1, "auth": 0, "logger": 1, "queue": -1, "db": 1},
+			"logger": {"app": -1, "auth": -1, "logger": 0, "queue": -1, "db": -1},
+			"queue":  {"app": -1, "auth": -1, "logger": 1, "queue": 0, "db": -1},
+			"db":     {"app": -1, "auth": -1, "logger": -1, "queue": -1, "db": 0},
+		}
+		if !reflect.DeepEqual(distances, expected) {
+			t.Errorf("Expected %v, got %v", expected, distances)
+		}
+	})
+
+	t.Run("Both", func(t *testing.T) {
+		distances, err := te.analyzer.PairwiseDistances(te.graphID, []models.NodeID{"app", "queue", "db"}, &types.TraversalOptions{Direction: types.DirectionBoth, MaxDepth: -1})
+		if err != nil {
+			t.Fatalf("PairwiseDistances failed: %v", err)
+		}
+		expected := map[models.NodeID]map[models.NodeID]int{
+			"app":   {"app": 0, "queue": 2, "db": 2},
+			"queue": {"app": 2, "queue": 0, "db": 3},
+			"db":    {"app": 2, "queue": 3, "db": 0},
+		}
+		if !reflect.DeepEqual(distances, expected) {
+			t.Errorf("Expected %v, got %v", expected, distances)
+		}
+	})
+
+	t.Run("Missing Node", func(t *testing.T) {
+		_, err := te.analyzer.PairwiseDistances(te.graphID, []models.NodeID{"app", "ghost"}, nil)
+		if !errors.Is(err, storage.ErrNodeNotFound) {
+			t.Errorf("Expected ErrNodeNotFound, got %v", err)
+		}
+	})
+
+	handler := redis.NewCommandHandler(te.engine)
+	matrix := func(t *testing.T, args ...string) [][]string {
+		t.Helper()
+		resp, err := handler.Handle("ANALYSIS.PAIRWISE", args)
+		if err != nil {
+			t.Fatalf("ANALYSIS.PAIRWISE %v failed: %v", args, err)
+		}
+		var rows [][]string
+		for _, row := range resp.NestedArrayValue {
+			rows = append(rows, row.([]string))
+		}
+		return rows
+	}
+
+	t.Run("Command", func(t *testing.T) {
+		expected := [][]string{
+			{"app", "auth", "db"},
+			{"0", "1", "2"},
+			{"-1", "0", "1"},
+			{"-1", "-1", "0"},
+		}
+		if got := matrix(t, "test-graph", "app,auth,db"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+
+		expected = [][]string{
+			{"app", "auth", "db"},
+			{"0", "-1", "-1"},
+			{"1", "0", "-1"},
+			{"2", "1", "0"},
+		}
+		if got := matrix(t, "test-graph", "app,auth,db", "DIRECTION", "in"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected the transpose with DIRECTION in, got %v", got)
+		}
+
+		expected = [][]string{
+			{"app", "auth", "db"},
+			{"0", "1", "-1"},
+			{"-1", "0", "1"},
+			{"-1", "-1", "0"},
+		}
+		if got := matrix(t, "test-graph", "app,auth,db", "maxdepth", "1"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected paths longer than MAXDEPTH to be -1, got %v", got)
+		}
+
+		expected = [][]string{
+			{"app", "auth"},
+			{"0", "-1"},
+			{"-1", "0"},
+		}
+		if got := matrix(t, "test-graph", "app,auth", "EDGETYPES", "calls", "DIRECTION", "both"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected only edges of EDGETYPES to be followed, got %v", got)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"test-graph"},
+			{"test-graph", "app,ghost"},
+			{"test-graph", "app,,db"},
+			{"test-graph", "app,app"},
+			{"test-graph", "app,db", "MAXDEPTH", "-1"},
+			{"test-graph", "app,db", "DIRECTION", "up"},
+			{"test-graph", "app,db", "WEIGHTED"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.PAIRWISE", args); err == nil {
+				t.Errorf("Expected ANALYSIS.PAIRWISE %v to be rejected", args)
+			}
+		}
+
+		limits := commands.DefaultAnalysisLimits()
+		limits.PairwiseNodes = 2
+		capped := redis.NewCommandHandler(te.engine, redis.WithAnalysisLimits(limits))
+		if _, err := capped.Handle("ANALYSIS.PAIRWISE", []string{"test-graph", "app,auth,db"}); err == nil {
+			t.Error("Expected more nodes than the configured cap to be rejected")
+		}
+		if _, err := capped.Handle("ANALYSIS.PAIRWISE", []string{"test-graph", "app,auth"}); err != nil {
+			t.Errorf("Expected nodes up to the cap to be compared, got %v", err)
+		}
+	})
+}
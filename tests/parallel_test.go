@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestParallelEdges tests parallel edge statistics and ANALYSIS.PARALLEL
+func TestParallelEdges(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_parallel_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("parallel-test-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"api", "auth", "db"} {
+		if err := engine.CreateNode(graphID, &models.Node{ID: id, Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node %s: %v", id, err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		// api -> auth calls x3
+		{ID: "api-auth-1", FromNodeID: "api", ToNodeID: "auth", Type: "calls", Attributes: map[string]interface{}{"path": "/login"}},
+		{ID: "api-auth-2", FromNodeID: "api", ToNodeID: "auth", Type: "calls", Attributes: map[string]interface{}{"path": "/logout"}},
+		{ID: "api-auth-3", FromNodeID: "api", ToNodeID: "auth", Type: "calls", Attributes: map[string]interface{}{"path": "/refresh"}},
+		// auth -> db reads x2
+		{ID: "auth-db-1", FromNodeID: "auth", ToNodeID: "db", Type: "reads"},
+		{ID: "auth-db-2", FromNodeID: "auth", ToNodeID: "db", Type: "reads"},
+		// Same endpoints but a different type is not parallel
+		{ID: "auth-db-3", FromNodeID: "auth", ToNodeID: "db", Type: "writes"},
+		// Reverse direction is a separate group
+		{ID: "auth-api-1", FromNodeID: "auth", ToNodeID: "api", Type: "calls"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	t.Run("Stats", func(t *testing.T) {
+		analyzer := analysis.NewGraphAnalyzer(engine)
+		stats, err := analyzer.GetGraphStats(graphID, &types.TraversalOptions{Direction: types.DirectionForward})
+		if err != nil {
+			t.Fatalf("Failed to get graph stats: %v", err)
+		}
+		if stats.ParallelEdgeGroupCount != 2 {
+			t.Errorf("Expected 2 parallel edge groups, got %d", stats.ParallelEdgeGroupCount)
+		}
+		if stats.MaxEdgeMultiplicity != 3 {
+			t.Errorf("Expected max edge multiplicity 3, got %d", stats.MaxEdgeMultiplicity)
+		}
+	})
+
+	analysisCommands := commands.NewAnalysisCommands(engine)
+
+	t.Run("ListDefault", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("PARALLEL", []string{string(graphID)})
+		if err != nil {
+			t.Fatalf("ANALYSIS.PARALLEL failed: %v", err)
+		}
+		expected := []string{"api:auth:calls:3", "auth:db:reads:2"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("ListMin", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("PARALLEL", []string{string(graphID), "MIN", "3"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.PARALLEL failed: %v", err)
+		}
+		expected := []string{"api:auth:calls:3"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		resp, err = analysisCommands.Handle("PARALLEL", []string{string(graphID), "MIN", "4"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.PARALLEL failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 0 {
+			t.Errorf("Expected no groups, got %v", resp.ArrayValue)
+		}
+	})
+
+	t.Run("InvalidMin", func(t *testing.T) {
+		if _, err := analysisCommands.Handle("PARALLEL", []string{string(graphID), "MIN", "1"}); err == nil {
+			t.Error("Expected error for MIN below 2")
+		}
+		if _, err := analysisCommands.Handle("PARALLEL", []string{string(graphID), "MAX", "2"}); err == nil {
+			t.Error("Expected error for unknown option")
+		}
+	})
+}
@@ -0,0 +1,292 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestPassThroughNodeTypes tests contracting interface nodes between the
+// services and databases they connect
+func TestPassThroughNodeTypes(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_passthrough_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	handler := redis.NewCommandHandler(engine)
+
+	run := func(t *testing.T, args ...string) []string {
+		t.Helper()
+		resp, err := handler.Handle(args[0], args[1:])
+		if err != nil {
+			t.Fatalf("%s failed: %v", strings.Join(args, " "), err)
+		}
+		if resp.ArrayValue == nil {
+			return []string{resp.StringValue}
+		}
+		return resp.ArrayValue
+	}
+
+	// web and orders call checkout through checkout-api, and checkout
+	// reads ledger through ledger-api
+	graphID := models.GraphID("platform")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "platform"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "web", Type: "service"},
+		{ID: "orders", Type: "service"},
+		{ID: "checkout", Type: "service"},
+		{ID: "checkout-api", Type: "interface"},
+		{ID: "ledger-api", Type: "interface"},
+		{ID: "ledger", Type: "database"},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "web-calls", Type: "calls", FromNodeID: "web", ToNodeID: "checkout-api"},
+		{ID: "orders-calls", Type: "calls", FromNodeID: "orders", ToNodeID: "checkout-api"},
+		{ID: "checkout-serves", Type: "served_by", FromNodeID: "checkout-api", ToNodeID: "checkout"},
+		{ID: "checkout-reads", Type: "reads", FromNodeID: "checkout", ToNodeID: "ledger-api"},
+		{ID: "ledger-serves", Type: "served_by", FromNodeID: "ledger-api", ToNodeID: "ledger"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+	passThrough := []models.NodeType{"interface"}
+
+	t.Run("Shortest Path", func(t *testing.T) {
+		plain, err := analyzer.GetShortestPath(graphID, "checkout", "ledger", nil)
+		if err != nil || plain.Length != 2 || len(plain.Hops) != 0 {
+			t.Fatalf("Expected a path of length 2 without pass-through types, got %+v, %v", plain, err)
+		}
+
+		path, err := analyzer.GetShortestPath(graphID, "checkout", "ledger", &types.TraversalOptions{
+			Direction:            types.DirectionForward,
+			PassThroughNodeTypes: passThrough,
+		})
+		if err != nil {
+			t.Fatalf("GetShortestPath failed: %v", err)
+		}
+		if path.Length != 1 || !reflect.DeepEqual(path.Path, []models.NodeID{"checkout", "ledger"}) {
+			t.Errorf("Expected a path of length 1 from checkout to ledger, got %+v", path)
+		}
+		expected := []types.Hop{{
+			From:  "checkout",
+			To:    "ledger",
+			Edges: []models.EdgeID{"checkout-reads", "ledger-serves"},
+			Via:   []models.NodeID{"ledger-api"},
+		}}
+		if !reflect.DeepEqual(path.Hops, expected) {
+			t.Errorf("Expected one hop via ledger-api, got %+v", path.Hops)
+		}
+
+		// The target of a search is reached whatever its type
+		path, err = analyzer.GetShortestPath(graphID, "web", "ledger-api", &types.TraversalOptions{
+			Direction:            types.DirectionForward,
+			PassThroughNodeTypes: passThrough,
+		})
+		if err != nil || path.Length != 2 {
+			t.Errorf("Expected a path of 2 hops to ledger-api, got %+v, %v", path, err)
+		}
+	})
+
+	t.Run("Traversal", func(t *testing.T) {
+		result, err := analyzer.DepthFirstSearch(graphID, "web", &types.TraversalOptions{
+			Direction:            types.DirectionForward,
+			MaxDepth:             -1,
+			PassThroughNodeTypes: passThrough,
+		})
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		if !reflect.DeepEqual(result.Path, []models.NodeID{"web", "checkout", "ledger"}) || result.Distance != 2 {
+			t.Errorf("Expected web, checkout and ledger, got %v (distance %d)", result.Path, result.Distance)
+		}
+		if len(result.Hops) != 2 || result.Hops[0].Via[0] != "checkout-api" || len(result.Edges) != 4 {
+			t.Errorf("Expected two hops over four edges, got %+v, %d edges", result.Hops, len(result.Edges))
+		}
+
+		// Pass-through nodes are not counted toward depth
+		for _, tc := range []struct {
+			passThrough []models.NodeType
+			expected    []models.NodeID
+		}{
+			{nil, []models.NodeID{"web", "checkout-api"}},
+			{passThrough, []models.NodeID{"web", "checkout"}},
+		} {
+			result, err := analyzer.DepthFirstSearch(graphID, "web", &types.TraversalOptions{
+				Direction:            types.DirectionForward,
+				MaxDepth:             1,
+				PassThroughNodeTypes: tc.passThrough,
+			})
+			if err != nil || !reflect.DeepEqual(result.Path, tc.expected) {
+				t.Errorf("Expected %v within depth 1, got %+v, %v", tc.expected, result, err)
+			}
+		}
+
+		// Backward from checkout, both callers are one hop away
+		var callers []models.NodeID
+		err = analyzer.WalkBFS(context.Background(), graphID, "checkout", &types.TraversalOptions{
+			Direction:            types.DirectionBackward,
+			MaxDepth:             -1,
+			PassThroughNodeTypes: passThrough,
+		}, func(node *models.Node, depth int, via *models.Edge) error {
+			if depth == 1 {
+				callers = append(callers, node.ID)
+			}
+			return nil
+		})
+		if err != nil || !reflect.DeepEqual(callers, []models.NodeID{"orders", "web"}) {
+			t.Errorf("Expected orders and web at depth 1, got %v, %v", callers, err)
+		}
+	})
+
+	t.Run("Commands", func(t *testing.T) {
+		nodes := run(t, "ANALYSIS.TRAVERSE", "platform", "web", "FORMAT", "simple", "PASSTHROUGH", "interface")
+		if !reflect.DeepEqual(nodes, []string{"web:service", "checkout:service", "ledger:database"}) {
+			t.Errorf("Expected the interfaces left out, got %v", nodes)
+		}
+
+		paths := run(t, "ANALYSIS.TRAVERSE", "platform", "web", "PASSTHROUGH", "interface")
+		expected := []string{"web:service->web-calls:calls->(checkout-api)->checkout-serves:served_by->checkout:service" +
+			"->checkout-reads:reads->(ledger-api)->ledger-serves:served_by->ledger:database"}
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("Expected the hops written through the interfaces, got %v", paths)
+		}
+
+		path := run(t, "ANALYSIS.SHORTESTPATH", "platform", "checkout", "ledger", "PASSTHROUGH", "interface")
+		if !reflect.DeepEqual(path, []string{"checkout:service->checkout-reads:reads->(ledger-api)->ledger-serves:served_by->ledger:database"}) {
+			t.Errorf("Expected one detailed path via ledger-api, got %v", path)
+		}
+
+		encoded := run(t, "ANALYSIS.SHORTESTPATH", "platform", "checkout", "ledger", "FORMAT", "json", "PASSTHROUGH", "interface")
+		var result types.PathResult
+		if err := json.Unmarshal([]byte(encoded[0]), &result); err != nil {
+			t.Fatalf("Expected a JSON path, got %v: %v", encoded, err)
+		}
+		if result.Length != 1 || len(result.Hops) != 1 || !reflect.DeepEqual(result.Hops[0].Via, []models.NodeID{"ledger-api"}) {
+			t.Errorf("Expected one hop via ledger-api, got %+v", result)
+		}
+
+		encoded = run(t, "ANALYSIS.SHORTESTPATH", "platform", "checkout", "ledger", "FORMAT", "json")
+		result = types.PathResult{}
+		if err := json.Unmarshal([]byte(encoded[0]), &result); err != nil || result.Length != 2 || result.Hops != nil {
+			t.Errorf("Expected a path of length 2 without hops, got %+v, %v", result, err)
+		}
+
+		for _, args := range [][]string{
+			{"platform", "web", "PASSTHROUGH"},
+			{"platform", "web", "PASSTHROUGH", "interface,"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.TRAVERSE", args); err == nil {
+				t.Errorf("Expected ANALYSIS.TRAVERSE %v to fail", args)
+			}
+		}
+	})
+
+	t.Run("Centrality", func(t *testing.T) {
+		scores, err := analyzer.CalculateContractedDegreeCentrality(graphID, nil, types.DirectionBoth, passThrough)
+		if err != nil {
+			t.Fatalf("CalculateContractedDegreeCentrality failed: %v", err)
+		}
+		expected := map[models.NodeID]int{"web": 1, "orders": 1, "checkout": 3, "ledger": 1}
+		if !reflect.DeepEqual(scores, expected) {
+			t.Errorf("Expected %v, got %v", expected, scores)
+		}
+
+		ranked := run(t, "ANALYSIS.CENTRALITY", "platform", "degree", "TOP", "1", "PASSTHROUGH", "interface")
+		if !reflect.DeepEqual(ranked, []string{"checkout", "3"}) {
+			t.Errorf("Expected checkout first with 3 hops, got %v", ranked)
+		}
+		if _, err := handler.Handle("ANALYSIS.CENTRALITY", []string{"platform", "pagerank", "PASSTHROUGH", "interface"}); err == nil {
+			t.Error("Expected PASSTHROUGH to be rejected for pagerank")
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		stats, err := analyzer.GetGraphStats(graphID, &types.TraversalOptions{PassThroughNodeTypes: passThrough})
+		if err != nil {
+			t.Fatalf("GetGraphStats failed: %v", err)
+		}
+		if stats.NodeCount != 4 || stats.EdgeCount != 3 || stats.NodeTypeCount["interface"] != 0 {
+			t.Errorf("Expected 4 nodes and 3 hops, got %+v", stats)
+		}
+		if stats.RootNodeCount != 2 || stats.LeafNodeCount != 1 || stats.MaxDepth != 2 || stats.ConnectedComponents != 1 || stats.HasCycles {
+			t.Errorf("Expected 2 roots, 1 leaf, depth 2 and one component, got %+v", stats)
+		}
+	})
+
+	t.Run("Loops", func(t *testing.T) {
+		// Interfaces bridging each other in both directions end no hop
+		// and cannot loop
+		for _, edge := range []*models.Edge{
+			{ID: "bridge-out", Type: "bridges", FromNodeID: "ledger-api", ToNodeID: "checkout-api"},
+			{ID: "bridge-back", Type: "bridges", FromNodeID: "checkout-api", ToNodeID: "ledger-api"},
+		} {
+			if err := engine.CreateEdge(graphID, edge); err != nil {
+				t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+			}
+		}
+		result, err := analyzer.DepthFirstSearch(graphID, "web", &types.TraversalOptions{
+			Direction:            types.DirectionForward,
+			MaxDepth:             -1,
+			PassThroughNodeTypes: passThrough,
+		})
+		// bridge-back sorts before checkout-serves, so ledger is reached
+		// first, across both interfaces
+		if err != nil || !reflect.DeepEqual(result.Path, []models.NodeID{"web", "ledger", "checkout"}) {
+			t.Errorf("Expected web, ledger and checkout, got %+v, %v", result, err)
+		}
+		if err == nil && !reflect.DeepEqual(result.Hops[0].Via, []models.NodeID{"checkout-api", "ledger-api"}) {
+			t.Errorf("Expected ledger to be reached via both interfaces, got %+v", result.Hops[0])
+		}
+		stats, err := analyzer.GetGraphStats(graphID, &types.TraversalOptions{PassThroughNodeTypes: passThrough})
+		if err != nil || !stats.HasCycles {
+			t.Errorf("Expected checkout to reach itself through the bridge, got %+v, %v", stats, err)
+		}
+	})
+
+	t.Run("DeepStats", func(t *testing.T) {
+		// A long chain of services joined by interfaces, and a ladder of
+		// diamonds with as many paths as 2 to the number of rungs
+		const chain, rungs = 20000, 60
+		var edges []string
+		for i := 0; i < chain-1; i++ {
+			edges = append(edges, fmt.Sprintf("s%d:service -[calls]-> s%d-api:interface -[serves]-> s%d:service", i, i+1, i+1))
+		}
+		for i := 0; i < rungs; i++ {
+			edges = append(edges,
+				fmt.Sprintf("d%d:service -[calls]-> x%d:service -[calls]-> d%d:service", i, i, i+1),
+				fmt.Sprintf("d%d -[calls]-> y%d:service -[calls]-> d%d", i, i, i+1))
+		}
+		deep, deepID := loadFixture(t, strings.Join(edges, ", "))
+		stats, err := deep.GetGraphStats(deepID, &types.TraversalOptions{PassThroughNodeTypes: passThrough})
+		if err != nil {
+			t.Fatalf("GetGraphStats failed: %v", err)
+		}
+		if stats.MaxDepth != chain-1 || stats.HasCycles || stats.RootNodeCount != 2 {
+			t.Errorf("Expected depth %d, no cycles and 2 roots, got %+v", chain-1, stats)
+		}
+	})
+}
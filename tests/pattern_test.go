@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestGraphPattern tests creating chains and stars with GRAPH.PATTERN,
+// running them again, and sharing nodes between them
+func TestGraphPattern(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_pattern_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("fixtures")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "fixtures"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	pattern := func(t *testing.T, args ...string) []string {
+		t.Helper()
+		resp, err := handler.Handle("GRAPH.PATTERN", append([]string{string(graphID)}, args...))
+		if err != nil {
+			t.Fatalf("GRAPH.PATTERN %v failed: %v", args, err)
+		}
+		return resp.ArrayValue
+	}
+	counts := func(nodesCreated, nodesReused, edgesCreated, edgesReused string) []string {
+		return []string{"nodes_created", nodesCreated, "nodes_reused", nodesReused, "edges_created", edgesCreated, "edges_reused", edgesReused}
+	}
+	// traverse returns the paths ANALYSIS.TRAVERSE finds from a node, sorted
+	traverse := func(t *testing.T, from string) []string {
+		t.Helper()
+		resp, err := handler.Handle("ANALYSIS.TRAVERSE", []string{string(graphID), from})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		paths := append([]string{}, resp.ArrayValue...)
+		sort.Strings(paths)
+		return paths
+	}
+
+	t.Run("Chain", func(t *testing.T) {
+		if got, expected := pattern(t, "CHAIN", "service", "calls", "a", "b", "c", "d"), counts("4", "0", "3", "0"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+		edge, err := engine.GetEdge(graphID, "b-c-calls")
+		if err != nil || edge.FromNodeID != "b" || edge.ToNodeID != "c" || edge.Type != "calls" {
+			t.Errorf("Expected the edge b-c-calls from b to c, got %+v, %v", edge, err)
+		}
+		if expected := []string{"a:service->a-b-calls:calls->b:service->b-c-calls:calls->c:service->c-d-calls:calls->d:service"}; !reflect.DeepEqual(traverse(t, "a"), expected) {
+			t.Errorf("Expected the chain %v, got %v", expected, traverse(t, "a"))
+		}
+
+		// Running it again creates nothing and writes nothing
+		generation := engine.Generation(graphID)
+		if got, expected := pattern(t, "chain", "service", "calls", "a", "b", "c", "d"), counts("0", "4", "0", "3"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v again, got %v", expected, got)
+		}
+		if got := engine.Generation(graphID); got != generation {
+			t.Errorf("Expected the generation to stay %d, got %d", generation, got)
+		}
+		if count, err := engine.CountEdges(graphID); err != nil || count != 3 {
+			t.Errorf("Expected 3 edges, got %d, %v", count, err)
+		}
+	})
+
+	t.Run("Star", func(t *testing.T) {
+		// The hub is the end of the chain
+		if got, expected := pattern(t, "STAR", "d", "service", "reads", "database", "x", "y"), counts("2", "1", "2", "0"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+		expected := []string{
+			"c:service->c-d-calls:calls->d:service->d-x-reads:reads->x:database",
+			"c:service->c-d-calls:calls->d:service->d-y-reads:reads->y:database",
+		}
+		if got := traverse(t, "c"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+
+		// A chain through the same edge twice names it once
+		if got, expected := pattern(t, "CHAIN", "service", "calls", "a", "b", "a", "b"), counts("0", "2", "1", "1"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		count, err := engine.CountNodes(graphID)
+		if err != nil {
+			t.Fatalf("CountNodes failed: %v", err)
+		}
+		if err := engine.CreateEdge(graphID, &models.Edge{ID: "x-y-reads", FromNodeID: "y", ToNodeID: "x", Type: "reads"}); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+		for _, args := range [][]string{
+			// d exists as a service
+			{"STAR", "d", "database", "reads", "database", "new-1"},
+			{"CHAIN", "database", "reads", "new-1", "d"},
+			// x-y-reads exists the other way round
+			{"CHAIN", "database", "reads", "new-1", "x", "y"},
+			// One ID given two types
+			{"STAR", "new-1", "service", "calls", "database", "new-1"},
+			{"CHAIN", "service", "calls", "new-1"},
+			{"STAR", "new-1", "service", "calls", "database"},
+			{"RING", "service", "calls", "new-1", "new-2"},
+			{"CHAIN", "service", "calls", "new\x001", "new-2"},
+		} {
+			if _, err := handler.Handle("GRAPH.PATTERN", append([]string{string(graphID)}, args...)); err == nil {
+				t.Errorf("Expected GRAPH.PATTERN %v to fail", args)
+			}
+		}
+		if got, err := engine.CountNodes(graphID); err != nil || got != count {
+			t.Errorf("Expected failed patterns to create no nodes, got %d, %v", got-count, err)
+		}
+		if _, err := handler.Handle("GRAPH.PATTERN", []string{"missing", "CHAIN", "service", "calls", "a", "b"}); !errors.Is(err, storage.ErrGraphNotFound) {
+			t.Errorf("Expected a missing graph to fail, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestGraphProtection tests that protected graphs are only deleted with
+// CONFIRM, that attempts are logged with the client, and GRAPH.DELETE DRYRUN
+func TestGraphProtection(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_protect_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	capture := newCaptureHandler()
+	handler := redis.NewCommandHandler(engine, redis.WithLogger(slog.New(capture)))
+	session := &commands.Session{Client: "10.0.0.5:4242"}
+
+	run := func(t *testing.T, args ...string) ([]string, error) {
+		t.Helper()
+		resp, err := handler.HandleSession(session, args[0], args[1:])
+		if err != nil {
+			return nil, err
+		}
+		if resp.Type == protocol.ResponseTypeInt {
+			return []string{strconv.FormatInt(resp.IntValue, 10)}, nil
+		}
+		return resp.ArrayValue, nil
+	}
+	mustRun := func(t *testing.T, args ...string) []string {
+		t.Helper()
+		reply, err := run(t, args...)
+		if err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+		return reply
+	}
+
+	for _, args := range [][]string{
+		{"GRAPH.CREATE", "prod"},
+		{"NODE.CREATE", "prod", "a", "svc", `{"tier":1}`},
+		{"NODE.CREATE", "prod", "b", "svc", "{}"},
+		{"NODE.CREATE", "prod", "c", "db", "{}"},
+		{"EDGE.CREATE", "prod", "e1", "a", "b", "calls", "{}"},
+		{"EDGE.CREATE", "prod", "e2", "b", "c", "uses", "{}"},
+		{"GRAPH.CREATE", "dev"},
+	} {
+		mustRun(t, args...)
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		audit, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		var keys int64
+		for _, count := range audit.Graphs["prod"] {
+			keys += count
+		}
+
+		got := mustRun(t, "GRAPH.DELETE", "prod", "DRYRUN")
+		expected := []string{"graph", "prod", "nodes", "3", "edges", "2", "keys", strconv.FormatInt(keys, 10)}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+		if count, err := engine.CountNodes("prod"); err != nil || count != 3 {
+			t.Errorf("Expected DRYRUN to leave 3 nodes, got %d, %v", count, err)
+		}
+
+		if _, err := run(t, "GRAPH.DELETE", "missing", "DRYRUN"); !errors.Is(err, storage.ErrGraphNotFound) {
+			t.Errorf("Expected DRYRUN of a missing graph to fail, got %v", err)
+		}
+		if spec, _ := handler.Registry().Lookup("GRAPH.DELETE"); !spec.IsReadOnly([]string{"prod", "DRYRUN"}) || spec.IsReadOnly([]string{"prod"}) {
+			t.Errorf("Expected only GRAPH.DELETE DRYRUN to be read-only")
+		}
+	})
+
+	t.Run("Protect", func(t *testing.T) {
+		if got := mustRun(t, "GRAPH.PROTECT", "prod"); got[0] != "0" {
+			t.Errorf("Expected prod to start unprotected, got %v", got)
+		}
+		mustRun(t, "GRAPH.PROTECT", "prod", "ON")
+		if got := mustRun(t, "GRAPH.PROTECT", "prod"); got[0] != "1" {
+			t.Errorf("Expected prod to be protected, got %v", got)
+		}
+
+		// Creating it again keeps protection
+		mustRun(t, "GRAPH.CREATE", "prod")
+		if got := mustRun(t, "GRAPH.PROTECT", "prod"); got[0] != "1" {
+			t.Errorf("Expected GRAPH.CREATE to keep protection, got %v", got)
+		}
+
+		for _, args := range [][]string{
+			{"GRAPH.PROTECT"},
+			{"GRAPH.PROTECT", "prod", "maybe"},
+			{"GRAPH.PROTECT", "prod", "on", "off"},
+			{"GRAPH.PROTECT", "missing", "on"},
+		} {
+			if _, err := run(t, args...); err == nil {
+				t.Errorf("Expected %v to be rejected", args)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		for _, tc := range []struct {
+			args    []string
+			confirm string
+		}{
+			{[]string{"GRAPH.DELETE", "prod"}, ""},
+			{[]string{"GRAPH.DELETE", "prod", "CONFIRM", "dev"}, "dev"},
+			{[]string{"GRAPH.DELETE", "prod", "CONFIRM", "PROD"}, "PROD"},
+		} {
+			*capture.records = nil
+			if _, err := run(t, tc.args...); !errors.Is(err, commands.ErrGraphProtected) {
+				t.Errorf("Expected %v to be refused with PROTECTED, got %v", tc.args, err)
+			}
+			rec, ok := capture.find("Refused destructive command on protected graph", slog.LevelWarn)
+			if !ok {
+				t.Fatalf("Expected %v to be logged at warn", tc.args)
+			}
+			if rec.attrs["client"].String() != "10.0.0.5:4242" || rec.attrs["command"].String() != "GRAPH.DELETE" {
+				t.Errorf("Expected the client and command to be logged, got %v", rec.attrs)
+			}
+			if tc.confirm != "" && rec.attrs["confirm"].String() != tc.confirm {
+				t.Errorf("Expected the wrong name %q to be logged, got %v", tc.confirm, rec.attrs["confirm"])
+			}
+		}
+		if _, err := engine.GetGraph("prod"); err != nil {
+			t.Fatalf("Expected refused deletes to leave the graph, got %v", err)
+		}
+
+		// CONFIRM is ignored for unprotected graphs
+		mustRun(t, "GRAPH.DELETE", "dev", "CONFIRM", "dev")
+		if _, err := run(t, "GRAPH.DELETE", "prod", "CONFIRM"); err == nil || errors.Is(err, commands.ErrGraphProtected) {
+			t.Errorf("Expected CONFIRM without a name to be a syntax error, got %v", err)
+		}
+
+		*capture.records = nil
+		mustRun(t, "GRAPH.DELETE", "prod", "CONFIRM", "prod")
+		if rec, ok := capture.find("Confirmed destructive command on protected graph", slog.LevelWarn); !ok || rec.attrs["client"].String() != "10.0.0.5:4242" {
+			t.Errorf("Expected the confirmed delete to be logged with the client")
+		}
+		if _, err := engine.GetGraph("prod"); !errors.Is(err, storage.ErrGraphNotFound) {
+			t.Errorf("Expected prod to be deleted, got %v", err)
+		}
+	})
+
+	t.Run("Unprotect", func(t *testing.T) {
+		if err := engine.CreateGraph(&models.Graph{ID: "staging", Name: "staging", Protected: true}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		*capture.records = nil
+		mustRun(t, "GRAPH.PROTECT", "staging", "off")
+		if rec, ok := capture.find("Graph protection turned off", slog.LevelWarn); !ok || rec.attrs["client"].String() != "10.0.0.5:4242" {
+			t.Errorf("Expected turning protection off to be logged with the client")
+		}
+		mustRun(t, "GRAPH.DELETE", "staging")
+	})
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestProtocolVersion tests that multi-item replies carry no leading count
+// unless COUNT asks for one, EDGE.LIST VERBOSE, and SYSTEM.PROTOVERSION
+func TestProtocolVersion(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_protoversion_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("services")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "services"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "a", Type: "service"},
+		{ID: "b", Type: "service"},
+		{ID: "c", Type: "database"},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	createdAt := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, edge := range []*models.Edge{
+		{ID: "a-b", Type: "calls", FromNodeID: "a", ToNodeID: "b", Attributes: models.Attributes{"weight": 1}, CreatedAt: createdAt},
+		{ID: "b-a", Type: "calls", FromNodeID: "b", ToNodeID: "a"},
+		{ID: "b-c", Type: "reads", FromNodeID: "b", ToNodeID: "c", ExpiresAt: &expiresAt},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	run := func(t *testing.T, args ...string) []string {
+		t.Helper()
+		resp, err := handler.Handle(args[0], args[1:])
+		if err != nil {
+			t.Fatalf("%s failed: %v", strings.Join(args, " "), err)
+		}
+		return resp.ArrayValue
+	}
+
+	t.Run("Count", func(t *testing.T) {
+		for _, tc := range []struct {
+			args     []string
+			expected []string
+		}{
+			{[]string{"EDGE.NEIGHBORS", "services", "c"}, []string{"b:service<-b-c:reads"}},
+			{[]string{"EDGE.NEIGHBORS", "services", "c", "FORMAT", "simple"}, []string{"b:service"}},
+			{[]string{"ANALYSIS.SHORTESTPATH", "services", "a", "c"}, []string{"a:service->a-b:calls->b:service->b-c:reads->c:database"}},
+			{[]string{"ANALYSIS.SHORTESTPATH", "services", "a", "c", "FORMAT", "simple"}, []string{"a:service", "b:service", "c:database"}},
+			{[]string{"ANALYSIS.CYCLES", "services", "FORMAT", "simple"}, []string{"a:service", "b:service"}},
+			{[]string{"ANALYSIS.TRAVERSE", "services", "b", "EDGETYPES", "reads"}, []string{"b:service->b-c:reads->c:database"}},
+		} {
+			if values := run(t, tc.args...); !reflect.DeepEqual(values, tc.expected) {
+				t.Errorf("Expected %s to reply %v, got %v", strings.Join(tc.args, " "), tc.expected, values)
+			}
+			counted := append([]string{strconv.Itoa(len(tc.expected))}, tc.expected...)
+			if values := run(t, append(tc.args, "count")...); !reflect.DeepEqual(values, counted) {
+				t.Errorf("Expected %s COUNT to reply %v, got %v", strings.Join(tc.args, " "), counted, values)
+			}
+		}
+	})
+
+	t.Run("Count Excludes Fanout", func(t *testing.T) {
+		values := run(t, "ANALYSIS.TRAVERSE", "services", "b", "FORMAT", "simple", "MAXFANOUT", "1", "COUNT")
+		expected := []string{"2", "b:service", "a:service", "fanout_limited", "b"}
+		if !reflect.DeepEqual(values, expected) {
+			t.Errorf("Expected %v, got %v", expected, values)
+		}
+	})
+
+	t.Run("Count With JSON", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"services", "a", "FORMAT", "json", "COUNT"},
+			{"services", "a", "c", "FORMAT", "json", "COUNT"},
+		} {
+			command := "ANALYSIS.TRAVERSE"
+			if len(args) == 6 {
+				command = "ANALYSIS.SHORTESTPATH"
+			}
+			if _, err := handler.Handle(command, args); err == nil || !strings.Contains(err.Error(), "COUNT cannot be combined") {
+				t.Errorf("Expected %s to reject COUNT with FORMAT json, got %v", command, err)
+			}
+		}
+	})
+
+	t.Run("Edge List Verbose", func(t *testing.T) {
+		if values := run(t, "EDGE.LIST", "services"); len(values) != 3 {
+			t.Errorf("Expected 3 edges, got %v", values)
+		}
+
+		resp, err := handler.Handle("EDGE.LIST", []string{"services", "verbose"})
+		if err != nil {
+			t.Fatalf("EDGE.LIST VERBOSE failed: %v", err)
+		}
+		if len(resp.NestedArrayValue) != 3 {
+			t.Fatalf("Expected 3 edges, got %v", resp.NestedArrayValue)
+		}
+		edges := map[string][]string{}
+		for _, value := range resp.NestedArrayValue {
+			fields, ok := value.([]string)
+			if !ok || len(fields) != 7 {
+				t.Fatalf("Expected arrays of 7 fields, got %#v", value)
+			}
+			edges[fields[0]] = fields
+		}
+		expected := []string{"a-b", "calls", "a", "b", `{"weight":1}`, "2026-10-01T09:30:00Z", ""}
+		if !reflect.DeepEqual(edges["a-b"], expected) {
+			t.Errorf("Expected %v, got %v", expected, edges["a-b"])
+		}
+		// Times that were never set are empty
+		if bc := edges["b-c"]; bc[5] != "" || bc[6] != "2030-01-02T03:04:05Z" {
+			t.Errorf("Expected b-c to expire at 2030-01-02T03:04:05Z with no creation time, got %v", bc)
+		}
+
+		if _, err := handler.Handle("EDGE.LIST", []string{"services", "VERBOSE", "FORMAT", "csv"}); err == nil {
+			t.Error("Expected VERBOSE with FORMAT to be rejected")
+		}
+	})
+
+	t.Run("Protocol Version", func(t *testing.T) {
+		values := run(t, "SYSTEM.PROTOVERSION")
+		if len(values) != 6 || values[0] != "version" || values[1] != "2" || values[3] != "never" {
+			t.Errorf("Expected version 2 without leading counts, got %v", values)
+		}
+		if !strings.Contains(values[5], "EDGE.NEIGHBORS") || !strings.Contains(values[5], "COUNT") {
+			t.Errorf("Expected the breaking change to name the commands and COUNT, got %q", values[5])
+		}
+		if _, err := handler.Handle("SYSTEM.PROTOVERSION", []string{"extra"}); err == nil {
+			t.Error("Expected arguments to be rejected")
+		}
+	})
+}
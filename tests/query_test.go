@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestNamedQueries tests QUERY.SAVE, QUERY.RUN, QUERY.LIST and QUERY.DELETE
+func TestNamedQueries(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_query_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("query-test-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	odd := models.NodeID(`cache "primary" node`)
+	for _, node := range []*models.Node{
+		{ID: "a", Type: "service"},
+		{ID: "b", Type: "service"},
+		{ID: "c", Type: "database"},
+		{ID: odd, Type: "cache"},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "a-b", FromNodeID: "a", ToNodeID: "b", Type: "calls"},
+		{ID: "b-c", FromNodeID: "b", ToNodeID: "c", Type: "reads"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	handler := redis.NewCommandHandler(engine, redis.WithAdminPassword("secret"))
+	session := &commands.Session{Client: "127.0.0.1:5000"}
+	admin := &commands.Session{Client: "127.0.0.1:5001"}
+
+	t.Run("SaveAndRunTraverse", func(t *testing.T) {
+		_, err := handler.HandleSession(session, "QUERY.SAVE", []string{"downstream", "ANALYSIS.TRAVERSE $1 $2 DIRECTION out"})
+		if err != nil {
+			t.Fatalf("QUERY.SAVE failed: %v", err)
+		}
+
+		resp, err := handler.HandleSession(session, "QUERY.RUN", []string{"downstream", string(graphID), "a"})
+		if err != nil {
+			t.Fatalf("QUERY.RUN failed: %v", err)
+		}
+		expected := []string{"a:service->a-b:calls->b:service->b-c:reads->c:database"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		resp, err = handler.HandleSession(session, "QUERY.RUN", []string{"downstream", string(graphID), "b"})
+		if err != nil {
+			t.Fatalf("QUERY.RUN failed: %v", err)
+		}
+		expected = []string{"b:service->b-c:reads->c:database"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("ArgumentIsNotSplit", func(t *testing.T) {
+		_, err := handler.HandleSession(session, "QUERY.SAVE", []string{"node", `NODE.GET "query-test-graph" $1`})
+		if err != nil {
+			t.Fatalf("QUERY.SAVE failed: %v", err)
+		}
+
+		// Spaces and quotes in the argument must reach NODE.GET as one argument.
+		resp, err := handler.HandleSession(session, "QUERY.RUN", []string{"node", string(odd)})
+		if err != nil {
+			t.Fatalf("QUERY.RUN failed: %v", err)
+		}
+		if len(resp.ArrayValue) == 0 || resp.ArrayValue[0] != string(odd) {
+			t.Errorf("Expected node %q, got %v", odd, resp.ArrayValue)
+		}
+	})
+
+	t.Run("WrongArgumentCount", func(t *testing.T) {
+		if _, err := handler.HandleSession(session, "QUERY.RUN", []string{"downstream", string(graphID)}); err == nil {
+			t.Error("Expected error when a placeholder has no argument")
+		}
+	})
+
+	t.Run("MutatingRequiresAdmin", func(t *testing.T) {
+		args := []string{"drop", "GRAPH.DELETE $1"}
+		if _, err := handler.HandleSession(session, "QUERY.SAVE", args); err == nil {
+			t.Fatal("Expected non-admin save of a mutating command to fail")
+		}
+
+		if _, err := handler.HandleSession(admin, "AUTH", []string{"wrong"}); err == nil {
+			t.Error("Expected AUTH with a wrong password to fail")
+		}
+		if _, err := handler.HandleSession(admin, "AUTH", []string{"secret"}); err != nil {
+			t.Fatalf("AUTH failed: %v", err)
+		}
+		if _, err := handler.HandleSession(admin, "QUERY.SAVE", args); err != nil {
+			t.Errorf("Expected admin save of a mutating command to succeed: %v", err)
+		}
+
+		query, err := engine.GetQuery("drop")
+		if err != nil {
+			t.Fatalf("Failed to get saved query: %v", err)
+		}
+		if query.CreatedBy != admin.Client || query.CreatedAt.IsZero() {
+			t.Errorf("Expected creator %s and a creation time, got %s at %v", admin.Client, query.CreatedBy, query.CreatedAt)
+		}
+	})
+
+	t.Run("NonAdminCannotRunOrReplace", func(t *testing.T) {
+		// "drop" was saved by the admin and deletes a graph
+		if _, err := handler.HandleSession(session, "QUERY.RUN", []string{"drop", string(graphID)}); err == nil {
+			t.Error("Expected non-admin run of a mutating query to fail")
+		}
+		if _, err := engine.GetGraph(graphID); err != nil {
+			t.Fatalf("Expected the graph to survive, got %v", err)
+		}
+		if _, err := handler.HandleSession(session, "QUERY.SAVE", []string{"drop", "GRAPH.GET $1"}); err == nil {
+			t.Error("Expected non-admin overwrite of another connection's query to fail")
+		}
+		if _, err := handler.HandleSession(session, "QUERY.DELETE", []string{"drop"}); err == nil {
+			t.Error("Expected non-admin delete of another connection's query to fail")
+		}
+		if query, err := engine.GetQuery("drop"); err != nil || query.Template != "GRAPH.DELETE $1" {
+			t.Errorf("Expected the admin's query to be unchanged, got %v, %v", query, err)
+		}
+
+		other := &commands.Session{Client: "127.0.0.1:5002"}
+		if _, err := handler.HandleSession(other, "QUERY.DELETE", []string{"downstream"}); err == nil {
+			t.Error("Expected delete of another connection's query to fail")
+		}
+		// The connection that saved a query may replace it
+		if _, err := handler.HandleSession(session, "QUERY.SAVE", []string{"downstream", "ANALYSIS.TRAVERSE $1 $2 DIRECTION out"}); err != nil {
+			t.Errorf("Expected the creator to replace its query: %v", err)
+		}
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		resp, err := handler.HandleSession(session, "QUERY.LIST", nil)
+		if err != nil {
+			t.Fatalf("QUERY.LIST failed: %v", err)
+		}
+		expected := []string{
+			"downstream", "ANALYSIS.TRAVERSE $1 $2 DIRECTION out",
+			"drop", "GRAPH.DELETE $1",
+			"node", `NODE.GET "query-test-graph" $1`,
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		if _, err := handler.HandleSession(admin, "QUERY.DELETE", []string{"drop"}); err != nil {
+			t.Fatalf("QUERY.DELETE failed: %v", err)
+		}
+		if _, err := handler.HandleSession(admin, "QUERY.RUN", []string{"drop", string(graphID)}); err == nil {
+			t.Error("Expected running a deleted query to fail")
+		}
+		if _, err := handler.HandleSession(admin, "QUERY.DELETE", []string{"drop"}); err == nil {
+			t.Error("Expected deleting a missing query to fail")
+		}
+	})
+}
@@ -0,0 +1,296 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestReindex tests backfilling the attribute index of a graph written
+// before the index existed, across an engine restart
+func TestReindex(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_reindex_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	// Seed a graph the way a build without the attribute index wrote it:
+	// nodes and their type index, but no attribute entries or reindex job
+	const nodeCount = 50000
+	graphID := models.GraphID("legacy")
+	db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open raw database: %v", err)
+	}
+	batch := db.NewWriteBatch()
+	graphValue, _ := (&models.Graph{ID: graphID, Name: string(graphID)}).ToJSON()
+	if err := batch.Set(utils.EncodeGraphKey(graphID), graphValue); err != nil {
+		t.Fatalf("Failed to seed graph: %v", err)
+	}
+	for i := 0; i < nodeCount; i++ {
+		node := &models.Node{
+			ID:         models.NodeID(fmt.Sprintf("node-%05d", i)),
+			Type:       "item",
+			Attributes: models.Attributes{"group": i % 10, "name": fmt.Sprintf("item %d", i)},
+		}
+		value, err := node.ToJSON()
+		if err != nil {
+			t.Fatalf("Failed to serialize node: %v", err)
+		}
+		if err := batch.Set(utils.EncodeNodeKey(graphID, node.ID), value); err != nil {
+			t.Fatalf("Failed to seed node: %v", err)
+		}
+		if err := batch.Set(utils.EncodeNodeTypeIndexKey(graphID, node.Type, node.ID), []byte(node.ID)); err != nil {
+			t.Fatalf("Failed to seed type index: %v", err)
+		}
+	}
+	if err := batch.Flush(); err != nil {
+		t.Fatalf("Failed to seed nodes: %v", err)
+	}
+	db.Close()
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { engine.Close() }()
+	handler := redis.NewCommandHandler(engine)
+
+	status := func(t *testing.T, graphID models.GraphID) *models.ReindexJob {
+		t.Helper()
+		job, err := engine.ReindexStatus(graphID, "attributes")
+		if err != nil {
+			t.Fatalf("ReindexStatus failed: %v", err)
+		}
+		return job
+	}
+	waitFor := func(t *testing.T, graphID models.GraphID, done func(job *models.ReindexJob) bool) *models.ReindexJob {
+		t.Helper()
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			job := status(t, graphID)
+			if done(job) {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for the reindex, last status %+v", job)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	groupCount := func(t *testing.T, group int) int {
+		t.Helper()
+		nodes, err := engine.FindNodesByAttribute(graphID, "group", group)
+		if err != nil {
+			t.Fatalf("FindNodesByAttribute failed: %v", err)
+		}
+		return len(nodes)
+	}
+
+	t.Run("Backfill", func(t *testing.T) {
+		if job := status(t, graphID); job != nil {
+			t.Fatalf("Expected no reindex job for a legacy graph, got %+v", job)
+		}
+		// Without the index, lookups scan
+		if count := groupCount(t, 3); count != nodeCount/10 {
+			t.Errorf("Expected %d nodes in group 3 before the reindex, got %d", nodeCount/10, count)
+		}
+
+		if _, err := handler.Handle("SYSTEM.REINDEX", []string{"attributes", string(graphID), "START", "RATE", "10000"}); err != nil {
+			t.Fatalf("SYSTEM.REINDEX START failed: %v", err)
+		}
+		if _, err := handler.Handle("SYSTEM.REINDEX", []string{"attributes", string(graphID), "START"}); err == nil {
+			t.Error("Expected an error for starting a running reindex")
+		}
+
+		// Writes during the reindex maintain their own entries: a node past
+		// the start marker, and a node the job may not have reached yet
+		if err := engine.CreateNode(graphID, &models.Node{ID: "zz-late", Type: "item", Attributes: models.Attributes{"group": 3}}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		moved, err := engine.GetNode(graphID, "node-49993")
+		if err != nil {
+			t.Fatalf("Failed to get node: %v", err)
+		}
+		moved.Attributes["group"] = 4
+		if err := engine.UpdateNode(graphID, moved); err != nil {
+			t.Fatalf("Failed to update node: %v", err)
+		}
+
+		// Restart part way; the cursor persists
+		job := waitFor(t, graphID, func(job *models.ReindexJob) bool { return job.Scanned >= 5000 })
+		if job.State != models.ReindexRunning || job.Total != nodeCount || job.Scanned >= job.Total {
+			t.Fatalf("Expected the reindex to be running part way, got %+v", job)
+		}
+		if job.Progress() <= 0 || job.Progress() >= 100 || job.ETA(time.Now()) <= 0 {
+			t.Errorf("Expected a partial progress and an ETA, got %.1f%% and %v", job.Progress(), job.ETA(time.Now()))
+		}
+		engine.Close()
+
+		engine = storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+		handler = redis.NewCommandHandler(engine)
+		if resumed := status(t, graphID); resumed.State != models.ReindexRunning || resumed.Scanned < job.Scanned || resumed.Scanned >= nodeCount {
+			t.Fatalf("Expected the reindex to resume from at least %d, got %+v", job.Scanned, resumed)
+		}
+
+		job = waitFor(t, graphID, func(job *models.ReindexJob) bool { return job.State != models.ReindexRunning })
+		if job.State != models.ReindexDone || job.Scanned != nodeCount || job.Progress() != 100 || job.FinishedAt == nil {
+			t.Fatalf("Expected the reindex to finish, got %+v", job)
+		}
+
+		// The index holds an entry for each attribute of every node
+		audit, err := engine.AuditKeys(utils.AttributePrefix)
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		if expected := int64(2*nodeCount + 1); audit.Families["ai"] != expected {
+			t.Errorf("Expected %d attribute index entries, got %d", expected, audit.Families["ai"])
+		}
+
+		for group := 0; group < 10; group++ {
+			// node-49993 moved from group 3 to 4, and zz-late joined group 3
+			expected := nodeCount / 10
+			if group == 4 {
+				expected++
+			}
+			if count := groupCount(t, group); count != expected {
+				t.Errorf("Expected %d nodes in group %d, got %d", expected, group, count)
+			}
+		}
+		nodes, err := engine.FindNodesByAttribute(graphID, "name", "item 12345")
+		if err != nil || len(nodes) != 1 || nodes[0].ID != "node-12345" {
+			t.Errorf("Expected node-12345 by name, got %v, %v", nodes, err)
+		}
+
+		resp, err := handler.Handle("NODE.FILTER", []string{string(graphID), "group", "7.0"})
+		if err != nil {
+			t.Fatalf("NODE.FILTER failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 3*nodeCount/10 {
+			t.Errorf("Expected %d nodes from NODE.FILTER, got %d", nodeCount/10, len(resp.ArrayValue)/3)
+		}
+
+		resp, err = handler.Handle("SYSTEM.REINDEX", []string{"ATTRIBUTES", string(graphID), "STATUS"})
+		if err != nil {
+			t.Fatalf("SYSTEM.REINDEX STATUS failed: %v", err)
+		}
+		fields := make(map[string]string)
+		for i := 0; i+1 < len(resp.ArrayValue); i += 2 {
+			fields[resp.ArrayValue[i]] = resp.ArrayValue[i+1]
+		}
+		if fields["state"] != "done" || fields["progress"] != "100.0" || fields["scanned"] != "50000" || fields["eta_seconds"] != "0" || fields["finished_at"] == "" {
+			t.Errorf("Unexpected status %v", fields)
+		}
+	})
+
+	t.Run("Control", func(t *testing.T) {
+		// A graph created by this build needs no reindex
+		controlID := models.GraphID("control")
+		if err := engine.CreateGraph(&models.Graph{ID: controlID, Name: "control"}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		if job := status(t, controlID); job == nil || job.State != models.ReindexDone {
+			t.Fatalf("Expected a new graph's index to be complete, got %+v", job)
+		}
+		for i := 0; i < 100; i++ {
+			node := &models.Node{ID: models.NodeID(fmt.Sprintf("n%03d", i)), Type: "item", Attributes: models.Attributes{"even": i%2 == 0}}
+			if err := engine.CreateNode(controlID, node); err != nil {
+				t.Fatalf("Failed to create node: %v", err)
+			}
+		}
+		if nodes, err := engine.FindNodesByAttribute(controlID, "even", true); err != nil || len(nodes) != 50 {
+			t.Errorf("Expected 50 even nodes from the index, got %d, %v", len(nodes), err)
+		}
+
+		reindex := func(args ...string) error {
+			_, err := handler.Handle("SYSTEM.REINDEX", append([]string{"attributes", string(controlID)}, args...))
+			return err
+		}
+		// A completed index can be rebuilt; at 10 keys a second the job
+		// runs long enough to be controlled
+		if err := reindex("START", "RATE", "10"); err != nil {
+			t.Fatalf("START failed: %v", err)
+		}
+		if err := reindex("RESUME"); err == nil {
+			t.Error("Expected an error for resuming a running reindex")
+		}
+		if err := reindex("PAUSE"); err != nil {
+			t.Fatalf("PAUSE failed: %v", err)
+		}
+		paused := status(t, controlID)
+		if paused.State != models.ReindexPaused || paused.ETA(time.Now()) != 0 {
+			t.Errorf("Expected the reindex to be paused, got %+v", paused)
+		}
+		time.Sleep(1200 * time.Millisecond)
+		if job := status(t, controlID); job.Scanned != paused.Scanned {
+			t.Errorf("Expected a paused reindex to stay at %d, got %d", paused.Scanned, job.Scanned)
+		}
+		// The index is not used until the rebuild completes
+		if nodes, err := engine.FindNodesByAttribute(controlID, "even", false); err != nil || len(nodes) != 50 {
+			t.Errorf("Expected 50 odd nodes by scan, got %d, %v", len(nodes), err)
+		}
+
+		if err := reindex("RESUME"); err != nil {
+			t.Fatalf("RESUME failed: %v", err)
+		}
+		if job := status(t, controlID); job.State != models.ReindexRunning {
+			t.Errorf("Expected the reindex to run again, got %+v", job)
+		}
+		if err := reindex("CANCEL"); err != nil {
+			t.Fatalf("CANCEL failed: %v", err)
+		}
+		if job := status(t, controlID); job.State != models.ReindexCancelled || job.FinishedAt == nil {
+			t.Errorf("Expected the reindex to be cancelled, got %+v", job)
+		}
+		if err := reindex("PAUSE"); err == nil {
+			t.Error("Expected an error for pausing a cancelled reindex")
+		}
+
+		// A cancelled job can be started over
+		if err := reindex("START", "RATE", "100000"); err != nil {
+			t.Fatalf("START after CANCEL failed: %v", err)
+		}
+		if job := waitFor(t, controlID, func(job *models.ReindexJob) bool { return job.State == models.ReindexDone }); job.Scanned != 100 {
+			t.Errorf("Expected 100 nodes scanned, got %+v", job)
+		}
+
+		// Deleting the graph removes its job
+		if _, err := engine.DeleteGraph(controlID); err != nil {
+			t.Fatalf("Failed to delete graph: %v", err)
+		}
+		audit, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		if audit.Orphaned != 0 {
+			t.Errorf("Expected no keys left by the deleted graph, got %v", audit.Orphans)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"attributes", "legacy"},
+			{"ordering", "legacy", "START"},
+			{"attributes", "missing", "STATUS"},
+			{"attributes", "legacy", "START", "RATE", "0"},
+			{"attributes", "legacy", "START", "SPEED", "10"},
+			{"attributes", "legacy", "STATUS", "NOW"},
+			{"attributes", "legacy", "REBUILD"},
+			{"attributes", "legacy", "PAUSE"},
+		} {
+			if _, err := handler.Handle("SYSTEM.REINDEX", args); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+	})
+}
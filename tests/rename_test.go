@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestGraphSetAndRename tests GRAPH.SET and GRAPH.RENAME, and that a rename
+// leaves no key under the old ID, touches no graph whose ID extends it and
+// is finished on open if it was interrupted
+func TestGraphSetAndRename(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_rename_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { engine.Close() }()
+	handler := redis.NewCommandHandler(engine)
+
+	run := func(t *testing.T, command string, args ...string) *redis.Response {
+		t.Helper()
+		resp, err := handler.Handle(command, args)
+		if err != nil {
+			t.Fatalf("%s %v failed: %v", command, args, err)
+		}
+		return resp
+	}
+
+	// fleet holds nodes and edges with TTLs, an alias and a snapshot;
+	// fleet:eu extends its ID and must be left alone
+	expires := time.Now().Add(time.Hour)
+	for _, graphID := range []models.GraphID{"fleet", "fleet:eu"} {
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		for _, node := range []*models.Node{
+			{ID: "depot", Type: "site", Attributes: models.Attributes{"city": "Lyon"}},
+			{ID: "truck", Type: "vehicle", ExpiresAt: &expires},
+			{ID: "driver", Type: "person"},
+		} {
+			if err := engine.CreateNode(graphID, node); err != nil {
+				t.Fatalf("Failed to create node: %v", err)
+			}
+		}
+		for _, edge := range []*models.Edge{
+			{ID: "parked", Type: "at", FromNodeID: "truck", ToNodeID: "depot"},
+			{ID: "drives", Type: "drives", FromNodeID: "driver", ToNodeID: "truck", ExpiresAt: &expires},
+		} {
+			if err := engine.CreateEdge(graphID, edge); err != nil {
+				t.Fatalf("Failed to create edge: %v", err)
+			}
+		}
+	}
+	if err := engine.AddNodeAlias("fleet", "truck", "lorry"); err != nil {
+		t.Fatalf("Failed to add alias: %v", err)
+	}
+	if _, err := engine.CreateSnapshot("fleet", "before"); err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+
+	t.Run("Set", func(t *testing.T) {
+		before, err := engine.GetGraph("fleet")
+		if err != nil {
+			t.Fatalf("GetGraph failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+		run(t, "GRAPH.SET", "fleet", "NAME", "Fleet", "description", "Trucks and depots")
+		graph, err := engine.GetGraph("fleet")
+		if err != nil {
+			t.Fatalf("GetGraph failed: %v", err)
+		}
+		if graph.Name != "Fleet" || graph.Description != "Trucks and depots" || !graph.UpdatedAt.After(before.UpdatedAt) {
+			t.Errorf("Expected the name, description and update time to change, got %+v", graph)
+		}
+
+		run(t, "GRAPH.SET", "fleet", "DESCRIPTION", "Trucks")
+		graph, _ = engine.GetGraph("fleet")
+		if graph.Name != "Fleet" || graph.Description != "Trucks" {
+			t.Errorf("Expected only the description to change, got %+v", graph)
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		// NODE.LIST and CountGraphKeys scan by prefix, so they would count
+		// fleet:eu as part of fleet; the audit tells the graphs apart
+		neighbors := run(t, "EDGE.NEIGHBORS", "fleet", "truck").ArrayValue
+		before, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+
+		run(t, "GRAPH.RENAME", "fleet", "trucks")
+
+		if got, expected := run(t, "NODE.LIST", "trucks").ArrayValue, []string{"depot:site", "driver:person", "truck:vehicle"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected the nodes %v under the new ID, got %v", expected, got)
+		}
+		if got := run(t, "EDGE.NEIGHBORS", "trucks", "truck").ArrayValue; len(got) != 2 || !reflect.DeepEqual(got, neighbors) {
+			t.Errorf("Expected the neighbors %v under the new ID, got %v", neighbors, got)
+		}
+		if got := run(t, "EDGE.NEIGHBORS", "trucks", "lorry").ArrayValue; !reflect.DeepEqual(got, neighbors) {
+			t.Errorf("Expected the alias to move with its node, got %v", got)
+		}
+
+		graph, err := engine.GetGraph("trucks")
+		if err != nil {
+			t.Fatalf("GetGraph failed: %v", err)
+		}
+		if graph.ID != "trucks" || graph.Name != "Fleet" {
+			t.Errorf("Expected the record to move with its new ID, got %+v", graph)
+		}
+		if _, err := engine.GetGraph("fleet"); !errors.Is(err, storage.ErrGraphNotFound) {
+			t.Errorf("Expected the old ID to be gone, got %v", err)
+		}
+		if nodes, _ := engine.ListNodes("fleet:eu"); len(nodes) != 3 {
+			t.Errorf("Expected fleet:eu to keep its 3 nodes, got %d", len(nodes))
+		}
+
+		after, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		if !reflect.DeepEqual(after.Graphs["trucks"], before.Graphs["fleet"]) {
+			t.Errorf("Expected the keys %v under the new ID, got %v", before.Graphs["fleet"], after.Graphs["trucks"])
+		}
+		if !reflect.DeepEqual(after.Graphs["fleet:eu"], before.Graphs["fleet:eu"]) {
+			t.Errorf("Expected the keys of fleet:eu untouched, got %v", after.Graphs["fleet:eu"])
+		}
+		// Keys left under the old ID would have no graph record
+		if after.Orphaned != 0 || len(after.Mismatches) != 0 || after.Families["gr"] != 0 {
+			t.Errorf("Expected no orphaned keys, index mismatches or rename marker, got %d, %v and %v", after.Orphaned, after.Mismatches, after.Families)
+		}
+
+		snapshots, err := engine.ListSnapshots("trucks")
+		if err != nil || len(snapshots) != 1 || snapshots[0].GraphID != "trucks" {
+			t.Errorf("Expected the snapshot to move, got %v, %v", snapshots, err)
+		}
+		counts, _ := engine.CountNodesByType("trucks")
+		if expected := map[models.NodeType]int{"site": 1, "vehicle": 1, "person": 1}; !reflect.DeepEqual(counts, expected) {
+			t.Errorf("Expected type counts %v, got %v", expected, counts)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, c := range []struct {
+			command string
+			args    []string
+		}{
+			{"GRAPH.SET", []string{"trucks"}},
+			{"GRAPH.SET", []string{"trucks", "NAME"}},
+			{"GRAPH.SET", []string{"trucks", "COLOR", "red"}},
+			{"GRAPH.SET", []string{"missing", "NAME", "x"}},
+			{"GRAPH.RENAME", []string{"trucks"}},
+			{"GRAPH.RENAME", []string{"missing", "other"}},
+			{"GRAPH.RENAME", []string{"trucks", "fleet:eu"}},
+			{"GRAPH.RENAME", []string{"trucks", "trucks"}},
+			{"GRAPH.RENAME", []string{"trucks", "n:trucks"}},
+		} {
+			if _, err := handler.Handle(c.command, c.args); err == nil {
+				t.Errorf("Expected %s %v to fail", c.command, c.args)
+			}
+		}
+		if _, err := engine.GetGraph("trucks"); err != nil {
+			t.Errorf("Expected the failed renames to leave the graph, got %v", err)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		// Leave a rename marker the way a crash right after marking does
+		if err := engine.Close(); err != nil {
+			t.Fatalf("Failed to close database: %v", err)
+		}
+		db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open raw database: %v", err)
+		}
+		err = db.Update(func(txn *badger.Txn) error {
+			return txn.Set(utils.EncodeGraphRenameKey("trucks"), []byte(`{"to":"lorries"}`))
+		})
+		db.Close()
+		if err != nil {
+			t.Fatalf("Failed to write rename marker: %v", err)
+		}
+
+		engine = storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+		handler = redis.NewCommandHandler(engine)
+		if _, err := engine.GetGraph("lorries"); err != nil {
+			t.Fatalf("Expected the rename to finish on open, got %v", err)
+		}
+		if left, _ := engine.CountGraphKeys("trucks"); left != 0 {
+			t.Errorf("Expected no keys left under the old ID, got %d", left)
+		}
+		if got := run(t, "NODE.LIST", "lorries").ArrayValue; len(got) != 3 {
+			t.Errorf("Expected 3 nodes under the new ID, got %v", got)
+		}
+	})
+}
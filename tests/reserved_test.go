@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestReservedCharacters tests that IDs and types containing reserved
+// sequences are rejected with BADARG by commands and the storage API
+func TestReservedCharacters(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_reserved_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	handler := redis.NewCommandHandler(engine)
+
+	for _, step := range [][]string{
+		{"GRAPH.CREATE", "g"},
+		{"NODE.CREATE", "g", "a", "service"},
+		{"NODE.CREATE", "g", "b", "service"},
+	} {
+		if _, err := handler.Handle(step[0], step[1:]); err != nil {
+			t.Fatalf("%v failed: %v", step, err)
+		}
+	}
+
+	// expectBadArg checks that err is a BADARG error naming the offending
+	// sequence
+	expectBadArg := func(t *testing.T, err error, offending string) {
+		t.Helper()
+		if err == nil {
+			t.Fatal("Expected BADARG error, got nil")
+		}
+		if !errors.Is(err, models.ErrBadArgument) || !strings.HasPrefix(err.Error(), "BADARG ") {
+			t.Fatalf("Expected a BADARG error, got %v", err)
+		}
+		if !strings.Contains(err.Error(), offending) {
+			t.Errorf("Expected the error to name %s, got %v", offending, err)
+		}
+	}
+
+	t.Run("Commands", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			command   string
+			args      []string
+			offending string
+		}{
+			{"NodeIDArrow", "NODE.CREATE", []string{"g", "a->b", "service"}, `"->"`},
+			{"NodeIDBackArrow", "NODE.CREATE", []string{"g", "a<-b", "service"}, `"<-"`},
+			{"NodeIDControl", "NODE.CREATE", []string{"g", "a\nb", "service"}, `'\n'`},
+			{"NodeIDTab", "NODE.CREATE", []string{"g", "a\tb", "service"}, `'\t'`},
+			{"NodeIDLeadingSpace", "NODE.CREATE", []string{"g", " a", "service"}, "whitespace"},
+			{"NodeIDTrailingSpace", "NODE.CREATE", []string{"g", "a ", "service"}, "whitespace"},
+			{"NodeTypeColon", "NODE.CREATE", []string{"g", "c", "web:service"}, `':'`},
+			{"NodeTypeArrow", "NODE.CREATE", []string{"g", "c", "web->service"}, `"->"`},
+			{"NodeUpdateType", "NODE.UPDATE", []string{"g", "a", "TYPE", "web:service"}, `':'`},
+			{"NodeRetype", "NODE.RETYPE", []string{"g", "service", "web:service"}, `':'`},
+			{"EdgeIDArrow", "EDGE.CREATE", []string{"g", "a->b", "a", "b", "calls"}, `"->"`},
+			{"EdgeIDControl", "EDGE.CREATE", []string{"g", "ab\x00", "a", "b", "calls"}, `'\x00'`},
+			{"EdgeTypeColon", "EDGE.CREATE", []string{"g", "ab", "a", "b", "calls:sync"}, `':'`},
+			{"EdgeTypeBackArrow", "EDGE.CREATE", []string{"g", "ab", "a", "b", "<-calls"}, `"<-"`},
+			{"EdgeRetype", "EDGE.RETYPE", []string{"g", "calls", "calls "}, "whitespace"},
+			{"GraphArrow", "GRAPH.CREATE", []string{"g->h"}, `"->"`},
+			{"GraphControl", "GRAPH.CREATE", []string{"g\rh"}, `'\r'`},
+			{"ImportGraphName", "GRAPH.IMPORT", []string{" copy", "BEGIN"}, "whitespace"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := handler.Handle(tt.command, tt.args)
+				expectBadArg(t, err, tt.offending)
+			})
+		}
+
+		node, err := engine.GetNode("g", "a")
+		if err != nil || node.Type != "service" {
+			t.Errorf("Expected rejected updates to leave the node unchanged, got %+v, %v", node, err)
+		}
+		if edges, _ := engine.ListEdges("g"); len(edges) != 0 {
+			t.Errorf("Expected no edges to be created, got %d", len(edges))
+		}
+	})
+
+	t.Run("StorageAPI", func(t *testing.T) {
+		expectBadArg(t, engine.CreateNode("g", &models.Node{ID: "x->y", Type: "service"}), `"->"`)
+		expectBadArg(t, engine.CreateEdge("g", &models.Edge{ID: "ab", Type: "a:b", FromNodeID: "a", ToNodeID: "b"}), `':'`)
+		expectBadArg(t, engine.CreateGraph(&models.Graph{ID: "g<-h"}), `"<-"`)
+		expectBadArg(t, engine.UpdateNode("g", &models.Node{ID: "a", Type: "web:service"}), `':'`)
+		_, err := engine.RenameEdgeType("g", "calls", "calls\x7f")
+		expectBadArg(t, err, `'\x7f'`)
+
+		err = engine.RunTransaction(func(tx storage.Transaction) error {
+			return tx.CreateNode("g", &models.Node{ID: "z", Type: " service"})
+		})
+		expectBadArg(t, err, "whitespace")
+
+		// Import wraps the error with the document context
+		doc := `{"graph":{},"nodes":[{"id":"p<-q","type":"service"}],"edges":[]}`
+		_, _, err = engine.ImportGraph("imported", strings.NewReader(doc))
+		if !errors.Is(err, models.ErrBadArgument) {
+			t.Errorf("Expected ImportGraph to reject the node ID, got %v", err)
+		}
+	})
+
+	// IDs may still contain single colons, dashes, spaces and non-ASCII
+	// characters, and they come back unchanged
+	t.Run("AllowedRoundTrip", func(t *testing.T) {
+		for _, step := range [][]string{
+			{"NODE.CREATE", "g", "urn:svc:a", "service"},
+			{"NODE.CREATE", "g", "billing - eu", "web-service"},
+			{"NODE.CREATE", "g", "café", "service"},
+			{"EDGE.CREATE", "g", "e1", "urn:svc:a", "billing - eu", "calls"},
+			{"EDGE.CREATE", "g", "e2", "billing - eu", "café", "calls"},
+		} {
+			if _, err := handler.Handle(step[0], step[1:]); err != nil {
+				t.Fatalf("%v failed: %v", step, err)
+			}
+		}
+
+		resp, err := handler.Handle("ANALYSIS.TRAVERSE", []string{"g", "urn:svc:a", "DIRECTION", "out", "FORMAT", "simple"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		got := append([]string{}, resp.ArrayValue...)
+		sort.Strings(got)
+		expected := []string{"billing - eu:web-service", "café:service", "urn:svc:a:service"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+		for _, entry := range got {
+			i := strings.LastIndex(entry, ":")
+			if _, err := engine.GetNode("g", models.NodeID(entry[:i])); err != nil {
+				t.Errorf("Expected %q to split at its last colon into a node ID, got %v", entry, err)
+			}
+		}
+
+		resp, err = handler.Handle("EDGE.NEIGHBORS", []string{"g", "billing - eu", "in", "FORMAT", "simple"})
+		if err != nil {
+			t.Fatalf("EDGE.NEIGHBORS failed: %v", err)
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, []string{"urn:svc:a:service"}) {
+			t.Errorf("Expected the colon-containing ID back, got %v", resp.ArrayValue)
+		}
+	})
+
+	t.Run("Protocol", func(t *testing.T) {
+		addr := startTestServer(t, engine, redis.DefaultConfig())
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		fmt.Fprint(conn, encodeCommand("NODE.CREATE", "g", "a->b", "service"))
+		reply, err := readReply(reader)
+		if err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+		if !strings.HasPrefix(reply[0], "-BADARG node ID ") {
+			t.Errorf("Expected a BADARG error reply, got %v", reply)
+		}
+	})
+}
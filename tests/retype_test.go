@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestRenameTypes tests NODE.RETYPE and EDGE.RETYPE
+func TestRenameTypes(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_retype_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("retype-test-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "gateway", Type: "gateway"},
+		{ID: "orders", Type: "service"},
+		{ID: "billing", Type: "service"},
+		{ID: "db", Type: "database"},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "gateway-orders", FromNodeID: "gateway", ToNodeID: "orders", Type: "calls"},
+		{ID: "orders-billing", FromNodeID: "orders", ToNodeID: "billing", Type: "calls"},
+		{ID: "billing-db", FromNodeID: "billing", ToNodeID: "db", Type: "reads"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	nodeCommands := commands.NewNodeCommands(engine)
+	edgeCommands := commands.NewEdgeCommands(engine)
+	analysisCommands := commands.NewAnalysisCommands(engine)
+
+	// nodeIDs lists the IDs of the nodes of a type
+	nodeIDs := func(t *testing.T, nodeType models.NodeType) []string {
+		nodes, err := engine.ListNodesByType(graphID, nodeType)
+		if err != nil {
+			t.Fatalf("Failed to list nodes by type: %v", err)
+		}
+		ids := []string{}
+		for _, node := range nodes {
+			ids = append(ids, string(node.ID))
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	t.Run("NodeRetype", func(t *testing.T) {
+		if got := nodeIDs(t, "service"); !reflect.DeepEqual(got, []string{"billing", "orders"}) {
+			t.Fatalf("Expected two service nodes before rename, got %v", got)
+		}
+		if got := nodeIDs(t, "microservice"); len(got) != 0 {
+			t.Fatalf("Expected no microservice nodes before rename, got %v", got)
+		}
+
+		resp, err := nodeCommands.Handle("RETYPE", []string{string(graphID), "service", "microservice"})
+		if err != nil {
+			t.Fatalf("NODE.RETYPE failed: %v", err)
+		}
+		if resp.IntValue != 2 {
+			t.Errorf("Expected 2 nodes renamed, got %d", resp.IntValue)
+		}
+
+		if got := nodeIDs(t, "service"); len(got) != 0 {
+			t.Errorf("Expected no service nodes after rename, got %v", got)
+		}
+		if got := nodeIDs(t, "microservice"); !reflect.DeepEqual(got, []string{"billing", "orders"}) {
+			t.Errorf("Expected renamed microservice nodes, got %v", got)
+		}
+		node, err := engine.GetNode(graphID, "orders")
+		if err != nil || node.Type != "microservice" {
+			t.Errorf("Expected node record to carry the new type, got %v, %v", node, err)
+		}
+
+		// Renaming again finds nothing to change
+		resp, err = nodeCommands.Handle("RETYPE", []string{string(graphID), "service", "microservice"})
+		if err != nil || resp.IntValue != 0 {
+			t.Errorf("Expected a repeated rename to change nothing, got %v, %v", resp, err)
+		}
+	})
+
+	t.Run("TraversalFilter", func(t *testing.T) {
+		resp, err := analysisCommands.Handle("TRAVERSE", []string{string(graphID), "gateway", "DIRECTION", "out", "FORMAT", "simple", "NODETYPES", "microservice"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		expected := []string{"orders:microservice", "billing:microservice"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("EdgeRetype", func(t *testing.T) {
+		resp, err := edgeCommands.Handle("RETYPE", []string{string(graphID), "calls", "invokes"})
+		if err != nil {
+			t.Fatalf("EDGE.RETYPE failed: %v", err)
+		}
+		if resp.IntValue != 2 {
+			t.Errorf("Expected 2 edges renamed, got %d", resp.IntValue)
+		}
+
+		calls, err := engine.ListEdgesByType(graphID, "calls")
+		if err != nil || len(calls) != 0 {
+			t.Errorf("Expected no calls edges after rename, got %d (%v)", len(calls), err)
+		}
+		invokes, err := engine.ListEdgesByType(graphID, "invokes")
+		if err != nil || len(invokes) != 2 {
+			t.Errorf("Expected 2 invokes edges after rename, got %d (%v)", len(invokes), err)
+		}
+		outgoing, err := engine.GetOutgoingEdges(graphID, "gateway")
+		if err != nil || len(outgoing) != 1 || outgoing[0].Type != "invokes" {
+			t.Errorf("Expected adjacency to see the renamed edge, got %v, %v", outgoing, err)
+		}
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+		batchGraph := models.GraphID("retype-batch-graph")
+		if err := engine.CreateGraph(&models.Graph{ID: batchGraph, Name: string(batchGraph)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		const count = 1200
+		for i := 0; i < count; i++ {
+			node := &models.Node{ID: models.NodeID(fmt.Sprintf("n%04d", i)), Type: "worker"}
+			if err := engine.CreateNode(batchGraph, node); err != nil {
+				t.Fatalf("Failed to create node: %v", err)
+			}
+		}
+
+		renamed, err := engine.RenameNodeType(batchGraph, "worker", "job")
+		if err != nil {
+			t.Fatalf("RenameNodeType failed: %v", err)
+		}
+		if renamed != count {
+			t.Errorf("Expected %d nodes renamed, got %d", count, renamed)
+		}
+		jobs, err := engine.ListNodesByType(batchGraph, "job")
+		if err != nil || len(jobs) != count {
+			t.Errorf("Expected %d job nodes, got %d (%v)", count, len(jobs), err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := nodeCommands.Handle("RETYPE", []string{"missing-graph", "a", "b"}); err == nil {
+			t.Error("Expected error for a missing graph")
+		}
+		if _, err := nodeCommands.Handle("RETYPE", []string{string(graphID), "database", "database"}); err == nil {
+			t.Error("Expected error when the types are the same")
+		}
+		if _, err := edgeCommands.Handle("RETYPE", []string{string(graphID), "reads", ""}); err == nil {
+			t.Error("Expected error for an empty type")
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// createScanGraph creates a graph of nodes nodes of three types, each with
+// an edge to the next one, in transactions of 1000 writes
+func createScanGraph(tb testing.TB, engine *storage.BadgerEngine, graphID models.GraphID, nodes int) {
+	tb.Helper()
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		tb.Fatalf("Failed to create graph: %v", err)
+	}
+	nodeID := func(i int) models.NodeID { return models.NodeID(fmt.Sprintf("node-%06d", i)) }
+	const batch = 1000
+	for start := 0; start < nodes; start += batch {
+		err := engine.RunTransaction(func(tx storage.Transaction) error {
+			for i := start; i < start+batch && i < nodes; i++ {
+				node := &models.Node{ID: nodeID(i), Type: models.NodeType(fmt.Sprintf("type-%d", i%3)), Attributes: models.Attributes{"index": i}}
+				if err := tx.CreateNode(graphID, node); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			tb.Fatalf("Failed to create nodes: %v", err)
+		}
+	}
+	for start := 0; start < nodes; start += batch {
+		err := engine.RunTransaction(func(tx storage.Transaction) error {
+			for i := start; i < start+batch && i < nodes; i++ {
+				edge := &models.Edge{ID: models.EdgeID(fmt.Sprintf("edge-%06d", i)), Type: models.EdgeType(fmt.Sprintf("link-%d", i%2)), FromNodeID: nodeID(i), ToNodeID: nodeID((i + 1) % nodes)}
+				if err := tx.CreateEdge(graphID, edge); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			tb.Fatalf("Failed to create edges: %v", err)
+		}
+	}
+}
+
+// scanResults is what the listing and counting calls return for a graph
+type scanResults struct {
+	Nodes        []*models.Node
+	Edges        []*models.Edge
+	Scanned      []models.NodeID
+	NodeCount    int
+	EdgeCount    int
+	NodesByType  []*models.Node
+	FilterByType []*models.Edge
+}
+
+// TestScanPaths tests that listings and counts return the same results with
+// the old read-ahead of 10 values, the new defaults, and no read-ahead
+func TestScanPaths(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_scan_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	createScanGraph(t, engine, "scan", 3000)
+	// A graph whose ID extends the first one's must not leak into its scans
+	createScanGraph(t, engine, "scanner", 10)
+	// An expired node is left out of listings but still counted
+	expired := time.Now().Add(-time.Minute)
+	if err := engine.CreateNode("scan", &models.Node{ID: "expired", Type: "type-0", ExpiresAt: &expired}); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	engine.Close()
+
+	read := func(t *testing.T, opts ...storage.Option) *scanResults {
+		t.Helper()
+		engine := storage.NewBadgerEngine(append(opts, storage.WithOffline(true))...)
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+
+		results := &scanResults{}
+		var err error
+		if results.Nodes, err = engine.ListNodes("scan"); err != nil {
+			t.Fatalf("ListNodes failed: %v", err)
+		}
+		if results.Edges, err = engine.ListEdges("scan"); err != nil {
+			t.Fatalf("ListEdges failed: %v", err)
+		}
+		err = engine.ScanNodes("scan", func(node *models.Node) error {
+			results.Scanned = append(results.Scanned, node.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ScanNodes failed: %v", err)
+		}
+		if results.NodeCount, err = engine.CountNodes("scan"); err != nil {
+			t.Fatalf("CountNodes failed: %v", err)
+		}
+		if results.EdgeCount, err = engine.CountEdges("scan"); err != nil {
+			t.Fatalf("CountEdges failed: %v", err)
+		}
+		if results.NodesByType, err = engine.ListNodesByType("scan", "type-1"); err != nil {
+			t.Fatalf("ListNodesByType failed: %v", err)
+		}
+		if results.FilterByType, err = engine.FilterEdges("scan", storage.EdgeFilter{From: "node-000003", Type: "link-1"}); err != nil {
+			t.Fatalf("FilterEdges failed: %v", err)
+		}
+		return results
+	}
+
+	old := read(t, storage.WithScanPrefetch(10), storage.WithListPrefetch(10))
+	if len(old.Nodes) != 3000 || len(old.Edges) != 3000 || len(old.Scanned) != 3000 {
+		t.Fatalf("Expected 3000 nodes, edges and scanned nodes, got %d, %d and %d", len(old.Nodes), len(old.Edges), len(old.Scanned))
+	}
+	if old.NodeCount != 3001 || old.EdgeCount != 3000 {
+		t.Errorf("Expected counts of 3001 nodes and 3000 edges, got %d and %d", old.NodeCount, old.EdgeCount)
+	}
+	if len(old.NodesByType) != 1000 || len(old.FilterByType) != 1 {
+		t.Errorf("Expected 1000 nodes of type-1 and 1 filtered edge, got %d and %d", len(old.NodesByType), len(old.FilterByType))
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []storage.Option
+	}{
+		{"Defaults", nil},
+		{"No Read-Ahead", []storage.Option{storage.WithScanPrefetch(0), storage.WithListPrefetch(0)}},
+		{"Large Read-Ahead", []storage.Option{storage.WithListPrefetch(1000)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if results := read(t, tc.opts...); !reflect.DeepEqual(results, old) {
+				t.Errorf("Expected the same results as a read-ahead of 10, got %d nodes, %d edges, counts %d and %d",
+					len(results.Nodes), len(results.Edges), results.NodeCount, results.EdgeCount)
+			}
+		})
+	}
+}
+
+// BenchmarkScan compares scans over a graph of 100k nodes and 100k edges:
+// counting keys only against counting by reading every value, and listing
+// with the old read-ahead of 10 values against the default
+func BenchmarkScan(b *testing.B) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_scan_bench")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	graphID := models.GraphID("bench-scan")
+	createScanGraph(b, engine, graphID, 100000)
+	engine.Close()
+
+	run := func(name string, opts []storage.Option, fn func(b *testing.B, engine *storage.BadgerEngine)) {
+		b.Run(name, func(b *testing.B) {
+			engine := storage.NewBadgerEngine(append(opts, storage.WithOffline(true))...)
+			if err := engine.Open(testPath); err != nil {
+				b.Fatalf("Failed to open database: %v", err)
+			}
+			defer engine.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fn(b, engine)
+			}
+		})
+	}
+
+	run("Count/KeysOnly", nil, func(b *testing.B, engine *storage.BadgerEngine) {
+		nodes, err := engine.CountNodes(graphID)
+		if err != nil {
+			b.Fatalf("CountNodes failed: %v", err)
+		}
+		edges, err := engine.CountEdges(graphID)
+		if err != nil {
+			b.Fatalf("CountEdges failed: %v", err)
+		}
+		if nodes+edges != 200000 {
+			b.Fatalf("Expected 200000 entities, got %d", nodes+edges)
+		}
+	})
+	run("Count/Values", []storage.Option{storage.WithListPrefetch(10)}, func(b *testing.B, engine *storage.BadgerEngine) {
+		count := 0
+		if err := engine.ScanNodes(graphID, func(*models.Node) error { count++; return nil }); err != nil {
+			b.Fatalf("ScanNodes failed: %v", err)
+		}
+		if err := engine.ScanEdges(graphID, func(*models.Edge) error { count++; return nil }); err != nil {
+			b.Fatalf("ScanEdges failed: %v", err)
+		}
+		if count != 200000 {
+			b.Fatalf("Expected 200000 entities, got %d", count)
+		}
+	})
+
+	list := func(b *testing.B, engine *storage.BadgerEngine) {
+		nodes, err := engine.ListNodes(graphID)
+		if err != nil {
+			b.Fatalf("ListNodes failed: %v", err)
+		}
+		edges, err := engine.ListEdges(graphID)
+		if err != nil {
+			b.Fatalf("ListEdges failed: %v", err)
+		}
+		if len(nodes)+len(edges) != 200000 {
+			b.Fatalf("Expected 200000 entities, got %d", len(nodes)+len(edges))
+		}
+	}
+	run("List/Prefetch10", []storage.Option{storage.WithListPrefetch(10)}, list)
+	run("List/Default", nil, list)
+}
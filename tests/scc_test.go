@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestStronglyConnectedComponents tests FindStronglyConnectedComponents,
+// HasCycles on top of it and ANALYSIS.SCC
+func TestStronglyConnectedComponents(t *testing.T) {
+	t.Run("Components", func(t *testing.T) {
+		analyzer, graphID := loadFixture(t, "a -[calls]-> b -[calls]-> c -[calls]-> a, c -[calls]-> d, "+
+			"d -[calls]-> e -[calls]-> d, f -[calls]-> f, g -[calls]-> h, b -[reads]-> x -[reads]-> b")
+
+		components, err := analyzer.FindStronglyConnectedComponents(graphID, nil)
+		if err != nil {
+			t.Fatalf("FindStronglyConnectedComponents failed: %v", err)
+		}
+		// Largest first, each sorted by node ID; g and h are on no cycle
+		expected := [][]models.NodeID{{"a", "b", "c", "x"}, {"d", "e"}, {"f"}}
+		if !reflect.DeepEqual(components, expected) {
+			t.Errorf("Expected %v, got %v", expected, components)
+		}
+
+		components, err = analyzer.FindStronglyConnectedComponents(graphID, &types.TraversalOptions{EdgeTypes: []models.EdgeType{"calls"}})
+		if err != nil {
+			t.Fatalf("FindStronglyConnectedComponents failed: %v", err)
+		}
+		if expected := [][]models.NodeID{{"a", "b", "c"}, {"d", "e"}, {"f"}}; !reflect.DeepEqual(components, expected) {
+			t.Errorf("Expected the calls edges to give %v, got %v", expected, components)
+		}
+		components, err = analyzer.FindStronglyConnectedComponents(graphID, &types.TraversalOptions{EdgeTypes: []models.EdgeType{"reads"}})
+		if err != nil || !reflect.DeepEqual(components, [][]models.NodeID{{"b", "x"}}) {
+			t.Errorf("Expected the reads edges to give [[b x]], got %v, %v", components, err)
+		}
+	})
+
+	t.Run("Dense", func(t *testing.T) {
+		// Every node leads to the next two around a ring, so the number of
+		// elementary cycles grows exponentially with the ring
+		const size = 2000
+		var edges []string
+		for i := 0; i < size; i++ {
+			edges = append(edges, fmt.Sprintf("n%04d -> n%04d", i, (i+1)%size), fmt.Sprintf("n%04d -> n%04d", i, (i+2)%size))
+		}
+		analyzer, graphID := loadFixture(t, strings.Join(edges, ", "))
+
+		start := time.Now()
+		cyclic, err := analyzer.HasCycles(graphID, nil)
+		if err != nil || !cyclic {
+			t.Fatalf("Expected the ring to have cycles, got %v, %v", cyclic, err)
+		}
+		components, err := analyzer.FindStronglyConnectedComponents(graphID, nil)
+		if err != nil || len(components) != 1 || len(components[0]) != size {
+			t.Fatalf("Expected one component of %d nodes, got %d components, %v", size, len(components), err)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("Expected the ring to be analyzed quickly, took %v", elapsed)
+		}
+
+		// Without the edges closing the ring it is acyclic
+		analyzer, graphID = loadFixture(t, strings.Join(edges[:2*size-4], ", "))
+		if cyclic, err := analyzer.HasCycles(graphID, nil); err != nil || cyclic {
+			t.Errorf("Expected the open ring to have no cycles, got %v, %v", cyclic, err)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		testPath := filepath.Join(os.TempDir(), "pathwaydb_scc_test")
+		os.RemoveAll(testPath)
+		defer os.RemoveAll(testPath)
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+		handler := redis.NewCommandHandler(engine)
+
+		graphID := "scc"
+		for _, args := range [][]string{
+			{"GRAPH.CREATE", graphID},
+			{"NODE.CREATE", graphID, "api", "service"},
+			{"NODE.CREATE", graphID, "auth", "service"},
+			{"NODE.CREATE", graphID, "db", "database"},
+			{"EDGE.CREATE", graphID, "api-auth", "api", "auth", "calls"},
+			{"EDGE.CREATE", graphID, "auth-api", "auth", "api", "calls"},
+			{"EDGE.CREATE", graphID, "db-db", "db", "db", "replicates"},
+			{"EDGE.CREATE", graphID, "api-db", "api", "db", "reads"},
+		} {
+			if _, err := handler.Handle(args[0], args[1:]); err != nil {
+				t.Fatalf("%v failed: %v", args, err)
+			}
+		}
+
+		resp, err := handler.Handle("ANALYSIS.SCC", []string{graphID})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SCC failed: %v", err)
+		}
+		if expected := []interface{}{[]string{"api", "auth"}, []string{"db"}}; !reflect.DeepEqual(resp.NestedArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.NestedArrayValue)
+		}
+		resp, err = handler.Handle("ANALYSIS.SCC", []string{graphID, "EDGETYPES", "reads", "replicates"})
+		if err != nil || !reflect.DeepEqual(resp.NestedArrayValue, []interface{}{[]string{"db"}}) {
+			t.Errorf("Expected only db with EDGETYPES reads replicates, got %v, %v", resp, err)
+		}
+
+		for _, args := range [][]string{
+			{},
+			{"missing"},
+			{graphID, "FORMAT", "simple"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.SCC", args); err == nil {
+				t.Errorf("Expected ANALYSIS.SCC %v to fail", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/search"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestTextSearch tests SEARCH.TEXT and the attribute flattening it uses
+func TestTextSearch(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_search_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("search-test-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "checkout", Type: "service", Attributes: models.Attributes{
+			"config": map[string]interface{}{"upstream": map[string]interface{}{"name": "Payments-V2"}},
+		}},
+		{ID: "gateway", Type: "service", Attributes: models.Attributes{
+			"routes": []interface{}{"/orders", map[string]interface{}{"target": "payments-v2/charge"}},
+		}},
+		{ID: "ledger", Type: "database", Attributes: models.Attributes{"owner": "payments-v2 team"}},
+		{ID: "metrics", Type: "service", Attributes: models.Attributes{"port": float64(8080), "enabled": true}},
+		{ID: "unrelated", Type: "service", Attributes: models.Attributes{"owner": "search"}},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+	edge := &models.Edge{ID: "checkout-ledger", FromNodeID: "checkout", ToNodeID: "ledger", Type: "writes", Attributes: models.Attributes{"via": "payments-v2"}}
+	if err := engine.CreateEdge(graphID, edge); err != nil {
+		t.Fatalf("Failed to create edge: %v", err)
+	}
+
+	handler := redis.NewCommandHandler(engine)
+	searchText := func(t *testing.T, args ...string) []string {
+		resp, err := handler.Handle("SEARCH.TEXT", append([]string{string(graphID)}, args...))
+		if err != nil {
+			t.Fatalf("SEARCH.TEXT failed: %v", err)
+		}
+		got := append([]string{}, resp.ArrayValue...)
+		sort.Strings(got)
+		return got
+	}
+
+	t.Run("NestedMapsAndArrays", func(t *testing.T) {
+		got := searchText(t, "PAYMENTS-v2")
+		expected := []string{
+			"checkout:service:config.upstream.name",
+			"gateway:service:routes[1].target",
+			"ledger:database:owner",
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("NumericAndBoolValues", func(t *testing.T) {
+		if got := searchText(t, "8080"); !reflect.DeepEqual(got, []string{"metrics:service:port"}) {
+			t.Errorf("Expected numeric match, got %v", got)
+		}
+		if got := searchText(t, "TRUE"); !reflect.DeepEqual(got, []string{"metrics:service:enabled"}) {
+			t.Errorf("Expected boolean match, got %v", got)
+		}
+	})
+
+	t.Run("NodeTypes", func(t *testing.T) {
+		got := searchText(t, "payments-v2", "NODETYPES", "database")
+		if !reflect.DeepEqual(got, []string{"ledger:database:owner"}) {
+			t.Errorf("Expected only the database match, got %v", got)
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		if got := searchText(t, "payments-v2", "LIMIT", "2"); len(got) != 2 {
+			t.Errorf("Expected 2 matches with LIMIT 2, got %v", got)
+		}
+		if got := searchText(t, "payments-v2", "LIMIT", "3", "EDGES"); len(got) != 3 {
+			t.Errorf("Expected the limit to stop before edges, got %v", got)
+		}
+	})
+
+	t.Run("Edges", func(t *testing.T) {
+		got := searchText(t, "payments-v2", "NODETYPES", "database", "EDGES")
+		expected := []string{"checkout-ledger:writes:via", "ledger:database:owner"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := handler.Handle("SEARCH.TEXT", []string{"missing-graph", "x"}); err == nil {
+			t.Error("Expected error for a missing graph")
+		}
+		if _, err := handler.Handle("SEARCH.TEXT", []string{string(graphID), "x", "LIMIT", "0"}); err == nil {
+			t.Error("Expected error for a non-positive LIMIT")
+		}
+	})
+
+	t.Run("FlattenEarlyExit", func(t *testing.T) {
+		var visited []string
+		complete := search.Flatten(map[string]interface{}{"a": "1", "b": []interface{}{"2", "3"}, "c": nil}, func(key, value string) bool {
+			visited = append(visited, key+"="+value)
+			return key != "b[0]"
+		})
+		if complete {
+			t.Error("Expected Flatten to report an early exit")
+		}
+		if expected := []string{"a=1", "b[0]=2"}; !reflect.DeepEqual(visited, expected) {
+			t.Errorf("Expected %v, got %v", expected, visited)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// TestSelfLoopConstraint tests GRAPH.CONSTRAINT SELFLOOPS and GRAPH.SELFLOOPS
+func TestSelfLoopConstraint(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_selfloop_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("selfloop-test-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{{ID: "a", Type: "service"}, {ID: "b", Type: "service"}} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node %s: %v", node.ID, err)
+		}
+	}
+
+	// Self-loops are allowed by default, so these exist before the
+	// constraint is enabled
+	for _, edge := range []*models.Edge{
+		{ID: "a-a", FromNodeID: "a", ToNodeID: "a", Type: "calls"},
+		{ID: "b-b", FromNodeID: "b", ToNodeID: "b", Type: "retries"},
+		{ID: "a-b", FromNodeID: "a", ToNodeID: "b", Type: "calls"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+
+	graphCommands := commands.NewGraphCommands(engine)
+	edgeCommands := commands.NewEdgeCommands(engine)
+
+	t.Run("Constraint", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"SET", string(graphID), "SELFLOOPS", "FORBID"},
+			{"SET", string(graphID), "SELFLOOPS", "ALLOW", "EDGETYPE", "retries"},
+		} {
+			if _, err := graphCommands.Handle("CONSTRAINT", args); err != nil {
+				t.Fatalf("GRAPH.CONSTRAINT %v failed: %v", args, err)
+			}
+		}
+
+		resp, err := graphCommands.Handle("CONSTRAINT", []string{"GET", string(graphID)})
+		if err != nil {
+			t.Fatalf("GRAPH.CONSTRAINT GET failed: %v", err)
+		}
+		expected := []string{"selfloops", "forbid", "selfloops:retries", "allow"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("RejectCreate", func(t *testing.T) {
+		_, err := edgeCommands.Handle("CREATE", []string{string(graphID), "b-b-calls", "b", "b", "calls"})
+		if err == nil || !strings.Contains(err.Error(), "self-loop") {
+			t.Fatalf("Expected self-loop rejection, got %v", err)
+		}
+		if _, err := engine.GetEdge(graphID, "b-b-calls"); err == nil {
+			t.Error("Expected rejected edge not to be stored")
+		}
+
+		// The edge type override still allows retries
+		if err := engine.CreateEdge(graphID, &models.Edge{ID: "a-a-retry", FromNodeID: "a", ToNodeID: "a", Type: "retries"}); err != nil {
+			t.Errorf("Expected self-loop of an allowed type to be created: %v", err)
+		}
+	})
+
+	t.Run("RejectUpdate", func(t *testing.T) {
+		edge, err := engine.GetEdge(graphID, "a-b")
+		if err != nil {
+			t.Fatalf("Failed to get edge: %v", err)
+		}
+		edge.ToNodeID = "a"
+		if err := engine.UpdateEdge(graphID, edge); err == nil || !strings.Contains(err.Error(), "self-loop") {
+			t.Errorf("Expected update creating a self-loop to be rejected, got %v", err)
+		}
+
+		retry, err := engine.GetEdge(graphID, "a-a-retry")
+		if err != nil {
+			t.Fatalf("Failed to get edge: %v", err)
+		}
+		retry.Type = "calls"
+		if err := engine.UpdateEdge(graphID, retry); err == nil {
+			t.Error("Expected moving a self-loop to a forbidden type to be rejected")
+		}
+		if _, err := engine.RenameEdgeType(graphID, "retries", "calls"); err == nil {
+			t.Error("Expected a rename into a forbidden type to be rejected")
+		}
+
+		// Pre-existing self-loops can still be edited
+		existing, err := engine.GetEdge(graphID, "a-a")
+		if err != nil {
+			t.Fatalf("Failed to get edge: %v", err)
+		}
+		existing.SetAttribute("note", "legacy")
+		if err := engine.UpdateEdge(graphID, existing); err != nil {
+			t.Errorf("Expected attribute update of an existing self-loop to succeed: %v", err)
+		}
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		resp, err := graphCommands.Handle("SELFLOOPS", []string{string(graphID)})
+		if err != nil {
+			t.Fatalf("GRAPH.SELFLOOPS failed: %v", err)
+		}
+		got := append([]string{}, resp.ArrayValue...)
+		sort.Strings(got)
+		expected := []string{"a-a-retry:retries", "a-a:calls", "b-b:retries"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+
+		resp, err = graphCommands.Handle("SELFLOOPS", []string{string(graphID), "DELETE"})
+		if err != nil {
+			t.Fatalf("GRAPH.SELFLOOPS DELETE failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 3 {
+			t.Errorf("Expected 3 deleted self-loops, got %v", resp.ArrayValue)
+		}
+
+		resp, err = graphCommands.Handle("SELFLOOPS", []string{string(graphID)})
+		if err != nil || len(resp.ArrayValue) != 0 {
+			t.Errorf("Expected no self-loops after DELETE, got %v, %v", resp, err)
+		}
+		edges, err := engine.ListEdges(graphID)
+		if err != nil || len(edges) != 1 || edges[0].ID != "a-b" {
+			t.Errorf("Expected only a-b to remain, got %v, %v", edges, err)
+		}
+	})
+
+	t.Run("IndexesCleaned", func(t *testing.T) {
+		engine.Close()
+		db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open badger: %v", err)
+		}
+		var leftovers []string
+		db.View(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+			for it.Rewind(); it.Valid(); it.Next() {
+				key := string(it.Item().Key())
+				// The deletion log records the deletions on purpose
+				if strings.HasPrefix(key, utils.DeletionLogPrefix) {
+					continue
+				}
+				for _, id := range []string{"a-a", "b-b", "a-a-retry"} {
+					if strings.HasSuffix(key, ":"+id) {
+						leftovers = append(leftovers, key)
+					}
+				}
+			}
+			return nil
+		})
+		db.Close()
+		if len(leftovers) > 0 {
+			t.Errorf("Expected no keys for deleted self-loops, got %v", leftovers)
+		}
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// startTestServer serves config on a free local port until the test ends
+func startTestServer(t *testing.T, engine storage.StorageEngine, config *redis.Config) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	config.LogLevel = "error"
+	server := redis.NewServer(config, engine)
+	go server.Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String()
+}
+
+// encodeCommand encodes a command as a RESP array of bulk strings
+func encodeCommand(args ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return b.String()
+}
+
+// readReply reads one RESP reply. Errors are returned with their leading
+// "-", arrays as their element count followed by the elements.
+func readReply(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+', ':':
+		return []string{line[1:]}, nil
+	case '-':
+		return []string{line}, nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return []string{"(nil)"}, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return []string{string(buf[:n])}, nil
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		reply := []string{strconv.Itoa(n)}
+		for i := 0; i < n; i++ {
+			item, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			reply = append(reply, item...)
+		}
+		return reply, nil
+	}
+	return nil, fmt.Errorf("unexpected reply: %q", line)
+}
+
+// TestServerCommandPool tests command execution through the bounded worker
+// pool
+func TestServerCommandPool(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_server_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	// Enumerating the cycles of a complete graph is slow enough to hold a
+	// worker while other commands arrive
+	slowGraph := models.GraphID("server-slow-graph")
+	createCompleteGraph(t, engine, slowGraph, 6)
+	slowCommand := encodeCommand("ANALYSIS.CYCLES", string(slowGraph))
+
+	t.Run("PipelineOrdering", func(t *testing.T) {
+		config := redis.DefaultConfig()
+		config.MaxConcurrentCommands = 4
+		config.CommandQueueSize = 16
+		addr := startTestServer(t, engine, config)
+
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+
+		pipeline := slowCommand
+		for i := 0; i < 100; i++ {
+			pipeline += encodeCommand("PING", strconv.Itoa(i))
+		}
+		if _, err := conn.Write([]byte(pipeline)); err != nil {
+			t.Fatalf("Failed to write pipeline: %v", err)
+		}
+
+		r := bufio.NewReader(conn)
+		reply, err := readReply(r)
+		if err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+		if n, _ := strconv.Atoi(reply[0]); n == 0 {
+			t.Fatalf("Expected cycles first, got %v", reply)
+		}
+		for i := 0; i < 100; i++ {
+			reply, err := readReply(r)
+			if err != nil {
+				t.Fatalf("Failed to read reply %d: %v", i, err)
+			}
+			if reply[0] != strconv.Itoa(i) {
+				t.Fatalf("Expected reply %d in order, got %v", i, reply)
+			}
+		}
+
+		if _, err := conn.Write([]byte(encodeCommand("INFO", "commandstats"))); err != nil {
+			t.Fatalf("Failed to write INFO: %v", err)
+		}
+		reply, err = readReply(r)
+		if err != nil {
+			t.Fatalf("Failed to read INFO: %v", err)
+		}
+		if !strings.Contains(reply[0], "cmdstat_ping:calls=100,") || !strings.Contains(reply[0], "queue_usec=") {
+			t.Errorf("Expected ping stats with queue time, got %q", reply[0])
+		}
+	})
+
+	t.Run("Busy", func(t *testing.T) {
+		config := redis.DefaultConfig()
+		config.MaxConcurrentCommands = 1
+		config.CommandQueueSize = 1
+		addr := startTestServer(t, engine, config)
+
+		const clients = 8
+		replies := make([]string, clients)
+		var wg sync.WaitGroup
+		for i := 0; i < clients; i++ {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+
+			wg.Add(1)
+			go func(i int, conn net.Conn) {
+				defer wg.Done()
+				if _, err := conn.Write([]byte(slowCommand)); err != nil {
+					replies[i] = err.Error()
+					return
+				}
+				reply, err := readReply(bufio.NewReader(conn))
+				if err != nil {
+					replies[i] = err.Error()
+					return
+				}
+				replies[i] = reply[0]
+			}(i, conn)
+		}
+		wg.Wait()
+
+		busy, done := 0, 0
+		for _, reply := range replies {
+			switch {
+			case reply == "-BUSY server overloaded, try later":
+				busy++
+			case strings.HasPrefix(reply, "-"):
+				t.Errorf("Unexpected error reply: %s", reply)
+			default:
+				done++
+			}
+		}
+		if busy == 0 || done == 0 {
+			t.Errorf("Expected both completed and BUSY replies, got %v", replies)
+		}
+		if done > 2 {
+			t.Errorf("Expected at most one running and one queued command, got %d completed", done)
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer ln.Close()
+		config := redis.DefaultConfig()
+		config.LogLevel = "error"
+		config.MaxConcurrentCommands = 2
+		server := redis.NewServer(config, engine)
+		go server.Serve(ln)
+
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if _, err := conn.Write([]byte(encodeCommand("PING"))); err != nil {
+			t.Fatalf("Failed to write PING: %v", err)
+		}
+		if reply, err := readReply(r); err != nil || reply[0] != "PONG" {
+			t.Fatalf("Expected PONG, got %v, %v", reply, err)
+		}
+
+		// Stop closes the command pool, so later commands are refused
+		server.Stop()
+		server.Stop()
+		if _, err := conn.Write([]byte(encodeCommand("PING"))); err != nil {
+			t.Fatalf("Failed to write PING: %v", err)
+		}
+		if reply, err := readReply(r); err != nil || reply[0] != "-ERR server is shutting down" {
+			t.Errorf("Expected the command to be refused after Stop, got %v, %v", reply, err)
+		}
+	})
+}
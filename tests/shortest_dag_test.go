@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestShortestPathDAG tests finding every edge on a shortest path on a
+// diamond with a tail, and the critical edges ANALYSIS.WHATIF reports
+func TestShortestPathDAG(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_shortest_dag_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+	analyzer := analysis.NewGraphAnalyzer(engine)
+
+	// The diamond a -> b|c -> d, its tail d -> e, a longer way round
+	// a -> x -> y -> d, and a dead end off b
+	graphID := models.GraphID("diamond")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "diamond"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for id, nodeType := range map[models.NodeID]models.NodeType{
+		"a": "gateway", "b": "service", "c": "service", "d": "service", "e": "database",
+		"x": "service", "y": "service", "z": "service",
+	} {
+		if err := engine.CreateNode(graphID, &models.Node{ID: id, Type: nodeType}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "a-b", FromNodeID: "a", ToNodeID: "b", Type: "calls"},
+		{ID: "a-c", FromNodeID: "a", ToNodeID: "c", Type: "calls"},
+		{ID: "b-d", FromNodeID: "b", ToNodeID: "d", Type: "calls"},
+		{ID: "c-d", FromNodeID: "c", ToNodeID: "d", Type: "calls"},
+		{ID: "d-e", FromNodeID: "d", ToNodeID: "e", Type: "reads"},
+		{ID: "a-x", FromNodeID: "a", ToNodeID: "x", Type: "calls"},
+		{ID: "x-y", FromNodeID: "x", ToNodeID: "y", Type: "calls"},
+		{ID: "y-d", FromNodeID: "y", ToNodeID: "d", Type: "calls"},
+		{ID: "b-z", FromNodeID: "b", ToNodeID: "z", Type: "calls"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	dag := func(t *testing.T, from, to models.NodeID, options *types.TraversalOptions) ([]models.NodeID, []models.EdgeID, *types.Subgraph) {
+		t.Helper()
+		result, err := analyzer.ShortestPathDAG(graphID, from, to, options)
+		if err != nil {
+			t.Fatalf("ShortestPathDAG failed: %v", err)
+		}
+		var nodes []models.NodeID
+		for _, node := range result.Nodes {
+			nodes = append(nodes, node.ID)
+		}
+		var edges []models.EdgeID
+		for _, edge := range result.Edges {
+			edges = append(edges, edge.ID)
+		}
+		return nodes, edges, result
+	}
+
+	t.Run("Diamond", func(t *testing.T) {
+		nodes, edges, result := dag(t, "a", "d", nil)
+		if expected := []models.EdgeID{"a-b", "a-c", "b-d", "c-d"}; !reflect.DeepEqual(edges, expected) {
+			t.Errorf("Expected the diamond's edges %v, got %v", expected, edges)
+		}
+		if expected := []models.NodeID{"a", "b", "c", "d"}; !reflect.DeepEqual(nodes, expected) {
+			t.Errorf("Expected the diamond's nodes %v, got %v", expected, nodes)
+		}
+		if result.Distance != 2 || len(result.Critical) != 0 {
+			t.Errorf("Expected distance 2 and no critical edges, got %d and %v", result.Distance, result.Critical)
+		}
+
+		// With the tail, its edge is on every shortest path
+		_, edges, result = dag(t, "a", "e", nil)
+		if expected := []models.EdgeID{"a-b", "a-c", "b-d", "c-d", "d-e"}; !reflect.DeepEqual(edges, expected) {
+			t.Errorf("Expected %v, got %v", expected, edges)
+		}
+		if expected := []models.EdgeID{"d-e"}; !reflect.DeepEqual(result.Critical, expected) {
+			t.Errorf("Expected critical edges %v, got %v", expected, result.Critical)
+		}
+
+		// Backward from e the same edges are found
+		_, edges, _ = dag(t, "e", "a", &types.TraversalOptions{Direction: types.DirectionBackward})
+		if expected := []models.EdgeID{"d-e", "b-d", "c-d", "a-b", "a-c"}; !reflect.DeepEqual(edges, expected) {
+			t.Errorf("Expected %v backward, got %v", expected, edges)
+		}
+
+		// Edge types exclude the tail
+		if _, err := analyzer.ShortestPathDAG(graphID, "a", "e", &types.TraversalOptions{EdgeTypes: []models.EdgeType{"calls"}}); !errors.Is(err, analysis.ErrNoPath) {
+			t.Errorf("Expected no path over calls edges, got %v", err)
+		}
+
+		nodes, edges, result = dag(t, "a", "a", nil)
+		if !reflect.DeepEqual(nodes, []models.NodeID{"a"}) || len(edges) != 0 || result.Distance != 0 {
+			t.Errorf("Expected a node alone from itself, got %v %v", nodes, edges)
+		}
+
+		if _, err := analyzer.ShortestPathDAG(graphID, "e", "a", nil); !errors.Is(err, analysis.ErrNoPath) {
+			t.Errorf("Expected ErrNoPath, got %v", err)
+		}
+		if _, err := analyzer.ShortestPathDAG(graphID, "a", "missing", nil); !errors.Is(err, storage.ErrNodeNotFound) {
+			t.Errorf("Expected ErrNodeNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		resp, err := handler.Handle("ANALYSIS.SHORTESTPATH", []string{"diamond", "a", "e", "DAG"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SHORTESTPATH DAG failed: %v", err)
+		}
+		expected := []interface{}{
+			[]string{"a:gateway", "b:service", "c:service", "d:service", "e:database"},
+			[]string{"a-b:calls", "a-c:calls", "b-d:calls", "c-d:calls", "d-e:reads"},
+		}
+		if !reflect.DeepEqual(resp.NestedArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.NestedArrayValue)
+		}
+
+		resp, err = handler.Handle("ANALYSIS.SHORTESTPATH", []string{"diamond", "a", "e", "dag", "FORMAT", "json"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SHORTESTPATH DAG FORMAT json failed: %v", err)
+		}
+		var decoded types.Subgraph
+		if err := json.Unmarshal([]byte(resp.StringValue), &decoded); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if decoded.Distance != 3 || len(decoded.Edges) != 5 || !reflect.DeepEqual(decoded.Critical, []models.EdgeID{"d-e"}) {
+			t.Errorf("Expected distance 3, 5 edges and d-e critical, got %+v", decoded)
+		}
+
+		for _, args := range [][]string{
+			{"diamond", "a", "e", "DAG", "COUNT"},
+			{"diamond", "a", "e", "DAG", "LABELS"},
+			{"diamond", "a", "e", "DAG", "PASSTHROUGH", "service"},
+			{"diamond", "e", "a", "DAG"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.SHORTESTPATH", args); err == nil {
+				t.Errorf("Expected ANALYSIS.SHORTESTPATH %v to fail", args)
+			}
+		}
+	})
+
+	t.Run("WhatIf", func(t *testing.T) {
+		// Without b-d every shortest path to d and e goes through c
+		critical, err := analyzer.WhatIfCriticalEdges(graphID, []models.NodeID{"a"}, []models.NodeID{"d", "e"}, &types.Overlay{
+			RemovedEdges: map[models.EdgeID]bool{"b-d": true},
+		})
+		if err != nil {
+			t.Fatalf("WhatIfCriticalEdges failed: %v", err)
+		}
+		if expected := []models.EdgeID{"a-c", "c-d", "d-e"}; !reflect.DeepEqual(critical, expected) {
+			t.Errorf("Expected %v, got %v", expected, critical)
+		}
+
+		resp, err := handler.Handle("ANALYSIS.WHATIF", []string{"diamond", "REMOVE", "EDGES", "d-e",
+			"SUMMARY", "FROMTYPE", "gateway", "TOTYPE", "service,database", "CRITICAL"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.WHATIF SUMMARY CRITICAL failed: %v", err)
+		}
+		expected := []string{"pairs", "7", "reachable_before", "7", "reachable_after", "6", "lost", "1", "a->e",
+			"critical", "5", "a-b", "a-c", "a-x", "b-z", "x-y"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		// Without CRITICAL the reply is unchanged
+		resp, err = handler.Handle("ANALYSIS.WHATIF", []string{"diamond", "REMOVE", "EDGES", "d-e",
+			"SUMMARY", "FROMTYPE", "gateway", "TOTYPE", "database"})
+		if err != nil || !reflect.DeepEqual(resp.ArrayValue, []string{"pairs", "1", "reachable_before", "1", "reachable_after", "0", "lost", "1", "a->e"}) {
+			t.Errorf("Expected the summary without critical edges, got %v, %v", resp.ArrayValue, err)
+		}
+	})
+}
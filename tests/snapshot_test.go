@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestGraphSnapshots tests GRAPH.SNAPSHOT CREATE, LIST, DIFF and DELETE
+func TestGraphSnapshots(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_snapshot_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine(storage.WithMaxSnapshots(3))
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("snapshot-test-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	te := &TestAnalysisEngine{engine: engine, graphID: graphID}
+	te.createSampleGraph()
+
+	graphCommands := commands.NewGraphCommands(engine)
+	nodeCommands := commands.NewNodeCommands(engine)
+	edgeCommands := commands.NewEdgeCommands(engine)
+
+	resp, err := graphCommands.Handle("SNAPSHOT", []string{"CREATE", string(graphID), "baseline"})
+	if err != nil {
+		t.Fatalf("GRAPH.SNAPSHOT CREATE failed: %v", err)
+	}
+	snapshotID := resp.StringValue
+
+	t.Run("List", func(t *testing.T) {
+		resp, err := graphCommands.Handle("SNAPSHOT", []string{"LIST", string(graphID)})
+		if err != nil {
+			t.Fatalf("GRAPH.SNAPSHOT LIST failed: %v", err)
+		}
+		values := resp.ArrayValue
+		if len(values) != 6 || values[0] != snapshotID || values[2] != "baseline" || values[3] != "6" || values[4] != "6" {
+			t.Fatalf("Unexpected snapshot listing: %v", values)
+		}
+		if values[5] == "0" {
+			t.Error("Expected a non-zero snapshot size")
+		}
+	})
+
+	t.Run("DiffUnchanged", func(t *testing.T) {
+		resp, err := graphCommands.Handle("SNAPSHOT", []string{"DIFF", string(graphID), snapshotID, "FORMAT", "full"})
+		if err != nil {
+			t.Fatalf("GRAPH.SNAPSHOT DIFF failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 0 {
+			t.Errorf("Expected no changes, got %v", resp.ArrayValue)
+		}
+	})
+
+	t.Run("DiffAfterMutations", func(t *testing.T) {
+		if _, err := nodeCommands.Handle("CREATE", []string{string(graphID), "metrics", "service"}); err != nil {
+			t.Fatalf("NODE.CREATE failed: %v", err)
+		}
+		if _, err := edgeCommands.Handle("CREATE", []string{string(graphID), "app-metrics", "app", "metrics", "depends_on"}); err != nil {
+			t.Fatalf("EDGE.CREATE failed: %v", err)
+		}
+		// Deleting queue also removes its edge to logger
+		if _, err := nodeCommands.Handle("DELETE", []string{string(graphID), "queue"}); err != nil {
+			t.Fatalf("NODE.DELETE failed: %v", err)
+		}
+
+		auth, err := engine.GetNode(graphID, "auth")
+		if err != nil {
+			t.Fatalf("Failed to get node: %v", err)
+		}
+		auth.SetAttribute("version", "2.0")
+		if err := engine.UpdateNode(graphID, auth); err != nil {
+			t.Fatalf("Failed to update node: %v", err)
+		}
+
+		edge, err := engine.GetEdge(graphID, "auth-db")
+		if err != nil {
+			t.Fatalf("Failed to get edge: %v", err)
+		}
+		edge.SetAttribute("pool", 10)
+		if err := engine.UpdateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to update edge: %v", err)
+		}
+
+		// Touching a node without changing its content is not a change
+		db, err := engine.GetNode(graphID, "db")
+		if err != nil {
+			t.Fatalf("Failed to get node: %v", err)
+		}
+		db.SetAttribute("name", "Database")
+		if err := engine.UpdateNode(graphID, db); err != nil {
+			t.Fatalf("Failed to update node: %v", err)
+		}
+
+		resp, err := graphCommands.Handle("SNAPSHOT", []string{"DIFF", string(graphID), snapshotID, "FORMAT", "full"})
+		if err != nil {
+			t.Fatalf("GRAPH.SNAPSHOT DIFF failed: %v", err)
+		}
+		expected := []string{
+			"+node:metrics", "-node:queue", "~node:auth",
+			"+edge:app-metrics", "-edge:queue-logger", "~edge:auth-db",
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		resp, err = graphCommands.Handle("SNAPSHOT", []string{"DIFF", string(graphID), snapshotID})
+		if err != nil {
+			t.Fatalf("GRAPH.SNAPSHOT DIFF failed: %v", err)
+		}
+		expected = []string{
+			"nodes_added", "1", "nodes_removed", "1", "nodes_changed", "1",
+			"edges_added", "1", "edges_removed", "1", "edges_changed", "1",
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		var created []string
+		for i := 0; i < 3; i++ {
+			resp, err := graphCommands.Handle("SNAPSHOT", []string{"CREATE", string(graphID), fmt.Sprintf("run-%d", i)})
+			if err != nil {
+				t.Fatalf("GRAPH.SNAPSHOT CREATE failed: %v", err)
+			}
+			created = append(created, resp.StringValue)
+		}
+
+		snapshots, err := engine.ListSnapshots(graphID)
+		if err != nil {
+			t.Fatalf("Failed to list snapshots: %v", err)
+		}
+		var ids []string
+		for _, snapshot := range snapshots {
+			ids = append(ids, snapshot.ID)
+		}
+		// The baseline snapshot is the oldest and is pruned first
+		if !reflect.DeepEqual(ids, created) {
+			t.Errorf("Expected snapshots %v after pruning, got %v", created, ids)
+		}
+		if _, err := engine.ReadSnapshot(graphID, snapshotID); err == nil {
+			t.Error("Expected pruned snapshot to be gone")
+		}
+	})
+
+	t.Run("DeleteFreesKeys", func(t *testing.T) {
+		snapshots, err := engine.ListSnapshots(graphID)
+		if err != nil {
+			t.Fatalf("Failed to list snapshots: %v", err)
+		}
+		for _, snapshot := range snapshots {
+			if _, err := graphCommands.Handle("SNAPSHOT", []string{"DELETE", string(graphID), snapshot.ID}); err != nil {
+				t.Fatalf("GRAPH.SNAPSHOT DELETE failed: %v", err)
+			}
+		}
+		if _, err := graphCommands.Handle("SNAPSHOT", []string{"DELETE", string(graphID), snapshots[0].ID}); err == nil {
+			t.Error("Expected deleting a missing snapshot to fail")
+		}
+
+		if err := engine.Close(); err != nil {
+			t.Fatalf("Failed to close database: %v", err)
+		}
+		db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open raw database: %v", err)
+		}
+		var leftover []string
+		db.View(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.IteratorOptions{PrefetchValues: false})
+			defer it.Close()
+			for it.Rewind(); it.Valid(); it.Next() {
+				key := string(it.Item().Key())
+				if strings.HasPrefix(key, "s:") || strings.HasPrefix(key, "sd:") {
+					leftover = append(leftover, key)
+				}
+			}
+			return nil
+		})
+		db.Close()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+
+		if len(leftover) != 0 {
+			t.Errorf("Expected snapshot keys to be deleted, found %v", leftover)
+		}
+	})
+}
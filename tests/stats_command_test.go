@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestAnalysisStats tests ANALYSIS.STATS on the microservices graph of the
+// integration tests
+func TestAnalysisStats(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_stats_command_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("microservices")
+	createMicroservicesGraph(t, engine, graphID)
+
+	// stats runs ANALYSIS.STATS and returns its fields, the per-type counts
+	// under node_types and edge_types
+	stats := func(t *testing.T, args ...string) map[string]interface{} {
+		t.Helper()
+		resp, err := handler.Handle("ANALYSIS.STATS", append([]string{string(graphID)}, args...))
+		if err != nil {
+			t.Fatalf("ANALYSIS.STATS %v failed: %v", args, err)
+		}
+		fields := make(map[string]interface{})
+		for i := 0; i+1 < len(resp.NestedArrayValue); i += 2 {
+			name, ok := resp.NestedArrayValue[i].(string)
+			if !ok {
+				t.Fatalf("Expected field %d to be a name, got %v", i, resp.NestedArrayValue[i])
+			}
+			fields[name] = resp.NestedArrayValue[i+1]
+		}
+		return fields
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		expected := map[string]interface{}{
+			"node_count":           "12",
+			"edge_count":           "16",
+			"root_node_count":      "1",
+			"leaf_node_count":      "5",
+			"orphan_node_count":    "0",
+			"has_cycles":           "false",
+			"max_depth":            "4",
+			"connected_components": "1",
+			"node_types":           []string{"application", "1", "cache", "1", "database", "2", "library", "1", "queue", "1", "service", "6"},
+			"edge_types":           []string{"depends_on", "16"},
+		}
+		if got := stats(t); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("EdgeTypes", func(t *testing.T) {
+		if err := engine.CreateEdge(graphID, &models.Edge{ID: "payment-order", Type: "calls", FromNodeID: "payment-service", ToNodeID: "order-service"}); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+		defer engine.DeleteEdge(graphID, "payment-order")
+
+		all := stats(t)
+		if all["has_cycles"] != "true" || all["edge_count"] != "17" || !reflect.DeepEqual(all["edge_types"], []string{"calls", "1", "depends_on", "16"}) {
+			t.Errorf("Expected the calls edge to close a cycle, got %v", all)
+		}
+		dependsOn := stats(t, "EDGETYPES", "depends_on")
+		if dependsOn["has_cycles"] != "false" || dependsOn["edge_count"] != "17" {
+			t.Errorf("Expected no cycle along depends_on edges and the whole graph counted, got %v", dependsOn)
+		}
+		calls := stats(t, "edgetypes", "calls")
+		if calls["orphan_node_count"] != "10" || calls["connected_components"] != "11" {
+			t.Errorf("Expected 10 orphans and 11 components along calls edges, got %v", calls)
+		}
+	})
+
+	t.Run("Server", func(t *testing.T) {
+		conn, err := net.Dial("tcp", startTestServer(t, engine, redis.DefaultConfig()))
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Write([]byte(encodeCommand("ANALYSIS.STATS", string(graphID)))); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		reply, err := readReply(bufio.NewReader(conn))
+		if err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+		// 16 field names and values, then node_types, its array of 12,
+		// edge_types and its array of 2
+		if len(reply) != 1+16+1+13+1+3 || reply[0] != "20" || reply[1] != "node_count" || reply[2] != "12" || reply[len(reply)-3] != "2" {
+			t.Errorf("Expected 10 fields with nested type counts, got %q", reply)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"missing"},
+			{string(graphID), "EDGETYPES"},
+			{string(graphID), "NODETYPES", "service"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.STATS", args); err == nil {
+				t.Errorf("Expected %v to fail", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestStatsHistory tests recording daily stats snapshots across simulated
+// days, reading them back with ANALYSIS.STATSHISTORY and pruning them past
+// the retention
+func TestStatsHistory(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_statshistory_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	engine := storage.NewBadgerEngine(storage.WithClock(func() time.Time { return now }))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+	recorder := analysis.NewStatsHistoryRecorder(engine, time.Hour, 3, nil)
+
+	run := func(t *testing.T, command string, args ...string) {
+		t.Helper()
+		if _, err := handler.Handle(command, args); err != nil {
+			t.Fatalf("%s %v failed: %v", command, args, err)
+		}
+	}
+	history := func(t *testing.T, args ...string) []interface{} {
+		t.Helper()
+		resp, err := handler.Handle("ANALYSIS.STATSHISTORY", append([]string{"infra"}, args...))
+		if err != nil {
+			t.Fatalf("ANALYSIS.STATSHISTORY failed: %v", err)
+		}
+		return resp.NestedArrayValue
+	}
+
+	run(t, "GRAPH.CREATE", "infra")
+	run(t, "NODE.CREATE", "infra", "api", "service")
+	run(t, "NODE.CREATE", "infra", "db", "database")
+
+	// Day 1: two unconnected nodes
+	if err := recorder.Record(); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	// Day 2: connected, then a second run later the same day
+	now = now.AddDate(0, 0, 1)
+	run(t, "EDGE.CREATE", "infra", "reads", "api", "db", "reads")
+	if err := recorder.Record(); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	now = now.Add(6 * time.Hour)
+	run(t, "NODE.CREATE", "infra", "cache", "cache")
+	if err := recorder.Record(); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	// Day 3: a cycle
+	now = now.AddDate(0, 0, 1)
+	run(t, "EDGE.CREATE", "infra", "notifies", "db", "api", "notifies")
+
+	t.Run("Series", func(t *testing.T) {
+		if err := recorder.Record(); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		rows := history(t)
+		want := [][]string{
+			{"2024-06-01", "node_count", "2", "edge_count", "0", "has_cycles", "false", "connected_components", "2",
+				"node_type:database", "1", "node_type:service", "1"},
+			{"2024-06-02", "node_count", "3", "edge_count", "1", "has_cycles", "false", "connected_components", "2",
+				"node_type:cache", "1", "node_type:database", "1", "node_type:service", "1", "edge_type:reads", "1"},
+			{"2024-06-03", "node_count", "3", "edge_count", "2", "has_cycles", "true", "connected_components", "2",
+				"node_type:cache", "1", "node_type:database", "1", "node_type:service", "1", "edge_type:notifies", "1", "edge_type:reads", "1"},
+		}
+		if len(rows) != len(want) {
+			t.Fatalf("Expected one snapshot per day, got %d: %v", len(rows), rows)
+		}
+		for i, row := range rows {
+			if !reflect.DeepEqual(row, want[i]) {
+				t.Errorf("Expected day %d to be %v, got %v", i+1, want[i], row)
+			}
+		}
+
+		// DAYS counts back from today
+		if rows := history(t, "DAYS", "2"); len(rows) != 2 || rows[0].([]string)[0] != "2024-06-02" {
+			t.Errorf("Expected the last 2 days, got %v", rows)
+		}
+	})
+
+	t.Run("Idempotent", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if err := recorder.Record(); err != nil {
+				t.Fatalf("Record failed: %v", err)
+			}
+		}
+		if rows := history(t); len(rows) != 3 {
+			t.Errorf("Expected rerunning the same day not to add snapshots, got %d", len(rows))
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		resp, err := handler.Handle("ANALYSIS.STATSHISTORY", []string{"infra", "FORMAT", "json"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.STATSHISTORY FORMAT json failed: %v", err)
+		}
+		var snapshots []models.StatsSnapshot
+		if err := json.Unmarshal([]byte(resp.StringValue), &snapshots); err != nil {
+			t.Fatalf("Failed to parse JSON: %v", err)
+		}
+		if len(snapshots) != 3 || snapshots[1].Date != "2024-06-02" || snapshots[1].NodeTypeCount["cache"] != 1 || !snapshots[2].HasCycles {
+			t.Errorf("Expected the series as JSON, got %+v", snapshots)
+		}
+		// The later run of day 2 replaced the earlier one
+		if recorded := snapshots[1].RecordedAt; recorded.Hour() != 15 {
+			t.Errorf("Expected day 2's last run to be kept, got one recorded at %v", recorded)
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		// Day 5 keeps days 3 to 5, skipping day 4, which was not recorded
+		now = now.AddDate(0, 0, 2)
+		run(t, "NODE.DELETE", "infra", "cache")
+		if err := recorder.Record(); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		rows := history(t, "DAYS", "30")
+		if len(rows) != 2 || rows[0].([]string)[0] != "2024-06-03" || rows[1].([]string)[0] != "2024-06-05" {
+			t.Fatalf("Expected days 3 and 5 after pruning, got %v", rows)
+		}
+		if count := rows[1].([]string)[2]; count != "2" {
+			t.Errorf("Expected 2 nodes on day 5, got %s", count)
+		}
+
+		audit, err := engine.AuditKeys("sh:")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		if audit.Families["sh"] != 2 {
+			t.Errorf("Expected the pruned snapshots to be deleted, got %d keys", audit.Families["sh"])
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"missing"},
+			{"infra", "DAYS", "0"},
+			{"infra", "DAYS"},
+			{"infra", "FORMAT", "csv"},
+			{"infra", "TOP", "3"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.STATSHISTORY", args); err == nil {
+				t.Errorf("Expected ANALYSIS.STATSHISTORY %v to fail", args)
+			}
+		}
+	})
+
+	t.Run("Scheduler", func(t *testing.T) {
+		// Day 6 keeps days 4 to 6
+		now = now.AddDate(0, 0, 1)
+		scheduled := analysis.NewStatsHistoryRecorder(engine, time.Hour, 3, nil)
+		scheduled.Start()
+		latest := func() string {
+			rows := history(t)
+			return rows[len(rows)-1].([]string)[0]
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for latest() != "2024-06-06" && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		scheduled.Stop()
+		if rows := history(t); len(rows) != 2 || rows[0].([]string)[0] != "2024-06-05" || rows[1].([]string)[0] != "2024-06-06" {
+			t.Errorf("Expected the scheduler to record a snapshot when started, got %v", rows)
+		}
+	})
+
+	t.Run("Graph Deletion", func(t *testing.T) {
+		if _, err := engine.DeleteGraph("infra"); err != nil {
+			t.Fatalf("DeleteGraph failed: %v", err)
+		}
+		audit, err := engine.AuditKeys("sh:")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		if audit.Keys != 0 {
+			t.Errorf("Expected deleting the graph to delete its stats history, got %d keys", audit.Keys)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -134,7 +135,7 @@ func TestGraphCRUD(t *testing.T) {
 
 	// Test DeleteGraph
 	t.Run("DeleteGraph", func(t *testing.T) {
-		err := te.engine.DeleteGraph("test-graph-2")
+		_, err := te.engine.DeleteGraph("test-graph-2")
 		if err != nil {
 			t.Errorf("Failed to delete graph: %v", err)
 		}
@@ -146,7 +147,7 @@ func TestGraphCRUD(t *testing.T) {
 		}
 		
 		// Try to delete non-existent graph (storage engine handles gracefully)
-		err = te.engine.DeleteGraph("non-existent")
+		_, err = te.engine.DeleteGraph("non-existent")
 		if err != nil {
 			t.Errorf("Unexpected error when deleting non-existent graph: %v", err)
 		}
@@ -162,7 +163,7 @@ func TestGraphCRUD(t *testing.T) {
 		te.engine.CreateNode(graphWithContentID, node)
 
 		// Delete the graph
-		err := te.engine.DeleteGraph(graphWithContentID)
+		_, err := te.engine.DeleteGraph(graphWithContentID)
 		if err != nil {
 			t.Fatalf("Failed to delete graph with content: %v", err)
 		}
@@ -801,6 +802,182 @@ func TestTTL(t *testing.T) {
 			t.Fatal("Node F should still exist")
 		}
 	})
+
+	t.Run("ExpiredWhileClosed", func(t *testing.T) {
+		nodeID := models.NodeID("ttl-node-offline")
+		expiresAt := time.Now().Add(1 * time.Second)
+		node := &models.Node{ID: nodeID, Type: "service", ExpiresAt: &expiresAt}
+
+		if err := te.engine.CreateNode(te.graphID, node); err != nil {
+			t.Fatalf("Failed to create node with TTL: %v", err)
+		}
+
+		if err := te.engine.Close(); err != nil {
+			t.Fatalf("Failed to close engine: %v", err)
+		}
+		time.Sleep(2 * time.Second)
+		if err := te.engine.Open(te.testPath); err != nil {
+			t.Fatalf("Failed to reopen engine: %v", err)
+		}
+
+		// No Cleanup call: the lazy read-path check must hide the node.
+		if _, err := te.engine.GetNode(te.graphID, nodeID); err == nil {
+			t.Fatal("Expired node should not be served after reopening")
+		}
+
+		nodes, err := te.engine.ListNodes(te.graphID)
+		if err != nil {
+			t.Fatalf("Failed to list nodes: %v", err)
+		}
+		for _, n := range nodes {
+			if n.ID == nodeID {
+				t.Fatal("Expired node should not be listed after reopening")
+			}
+		}
+	})
+}
+
+// TestTTLRefreshedBeforeDelete tests that nodes found expired on the read
+// path are not deleted if they were refreshed or recreated before the TTL
+// manager got to them
+func TestTTLRefreshedBeforeDelete(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_ttl_refresh_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	// Offline, no TTL manager runs, so queued deletions wait for Cleanup
+	engine := storage.NewBadgerEngine(storage.WithOffline(false))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	graphID := models.GraphID("ttl-refresh")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "ttl-refresh"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	for _, node := range []*models.Node{
+		{ID: "refreshed", Type: "service", ExpiresAt: &past},
+		{ID: "recreated", Type: "service", ExpiresAt: &past},
+		{ID: "expired", Type: "service", ExpiresAt: &past},
+		{ID: "target", Type: "service"},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+
+	// Reading the expired entities queues them for deletion
+	for _, nodeID := range []models.NodeID{"refreshed", "recreated", "expired"} {
+		if _, err := engine.GetNode(graphID, nodeID); !errors.Is(err, storage.ErrNodeNotFound) {
+			t.Fatalf("Expected %s to be hidden as expired, got %v", nodeID, err)
+		}
+	}
+
+	// Before the queue is processed, two of the nodes come back
+	if err := engine.UpdateNode(graphID, &models.Node{ID: "refreshed", Type: "service", ExpiresAt: &future}); err != nil {
+		t.Fatalf("Failed to refresh node: %v", err)
+	}
+	if err := engine.DeleteNode(graphID, "recreated"); err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+	if err := engine.CreateNode(graphID, &models.Node{ID: "recreated", Type: "service"}); err != nil {
+		t.Fatalf("Failed to recreate node: %v", err)
+	}
+
+	engine.Cleanup()
+
+	for _, nodeID := range []models.NodeID{"refreshed", "recreated"} {
+		if _, err := engine.GetNode(graphID, nodeID); err != nil {
+			t.Errorf("Expected %s to survive the queued deletion, got %v", nodeID, err)
+		}
+	}
+	if nodes, err := engine.ListNodesByType(graphID, "service"); err != nil || len(nodes) != 3 {
+		t.Errorf("Expected the expired node deleted and 3 nodes listed, got %d, %v", len(nodes), err)
+	}
+	if count, err := engine.CountNodes(graphID); err != nil || count != 3 {
+		t.Errorf("Expected the still expired node to be deleted, got %d nodes, %v", count, err)
+	}
+}
+
+// TestEdgeTTLIndexes tests that the TTL sweep finds expired edges through
+// the edge expiry index and deletes them with their type and node indexes
+func TestEdgeTTLIndexes(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_edge_ttl_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	// Offline, no TTL manager runs, so expired edges wait for Cleanup
+	engine := storage.NewBadgerEngine(storage.WithOffline(false))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	graphID := models.GraphID("edge-ttl")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "edge-ttl"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, nodeID := range []models.NodeID{"a", "b"} {
+		if err := engine.CreateNode(graphID, &models.Node{ID: nodeID, Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+
+	// keys counts the graph's keys of a family
+	keys := func(family string) int64 {
+		t.Helper()
+		audit, err := engine.AuditKeys("")
+		if err != nil {
+			t.Fatalf("AuditKeys failed: %v", err)
+		}
+		return audit.Graphs[graphID][family]
+	}
+
+	// short expires as created, later once updated with a TTL, and kept
+	// has its TTL removed by an update
+	expiresAt := time.Now().Add(1 * time.Second)
+	for _, edge := range []*models.Edge{
+		{ID: "short", FromNodeID: "a", ToNodeID: "b", Type: "calls", ExpiresAt: &expiresAt},
+		{ID: "later", FromNodeID: "b", ToNodeID: "a", Type: "reads"},
+		{ID: "kept", FromNodeID: "a", ToNodeID: "a", Type: "pings", ExpiresAt: &expiresAt},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge %s: %v", edge.ID, err)
+		}
+	}
+	if err := engine.UpdateEdge(graphID, &models.Edge{ID: "later", FromNodeID: "b", ToNodeID: "a", Type: "reads", ExpiresAt: &expiresAt}); err != nil {
+		t.Fatalf("Failed to update edge: %v", err)
+	}
+	if err := engine.UpdateEdge(graphID, &models.Edge{ID: "kept", FromNodeID: "a", ToNodeID: "a", Type: "pings"}); err != nil {
+		t.Fatalf("Failed to update edge: %v", err)
+	}
+	for family, expected := range map[string]int64{"e": 3, "ti:e": 3, "ni:out": 3, "ni:in": 3, "xe": 2} {
+		if count := keys(family); count != expected {
+			t.Errorf("Expected %d %s keys before expiry, got %d", expected, family, count)
+		}
+	}
+
+	// No read queues the edges, so the sweep must find them in the index
+	time.Sleep(2 * time.Second)
+	engine.Cleanup()
+
+	for family, expected := range map[string]int64{"e": 1, "ti:e": 1, "ni:out": 1, "ni:in": 1, "xe": 0} {
+		if count := keys(family); count != expected {
+			t.Errorf("Expected %d %s keys after cleanup, got %d", expected, family, count)
+		}
+	}
+	if edges, err := engine.GetOutgoingEdges(graphID, "a"); err != nil || len(edges) != 1 || edges[0].ID != "kept" {
+		t.Errorf("Expected only kept to leave a, got %v, %v", edges, err)
+	}
+	if edges, err := engine.ListEdgesByType(graphID, "calls"); err != nil || len(edges) != 0 {
+		t.Errorf("Expected no calls edges, got %v, %v", edges, err)
+	}
+	if audit, err := engine.AuditKeys(""); err != nil || len(audit.Mismatches) != 0 {
+		t.Errorf("Expected the edge indexes to match the edges, got %+v, %v", audit, err)
+	}
 }
 
 func TestErrorHandling(t *testing.T) {
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// subscribeClient is a connection to the test server with its reader
+type subscribeClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialSubscribeClient connects to the test server at addr
+func dialSubscribeClient(t *testing.T, addr string) *subscribeClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &subscribeClient{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// do sends a command and returns its reply
+func (c *subscribeClient) do(t *testing.T, args ...string) []string {
+	t.Helper()
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write([]byte(encodeCommand(args...))); err != nil {
+		t.Fatalf("Failed to write %v: %v", args, err)
+	}
+	return c.read(t)
+}
+
+// read reads the next reply or message
+func (c *subscribeClient) read(t *testing.T) []string {
+	t.Helper()
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reply, err := readReply(c.reader)
+	if err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	return reply
+}
+
+// readEvent reads the next message and returns its event
+func (c *subscribeClient) readEvent(t *testing.T) storage.Event {
+	t.Helper()
+	reply := c.read(t)
+	if len(reply) != 4 || reply[0] != "3" || reply[1] != "message" {
+		t.Fatalf("Expected a message, got %q", reply)
+	}
+	var event storage.Event
+	if err := json.Unmarshal([]byte(reply[3]), &event); err != nil {
+		t.Fatalf("Failed to decode event %q: %v", reply[3], err)
+	}
+	if string(event.Graph) != reply[2] {
+		t.Errorf("Expected the message of graph %s to carry it, got %+v", reply[2], event)
+	}
+	return event
+}
+
+// TestGraphSubscribe tests that a connection subscribed with GRAPH.SUBSCRIBE
+// receives the node and edge writes another connection makes, and that
+// AddEventListener reports the writes of committed transactions only
+func TestGraphSubscribe(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_subscribe_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	for _, graphID := range []models.GraphID{"watched", "other"} {
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+	}
+
+	t.Run("Listener", func(t *testing.T) {
+		var events []storage.Event
+		remove := engine.AddEventListener(func(committed []storage.Event) {
+			events = append(events, committed...)
+		})
+		engine.CreateNode("other", &models.Node{ID: "a", Type: "service"})
+		engine.CreateNode("other", &models.Node{ID: "b", Type: "service"})
+		engine.CreateEdge("other", &models.Edge{ID: "ab", Type: "calls", FromNodeID: "a", ToNodeID: "b"})
+		// The failed transaction publishes nothing
+		engine.RunTransaction(func(tx storage.Transaction) error {
+			tx.CreateNode("other", &models.Node{ID: "c", Type: "service"})
+			return storage.ErrNodeNotFound
+		})
+		engine.DeleteNode("other", "a")
+		remove()
+		engine.CreateNode("other", &models.Node{ID: "d", Type: "service"})
+
+		expected := []storage.Event{
+			{Graph: "other", Entity: "node", ID: "a", Type: "service", Op: storage.EventCreated},
+			{Graph: "other", Entity: "node", ID: "b", Type: "service", Op: storage.EventCreated},
+			{Graph: "other", Entity: "edge", ID: "ab", Type: "calls", Op: storage.EventCreated},
+			{Graph: "other", Entity: "node", ID: "a", Type: "service", Op: storage.EventDeleted},
+			{Graph: "other", Entity: "edge", ID: "ab", Type: "calls", Op: storage.EventDeleted},
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("Expected events %+v, got %+v", expected, events)
+		}
+	})
+
+	addr := startTestServer(t, engine, redis.DefaultConfig())
+
+	t.Run("Subscribe", func(t *testing.T) {
+		subscriber := dialSubscribeClient(t, addr)
+		writer := dialSubscribeClient(t, addr)
+
+		if got, expected := subscriber.do(t, "GRAPH.SUBSCRIBE", "watched"), []string{"1", "3", "subscribe", "watched", "1"}; !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Expected %q, got %q", expected, got)
+		}
+
+		// Writes to another graph are not delivered, so the first message
+		// is the node created in the watched graph
+		writer.do(t, "NODE.CREATE", "other", "skipped", "service")
+		writer.do(t, "NODE.CREATE", "watched", "api", "service", `{"port":8080}`)
+		if got, expected := subscriber.readEvent(t), (storage.Event{Graph: "watched", Entity: "node", ID: "api", Type: "service", Op: storage.EventCreated}); got != expected {
+			t.Errorf("Expected %+v, got %+v", expected, got)
+		}
+
+		writer.do(t, "NODE.UPDATE", "watched", "api", "TYPE", "gateway")
+		writer.do(t, "NODE.CREATE", "watched", "db", "database")
+		writer.do(t, "EDGE.CREATE", "watched", "api-db", "api", "db", "reads")
+		writer.do(t, "NODE.DELETE", "watched", "db")
+		for _, expected := range []storage.Event{
+			{Graph: "watched", Entity: "node", ID: "api", Type: "gateway", Op: storage.EventUpdated},
+			{Graph: "watched", Entity: "node", ID: "db", Type: "database", Op: storage.EventCreated},
+			{Graph: "watched", Entity: "edge", ID: "api-db", Type: "reads", Op: storage.EventCreated},
+			{Graph: "watched", Entity: "node", ID: "db", Type: "database", Op: storage.EventDeleted},
+			{Graph: "watched", Entity: "edge", ID: "api-db", Type: "reads", Op: storage.EventDeleted},
+		} {
+			if got := subscriber.readEvent(t); got != expected {
+				t.Errorf("Expected %+v, got %+v", expected, got)
+			}
+		}
+
+		// Only the subscription commands and PING run while subscribed
+		if got := subscriber.do(t, "NODE.LIST", "watched"); len(got) != 1 || got[0][0] != '-' {
+			t.Errorf("Expected NODE.LIST to be refused while subscribed, got %q", got)
+		}
+		if got, expected := subscriber.do(t, "PING"), []string{"2", "pong", ""}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+
+		if got, expected := subscriber.do(t, "GRAPH.UNSUBSCRIBE"), []string{"1", "3", "unsubscribe", "watched", "0"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+		writer.do(t, "NODE.CREATE", "watched", "unseen", "service")
+		if got, expected := subscriber.do(t, "PING"), []string{"PONG"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %q after unsubscribing, with no message before it, got %q", expected, got)
+		}
+		if got := subscriber.do(t, "NODE.GET", "watched", "unseen"); len(got) == 0 || got[0][0] == '-' {
+			t.Errorf("Expected commands to run again after unsubscribing, got %q", got)
+		}
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		if err := engine.CreateGraph(&models.Graph{ID: "flood", Name: "flood"}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer ln.Close()
+		capture := newCaptureHandler()
+		go redis.NewServer(redis.DefaultConfig(), engine, redis.WithLogger(slog.New(capture))).Serve(ln)
+
+		// The subscriber reads nothing after subscribing, so its backlog
+		// fills within the first transaction
+		subscriber := dialSubscribeClient(t, ln.Addr().String())
+		subscriber.do(t, "GRAPH.SUBSCRIBE", "flood")
+		for batch := 0; batch < 3; batch++ {
+			err := engine.RunTransaction(func(tx storage.Transaction) error {
+				for i := 0; i < 3000; i++ {
+					if err := tx.CreateNode("flood", &models.Node{ID: models.NodeID(fmt.Sprintf("n%d-%d", batch, i)), Type: "service"}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to write batch %d: %v", batch, err)
+			}
+		}
+
+		warnings := 0
+		capture.mu.Lock()
+		for _, rec := range *capture.records {
+			if rec.message == "closing subscriber that fell behind" {
+				warnings++
+			}
+		}
+		capture.mu.Unlock()
+		if warnings != 1 {
+			t.Errorf("Expected the subscriber to be closed once, got %d warnings", warnings)
+		}
+
+		// The messages written before the close are followed by the end of
+		// the connection
+		subscriber.conn.SetDeadline(time.Now().Add(5 * time.Second))
+		for {
+			if _, err := readReply(subscriber.reader); err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					t.Errorf("Expected the connection to be closed, got %v", err)
+				}
+				break
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		client := dialSubscribeClient(t, addr)
+		for _, args := range [][]string{
+			{"GRAPH.SUBSCRIBE"},
+			{"GRAPH.SUBSCRIBE", "watched", "missing"},
+		} {
+			if got := client.do(t, args...); len(got) != 1 || got[0][0] != '-' {
+				t.Errorf("Expected %v to fail, got %q", args, got)
+			}
+		}
+		// Neither graph was subscribed to, so commands still run
+		if got, expected := client.do(t, "PING"), []string{"PONG"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+
+		// The command handler runs without a connection to deliver to
+		if _, err := redis.NewCommandHandler(engine).Handle("GRAPH.SUBSCRIBE", []string{"watched"}); err == nil {
+			t.Error("Expected GRAPH.SUBSCRIBE to fail without a connection")
+		}
+	})
+}
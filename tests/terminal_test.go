@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestTerminalReasons tests that every path of AllPathsTraversal says why it
+// ended
+func TestTerminalReasons(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_terminal_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+
+	graphID := models.GraphID("terminal")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "terminal"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "s", Type: "service"},
+		{ID: "a", Type: "service"},
+		{ID: "b", Type: "service"},
+		{ID: "c", Type: "service"},
+		{ID: "d", Type: "service"},
+		{ID: "e", Type: "service"},
+		{ID: "f", Type: "service"},
+		{ID: "x", Type: "gateway"},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	// From s: a is a leaf, b leads 3 deep to d, e leads back to s, and f
+	// leads to the gateway x
+	for _, edge := range []*models.Edge{
+		{ID: "e1", Type: "calls", FromNodeID: "s", ToNodeID: "a"},
+		{ID: "e2", Type: "calls", FromNodeID: "s", ToNodeID: "b"},
+		{ID: "e3", Type: "calls", FromNodeID: "b", ToNodeID: "c"},
+		{ID: "e4", Type: "calls", FromNodeID: "c", ToNodeID: "d"},
+		{ID: "e5", Type: "calls", FromNodeID: "s", ToNodeID: "e"},
+		{ID: "e6", Type: "calls", FromNodeID: "e", ToNodeID: "s"},
+		{ID: "e7", Type: "calls", FromNodeID: "s", ToNodeID: "f"},
+		{ID: "e8", Type: "calls", FromNodeID: "f", ToNodeID: "x"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	terminals := func(t *testing.T, options *types.TraversalOptions) map[string]types.TerminalReason {
+		t.Helper()
+		paths, err := analyzer.AllPathsTraversal(graphID, "s", options)
+		if err != nil {
+			t.Fatalf("AllPathsTraversal failed: %v", err)
+		}
+		got := make(map[string]types.TerminalReason)
+		for _, path := range paths {
+			ids := make([]string, len(path.Path))
+			for i, nodeID := range path.Path {
+				ids[i] = string(nodeID)
+			}
+			key := strings.Join(ids, ">")
+			if _, seen := got[key]; seen {
+				t.Errorf("Path %s was returned twice", key)
+			}
+			got[key] = path.Terminal
+		}
+		return got
+	}
+
+	t.Run("Leaf Depth And Cycle", func(t *testing.T) {
+		got := terminals(t, &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: 2})
+		expected := map[string]types.TerminalReason{
+			"s>a":   types.TerminalLeaf,
+			"s>b>c": types.TerminalMaxDepth,
+			"s>e>s": types.TerminalCycle,
+			"s>f>x": types.TerminalLeaf,
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Unlimited Depth", func(t *testing.T) {
+		got := terminals(t, &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: -1})
+		if got["s>b>c>d"] != types.TerminalLeaf || got["s>e>s"] != types.TerminalCycle {
+			t.Errorf("Expected d to end a leaf path and s a cycle, got %v", got)
+		}
+		for key, reason := range got {
+			if reason == types.TerminalMaxDepth {
+				t.Errorf("Expected no depth-limited path without a limit, got %s", key)
+			}
+		}
+	})
+
+	t.Run("Stop Condition", func(t *testing.T) {
+		got := terminals(t, &types.TraversalOptions{
+			Direction:     types.DirectionForward,
+			MaxDepth:      -1,
+			StopCondition: func(node *models.Node) bool { return node.Type == "gateway" },
+		})
+		if got["s>f"] != types.TerminalStopCondition {
+			t.Errorf("Expected the path to end before the gateway, got %v", got)
+		}
+		if _, ok := got["s>f>x"]; ok {
+			t.Errorf("Expected the gateway to be left out, got %v", got)
+		}
+	})
+
+	t.Run("Traverse", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+		resp, err := handler.Handle("ANALYSIS.TRAVERSE", []string{"terminal", "s", "terminal"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		got := append([]string(nil), resp.ArrayValue...)
+		sort.Strings(got)
+		expected := []string{
+			"s:service->e1:calls->a:service|leaf",
+			"s:service->e2:calls->b:service->e3:calls->c:service->e4:calls->d:service|leaf",
+			"s:service->e5:calls->e:service->e6:calls->s:service|cycle",
+			"s:service->e7:calls->f:service->e8:calls->x:gateway|leaf",
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+
+		// Without TERMINAL, paths carry no suffix
+		resp, err = handler.Handle("ANALYSIS.TRAVERSE", []string{"terminal", "s"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		for _, path := range resp.ArrayValue {
+			if strings.Contains(path, "|") {
+				t.Errorf("Expected no terminal reason without TERMINAL, got %s", path)
+			}
+		}
+
+		if _, err := handler.Handle("ANALYSIS.TRAVERSE", []string{"terminal", "s", "FORMAT", "simple", "TERMINAL"}); err == nil {
+			t.Error("Expected TERMINAL to require FORMAT detailed")
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestTopologicalSort tests ordering nodes by their edges with
+// TopologicalSort and ANALYSIS.TOPOSORT
+func TestTopologicalSort(t *testing.T) {
+	// levels writes an order as id:level
+	levels := func(order []types.TopologicalEntry) []string {
+		var result []string
+		for _, entry := range order {
+			result = append(result, fmt.Sprintf("%s:%d", entry.Node.ID, entry.Level))
+		}
+		return result
+	}
+
+	t.Run("Order", func(t *testing.T) {
+		analyzer, graphID := loadFixture(t, sampleEdgeList)
+		order, err := analyzer.TopologicalSort(graphID, nil)
+		if err != nil {
+			t.Fatalf("TopologicalSort failed: %v", err)
+		}
+		if expected := []string{"app:0", "queue:0", "auth:1", "cache:2", "db:2", "logger:2"}; !reflect.DeepEqual(levels(order), expected) {
+			t.Errorf("Expected %v, got %v", expected, levels(order))
+		}
+
+		// logger is reached from app directly, but after auth
+		position := make(map[models.NodeID]int)
+		for i, entry := range order {
+			position[entry.Node.ID] = i
+		}
+		for _, edge := range [][2]models.NodeID{{"app", "auth"}, {"app", "logger"}, {"auth", "logger"}, {"auth", "db"}, {"queue", "logger"}} {
+			if position[edge[0]] >= position[edge[1]] {
+				t.Errorf("Expected %s before %s, got %v", edge[0], edge[1], levels(order))
+			}
+		}
+
+		empty, graphID := loadFixture(t, "")
+		if order, err := empty.TopologicalSort(graphID, nil); err != nil || len(order) != 0 {
+			t.Errorf("Expected an empty order, got %v, %v", levels(order), err)
+		}
+	})
+
+	t.Run("Cycles", func(t *testing.T) {
+		// d and y are not on a cycle, so are not named
+		analyzer, graphID := loadFixture(t, "a -> b -> c -> a, c -> d, x -> x, y -> a, z")
+		_, err := analyzer.TopologicalSort(graphID, nil)
+		if !errors.Is(err, analysis.ErrCycleDetected) {
+			t.Fatalf("Expected ErrCycleDetected, got %v", err)
+		}
+		if !strings.HasSuffix(err.Error(), "nodes a, b, c, x are on cycles") {
+			t.Errorf("Expected the error to name a, b, c and x, got %v", err)
+		}
+	})
+
+	t.Run("EdgeTypes", func(t *testing.T) {
+		// The cycle is of calls edges only
+		analyzer, graphID := loadFixture(t, "a:service -[calls]-> b:service -[calls]-> a, b -[writes_to]-> c:database")
+		if _, err := analyzer.TopologicalSort(graphID, nil); !errors.Is(err, analysis.ErrCycleDetected) {
+			t.Errorf("Expected ErrCycleDetected, got %v", err)
+		}
+		order, err := analyzer.TopologicalSort(graphID, &types.TraversalOptions{EdgeTypes: []models.EdgeType{"writes_to"}})
+		if expected := []string{"a:0", "b:0", "c:1"}; err != nil || !reflect.DeepEqual(levels(order), expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, levels(order), err)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		testPath := filepath.Join(os.TempDir(), "pathwaydb_toposort_test")
+		os.RemoveAll(testPath)
+		defer os.RemoveAll(testPath)
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+		handler := redis.NewCommandHandler(engine)
+
+		graphID := models.GraphID("microservices")
+		createMicroservicesGraph(t, engine, graphID)
+
+		resp, err := handler.Handle("ANALYSIS.TOPOSORT", []string{string(graphID)})
+		expected := []string{
+			"frontend:application:0",
+			"api-gateway:service:1",
+			"auth-service:service:2", "order-service:service:2", "user-service:service:2",
+			"notification-service:service:3", "order-db:database:3", "payment-service:service:3", "redis-cache:cache:3", "user-db:database:3",
+			"logger:library:4", "message-queue:queue:4",
+		}
+		if err != nil || !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, resp, err)
+		}
+
+		replica := &models.Edge{ID: "userdb-user", Type: "replicates", FromNodeID: "user-db", ToNodeID: "user-service", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := engine.CreateEdge(graphID, replica); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+		if _, err := handler.Handle("ANALYSIS.TOPOSORT", []string{string(graphID)}); err == nil || !strings.Contains(err.Error(), "nodes user-db, user-service are on cycles") {
+			t.Errorf("Expected the cycle to be named, got %v", err)
+		}
+		resp, err = handler.Handle("ANALYSIS.TOPOSORT", []string{string(graphID), "EDGETYPES", "depends_on", "format", "simple"})
+		if err != nil || len(resp.ArrayValue) != 12 || resp.ArrayValue[0] != "frontend:application" || resp.ArrayValue[11] != "message-queue:queue" {
+			t.Errorf("Expected the depends_on order, got %v, %v", resp, err)
+		}
+
+		for _, args := range [][]string{
+			{},
+			{"missing"},
+			{string(graphID), "FORMAT", "json"},
+			{string(graphID), "DIRECTION", "in"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.TOPOSORT", args); err == nil {
+				t.Errorf("Expected ANALYSIS.TOPOSORT %v to fail", args)
+			}
+		}
+	})
+}
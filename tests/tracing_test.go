@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanAttributes returns the attributes of a span by key
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+// TestTracing tests the spans of commands, of the analyses they run and of
+// sampled storage transactions
+func TestTracing(t *testing.T) {
+	te := setupTestAnalysisEngine(t)
+	defer te.cleanup()
+	te.createSampleGraph()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	handler := redis.NewCommandHandler(te.engine, redis.WithTracerProvider(provider))
+	graph := string(te.graphID)
+
+	// run runs a command and returns the spans it ended
+	run := func(t *testing.T, command string, args ...string) ([]sdktrace.ReadOnlySpan, error) {
+		t.Helper()
+		before := len(recorder.Ended())
+		_, err := handler.Handle(command, args)
+		return recorder.Ended()[before:], err
+	}
+
+	t.Run("Traverse", func(t *testing.T) {
+		spans, err := run(t, "ANALYSIS.TRAVERSE", graph, "auth", "FORMAT", "simple")
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		if len(spans) != 2 {
+			t.Fatalf("Expected a command span and a traversal span, got %d spans", len(spans))
+		}
+		// Children end before their parents
+		traverse, command := spans[0], spans[1]
+
+		if command.Name() != "ANALYSIS.TRAVERSE" || command.Parent().IsValid() {
+			t.Errorf("Expected a root span named after the command, got %q", command.Name())
+		}
+		attrs := spanAttributes(command)
+		if attrs["command"].AsString() != "ANALYSIS.TRAVERSE" || attrs["graph"].AsString() != graph || attrs["args"].AsInt64() != 4 {
+			t.Errorf("Expected the command, graph and argument count, got %v", command.Attributes())
+		}
+		if _, ok := attrs["error"]; ok || command.Status().Code == codes.Error {
+			t.Errorf("Expected no error on a successful command, got %v", command.Status())
+		}
+
+		if traverse.Name() != "analysis.traverse" {
+			t.Errorf("Expected the traversal span, got %q", traverse.Name())
+		}
+		if traverse.Parent().SpanID() != command.SpanContext().SpanID() || traverse.SpanContext().TraceID() != command.SpanContext().TraceID() {
+			t.Error("Expected the traversal span to be a child of the command span")
+		}
+		// auth reaches db, cache and logger over three edges, none of which
+		// have edges of their own
+		attrs = spanAttributes(traverse)
+		if attrs["graph"].AsString() != graph || attrs["start"].AsString() != "auth" {
+			t.Errorf("Expected the graph and start node, got %v", traverse.Attributes())
+		}
+		if attrs["nodes_visited"].AsInt64() != 4 || attrs["edges_visited"].AsInt64() != 3 {
+			t.Errorf("Expected 4 nodes and 3 edges visited, got %v", traverse.Attributes())
+		}
+	})
+
+	t.Run("Shortest Path And Cycles", func(t *testing.T) {
+		for _, tc := range []struct {
+			command string
+			args    []string
+			span    string
+		}{
+			{"ANALYSIS.SHORTESTPATH", []string{graph, "app", "db", "FORMAT", "simple"}, "analysis.shortest_path"},
+			{"ANALYSIS.CYCLES", []string{graph}, "analysis.cycles"},
+		} {
+			spans, err := run(t, tc.command, tc.args...)
+			if err != nil {
+				t.Fatalf("%s failed: %v", tc.command, err)
+			}
+			if len(spans) != 2 || spans[0].Name() != tc.span || spans[1].Name() != tc.command {
+				t.Fatalf("Expected %s under %s, got %d spans", tc.span, tc.command, len(spans))
+			}
+			if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+				t.Errorf("Expected %s to be a child of the command span", tc.span)
+			}
+			if attrs := spanAttributes(spans[0]); attrs["nodes_visited"].AsInt64() == 0 {
+				t.Errorf("Expected %s to count the nodes visited, got %v", tc.span, spans[0].Attributes())
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		spans, err := run(t, "ANALYSIS.TRAVERSE", graph, "missing")
+		if err == nil {
+			t.Fatal("Expected traversing from a missing node to fail")
+		}
+		command := spans[len(spans)-1]
+		if spanAttributes(command)["error"].AsString() != err.Error() || command.Status().Code != codes.Error {
+			t.Errorf("Expected the error on the command span, got %v, %v", command.Attributes(), command.Status())
+		}
+	})
+
+	t.Run("Storage", func(t *testing.T) {
+		// Storage spans are off until enabled
+		spans, err := run(t, "NODE.CREATE", graph, "search", "service")
+		if err != nil || len(spans) != 1 {
+			t.Fatalf("Expected only the command span, got %d spans, %v", len(spans), err)
+		}
+
+		te.engine.SetTracing(provider, 1)
+		defer te.engine.SetTracing(nil, 0)
+		spans, err = run(t, "NODE.CREATE", graph, "billing", "service")
+		if err != nil || len(spans) != 2 {
+			t.Fatalf("Expected a storage span and the command span, got %d spans, %v", len(spans), err)
+		}
+		transaction := spans[0]
+		if transaction.Name() != "storage.transaction" || len(transaction.Events()) != 1 || transaction.Events()[0].Name != "commit" {
+			t.Errorf("Expected a committed storage transaction, got %q with %v", transaction.Name(), transaction.Events())
+		}
+		if attrs := spanAttributes(transaction); attrs["graphs"].AsInt64() != 1 || attrs["records_changed"].AsInt64() != 1 {
+			t.Errorf("Expected one graph and record changed, got %v", transaction.Attributes())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		before := len(recorder.Ended())
+		untraced := redis.NewCommandHandler(te.engine)
+		if _, err := untraced.Handle("ANALYSIS.TRAVERSE", []string{graph, "auth"}); err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		if spans := recorder.Ended()[before:]; len(spans) != 0 {
+			t.Errorf("Expected no spans without a tracer provider, got %d", len(spans))
+		}
+	})
+}
+
+// BenchmarkTraversalTracing compares ANALYSIS.TRAVERSE with tracing
+// disabled, the default, to tracing every command
+func BenchmarkTraversalTracing(b *testing.B) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_tracing_bench")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+
+	// A binary tree of 255 nodes
+	graphID := models.GraphID("bench-graph")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+		b.Fatalf("Failed to create graph: %v", err)
+	}
+	const size = 255
+	for i := 0; i < size; i++ {
+		if err := engine.CreateNode(graphID, &models.Node{ID: models.NodeID(fmt.Sprintf("n%d", i)), Type: "service"}); err != nil {
+			b.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	for i := 1; i < size; i++ {
+		edge := &models.Edge{
+			ID:         models.EdgeID(fmt.Sprintf("e%d", i)),
+			FromNodeID: models.NodeID(fmt.Sprintf("n%d", (i-1)/2)),
+			ToNodeID:   models.NodeID(fmt.Sprintf("n%d", i)),
+			Type:       "calls",
+		}
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			b.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	handlers := []struct {
+		name    string
+		handler *redis.CommandHandler
+	}{
+		{"Disabled", redis.NewCommandHandler(engine)},
+		{"Enabled", redis.NewCommandHandler(engine, redis.WithTracerProvider(sdktrace.NewTracerProvider()))},
+	}
+	args := []string{string(graphID), "n0", "FORMAT", "simple"}
+	for _, h := range handlers {
+		b.Run("Tracing="+h.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := h.handler.Handle("ANALYSIS.TRAVERSE", args); err != nil {
+					b.Fatalf("Traversal failed: %v", err)
+				}
+			}
+		})
+	}
+}
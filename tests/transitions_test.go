@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestEdgeTypeTransitions tests that a path grammar limits traversals and
+// shortest paths to conforming paths
+func TestEdgeTypeTransitions(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_transitions_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	graphID := models.GraphID("pipeline")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "pipeline"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, node := range []*models.Node{
+		{ID: "repo", Type: "repository"},
+		{ID: "artifact", Type: "artifact"},
+		{ID: "env", Type: "environment"},
+		{ID: "lib", Type: "library"},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	// e1 reaches artifact first by an edge the grammar cannot continue
+	// from, e2 by one it can
+	for _, edge := range []*models.Edge{
+		{ID: "e1", Type: "tests", FromNodeID: "repo", ToNodeID: "artifact"},
+		{ID: "e2", Type: "builds", FromNodeID: "repo", ToNodeID: "artifact"},
+		{ID: "e3", Type: "deploys_to", FromNodeID: "artifact", ToNodeID: "env"},
+		{ID: "e4", Type: "uses", FromNodeID: "artifact", ToNodeID: "lib"},
+		{ID: "e5", Type: "deploys_to", FromNodeID: "repo", ToNodeID: "env"},
+	} {
+		if err := engine.CreateEdge(graphID, edge); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	grammar := map[models.EdgeType][]models.EdgeType{
+		types.TransitionStart: {"builds", "tests"},
+		"builds":              {"deploys_to"},
+	}
+	options := func(transitions map[models.EdgeType][]models.EdgeType) *types.TraversalOptions {
+		return &types.TraversalOptions{MaxDepth: -1, Direction: types.DirectionForward, EdgeTypeTransitions: transitions}
+	}
+
+	t.Run("DepthFirstSearch", func(t *testing.T) {
+		result, err := analyzer.DepthFirstSearch(graphID, "repo", options(grammar))
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		expected := []models.NodeID{"repo", "artifact", "env"}
+		if !reflect.DeepEqual(result.Path, expected) {
+			t.Errorf("Expected %v, got %v", expected, result.Path)
+		}
+
+		result, err = analyzer.DepthFirstSearch(graphID, "repo", options(nil))
+		if err != nil {
+			t.Fatalf("DepthFirstSearch failed: %v", err)
+		}
+		expected = []models.NodeID{"repo", "artifact", "env", "lib"}
+		if !reflect.DeepEqual(result.Path, expected) {
+			t.Errorf("Expected %v without a grammar, got %v", expected, result.Path)
+		}
+	})
+
+	t.Run("WalkBFS", func(t *testing.T) {
+		visits := make(map[models.NodeID]int)
+		err := analyzer.WalkBFS(context.Background(), graphID, "repo", options(grammar), func(node *models.Node, depth int, via *models.Edge) error {
+			visits[node.ID]++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkBFS failed: %v", err)
+		}
+		expected := map[models.NodeID]int{"repo": 1, "artifact": 1, "env": 1}
+		if !reflect.DeepEqual(visits, expected) {
+			t.Errorf("Expected each conforming node visited once, got %v", visits)
+		}
+	})
+
+	t.Run("AllPathsTraversal", func(t *testing.T) {
+		paths, err := analyzer.AllPathsTraversal(graphID, "repo", options(grammar))
+		if err != nil {
+			t.Fatalf("AllPathsTraversal failed: %v", err)
+		}
+		var got [][]models.NodeID
+		for _, path := range paths {
+			got = append(got, path.Path)
+		}
+		expected := [][]models.NodeID{{"repo", "artifact"}, {"repo", "artifact", "env"}}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+
+		paths, err = analyzer.AllPathsTraversal(graphID, "repo", options(nil))
+		if err != nil {
+			t.Fatalf("AllPathsTraversal failed: %v", err)
+		}
+		if len(paths) != 5 {
+			t.Errorf("Expected 5 paths without a grammar, got %d", len(paths))
+		}
+	})
+
+	t.Run("ShortestPath", func(t *testing.T) {
+		path, err := analyzer.GetShortestPath(graphID, "repo", "env", options(grammar))
+		if err != nil {
+			t.Fatalf("GetShortestPath failed: %v", err)
+		}
+		if !reflect.DeepEqual(path.Edges, []models.EdgeID{"e2", "e3"}) {
+			t.Errorf("Expected the path through the build, got %v", path.Edges)
+		}
+		path, err = analyzer.GetShortestPath(graphID, "repo", "env", options(nil))
+		if err != nil || !reflect.DeepEqual(path.Edges, []models.EdgeID{"e5"}) {
+			t.Errorf("Expected the direct path without a grammar, got %+v, %v", path, err)
+		}
+		if _, err := analyzer.GetShortestPath(graphID, "repo", "lib", options(grammar)); err == nil {
+			t.Error("Expected no path the grammar allows to lib")
+		}
+	})
+
+	t.Run("Commands", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+		transitions := `{"":["builds","tests"],"builds":["deploys_to"]}`
+
+		resp, err := handler.Handle("ANALYSIS.TRAVERSE", []string{"pipeline", "repo", "FORMAT", "simple", "TRANSITIONS", transitions})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		expected := []string{"repo:repository", "artifact:artifact", "env:environment"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		resp, err = handler.Handle("ANALYSIS.TRAVERSE", []string{"pipeline", "repo", "TRANSITIONS", transitions})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TRAVERSE failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 2 || strings.Contains(strings.Join(resp.ArrayValue, " "), "lib") {
+			t.Errorf("Expected 2 paths without lib, got %v", resp.ArrayValue)
+		}
+
+		resp, err = handler.Handle("ANALYSIS.SHORTESTPATH", []string{"pipeline", "repo", "env", "TRANSITIONS", transitions})
+		if err != nil {
+			t.Fatalf("ANALYSIS.SHORTESTPATH failed: %v", err)
+		}
+		if len(resp.ArrayValue) != 1 || !strings.Contains(resp.ArrayValue[0], "builds") {
+			t.Errorf("Expected the one path through the build, got %v", resp.ArrayValue)
+		}
+		resp, err = handler.Handle("ANALYSIS.SHORTESTPATH", []string{"pipeline", "repo", "env", "FORMAT", "simple"})
+		if err != nil || len(resp.ArrayValue) != 2 {
+			t.Errorf("Expected the direct path without a grammar, got %v, %v", resp, err)
+		}
+
+		for _, args := range [][]string{
+			{"pipeline", "repo", "TRANSITIONS"},
+			{"pipeline", "repo", "TRANSITIONS", `["builds"]`},
+			{"pipeline", "repo", "TRANSITIONS", "null"},
+			{"pipeline", "repo", "TRANSITIONS", `{"":"builds"}`},
+		} {
+			if _, err := handler.Handle("ANALYSIS.TRAVERSE", args); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// outline writes a dependency tree one node per line, indented by depth,
+// with * for truncated nodes and @ for cycles
+func outline(tree *types.DependencyTree) []string {
+	line := strings.Repeat("  ", tree.Depth) + string(tree.NodeID)
+	if tree.Truncated {
+		line += "*"
+	}
+	if tree.Cycle {
+		line += "@"
+	}
+	lines := []string{line}
+	for _, child := range tree.Children {
+		lines = append(lines, outline(child)...)
+	}
+	return lines
+}
+
+// TestDependencyTree tests BuildDependencyTree and ANALYSIS.TREE on a graph
+// with a diamond, a cycle and parallel edges
+func TestDependencyTree(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_tree_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	analyzer := analysis.NewGraphAnalyzer(engine)
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("tree")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "tree"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"app", "web", "api", "db", "log", "auth"} {
+		if err := engine.CreateNode(graphID, &models.Node{ID: id, Type: "service"}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	// app depends on web and api, which both depend on db, itself depending
+	// on log; auth and api depend on each other, and auth calls db directly
+	for i, edge := range [][3]string{
+		{"app", "web", "depends_on"},
+		{"app", "api", "depends_on"},
+		{"web", "db", "depends_on"},
+		{"api", "db", "depends_on"},
+		{"api", "db", "depends_on"},
+		{"db", "log", "depends_on"},
+		{"api", "auth", "calls"},
+		{"auth", "api", "calls"},
+		{"auth", "db", "calls"},
+	} {
+		if err := engine.CreateEdge(graphID, &models.Edge{ID: models.EdgeID(fmt.Sprintf("e%d", i)), FromNodeID: models.NodeID(edge[0]), ToNodeID: models.NodeID(edge[1]), Type: models.EdgeType(edge[2])}); err != nil {
+			t.Fatalf("Failed to create edge: %v", err)
+		}
+	}
+
+	build := func(t *testing.T, root models.NodeID, options *types.TraversalOptions) []string {
+		t.Helper()
+		tree, err := analyzer.BuildDependencyTree(graphID, root, options)
+		if err != nil {
+			t.Fatalf("BuildDependencyTree failed: %v", err)
+		}
+		return outline(tree)
+	}
+
+	t.Run("Tree", func(t *testing.T) {
+		// db is expanded under api, the first place it is reached at its
+		// shortest depth, and truncated under auth and web; api closes the
+		// cycle through auth
+		expected := []string{
+			"app",
+			"  api",
+			"    auth",
+			"      api*@",
+			"      db*",
+			"    db",
+			"      log",
+			"  web",
+			"    db*",
+		}
+		for i := 0; i < 3; i++ {
+			if got := build(t, "app", nil); !reflect.DeepEqual(got, expected) {
+				t.Fatalf("Expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+			}
+		}
+	})
+
+	t.Run("ShortestDepth", func(t *testing.T) {
+		// auth reaches db first through api, at depth 2, but db is
+		// expanded where it is at its shortest depth, directly under auth
+		expected := []string{
+			"auth",
+			"  api",
+			"    auth*@",
+			"    db*",
+			"  db",
+			"    log",
+		}
+		if got := build(t, "auth", nil); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		expected := []string{"app", "  api*", "  web*"}
+		if got := build(t, "app", &types.TraversalOptions{MaxDepth: 1}); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected MaxDepth 1 to give %v, got %v", expected, got)
+		}
+		expected = []string{"api", "  db", "    log"}
+		if got := build(t, "api", &types.TraversalOptions{MaxDepth: -1, EdgeTypes: []models.EdgeType{"depends_on"}}); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected the depends_on tree %v, got %v", expected, got)
+		}
+		expected = []string{"db", "  api", "    app", "    auth*", "  auth", "    api*", "  web", "    app"}
+		if got := build(t, "db", &types.TraversalOptions{MaxDepth: -1, Direction: types.DirectionBackward}); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected the dependents tree %v, got %v", expected, got)
+		}
+		if _, err := analyzer.BuildDependencyTree(graphID, "missing", nil); err == nil {
+			t.Error("Expected a missing root to fail")
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		resp, err := handler.Handle("ANALYSIS.TREE", []string{string(graphID), "app", "MAXDEPTH", "2", "FORMAT", "json"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.TREE failed: %v", err)
+		}
+		var tree types.DependencyTree
+		if err := json.Unmarshal([]byte(resp.StringValue), &tree); err != nil {
+			t.Fatalf("Failed to decode %q: %v", resp.StringValue, err)
+		}
+		expected := []string{"app", "  api", "    auth*", "    db*", "  web", "    db*"}
+		if got := outline(&tree); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+		if !strings.Contains(resp.StringValue, `"truncated":true`) || strings.Contains(resp.StringValue, `"cycle"`) {
+			t.Errorf("Expected truncated flags and no cycle flag in %s", resp.StringValue)
+		}
+
+		for _, args := range [][]string{
+			{string(graphID)},
+			{string(graphID), "missing"},
+			{string(graphID), "app", "MAXDEPTH"},
+			{string(graphID), "app", "MAXDEPTH", "-1"},
+			{string(graphID), "app", "FORMAT", "dot"},
+			{string(graphID), "app", "DEPTH", "2"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.TREE", args); err == nil {
+				t.Errorf("Expected ANALYSIS.TREE %v to fail", args)
+			}
+		}
+	})
+}
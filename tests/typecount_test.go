@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/utils"
+)
+
+// scanTypeCounts counts the node and edge types of a graph from a fresh
+// listing
+func scanTypeCounts(t *testing.T, engine *storage.BadgerEngine, graphID models.GraphID) (map[models.NodeType]int, map[models.EdgeType]int) {
+	t.Helper()
+	nodes, err := engine.ListNodes(graphID)
+	if err != nil {
+		t.Fatalf("ListNodes failed: %v", err)
+	}
+	edges, err := engine.ListEdges(graphID)
+	if err != nil {
+		t.Fatalf("ListEdges failed: %v", err)
+	}
+	nodeCounts := make(map[models.NodeType]int)
+	for _, node := range nodes {
+		nodeCounts[node.Type]++
+	}
+	edgeCounts := make(map[models.EdgeType]int)
+	for _, edge := range edges {
+		edgeCounts[edge.Type]++
+	}
+	return nodeCounts, edgeCounts
+}
+
+// checkTypeCounts fails the test if the type counts of a graph differ from
+// a fresh scan
+func checkTypeCounts(t *testing.T, engine *storage.BadgerEngine, graphID models.GraphID, step string) {
+	t.Helper()
+	expectedNodes, expectedEdges := scanTypeCounts(t, engine, graphID)
+	nodeCounts, err := engine.CountNodesByType(graphID)
+	if err != nil {
+		t.Fatalf("CountNodesByType failed: %v", err)
+	}
+	edgeCounts, err := engine.CountEdgesByType(graphID)
+	if err != nil {
+		t.Fatalf("CountEdgesByType failed: %v", err)
+	}
+	if !reflect.DeepEqual(nodeCounts, expectedNodes) {
+		t.Fatalf("%s: expected node counts %v in %s, got %v", step, expectedNodes, graphID, nodeCounts)
+	}
+	if !reflect.DeepEqual(edgeCounts, expectedEdges) {
+		t.Fatalf("%s: expected edge counts %v in %s, got %v", step, expectedEdges, graphID, edgeCounts)
+	}
+}
+
+// TestTypeCounts tests that the per-type node and edge counts stay equal to
+// a fresh scan through a random sequence of writes, and that GetGraphStats,
+// DeleteGraph and reopening a database written without counts agree with
+// them
+func TestTypeCounts(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_typecount_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { engine.Close() }()
+
+	graphIDs := []models.GraphID{"counts", "tally"}
+	for _, graphID := range graphIDs {
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: string(graphID)}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+	}
+
+	t.Run("RandomWrites", func(t *testing.T) {
+		nodeTypes := []models.NodeType{"service", "database", "queue"}
+		edgeTypes := []models.EdgeType{"calls", "reads"}
+		rng := rand.New(rand.NewSource(1))
+		for step := 0; step < 600; step++ {
+			graphID := graphIDs[rng.Intn(len(graphIDs))]
+			nodeID := models.NodeID(fmt.Sprintf("n%d", rng.Intn(20)))
+			edgeID := models.EdgeID(fmt.Sprintf("e%d", rng.Intn(30)))
+			var err error
+			switch op := rng.Intn(6); op {
+			case 0, 1:
+				// Creating a node that exists replaces it, possibly with
+				// another type
+				err = engine.CreateNode(graphID, &models.Node{ID: nodeID, Type: nodeTypes[rng.Intn(len(nodeTypes))]})
+			case 2:
+				if err = engine.DeleteNode(graphID, nodeID); err != nil {
+					err = nil // The node may not exist
+				}
+			case 3:
+				// Creating an edge that exists replaces it too
+				err = engine.CreateEdge(graphID, &models.Edge{
+					ID:         edgeID,
+					Type:       edgeTypes[rng.Intn(len(edgeTypes))],
+					FromNodeID: models.NodeID(fmt.Sprintf("n%d", rng.Intn(20))),
+					ToNodeID:   nodeID,
+				})
+				if err != nil {
+					err = nil // An endpoint may not exist
+				}
+			case 4:
+				if err = engine.DeleteEdge(graphID, edgeID); err != nil {
+					err = nil
+				}
+			case 5:
+				if node, getErr := engine.GetNode(graphID, nodeID); getErr == nil {
+					node.Type = nodeTypes[rng.Intn(len(nodeTypes))]
+					err = engine.UpdateNode(graphID, node)
+				} else if edge, getErr := engine.GetEdge(graphID, edgeID); getErr == nil {
+					edge.Type = edgeTypes[rng.Intn(len(edgeTypes))]
+					err = engine.UpdateEdge(graphID, edge)
+				}
+			}
+			if err != nil {
+				t.Fatalf("Step %d failed: %v", step, err)
+			}
+			if step%25 == 0 {
+				for _, graphID := range graphIDs {
+					checkTypeCounts(t, engine, graphID, fmt.Sprintf("step %d", step))
+				}
+			}
+		}
+		for _, graphID := range graphIDs {
+			checkTypeCounts(t, engine, graphID, "end")
+		}
+	})
+
+	t.Run("GraphStats", func(t *testing.T) {
+		expectedNodes, expectedEdges := scanTypeCounts(t, engine, graphIDs[0])
+		stats, err := analysis.NewGraphAnalyzer(engine).GetGraphStats(graphIDs[0], nil)
+		if err != nil {
+			t.Fatalf("GetGraphStats failed: %v", err)
+		}
+		if !reflect.DeepEqual(stats.NodeTypeCount, expectedNodes) || !reflect.DeepEqual(stats.EdgeTypeCount, expectedEdges) {
+			t.Errorf("Expected type counts %v and %v, got %v and %v", expectedNodes, expectedEdges, stats.NodeTypeCount, stats.EdgeTypeCount)
+		}
+		nodes, _ := engine.CountNodes(graphIDs[0])
+		if stats.NodeCount == 0 || stats.NodeCount != nodes {
+			t.Errorf("Expected %d nodes, got %d", nodes, stats.NodeCount)
+		}
+	})
+
+	t.Run("DeleteGraph", func(t *testing.T) {
+		keys, err := engine.CountGraphKeys(graphIDs[0])
+		if err != nil {
+			t.Fatalf("CountGraphKeys failed: %v", err)
+		}
+		removed, err := engine.DeleteGraph(graphIDs[0])
+		if err != nil {
+			t.Fatalf("DeleteGraph failed: %v", err)
+		}
+		if removed != keys {
+			t.Errorf("Expected DeleteGraph to remove the %d keys counted, removed %d", keys, removed)
+		}
+		if err := engine.CreateGraph(&models.Graph{ID: graphIDs[0], Name: "again"}); err != nil {
+			t.Fatalf("Failed to recreate graph: %v", err)
+		}
+		checkTypeCounts(t, engine, graphIDs[0], "recreated")
+		checkTypeCounts(t, engine, graphIDs[1], "after deleting the other graph")
+	})
+
+	t.Run("Backfill", func(t *testing.T) {
+		// Seed a graph the way a build without type counts wrote it
+		if err := engine.Close(); err != nil {
+			t.Fatalf("Failed to close database: %v", err)
+		}
+		graphID := models.GraphID("legacy")
+		db, err := badger.Open(badger.DefaultOptions(testPath).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Failed to open raw database: %v", err)
+		}
+		batch := db.NewWriteBatch()
+		graphValue, _ := (&models.Graph{ID: graphID, Name: string(graphID)}).ToJSON()
+		batch.Set(utils.EncodeGraphKey(graphID), graphValue)
+		for i := 0; i < 30; i++ {
+			node := &models.Node{ID: models.NodeID(fmt.Sprintf("n%d", i)), Type: models.NodeType(fmt.Sprintf("t%d", i%3))}
+			value, _ := node.ToJSON()
+			batch.Set(utils.EncodeNodeKey(graphID, node.ID), value)
+			batch.Set(utils.EncodeNodeTypeIndexKey(graphID, node.Type, node.ID), []byte(node.ID))
+		}
+		if err := batch.Flush(); err != nil {
+			t.Fatalf("Failed to seed graph: %v", err)
+		}
+		db.Close()
+
+		engine = storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+		counts, err := engine.CountNodesByType(graphID)
+		if err != nil {
+			t.Fatalf("CountNodesByType failed: %v", err)
+		}
+		if expected := map[models.NodeType]int{"t0": 10, "t1": 10, "t2": 10}; !reflect.DeepEqual(counts, expected) {
+			t.Errorf("Expected the legacy graph counted on open as %v, got %v", expected, counts)
+		}
+		if err := engine.DeleteNode(graphID, "n0"); err != nil {
+			t.Fatalf("DeleteNode failed: %v", err)
+		}
+		for _, graphID := range append(graphIDs, graphID) {
+			checkTypeCounts(t, engine, graphID, "reopened")
+		}
+	})
+}
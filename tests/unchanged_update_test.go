@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestUnchangedUpdate tests that updates leaving a node or edge as stored
+// are not written, and that updates changing anything still are
+func TestUnchangedUpdate(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_unchanged_update_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	engine := storage.NewBadgerEngine(storage.WithClock(clock.Now))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	graphID := models.GraphID("services")
+	// reply runs a command and returns its simple string reply
+	reply := func(t *testing.T, command string, args ...string) string {
+		t.Helper()
+		resp, err := handler.Handle(command, append([]string{string(graphID)}, args...))
+		if err != nil {
+			t.Fatalf("%s failed: %v", command, err)
+		}
+		return resp.StringValue
+	}
+	// updates returns the node and edge updates counted this hour
+	updates := func(t *testing.T) (uint64, uint64) {
+		t.Helper()
+		buckets, err := engine.Activity(graphID, 1)
+		if err != nil || len(buckets) != 1 {
+			t.Fatalf("Activity failed: %v, %v", buckets, err)
+		}
+		return buckets[0].Counts[storage.ActivityNodeUpdate], buckets[0].Counts[storage.ActivityEdgeUpdate]
+	}
+
+	reply(t, "GRAPH.CREATE")
+	reply(t, "NODE.CREATE", "api", "service", `{"tier":1,"owner":"payments"}`)
+	reply(t, "NODE.CREATE", "db", "database", "{}")
+	reply(t, "EDGE.CREATE", "api-db", "api", "db", "reads", `{"pool":10}`)
+
+	t.Run("Node", func(t *testing.T) {
+		before, err := engine.GetNode(graphID, "api")
+		if err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		generation := engine.Generation(graphID)
+		nodeUpdates, _ := updates(t)
+
+		// The attributes it was created with, in another key order, and
+		// the same type
+		time.Sleep(2 * time.Millisecond)
+		for _, args := range [][]string{
+			{"api", "ATTRIBUTES", `{"owner":"payments","tier":1}`},
+			{"api", "TYPE", "service"},
+			{"api", `{"tier":1,"owner":"payments"}`},
+		} {
+			if got := reply(t, "NODE.UPDATE", args...); got != "NOCHANGE" {
+				t.Errorf("Expected NODE.UPDATE %v to reply NOCHANGE, got %s", args, got)
+			}
+		}
+		after, err := engine.GetNode(graphID, "api")
+		if err != nil || !after.UpdatedAt.Equal(before.UpdatedAt) {
+			t.Errorf("Expected UpdatedAt to stay %v, got %v, %v", before.UpdatedAt, after.UpdatedAt, err)
+		}
+		if got := engine.Generation(graphID); got != generation {
+			t.Errorf("Expected the generation to stay %d, got %d", generation, got)
+		}
+		if got, _ := updates(t); got != nodeUpdates {
+			t.Errorf("Expected %d node updates counted, got %d", nodeUpdates, got)
+		}
+
+		// A changed attribute, type or TTL is written
+		for _, args := range [][]string{
+			{"api", "ATTRIBUTES", `{"tier":2,"owner":"payments"}`},
+			{"api", "TYPE", "gateway"},
+			{"api", "TTL", "3600"},
+		} {
+			generation := engine.Generation(graphID)
+			if got := reply(t, "NODE.UPDATE", args...); got != "OK" {
+				t.Errorf("Expected NODE.UPDATE %v to be written, got %s", args, got)
+			}
+			if engine.Generation(graphID) <= generation {
+				t.Errorf("Expected NODE.UPDATE %v to advance the generation", args)
+			}
+		}
+		after, err = engine.GetNode(graphID, "api")
+		if err != nil || after.Type != "gateway" || after.ExpiresAt == nil || !after.UpdatedAt.After(before.UpdatedAt) {
+			t.Errorf("Expected the changes to be stored, got %+v, %v", after, err)
+		}
+		if got, _ := updates(t); got != nodeUpdates+3 {
+			t.Errorf("Expected %d node updates counted, got %d", nodeUpdates+3, got)
+		}
+		if nodes, err := engine.ListNodesByType(graphID, "gateway"); err != nil || len(nodes) != 1 {
+			t.Errorf("Expected the type index to follow the change, got %v, %v", nodes, err)
+		}
+	})
+
+	t.Run("Edge", func(t *testing.T) {
+		before, err := engine.GetEdge(graphID, "api-db")
+		if err != nil {
+			t.Fatalf("GetEdge failed: %v", err)
+		}
+		generation := engine.Generation(graphID)
+		_, edgeUpdates := updates(t)
+
+		time.Sleep(2 * time.Millisecond)
+		if got := reply(t, "EDGE.UPDATE", "api-db", `{"pool":10}`); got != "NOCHANGE" {
+			t.Errorf("Expected EDGE.UPDATE to reply NOCHANGE, got %s", got)
+		}
+		after, err := engine.GetEdge(graphID, "api-db")
+		if err != nil || !after.UpdatedAt.Equal(before.UpdatedAt) {
+			t.Errorf("Expected UpdatedAt to stay %v, got %v, %v", before.UpdatedAt, after.UpdatedAt, err)
+		}
+		if got := engine.Generation(graphID); got != generation {
+			t.Errorf("Expected the generation to stay %d, got %d", generation, got)
+		}
+
+		if got := reply(t, "EDGE.UPDATE", "api-db", `{"pool":20}`); got != "OK" {
+			t.Errorf("Expected EDGE.UPDATE to be written, got %s", got)
+		}
+		if after, err := engine.GetEdge(graphID, "api-db"); err != nil || after.Attributes["pool"] != float64(20) {
+			t.Errorf("Expected the new pool to be stored, got %+v, %v", after, err)
+		}
+		if _, got := updates(t); got != edgeUpdates+1 {
+			t.Errorf("Expected %d edge updates counted, got %d", edgeUpdates+1, got)
+		}
+	})
+
+	t.Run("Storage", func(t *testing.T) {
+		node, err := engine.GetNode(graphID, "db")
+		if err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		node.UpdatedAt = node.UpdatedAt.Add(time.Hour)
+		if written, err := engine.UpdateNodeWithResult(graphID, node); err != nil || written {
+			t.Errorf("Expected a new UpdatedAt alone not to be written, got %v, %v", written, err)
+		}
+		node.Attributes = map[string]interface{}{"engine": "postgres"}
+		if written, err := engine.UpdateNodeWithResult(graphID, node); err != nil || !written {
+			t.Errorf("Expected a new attribute to be written, got %v, %v", written, err)
+		}
+
+		edge, err := engine.GetEdge(graphID, "api-db")
+		if err != nil {
+			t.Fatalf("GetEdge failed: %v", err)
+		}
+		if written, err := engine.UpdateEdgeWithResult(graphID, edge); err != nil || written {
+			t.Errorf("Expected the stored edge not to be written, got %v, %v", written, err)
+		}
+		if _, err := engine.UpdateNodeWithResult(graphID, &models.Node{ID: "missing", Type: "service"}); err == nil {
+			t.Error("Expected updating a missing node to fail")
+		}
+	})
+}
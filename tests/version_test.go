@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/commands"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/version"
+)
+
+// TestVersionAndCapabilities tests SYSTEM.VERSION, the version in INFO,
+// that SYSTEM.CAPABILITIES lists exactly the registered commands, and that
+// the version and commit are set by the documented ldflags
+func TestVersionAndCapabilities(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_version_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	t.Run("Version", func(t *testing.T) {
+		resp, err := handler.Handle("SYSTEM.VERSION", nil)
+		if err != nil {
+			t.Fatalf("SYSTEM.VERSION failed: %v", err)
+		}
+		fields := make(map[string]string)
+		for i := 0; i+1 < len(resp.ArrayValue); i += 2 {
+			fields[resp.ArrayValue[i]] = resp.ArrayValue[i+1]
+		}
+		if fields["version"] != version.Version || fields["commit"] != version.Commit || fields["protocol"] != "2" || !strings.HasPrefix(fields["go"], "go") {
+			t.Errorf("Expected the build fields, got %v", resp.ArrayValue)
+		}
+		if _, err := handler.Handle("SYSTEM.VERSION", []string{"full"}); err == nil {
+			t.Error("Expected SYSTEM.VERSION to take no arguments")
+		}
+
+		resp, err = handler.Handle("INFO", []string{"server"})
+		if err != nil {
+			t.Fatalf("INFO failed: %v", err)
+		}
+		lines := strings.Split(resp.StringValue, "\r\n")
+		if !slices.Contains(lines, "version:"+version.Version) || !slices.Contains(lines, "commit:"+version.Commit) {
+			t.Errorf("Expected INFO to report the version and commit, got %q", resp.StringValue)
+		}
+	})
+
+	t.Run("Capabilities", func(t *testing.T) {
+		resp, err := handler.Handle("SYSTEM.CAPABILITIES", nil)
+		if err != nil {
+			t.Fatalf("SYSTEM.CAPABILITIES failed: %v", err)
+		}
+
+		// One row per registered command, in the registry's order, with the
+		// keywords the command's parser accepts
+		registered := handler.Registry().Commands()
+		if len(resp.NestedArrayValue) != len(registered) {
+			t.Fatalf("Expected %d commands, got %d", len(registered), len(resp.NestedArrayValue))
+		}
+		capabilities := make(map[string][]string)
+		for i, row := range resp.NestedArrayValue {
+			fields := row.([]string)
+			if fields[0] != registered[i].Name {
+				t.Errorf("Expected command %d to be %s, got %s", i, registered[i].Name, fields[0])
+			}
+			capabilities[fields[0]] = fields[1:]
+		}
+		for _, spec := range registered {
+			if keywords := capabilities[spec.Name]; !reflect.DeepEqual(keywords, append([]string{}, spec.Keywords...)) {
+				t.Errorf("Expected %s to list keywords %v, got %v", spec.Name, spec.Keywords, keywords)
+			}
+		}
+
+		// The commands clients feature-detect are listed, and they are
+		// the ones the handler dispatches
+		if !slices.Contains(capabilities["ANALYSIS.TRAVERSE"], "MAXFANOUT") {
+			t.Errorf("Expected ANALYSIS.TRAVERSE to list MAXFANOUT, got %v", capabilities["ANALYSIS.TRAVERSE"])
+		}
+		for _, name := range []string{"PING", "HELP", "NODE.HELP", "SYSTEM.CAPABILITIES", "SYSTEM.VERSION"} {
+			if _, ok := capabilities[name]; !ok {
+				t.Errorf("Expected %s to be listed", name)
+			}
+		}
+		if _, err := handler.Handle("SYSTEM.NOSUCH", nil); err == nil {
+			t.Error("Expected an unlisted command to be unknown")
+		}
+
+		// The family handler lists only its own commands
+		resp, err = commands.NewSystemCommands(engine).Handle("CAPABILITIES", nil)
+		if err != nil {
+			t.Fatalf("SYSTEM CAPABILITIES failed: %v", err)
+		}
+		for _, row := range resp.NestedArrayValue {
+			if name := row.([]string)[0]; !strings.HasPrefix(name, "SYSTEM.") {
+				t.Errorf("Expected only SYSTEM commands from the family handler, got %s", name)
+			}
+		}
+	})
+
+	t.Run("Ldflags", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("builds the server binary")
+		}
+		binary := filepath.Join(testPath, "redis-server")
+		build := exec.Command("go", "build",
+			"-ldflags", "-X github.com/ywadi/PathwayDB/version.Version=9.8.7 -X github.com/ywadi/PathwayDB/version.Commit=abc1234",
+			"-o", binary, "./cmd/redis-server")
+		build.Dir = ".."
+		if output, err := build.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to build the server: %v\n%s", err, output)
+		}
+
+		output, err := exec.Command(binary, "version").Output()
+		if err != nil {
+			t.Fatalf("version failed: %v", err)
+		}
+		if got := strings.TrimSpace(string(output)); got != "PathwayDB 9.8.7 (commit abc1234)" {
+			t.Errorf("Expected the version and commit from the ldflags, got %q", got)
+		}
+	})
+}
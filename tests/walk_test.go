@@ -0,0 +1,290 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// walkStep is one call of a WalkFunc
+type walkStep struct {
+	node  models.NodeID
+	depth int
+	via   models.EdgeID
+}
+
+// recordWalk returns a WalkFunc that records its calls in steps
+func recordWalk(steps *[]walkStep) analysis.WalkFunc {
+	return func(node *models.Node, depth int, via *models.Edge) error {
+		step := walkStep{node: node.ID, depth: depth}
+		if via != nil {
+			step.via = via.ID
+		}
+		*steps = append(*steps, step)
+		return nil
+	}
+}
+
+// TestWalk tests the streaming traversal API against the sample graph:
+// app -> auth, logger; auth -> db, cache, logger; queue -> logger
+func TestWalk(t *testing.T) {
+	te := setupTestAnalysisEngine(t)
+	defer te.cleanup()
+	te.createSampleGraph()
+	ctx := context.Background()
+	forward := &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: -1}
+
+	t.Run("DFSOrder", func(t *testing.T) {
+		var steps []walkStep
+		if err := te.analyzer.WalkDFS(ctx, te.graphID, "app", forward, recordWalk(&steps)); err != nil {
+			t.Fatalf("WalkDFS failed: %v", err)
+		}
+		expected := []walkStep{
+			{"app", 0, ""},
+			{"auth", 1, "app-auth"},
+			{"cache", 2, "auth-cache"},
+			{"db", 2, "auth-db"},
+			{"logger", 2, "auth-logger"},
+		}
+		if !reflect.DeepEqual(steps, expected) {
+			t.Errorf("Expected %v, got %v", expected, steps)
+		}
+	})
+
+	t.Run("BFSOrder", func(t *testing.T) {
+		var steps []walkStep
+		if err := te.analyzer.WalkBFS(ctx, te.graphID, "app", forward, recordWalk(&steps)); err != nil {
+			t.Fatalf("WalkBFS failed: %v", err)
+		}
+		expected := []walkStep{
+			{"app", 0, ""},
+			{"auth", 1, "app-auth"},
+			{"logger", 1, "app-logger"},
+			{"cache", 2, "auth-cache"},
+			{"db", 2, "auth-db"},
+		}
+		if !reflect.DeepEqual(steps, expected) {
+			t.Errorf("Expected %v, got %v", expected, steps)
+		}
+
+		// Breadth-first depths are shortest path lengths
+		for _, step := range steps {
+			path, err := te.analyzer.GetShortestPath(te.graphID, "app", step.node, nil)
+			if err != nil {
+				t.Fatalf("GetShortestPath to %s failed: %v", step.node, err)
+			}
+			if path.Length != step.depth {
+				t.Errorf("Expected %s at depth %d, got %d", step.node, path.Length, step.depth)
+			}
+		}
+
+		var limited []walkStep
+		options := &types.TraversalOptions{Direction: types.DirectionForward, MaxDepth: 1}
+		if err := te.analyzer.WalkBFS(ctx, te.graphID, "app", options, recordWalk(&limited)); err != nil {
+			t.Fatalf("WalkBFS failed: %v", err)
+		}
+		if !reflect.DeepEqual(limited, expected[:3]) {
+			t.Errorf("Expected %v with MaxDepth 1, got %v", expected[:3], limited)
+		}
+	})
+
+	t.Run("MatchesDepthFirstSearch", func(t *testing.T) {
+		for _, options := range []*types.TraversalOptions{
+			forward,
+			{Direction: types.DirectionBackward, MaxDepth: -1},
+			{Direction: types.DirectionBoth, MaxDepth: 2},
+			{Direction: types.DirectionForward, MaxDepth: -1, NodeTypes: []models.NodeType{"service", "database"}},
+		} {
+			result, err := te.analyzer.DepthFirstSearch(te.graphID, "logger", options)
+			if err != nil {
+				t.Fatalf("DepthFirstSearch failed: %v", err)
+			}
+			var steps []walkStep
+			if err := te.analyzer.WalkDFS(ctx, te.graphID, "logger", options, recordWalk(&steps)); err != nil {
+				t.Fatalf("WalkDFS failed: %v", err)
+			}
+
+			var path []models.NodeID
+			var edges []models.EdgeID
+			depths := map[models.NodeID]int{}
+			for _, step := range steps {
+				path = append(path, step.node)
+				depths[step.node] = step.depth
+				if step.via != "" {
+					edges = append(edges, step.via)
+				}
+			}
+			var resultEdges []models.EdgeID
+			for _, edge := range result.Edges {
+				resultEdges = append(resultEdges, edge.ID)
+
+				// Each edge leads from a node one level up
+				from, to := edge.FromNodeID, edge.ToNodeID
+				if options.Direction == types.DirectionBackward || depths[to] < depths[from] {
+					from, to = to, from
+				}
+				if depths[to] != depths[from]+1 {
+					t.Errorf("Expected edge %s to go one level deeper, got depths %d and %d", edge.ID, depths[from], depths[to])
+				}
+			}
+			if !reflect.DeepEqual(path, result.Path) || !reflect.DeepEqual(edges, resultEdges) {
+				t.Errorf("Options %+v: walk visited %v by %v, DepthFirstSearch returned %v by %v", options, path, edges, result.Path, resultEdges)
+			}
+		}
+	})
+
+	t.Run("SkipSubtree", func(t *testing.T) {
+		var visited []models.NodeID
+		err := te.analyzer.WalkDFS(ctx, te.graphID, "app", forward, func(node *models.Node, depth int, via *models.Edge) error {
+			visited = append(visited, node.ID)
+			if node.ID == "auth" {
+				return analysis.SkipSubtree
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkDFS failed: %v", err)
+		}
+		// logger is still reached from app
+		expected := []models.NodeID{"app", "auth", "logger"}
+		if !reflect.DeepEqual(visited, expected) {
+			t.Errorf("Expected %v, got %v", expected, visited)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		errEnough := errors.New("enough")
+		for name, walk := range map[string]func(context.Context, models.GraphID, models.NodeID, *types.TraversalOptions, analysis.WalkFunc) error{
+			"DFS": te.analyzer.WalkDFS,
+			"BFS": te.analyzer.WalkBFS,
+		} {
+			visits := 0
+			err := walk(ctx, te.graphID, "app", forward, func(node *models.Node, depth int, via *models.Edge) error {
+				visits++
+				if visits == 2 {
+					return errEnough
+				}
+				return nil
+			})
+			if !errors.Is(err, errEnough) || visits != 2 {
+				t.Errorf("%s: expected the walk to stop with the visitor's error after 2 visits, got %v after %d", name, err, visits)
+			}
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		err := te.analyzer.WalkDFS(cancelled, te.graphID, "app", forward, func(*models.Node, int, *models.Edge) error {
+			t.Error("Expected no visits after cancellation")
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("VisitorWrites", func(t *testing.T) {
+		// An edge added to the node being visited is followed
+		err := te.analyzer.WalkDFS(ctx, te.graphID, "db", forward, func(node *models.Node, depth int, via *models.Edge) error {
+			if node.ID == "db" {
+				return te.engine.CreateEdge(te.graphID, &models.Edge{ID: "db-queue", Type: "notifies", FromNodeID: "db", ToNodeID: "queue"})
+			}
+			if node.ID == "queue" && (depth != 1 || via.ID != "db-queue") {
+				t.Errorf("Expected queue at depth 1 via db-queue, got %d via %s", depth, via.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkDFS failed: %v", err)
+		}
+	})
+}
+
+// chainStorage serves a generated chain c-0000000 -> c-0000001 -> ...
+// without a database, so walks over millions of nodes are fast and their
+// allocations are the walker's own. Other methods are not implemented.
+type chainStorage struct {
+	storage.StorageEngine
+	n int
+}
+
+func (c *chainStorage) GetNode(graphID models.GraphID, nodeID models.NodeID) (*models.Node, error) {
+	i, err := strconv.Atoi(strings.TrimPrefix(string(nodeID), "c-"))
+	if err != nil || i < 0 || i >= c.n {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	return &models.Node{ID: nodeID, Type: "service", Attributes: models.Attributes{"index": i}}, nil
+}
+
+func (c *chainStorage) GetOutgoingEdges(graphID models.GraphID, nodeID models.NodeID) ([]*models.Edge, error) {
+	i, _ := strconv.Atoi(strings.TrimPrefix(string(nodeID), "c-"))
+	if i+1 >= c.n {
+		return nil, nil
+	}
+	return []*models.Edge{{
+		ID:         models.EdgeID(fmt.Sprintf("e-%07d", i)),
+		Type:       "calls",
+		FromNodeID: nodeID,
+		ToNodeID:   models.NodeID(fmt.Sprintf("c-%07d", i+1)),
+	}}, nil
+}
+
+// TestWalkMemory tests that walking a 1M-node chain with a counting visitor
+// keeps only the visited set, far less than the nodes themselves
+func TestWalkMemory(t *testing.T) {
+	const n = 1000000
+	analyzer := analysis.NewGraphAnalyzer(&chainStorage{n: n})
+
+	heap := func() uint64 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+
+	for name, walk := range map[string]func(context.Context, models.GraphID, models.NodeID, *types.TraversalOptions, analysis.WalkFunc) error{
+		"DFS": analyzer.WalkDFS,
+		"BFS": analyzer.WalkBFS,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var start, end uint64
+			count := 0
+			err := walk(context.Background(), "chain", "c-0000000", nil, func(node *models.Node, depth int, via *models.Edge) error {
+				if depth != count {
+					return fmt.Errorf("expected depth %d, got %d", count, depth)
+				}
+				count++
+				switch count {
+				case n / 10:
+					start = heap()
+				case n:
+					end = heap()
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk failed: %v", err)
+			}
+			if count != n {
+				t.Fatalf("Expected %d visits, got %d", n, count)
+			}
+
+			// The visited set costs under 128 bytes a node; keeping the
+			// nodes would add their IDs, attribute maps and timestamps
+			if end > start {
+				if perNode := (end - start) / (n - n/10); perNode > 128 {
+					t.Errorf("Expected the walk to retain under 128 bytes per node, got %d", perNode)
+				}
+			}
+		})
+	}
+}
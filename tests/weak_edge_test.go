@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestWeakEdges tests that weak edges outlive an expired endpoint, marked
+// dangling, are left out of analyses and are reattached when the node is
+// created again
+func TestWeakEdges(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_weak_edge_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	engine := storage.NewBadgerEngine()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+	analyzer := analysis.NewGraphAnalyzer(engine)
+
+	graphID := models.GraphID("services")
+	if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "services"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	expiresAt := time.Now().Add(time.Second)
+	for _, node := range []*models.Node{
+		{ID: "api", Type: "service"},
+		{ID: "db", Type: "database"},
+		{ID: "cache", Type: "cache", ExpiresAt: &expiresAt},
+	} {
+		if err := engine.CreateNode(graphID, node); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	for _, args := range [][]string{
+		{"services", "api-db", "api", "db", "reads"},
+		{"services", "api-cache", "api", "cache", "uses", `{"ttl":"5m"}`, "WEAK"},
+		{"services", "cache-db", "cache", "db", "reads"},
+	} {
+		if _, err := handler.Handle("EDGE.CREATE", args); err != nil {
+			t.Fatalf("EDGE.CREATE %v failed: %v", args, err)
+		}
+	}
+
+	// The cache expires, taking its normal edge with it
+	time.Sleep(1500 * time.Millisecond)
+	engine.Cleanup()
+	if _, err := engine.GetNode(graphID, "cache"); err == nil {
+		t.Fatal("Expected the cache node to have expired")
+	}
+
+	t.Run("Expiry", func(t *testing.T) {
+		if _, err := engine.GetEdge(graphID, "cache-db"); err == nil {
+			t.Error("Expected the normal edge of the expired node to be deleted")
+		}
+		edge, err := engine.GetEdge(graphID, "api-cache")
+		if err != nil {
+			t.Fatalf("Expected the weak edge to be kept, got %v", err)
+		}
+		if !edge.Weak || !edge.Dangling() {
+			t.Errorf("Expected a weak dangling edge, got %+v", edge)
+		}
+		if value, _ := edge.GetAttribute(models.DanglingToAttribute); value != "cache" {
+			t.Errorf("Expected %s to name the expired node, got %v", models.DanglingToAttribute, value)
+		}
+		if edge.HasAttribute(models.DanglingFromAttribute) {
+			t.Errorf("Expected the source end not to be marked, got %v", edge.Attributes)
+		}
+		if value, _ := edge.GetAttribute("ttl"); value != "5m" {
+			t.Errorf("Expected the other attributes to be kept, got %v", edge.Attributes)
+		}
+	})
+
+	t.Run("Traversal", func(t *testing.T) {
+		options := &types.TraversalOptions{MaxDepth: -1, Direction: types.DirectionForward}
+		result, err := analyzer.DepthFirstSearch(graphID, "api", options)
+		if err != nil {
+			t.Fatalf("Traversal failed: %v", err)
+		}
+		if !reflect.DeepEqual(result.Path, []models.NodeID{"api", "db"}) || len(result.Edges) != 1 {
+			t.Errorf("Expected the traversal to skip the dangling edge, got %v over %d edges", result.Path, len(result.Edges))
+		}
+		if len(result.DanglingEdges) != 0 {
+			t.Errorf("Expected no dangling edges without IncludeDangling, got %v", result.DanglingEdges)
+		}
+
+		options.IncludeDangling = true
+		result, err = analyzer.DepthFirstSearch(graphID, "api", options)
+		if err != nil {
+			t.Fatalf("Traversal failed: %v", err)
+		}
+		if !reflect.DeepEqual(result.Path, []models.NodeID{"api", "db"}) {
+			t.Errorf("Expected dangling edges never to be followed, got %v", result.Path)
+		}
+		if len(result.DanglingEdges) != 1 || result.DanglingEdges[0].ID != "api-cache" {
+			t.Errorf("Expected IncludeDangling to report api-cache, got %v", result.DanglingEdges)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		stats, err := analyzer.GetGraphStats(graphID, nil)
+		if err != nil {
+			t.Fatalf("GetGraphStats failed: %v", err)
+		}
+		if stats.EdgeCount != 2 || stats.DanglingEdgeCount != 1 {
+			t.Errorf("Expected 2 edges with 1 dangling, got %d with %d", stats.EdgeCount, stats.DanglingEdgeCount)
+		}
+	})
+
+	t.Run("List Orphans", func(t *testing.T) {
+		resp, err := handler.Handle("EDGE.LIST", []string{"services", "orphans"})
+		if err != nil {
+			t.Fatalf("EDGE.LIST ORPHANS failed: %v", err)
+		}
+		if !reflect.DeepEqual(resp.ArrayValue, []string{"api-cache:uses"}) {
+			t.Errorf("Expected only api-cache, got %v", resp.ArrayValue)
+		}
+		resp, err = handler.Handle("EDGE.LIST", []string{"services", "ORPHANS", "VERBOSE"})
+		if err != nil {
+			t.Fatalf("EDGE.LIST ORPHANS VERBOSE failed: %v", err)
+		}
+		if len(resp.NestedArrayValue) != 1 {
+			t.Errorf("Expected one verbose edge, got %v", resp.NestedArrayValue)
+		}
+		if _, err := handler.Handle("EDGE.LIST", []string{"services", "VERBOSE", "ORPHANS"}); err == nil {
+			t.Error("Expected ORPHANS after VERBOSE to be rejected")
+		}
+	})
+
+	t.Run("Markers Are Kept", func(t *testing.T) {
+		// Replacing the attributes keeps the markers of a dangling edge
+		if _, err := handler.Handle("EDGE.UPDATE", []string{"services", "api-cache", `{"ttl":"1m"}`}); err != nil {
+			t.Fatalf("EDGE.UPDATE failed: %v", err)
+		}
+		edge, err := engine.GetEdge(graphID, "api-cache")
+		if err != nil || !edge.DanglingEnd("cache") {
+			t.Errorf("Expected api-cache to stay dangling after an update, got %+v, %v", edge, err)
+		}
+
+		// and a marker given to an edge whose ends exist is dropped
+		if _, err := handler.Handle("EDGE.UPDATE", []string{"services", "api-db", `{"_dangling_to":"db"}`}); err != nil {
+			t.Fatalf("EDGE.UPDATE failed: %v", err)
+		}
+		if edge, err := engine.GetEdge(graphID, "api-db"); err != nil || edge.Dangling() {
+			t.Errorf("Expected api-db not to be dangling, got %+v, %v", edge, err)
+		}
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := engine.ExportGraph(graphID, &buf, false); err != nil {
+			t.Fatalf("ExportGraph failed: %v", err)
+		}
+		if _, _, err := engine.ImportGraph("services-copy", bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatalf("Expected a dangling weak edge to import, got %v", err)
+		}
+		edge, err := engine.GetEdge("services-copy", "api-cache")
+		if err != nil || !edge.Weak || !edge.DanglingEnd("cache") {
+			t.Errorf("Expected the imported edge to stay dangling, got %+v, %v", edge, err)
+		}
+	})
+
+	t.Run("Recreate", func(t *testing.T) {
+		// Creating the node again reattaches its weak edges at once
+		if _, err := handler.Handle("NODE.CREATE", []string{"services", "cache", "cache"}); err != nil {
+			t.Fatalf("NODE.CREATE failed: %v", err)
+		}
+		edge, err := engine.GetEdge(graphID, "api-cache")
+		if err != nil || edge.Dangling() || !edge.Weak {
+			t.Fatalf("Expected api-cache to be reattached and still weak, got %+v, %v", edge, err)
+		}
+
+		result, err := analyzer.DepthFirstSearch(graphID, "api", &types.TraversalOptions{MaxDepth: -1, Direction: types.DirectionForward})
+		if err != nil {
+			t.Fatalf("Traversal failed: %v", err)
+		}
+		if len(result.Nodes) != 3 {
+			t.Errorf("Expected the traversal to reach the cache again, got %v", result.Path)
+		}
+		if resp, err := handler.Handle("EDGE.LIST", []string{"services", "ORPHANS"}); err != nil || len(resp.ArrayValue) != 0 {
+			t.Errorf("Expected no orphans, got %v, %v", resp, err)
+		}
+
+		// The normal edge deleted with the node does not come back
+		if _, err := engine.GetEdge(graphID, "cache-db"); err == nil {
+			t.Error("Expected cache-db to stay deleted")
+		}
+
+		// Deleting the node rather than letting it expire marks the edge
+		// the same way
+		if err := engine.DeleteNode(graphID, "cache"); err != nil {
+			t.Fatalf("Failed to delete node: %v", err)
+		}
+		if edge, err := engine.GetEdge(graphID, "api-cache"); err != nil || !edge.DanglingEnd("cache") {
+			t.Errorf("Expected api-cache to dangle after the delete, got %+v, %v", edge, err)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/internal/memgraph"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/redis/protocol"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestWeightedShortestPath tests finding the lightest path by an edge
+// attribute with GetWeightedShortestPath and ANALYSIS.SHORTESTPATH WEIGHT
+func TestWeightedShortestPath(t *testing.T) {
+	graphID := models.GraphID("services")
+	// The direct edge is the shortest path but the heaviest
+	graph := memgraph.New(graphID)
+	for _, id := range []models.NodeID{"gateway", "auth", "users", "db", "cache"} {
+		if err := graph.AddNode(&models.Node{ID: id, Type: "service"}); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+	}
+	for _, edge := range []*models.Edge{
+		{ID: "gateway-auth", FromNodeID: "gateway", ToNodeID: "auth", Type: "calls", Attributes: models.Attributes{"latency": 10.0}},
+		{ID: "auth-db", FromNodeID: "auth", ToNodeID: "db", Type: "reads", Attributes: models.Attributes{"latency": 10.0}},
+		{ID: "gateway-users", FromNodeID: "gateway", ToNodeID: "users", Type: "calls", Attributes: models.Attributes{"latency": 2.5}},
+		{ID: "users-db", FromNodeID: "users", ToNodeID: "db", Type: "reads", Attributes: models.Attributes{"latency": 10}},
+		{ID: "gateway-db", FromNodeID: "gateway", ToNodeID: "db", Type: "reads", Attributes: models.Attributes{"latency": 50.0}},
+		{ID: "db-cache", FromNodeID: "db", ToNodeID: "cache", Type: "writes", Attributes: models.Attributes{"latency": "fast"}},
+	} {
+		if err := graph.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge failed: %v", err)
+		}
+	}
+	analyzer := analysis.NewGraphAnalyzer(graph)
+	latency := types.EdgeWeight{Attribute: "latency"}
+
+	t.Run("Lightest", func(t *testing.T) {
+		result, err := analyzer.GetWeightedShortestPath(graphID, "gateway", "db", latency, nil)
+		if err != nil {
+			t.Fatalf("GetWeightedShortestPath failed: %v", err)
+		}
+		if expected := []models.NodeID{"gateway", "users", "db"}; !reflect.DeepEqual(result.Path, expected) {
+			t.Errorf("Expected path %v, got %v", expected, result.Path)
+		}
+		if expected := []models.EdgeID{"gateway-users", "users-db"}; !reflect.DeepEqual(result.Edges, expected) {
+			t.Errorf("Expected edges %v, got %v", expected, result.Edges)
+		}
+		if result.TotalWeight != 12.5 || result.Length != 2 {
+			t.Errorf("Expected weight 12.5 over 2 edges, got %v over %d", result.TotalWeight, result.Length)
+		}
+
+		// Unweighted, the direct edge is shortest
+		unweighted, err := analyzer.GetShortestPath(graphID, "gateway", "db", nil)
+		if err != nil || unweighted.Length != 1 {
+			t.Errorf("Expected the unweighted path to take the direct edge, got %+v, %v", unweighted, err)
+		}
+
+		backward, err := analyzer.GetWeightedShortestPath(graphID, "db", "gateway", latency, &types.TraversalOptions{Direction: types.DirectionBackward})
+		if err != nil || backward.TotalWeight != 12.5 || !reflect.DeepEqual(backward.Path, []models.NodeID{"db", "users", "gateway"}) {
+			t.Errorf("Expected the same path backward, got %+v, %v", backward, err)
+		}
+
+		same, err := analyzer.GetWeightedShortestPath(graphID, "gateway", "gateway", latency, nil)
+		if err != nil || same.TotalWeight != 0 || len(same.Path) != 1 {
+			t.Errorf("Expected a path of weight 0 to the same node, got %+v, %v", same, err)
+		}
+	})
+
+	t.Run("InvalidWeights", func(t *testing.T) {
+		if _, err := analyzer.GetWeightedShortestPath(graphID, "gateway", "cache", latency, nil); !errors.Is(err, analysis.ErrInvalidWeight) {
+			t.Errorf("Expected a non-numeric weight to fail, got %v", err)
+		}
+		one := 1.0
+		result, err := analyzer.GetWeightedShortestPath(graphID, "gateway", "cache", types.EdgeWeight{Attribute: "latency", Default: &one}, nil)
+		if err != nil || result.TotalWeight != 13.5 {
+			t.Errorf("Expected the default weight for db-cache, got %+v, %v", result, err)
+		}
+
+		negative := memgraph.New(graphID)
+		negative.AddNode(&models.Node{ID: "a", Type: "service"})
+		negative.AddNode(&models.Node{ID: "b", Type: "service"})
+		negative.AddEdge(&models.Edge{ID: "a-b", FromNodeID: "a", ToNodeID: "b", Type: "calls", Attributes: models.Attributes{"latency": -1.0}})
+		if _, err := analysis.NewGraphAnalyzer(negative).GetWeightedShortestPath(graphID, "a", "b", latency, nil); !errors.Is(err, analysis.ErrInvalidWeight) {
+			t.Errorf("Expected a negative weight to fail, got %v", err)
+		}
+
+		if _, err := analyzer.GetWeightedShortestPath(graphID, "cache", "gateway", latency, nil); !errors.Is(err, analysis.ErrNoPath) {
+			t.Errorf("Expected ErrNoPath, got %v", err)
+		}
+		if _, err := analyzer.GetWeightedShortestPath(graphID, "gateway", "missing", latency, nil); !errors.Is(err, storage.ErrNodeNotFound) {
+			t.Errorf("Expected ErrNodeNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		testPath := filepath.Join(os.TempDir(), "pathwaydb_weighted_path_test")
+		os.RemoveAll(testPath)
+		defer os.RemoveAll(testPath)
+		engine := storage.NewBadgerEngine()
+		if err := engine.Open(testPath); err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer engine.Close()
+		handler := redis.NewCommandHandler(engine)
+
+		nodes, _ := graph.ListNodes(graphID)
+		edges, _ := graph.ListEdges(graphID)
+		if err := engine.CreateGraph(&models.Graph{ID: graphID, Name: "services"}); err != nil {
+			t.Fatalf("Failed to create graph: %v", err)
+		}
+		if err := engine.CreateNodes(graphID, nodes); err != nil {
+			t.Fatalf("CreateNodes failed: %v", err)
+		}
+		if err := engine.CreateEdges(graphID, edges); err != nil {
+			t.Fatalf("CreateEdges failed: %v", err)
+		}
+		shortestPath := func(args ...string) ([]string, error) {
+			resp, err := handler.Handle("ANALYSIS.SHORTESTPATH", append([]string{string(graphID)}, args...))
+			if err != nil {
+				return nil, err
+			}
+			if resp.Type != protocol.ResponseTypeArray {
+				return []string{resp.StringValue}, nil
+			}
+			return resp.ArrayValue, nil
+		}
+
+		got, err := shortestPath("gateway", "db", "WEIGHT", "latency")
+		if expected := []string{"gateway:service->gateway-users:calls->users:service->users-db:reads->db:service", "12.5"}; err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, got, err)
+		}
+		got, err = shortestPath("gateway", "cache", "weight", "latency", "DEFAULT", "0.25", "FORMAT", "simple")
+		if expected := []string{"gateway:service", "users:service", "db:service", "cache:service", "12.75"}; err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, got, err)
+		}
+		got, err = shortestPath("gateway", "db", "WEIGHT", "latency", "FORMAT", "json")
+		if err != nil || len(got) != 1 {
+			t.Fatalf("Expected a JSON reply, got %v, %v", got, err)
+		}
+		var result types.PathResult
+		if err := json.Unmarshal([]byte(got[0]), &result); err != nil || result.TotalWeight != 12.5 {
+			t.Errorf("Expected total_weight 12.5, got %+v, %v", result, err)
+		}
+
+		for _, args := range [][]string{
+			{"gateway", "cache", "WEIGHT", "latency"},
+			{"gateway", "db", "WEIGHT", "latency", "DEFAULT", "-1"},
+			{"gateway", "db", "WEIGHT", "latency", "DEFAULT", "NaN"},
+			{"gateway", "db", "WEIGHT", "latency", "COUNT"},
+			{"gateway", "db", "WEIGHT", "latency", "DAG"},
+		} {
+			if _, err := shortestPath(args...); err == nil {
+				t.Errorf("Expected ANALYSIS.SHORTESTPATH %v to fail", args)
+			}
+		}
+	})
+}
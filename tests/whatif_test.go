@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/analysis"
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+	"github.com/ywadi/PathwayDB/types"
+)
+
+// TestWhatIf tests what-if overlays on the microservices graph
+func TestWhatIf(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_whatif_test")
+	os.RemoveAll(testPath)
+	engine := storage.NewBadgerEngine()
+	defer func() {
+		engine.Close()
+		os.RemoveAll(testPath)
+	}()
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	graphID := models.GraphID("whatif")
+	createMicroservicesGraph(t, engine, graphID)
+	analyzer := analysis.NewGraphAnalyzer(engine)
+
+	removeEdges := func(ids ...models.EdgeID) *types.Overlay {
+		overlay := &types.Overlay{RemovedEdges: make(map[models.EdgeID]bool)}
+		for _, id := range ids {
+			overlay.RemovedEdges[id] = true
+		}
+		return overlay
+	}
+	reachable := func(t *testing.T, from, to models.NodeID, overlay *types.Overlay) bool {
+		t.Helper()
+		ok, err := analyzer.WhatIfReachable(graphID, from, to, overlay)
+		if err != nil {
+			t.Fatalf("WhatIfReachable failed: %v", err)
+		}
+		return ok
+	}
+
+	t.Run("Reachable", func(t *testing.T) {
+		// Without gateway-user, user-service is cut off from frontend but
+		// user-db is still reached through auth-service
+		overlay := removeEdges("gateway-user")
+		if !reachable(t, "frontend", "user-service", nil) || reachable(t, "frontend", "user-service", overlay) {
+			t.Error("Expected frontend to lose user-service")
+		}
+		if !reachable(t, "frontend", "user-db", overlay) {
+			t.Error("Expected frontend to reach user-db through auth-service")
+		}
+
+		// Without the auth-service path as well, user-db is lost
+		overlay = removeEdges("gateway-user", "auth-userdb")
+		if reachable(t, "frontend", "user-db", overlay) {
+			t.Error("Expected frontend to lose user-db")
+		}
+		if !reachable(t, "frontend", "order-db", overlay) {
+			t.Error("Expected frontend to keep order-db")
+		}
+
+		// Removing a node removes its edges
+		overlay = &types.Overlay{RemovedNodes: map[models.NodeID]bool{"auth-service": true}}
+		if !reachable(t, "frontend", "user-db", overlay) || reachable(t, "frontend", "redis-cache", overlay) {
+			t.Error("Expected only auth-service's dependencies to be lost")
+		}
+		if reachable(t, "frontend", "auth-service", overlay) {
+			t.Error("Expected a removed node to be unreachable")
+		}
+
+		// An added edge restores a path
+		overlay = removeEdges("gateway-user")
+		overlay.AddedEdges = []*models.Edge{{ID: "frontend-user", Type: "depends_on", FromNodeID: "frontend", ToNodeID: "user-service"}}
+		if !reachable(t, "frontend", "user-service", overlay) {
+			t.Error("Expected the added edge to be followed")
+		}
+
+		// Nothing is written
+		if _, err := engine.GetEdge(graphID, "gateway-user"); err != nil {
+			t.Errorf("Expected gateway-user to remain, got %v", err)
+		}
+		if _, err := engine.GetEdge(graphID, "frontend-user"); err == nil {
+			t.Error("Expected the added edge not to be stored")
+		}
+	})
+
+	t.Run("ShortestPath", func(t *testing.T) {
+		path, err := analyzer.WhatIfShortestPath(graphID, "api-gateway", "logger", removeEdges("gateway-auth", "gateway-user"))
+		if err != nil {
+			t.Fatalf("WhatIfShortestPath failed: %v", err)
+		}
+		expected := []models.NodeID{"api-gateway", "order-service", "logger"}
+		if !reflect.DeepEqual(path.Path, expected) {
+			t.Errorf("Expected %v, got %v", expected, path.Path)
+		}
+		if _, err := analyzer.WhatIfShortestPath(graphID, "frontend", "user-service", removeEdges("gateway-user")); err == nil {
+			t.Error("Expected no path to user-service")
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		summary, err := analyzer.WhatIfStats(graphID,
+			[]models.NodeID{"frontend", "api-gateway"}, []models.NodeID{"user-db", "order-db"},
+			removeEdges("gateway-user", "auth-userdb"))
+		if err != nil {
+			t.Fatalf("WhatIfStats failed: %v", err)
+		}
+		expected := &types.WhatIfSummary{
+			Pairs:           4,
+			ReachableBefore: 4,
+			ReachableAfter:  2,
+			Lost:            []types.NodePair{{From: "frontend", To: "user-db"}, {From: "api-gateway", To: "user-db"}},
+			Gained:          []types.NodePair{},
+		}
+		if !reflect.DeepEqual(summary, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, summary)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		handler := redis.NewCommandHandler(engine)
+
+		resp, err := handler.Handle("ANALYSIS.WHATIF", []string{"whatif", "REMOVE", "EDGES", "gateway-user", "CHECK", "REACHABLE", "frontend", "user-service"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.WHATIF failed: %v", err)
+		}
+		if expected := []string{"reachable_before", "1", "reachable_after", "0"}; !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+		resp, err = handler.Handle("ANALYSIS.WHATIF", []string{"whatif", "REMOVE", "EDGES", "gateway-user", "CHECK", "REACHABLE", "frontend", "user-db"})
+		if err != nil || !reflect.DeepEqual(resp.ArrayValue, []string{"reachable_before", "1", "reachable_after", "1"}) {
+			t.Errorf("Expected user-db to stay reachable, got %v, %v", resp, err)
+		}
+
+		resp, err = handler.Handle("ANALYSIS.WHATIF", []string{"whatif", "REMOVE", "EDGES", "gateway-user", "REMOVE", "NODES", "auth-service",
+			"CHECK", "SHORTESTPATH", "frontend", "user-db"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.WHATIF failed: %v", err)
+		}
+		if resp.ArrayValue != nil {
+			t.Errorf("Expected null without a path, got %v", resp.ArrayValue)
+		}
+
+		resp, err = handler.Handle("ANALYSIS.WHATIF", []string{"whatif", "REMOVE", "EDGES", "gateway-user,auth-userdb",
+			"SUMMARY", "FROMTYPE", "application", "TOTYPE", "database"})
+		if err != nil {
+			t.Fatalf("ANALYSIS.WHATIF SUMMARY failed: %v", err)
+		}
+		expected := []string{"pairs", "2", "reachable_before", "2", "reachable_after", "1", "lost", "1", "frontend->user-db"}
+		if !reflect.DeepEqual(resp.ArrayValue, expected) {
+			t.Errorf("Expected %v, got %v", expected, resp.ArrayValue)
+		}
+
+		for _, args := range [][]string{
+			{"whatif", "CHECK", "REACHABLE", "frontend", "user-db"},
+			{"whatif", "REMOVE", "EDGES", "no-such-edge", "CHECK", "REACHABLE", "frontend", "user-db"},
+			{"whatif", "REMOVE", "PORTS", "x", "CHECK", "REACHABLE", "frontend", "user-db"},
+			{"whatif", "REMOVE", "EDGES", "gateway-user"},
+			{"whatif", "REMOVE", "EDGES", "gateway-user", "CHECK", "FASTEST", "frontend", "user-db"},
+			{"whatif", "REMOVE", "EDGES", "gateway-user", "SUMMARY", "FROMTYPE", "application"},
+		} {
+			if _, err := handler.Handle("ANALYSIS.WHATIF", args); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/redis"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+// TestWideAttributes tests paging through the attributes of a node and an
+// edge with thousands of keys with NODE.GET and EDGE.GET
+func TestWideAttributes(t *testing.T) {
+	testPath := filepath.Join(os.TempDir(), "pathwaydb_wide_attrs_test")
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	// Unpadded numbers, so key order differs from insertion order
+	const width = 5000
+
+	// Wider than the default limit on attribute keys
+	limits := models.DefaultAttributeLimits()
+	limits.MaxKeys = width
+	engine := storage.NewBadgerEngine(storage.WithAttributeLimits(limits))
+	if err := engine.Open(testPath); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer engine.Close()
+	handler := redis.NewCommandHandler(engine)
+
+	attributes := make(models.Attributes, width)
+	var keys []string
+	for i := 0; i < width; i++ {
+		key := fmt.Sprintf("attr%d", i)
+		attributes[key] = float64(i)
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if err := engine.CreateGraph(&models.Graph{ID: "cmdb", Name: "cmdb"}); err != nil {
+		t.Fatalf("Failed to create graph: %v", err)
+	}
+	for _, id := range []models.NodeID{"host", "rack"} {
+		if err := engine.CreateNode("cmdb", &models.Node{ID: id, Type: "ci", Attributes: attributes}); err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+	}
+	if err := engine.CreateEdge("cmdb", &models.Edge{ID: "in-rack", Type: "mounted_in", FromNodeID: "host", ToNodeID: "rack", Attributes: attributes}); err != nil {
+		t.Fatalf("Failed to create edge: %v", err)
+	}
+
+	// page reads one page with ATTRS and checks the reply's other fields
+	page := func(t *testing.T, command, id string, fields, attrIndex int, offset, count int) []string {
+		t.Helper()
+		resp, err := handler.Handle(command, []string{"cmdb", id, "ATTRS", strconv.Itoa(offset), strconv.Itoa(count)})
+		if err != nil {
+			t.Fatalf("%s ATTRS %d %d failed: %v", command, offset, count, err)
+		}
+		if len(resp.ArrayValue) != fields+1 || resp.ArrayValue[0] != id || resp.ArrayValue[fields] != "5000" {
+			t.Fatalf("Expected the details followed by the total of 5000 keys, got %d fields ending %q", len(resp.ArrayValue), resp.ArrayValue[len(resp.ArrayValue)-1])
+		}
+		var got models.Attributes
+		if err := json.Unmarshal([]byte(resp.ArrayValue[attrIndex]), &got); err != nil {
+			t.Fatalf("Invalid attributes JSON: %v", err)
+		}
+		pageKeys := make([]string, 0, len(got))
+		for key, value := range got {
+			if value != attributes[key] {
+				t.Errorf("Expected %s to be %v, got %v", key, attributes[key], value)
+			}
+			pageKeys = append(pageKeys, key)
+		}
+		sort.Strings(pageKeys)
+		return pageKeys
+	}
+
+	for _, tc := range []struct {
+		command   string
+		id        string
+		fields    int
+		attrIndex int
+	}{
+		{"NODE.GET", "host", 4, 2},
+		{"EDGE.GET", "in-rack", 6, 4},
+	} {
+		t.Run(tc.command, func(t *testing.T) {
+			// Pages of 300 cover every key once, in order, the last one short
+			var all []string
+			for offset := 0; offset < width; offset += 300 {
+				got := page(t, tc.command, tc.id, tc.fields, tc.attrIndex, offset, 300)
+				if expected := keys[offset:min(offset+300, width)]; !reflect.DeepEqual(got, expected) {
+					t.Fatalf("Expected keys %s to %s at offset %d, got %d keys", expected[0], expected[len(expected)-1], offset, len(got))
+				}
+				all = append(all, got...)
+			}
+			if !reflect.DeepEqual(all, keys) {
+				t.Errorf("Expected the pages to hold every key once")
+			}
+
+			// Boundaries
+			if got := page(t, tc.command, tc.id, tc.fields, tc.attrIndex, 4999, 10); !reflect.DeepEqual(got, keys[4999:]) {
+				t.Errorf("Expected only the last key, got %v", got)
+			}
+			if got := page(t, tc.command, tc.id, tc.fields, tc.attrIndex, width, 10); len(got) != 0 {
+				t.Errorf("Expected an empty page past the end, got %v", got)
+			}
+			if got := page(t, tc.command, tc.id, tc.fields, tc.attrIndex, 0, width+1); !reflect.DeepEqual(got, keys) {
+				t.Errorf("Expected one page larger than the map to hold every key")
+			}
+
+			// The same page twice is the same
+			if first, second := page(t, tc.command, tc.id, tc.fields, tc.attrIndex, 1234, 5), page(t, tc.command, tc.id, tc.fields, tc.attrIndex, 1234, 5); !reflect.DeepEqual(first, second) || !reflect.DeepEqual(first, keys[1234:1239]) {
+				t.Errorf("Expected a stable page, got %v and %v", first, second)
+			}
+
+			resp, err := handler.Handle(tc.command, []string{"cmdb", tc.id, "attrkeys"})
+			if err != nil {
+				t.Fatalf("%s ATTRKEYS failed: %v", tc.command, err)
+			}
+			if !reflect.DeepEqual(resp.ArrayValue, keys) {
+				t.Errorf("Expected ATTRKEYS to list the %d sorted keys, got %d", width, len(resp.ArrayValue))
+			}
+
+			// Without options the reply is unchanged
+			resp, err = handler.Handle(tc.command, []string{"cmdb", tc.id})
+			if err != nil || len(resp.ArrayValue) != tc.fields {
+				t.Fatalf("Expected %d fields without options, got %+v, %v", tc.fields, len(resp.ArrayValue), err)
+			}
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"cmdb", "host", "ATTRS"},
+			{"cmdb", "host", "ATTRS", "0"},
+			{"cmdb", "host", "ATTRS", "-1", "10"},
+			{"cmdb", "host", "ATTRS", "0", "0"},
+			{"cmdb", "host", "ATTRS", "0", "10", "ATTRKEYS"},
+			{"cmdb", "host", "ATTRKEYS", "5"},
+			{"cmdb", "host", "WIDE"},
+		} {
+			if _, err := handler.Handle("NODE.GET", args); err == nil {
+				t.Errorf("Expected NODE.GET %v to be rejected", args)
+			}
+		}
+	})
+}
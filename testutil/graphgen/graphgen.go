@@ -0,0 +1,260 @@
+// Package graphgen generates synthetic graphs for benchmarks and load tests.
+// A Generator builds a graph in memory from its parameters and seed alone,
+// so the same call always gives the same nodes and edges, and Write stores
+// it in a graph of a storage engine. Decorators such as WithTypes and
+// WithAttributes change what a generator builds.
+package graphgen
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/ywadi/PathwayDB/models"
+	"github.com/ywadi/PathwayDB/storage"
+)
+
+const (
+	// DefaultNodeType is the type of generated nodes before WithTypes
+	DefaultNodeType models.NodeType = "node"
+	// DefaultEdgeType is the type of generated edges before WithTypes
+	DefaultEdgeType models.EdgeType = "depends_on"
+
+	// writeBatchSize is the number of nodes or edges Write creates in one
+	// transaction
+	writeBatchSize = 1000
+)
+
+// Graph is a generated graph. No generator builds a cycle: edges lead from
+// a node to later ones in a chain or DAG, from a hub to its leaves, and from
+// a node to earlier ones in a scale-free graph.
+type Graph struct {
+	Nodes []*models.Node
+	Edges []*models.Edge
+}
+
+// Generator builds a graph
+type Generator func() *Graph
+
+// nodeID is the ID of the ith generated node. IDs are padded so that ID
+// order is creation order up to a million nodes.
+func nodeID(i int) models.NodeID {
+	return models.NodeID(fmt.Sprintf("n%06d", i))
+}
+
+// builder accumulates the nodes and edges of a graph
+type builder struct {
+	graph *Graph
+}
+
+func (b *builder) node(id models.NodeID) {
+	b.graph.Nodes = append(b.graph.Nodes, &models.Node{ID: id, Type: DefaultNodeType})
+}
+
+func (b *builder) edge(from, to models.NodeID) {
+	b.graph.Edges = append(b.graph.Edges, &models.Edge{
+		ID:         models.EdgeID(fmt.Sprintf("e%07d", len(b.graph.Edges))),
+		Type:       DefaultEdgeType,
+		FromNodeID: from,
+		ToNodeID:   to,
+	})
+}
+
+// Chain generates n nodes, each with an edge to the next
+func Chain(n int) Generator {
+	return func() *Graph {
+		b := &builder{graph: &Graph{}}
+		for i := 0; i < n; i++ {
+			b.node(nodeID(i))
+			if i > 0 {
+				b.edge(nodeID(i-1), nodeID(i))
+			}
+		}
+		return b.graph
+	}
+}
+
+// Star generates a hub node with an edge to each of leaves nodes
+func Star(hub models.NodeID, leaves int) Generator {
+	return func() *Graph {
+		b := &builder{graph: &Graph{}}
+		b.node(hub)
+		for i := 0; i < leaves; i++ {
+			b.node(nodeID(i))
+			b.edge(hub, nodeID(i))
+		}
+		return b.graph
+	}
+}
+
+// RandomDAG generates nodes nodes, each with edges to edgesPerNode nodes
+// created after it, chosen at random. Nodes near the end have fewer
+// successors to choose from, and the last has none.
+func RandomDAG(nodes, edgesPerNode int, seed int64) Generator {
+	return func() *Graph {
+		rng := rand.New(rand.NewSource(seed))
+		b := &builder{graph: &Graph{}}
+		for i := 0; i < nodes; i++ {
+			b.node(nodeID(i))
+		}
+		for i := 0; i < nodes; i++ {
+			later := nodes - i - 1
+			count := edgesPerNode
+			if count > later {
+				count = later
+			}
+			targets := pickDistinct(rng, later, count)
+			for _, t := range targets {
+				b.edge(nodeID(i), nodeID(i+1+t))
+			}
+		}
+		return b.graph
+	}
+}
+
+// ScaleFree generates nodes nodes by Barabási–Albert preferential
+// attachment: after m initial nodes, each node gets edges to m distinct
+// earlier nodes chosen with probability proportional to their degree, so a
+// few hubs gather most edges.
+func ScaleFree(nodes, m int, seed int64) Generator {
+	return func() *Graph {
+		rng := rand.New(rand.NewSource(seed))
+		b := &builder{graph: &Graph{}}
+		if m < 1 {
+			m = 1
+		}
+
+		// repeated lists each node once per edge it has, so picking from
+		// it uniformly picks nodes in proportion to their degree
+		var repeated []int
+		targets := make([]int, 0, m)
+		for i := 0; i < nodes && i < m; i++ {
+			b.node(nodeID(i))
+			targets = append(targets, i)
+		}
+		for i := m; i < nodes; i++ {
+			b.node(nodeID(i))
+			for _, t := range targets {
+				b.edge(nodeID(i), nodeID(t))
+				repeated = append(repeated, t, i)
+			}
+
+			chosen := make(map[int]bool, m)
+			targets = targets[:0]
+			for len(targets) < m {
+				t := repeated[rng.Intn(len(repeated))]
+				if !chosen[t] {
+					chosen[t] = true
+					targets = append(targets, t)
+				}
+			}
+			sort.Ints(targets)
+		}
+		return b.graph
+	}
+}
+
+// WithTypes gives the nodes and edges of g types from nodeTypes and
+// edgeTypes, chosen at random from seed. An empty list leaves those types
+// as they are.
+func WithTypes(g Generator, nodeTypes []models.NodeType, edgeTypes []models.EdgeType, seed int64) Generator {
+	return func() *Graph {
+		graph := g()
+		rng := rand.New(rand.NewSource(seed))
+		if len(nodeTypes) > 0 {
+			for _, node := range graph.Nodes {
+				node.Type = nodeTypes[rng.Intn(len(nodeTypes))]
+			}
+		}
+		if len(edgeTypes) > 0 {
+			for _, edge := range graph.Edges {
+				edge.Type = edgeTypes[rng.Intn(len(edgeTypes))]
+			}
+		}
+		return graph
+	}
+}
+
+// WithAttributes gives the nodes and edges of g keys attributes each, named
+// attr0 onwards, alternating numbers and strings chosen at random from
+// seed. Numbers are float64, as they read back from JSON.
+func WithAttributes(g Generator, keys int, seed int64) Generator {
+	return func() *Graph {
+		graph := g()
+		rng := rand.New(rand.NewSource(seed))
+		payload := func() models.Attributes {
+			attributes := make(models.Attributes, keys)
+			for k := 0; k < keys; k++ {
+				if k%2 == 0 {
+					attributes[fmt.Sprintf("attr%d", k)] = float64(rng.Intn(1000))
+				} else {
+					attributes[fmt.Sprintf("attr%d", k)] = fmt.Sprintf("value-%d", rng.Intn(1000))
+				}
+			}
+			return attributes
+		}
+		for _, node := range graph.Nodes {
+			node.Attributes = payload()
+		}
+		for _, edge := range graph.Edges {
+			edge.Attributes = payload()
+		}
+		return graph
+	}
+}
+
+// Write creates the nodes and edges of graph in graphID, which must exist,
+// in batches of CreateNodes and CreateEdges calls. Nodes and edges without
+// a creation time are given the time Write was called.
+func Write(engine storage.StorageEngine, graphID models.GraphID, graph *Graph) error {
+	now := time.Now()
+	for start := 0; start < len(graph.Nodes); start += writeBatchSize {
+		batch := graph.Nodes[start:min(start+writeBatchSize, len(graph.Nodes))]
+		for _, node := range batch {
+			if node.CreatedAt.IsZero() {
+				node.CreatedAt, node.UpdatedAt = now, now
+			}
+		}
+		if err := engine.CreateNodes(graphID, batch); err != nil {
+			return fmt.Errorf("failed to create nodes: %w", err)
+		}
+	}
+	for start := 0; start < len(graph.Edges); start += writeBatchSize {
+		batch := graph.Edges[start:min(start+writeBatchSize, len(graph.Edges))]
+		for _, edge := range batch {
+			if edge.CreatedAt.IsZero() {
+				edge.CreatedAt, edge.UpdatedAt = now, now
+			}
+		}
+		if err := engine.CreateEdges(graphID, batch); err != nil {
+			return fmt.Errorf("failed to create edges: %w", err)
+		}
+	}
+	return nil
+}
+
+// pickDistinct returns count distinct integers from [0, n) in order,
+// chosen at random
+func pickDistinct(rng *rand.Rand, n, count int) []int {
+	if count <= 0 {
+		return nil
+	}
+	// A partial Fisher-Yates shuffle over a sparse permutation, so choosing
+	// few of many costs count steps
+	swapped := make(map[int]int, count)
+	at := func(i int) int {
+		if v, ok := swapped[i]; ok {
+			return v
+		}
+		return i
+	}
+	picked := make([]int, count)
+	for i := 0; i < count; i++ {
+		j := i + rng.Intn(n-i)
+		picked[i] = at(j)
+		swapped[j] = at(i)
+	}
+	sort.Ints(picked)
+	return picked
+}
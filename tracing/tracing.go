@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName names the tracer every PathwayDB span is created with
+const TracerName = "github.com/ywadi/PathwayDB"
+
+// ServiceName is the service.name spans are exported under
+const ServiceName = "pathwaydb"
+
+// NewProvider returns a tracer provider that batches spans to an OTLP/HTTP
+// collector at endpoint, given as a URL such as http://localhost:4318 or as
+// host:port, which is reached over plain HTTP. An empty endpoint uses the
+// exporter's defaults, including the OTEL_EXPORTER_OTLP_* environment
+// variables. Spans are sent in the background; call Shutdown on the
+// provider to flush them.
+func NewProvider(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	var opts []otlptracehttp.Option
+	switch {
+	case strings.Contains(endpoint, "://"):
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	case endpoint != "":
+		opts = append(opts, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+	), nil
+}
+
+// Start starts a span as a child of the span in ctx, using that span's
+// provider. Without a span in ctx the returned span is a no-op, so code
+// called outside a traced command pays for no tracing.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.IsRecording() {
+		return ctx, parent
+	}
+	return parent.TracerProvider().Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"time"
+
 	"github.com/ywadi/PathwayDB/models"
 )
 
@@ -10,6 +12,50 @@ type TraversalResult struct {
 	Edges    []*models.Edge `json:"edges"`
 	Path     []models.NodeID `json:"path"`
 	Distance int            `json:"distance"`
+
+	// FanoutLimitedNodes lists the nodes whose edges were cut to MaxFanout
+	FanoutLimitedNodes []models.NodeID `json:"fanout_limited_nodes,omitempty"`
+
+	// Hops lists the step each node after the first was reached by, when
+	// PassThroughNodeTypes is set. Edges then holds the edges of every hop.
+	Hops []Hop `json:"hops,omitempty"`
+
+	// DanglingEdges lists the weak edges from the nodes reached to deleted
+	// nodes, when IncludeDangling is set
+	DanglingEdges []*models.Edge `json:"dangling_edges,omitempty"`
+
+	// Terminal is why the path ended, set on the paths of AllPathsTraversal
+	Terminal TerminalReason `json:"terminal,omitempty"`
+}
+
+// TerminalReason is why a path of AllPathsTraversal ended
+type TerminalReason string
+
+const (
+	// TerminalLeaf ends a path at a node with no edges left to follow
+	TerminalLeaf TerminalReason = "leaf"
+
+	// TerminalMaxDepth ends a path at MaxDepth, at a node with edges that
+	// would have been followed
+	TerminalMaxDepth TerminalReason = "max_depth"
+
+	// TerminalCycle ends a path that returns to a node already on it. The
+	// path is the cycle, closing node included.
+	TerminalCycle TerminalReason = "cycle"
+
+	// TerminalStopCondition ends a path before a node matching
+	// StopCondition
+	TerminalStopCondition TerminalReason = "stop_condition"
+)
+
+// Hop is one step of a traversal or path. A hop is a single edge unless it
+// crosses pass-through nodes, which Via lists in the order they are crossed,
+// between its edges.
+type Hop struct {
+	From  models.NodeID   `json:"from"`
+	To    models.NodeID   `json:"to"`
+	Edges []models.EdgeID `json:"edges"`
+	Via   []models.NodeID `json:"via,omitempty"`
 }
 
 // CycleResult represents a detected cycle in the graph
@@ -30,6 +76,19 @@ type GraphStats struct {
 	OrphanNodeCount    int                        `json:"orphan_node_count"`
 	HasCycles          bool                       `json:"has_cycles"`
 	ConnectedComponents int                       `json:"connected_components"`
+	ParallelEdgeGroupCount int                    `json:"parallel_edge_group_count"`
+	MaxEdgeMultiplicity    int                    `json:"max_edge_multiplicity"`
+	// DanglingEdgeCount counts the weak edges, included in EdgeCount, with
+	// a deleted endpoint
+	DanglingEdgeCount int `json:"dangling_edge_count"`
+}
+
+// ParallelEdgeGroup represents edges of one type sharing the same endpoints
+type ParallelEdgeGroup struct {
+	FromNodeID models.NodeID   `json:"from_node_id"`
+	ToNodeID   models.NodeID   `json:"to_node_id"`
+	Type       models.EdgeType `json:"type"`
+	Count      int             `json:"count"`
 }
 
 // NodeMetrics represents metrics for a specific node
@@ -53,14 +112,75 @@ type PathResult struct {
 	Path       []models.NodeID `json:"path"`
 	Length     int             `json:"length"`
 	Edges      []models.EdgeID `json:"edges"`
+
+	// FanoutLimitedNodes lists the nodes whose edges were cut to MaxFanout
+	FanoutLimitedNodes []models.NodeID `json:"fanout_limited_nodes,omitempty"`
+
+	// Hops lists the steps of Path when PassThroughNodeTypes is set, so
+	// Length counts hops rather than edges
+	Hops []Hop `json:"hops,omitempty"`
+
+	// TotalWeight is the sum of the weights of Edges, for paths found by
+	// GetWeightedShortestPath
+	TotalWeight float64 `json:"total_weight,omitempty"`
+}
+
+// EdgeWeight says how GetWeightedShortestPath weighs edges: by the numeric
+// value of their Attribute. Edges without a numeric value weigh Default if
+// it is set, and are an error otherwise.
+type EdgeWeight struct {
+	Attribute string
+	Default   *float64
+}
+
+// Subgraph is a set of nodes and the edges between them. From
+// ShortestPathDAG it is the shortest-path DAG of two nodes: the edges on at
+// least one shortest path, and the nodes they join.
+type Subgraph struct {
+	Nodes []*models.Node `json:"nodes"`
+	Edges []*models.Edge `json:"edges"`
+
+	// Distance is the length of the shortest paths, in edges
+	Distance int `json:"distance"`
+
+	// Critical lists the edges on every shortest path: removing any one of
+	// them lengthens the distance or disconnects the nodes
+	Critical []models.EdgeID `json:"critical"`
 }
 
-// DependencyTree represents a hierarchical dependency structure
+// DependencyTree represents a hierarchical dependency structure, as built by
+// BuildDependencyTree
 type DependencyTree struct {
-	NodeID   models.NodeID    `json:"node_id"`
-	Node     *models.Node     `json:"node"`
-	Children []*DependencyTree `json:"children"`
-	Depth    int              `json:"depth"`
+	NodeID   models.NodeID     `json:"node_id"`
+	Node     *models.Node      `json:"node"`
+	Children []*DependencyTree `json:"children,omitempty"`
+	Depth    int               `json:"depth"`
+
+	// Truncated is set when the node has dependencies that are not listed
+	// here: they are under the node where it is expanded, or beyond
+	// MaxDepth, or the node is an ancestor and Cycle is set
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Cycle is set when the node is its own dependency through this branch:
+	// it is one of the ancestors of this place in the tree
+	Cycle bool `json:"cycle,omitempty"`
+}
+
+// DependencyEntry is a node GetDependenciesWithDepth or
+// GetDependentsWithDepth reached, with the depth it was first reached at.
+// Direct dependencies are at depth 1.
+type DependencyEntry struct {
+	Node   *models.Node `json:"node"`
+	Depth  int          `json:"depth"`
+	Direct bool         `json:"direct"`
+}
+
+// TopologicalEntry is a node in the order TopologicalSort returns. Level is
+// the length of the longest chain of edges leading to the node, so nodes of
+// the same level do not depend on each other.
+type TopologicalEntry struct {
+	Node  *models.Node `json:"node"`
+	Level int          `json:"level"`
 }
 
 // TraversalOptions provides options for graph traversal
@@ -70,8 +190,51 @@ type TraversalOptions struct {
 	NodeTypes    []models.NodeType          `json:"node_types"`
 	Direction    TraversalDirection         `json:"direction"`
 	StopCondition func(*models.Node) bool    `json:"-"`
+
+	// MinDepth, when positive, leaves out the nodes a traversal reaches at a
+	// smaller depth and the paths with fewer edges. Nodes above it are
+	// still traversed to reach the deeper ones.
+	MinDepth int `json:"min_depth,omitempty"`
+
+	// UpdatedBefore, when set, only includes nodes last updated before this time
+	UpdatedBefore *time.Time `json:"updated_before,omitempty"`
+
+	// MaxFanout, when positive, expands at most this many edges of any node,
+	// chosen by FanoutStrategy. FanoutSeed seeds FanoutRandom.
+	MaxFanout      int            `json:"max_fanout,omitempty"`
+	FanoutStrategy FanoutStrategy `json:"fanout_strategy,omitempty"`
+	FanoutSeed     int64          `json:"fanout_seed,omitempty"`
+
+	// EdgeTypeTransitions, when not nil, is a path grammar: an edge may only
+	// be followed if its type is listed under the type of the edge the
+	// current node was reached by, or under TransitionStart for edges
+	// leaving the start node. A type without an entry ends the path.
+	EdgeTypeTransitions map[models.EdgeType][]models.EdgeType `json:"edge_type_transitions,omitempty"`
+
+	// PassThroughNodeTypes lists connector node types that are crossed but
+	// never reported: the edges into and out of such a node form one hop,
+	// counted once toward depth and path length. The start node and the
+	// target of a path search are reported whatever their type.
+	PassThroughNodeTypes []models.NodeType `json:"pass_through_node_types,omitempty"`
+
+	// IncludeDangling reports the weak edges leading from a reached node to
+	// a deleted one in DanglingEdges. They are never followed, and without
+	// it they are ignored.
+	IncludeDangling bool `json:"include_dangling,omitempty"`
 }
 
+// TransitionStart is the EdgeTypeTransitions key listing the edge types that
+// may leave the start node
+const TransitionStart models.EdgeType = ""
+
+// FanoutStrategy chooses which edges of a node over MaxFanout are expanded
+type FanoutStrategy string
+
+const (
+	FanoutFirst  FanoutStrategy = "first"  // The first edges by edge ID (default)
+	FanoutRandom FanoutStrategy = "random" // A seeded random sample
+)
+
 // TraversalDirection specifies the direction of traversal
 type TraversalDirection int
 
@@ -80,3 +243,46 @@ const (
 	DirectionBackward                           // Follow incoming edges  
 	DirectionBoth                              // Follow both directions
 )
+
+// GraphDiff lists the nodes and edges that differ between two versions of a
+// graph. Changed entities exist in both versions with a different type,
+// endpoints or attributes.
+type GraphDiff struct {
+	AddedNodes   []models.NodeID `json:"added_nodes"`
+	RemovedNodes []models.NodeID `json:"removed_nodes"`
+	ChangedNodes []models.NodeID `json:"changed_nodes"`
+	AddedEdges   []models.EdgeID `json:"added_edges"`
+	RemovedEdges []models.EdgeID `json:"removed_edges"`
+	ChangedEdges []models.EdgeID `json:"changed_edges"`
+}
+
+// Overlay describes hypothetical changes to a graph for what-if analysis.
+// Removing a node also removes its edges. Overlays are applied over storage
+// reads and never written.
+type Overlay struct {
+	RemovedNodes map[models.NodeID]bool `json:"removed_nodes,omitempty"`
+	RemovedEdges map[models.EdgeID]bool `json:"removed_edges,omitempty"`
+	AddedEdges   []*models.Edge         `json:"added_edges,omitempty"`
+}
+
+// NodePair is an ordered pair of nodes
+type NodePair struct {
+	From models.NodeID `json:"from"`
+	To   models.NodeID `json:"to"`
+}
+
+// WhatIfSummary compares the reachability of source and target pairs with
+// and without an overlay. Lost pairs are only reachable without it, gained
+// pairs only with it.
+type WhatIfSummary struct {
+	Pairs           int        `json:"pairs"`
+	ReachableBefore int        `json:"reachable_before"`
+	ReachableAfter  int        `json:"reachable_after"`
+	Lost            []NodePair `json:"lost"`
+	Gained          []NodePair `json:"gained"`
+
+	// Critical lists the edges on every shortest path of a pair still
+	// reachable with the overlay, each a single point of failure for it.
+	// WhatIfStats leaves it empty; WhatIfCriticalEdges computes it.
+	Critical []models.EdgeID `json:"critical,omitempty"`
+}
@@ -10,20 +10,200 @@ import (
 
 // Key prefixes for different data types
 const (
-	GraphPrefix     = "g:"
-	NodePrefix      = "n:"
-	EdgePrefix      = "e:"
-	NodeIndexPrefix = "ni:"
-	EdgeIndexPrefix   = "ei:"
-	TypeIndexPrefix   = "ti:"
-	ExpiryIndexPrefix = "xi:"
+	GraphPrefix        = "g:"
+	NodePrefix         = "n:"
+	EdgePrefix         = "e:"
+	NodeIndexPrefix    = "ni:"
+	EdgeIndexPrefix    = "ei:"
+	TypeIndexPrefix    = "ti:"
+	ExpiryIndexPrefix  = "xi:"
+	EdgeExpiryPrefix   = "xe:"
+	QueryPrefix        = "q:"
+	SnapshotPrefix     = "s:"
+	SnapshotDataPrefix = "sd:"
+	ReadCountPrefix    = "hr:"
+	MaintenancePrefix  = "mr:"
+	MetaPrefix         = "m:"
+	AttributePrefix    = "ai:"
+	ReindexPrefix      = "rx:"
+	AliasPrefix        = "al:"
+	AliasIndexPrefix   = "na:"
+	ActivityPrefix     = "act:"
+	DeletionPrefix     = "gd:"
+	GenerationPrefix   = "gen:"
+	StatsHistoryPrefix = "sh:"
+	DeletionLogPrefix  = "dl:"
+	TypeCountPrefix    = "tc:"
+	RenamePrefix       = "gr:"
 )
 
+// KeyPrefixes registers the prefix of every key family. A new family must
+// be added here, with a prefix that is neither a prefix of a registered one
+// nor starts with one, so that scanning one family never reads another.
+var KeyPrefixes = []string{
+	GraphPrefix,
+	NodePrefix,
+	EdgePrefix,
+	NodeIndexPrefix,
+	EdgeIndexPrefix,
+	TypeIndexPrefix,
+	ExpiryIndexPrefix,
+	EdgeExpiryPrefix,
+	QueryPrefix,
+	SnapshotPrefix,
+	SnapshotDataPrefix,
+	ReadCountPrefix,
+	MaintenancePrefix,
+	MetaPrefix,
+	AttributePrefix,
+	ReindexPrefix,
+	AliasPrefix,
+	AliasIndexPrefix,
+	ActivityPrefix,
+	DeletionPrefix,
+	GenerationPrefix,
+	StatsHistoryPrefix,
+	DeletionLogPrefix,
+	TypeCountPrefix,
+	RenamePrefix,
+}
+
+// CheckGraphID rejects graph IDs that start with a registered key prefix.
+// Keys embed graph IDs right after their family prefix, so such an ID
+// makes keys like "g:n:web" read as keys of two families to raw scans and
+// SYSTEM.KEYAUDIT.
+func CheckGraphID(graphID models.GraphID) error {
+	for _, prefix := range KeyPrefixes {
+		if strings.HasPrefix(string(graphID), prefix) {
+			return fmt.Errorf("%w graph ID %q starts with reserved key prefix %q", models.ErrBadArgument, graphID, prefix)
+		}
+	}
+	return nil
+}
+
+// DeletionLogTimeLayout is the fixed-width UTC timestamp of deletion log
+// keys, so a graph's entries sort by the time of the deletion
+const DeletionLogTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
 // EncodeGraphKey creates a key for storing graph metadata
 func EncodeGraphKey(graphID models.GraphID) []byte {
 	return []byte(GraphPrefix + string(graphID))
 }
 
+// EncodeQueryKey creates a key for storing a named query
+func EncodeQueryKey(name string) []byte {
+	return []byte(QueryPrefix + name)
+}
+
+// EncodeSnapshotKey creates a key for storing snapshot metadata
+func EncodeSnapshotKey(graphID models.GraphID, snapshotID string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s", SnapshotPrefix, graphID, snapshotID))
+}
+
+// EncodeSnapshotDataKey creates a key for storing compressed snapshot contents
+func EncodeSnapshotDataKey(graphID models.GraphID, snapshotID string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s", SnapshotDataPrefix, graphID, snapshotID))
+}
+
+// CreateSnapshotIteratorPrefix creates a prefix for iterating over the snapshots of a graph
+func CreateSnapshotIteratorPrefix(graphID models.GraphID) []byte {
+	return []byte(fmt.Sprintf("%s%s:", SnapshotPrefix, graphID))
+}
+
+// CreateSnapshotDataIteratorPrefix creates a prefix for iterating over the snapshot contents of a graph
+func CreateSnapshotDataIteratorPrefix(graphID models.GraphID) []byte {
+	return []byte(fmt.Sprintf("%s%s:", SnapshotDataPrefix, graphID))
+}
+
+// EncodeReadCountKey creates a key for storing the read count of a node
+func EncodeReadCountKey(graphID models.GraphID, nodeID models.NodeID) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s", ReadCountPrefix, graphID, nodeID))
+}
+
+// EncodeMaintenanceKey creates a key for storing the last maintenance run of a graph
+func EncodeMaintenanceKey(graphID models.GraphID) []byte {
+	return []byte(MaintenancePrefix + string(graphID))
+}
+
+// EncodeActivityKey creates a key for storing the hourly mutation counts of a graph
+func EncodeActivityKey(graphID models.GraphID) []byte {
+	return []byte(ActivityPrefix + string(graphID))
+}
+
+// EncodeGraphDeletionKey creates a key for marking a graph whose deletion is in progress
+func EncodeGraphDeletionKey(graphID models.GraphID) []byte {
+	return []byte(DeletionPrefix + string(graphID))
+}
+
+// EncodeGraphRenameKey creates a key for marking a graph whose rename is in progress
+func EncodeGraphRenameKey(graphID models.GraphID) []byte {
+	return []byte(RenamePrefix + string(graphID))
+}
+
+// EncodeGenerationKey creates a key whose version tracks a graph's latest committed write
+func EncodeGenerationKey(graphID models.GraphID) []byte {
+	return []byte(GenerationPrefix + string(graphID))
+}
+
+// EncodeStatsHistoryKey creates a key for storing a graph's stats snapshot of a date (YYYY-MM-DD)
+func EncodeStatsHistoryKey(graphID models.GraphID, date string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s", StatsHistoryPrefix, graphID, date))
+}
+
+// CreateStatsHistoryIteratorPrefix creates a prefix for iterating over the stats snapshots of a graph
+func CreateStatsHistoryIteratorPrefix(graphID models.GraphID) []byte {
+	return []byte(fmt.Sprintf("%s%s:", StatsHistoryPrefix, graphID))
+}
+
+// EncodeDeletionLogKey creates a key recording that a node ("n") or edge ("e") of a graph was deleted
+func EncodeDeletionLogKey(graphID models.GraphID, deletedAt time.Time, kind string, id string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%s:%s", DeletionLogPrefix, graphID, deletedAt.UTC().Format(DeletionLogTimeLayout), kind, id))
+}
+
+// CreateDeletionLogIteratorPrefix creates a prefix for iterating over the deletion log of a graph
+func CreateDeletionLogIteratorPrefix(graphID models.GraphID) []byte {
+	return []byte(fmt.Sprintf("%s%s:", DeletionLogPrefix, graphID))
+}
+
+// DecodeDeletionLogKey decodes the time, kind and ID of a deletion log entry of a graph. The timestamp
+// contains colons itself, so it is sliced by length; ok is false for keys of other graphs sharing the prefix.
+func DecodeDeletionLogKey(graphID models.GraphID, key []byte) (deletedAt time.Time, kind string, id string, ok bool) {
+	rest := strings.TrimPrefix(string(key), string(CreateDeletionLogIteratorPrefix(graphID)))
+	width := len(DeletionLogTimeLayout)
+	if len(rest) <= width || rest[width] != ':' {
+		return time.Time{}, "", "", false
+	}
+	deletedAt, err := time.Parse(DeletionLogTimeLayout, rest[:width])
+	if err != nil {
+		return time.Time{}, "", "", false
+	}
+	kind, id, ok = strings.Cut(rest[width+1:], ":")
+	if !ok || (kind != "n" && kind != "e") {
+		return time.Time{}, "", "", false
+	}
+	return deletedAt, kind, id, true
+}
+
+// EncodeMetaKey creates a key for storing a metadata value of a graph
+func EncodeMetaKey(graphID models.GraphID, namespace string, key string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%s", MetaPrefix, graphID, namespace, key))
+}
+
+// CreateMetaIteratorPrefix creates a prefix for iterating over one metadata namespace of a graph
+func CreateMetaIteratorPrefix(graphID models.GraphID, namespace string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:", MetaPrefix, graphID, namespace))
+}
+
+// CreateGraphMetaIteratorPrefix creates a prefix for iterating over all metadata of a graph
+func CreateGraphMetaIteratorPrefix(graphID models.GraphID) []byte {
+	return []byte(fmt.Sprintf("%s%s:", MetaPrefix, graphID))
+}
+
+// CreateReadCountIteratorPrefix creates a prefix for iterating over the read counts of a graph
+func CreateReadCountIteratorPrefix(graphID models.GraphID) []byte {
+	return []byte(fmt.Sprintf("%s%s:", ReadCountPrefix, graphID))
+}
+
 // EncodeNodeKey creates a key for storing a node
 func EncodeNodeKey(graphID models.GraphID, nodeID models.NodeID) []byte {
 	return []byte(fmt.Sprintf("%s%s:%s", NodePrefix, graphID, nodeID))
@@ -44,6 +224,27 @@ func EncodeEdgeTypeIndexKey(graphID models.GraphID, edgeType models.EdgeType, ed
 	return []byte(fmt.Sprintf("%se:%s:%s:%s", TypeIndexPrefix, graphID, edgeType, edgeID))
 }
 
+// EncodeNodeTypeCountKey creates a key for storing the number of nodes of a type in a graph
+func EncodeNodeTypeCountKey(graphID models.GraphID, nodeType models.NodeType) []byte {
+	return []byte(fmt.Sprintf("%sn:%s:%s", TypeCountPrefix, graphID, nodeType))
+}
+
+// EncodeEdgeTypeCountKey creates a key for storing the number of edges of a type in a graph
+func EncodeEdgeTypeCountKey(graphID models.GraphID, edgeType models.EdgeType) []byte {
+	return []byte(fmt.Sprintf("%se:%s:%s", TypeCountPrefix, graphID, edgeType))
+}
+
+// CreateTypeCountIteratorPrefix creates a prefix for iterating over the type counts of a graph,
+// entityType being "n" for nodes or "e" for edges
+func CreateTypeCountIteratorPrefix(graphID models.GraphID, entityType string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:", TypeCountPrefix, entityType, graphID))
+}
+
+// EncodeTypeCountMarkerKey creates a key marking that a graph's type counts are complete
+func EncodeTypeCountMarkerKey(graphID models.GraphID) []byte {
+	return []byte(TypeCountPrefix + "g:" + string(graphID))
+}
+
 // EncodeNodeOutEdgeIndexKey creates a key for indexing outgoing edges from a node
 func EncodeNodeOutEdgeIndexKey(graphID models.GraphID, nodeID models.NodeID, edgeID models.EdgeID) []byte {
 	return []byte(fmt.Sprintf("%sout:%s:%s:%s", NodeIndexPrefix, graphID, nodeID, edgeID))
@@ -54,11 +255,27 @@ func EncodeNodeInEdgeIndexKey(graphID models.GraphID, nodeID models.NodeID, edge
 	return []byte(fmt.Sprintf("%sin:%s:%s:%s", NodeIndexPrefix, graphID, nodeID, edgeID))
 }
 
+// attributeKeyEscaper escapes ":" in attribute keys, and "%" so escaped keys
+// stay distinct, so that a key containing ":" cannot read as a shorter key
+// followed by the start of a value
+var attributeKeyEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
 // EncodeAttributeIndexKey creates a key for indexing nodes/edges by attribute
 func EncodeAttributeIndexKey(graphID models.GraphID, entityType string, attrKey string, attrValue string, entityID string) []byte {
-	return []byte(fmt.Sprintf("ai:%s:%s:%s:%s:%s", graphID, entityType, attrKey, attrValue, entityID))
+	return []byte(fmt.Sprintf("%s%s:%s:%s:%s:%s", AttributePrefix, graphID, entityType, attributeKeyEscaper.Replace(attrKey), attrValue, entityID))
+}
+
+// CreateAttributeIteratorPrefix creates a prefix for iterating over the entities with one attribute value
+func CreateAttributeIteratorPrefix(graphID models.GraphID, entityType string, attrKey string, attrValue string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%s:%s:", AttributePrefix, graphID, entityType, attributeKeyEscaper.Replace(attrKey), attrValue))
+}
+
+// EncodeReindexKey creates a key for storing the backfill state of one index of a graph
+func EncodeReindexKey(graphID models.GraphID, index string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s", ReindexPrefix, graphID, index))
 }
 
+
 // DecodeGraphID extracts graph ID from a graph key
 func DecodeGraphID(key []byte) models.GraphID {
 	keyStr := string(key)
@@ -115,14 +332,32 @@ func EncodeExpiryIndexKey(graphID models.GraphID, nodeID models.NodeID, expiresA
 
 // DecodeExpiryIndexKey decodes the graph ID and node ID from an expiry index key.
 func DecodeExpiryIndexKey(key []byte) (graphID models.GraphID, nodeID models.NodeID) {
-	keyStr := strings.TrimPrefix(string(key), ExpiryIndexPrefix)
+	graph, id := decodeExpiryKey(strings.TrimPrefix(string(key), ExpiryIndexPrefix))
+	return models.GraphID(graph), models.NodeID(id)
+}
+
+// EncodeEdgeExpiryIndexKey creates a key for the edge expiration index. It
+// is laid out as the node expiration index is.
+func EncodeEdgeExpiryIndexKey(graphID models.GraphID, edgeID models.EdgeID, expiresAt time.Time) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%s", EdgeExpiryPrefix, expiresAt.UTC().Format(time.RFC3339), graphID, edgeID))
+}
+
+// DecodeEdgeExpiryIndexKey decodes the graph ID and edge ID from an edge
+// expiry index key.
+func DecodeEdgeExpiryIndexKey(key []byte) (graphID models.GraphID, edgeID models.EdgeID) {
+	graph, id := decodeExpiryKey(strings.TrimPrefix(string(key), EdgeExpiryPrefix))
+	return models.GraphID(graph), models.EdgeID(id)
+}
 
-	// Find the last colon, which separates the node ID.
+// decodeExpiryKey splits the graph ID and entity ID from an expiry index
+// key without its prefix
+func decodeExpiryKey(keyStr string) (graphID, id string) {
+	// Find the last colon, which separates the entity ID.
 	lastColon := strings.LastIndex(keyStr, ":")
 	if lastColon == -1 {
 		return "", ""
 	}
-	nodeID = models.NodeID(keyStr[lastColon+1:])
+	id = keyStr[lastColon+1:]
 
 	// Find the second to last colon, which separates the graph ID.
 	remaining := keyStr[:lastColon]
@@ -130,12 +365,53 @@ func DecodeExpiryIndexKey(key []byte) (graphID models.GraphID, nodeID models.Nod
 	if secondLastColon == -1 {
 		return "", ""
 	}
-	graphID = models.GraphID(remaining[secondLastColon+1:])
+	graphID = remaining[secondLastColon+1:]
 
-	return graphID, nodeID
+	return graphID, id
+}
+
+// DecodeExpiryIndexTime returns the RFC3339 expiry timestamp encoded in a node or edge expiry index key.
+// The timestamp contains colons itself, so it is sliced by length rather than split.
+func DecodeExpiryIndexTime(key []byte) string {
+	keyStr := strings.TrimPrefix(strings.TrimPrefix(string(key), ExpiryIndexPrefix), EdgeExpiryPrefix)
+	width := len("2006-01-02T15:04:05Z")
+	if len(keyStr) < width {
+		return keyStr
+	}
+	return keyStr[:width]
 }
 
 // CreateExpiryIteratorPrefix creates a prefix for iterating over the expiry index.
 func CreateExpiryIteratorPrefix() []byte {
 	return []byte(ExpiryIndexPrefix)
 }
+
+// CreateEdgeExpiryIteratorPrefix creates a prefix for iterating over the edge expiry index.
+func CreateEdgeExpiryIteratorPrefix() []byte {
+	return []byte(EdgeExpiryPrefix)
+}
+
+// EncodeAliasKey creates a key for resolving a node alias to its node ID
+func EncodeAliasKey(graphID models.GraphID, alias string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s", AliasPrefix, graphID, alias))
+}
+
+// EncodeAliasIndexKey creates a key for indexing the aliases of a node
+func EncodeAliasIndexKey(graphID models.GraphID, nodeID models.NodeID, alias string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%s", AliasIndexPrefix, graphID, nodeID, alias))
+}
+
+// CreateAliasIndexIteratorPrefix creates a prefix for iterating over the aliases of a node
+func CreateAliasIndexIteratorPrefix(graphID models.GraphID, nodeID models.NodeID) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:", AliasIndexPrefix, graphID, nodeID))
+}
+
+// CreateGraphAliasIteratorPrefix creates a prefix for iterating over all node aliases of a graph
+func CreateGraphAliasIteratorPrefix(graphID models.GraphID) []byte {
+	return []byte(fmt.Sprintf("%s%s:", AliasPrefix, graphID))
+}
+
+// CreateGraphAliasIndexIteratorPrefix creates a prefix for iterating over the alias index of a graph
+func CreateGraphAliasIndexIteratorPrefix(graphID models.GraphID) []byte {
+	return []byte(fmt.Sprintf("%s%s:", AliasIndexPrefix, graphID))
+}
@@ -0,0 +1,13 @@
+// Package version holds the version and commit the binary was built from,
+// set at build time with
+//
+//	go build -ldflags "-X github.com/ywadi/PathwayDB/version.Version=1.4.0 -X github.com/ywadi/PathwayDB/version.Commit=$(git rev-parse --short HEAD)" ./cmd/redis-server
+//
+// Builds without the flags report the defaults below.
+package version
+
+// Version is the semantic version of the server
+var Version = "1.0.0"
+
+// Commit is the git commit the server was built from
+var Commit = "unknown"